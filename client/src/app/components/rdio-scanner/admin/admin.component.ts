@@ -31,12 +31,18 @@ export class RdioScannerAdminComponent implements OnDestroy {
 
     groups: Group[] = [];
 
+    notifications = this.adminService.notifications;
+
     tags: Tag[] = [];
 
     private eventSubscription = this.adminService.event.subscribe(async (event: AdminEvent) => {
         if ('authenticated' in event) {
             this.authenticated = event.authenticated || false;
         }
+
+        if ('notifications' in event) {
+            this.notifications = event.notifications || 0;
+        }
     });
 
     constructor(private adminService: RdioScannerAdminService) { }
@@ -24,50 +24,84 @@ import { RdioScannerAdminComponent } from './admin.component';
 import { RdioScannerAdminService } from './admin.service';
 import { RdioScannerAdminConfigComponent } from './config/config.component';
 import { RdioScannerAdminAccessComponent } from './config/access/access.component';
+import { RdioScannerAdminAlertsComponent } from './config/alerts/alerts.component';
 import { RdioScannerAdminApiKeysComponent } from './config/api-keys/api-keys.component';
 import { RdioScannerAdminDirWatchComponent } from './config/dir-watch/dir-watch.component';
 import { RdioScannerAdminDownstreamsComponent } from './config/downstreams/downstreams.component';
 import { RdioScannerAdminGroupsComponent } from './config/groups/groups.component';
 import { RdioScannerAdminOptionsComponent } from './config/options/options.component';
 import { RdioScannerAdminSystemsSelectComponent } from './config/systems/select/select.component';
+import { RdioScannerAdminRetentionsComponent } from './config/retentions/retentions.component';
+import { RdioScannerAdminSdrComponent } from './config/sdr/sdr.component';
 import { RdioScannerAdminSystemComponent } from './config/systems/system/system.component';
 import { RdioScannerAdminSystemsComponent } from './config/systems/systems.component';
 import { RdioScannerAdminTalkgroupComponent } from './config/systems/talkgroup/talkgroup.component';
 import { RdioScannerAdminUnitComponent } from './config/systems/unit/unit.component';
 import { RdioScannerAdminTagsComponent } from './config/tags/tags.component';
+import { RdioScannerAdminWebhooksComponent } from './config/webhooks/webhooks.component';
 import { RdioScannerAdminLoginComponent } from './login/login.component';
 import { RdioScannerAdminLogsComponent } from './logs/logs.component';
+import { RdioScannerAdminNotificationsComponent } from './notifications/notifications.component';
 import { RdioScannerAdminTodosComponent } from './todos/todos.component';
 import { RdioScannerAdminToolsComponent } from './tools/tools.component';
+import { RdioScannerAdminBackupsComponent } from './tools/backups/backups.component';
+import { RdioScannerAdminBansComponent } from './tools/bans/bans.component';
+import { RdioScannerAdminBlacklistsComponent } from './tools/blacklists/blacklists.component';
+import { RdioScannerAdminBookmarksComponent } from './tools/bookmarks/bookmarks.component';
+import { RdioScannerAdminDuplicatesComponent } from './tools/duplicates/duplicates.component';
 import { RdioScannerAdminImportExportConfigComponent } from './tools/import-export-config/import-export-config.component';
+import { RdioScannerAdminIncidentsComponent } from './tools/incidents/incidents.component';
 import { RdioScannerAdminImportTalkgroupsComponent } from './tools/import-talkgroups/import-talkgroups.component';
 import { RdioScannerAdminImportUnitsComponent } from './tools/import-units/import-units.component';
 import { RdioScannerAdminPasswordComponent } from './tools/password/password.component';
+import { RdioScannerAdminStatsComponent } from './tools/stats/stats.component';
+import { RdioScannerAdminTelemetryComponent } from './tools/telemetry/telemetry.component';
+import { RdioScannerAdminTwoFactorComponent } from './tools/two-factor/two-factor.component';
+import { RdioScannerAdminUnitAliasesComponent } from './tools/unit-aliases/unit-aliases.component';
+import { RdioScannerAdminUsersComponent } from './tools/users/users.component';
+import { RdioScannerAdminWatermarkComponent } from './tools/watermark/watermark.component';
 
 @NgModule({
     declarations: [
         RdioScannerAdminComponent,
         RdioScannerAdminConfigComponent,
         RdioScannerAdminAccessComponent,
+        RdioScannerAdminAlertsComponent,
         RdioScannerAdminApiKeysComponent,
+        RdioScannerAdminBackupsComponent,
+        RdioScannerAdminBansComponent,
+        RdioScannerAdminBlacklistsComponent,
+        RdioScannerAdminBookmarksComponent,
         RdioScannerAdminDirWatchComponent,
         RdioScannerAdminDownstreamsComponent,
+        RdioScannerAdminDuplicatesComponent,
         RdioScannerAdminGroupsComponent,
         RdioScannerAdminImportExportConfigComponent,
         RdioScannerAdminImportTalkgroupsComponent,
         RdioScannerAdminImportUnitsComponent,
+        RdioScannerAdminIncidentsComponent,
         RdioScannerAdminLoginComponent,
         RdioScannerAdminLogsComponent,
+        RdioScannerAdminNotificationsComponent,
         RdioScannerAdminOptionsComponent,
         RdioScannerAdminPasswordComponent,
+        RdioScannerAdminRetentionsComponent,
+        RdioScannerAdminSdrComponent,
         RdioScannerAdminSystemComponent,
         RdioScannerAdminSystemsComponent,
         RdioScannerAdminSystemsSelectComponent,
         RdioScannerAdminTagsComponent,
         RdioScannerAdminTalkgroupComponent,
+        RdioScannerAdminStatsComponent,
+        RdioScannerAdminTelemetryComponent,
         RdioScannerAdminTodosComponent,
         RdioScannerAdminToolsComponent,
+        RdioScannerAdminTwoFactorComponent,
+        RdioScannerAdminUnitAliasesComponent,
         RdioScannerAdminUnitComponent,
+        RdioScannerAdminUsersComponent,
+        RdioScannerAdminWatermarkComponent,
+        RdioScannerAdminWebhooksComponent,
     ],
     entryComponents: [RdioScannerAdminSystemsSelectComponent],
     exports: [RdioScannerAdminComponent],
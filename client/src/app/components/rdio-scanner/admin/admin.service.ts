@@ -21,29 +21,440 @@ import { HttpClient, HttpErrorResponse, HttpHeaders } from '@angular/common/http
 import { EventEmitter, Injectable, OnDestroy } from '@angular/core';
 import { AbstractControl, FormBuilder, FormGroup, ValidationErrors, ValidatorFn, Validators } from '@angular/forms';
 import { MatSnackBar } from '@angular/material/snack-bar';
-import { firstValueFrom, timer } from 'rxjs';
+import { firstValueFrom, Subscription, timer } from 'rxjs';
 import { AppUpdateService } from '../../../shared/update/update.service';
 
 export interface Access {
     _id?: string;
+    archive?: string;
+    archiveHours?: number | null;
     code?: string;
+    deny?: {
+        id: number;
+        talkgroups: {
+            id: number;
+        }[] | number[] | '*';
+    }[] | number[] | '*' | null;
     expiration?: Date;
+    hours?: number;
     ident?: string;
     limit?: number;
     order?: number;
+    schedule?: AccessWindow[] | null;
+    streamOnly?: boolean;
     systems?: {
         id: number;
         talkgroups: {
             id: number;
         }[] | number[] | '*';
     }[] | number[] | '*';
+    watermark?: string;
+}
+
+export interface AccessWindow {
+    days?: number[] | null;
+    from?: string;
+    to?: string;
+}
+
+export interface Alert {
+    _id?: number;
+    anomalies?: boolean;
+    burstCount?: number;
+    burstWindow?: number;
+    cooldown?: number;
+    delivery?: 'email' | 'pushover' | 'telegram' | 'webhook';
+    disabled?: boolean;
+    keywords?: string;
+    label?: string;
+    order?: number;
+    systems?: {
+        id: number;
+        talkgroups: number[] | '*';
+    }[] | number[] | '*';
+    target?: string;
+    units?: string;
+}
+
+export interface AdminBackup {
+    dateTime: string;
+    location: 'local' | 's3';
+    name: string;
+    size: number;
+}
+
+export interface AdminBackups {
+    backups: AdminBackup[];
+    enabled: boolean;
+    lastError?: string;
+    lastRun?: string;
+    next?: string;
+    running: boolean;
+}
+
+export interface AdminBan {
+    address: string;
+    count: number;
+    reason: 'failures' | 'rejections';
+    since: string;
+    until: string;
+}
+
+export interface AdminBlacklistCounter {
+    count: number;
+    dateTime: string;
+    system: number;
+    systemLabel?: string;
+    talkgroup: number;
+    talkgroupLabel?: string;
+    type: 'talkgroup' | 'unit';
+    unit?: number;
+}
+
+export interface AdminBlacklists {
+    counters: AdminBlacklistCounter[];
+    since: string;
+    talkgroups: number;
+    units: number;
+}
+
+export interface AdminBookmark {
+    _id: number;
+    callDateTime: string;
+    callId: number;
+    dateTime: string;
+    note: string;
+    owner: string;
+    shared: boolean;
+    system: number;
+    systemLabel?: string;
+    talkgroup: number;
+    talkgroupLabel?: string;
+    updated: string;
+}
+
+export interface AdminBookmarks {
+    bookmarks: AdminBookmark[];
+    count: number;
+}
+
+export interface AdminBookmarksOptions {
+    callId?: number;
+    limit?: number;
+    mine?: boolean;
+    offset?: number;
+    q?: string;
+    system?: number;
+    talkgroup?: number;
+}
+
+export interface AdminConfigImport {
+    config?: Config;
+    dryRun: boolean;
+    revision?: number;
+    sections: { count: number; errors: string[]; section: string; }[];
+    valid: boolean;
+}
+
+export interface AdminDeliveryTest {
+    delivery: string;
+    duration: number;
+    error?: string;
+    payload?: string;
+    response?: string;
+    status?: number;
+    success: boolean;
+    target: string;
+}
+
+export interface AdminDuplicate {
+    callDateTime: string;
+    dateTime: string;
+    delta: number;
+    grouped: boolean;
+    originalDateTime: string;
+    originalId: number;
+    system: number;
+    systemLabel: string;
+    talkgroup: number;
+    talkgroupLabel: string;
+    uploader: string;
+}
+
+export interface AdminDuplicates {
+    checked: number;
+    disabled: boolean;
+    grouped: number;
+    ratio: number;
+    recent: AdminDuplicate[];
+    rejected: number;
+    since: string;
+    timeFrame: number;
+    uploaders: { count: number; uploader: string; }[];
 }
 
 export interface AdminEvent {
     authenticated?: boolean;
     config?: Config;
     docker?: boolean;
+    notice?: AdminNotice;
+    notification?: AdminNotification;
+    notifications?: number;
     passwordNeedChange?: boolean;
+    presence?: AdminPresence[];
+    readOnly?: boolean;
+    restartRequired?: string[];
+}
+
+export interface AdminIncident {
+    _id?: number;
+    calls?: AdminIncidentCall[];
+    callsCount?: number;
+    createdBy?: string;
+    dateTime?: string;
+    description: string;
+    notes?: AdminIncidentNote[];
+    status: AdminIncidentStatus;
+    title: string;
+    updated?: string;
+}
+
+export interface AdminIncidentCall {
+    callId: number;
+    dateTime: string;
+    duration?: number;
+    source?: number;
+    sourceLabel?: string;
+    system: number;
+    systemLabel: string;
+    talkgroup: number;
+    talkgroupLabel: string;
+    transcript?: string;
+}
+
+export interface AdminIncidentCallsFilter {
+    from: string;
+    system?: number;
+    talkgroup?: number;
+    to: string;
+}
+
+export interface AdminIncidentNote {
+    _id: number;
+    author: string;
+    dateTime: string;
+    note: string;
+}
+
+export type AdminIncidentStatus = 'closed' | 'draft' | 'open';
+
+export interface AdminOptionSchema {
+    default: boolean | number | string;
+    enum?: string[];
+    max?: number;
+    min?: number;
+    name: string;
+    restart: boolean;
+    secret?: boolean;
+    type: 'boolean' | 'integer' | 'string';
+}
+
+export interface AdminPresence {
+    address?: string;
+    id?: string;
+    role?: string;
+    section?: string;
+    self?: boolean;
+    since?: string;
+    username?: string;
+}
+
+export interface AdminHeartbeat {
+    address: string;
+    apikey: number;
+    ident: string;
+    lastSeen: string;
+    silent: boolean;
+    status?: unknown;
+}
+
+export interface AdminMonitorSystem {
+    lastCall: string;
+    silent: boolean;
+    silentSince?: string;
+    system: number;
+}
+
+export interface AdminNotice {
+    ident?: string;
+    lastCall?: string;
+    lastSeen?: string;
+    message?: string;
+    revision?: number;
+    sections?: string[];
+    system?: number;
+    type?: 'heartbeat' | 'heartbeatLost' | 'resumed' | 'silence' | 'watchdog';
+}
+
+export interface AdminNotification {
+    _id: number;
+    count: number;
+    dateTime: string;
+    key: string;
+    kind: 'disk' | 'downstream' | 'update';
+    message: string;
+    read: boolean;
+    updated: string;
+}
+
+export interface AdminNotifications {
+    notifications: AdminNotification[];
+    unread: number;
+}
+
+export interface AdminRetentionMatch {
+    days: number;
+    expires?: string;
+    label?: string;
+    policyId?: number;
+    scope: 'default' | 'system' | 'tag' | 'talkgroup';
+}
+
+export interface AdminStatsBucket {
+    airtime: number;
+    calls: number;
+    dateTime: string;
+}
+
+export interface AdminStats {
+    airtime: number;
+    buckets: AdminStatsBucket[];
+    calls: number;
+    listeners: number;
+    options: AdminStatsOptions;
+    talkgroups: AdminStatsTalkgroup[];
+    units: AdminStatsUnit[];
+}
+
+export interface AdminStatsOptions {
+    bucket?: 'day' | 'hour' | 'week';
+    from?: string;
+    linked?: boolean;
+    system?: number;
+    talkgroup?: number;
+    to?: string;
+    top?: number;
+}
+
+export interface AdminStatsTalkgroup {
+    airtime: number;
+    buckets: AdminStatsBucket[];
+    calls: number;
+    listeners: number;
+    system: number;
+    systemLabel?: string;
+    talkgroup: number;
+    talkgroupLabel?: string;
+    units: AdminStatsUnit[];
+}
+
+export interface AdminStatsUnit {
+    calls: number;
+    label?: string;
+    system: number;
+    unit: number;
+}
+
+export interface AdminTotp {
+    enabled: boolean;
+    secret?: string;
+    uri?: string;
+}
+
+export interface AdminUnitAliasChange {
+    added?: boolean;
+    id: number;
+    label: string;
+    previous?: string;
+    removed?: boolean;
+    system: number;
+}
+
+export interface AdminUnitAliases {
+    dryRun: boolean;
+    ranges: { from: number; label: string; overwrite: boolean; to: number; }[];
+    renames: { pattern: string; replace: string; }[];
+    retroactive: boolean;
+    system: number;
+}
+
+export interface AdminUnitAliasesResult {
+    changes: AdminUnitAliasChange[];
+    dryRun: boolean;
+    revision?: number;
+}
+
+export interface AdminUnitsImport {
+    conflict: string;
+    csv?: string;
+    dryRun: boolean;
+    system: number;
+    url?: string;
+}
+
+export interface AdminUnitsImportResult extends AdminUnitAliasesResult {
+    units: number;
+}
+
+export interface AdminUser {
+    _id?: number;
+    disabled?: boolean;
+    password?: string;
+    role?: AdminUserRole;
+    username?: string;
+}
+
+export type AdminUserRole = 'admin' | 'editor' | 'viewer';
+
+export interface AdminTalkgroupImportChange {
+    added?: boolean;
+    group: string;
+    id: number;
+    label: string;
+    name: string;
+    previous?: { group: string; label: string; name: string; tag: string; };
+    system: number;
+    tag: string;
+}
+
+export interface AdminTalkgroupsImport {
+    conflict: string;
+    dryRun: boolean;
+    file?: string;
+    radioReferenceSystemId?: number;
+    system: number;
+}
+
+export interface AdminTalkgroupsImportResult {
+    changes: AdminTalkgroupImportChange[];
+    dryRun: boolean;
+    revision?: number;
+    talkgroups: number;
+}
+
+export interface AdminTelemetry {
+    enabled: boolean;
+    lastError?: string;
+    lastSent?: string;
+    report?: { [key: string]: unknown };
+}
+
+export interface AdminWatermarkTrace {
+    access?: { _id: number; code: string; ident: string; };
+    found: boolean;
+    id?: number;
+    source?: 'id' | 'metadata' | 'signal';
+    votes?: number;
 }
 
 export interface ApiKey {
@@ -52,6 +463,7 @@ export interface ApiKey {
     ident?: string;
     key?: string;
     order?: number;
+    scopes?: string;
     systems?: {
         id: number;
         talkgroups: number[] | '*';
@@ -60,17 +472,22 @@ export interface ApiKey {
 
 export interface Config {
     access?: Access[];
+    alerts?: Alert[];
     apiKeys?: ApiKey[];
     dirWatch?: DirWatch[];
     downstreams?: Downstream[];
     groups?: Group[];
     options?: Options;
+    retentions?: Retention[];
+    sdr?: Sdr[];
     systems?: System[];
     tags?: Tag[];
+    webhooks?: Webhook[];
 }
 
 export interface DirWatch {
     _id?: string;
+    archiveDir?: string;
     delay?: number;
     deleteAfter?: boolean;
     directory?: string;
@@ -79,9 +496,12 @@ export interface DirWatch {
     frequency?: number;
     mask?: string;
     order?: number;
+    password?: string;
+    pollInterval?: number;
     systemId?: number;
     talkgroupId?: number;
     type?: string;
+    username?: string;
 }
 
 export interface Downstream {
@@ -129,32 +549,177 @@ export interface LogsQueryOptions {
 }
 
 export interface Options {
+    accessLog?: string;
+    adminSessionExpiry?: number;
+    adminTokenExpiry?: number;
     afsSystems?: string;
+    alertPushoverToken?: string;
+    alertSmtpFrom?: string;
+    alertSmtpPassword?: string;
+    alertSmtpServer?: string;
+    alertSmtpUsername?: string;
+    alertTelegramToken?: string;
+    anomalyDetection?: boolean;
+    anomalySilenceDays?: number;
+    anomalyVolumeFactor?: number;
+    audioBitrate?: number;
+    audioChannels?: number;
+    audioCodec?: string;
+    audioLoudnessTarget?: number;
+    audioNormalization?: boolean;
+    audioStorage?: string;
+    audioStorageS3AccessKey?: string;
+    audioStorageS3Bucket?: string;
+    audioStorageS3Endpoint?: string;
+    audioStorageS3PathStyle?: boolean;
+    audioStorageS3Prefix?: string;
+    audioStorageS3Region?: string;
+    audioStorageS3SecretKey?: string;
+    authWebhook?: string;
+    authWebhookSecret?: string;
     autoPopulate?: boolean;
+    backupDirectory?: string;
+    backupRetention?: number;
+    backupS3Bucket?: string;
+    backupSchedule?: string;
+    broadcastDedupWindow?: number;
+    checkForUpdates?: boolean;
+    clockSkewCorrection?: boolean;
+    clockSkewThreshold?: number;
+    conversationGap?: number;
+    conversionWorkers?: number;
     dimmerDelay?: number;
+    directoryLocation?: string;
+    directoryName?: string;
+    directoryPublish?: boolean;
+    directoryUrl?: string;
     disableAudioConversion?: boolean;
     disableDuplicateDetection?: boolean;
+    diskWarningPercent?: number;
     duplicateDetectionTimeFrame?: number;
+    duplicateFingerprintMatch?: number;
+    duplicateFingerprintWindow?: number;
+    heartbeatTimeout?: number;
+    incidentDetection?: boolean;
+    incidentDetectionMinCalls?: number;
+    incidentDetectionThreshold?: number;
+    incidentDetectionWindow?: number;
+    ingestCallbacks?: boolean;
     keypadBeeps?: string;
+    linkedTalkgroups?: boolean;
+    listeningRooms?: boolean;
+    maxCallDuration?: number;
+    maxCallSize?: number;
     maxClients?: number;
+    oidcAdmin?: boolean;
+    oidcAdminGroups?: string;
+    oidcAllowedGroups?: string;
+    oidcClientId?: string;
+    oidcClientSecret?: string;
+    oidcGroupsClaim?: string;
+    oidcIssuer?: string;
+    oidcListeners?: boolean;
     playbackGoesLive?: boolean;
     pruneDays?: number;
+    publicUrl?: string;
+    radioReferenceAppKey?: string;
+    radioReferenceInterval?: number;
+    radioReferencePassword?: string;
+    radioReferenceUsername?: string;
+    rateLimitAdmin?: number;
+    rateLimitBanDuration?: number;
+    rateLimitBanFailures?: number;
+    rateLimitBanRejections?: number;
+    rateLimitUpload?: number;
+    rateLimitWebsocket?: number;
+    relatedCallsWindow?: number;
+    samlAllowedGroups?: string;
+    samlAllowedUsers?: string;
+    samlEntityId?: string;
+    samlGroupRoles?: string;
+    samlGroupsAttribute?: string;
+    samlIdpCertificate?: string;
+    samlIdpUrl?: string;
+    scheduledRestart?: boolean;
+    scheduledRestartHour?: number;
     searchPatchedTalkgroups?: boolean;
+    serverQueue?: boolean;
+    serverQueueMaxDepth?: number;
+    serverQueueSkipPolicy?: string;
+    serverTiming?: boolean;
     showListenersCount?: boolean;
+    silenceAlert?: number;
+    silenceAlertFrom?: number;
+    silenceAlertTo?: number;
     sortTalkgroups?: boolean;
+    ssoRole?: string;
     tagsToggle?: boolean;
+    telemetry?: boolean;
+    telemetryUrl?: string;
+    transcription?: string;
+    transcriptionApiKey?: string;
+    transcriptionCommand?: string;
+    transcriptionLanguage?: string;
+    transcriptionModel?: string;
+    transcriptionUrl?: string;
+    truncateLongCalls?: boolean;
+    trustedProxies?: string;
+    unitsImportInterval?: number;
+    votingWindow?: number;
+    watchdogSelfHeal?: boolean;
+    watchdogStallTimeout?: number;
+}
+
+export interface Retention {
+    _id?: number;
+    days?: number;
+    label?: string;
+    order?: number;
+    system?: number | null;
+    tagId?: number | null;
+    talkgroup?: number | null;
+}
+
+export interface Sdr {
+    _id?: number;
+    command?: string;
+    disabled?: boolean;
+    frequency?: number;
+    hang?: number;
+    maxDuration?: number;
+    minDuration?: number;
+    order?: number;
+    sampleRate?: number;
+    squelch?: number;
+    systemId?: number;
+    talkgroupId?: number;
 }
 
 export interface System {
     _id?: number;
+    audioBitrate?: number | null;
+    audioChannels?: number | null;
+    audioCodec?: string;
+    audioConversion?: string;
+    audioSampleRate?: number | null;
     autoPopulate?: boolean;
     blacklists?: string;
+    broadcastifyApiKey?: string;
+    broadcastifySystemId?: number | null;
+    duplicatePrimary?: string;
+    duplicateStrategy?: string;
     id?: number;
     label?: string;
     led?: string | null;
     order?: number | null;
+    radioReferenceConflict?: string;
+    radioReferenceSystemId?: number | null;
+    streamOnly?: boolean;
     talkgroups?: Talkgroup[];
+    unitBlacklists?: string;
     units?: Unit[];
+    unitsImportConflict?: string;
+    unitsImportUrl?: string;
 }
 
 export interface Tag {
@@ -163,14 +728,21 @@ export interface Tag {
 }
 
 export interface Talkgroup {
+    broadcastify?: boolean;
+    broadcastifySlot?: number | null;
+    delay?: number;
+    downstreamDelay?: number;
     frequency?: number | null;
     groupId?: number;
     id?: number;
     label?: string;
     led?: string | null;
+    linkedSystem?: number | null;
+    linkedTalkgroup?: number | null;
     name?: string;
     order?: number;
     tagId?: number;
+    unitBlacklists?: string;
 }
 
 export interface Unit {
@@ -179,16 +751,64 @@ export interface Unit {
     order?: number;
 }
 
+export interface Webhook {
+    _id?: number;
+    audioLink?: boolean;
+    disabled?: boolean;
+    headers?: string;
+    order?: number;
+    systems?: {
+        id: number;
+        talkgroups: number[] | '*';
+    }[] | number[] | '*';
+    template?: string;
+    url?: string;
+}
+
 enum url {
+    alertsTest = 'alerts/test',
+    backups = 'backups',
+    bans = 'bans',
+    backupsRestore = 'backups/restore',
+    blacklists = 'blacklists',
+    bookmarks = 'bookmarks',
     config = 'config',
+    configExport = 'config/export',
+    configImport = 'config/import',
+    duplicates = 'duplicates',
+    heartbeats = 'heartbeats',
+    incidents = 'incidents',
+    incidentsCalls = 'incidents/calls',
+    incidentsExport = 'incidents/export',
+    incidentsNotes = 'incidents/notes',
     login = 'login',
     logout = 'logout',
     logs = 'logs',
+    saml = 'saml/',
+    samlLogin = 'saml/login',
+    monitor = 'monitor',
+    notifications = 'notifications',
+    optionsSchema = 'options/schema',
     password = 'password',
+    refresh = 'refresh',
+    retentionsTest = 'retentions/test',
+    stats = 'stats',
+    talkgroupsImport = 'talkgroups/import',
+    telemetry = 'telemetry',
+    totp = '2fa',
+    units = 'units',
+    unitsImport = 'units/import',
+    users = 'users',
+    watermark = 'watermark',
+    webhooksTest = 'webhooks/test',
 }
 
 const SESSION_STORAGE_KEY = 'rdio-scanner-admin-token';
 
+const SESSION_STORAGE_REFRESH_KEY = 'rdio-scanner-admin-refresh';
+
+const SESSION_STORAGE_ROLE_KEY = 'rdio-scanner-admin-role';
+
 @Injectable()
 export class RdioScannerAdminService implements OnDestroy {
     event = new EventEmitter<AdminEvent>();
@@ -201,16 +821,56 @@ export class RdioScannerAdminService implements OnDestroy {
         return this._docker;
     }
 
+    get notifications() {
+        return this._notifications;
+    }
+
     get passwordNeedChange() {
         return this._passwordNeedChange;
     }
 
+    get readOnly() {
+        return this._readOnly;
+    }
+
+    get restartRequired() {
+        return this._restartRequired;
+    }
+
+    get role(): AdminUserRole {
+        return (window?.sessionStorage?.getItem(SESSION_STORAGE_ROLE_KEY) as AdminUserRole) || 'admin';
+    }
+
+    private set role(role: AdminUserRole) {
+        window?.sessionStorage?.setItem(SESSION_STORAGE_ROLE_KEY, role);
+    }
+
     private configWebSocket: WebSocket | undefined;
 
     private _docker = false;
 
+    private _notifications = 0;
+
     private _passwordNeedChange = false;
 
+    private _readOnly = false;
+
+    private _restartRequired: string[] = [];
+
+    private refreshTimer: Subscription | undefined;
+
+    private refreshing = false;
+
+    private revision = 0;
+
+    private get refreshToken(): string {
+        return window?.sessionStorage?.getItem(SESSION_STORAGE_REFRESH_KEY) || '';
+    }
+
+    private set refreshToken(refreshToken: string) {
+        window?.sessionStorage?.setItem(SESSION_STORAGE_REFRESH_KEY, refreshToken);
+    }
+
     private get token(): string {
         return window?.sessionStorage?.getItem(SESSION_STORAGE_KEY) || '';
     }
@@ -225,15 +885,55 @@ export class RdioScannerAdminService implements OnDestroy {
         private ngFormBuilder: FormBuilder,
         private ngHttpClient: HttpClient,
     ) {
-        this.configWebSocketOpen();
+        const params = new URLSearchParams(window.location.hash.replace(/^#/, ''));
+
+        const token = params.get('token');
+
+        if (token) {
+            this.token = token;
+
+            this.refreshToken = params.get('refresh') || '';
+
+            window.history.replaceState(null, '', window.location.pathname + window.location.search);
+        }
+
+        if (this.refreshToken) {
+            this.refresh();
+
+        } else {
+            this.configWebSocketOpen();
+        }
     }
 
     ngOnDestroy(): void {
         this.event.complete();
 
+        this.refreshTimer?.unsubscribe();
+
         this.configWebSocketClose();
     }
 
+    async applyUnitAliases(aliases: AdminUnitAliases): Promise<AdminUnitAliasesResult | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.post<AdminUnitAliasesResult>(
+                this.getUrl(url.units),
+                aliases,
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+        } catch (error) {
+            if (error instanceof HttpErrorResponse && error.status === 400 && error.error?.error) {
+                this.matSnackBar.open(error.error.error, '', { duration: 5000 });
+
+                return undefined;
+            }
+
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
     async changePassword(currentPassword: string, newPassword: string): Promise<void> {
         try {
             const res = await firstValueFrom(this.ngHttpClient.post<{ passwordNeedChange: boolean }>(
@@ -259,47 +959,796 @@ export class RdioScannerAdminService implements OnDestroy {
             const res = await firstValueFrom(this.ngHttpClient.get<{
                 config: Config;
                 docker: boolean;
+                notifications?: number;
                 passwordNeedChange: boolean;
+                readOnly?: boolean;
+                restartRequired?: string[];
+                revision: number;
             }>(
                 this.getUrl(url.config),
                 { headers: this.getHeaders(), responseType: 'json' },
             ));
 
+            this.revision = res.revision;
+
             if (res.docker !== this._docker) {
                 this._docker = res.docker;
 
                 this.event.emit({ docker: this.docker })
             }
 
+            if (typeof res.notifications === 'number' && res.notifications !== this._notifications) {
+                this._notifications = res.notifications;
+
+                this.event.emit({ notifications: this.notifications });
+            }
+
             if (res.passwordNeedChange !== this._passwordNeedChange) {
                 this._passwordNeedChange = res.passwordNeedChange;
 
                 this.event.emit({ passwordNeedChange: this.passwordNeedChange });
             }
 
-            return res.config;
+            if (!!res.readOnly !== this._readOnly) {
+                this._readOnly = !!res.readOnly;
+
+                this.event.emit({ readOnly: this.readOnly });
+            }
+
+            this.setRestartRequired(res.restartRequired);
+
+            return res.config;
+
+        } catch (error) {
+            this.errorHandler(error);
+        }
+
+        return {};
+    }
+
+    async addIncidentNote(incidentId: number, note: string): Promise<AdminIncidentNote | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.post<AdminIncidentNote>(
+                this.getUrl(url.incidentsNotes),
+                { incidentId, note },
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async attachIncidentCalls(incidentId: number, calls: number[] | AdminIncidentCallsFilter): Promise<number | undefined> {
+        const body = Array.isArray(calls) ? { callIds: calls, incidentId } : { filter: calls, incidentId };
+
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.post<{ attached: number }>(
+                this.getUrl(url.incidentsCalls),
+                body,
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+            return res.attached;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async detachIncidentCall(incidentId: number, callId: number): Promise<boolean> {
+        try {
+            await firstValueFrom(this.ngHttpClient.delete(
+                this.getUrl(url.incidentsCalls),
+                { headers: this.getHeaders(), params: { callId: `${callId}`, incidentId: `${incidentId}` }, responseType: 'text' },
+            ));
+
+            return true;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return false;
+        }
+    }
+
+    async exportConfig(sections: string[], format: 'json' | 'yaml'): Promise<Blob | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.get(
+                this.getUrl(url.configExport),
+                { headers: this.getHeaders(), params: { format, sections: sections.join(',') }, responseType: 'blob' },
+            ));
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async exportIncident(id: number): Promise<Blob | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.get(
+                this.getUrl(url.incidentsExport),
+                { headers: this.getHeaders(), params: { id: `${id}` }, responseType: 'blob' },
+            ));
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async getBackups(): Promise<AdminBackups | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.get<AdminBackups>(
+                this.getUrl(url.backups),
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async exportBookmarks(options: AdminBookmarksOptions, format: 'csv' | 'ndjson'): Promise<Blob | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.get(
+                this.getUrl(url.bookmarks),
+                { headers: this.getHeaders(), params: { ...this.getBookmarksParams(options), format }, responseType: 'blob' },
+            ));
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async getBans(): Promise<AdminBan[] | undefined> {
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.get<{ bans: AdminBan[] }>(
+                this.getUrl(url.bans),
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+            return res.bans;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async getBlacklists(): Promise<AdminBlacklists | undefined> {
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.get<AdminBlacklists>(
+                this.getUrl(url.blacklists),
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+            return res;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async getDuplicates(): Promise<AdminDuplicates | undefined> {
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.get<AdminDuplicates>(
+                this.getUrl(url.duplicates),
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+            return res;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    getLeds(): string[] {
+        return ['blue', 'cyan', 'green', 'magenta', 'orange', 'red', 'white', 'yellow'];
+    }
+
+    async getLogs(options: LogsQueryOptions): Promise<LogsQuery | undefined> {
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.post<LogsQuery>(
+                this.getUrl(url.logs),
+                options,
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+            return res;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async getHeartbeats(): Promise<AdminHeartbeat[]> {
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.get<AdminHeartbeat[]>(
+                this.getUrl(url.heartbeats),
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+            return res;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return [];
+        }
+    }
+
+    async getMonitor(): Promise<AdminMonitorSystem[]> {
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.get<AdminMonitorSystem[]>(
+                this.getUrl(url.monitor),
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+            return res;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return [];
+        }
+    }
+
+    async getNotifications(unread = false): Promise<AdminNotifications | undefined> {
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.get<AdminNotifications>(
+                this.getUrl(url.notifications),
+                { headers: this.getHeaders(), params: unread ? { unread: 'true' } : {}, responseType: 'json' },
+            ));
+
+            if (res.unread !== this._notifications) {
+                this._notifications = res.unread;
+
+                this.event.emit({ notifications: this.notifications });
+            }
+
+            return res;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async getOptionsSchema(): Promise<AdminOptionSchema[] | undefined> {
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.get<{ options: AdminOptionSchema[] }>(
+                this.getUrl(url.optionsSchema),
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+            return res.options;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async getBookmarks(options: AdminBookmarksOptions = {}): Promise<AdminBookmarks | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.get<AdminBookmarks>(
+                this.getUrl(url.bookmarks),
+                { headers: this.getHeaders(), params: this.getBookmarksParams(options), responseType: 'json' },
+            ));
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async getIncident(id: number): Promise<AdminIncident | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.get<AdminIncident>(
+                this.getUrl(url.incidents),
+                { headers: this.getHeaders(), params: { id: `${id}` }, responseType: 'json' },
+            ));
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async getIncidents(status?: AdminIncidentStatus): Promise<AdminIncident[] | undefined> {
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.get<{ incidents: AdminIncident[] }>(
+                this.getUrl(url.incidents),
+                { headers: this.getHeaders(), params: status ? { status } : {}, responseType: 'json' },
+            ));
+
+            return res.incidents;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async getStats(options: AdminStatsOptions = {}): Promise<AdminStats | undefined> {
+        const params = Object.entries(options).reduce((p, [key, value]) => {
+            if (value !== undefined && value !== null && value !== '') {
+                p[key] = `${value}`;
+            }
+            return p;
+        }, {} as { [key: string]: string });
+
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.get<AdminStats>(
+                this.getUrl(url.stats),
+                { headers: this.getHeaders(), params, responseType: 'json' },
+            ));
+
+            return res;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async getTelemetry(): Promise<AdminTelemetry | undefined> {
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.get<AdminTelemetry>(
+                this.getUrl(url.telemetry),
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+            return res;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async getUsers(): Promise<AdminUser[] | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.get<AdminUser[]>(
+                this.getUrl(url.users),
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async getTotp(): Promise<AdminTotp | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.get<AdminTotp>(
+                this.getUrl(url.totp),
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    hasRole(role: AdminUserRole): boolean {
+        const ranks: AdminUserRole[] = ['viewer', 'editor', 'admin'];
+
+        return ranks.indexOf(this.role) >= ranks.indexOf(role);
+    }
+
+    async importConfig(bundle: string, sections: string[], dryRun = true): Promise<AdminConfigImport | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.post<AdminConfigImport>(
+                this.getUrl(url.configImport),
+                bundle,
+                {
+                    headers: this.getHeaders().set('Content-Type', 'text/plain'),
+                    params: { dryRun: dryRun ? 'true' : 'false', sections: sections.join(',') },
+                    responseType: 'json',
+                },
+            ));
+
+        } catch (error) {
+            if (error instanceof HttpErrorResponse && error.status === 422 && error.error?.sections) {
+                return error.error as AdminConfigImport;
+            }
+
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async importTalkgroups(req: AdminTalkgroupsImport): Promise<AdminTalkgroupsImportResult | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.post<AdminTalkgroupsImportResult>(
+                this.getUrl(url.talkgroupsImport),
+                req,
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+        } catch (error) {
+            if (error instanceof HttpErrorResponse && error.status === 400 && error.error?.error) {
+                this.matSnackBar.open(error.error.error, '', { duration: 5000 });
+
+                return undefined;
+            }
+
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async importUnits(req: AdminUnitsImport): Promise<AdminUnitsImportResult | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.post<AdminUnitsImportResult>(
+                this.getUrl(url.unitsImport),
+                req,
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+        } catch (error) {
+            if (error instanceof HttpErrorResponse && error.status === 400 && error.error?.error) {
+                this.matSnackBar.open(error.error.error, '', { duration: 5000 });
+
+                return undefined;
+            }
+
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async isOidcEnabled(): Promise<boolean> {
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.get<{ admin: boolean }>(
+                `${window.location.href}/../api/oidc/`,
+                { responseType: 'json' },
+            ));
+
+            return !!res.admin;
+
+        } catch (error) {
+            return false;
+        }
+    }
+
+    async isSamlEnabled(): Promise<boolean> {
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.get<{ enabled: boolean }>(
+                this.getUrl(url.saml),
+                { responseType: 'json' },
+            ));
+
+            return !!res.enabled;
+
+        } catch (error) {
+            return false;
+        }
+    }
+
+    async login(password: string, username?: string, code?: string): Promise<boolean | 'totp'> {
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.post<{
+                expiresIn: number,
+                passwordNeedChange: boolean,
+                refreshToken: string,
+                role: AdminUserRole,
+                token: string,
+            }>(
+                this.getUrl(url.login),
+                { code, password, username },
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+            this.token = res.token;
+
+            this.refreshToken = res.refreshToken || '';
+
+            this.role = res.role || 'admin';
+
+            this.scheduleRefresh(res.expiresIn);
+
+            this._passwordNeedChange = res.passwordNeedChange;
+
+            this.event.emit({
+                authenticated: this.authenticated,
+                passwordNeedChange: res.passwordNeedChange,
+            });
+
+            this.configWebSocketOpen();
+
+            return !!this.token;
+
+        } catch (error) {
+            if (error instanceof HttpErrorResponse && error.status === 401 && error.error?.totp) {
+                return 'totp';
+            }
+
+            this.errorHandler(error);
+
+            return false;
+        }
+    }
+
+    loginWithOidc(): void {
+        window.location.href = `${window.location.href}/../api/oidc/login?target=admin`;
+    }
+
+    loginWithSaml(): void {
+        window.location.href = this.getUrl(url.samlLogin);
+    }
+
+    async logout(): Promise<boolean> {
+        try {
+            this.ngHttpClient.post(
+                this.getUrl(url.logout),
+                null,
+                { headers: this.getHeaders(), responseType: 'text' },
+            );
+
+            this.configWebSocketClose();
+
+            this.clearSession();
+
+            this.event.emit({ authenticated: this.authenticated });
+
+            return true;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return false;
+        }
+    }
+
+    async markNotifications(ids: number[] | 'all', read = true): Promise<boolean> {
+        try {
+            await firstValueFrom(this.ngHttpClient.post(
+                this.getUrl(url.notifications),
+                ids === 'all' ? { all: true, read } : { ids, read },
+                { headers: this.getHeaders(), responseType: 'text' },
+            ));
+
+            return true;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return false;
+        }
+    }
+
+    async removeBan(address?: string): Promise<boolean> {
+        try {
+            await firstValueFrom(this.ngHttpClient.delete(
+                this.getUrl(url.bans),
+                { headers: this.getHeaders(), params: address ? { address } : {}, responseType: 'text' },
+            ));
+
+            return true;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return false;
+        }
+    }
+
+    async removeBookmark(id: number): Promise<boolean> {
+        try {
+            await firstValueFrom(this.ngHttpClient.delete(
+                this.getUrl(url.bookmarks),
+                { headers: this.getHeaders(), params: { id: `${id}` }, responseType: 'text' },
+            ));
+
+            return true;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return false;
+        }
+    }
+
+    async removeIncident(id: number): Promise<boolean> {
+        try {
+            await firstValueFrom(this.ngHttpClient.delete(
+                this.getUrl(url.incidents),
+                { headers: this.getHeaders(), params: { id: `${id}` }, responseType: 'text' },
+            ));
+
+            return true;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return false;
+        }
+    }
+
+    async removeNotification(id: number): Promise<boolean> {
+        try {
+            await firstValueFrom(this.ngHttpClient.delete(
+                this.getUrl(url.notifications),
+                { headers: this.getHeaders(), params: { id: `${id}` }, responseType: 'text' },
+            ));
+
+            return true;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return false;
+        }
+    }
+
+    async removeIncidentNote(incidentId: number, id: number): Promise<boolean> {
+        try {
+            await firstValueFrom(this.ngHttpClient.delete(
+                this.getUrl(url.incidentsNotes),
+                { headers: this.getHeaders(), params: { id: `${id}`, incidentId: `${incidentId}` }, responseType: 'text' },
+            ));
+
+            return true;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return false;
+        }
+    }
+
+    async resetBlacklists(): Promise<boolean> {
+        try {
+            await firstValueFrom(this.ngHttpClient.delete(
+                this.getUrl(url.blacklists),
+                { headers: this.getHeaders(), responseType: 'text' },
+            ));
+
+            return true;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return false;
+        }
+    }
+
+    async resetDuplicates(): Promise<boolean> {
+        try {
+            await firstValueFrom(this.ngHttpClient.delete(
+                this.getUrl(url.duplicates),
+                { headers: this.getHeaders(), responseType: 'text' },
+            ));
+
+            return true;
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return false;
+        }
+    }
+
+    async restoreBackup(name: string): Promise<boolean> {
+        try {
+            await firstValueFrom(this.ngHttpClient.post(
+                this.getUrl(url.backupsRestore),
+                { name },
+                { headers: this.getHeaders(), responseType: 'text' },
+            ));
+
+            return true;
+
+        } catch (error) {
+            this.backupErrorHandler(error);
+
+            return false;
+        }
+    }
+
+    async runBackup(): Promise<AdminBackup | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.post<AdminBackup>(
+                this.getUrl(url.backups),
+                null,
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+        } catch (error) {
+            this.backupErrorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async removeUser(username: string): Promise<AdminUser[] | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.delete<AdminUser[]>(
+                this.getUrl(url.users),
+                { headers: this.getHeaders(), params: { username }, responseType: 'json' },
+            ));
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async saveBookmark(bookmark: { callId: number; note: string; shared: boolean; }): Promise<AdminBookmark | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.post<AdminBookmark>(
+                this.getUrl(url.bookmarks),
+                bookmark,
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async saveIncident(incident: AdminIncident): Promise<AdminIncident | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.post<AdminIncident>(
+                this.getUrl(url.incidents),
+                incident,
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
 
         } catch (error) {
             this.errorHandler(error);
-        }
-
-        return {};
-    }
 
-    getLeds(): string[] {
-        return ['blue', 'cyan', 'green', 'magenta', 'orange', 'red', 'white', 'yellow'];
+            return undefined;
+        }
     }
 
-    async getLogs(options: LogsQueryOptions): Promise<LogsQuery | undefined> {
+    async saveUser(user: AdminUser): Promise<AdminUser[] | undefined> {
         try {
-            const res = await firstValueFrom(this.ngHttpClient.post<LogsQuery>(
-                this.getUrl(url.logs),
-                options,
+            return await firstValueFrom(this.ngHttpClient.post<AdminUser[]>(
+                this.getUrl(url.users),
+                user,
                 { headers: this.getHeaders(), responseType: 'json' },
             ));
 
-            return res;
-
         } catch (error) {
             this.errorHandler(error);
 
@@ -307,68 +1756,76 @@ export class RdioScannerAdminService implements OnDestroy {
         }
     }
 
-    async login(password: string): Promise<boolean> {
+    async testAlert(alert: Alert): Promise<AdminDeliveryTest | undefined> {
         try {
-            const res = await firstValueFrom(this.ngHttpClient.post<{
-                passwordNeedChange: boolean,
-                token: string
-            }>(
-                this.getUrl(url.login),
-                { password },
+            return await firstValueFrom(this.ngHttpClient.post<AdminDeliveryTest>(
+                this.getUrl(url.alertsTest),
+                alert,
                 { headers: this.getHeaders(), responseType: 'json' },
             ));
 
-            this.token = res.token;
+        } catch (error) {
+            this.errorHandler(error);
 
-            this._passwordNeedChange = res.passwordNeedChange;
+            return undefined;
+        }
+    }
 
-            this.event.emit({
-                authenticated: this.authenticated,
-                passwordNeedChange: res.passwordNeedChange,
-            });
+    async testRetention(params: { callId?: number; system?: number; talkgroup?: number }): Promise<AdminRetentionMatch | undefined> {
+        const query: { [key: string]: string } = {};
 
-            this.configWebSocketOpen();
+        Object.entries(params).forEach(([key, value]) => {
+            if (typeof value === 'number') {
+                query[key] = `${value}`;
+            }
+        });
 
-            return !!this.token;
+        try {
+            return await firstValueFrom(this.ngHttpClient.get<AdminRetentionMatch>(
+                this.getUrl(url.retentionsTest),
+                { headers: this.getHeaders(), params: query, responseType: 'json' },
+            ));
 
         } catch (error) {
             this.errorHandler(error);
 
-            return false;
+            return undefined;
         }
     }
 
-    async logout(): Promise<boolean> {
+    async testWebhook(webhook: Webhook): Promise<AdminDeliveryTest | undefined> {
         try {
-            this.ngHttpClient.post(
-                this.getUrl(url.logout),
-                null,
-                { headers: this.getHeaders(), responseType: 'text' },
-            );
-
-            this.configWebSocketClose();
-
-            this.token = '';
-
-            this.event.emit({ authenticated: this.authenticated });
-
-            return true;
+            return await firstValueFrom(this.ngHttpClient.post<AdminDeliveryTest>(
+                this.getUrl(url.webhooksTest),
+                webhook,
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
 
         } catch (error) {
             this.errorHandler(error);
 
-            return false;
+            return undefined;
+        }
+    }
+
+    setPresence(section: string): void {
+        if (this.configWebSocket?.readyState === WebSocket.OPEN) {
+            this.configWebSocket.send(JSON.stringify({ presence: { section } }));
         }
     }
 
     async saveConfig(config: Config): Promise<Config> {
         try {
-            const res = await firstValueFrom(this.ngHttpClient.put<{ config: Config }>(
+            const res = await firstValueFrom(this.ngHttpClient.put<{ config: Config, restartRequired?: string[], revision: number }>(
                 this.getUrl(url.config),
-                config,
+                { ...config, revision: this.revision },
                 { headers: this.getHeaders(), responseType: 'json' },
             ));
 
+            this.revision = res.revision;
+
+            this.setRestartRequired(res.restartRequired);
+
             return res.config;
 
         } catch (error) {
@@ -381,12 +1838,45 @@ export class RdioScannerAdminService implements OnDestroy {
     newAccessForm(access?: Access): FormGroup {
         return this.ngFormBuilder.group({
             _id: [access?._id],
+            archive: [access?.archive || ''],
+            archiveHours: [access?.archiveHours, Validators.min(1)],
             code: [access?.code, [Validators.required, this.validateAccessCode()]],
+            deny: [access?.deny || null],
             expiration: [access?.expiration],
+            hours: [access?.hours, Validators.min(0)],
             ident: [access?.ident, Validators.required],
             limit: [access?.limit],
             order: [access?.order],
+            schedule: this.ngFormBuilder.array(access?.schedule?.map((window) => this.newAccessWindowForm(window)) || []),
+            streamOnly: [access?.streamOnly],
             systems: [access?.systems, Validators.required],
+            watermark: [access?.watermark || ''],
+        });
+    }
+
+    newAccessWindowForm(window?: AccessWindow): FormGroup {
+        return this.ngFormBuilder.group({
+            days: [window?.days || []],
+            from: [window?.from, Validators.required],
+            to: [window?.to, Validators.required],
+        });
+    }
+
+    newAlertForm(alert?: Alert): FormGroup {
+        return this.ngFormBuilder.group({
+            _id: [alert?._id],
+            anomalies: [alert?.anomalies || false],
+            burstCount: [alert?.burstCount || 0, Validators.min(0)],
+            burstWindow: [alert?.burstWindow || 0, Validators.min(0)],
+            cooldown: [alert?.cooldown || 0, Validators.min(0)],
+            delivery: [alert?.delivery, Validators.required],
+            disabled: [alert?.disabled],
+            keywords: [alert?.keywords || ''],
+            label: [alert?.label, Validators.required],
+            order: [alert?.order],
+            systems: [alert?.systems, Validators.required],
+            target: [alert?.target, [Validators.required, this.validateAlertTarget()]],
+            units: [alert?.units || '', this.validateAlertUnits()],
         });
     }
 
@@ -397,6 +1887,7 @@ export class RdioScannerAdminService implements OnDestroy {
             ident: [apiKey?.ident, Validators.required],
             key: [apiKey?.key, [Validators.required, this.validateApiKey()]],
             order: [apiKey?.order],
+            scopes: [apiKey?.scopes, Validators.pattern(/^\s*(calls:read|systems:read|upload)\s*(,\s*(calls:read|systems:read|upload)\s*)*$/)],
             systems: [apiKey?.systems, Validators.required],
         });
     }
@@ -404,19 +1895,24 @@ export class RdioScannerAdminService implements OnDestroy {
     newConfigForm(config?: Config): FormGroup {
         return this.ngFormBuilder.group({
             access: this.ngFormBuilder.array(config?.access?.map((access) => this.newAccessForm(access)) || []),
+            alerts: this.ngFormBuilder.array(config?.alerts?.map((alert) => this.newAlertForm(alert)) || []),
             apiKeys: this.ngFormBuilder.array(config?.apiKeys?.map((apiKey) => this.newApiKeyForm(apiKey)) || []),
             dirWatch: this.ngFormBuilder.array(config?.dirWatch?.map((dirWatch) => this.newDirWatchForm(dirWatch)) || []),
             downstreams: this.ngFormBuilder.array(config?.downstreams?.map((downstream) => this.newDownstreamForm(downstream)) || []),
             groups: this.ngFormBuilder.array(config?.groups?.map((group) => this.newGroupForm(group)) || []),
             options: this.newOptionsForm(config?.options),
+            retentions: this.ngFormBuilder.array(config?.retentions?.map((retention) => this.newRetentionForm(retention)) || []),
+            sdr: this.ngFormBuilder.array(config?.sdr?.map((sdr) => this.newSdrForm(sdr)) || []),
             systems: this.ngFormBuilder.array(config?.systems?.map((system) => this.newSystemForm(system)) || []),
             tags: this.ngFormBuilder.array(config?.tags?.map((tag) => this.newTagForm(tag)) || []),
+            webhooks: this.ngFormBuilder.array(config?.webhooks?.map((webhook) => this.newWebhookForm(webhook)) || []),
         });
     }
 
     newDirWatchForm(dirWatch?: DirWatch): FormGroup {
         return this.ngFormBuilder.group({
             _id: [dirWatch?._id],
+            archiveDir: [dirWatch?.archiveDir],
             delay: [typeof dirWatch?.delay === 'number' ? Math.max(2000, dirWatch?.delay) : 2000],
             deleteAfter: [dirWatch?.deleteAfter],
             directory: [dirWatch?.directory, [Validators.required, this.validateDirectory()]],
@@ -425,9 +1921,12 @@ export class RdioScannerAdminService implements OnDestroy {
             frequency: [dirWatch?.frequency, Validators.min(0)],
             mask: [dirWatch?.mask, this.validateMask()],
             order: [dirWatch?.order],
+            password: [dirWatch?.password],
+            pollInterval: [dirWatch?.pollInterval, Validators.min(5)],
             systemId: [dirWatch?.systemId, this.validateDirwatchSystemId()],
             talkgroupId: [dirWatch?.talkgroupId, this.validateDirwatchTalkgroupId()],
             type: [dirWatch?.type],
+            username: [dirWatch?.username],
         });
     }
 
@@ -449,6 +1948,35 @@ export class RdioScannerAdminService implements OnDestroy {
         });
     }
 
+    newRetentionForm(retention?: Retention): FormGroup {
+        return this.ngFormBuilder.group({
+            _id: [retention?._id],
+            days: [retention?.days || 0, [Validators.required, Validators.min(0)]],
+            label: [retention?.label, Validators.required],
+            order: [retention?.order],
+            system: [retention?.system || null, this.validateRetentionScope()],
+            tagId: [retention?.tagId || null],
+            talkgroup: [retention?.talkgroup || null],
+        });
+    }
+
+    newSdrForm(sdr?: Sdr): FormGroup {
+        return this.ngFormBuilder.group({
+            _id: [sdr?._id],
+            command: [sdr?.command, Validators.required],
+            disabled: [sdr?.disabled],
+            frequency: [sdr?.frequency, Validators.min(0)],
+            hang: [sdr?.hang, [Validators.required, Validators.min(100)]],
+            maxDuration: [sdr?.maxDuration, [Validators.required, Validators.min(1)]],
+            minDuration: [sdr?.minDuration, [Validators.required, Validators.min(0)]],
+            order: [sdr?.order],
+            sampleRate: [sdr?.sampleRate, [Validators.required, Validators.min(8000)]],
+            squelch: [sdr?.squelch, [Validators.required, Validators.min(1), Validators.max(32767)]],
+            systemId: [sdr?.systemId, Validators.required],
+            talkgroupId: [sdr?.talkgroupId, Validators.required],
+        });
+    }
+
     newTagForm(tag?: Tag): FormGroup {
         return this.ngFormBuilder.group({
             _id: [tag?._id],
@@ -459,27 +1987,49 @@ export class RdioScannerAdminService implements OnDestroy {
     newSystemForm(system?: System): FormGroup {
         return this.ngFormBuilder.group({
             _id: [system?._id],
+            audioBitrate: [system?.audioBitrate || null, Validators.min(0)],
+            audioChannels: [system?.audioChannels || 0],
+            audioCodec: [system?.audioCodec || ''],
+            audioConversion: [system?.audioConversion || ''],
+            audioSampleRate: [system?.audioSampleRate || 0],
             autoPopulate: [system?.autoPopulate],
             blacklists: [system?.blacklists, this.validateBlacklists()],
+            broadcastifyApiKey: [system?.broadcastifyApiKey || ''],
+            broadcastifySystemId: [system?.broadcastifySystemId || null, Validators.min(1)],
+            duplicatePrimary: [system?.duplicatePrimary, this.validateDuplicatePrimary()],
+            duplicateStrategy: [system?.duplicateStrategy || ''],
             id: [system?.id, [Validators.required, Validators.min(1), this.validateId()]],
             label: [system?.label, Validators.required],
             led: [system?.led],
             order: [system?.order],
+            radioReferenceConflict: [system?.radioReferenceConflict || 'keep'],
+            radioReferenceSystemId: [system?.radioReferenceSystemId || null, Validators.min(1)],
+            streamOnly: [system?.streamOnly],
             talkgroups: this.ngFormBuilder.array(system?.talkgroups?.map((talkgroup) => this.newTalkgroupForm(talkgroup)) || []),
+            unitBlacklists: [system?.unitBlacklists, this.validateBlacklists()],
             units: this.ngFormBuilder.array(system?.units?.map((unit) => this.newUnitForm(unit)) || []),
+            unitsImportConflict: [system?.unitsImportConflict || 'keep'],
+            unitsImportUrl: [system?.unitsImportUrl, Validators.pattern(/^https?:\/\/.+/)],
         });
     }
 
     newTalkgroupForm(talkgroup?: Talkgroup): FormGroup {
         return this.ngFormBuilder.group({
+            broadcastify: [talkgroup?.broadcastify || false],
+            broadcastifySlot: [talkgroup?.broadcastifySlot || null, Validators.min(1)],
+            delay: [talkgroup?.delay || 0, Validators.min(0)],
+            downstreamDelay: [talkgroup?.downstreamDelay || 0, Validators.min(0)],
             frequency: [talkgroup?.frequency, Validators.min(0)],
             groupId: [talkgroup?.groupId, [Validators.required, this.validateGroup()]],
             id: [talkgroup?.id, [Validators.required, Validators.min(1), this.validateId()]],
             label: [talkgroup?.label, Validators.required],
             led: [talkgroup?.led],
+            linkedSystem: [talkgroup?.linkedSystem || null],
+            linkedTalkgroup: [talkgroup?.linkedTalkgroup || null, this.validateLinkedTalkgroup()],
             name: [talkgroup?.name, Validators.required],
             order: [talkgroup?.order],
             tagId: [talkgroup?.tagId, [Validators.required, this.validateTag()]],
+            unitBlacklists: [talkgroup?.unitBlacklists, this.validateBlacklists()],
         });
     }
 
@@ -491,22 +2041,141 @@ export class RdioScannerAdminService implements OnDestroy {
         });
     }
 
+    newWebhookForm(webhook?: Webhook): FormGroup {
+        return this.ngFormBuilder.group({
+            _id: [webhook?._id],
+            audioLink: [webhook?.audioLink],
+            disabled: [webhook?.disabled],
+            headers: [webhook?.headers || '', this.validateHeaders()],
+            order: [webhook?.order],
+            systems: [webhook?.systems, Validators.required],
+            template: [webhook?.template || ''],
+            url: [webhook?.url, [Validators.required, this.validateUrl()]],
+        });
+    }
+
     newOptionsForm(options?: Options): FormGroup {
         return this.ngFormBuilder.group({
+            accessLog: [options?.accessLog],
+            adminSessionExpiry: [options?.adminSessionExpiry, [Validators.required, Validators.min(1)]],
+            adminTokenExpiry: [options?.adminTokenExpiry, [Validators.required, Validators.min(1)]],
             afsSystems: [options?.afsSystems, this.validateAfsSystems()],
+            alertPushoverToken: [options?.alertPushoverToken],
+            alertSmtpFrom: [options?.alertSmtpFrom],
+            alertSmtpPassword: [options?.alertSmtpPassword],
+            alertSmtpServer: [options?.alertSmtpServer],
+            alertSmtpUsername: [options?.alertSmtpUsername],
+            alertTelegramToken: [options?.alertTelegramToken],
+            anomalyDetection: [options?.anomalyDetection],
+            anomalySilenceDays: [options?.anomalySilenceDays, [Validators.required, Validators.min(1)]],
+            anomalyVolumeFactor: [options?.anomalyVolumeFactor, [Validators.required, Validators.min(2)]],
+            audioBitrate: [options?.audioBitrate, [Validators.required, Validators.min(8), Validators.max(320)]],
+            audioChannels: [options?.audioChannels],
+            audioCodec: [options?.audioCodec],
+            audioLoudnessTarget: [options?.audioLoudnessTarget, [Validators.required, Validators.min(-70), Validators.max(-5)]],
+            audioNormalization: [options?.audioNormalization],
+            audioStorage: [options?.audioStorage],
+            audioStorageS3AccessKey: [options?.audioStorageS3AccessKey],
+            audioStorageS3Bucket: [options?.audioStorageS3Bucket],
+            audioStorageS3Endpoint: [options?.audioStorageS3Endpoint],
+            audioStorageS3PathStyle: [options?.audioStorageS3PathStyle],
+            audioStorageS3Prefix: [options?.audioStorageS3Prefix],
+            audioStorageS3Region: [options?.audioStorageS3Region],
+            audioStorageS3SecretKey: [options?.audioStorageS3SecretKey],
+            authWebhook: [options?.authWebhook],
+            authWebhookSecret: [options?.authWebhookSecret],
             autoPopulate: [options?.autoPopulate],
+            backupDirectory: [options?.backupDirectory],
+            backupRetention: [options?.backupRetention, [Validators.required, Validators.min(0)]],
+            backupS3Bucket: [options?.backupS3Bucket],
+            backupSchedule: [options?.backupSchedule],
+            broadcastDedupWindow: [options?.broadcastDedupWindow, [Validators.required, Validators.min(0)]],
+            checkForUpdates: [options?.checkForUpdates],
+            clockSkewCorrection: [options?.clockSkewCorrection],
+            clockSkewThreshold: [options?.clockSkewThreshold, [Validators.required, Validators.min(0)]],
+            conversationGap: [options?.conversationGap, [Validators.required, Validators.min(0)]],
+            conversionWorkers: [options?.conversionWorkers, [Validators.required, Validators.min(1)]],
             dimmerDelay: [options?.dimmerDelay, [Validators.required, Validators.min(0)]],
+            directoryLocation: [options?.directoryLocation],
+            directoryName: [options?.directoryName],
+            directoryPublish: [options?.directoryPublish],
+            directoryUrl: [options?.directoryUrl],
             disableAudioConversion: [options?.disableAudioConversion],
             disableDuplicateDetection: [options?.disableDuplicateDetection],
+            diskWarningPercent: [options?.diskWarningPercent, [Validators.required, Validators.min(0)]],
             duplicateDetectionTimeFrame: [options?.duplicateDetectionTimeFrame, [Validators.required, Validators.min(0)]],
+            duplicateFingerprintMatch: [options?.duplicateFingerprintMatch, [Validators.required, Validators.min(50), Validators.max(100)]],
+            duplicateFingerprintWindow: [options?.duplicateFingerprintWindow, [Validators.required, Validators.min(0)]],
+            heartbeatTimeout: [options?.heartbeatTimeout, [Validators.required, Validators.min(0)]],
+            incidentDetection: [options?.incidentDetection],
+            incidentDetectionMinCalls: [options?.incidentDetectionMinCalls, [Validators.required, Validators.min(1)]],
+            incidentDetectionThreshold: [options?.incidentDetectionThreshold, [Validators.required, Validators.min(1)]],
+            incidentDetectionWindow: [options?.incidentDetectionWindow, [Validators.required, Validators.min(1)]],
+            ingestCallbacks: [options?.ingestCallbacks],
             keypadBeeps: [options?.keypadBeeps, Validators.required],
+            linkedTalkgroups: [options?.linkedTalkgroups],
+            listeningRooms: [options?.listeningRooms],
+            maxCallDuration: [options?.maxCallDuration, [Validators.required, Validators.min(0)]],
+            maxCallSize: [options?.maxCallSize, [Validators.required, Validators.min(0)]],
             maxClients: [options?.maxClients, [Validators.required, Validators.min(1)]],
+            oidcAdmin: [options?.oidcAdmin],
+            oidcAdminGroups: [options?.oidcAdminGroups],
+            oidcAllowedGroups: [options?.oidcAllowedGroups],
+            oidcClientId: [options?.oidcClientId],
+            oidcClientSecret: [options?.oidcClientSecret],
+            oidcGroupsClaim: [options?.oidcGroupsClaim],
+            oidcIssuer: [options?.oidcIssuer],
+            oidcListeners: [options?.oidcListeners],
             playbackGoesLive: [options?.playbackGoesLive],
             pruneDays: [options?.pruneDays, [Validators.required, Validators.min(0)]],
+            publicUrl: [options?.publicUrl],
+            radioReferenceAppKey: [options?.radioReferenceAppKey],
+            radioReferenceInterval: [options?.radioReferenceInterval, [Validators.required, Validators.min(1)]],
+            radioReferencePassword: [options?.radioReferencePassword],
+            radioReferenceUsername: [options?.radioReferenceUsername],
+            rateLimitAdmin: [options?.rateLimitAdmin, [Validators.required, Validators.min(0)]],
+            rateLimitBanDuration: [options?.rateLimitBanDuration, [Validators.required, Validators.min(0)]],
+            rateLimitBanFailures: [options?.rateLimitBanFailures, [Validators.required, Validators.min(0)]],
+            rateLimitBanRejections: [options?.rateLimitBanRejections, [Validators.required, Validators.min(0)]],
+            rateLimitUpload: [options?.rateLimitUpload, [Validators.required, Validators.min(0)]],
+            rateLimitWebsocket: [options?.rateLimitWebsocket, [Validators.required, Validators.min(0)]],
+            relatedCallsWindow: [options?.relatedCallsWindow, [Validators.required, Validators.min(0)]],
+            samlAllowedGroups: [options?.samlAllowedGroups],
+            samlAllowedUsers: [options?.samlAllowedUsers],
+            samlEntityId: [options?.samlEntityId],
+            samlGroupRoles: [options?.samlGroupRoles],
+            samlGroupsAttribute: [options?.samlGroupsAttribute],
+            samlIdpCertificate: [options?.samlIdpCertificate],
+            samlIdpUrl: [options?.samlIdpUrl],
+            scheduledRestart: [options?.scheduledRestart],
+            scheduledRestartHour: [options?.scheduledRestartHour, [Validators.required, Validators.min(0)]],
 			searchPatchedTalkgroups: [options?.searchPatchedTalkgroups],
+            serverQueue: [options?.serverQueue],
+            serverQueueMaxDepth: [options?.serverQueueMaxDepth, [Validators.required, Validators.min(0)]],
+            serverQueueSkipPolicy: [options?.serverQueueSkipPolicy],
+            serverTiming: [options?.serverTiming],
 			showListenersCount: [options?.showListenersCount],
+            silenceAlert: [options?.silenceAlert, [Validators.required, Validators.min(0)]],
+            silenceAlertFrom: [options?.silenceAlertFrom, [Validators.required, Validators.min(0)]],
+            silenceAlertTo: [options?.silenceAlertTo, [Validators.required, Validators.min(0)]],
             sortTalkgroups: [options?.sortTalkgroups],
+            ssoRole: [options?.ssoRole],
             tagsToggle: [options?.tagsToggle],
+
+            telemetry: [options?.telemetry],
+            telemetryUrl: [options?.telemetryUrl],
+            transcription: [options?.transcription],
+            transcriptionApiKey: [options?.transcriptionApiKey],
+            transcriptionCommand: [options?.transcriptionCommand],
+            transcriptionLanguage: [options?.transcriptionLanguage],
+            transcriptionModel: [options?.transcriptionModel],
+            transcriptionUrl: [options?.transcriptionUrl],
+            truncateLongCalls: [options?.truncateLongCalls],
+            trustedProxies: [options?.trustedProxies],
+            unitsImportInterval: [options?.unitsImportInterval, [Validators.required, Validators.min(1)]],
+            votingWindow: [options?.votingWindow, [Validators.required, Validators.min(0)]],
+            watchdogSelfHeal: [options?.watchdogSelfHeal],
+            watchdogStallTimeout: [options?.watchdogStallTimeout, [Validators.required, Validators.min(10)]],
         });
     }
 
@@ -538,8 +2207,13 @@ export class RdioScannerAdminService implements OnDestroy {
         this.configWebSocket = new WebSocket(webSocketUrl);
 
         this.configWebSocket.onclose = (ev: CloseEvent) => {
-            if (ev.code === 1000) {
-                this.token = '';
+            if (ev.code === 1000 && this.refreshToken) {
+                this.configWebSocketClose();
+
+                this.refresh();
+
+            } else if (ev.code === 1000) {
+                this.clearSession();
 
                 this.event.emit({ authenticated: this.authenticated });
             } else {
@@ -552,29 +2226,250 @@ export class RdioScannerAdminService implements OnDestroy {
 
             if (this.configWebSocket instanceof WebSocket) {
                 this.configWebSocket.onmessage = (ev: MessageEvent<string>) => {
-                    this.event.emit({ config: JSON.parse(ev.data) });
+                    const data = JSON.parse(ev.data);
+
+                    if ('presence' in data) {
+                        const presence: AdminPresence[] = (data.presence || []).map((p: AdminPresence) => ({
+                            ...p,
+                            self: p.id === data.self,
+                        }));
+
+                        this.event.emit({ presence });
+
+                    } else if ('notifications' in data) {
+                        const notification: AdminNotification | undefined = data.notifications.notification;
+
+                        this._notifications = data.notifications.unread || 0;
+
+                        if (notification) {
+                            this.matSnackBar.open(notification.message, '', { duration: 10000 });
+                        }
+
+                        this.event.emit({ notification, notifications: this.notifications });
+
+                    } else if ('notice' in data) {
+                        const notice: AdminNotice = data.notice;
+
+                        if (notice.type === 'silence') {
+                            this.matSnackBar.open(`No calls received on system ${notice.system} since ${notice.lastCall}`, '', { duration: 10000 });
+
+                            this.event.emit({ notice });
+
+                        } else if (notice.type === 'heartbeatLost') {
+                            this.matSnackBar.open(`No heartbeat received from recorder ${notice.ident} since ${notice.lastSeen}`, '', { duration: 10000 });
+
+                            this.event.emit({ notice });
+
+                        } else if (notice.type === 'watchdog') {
+                            this.matSnackBar.open(`Watchdog: ${notice.message}`, '', { duration: 10000 });
+
+                            this.event.emit({ notice });
+
+                        } else if (notice.type === 'heartbeat' || notice.type === 'resumed') {
+                            this.event.emit({ notice });
+
+                        } else if (typeof notice.revision === 'number' && notice.revision > this.revision) {
+                            this.matSnackBar.open(notice.message || '', '', { duration: 5000 });
+
+                            this.event.emit({ notice });
+                        }
+
+                    } else {
+                        this.event.emit({ config: data });
+                    }
                 }
             }
         }
     }
 
+    async traceWatermark(source: File | number): Promise<AdminWatermarkTrace | undefined> {
+        const body = new FormData();
+
+        if (source instanceof File) {
+            body.append('audio', source);
+        } else {
+            body.append('id', `${source}`);
+        }
+
+        try {
+            return await firstValueFrom(this.ngHttpClient.post<AdminWatermarkTrace>(
+                this.getUrl(url.watermark),
+                body,
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+        } catch (error) {
+            if (error instanceof HttpErrorResponse && error.status === 400 && error.error?.error) {
+                this.matSnackBar.open(error.error.error, '', { duration: 5000 });
+
+                return undefined;
+            }
+
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    async updateTotp(action: 'disable' | 'generate' | 'verify', code?: string): Promise<AdminTotp | undefined> {
+        try {
+            return await firstValueFrom(this.ngHttpClient.post<AdminTotp>(
+                this.getUrl(url.totp),
+                { action, code },
+                { headers: this.getHeaders(), responseType: 'json' },
+            ));
+
+        } catch (error) {
+            this.errorHandler(error);
+
+            return undefined;
+        }
+    }
+
+    private clearSession(): void {
+        this.refreshTimer?.unsubscribe();
+
+        this.refreshTimer = undefined;
+
+        this.refreshToken = '';
+
+        this.token = '';
+    }
+
+    private backupErrorHandler(error: unknown): void {
+        if (error instanceof HttpErrorResponse && error.status === 417) {
+            let message = '';
+
+            try {
+                message = JSON.parse(error.error)?.error;
+
+            } catch (_) {
+                message = error.error?.error;
+            }
+
+            this.matSnackBar.open(message || 'Backup operation failed', '', { duration: 10000 });
+
+            return;
+        }
+
+        this.errorHandler(error);
+    }
+
     private errorHandler(error: unknown): void {
         if (!(error instanceof HttpErrorResponse)) {
             return;
         }
 
         if (error.status === 401) {
-            this.token = '';
+            if (this.refreshToken && error.url !== this.getUrl(url.refresh)) {
+                this.refresh();
+
+                return;
+            }
+
+            this.clearSession();
 
             this.event.emit({ authenticated: this.authenticated });
 
             this.configWebSocketClose();
 
+        } else if (error.status === 403) {
+            this.matSnackBar.open('Your role does not allow this operation', '', { duration: 5000 });
+
+        } else if (error.status === 409) {
+            this.matSnackBar.open(error.error?.error || error.message, '', { duration: 5000 });
+
         } else {
             this.matSnackBar.open(error.message, '', { duration: 5000 });
         }
     }
 
+    private async refresh(): Promise<void> {
+        const refreshToken = this.refreshToken;
+
+        if (!refreshToken || this.refreshing) {
+            return;
+        }
+
+        this.refreshing = true;
+
+        try {
+            const res = await firstValueFrom(this.ngHttpClient.post<{
+                expiresIn: number,
+                refreshToken: string,
+                role: AdminUserRole,
+                token: string,
+            }>(
+                this.getUrl(url.refresh),
+                { refreshToken },
+                { responseType: 'json' },
+            ));
+
+            const authenticated = this.authenticated;
+
+            this.token = res.token;
+
+            this.refreshToken = res.refreshToken;
+
+            this.role = res.role || 'admin';
+
+            this.scheduleRefresh(res.expiresIn);
+
+            if (!authenticated) {
+                this.event.emit({ authenticated: this.authenticated });
+            }
+
+            if (!this.configWebSocket) {
+                this.configWebSocketOpen();
+            }
+
+        } catch (error) {
+            this.refreshing = false;
+
+            if (error instanceof HttpErrorResponse && error.status === 401) {
+                this.clearSession();
+
+                this.event.emit({ authenticated: this.authenticated });
+
+                this.configWebSocketClose();
+
+            } else {
+                this.scheduleRefresh(30);
+            }
+
+            return;
+        }
+
+        this.refreshing = false;
+    }
+
+    private scheduleRefresh(expiresIn: number): void {
+        this.refreshTimer?.unsubscribe();
+
+        if (!this.refreshToken || !(expiresIn > 0)) {
+            return;
+        }
+
+        this.refreshTimer = timer(Math.max(expiresIn - 60, 10) * 1000).subscribe(() => this.refresh());
+    }
+
+    private setRestartRequired(restartRequired: string[] = []): void {
+        if (restartRequired.join() !== this._restartRequired.join()) {
+            this._restartRequired = restartRequired;
+
+            this.event.emit({ restartRequired: this.restartRequired });
+        }
+    }
+
+    private getBookmarksParams(options: AdminBookmarksOptions): { [key: string]: string } {
+        return Object.entries(options).reduce((p, [key, value]) => {
+            if (value !== undefined && value !== null && value !== '') {
+                p[key] = `${value}`;
+            }
+            return p;
+        }, {} as { [key: string]: string });
+    }
+
     private getHeaders(): HttpHeaders {
         return new HttpHeaders({
             Authorization: this.token || '',
@@ -599,6 +2494,35 @@ export class RdioScannerAdminService implements OnDestroy {
         };
     }
 
+    private validateAlertTarget(): ValidatorFn {
+        return (control: AbstractControl): ValidationErrors | null => {
+            if (typeof control.value !== 'string' || !control.value.length) {
+                return null;
+            }
+
+            switch (control.parent?.get('delivery')?.value) {
+                case 'email':
+                    return control.value.split(',').every((s: string) => /^[^@\s]+@[^@\s]+$/.test(s.trim())) ? null : { invalid: true };
+
+                case 'webhook':
+                    return /^https?:\/\/.+$/.test(control.value) ? null : { invalid: true };
+
+                default:
+                    return null;
+            }
+        };
+    }
+
+    private validateAlertUnits(): ValidatorFn {
+        return (control: AbstractControl): ValidationErrors | null => {
+            if (typeof control.value !== 'string' || !control.value.trim().length) {
+                return null;
+            }
+
+            return /^\s*\d+(\s*,\s*\d+)*\s*$/.test(control.value) ? null : { invalid: true };
+        };
+    }
+
     private validateAfsSystems(): ValidatorFn {
         return (control: AbstractControl): ValidationErrors | null => {
             return typeof control.value === 'string' && control.value.length ? /^[0-9]+(,[0-9]+)*$/.test(control.value) ? null : { invalid: true } : null;
@@ -677,6 +2601,14 @@ export class RdioScannerAdminService implements OnDestroy {
         };
     }
 
+    private validateDuplicatePrimary(): ValidatorFn {
+        return (control: AbstractControl): ValidationErrors | null => {
+            const system = control.parent?.getRawValue() || {};
+
+            return system.duplicateStrategy !== 'priority' || (typeof control.value === 'string' && control.value.trim().length > 0) ? null : { required: true };
+        };
+    }
+
     private validateExtension(): ValidatorFn {
         return (control: AbstractControl): ValidationErrors | null => {
             if (typeof control.value !== 'string' || !control.value.length) {
@@ -699,6 +2631,18 @@ export class RdioScannerAdminService implements OnDestroy {
         };
     }
 
+    private validateHeaders(): ValidatorFn {
+        return (control: AbstractControl): ValidationErrors | null => {
+            if (typeof control.value !== 'string' || !control.value.length) {
+                return null;
+            }
+
+            return control.value.split('\n')
+                .filter((line: string) => line.trim().length)
+                .every((line: string) => /^[\w-]+\s*:.*$/.test(line.trim())) ? null : { invalid: true };
+        };
+    }
+
     private validateId(): ValidatorFn {
         return (control: AbstractControl): ValidationErrors | null => {
             if (control.value === null || typeof control.value !== 'number') {
@@ -735,6 +2679,46 @@ export class RdioScannerAdminService implements OnDestroy {
         };
     }
 
+    private validateLinkedTalkgroup(): ValidatorFn {
+        return (control: AbstractControl): ValidationErrors | null => {
+            const linkedSystem = control.parent?.get('linkedSystem')?.value;
+
+            if (!linkedSystem && !control.value) {
+                return null;
+            }
+
+            if (!linkedSystem || !control.value) {
+                return { required: true };
+            }
+
+            const systems: System[] = control.root.get('systems')?.value || [];
+
+            const system = systems.find((sys) => sys.id === linkedSystem);
+
+            if (!system) {
+                return null;
+            }
+
+            if (control.parent?.parent?.parent?.get('id')?.value === linkedSystem && control.parent?.get('id')?.value === control.value) {
+                return { invalid: true };
+            }
+
+            return system.talkgroups?.some((tg) => tg.id === control.value) ? null : { invalid: true };
+        };
+    }
+
+    private validateRetentionScope(): ValidatorFn {
+        return (control: AbstractControl): ValidationErrors | null => {
+            const tagId = control.parent?.get('tagId')?.value;
+
+            if (!tagId && !control.value) {
+                return { required: true };
+            }
+
+            return tagId && control.value ? { invalid: true } : null;
+        };
+    }
+
     private validateTag(): ValidatorFn {
         return (control: AbstractControl): ValidationErrors | null => {
             if (typeof control.value !== 'number') {
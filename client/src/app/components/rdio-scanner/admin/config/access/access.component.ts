@@ -19,7 +19,7 @@
 
 import { CdkDragDrop, moveItemInArray } from '@angular/cdk/drag-drop';
 import { Component, Input, QueryList, ViewChildren } from '@angular/core';
-import { FormArray, FormGroup } from '@angular/forms';
+import { FormArray, FormControl, FormGroup } from '@angular/forms';
 import { MatDialog } from '@angular/material/dialog';
 import { MatExpansionPanel } from '@angular/material/expansion';
 import { RdioScannerAdminService } from '../../admin.service';
@@ -32,6 +32,8 @@ import { RdioScannerAdminSystemsSelectComponent } from '../systems/select/select
 export class RdioScannerAdminAccessComponent {
     @Input() form: FormArray | undefined;
 
+    days = ['Sunday', 'Monday', 'Tuesday', 'Wednesday', 'Thursday', 'Friday', 'Saturday'];
+
     hideCode = true;
 
     get accesses(): FormGroup[] {
@@ -53,6 +55,14 @@ export class RdioScannerAdminAccessComponent {
         this.form?.markAsDirty();
     }
 
+    addWindow(access: FormGroup): void {
+        const schedule = access.get('schedule') as FormArray;
+
+        schedule.push(this.adminService.newAccessWindowForm());
+
+        access.markAsDirty();
+    }
+
     closeAll(): void {
         this.panels?.forEach((panel) => panel.close());
     }
@@ -73,6 +83,20 @@ export class RdioScannerAdminAccessComponent {
         this.form?.markAsDirty();
     }
 
+    removeWindow(access: FormGroup, index: number): void {
+        const schedule = access.get('schedule') as FormArray;
+
+        schedule.removeAt(index);
+
+        access.markAsDirty();
+    }
+
+    schedule(access: FormGroup): FormGroup[] {
+        const schedule = access.get('schedule') as FormArray;
+
+        return schedule.controls as FormGroup[];
+    }
+
     select(access: FormGroup): void {
         const matDialogRef = this.matDialog.open(RdioScannerAdminSystemsSelectComponent, { data: access });
 
@@ -84,4 +108,20 @@ export class RdioScannerAdminAccessComponent {
             }
         });
     }
+
+    selectDeny(access: FormGroup): void {
+        const deny = new FormGroup({ systems: new FormControl(access.value.deny || []) });
+
+        deny.setParent(access);
+
+        const matDialogRef = this.matDialog.open(RdioScannerAdminSystemsSelectComponent, { data: deny });
+
+        matDialogRef.afterClosed().subscribe((data) => {
+            if (data) {
+                access.get('deny')?.setValue(Array.isArray(data) && !data.length ? null : data);
+
+                access.markAsDirty();
+            }
+        });
+    }
 }
@@ -34,10 +34,18 @@ export class RdioScannerAdminConfigComponent implements OnDestroy, OnInit {
 
     form: FormGroup | undefined;
 
+    readOnly: boolean = false;
+
+    restartRequired: string[] = [];
+
     get access(): FormArray {
         return this.form?.get('access') as FormArray;
     }
 
+    get alerts(): FormArray {
+        return this.form?.get('alerts') as FormArray;
+    }
+
     get apiKeys(): FormArray {
         return this.form?.get('apiKeys') as FormArray;
     }
@@ -58,6 +66,14 @@ export class RdioScannerAdminConfigComponent implements OnDestroy, OnInit {
         return this.form?.get('options') as FormGroup;
     }
 
+    get retentions(): FormArray {
+        return this.form?.get('retentions') as FormArray;
+    }
+
+    get sdr(): FormArray {
+        return this.form?.get('sdr') as FormArray;
+    }
+
     get systems(): FormArray {
         return this.form?.get('systems') as FormArray;
     }
@@ -66,6 +82,10 @@ export class RdioScannerAdminConfigComponent implements OnDestroy, OnInit {
         return this.form?.get('tags') as FormArray;
     }
 
+    get webhooks(): FormArray {
+        return this.form?.get('webhooks') as FormArray;
+    }
+
     private config: Config | undefined;
 
     private eventSubscription = this.adminService.event.subscribe(async (event: AdminEvent) => {
@@ -86,6 +106,18 @@ export class RdioScannerAdminConfigComponent implements OnDestroy, OnInit {
         if ('docker' in event) {
             this.docker = event.docker!;
         }
+
+        if ('readOnly' in event) {
+            this.readOnly = event.readOnly!;
+
+            this.ngChangeDetectorRef.markForCheck();
+        }
+
+        if ('restartRequired' in event) {
+            this.restartRequired = event.restartRequired!;
+
+            this.ngChangeDetectorRef.markForCheck();
+        }
     });
 
     @ViewChildren(MatExpansionPanel) private panels: QueryList<MatExpansionPanel> | undefined;
@@ -102,6 +134,8 @@ export class RdioScannerAdminConfigComponent implements OnDestroy, OnInit {
     async ngOnInit(): Promise<void> {
         this.config = await this.adminService.getConfig();
 
+        this.restartRequired = this.adminService.restartRequired;
+
         this.reset();
     }
 
@@ -76,6 +76,10 @@ export class RdioScannerAdminDirWatchComponent implements OnChanges {
         this.form?.markAsDirty();
     }
 
+    isRemote(dirWatch: FormGroup): boolean {
+        return /^(ftps?|s3|sftp):\/\//i.test(dirWatch.value.directory || '');
+    }
+
     closeAll(): void {
         this.panels?.forEach((panel) => panel.close());
     }
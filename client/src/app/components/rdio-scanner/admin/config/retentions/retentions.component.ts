@@ -0,0 +1,91 @@
+/*
+ * *****************************************************************************
+ * Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>
+ * ****************************************************************************
+ */
+
+import { CdkDragDrop, moveItemInArray } from '@angular/cdk/drag-drop';
+import { Component, Input, QueryList, ViewChildren } from '@angular/core';
+import { FormArray, FormGroup } from '@angular/forms';
+import { MatExpansionPanel } from '@angular/material/expansion';
+import { AdminRetentionMatch, RdioScannerAdminService, System, Talkgroup } from '../../admin.service';
+
+@Component({
+    selector: 'rdio-scanner-admin-retentions',
+    templateUrl: './retentions.component.html',
+})
+export class RdioScannerAdminRetentionsComponent {
+    @Input() form: FormArray | undefined;
+
+    testCallId: number | undefined;
+
+    testResult: AdminRetentionMatch | undefined;
+
+    testSystem: number | undefined;
+
+    testTalkgroup: number | undefined;
+
+    get retentions(): FormGroup[] {
+        return this.form?.controls
+            .sort((a, b) => a.value.order - b.value.order) as FormGroup[];
+    }
+
+    @ViewChildren(MatExpansionPanel) private panels: QueryList<MatExpansionPanel> | undefined;
+
+    constructor(private adminService: RdioScannerAdminService) { }
+
+    add(): void {
+        const retention = this.adminService.newRetentionForm({ days: 365 });
+
+        retention.markAllAsTouched();
+
+        this.form?.insert(0, retention);
+
+        this.form?.markAsDirty();
+    }
+
+    closeAll(): void {
+        this.panels?.forEach((panel) => panel.close());
+    }
+
+    drop(event: CdkDragDrop<FormGroup[]>): void {
+        if (event.previousIndex !== event.currentIndex) {
+            moveItemInArray(event.container.data, event.previousIndex, event.currentIndex);
+
+            event.container.data.forEach((dat, idx) => dat.get('order')?.setValue(idx + 1, { emitEvent: false }));
+
+            this.form?.markAsDirty();
+        }
+    }
+
+    getTalkgroups(retention: FormGroup): Talkgroup[] {
+        const systems: System[] = this.form?.root.get('systems')?.value || [];
+
+        return systems.find((system) => system.id === retention.value.system)?.talkgroups || [];
+    }
+
+    remove(index: number): void {
+        this.form?.removeAt(index);
+
+        this.form?.markAsDirty();
+    }
+
+    async test(): Promise<void> {
+        this.testResult = await this.adminService.testRetention(this.testCallId
+            ? { callId: this.testCallId }
+            : { system: this.testSystem, talkgroup: this.testTalkgroup });
+    }
+}
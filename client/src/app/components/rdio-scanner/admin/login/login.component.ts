@@ -17,7 +17,7 @@
  * ****************************************************************************
  */
 
-import { Component, EventEmitter, Output } from '@angular/core';
+import { Component, EventEmitter, OnInit, Output } from '@angular/core';
 import { FormBuilder, Validators } from '@angular/forms';
 import { RdioScannerAdminService } from '../admin.service';
 
@@ -26,20 +26,34 @@ import { RdioScannerAdminService } from '../admin.service';
     styleUrls: ['./login.component.scss'],
     templateUrl: './login.component.html',
 })
-export class RdioScannerAdminLoginComponent {
+export class RdioScannerAdminLoginComponent implements OnInit {
     @Output() loggedIn = new EventEmitter<void>();
 
     form = this.formBuilder.group({
+        code: [null],
         password: [null, Validators.required],
+        username: [null],
     });
 
     message = '';
 
+    oidc = false;
+
+    saml = false;
+
+    totp = false;
+
     constructor(
         private adminService: RdioScannerAdminService,
         private formBuilder: FormBuilder,
     ) { }
 
+    async ngOnInit(): Promise<void> {
+        this.oidc = await this.adminService.isOidcEnabled();
+
+        this.saml = await this.adminService.isSamlEnabled();
+    }
+
     async login(password = this.form.get('password')?.value): Promise<void> {
         if (!password) {
             return;
@@ -47,16 +61,36 @@ export class RdioScannerAdminLoginComponent {
 
         this.form.disable();
 
-        const loggedIn = await this.adminService.login(password);
+        const code = this.form.get('code')?.value || undefined;
+
+        const loggedIn = await this.adminService.login(password, this.form.get('username')?.value || undefined, code);
+
+        if (loggedIn === 'totp') {
+            this.form.enable();
+            this.form.get('code')?.reset();
+
+            this.message = code ? 'Invalid authentication code' : '';
 
-        if (loggedIn) {
+            this.totp = true;
+
+        } else if (loggedIn) {
             this.loggedIn.emit();
 
         } else {
             this.form.enable();
             this.form.reset();
 
-            this.message = 'Invalid password';
+            this.message = 'Invalid username or password';
+
+            this.totp = false;
         }
     }
+
+    loginWithOidc(): void {
+        this.adminService.loginWithOidc();
+    }
+
+    loginWithSaml(): void {
+        this.adminService.loginWithSaml();
+    }
 }
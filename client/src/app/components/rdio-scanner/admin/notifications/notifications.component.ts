@@ -0,0 +1,90 @@
+/*
+ * *****************************************************************************
+ * Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>
+ * ****************************************************************************
+ */
+
+import { Component, OnDestroy } from '@angular/core';
+import { AdminEvent, AdminNotification, RdioScannerAdminService } from '../admin.service';
+
+@Component({
+    selector: 'rdio-scanner-admin-notifications',
+    styleUrls: ['./notifications.component.scss'],
+    templateUrl: './notifications.component.html',
+})
+export class RdioScannerAdminNotificationsComponent implements OnDestroy {
+    notifications: AdminNotification[] = [];
+
+    pending = false;
+
+    unread = this.adminService.notifications;
+
+    unreadOnly = false;
+
+    private eventSubscription = this.adminService.event.subscribe((event: AdminEvent) => {
+        if ('notifications' in event) {
+            this.unread = event.notifications || 0;
+        }
+
+        if (event.notification) {
+            this.reload();
+        }
+    });
+
+    constructor(private adminService: RdioScannerAdminService) { }
+
+    ngOnDestroy(): void {
+        this.eventSubscription.unsubscribe();
+    }
+
+    async mark(notification: AdminNotification, read: boolean): Promise<void> {
+        if (await this.adminService.markNotifications([notification._id], read)) {
+            await this.reload();
+        }
+    }
+
+    async markAll(): Promise<void> {
+        if (await this.adminService.markNotifications('all')) {
+            await this.reload();
+        }
+    }
+
+    async reload(): Promise<void> {
+        this.pending = true;
+
+        const res = await this.adminService.getNotifications(this.unreadOnly);
+
+        if (res) {
+            this.notifications = res.notifications;
+
+            this.unread = res.unread;
+        }
+
+        this.pending = false;
+    }
+
+    async remove(notification: AdminNotification): Promise<void> {
+        if (await this.adminService.removeNotification(notification._id)) {
+            await this.reload();
+        }
+    }
+
+    async toggleUnreadOnly(): Promise<void> {
+        this.unreadOnly = !this.unreadOnly;
+
+        await this.reload();
+    }
+}
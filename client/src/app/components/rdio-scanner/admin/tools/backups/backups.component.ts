@@ -0,0 +1,86 @@
+/*
+ * *****************************************************************************
+ * Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>
+ * ****************************************************************************
+ */
+
+import { Component, OnInit } from '@angular/core';
+import { MatSnackBar } from '@angular/material/snack-bar';
+import { AdminBackup, AdminBackups, RdioScannerAdminService } from '../../admin.service';
+
+@Component({
+    selector: 'rdio-scanner-admin-backups',
+    styleUrls: ['./backups.component.scss'],
+    templateUrl: './backups.component.html',
+})
+export class RdioScannerAdminBackupsComponent implements OnInit {
+    backups: AdminBackups | undefined;
+
+    loading = false;
+
+    get isAdmin(): boolean {
+        return this.adminService.hasRole('admin');
+    }
+
+    get isEditor(): boolean {
+        return this.adminService.hasRole('editor');
+    }
+
+    constructor(
+        private adminService: RdioScannerAdminService,
+        private matSnackBar: MatSnackBar,
+    ) { }
+
+    ngOnInit(): void {
+        this.reload();
+    }
+
+    async reload(): Promise<void> {
+        this.loading = true;
+
+        this.backups = await this.adminService.getBackups();
+
+        this.loading = false;
+    }
+
+    async restore(backup: AdminBackup): Promise<void> {
+        if (!confirm(`Restore the database from ${backup.name}? The server will restart and all changes made since this backup will be lost.`)) {
+            return;
+        }
+
+        this.loading = true;
+
+        if (await this.adminService.restoreBackup(backup.name)) {
+            this.matSnackBar.open('Database restored, the server is restarting', '', { duration: 10000 });
+        }
+
+        this.loading = false;
+    }
+
+    async run(): Promise<void> {
+        this.loading = true;
+
+        const backup = await this.adminService.runBackup();
+
+        if (backup) {
+            this.matSnackBar.open(`Backup ${backup.name} completed`, '', { duration: 5000 });
+        }
+
+        this.backups = await this.adminService.getBackups();
+
+        this.loading = false;
+    }
+}
@@ -0,0 +1,146 @@
+/*
+ * *****************************************************************************
+ * Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>
+ * ****************************************************************************
+ */
+import { DOCUMENT } from '@angular/common';
+import { Component, Inject, OnInit } from '@angular/core';
+import { AdminBookmark, AdminBookmarksOptions, RdioScannerAdminService } from '../../admin.service';
+
+@Component({
+    selector: 'rdio-scanner-admin-bookmarks',
+    styleUrls: ['./bookmarks.component.scss'],
+    templateUrl: './bookmarks.component.html',
+})
+export class RdioScannerAdminBookmarksComponent implements OnInit {
+    static PAGE_SIZE = 50;
+
+    bookmarks: AdminBookmark[] = [];
+
+    count = 0;
+
+    draft = { callId: 0, note: '', shared: false };
+
+    loading = false;
+
+    options: AdminBookmarksOptions = { limit: RdioScannerAdminBookmarksComponent.PAGE_SIZE, mine: false, offset: 0, q: '' };
+
+    get page(): number {
+        return Math.floor((this.options.offset || 0) / RdioScannerAdminBookmarksComponent.PAGE_SIZE) + 1;
+    }
+
+    get pages(): number {
+        return Math.max(1, Math.ceil(this.count / RdioScannerAdminBookmarksComponent.PAGE_SIZE));
+    }
+
+    constructor(
+        private adminService: RdioScannerAdminService,
+        @Inject(DOCUMENT) private document: Document,
+    ) { }
+
+    ngOnInit(): void {
+        this.reload();
+    }
+
+    edit(bookmark: AdminBookmark): void {
+        this.draft = { callId: bookmark.callId, note: bookmark.note, shared: bookmark.shared };
+    }
+
+    async export(format: 'csv' | 'ndjson'): Promise<void> {
+        this.loading = true;
+
+        const blob = await this.adminService.exportBookmarks({ ...this.options, limit: undefined, offset: undefined }, format);
+
+        this.loading = false;
+
+        if (!blob) return;
+
+        const fileUri = URL.createObjectURL(blob);
+
+        const el = this.document.createElement('a');
+
+        el.style.display = 'none';
+
+        el.setAttribute('href', fileUri);
+        el.setAttribute('download', `rdio-scanner-bookmarks.${format}`);
+
+        this.document.body.appendChild(el);
+
+        el.click();
+
+        this.document.body.removeChild(el);
+
+        URL.revokeObjectURL(fileUri);
+    }
+
+    async next(): Promise<void> {
+        if (this.page < this.pages) {
+            this.options.offset = (this.options.offset || 0) + RdioScannerAdminBookmarksComponent.PAGE_SIZE;
+
+            await this.reload();
+        }
+    }
+
+    async previous(): Promise<void> {
+        if (this.page > 1) {
+            this.options.offset = Math.max(0, (this.options.offset || 0) - RdioScannerAdminBookmarksComponent.PAGE_SIZE);
+
+            await this.reload();
+        }
+    }
+
+    async reload(): Promise<void> {
+        this.loading = true;
+
+        const res = await this.adminService.getBookmarks(this.options);
+
+        this.bookmarks = res?.bookmarks || [];
+
+        this.count = res?.count || 0;
+
+        this.loading = false;
+    }
+
+    async remove(bookmark: AdminBookmark): Promise<void> {
+        this.loading = true;
+
+        if (await this.adminService.removeBookmark(bookmark._id)) {
+            await this.reload();
+        }
+
+        this.loading = false;
+    }
+
+    async save(): Promise<void> {
+        if (!this.draft.callId) return;
+
+        this.loading = true;
+
+        if (await this.adminService.saveBookmark(this.draft)) {
+            this.draft = { callId: 0, note: '', shared: false };
+
+            await this.reload();
+        }
+
+        this.loading = false;
+    }
+
+    async search(): Promise<void> {
+        this.options.offset = 0;
+
+        await this.reload();
+    }
+}
@@ -0,0 +1,60 @@
+/*
+ * *****************************************************************************
+ * Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>
+ * ****************************************************************************
+ */
+
+import { Component, OnInit } from '@angular/core';
+import { AdminDuplicates, RdioScannerAdminService } from '../../admin.service';
+
+@Component({
+    selector: 'rdio-scanner-admin-duplicates',
+    styleUrls: ['./duplicates.component.scss'],
+    templateUrl: './duplicates.component.html',
+})
+export class RdioScannerAdminDuplicatesComponent implements OnInit {
+    duplicates: AdminDuplicates | undefined;
+
+    loading = false;
+
+    get isEditor(): boolean {
+        return this.adminService.hasRole('editor');
+    }
+
+    constructor(private adminService: RdioScannerAdminService) { }
+
+    ngOnInit(): void {
+        this.reload();
+    }
+
+    async reload(): Promise<void> {
+        this.loading = true;
+
+        this.duplicates = await this.adminService.getDuplicates();
+
+        this.loading = false;
+    }
+
+    async reset(): Promise<void> {
+        this.loading = true;
+
+        if (await this.adminService.resetDuplicates()) {
+            this.duplicates = await this.adminService.getDuplicates();
+        }
+
+        this.loading = false;
+    }
+}
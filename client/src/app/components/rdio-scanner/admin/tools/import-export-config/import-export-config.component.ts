@@ -30,6 +30,18 @@ import { Config, RdioScannerAdminService } from '../../admin.service';
 export class RdioScannerAdminImportExportConfigComponent {
     @Output() config = new EventEmitter<Config>();
 
+    format: 'json' | 'yaml' = 'json';
+
+    loading = false;
+
+    sections: string[] = ['access', 'alerts', 'apiKeys', 'dirWatch', 'downstreams', 'groups', 'options', 'retentions', 'sdr', 'systems', 'tags', 'webhooks'];
+
+    selected: string[] = [...this.sections];
+
+    get isEditor(): boolean {
+        return this.adminService.hasRole('editor');
+    }
+
     constructor(
         private adminService: RdioScannerAdminService,
         @Inject(DOCUMENT) private document: Document,
@@ -37,27 +49,30 @@ export class RdioScannerAdminImportExportConfigComponent {
     ) { }
 
     async export(): Promise<void> {
-        const config = await this.adminService.getConfig();
+        this.loading = true;
+
+        const blob = await this.adminService.exportConfig(this.selected, this.format);
+
+        this.loading = false;
+
+        if (!blob) return;
 
-        const file = encodeURIComponent(JSON.stringify(config)).replace(/%([0-9A-F]{2})/g, (_, c) => {
-            return String.fromCharCode(parseInt(c, 16));
-        });
-        const fileName = 'rdio-scanner.json';
-        const fileType = 'application/json';
-        const fileUri = `data:${fileType};base64,${window.btoa(file)}`;
+        const fileUri = URL.createObjectURL(blob);
 
         const el = this.document.createElement('a');
 
         el.style.display = 'none';
 
         el.setAttribute('href', fileUri);
-        el.setAttribute('download', fileName);
+        el.setAttribute('download', `rdio-scanner.${this.format}`);
 
         this.document.body.appendChild(el);
 
         el.click();
 
         this.document.body.removeChild(el);
+
+        URL.revokeObjectURL(fileUri);
     }
 
     async import(event: Event): Promise<void> {
@@ -67,23 +82,32 @@ export class RdioScannerAdminImportExportConfigComponent {
 
         if (!(file instanceof File)) return;
 
-        const reader = new FileReader();
+        this.loading = true;
 
-        reader.onloadend = () => {
-            target.value = '';
+        try {
+            const res = await this.adminService.importConfig(await file.text(), this.selected);
 
-            try {
-                const res = decodeURIComponent(Array.prototype.map.call(reader.result, (c) => {
-                    return '%' + ('00' + c.charCodeAt(0).toString(16)).slice(-2)
-                }).join(''));
+            if (!res) return;
 
-                this.config.emit(JSON.parse(res));
+            if (!res.valid) {
+                const errors = res.sections.reduce((e, s) => e.concat(s.errors), [] as string[]);
 
-            } catch (error) {
-                this.matSnackBar.open(error as string, '', { duration: 5000 });
+                this.matSnackBar.open(errors.slice(0, 3).join(', ') || 'Invalid configuration', '', { duration: 10000 });
+
+                return;
             }
-        };
 
-        reader.readAsBinaryString(file);
+            const config = await this.adminService.getConfig();
+
+            this.config.emit({ ...config, ...res.config });
+
+        } catch (error) {
+            this.matSnackBar.open(error as string, '', { duration: 5000 });
+
+        } finally {
+            target.value = '';
+
+            this.loading = false;
+        }
     }
 }
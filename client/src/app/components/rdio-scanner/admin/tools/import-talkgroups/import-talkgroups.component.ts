@@ -17,71 +17,71 @@
  * ****************************************************************************
  */
 
-import { Component, EventEmitter, Output } from '@angular/core';
-import { Config, RdioScannerAdminService } from '../../admin.service';
+import { Component, OnInit } from '@angular/core';
+import { MatSnackBar } from '@angular/material/snack-bar';
+import { AdminTalkgroupsImport, AdminTalkgroupsImportResult, RdioScannerAdminService, System } from '../../admin.service';
 
 @Component({
     selector: 'rdio-scanner-admin-import-talkgroups',
     styleUrls: ['./import-talkgroups.component.scss'],
     templateUrl: './import-talkgroups.component.html',
 })
-export class RdioScannerAdminImportTalkgroupsComponent {
-    @Output() config = new EventEmitter<Config>();
+export class RdioScannerAdminImportTalkgroupsComponent implements OnInit {
+    file: string | undefined;
 
-    csv: string[][] = [];
+    loading = false;
 
-    fields = [
-        // [id, label, description, tag, group]
-        [0, 3, 4, 5, 6], // trunk-recorder
-        [0, 2, 4, 5, 6], // radioreference.com
-    ];
+    request: AdminTalkgroupsImport = {
+        conflict: 'keep',
+        dryRun: true,
+        system: 0,
+    };
 
-    mode = 0;
+    result: AdminTalkgroupsImportResult | undefined;
 
-    tableColumns = ['id', 'label', 'description', 'tag', 'group', 'action'];
+    systems: System[] = [];
 
-    constructor(private adminService: RdioScannerAdminService) { }
+    tableColumns = ['id', 'label', 'name', 'tag', 'group'];
 
-    async import(): Promise<void> {
-        const config = await this.adminService.getConfig();
+    get isEditor(): boolean {
+        return this.adminService.hasRole('editor');
+    }
 
-        this.csv.forEach((tg) => {
-            const group = tg[this.fields[this.mode][4]];
+    constructor(
+        private adminService: RdioScannerAdminService,
+        private matSnackBar: MatSnackBar,
+    ) { }
 
-            if (!config.groups?.find((g) => g.label === group)) {
-                const id = config.groups?.reduce((pv, cv) => typeof cv._id === 'number' && cv._id >= pv ? cv._id + 1 : pv, 1);
+    async ngOnInit(): Promise<void> {
+        const config = await this.adminService.getConfig();
 
-                config.groups?.push({ _id: id, label: group });
-            }
+        this.systems = config.systems || [];
 
-            const tag = tg[this.fields[this.mode][3]];
+        if (this.systems.length > 0) {
+            this.selectSystem(this.systems[0].id!);
+        }
+    }
 
-            if (!config.tags?.find((t) => t.label === tag)) {
-                const id = config.tags?.reduce((pv, cv) => typeof cv._id === 'number' && cv._id >= pv ? cv._id + 1 : pv, 1);
+    async import(): Promise<void> {
+        this.loading = true;
 
-                config.tags?.push({ _id: id, label: tag });
-            }
-        });
+        const result = await this.adminService.importTalkgroups({ ...this.request, dryRun: false });
 
-        const talkgroups = this.csv.map((csv, idx) => {
-            const groupId = config.groups?.find((g) => g.label === csv[this.fields[this.mode][4]])?._id;
-            const tagId = config.tags?.find((t) => t.label === csv[this.fields[this.mode][3]])?._id;
+        if (result) {
+            this.matSnackBar.open(`${result.changes.length} talkgroups changed`, '', { duration: 5000 });
 
-            return {
-                id: +csv[this.fields[this.mode][0]],
-                label: csv[this.fields[this.mode][1]],
-                name: csv[this.fields[this.mode][2]],
-                order: idx + 1,
-                tagId,
-                groupId,
-            };
-        });
+            this.reset();
+        }
+
+        this.loading = false;
+    }
 
-        config.systems?.unshift({ talkgroups });
+    async preview(): Promise<void> {
+        this.loading = true;
 
-        this.csv = [];
+        this.result = await this.adminService.importTalkgroups({ ...this.request, dryRun: true });
 
-        this.config.emit(config);
+        this.loading = false;
     }
 
     async read(event: Event): Promise<void> {
@@ -100,13 +100,31 @@ export class RdioScannerAdminImportTalkgroupsComponent {
                 return;
             }
 
-            this.csv = reader.result
-                .split(/\n|\r\n/)
-                .map((tg) => tg.replace(/^"|"$/g, '').split(/"*,"*/))
-                .filter((tg) => tg && /^[0-9]+$/.test(tg[0]))
-                .filter((tg, idx, arr) => arr.findIndex((a) => a[0] === tg[0]) === idx);
+            this.file = file.name;
+
+            this.request.file = reader.result;
+
+            this.result = undefined;
         };
 
-        reader.readAsBinaryString(file);
+        reader.readAsText(file);
+    }
+
+    reset(): void {
+        this.file = undefined;
+
+        this.request.file = undefined;
+
+        this.result = undefined;
+    }
+
+    selectSystem(id: number): void {
+        const system = this.systems.find((system) => system.id === id);
+
+        this.request.conflict = system?.radioReferenceConflict || 'keep';
+        this.request.radioReferenceSystemId = system?.radioReferenceSystemId || undefined;
+        this.request.system = id;
+
+        this.result = undefined;
     }
 }
@@ -17,49 +17,71 @@
  * ****************************************************************************
  */
 
-import { Component, EventEmitter, OnInit, Output } from '@angular/core';
-import { Config, RdioScannerAdminService, System } from '../../admin.service';
+import { Component, OnInit } from '@angular/core';
+import { MatSnackBar } from '@angular/material/snack-bar';
+import { AdminUnitsImport, AdminUnitsImportResult, RdioScannerAdminService, System } from '../../admin.service';
 
 @Component({
     selector: 'rdio-scanner-admin-import-units',
     styleUrls: ['./import-units.component.scss'],
     templateUrl: './import-units.component.html',
 })
-export class RdioScannerAdminImportUnitsComponent implements OnInit{
-    @Output() config = new EventEmitter<Config>();
+export class RdioScannerAdminImportUnitsComponent implements OnInit {
+    file: string | undefined;
 
-    baseConfig: Config = {};
+    loading = false;
 
-    csv: string[][] = [];
+    request: AdminUnitsImport = {
+        conflict: 'keep',
+        dryRun: true,
+        system: 0,
+    };
 
-    system: System | undefined;
+    result: AdminUnitsImportResult | undefined;
 
-    tableColumns = ['id', 'label', 'action'];
+    systems: System[] = [];
 
-    constructor(private adminService: RdioScannerAdminService) { }
+    tableColumns = ['id', 'previous', 'label'];
+
+    get isEditor(): boolean {
+        return this.adminService.hasRole('editor');
+    }
+
+    constructor(
+        private adminService: RdioScannerAdminService,
+        private matSnackBar: MatSnackBar,
+    ) { }
 
     async ngOnInit(): Promise<void> {
-        this.baseConfig = await this.adminService.getConfig();
+        const config = await this.adminService.getConfig();
 
-        if (Array.isArray(this.baseConfig.systems) && this.baseConfig.systems.length > 0) {
-            this.system = this.baseConfig.systems![0];
+        this.systems = config.systems || [];
+
+        if (this.systems.length > 0) {
+            this.selectSystem(this.systems[0].id!);
         }
     }
 
     async import(): Promise<void> {
-        if (this.system === undefined) return;
+        this.loading = true;
+
+        const result = await this.adminService.importUnits({ ...this.request, dryRun: false });
 
-        const units = this.csv.map((csv, idx) => ({
-            id: +csv[0],
-            label: csv[1],
-            order: idx + 1,
-        }));
+        if (result) {
+            this.matSnackBar.open(`${result.changes.length} unit aliases changed`, '', { duration: 5000 });
 
-        this.system!.units = this.system!.units!.concat(units);
+            this.reset();
+        }
+
+        this.loading = false;
+    }
 
-        this.csv = [];
+    async preview(): Promise<void> {
+        this.loading = true;
 
-        this.config.emit(this.baseConfig);
+        this.result = await this.adminService.importUnits({ ...this.request, dryRun: true });
+
+        this.loading = false;
     }
 
     async read(event: Event): Promise<void> {
@@ -78,13 +100,31 @@ export class RdioScannerAdminImportUnitsComponent implements OnInit{
                 return;
             }
 
-            this.csv = reader.result
-                .split(/\n|\r\n/)
-                .map((tg) => tg.replace(/^"|"$/g, '').split(/"*,"*/))
-                .filter((tg) => tg && /^[0-9]+$/.test(tg[0]))
-                .filter((tg, idx, arr) => arr.findIndex((a) => a[0] === tg[0]) === idx);
+            this.file = file.name;
+
+            this.request.csv = reader.result;
+
+            this.result = undefined;
         };
 
-        reader.readAsBinaryString(file);
+        reader.readAsText(file);
+    }
+
+    reset(): void {
+        this.file = undefined;
+
+        this.request.csv = undefined;
+
+        this.result = undefined;
+    }
+
+    selectSystem(id: number): void {
+        const system = this.systems.find((system) => system.id === id);
+
+        this.request.conflict = system?.unitsImportConflict || 'keep';
+        this.request.system = id;
+        this.request.url = system?.unitsImportUrl || '';
+
+        this.result = undefined;
     }
 }
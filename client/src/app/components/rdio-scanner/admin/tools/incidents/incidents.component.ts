@@ -0,0 +1,231 @@
+/*
+ * *****************************************************************************
+ * Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>
+ * ****************************************************************************
+ */
+import { DOCUMENT } from '@angular/common';
+import { Component, Inject, OnInit } from '@angular/core';
+import {
+    AdminIncident,
+    AdminIncidentCall,
+    AdminIncidentNote,
+    AdminIncidentStatus,
+    RdioScannerAdminService,
+    System,
+} from '../../admin.service';
+
+@Component({
+    selector: 'rdio-scanner-admin-incidents',
+    styleUrls: ['./incidents.component.scss'],
+    templateUrl: './incidents.component.html',
+})
+export class RdioScannerAdminIncidentsComponent implements OnInit {
+    attach = { callIds: '', from: '', system: 0, talkgroup: 0, to: '' };
+
+    incident: AdminIncident | undefined;
+
+    incidents: AdminIncident[] = [];
+
+    loading = false;
+
+    note = '';
+
+    status: AdminIncidentStatus | '' = '';
+
+    systems: System[] = [];
+
+    get isEditor(): boolean {
+        return this.adminService.hasRole('editor');
+    }
+
+    get talkgroups(): { id: number; label: string }[] {
+        const system = this.systems.find((s) => s.id === this.attach.system);
+
+        return (system?.talkgroups || []).map((talkgroup) => ({ id: talkgroup.id as number, label: talkgroup.label as string }));
+    }
+
+    constructor(
+        private adminService: RdioScannerAdminService,
+        @Inject(DOCUMENT) private document: Document,
+    ) { }
+
+    async ngOnInit(): Promise<void> {
+        const config = await this.adminService.getConfig();
+
+        this.systems = config.systems || [];
+
+        await this.reload();
+    }
+
+    async addNote(): Promise<void> {
+        if (!this.incident?._id || !this.note.trim()) return;
+
+        this.loading = true;
+
+        if (await this.adminService.addIncidentNote(this.incident._id, this.note)) {
+            this.note = '';
+
+            await this.open(this.incident._id);
+        }
+
+        this.loading = false;
+    }
+
+    async attachByFilter(): Promise<void> {
+        if (!this.incident?._id || !this.attach.from || !this.attach.to) return;
+
+        this.loading = true;
+
+        const attached = await this.adminService.attachIncidentCalls(this.incident._id, {
+            from: new Date(this.attach.from).toISOString(),
+            system: this.attach.system || undefined,
+            talkgroup: this.attach.system && this.attach.talkgroup || undefined,
+            to: new Date(this.attach.to).toISOString(),
+        });
+
+        if (attached !== undefined) {
+            await this.open(this.incident._id);
+        }
+
+        this.loading = false;
+    }
+
+    async attachById(): Promise<void> {
+        const ids = this.attach.callIds.split(/[^0-9]+/).map((id) => parseInt(id, 10)).filter((id) => id > 0);
+
+        if (!this.incident?._id || !ids.length) return;
+
+        this.loading = true;
+
+        if (await this.adminService.attachIncidentCalls(this.incident._id, ids) !== undefined) {
+            this.attach.callIds = '';
+
+            await this.open(this.incident._id);
+        }
+
+        this.loading = false;
+    }
+
+    close(): void {
+        this.incident = undefined;
+
+        this.reload();
+    }
+
+    create(): void {
+        this.incident = { description: '', status: 'open', title: '' };
+    }
+
+    async detach(call: AdminIncidentCall): Promise<void> {
+        if (!this.incident?._id) return;
+
+        this.loading = true;
+
+        if (await this.adminService.detachIncidentCall(this.incident._id, call.callId)) {
+            await this.open(this.incident._id);
+        }
+
+        this.loading = false;
+    }
+
+    async export(): Promise<void> {
+        if (!this.incident?._id) return;
+
+        this.loading = true;
+
+        const blob = await this.adminService.exportIncident(this.incident._id);
+
+        this.loading = false;
+
+        if (!blob) return;
+
+        const fileUri = URL.createObjectURL(blob);
+
+        const el = this.document.createElement('a');
+
+        el.style.display = 'none';
+
+        el.setAttribute('href', fileUri);
+        el.setAttribute('download', `rdio-scanner-incident-${this.incident._id}.zip`);
+
+        this.document.body.appendChild(el);
+
+        el.click();
+
+        this.document.body.removeChild(el);
+
+        URL.revokeObjectURL(fileUri);
+    }
+
+    async open(id: number): Promise<void> {
+        this.loading = true;
+
+        this.incident = await this.adminService.getIncident(id);
+
+        this.loading = false;
+    }
+
+    async reload(): Promise<void> {
+        this.loading = true;
+
+        this.incidents = await this.adminService.getIncidents(this.status || undefined) || [];
+
+        this.loading = false;
+    }
+
+    async remove(): Promise<void> {
+        if (!this.incident?._id) return;
+
+        this.loading = true;
+
+        if (await this.adminService.removeIncident(this.incident._id)) {
+            this.close();
+        }
+
+        this.loading = false;
+    }
+
+    async removeNote(note: AdminIncidentNote): Promise<void> {
+        if (!this.incident?._id) return;
+
+        this.loading = true;
+
+        if (await this.adminService.removeIncidentNote(this.incident._id, note._id)) {
+            await this.open(this.incident._id);
+        }
+
+        this.loading = false;
+    }
+
+    async save(): Promise<void> {
+        if (!this.incident?.title.trim()) return;
+
+        this.loading = true;
+
+        const incident = await this.adminService.saveIncident({
+            _id: this.incident._id,
+            description: this.incident.description,
+            status: this.incident.status,
+            title: this.incident.title,
+        });
+
+        if (incident) {
+            this.incident = incident;
+        }
+
+        this.loading = false;
+    }
+}
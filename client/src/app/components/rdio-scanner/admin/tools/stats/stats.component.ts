@@ -0,0 +1,70 @@
+/*
+ * *****************************************************************************
+ * Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>
+ * ****************************************************************************
+ */
+
+import { Component, OnInit } from '@angular/core';
+import { AdminStats, AdminStatsOptions, RdioScannerAdminService, System } from '../../admin.service';
+
+@Component({
+    selector: 'rdio-scanner-admin-stats',
+    styleUrls: ['./stats.component.scss'],
+    templateUrl: './stats.component.html',
+})
+export class RdioScannerAdminStatsComponent implements OnInit {
+    loading = false;
+
+    max = 0;
+
+    options: AdminStatsOptions = {
+        bucket: 'day',
+        linked: false,
+        system: 0,
+    };
+
+    stats: AdminStats | undefined;
+
+    systems: System[] = [];
+
+    constructor(private adminService: RdioScannerAdminService) { }
+
+    async ngOnInit(): Promise<void> {
+        const config = await this.adminService.getConfig();
+
+        this.systems = config.systems || [];
+
+        await this.reload();
+    }
+
+    getSystemLabel(id: number): string {
+        return this.systems.find((system) => system.id === id)?.label || `${id}`;
+    }
+
+    async reload(): Promise<void> {
+        this.loading = true;
+
+        this.stats = await this.adminService.getStats({
+            bucket: this.options.bucket,
+            linked: this.options.linked || undefined,
+            system: this.options.system || undefined,
+        });
+
+        this.max = Math.max(0, ...(this.stats?.buckets || []).map((bucket) => bucket.calls));
+
+        this.loading = false;
+    }
+}
@@ -0,0 +1,46 @@
+/*
+ * *****************************************************************************
+ * Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>
+ * ****************************************************************************
+ */
+
+import { Component, OnInit } from '@angular/core';
+import { AdminTelemetry, RdioScannerAdminService } from '../../admin.service';
+
+@Component({
+    selector: 'rdio-scanner-admin-telemetry',
+    styleUrls: ['./telemetry.component.scss'],
+    templateUrl: './telemetry.component.html',
+})
+export class RdioScannerAdminTelemetryComponent implements OnInit {
+    loading = false;
+
+    telemetry: AdminTelemetry | undefined;
+
+    constructor(private adminService: RdioScannerAdminService) { }
+
+    ngOnInit(): void {
+        this.reload();
+    }
+
+    async reload(): Promise<void> {
+        this.loading = true;
+
+        this.telemetry = await this.adminService.getTelemetry();
+
+        this.loading = false;
+    }
+}
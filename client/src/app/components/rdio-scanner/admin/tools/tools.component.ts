@@ -19,7 +19,7 @@
 
 import { Component, EventEmitter, Output, QueryList, ViewChildren } from '@angular/core';
 import { MatExpansionPanel } from '@angular/material/expansion';
-import { Config } from '../admin.service';
+import { Config, RdioScannerAdminService } from '../admin.service';
 
 @Component({
     selector: 'rdio-scanner-admin-tools',
@@ -30,6 +30,12 @@ export class RdioScannerAdminToolsComponent {
 
     @ViewChildren(MatExpansionPanel) private panels: QueryList<MatExpansionPanel> | undefined;
 
+    get isAdmin(): boolean {
+        return this.adminService.hasRole('admin');
+    }
+
+    constructor(private adminService: RdioScannerAdminService) { }
+
     closeAll(): void {
         this.panels?.forEach((panel) => panel.close());
     }
@@ -0,0 +1,83 @@
+/*
+ * *****************************************************************************
+ * Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>
+ * ****************************************************************************
+ */
+
+import { Component, OnInit } from '@angular/core';
+import { FormBuilder, Validators } from '@angular/forms';
+import { AdminTotp, RdioScannerAdminService } from '../../admin.service';
+
+@Component({
+    selector: 'rdio-scanner-admin-two-factor',
+    styleUrls: ['./two-factor.component.scss'],
+    templateUrl: './two-factor.component.html',
+})
+export class RdioScannerAdminTwoFactorComponent implements OnInit {
+    form = this.ngFormBuilder.group({
+        code: ['', [Validators.required, Validators.pattern(/^\s*\d{6}\s*$/)]],
+    });
+
+    loading = false;
+
+    totp: AdminTotp | undefined;
+
+    constructor(
+        private adminService: RdioScannerAdminService,
+        private ngFormBuilder: FormBuilder,
+    ) { }
+
+    async ngOnInit(): Promise<void> {
+        this.loading = true;
+
+        this.totp = await this.adminService.getTotp();
+
+        this.loading = false;
+    }
+
+    async disable(): Promise<void> {
+        await this.update('disable');
+    }
+
+    async generate(): Promise<void> {
+        await this.update('generate');
+    }
+
+    async verify(): Promise<void> {
+        await this.update('verify');
+    }
+
+    private async update(action: 'disable' | 'generate' | 'verify'): Promise<void> {
+        if (action !== 'generate' && this.form.invalid) {
+            return;
+        }
+
+        this.loading = true;
+
+        const totp = await this.adminService.updateTotp(action, action === 'generate' ? undefined : `${this.form.value.code}`.trim());
+
+        if (totp) {
+            this.totp = totp;
+
+            this.form.reset({ code: '' });
+
+        } else {
+            this.form.get('code')?.setErrors({ invalid: true });
+        }
+
+        this.loading = false;
+    }
+}
@@ -0,0 +1,108 @@
+/*
+ * *****************************************************************************
+ * Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>
+ * ****************************************************************************
+ */
+
+import { Component, OnInit } from '@angular/core';
+import { MatSnackBar } from '@angular/material/snack-bar';
+import { AdminUnitAliases, AdminUnitAliasesResult, RdioScannerAdminService, System } from '../../admin.service';
+
+@Component({
+    selector: 'rdio-scanner-admin-unit-aliases',
+    styleUrls: ['./unit-aliases.component.scss'],
+    templateUrl: './unit-aliases.component.html',
+})
+export class RdioScannerAdminUnitAliasesComponent implements OnInit {
+    aliases: AdminUnitAliases = {
+        dryRun: true,
+        ranges: [],
+        renames: [],
+        retroactive: false,
+        system: 0,
+    };
+
+    loading = false;
+
+    result: AdminUnitAliasesResult | undefined;
+
+    systems: System[] = [];
+
+    get isEditor(): boolean {
+        return this.adminService.hasRole('editor');
+    }
+
+    constructor(
+        private adminService: RdioScannerAdminService,
+        private matSnackBar: MatSnackBar,
+    ) { }
+
+    async ngOnInit(): Promise<void> {
+        const config = await this.adminService.getConfig();
+
+        this.systems = config.systems || [];
+    }
+
+    addRange(): void {
+        this.aliases.ranges.push({ from: 0, label: '', overwrite: false, to: 0 });
+
+        this.result = undefined;
+    }
+
+    addRename(): void {
+        this.aliases.renames.push({ pattern: '', replace: '' });
+
+        this.result = undefined;
+    }
+
+    async apply(): Promise<void> {
+        this.loading = true;
+
+        const result = await this.adminService.applyUnitAliases({ ...this.aliases, dryRun: false });
+
+        if (result) {
+            this.matSnackBar.open(`${result.changes.length} unit labels changed`, '', { duration: 5000 });
+
+            this.result = undefined;
+        }
+
+        this.loading = false;
+    }
+
+    getSystemLabel(id: number): string {
+        return this.systems.find((system) => system.id === id)?.label || `${id}`;
+    }
+
+    async preview(): Promise<void> {
+        this.loading = true;
+
+        this.result = await this.adminService.applyUnitAliases({ ...this.aliases, dryRun: true });
+
+        this.loading = false;
+    }
+
+    removeRange(index: number): void {
+        this.aliases.ranges.splice(index, 1);
+
+        this.result = undefined;
+    }
+
+    removeRename(index: number): void {
+        this.aliases.renames.splice(index, 1);
+
+        this.result = undefined;
+    }
+}
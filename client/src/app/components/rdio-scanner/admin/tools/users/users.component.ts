@@ -0,0 +1,100 @@
+/*
+ * *****************************************************************************
+ * Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>
+ * ****************************************************************************
+ */
+
+import { Component, OnInit } from '@angular/core';
+import { FormBuilder, Validators } from '@angular/forms';
+import { AdminUser, RdioScannerAdminService } from '../../admin.service';
+
+@Component({
+    selector: 'rdio-scanner-admin-users',
+    styleUrls: ['./users.component.scss'],
+    templateUrl: './users.component.html',
+})
+export class RdioScannerAdminUsersComponent implements OnInit {
+    form = this.ngFormBuilder.group({
+        disabled: [false],
+        password: [''],
+        role: ['viewer', Validators.required],
+        username: ['', Validators.required],
+    });
+
+    loading = false;
+
+    users: AdminUser[] = [];
+
+    constructor(
+        private adminService: RdioScannerAdminService,
+        private ngFormBuilder: FormBuilder,
+    ) { }
+
+    ngOnInit(): void {
+        this.reload();
+    }
+
+    edit(user: AdminUser): void {
+        this.form.reset({
+            disabled: !!user.disabled,
+            password: '',
+            role: user.role || 'viewer',
+            username: user.username || '',
+        });
+    }
+
+    async reload(): Promise<void> {
+        this.loading = true;
+
+        this.users = await this.adminService.getUsers() || [];
+
+        this.loading = false;
+    }
+
+    async remove(user: AdminUser): Promise<void> {
+        if (!user.username) {
+            return;
+        }
+
+        this.loading = true;
+
+        this.users = await this.adminService.removeUser(user.username) || this.users;
+
+        this.loading = false;
+    }
+
+    reset(): void {
+        this.form.reset({ disabled: false, password: '', role: 'viewer', username: '' });
+    }
+
+    async save(): Promise<void> {
+        if (this.form.invalid) {
+            return;
+        }
+
+        this.loading = true;
+
+        const users = await this.adminService.saveUser(this.form.getRawValue());
+
+        if (users) {
+            this.users = users;
+
+            this.reset();
+        }
+
+        this.loading = false;
+    }
+}
@@ -0,0 +1,68 @@
+/*
+ * *****************************************************************************
+ * Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>
+ * ****************************************************************************
+ */
+
+import { Component } from '@angular/core';
+import { AdminWatermarkTrace, RdioScannerAdminService } from '../../admin.service';
+
+@Component({
+    selector: 'rdio-scanner-admin-watermark',
+    styleUrls: ['./watermark.component.scss'],
+    templateUrl: './watermark.component.html',
+})
+export class RdioScannerAdminWatermarkComponent {
+    file: string | undefined;
+
+    id: number | undefined;
+
+    loading = false;
+
+    result: AdminWatermarkTrace | undefined;
+
+    constructor(private adminService: RdioScannerAdminService) { }
+
+    async lookup(): Promise<void> {
+        if (typeof this.id !== 'number') return;
+
+        this.loading = true;
+
+        this.file = undefined;
+
+        this.result = await this.adminService.traceWatermark(this.id);
+
+        this.loading = false;
+    }
+
+    async read(event: Event): Promise<void> {
+        const target = (event.target as HTMLInputElement & EventTarget);
+
+        const file = target.files?.item(0);
+
+        target.value = '';
+
+        if (!(file instanceof File)) return;
+
+        this.loading = true;
+
+        this.file = file.name;
+
+        this.result = await this.adminService.traceWatermark(file);
+
+        this.loading = false;
+    }
+}
@@ -84,6 +84,8 @@ export class RdioScannerMainComponent implements OnDestroy, OnInit {
 
     map: RdioScannerLivefeedMap = {};
 
+    oidc = false;
+
     patched = false;
 
     playbackMode = false;
@@ -115,7 +117,15 @@ export class RdioScannerMainComponent implements OnDestroy, OnInit {
         private rdioScannerService: RdioScannerService,
         private ngChangeDetectorRef: ChangeDetectorRef,
         private ngFormBuilder: FormBuilder,
-    ) { }
+    ) {
+        const pin = new URLSearchParams(window.location.hash.replace(/^#/, '')).get('pin');
+
+        if (pin) {
+            window?.localStorage?.setItem(LOCAL_STORAGE_KEY, window.btoa(pin));
+
+            window.history.replaceState(null, '', window.location.pathname + window.location.search);
+        }
+    }
 
     authenticate(password = this.authForm.value.password): void {
         this.authForm.disable();
@@ -203,6 +213,10 @@ export class RdioScannerMainComponent implements OnDestroy, OnInit {
         }
     }
 
+    loginWithOidc(): void {
+        window.location.href = new URL('api/oidc/login?target=listener', window.location.href).toString();
+    }
+
     ngOnDestroy(): void {
         this.clockTimer?.unsubscribe();
 
@@ -211,6 +225,11 @@ export class RdioScannerMainComponent implements OnDestroy, OnInit {
 
     ngOnInit(): void {
         this.syncClock();
+
+        fetch(new URL('api/oidc/', window.location.href).toString())
+            .then((res) => res.json())
+            .then((res) => this.oidc = !!res?.listener)
+            .catch(() => this.oidc = false);
     }
 
     pause(): void {
@@ -279,6 +298,9 @@ export class RdioScannerMainComponent implements OnDestroy, OnInit {
         if (this.auth) {
             this.authFocus();
 
+        } else if (this.config.archive === false) {
+            this.rdioScannerService.beep(RdioScannerBeepStyle.Denied);
+
         } else {
             this.rdioScannerService.beep();
 
@@ -523,7 +545,7 @@ export class RdioScannerMainComponent implements OnDestroy, OnInit {
                 if (typeof source.src === 'number' && Array.isArray(this.call.systemData?.units)) {
                     const callUnit = this.call?.systemData?.units?.find((u) => u.id === source.src);
 
-                    this.callUnit = callUnit ? callUnit.label : `${source.src}`;
+                    this.callUnit = callUnit ? callUnit.label : source.tag || `${source.src}`;
 
                 } else {
                     this.callUnit = typeof this.call.source === 'number' ? `${this.call.source}` : '';
@@ -25,6 +25,7 @@ import { AppUpdateService } from '../../shared/update/update.service';
 import {
     RdioScannerAvoidOptions,
     RdioScannerBeepStyle,
+    RdioScannerBookmarksOptions,
     RdioScannerCall,
     RdioScannerCategory,
     RdioScannerCategoryStatus,
@@ -35,6 +36,7 @@ import {
     RdioScannerLivefeedMode,
     RdioScannerPlaybackList,
     RdioScannerSearchOptions,
+    RdioScannerTranscript,
 } from './rdio-scanner';
 
 declare global {
@@ -49,20 +51,31 @@ enum WebsocketCallFlag {
 }
 
 enum WebsocketCommand {
+    Alternate = 'ALT',
+    Bookmark = 'BMK',
     Call = 'CAL',
     Config = 'CFG',
+    Directory = 'DIR',
     Expired = 'XPR',
+    Histogram = 'HST',
     ListCall = 'LCL',
     ListenersCount = 'LSC',
     LivefeedMap = 'LFM',
     Max = 'MAX',
     Pin = 'PIN',
+    Queue = 'QUE',
+    RelatedCalls = 'RLC',
+    Room = 'ROM',
+    Timeline = 'TML',
+    Transcript = 'TRS',
 }
 
 @Injectable()
 export class RdioScannerService implements OnDestroy {
     static LOCAL_STORAGE_KEY = 'rdio-scanner';
 
+    static WEBSOCKET_FAILURES_BEFORE_FALLBACK = 2;
+
     event = new EventEmitter<RdioScannerEvent>();
 
     private audioContext: AudioContext | undefined;
@@ -103,7 +116,12 @@ export class RdioScannerService implements OnDestroy {
 
     private skipDelay: Subscription | undefined;
 
+    private eventPost: Promise<unknown> = Promise.resolve();
+    private eventSession: string | undefined;
+    private eventSource: EventSource | undefined;
+
     private websocket: WebSocket | undefined;
+    private websocketFailures = 0;
 
     constructor(
         appUpdateService: AppUpdateService,
@@ -341,6 +359,54 @@ export class RdioScannerService implements OnDestroy {
         });
     }
 
+    attachServerQueue(id: string, priorities?: { [systemId: number]: { [talkgroupId: number]: number } }): void {
+        this.sendtoWebsocket(WebsocketCommand.Queue, { action: 'attach', id, priorities });
+    }
+
+    clearServerQueue(): void {
+        this.sendtoWebsocket(WebsocketCommand.Queue, { action: 'clear' });
+    }
+
+    createRoom(name?: string): void {
+        this.sendtoWebsocket(WebsocketCommand.Room, { action: 'create', name });
+    }
+
+    deleteBookmark(id: number): void {
+        this.sendtoWebsocket(WebsocketCommand.Bookmark, { action: 'delete', id });
+    }
+
+    detachServerQueue(): void {
+        this.sendtoWebsocket(WebsocketCommand.Queue, { action: 'detach' });
+    }
+
+    getBookmarks(options: RdioScannerBookmarksOptions = {}): void {
+        this.sendtoWebsocket(WebsocketCommand.Bookmark, { ...options, action: 'list' });
+    }
+
+    getDirectory(): void {
+        this.sendtoWebsocket(WebsocketCommand.Directory);
+    }
+
+    getHistogram(options: RdioScannerSearchOptions): void {
+        this.sendtoWebsocket(WebsocketCommand.Histogram, options);
+    }
+
+    getRelatedCalls(id: number): void {
+        this.sendtoWebsocket(WebsocketCommand.RelatedCalls, id);
+    }
+
+    getTimeline(system: number, talkgroup: number, dateTime: Date, window?: number): void {
+        this.sendtoWebsocket(WebsocketCommand.Timeline, { dateTime, system, talkgroup, window });
+    }
+
+    joinRoom(id: string): void {
+        this.sendtoWebsocket(WebsocketCommand.Room, { action: 'join', id });
+    }
+
+    leaveRoom(): void {
+        this.sendtoWebsocket(WebsocketCommand.Room, { action: 'leave' });
+    }
+
     livefeed(): void {
         if (this.livefeedMode === RdioScannerLivefeedMode.Offline) {
             this.startLivefeed();
@@ -361,6 +427,14 @@ export class RdioScannerService implements OnDestroy {
         this.getCall(id, WebsocketCallFlag.Download);
     }
 
+    loadAndDownloadAlternate(id: number): void {
+        if (!id) {
+            return;
+        }
+
+        this.sendtoWebsocket(WebsocketCommand.Alternate, `${id}`, WebsocketCallFlag.Download);
+    }
+
     loadAndPlay(id: number): void {
         if (!id) {
             return;
@@ -396,7 +470,13 @@ export class RdioScannerService implements OnDestroy {
         this.getCall(id, WebsocketCallFlag.Play);
     }
 
+    nextServerQueued(): void {
+        this.sendtoWebsocket(WebsocketCommand.Queue, { action: 'next' });
+    }
+
     ngOnDestroy(): void {
+        this.closeEventSource();
+
         this.closeWebsocket();
 
         this.stop();
@@ -421,7 +501,7 @@ export class RdioScannerService implements OnDestroy {
         if (this.livefeedPaused || this.skipDelay) {
             return;
 
-        } else if (call?.audio) {
+        } else if (call?.audio || call?.audioUrl) {
             if (this.call) {
                 this.stop({ emit: false });
             }
@@ -435,23 +515,18 @@ export class RdioScannerService implements OnDestroy {
             this.call = this.callQueue.shift();
         }
 
-        if (!this.call?.audio) {
+        if (!this.call?.audio && !this.call?.audioUrl) {
             return;
         }
 
+        const current = this.call;
+
         const queue = this.livefeedMode === RdioScannerLivefeedMode.Playback
             ? this.getPlaybackQueueCount()
             : this.callQueue.length;
 
-        const arrayBuffer = new ArrayBuffer(this.call.audio.data.length);
-        const arrayBufferView = new Uint8Array(arrayBuffer);
-
-        for (let i = 0; i < (this.call.audio.data.length); i++) {
-            arrayBufferView[i] = this.call.audio.data[i];
-        }
-
-        this.audioContext?.decodeAudioData(arrayBuffer, (buffer) => {
-            if (!this.audioContext || this.audioSource || !this.call) {
+        this.getCallAudio(current).then((arrayBuffer) => void this.audioContext?.decodeAudioData(arrayBuffer, (buffer) => {
+            if (!this.audioContext || this.audioSource || this.call !== current) {
                 return;
             }
 
@@ -477,12 +552,20 @@ export class RdioScannerService implements OnDestroy {
         }, () => {
             this.event.emit({ call: this.call, queue });
 
+            this.skip({ delay: false });
+        })).catch(() => {
+            if (this.call !== current) {
+                return;
+            }
+
+            this.event.emit({ call: this.call, queue });
+
             this.skip({ delay: false });
         });
     }
 
     queue(call: RdioScannerCall, options?: { priority?: boolean }): void {
-        if (!call?.audio || this.livefeedMode === RdioScannerLivefeedMode.Offline) {
+        if ((!call?.audio && !call?.audioUrl) || this.livefeedMode === RdioScannerLivefeedMode.Offline) {
             return;
         }
 
@@ -507,6 +590,10 @@ export class RdioScannerService implements OnDestroy {
         this.play(this.call || this.callPrevious);
     }
 
+    saveBookmark(callId: number, note = '', shared = false): void {
+        this.sendtoWebsocket(WebsocketCommand.Bookmark, { action: 'save', callId, note, shared });
+    }
+
     searchCalls(options: RdioScannerSearchOptions): void {
         this.sendtoWebsocket(WebsocketCommand.ListCall, options);
     }
@@ -593,6 +680,10 @@ export class RdioScannerService implements OnDestroy {
         this.stop();
     }
 
+    syncRoom(state: { call?: number | null; livefeed?: RdioScannerLivefeedMap; paused?: boolean; position?: number }): void {
+        this.sendtoWebsocket(WebsocketCommand.Room, { action: 'sync', ...state });
+    }
+
     toggleCategory(category: RdioScannerCategory): void {
         if (category) {
             if (this.livefeedMapPriorToHoldSystem) {
@@ -714,6 +805,17 @@ export class RdioScannerService implements OnDestroy {
         this.callQueue.splice(0, this.callQueue.length);
     }
 
+    private closeEventSource(): void {
+        if (this.eventSource instanceof EventSource) {
+            this.eventSource.onerror = null;
+            this.eventSource.onmessage = null;
+
+            this.eventSource.close();
+
+            this.eventSource = undefined;
+        }
+    }
+
     private closeWebsocket(): void {
         if (this.websocket instanceof WebSocket) {
             this.websocket.onclose = null;
@@ -728,11 +830,12 @@ export class RdioScannerService implements OnDestroy {
     }
 
     private download(call: RdioScannerCall): void {
-        if (call.audio) {
-            const file = call.audio.data.reduce((str, val) => str += String.fromCharCode(val), '');
+        if (call.audio || call.audioUrl) {
             const fileName = call.audioName || 'unknown.dat';
             const fileType = call.audioType || 'audio/*';
-            const fileUri = `data:${fileType};base64,${window.btoa(file)}`;
+            const fileUri = call.audio
+                ? `data:${fileType};base64,${window.btoa(call.audio.data.reduce((str, val) => str += String.fromCharCode(val), ''))}`
+                : `${new URL(call.audioUrl as string, window.location.href).href}&download=true`;
 
             const el = this.document.createElement('a');
 
@@ -753,6 +856,27 @@ export class RdioScannerService implements OnDestroy {
         this.sendtoWebsocket(WebsocketCommand.Call, `${id}`, flags);
     }
 
+    private async getCallAudio(call: RdioScannerCall): Promise<ArrayBuffer> {
+        if (call.audio) {
+            const arrayBuffer = new ArrayBuffer(call.audio.data.length);
+            const arrayBufferView = new Uint8Array(arrayBuffer);
+
+            for (let i = 0; i < (call.audio.data.length); i++) {
+                arrayBufferView[i] = call.audio.data[i];
+            }
+
+            return arrayBuffer;
+        }
+
+        const res = await fetch(new URL(call.audioUrl as string, window.location.href).href);
+
+        if (!res.ok) {
+            throw new Error(`${res.status} ${res.statusText}`);
+        }
+
+        return res.arrayBuffer();
+    }
+
     private getPlaybackQueueCount(id = this.call?.id || this.callPrevious?.id): number {
         let queueCount = 0;
 
@@ -772,27 +896,84 @@ export class RdioScannerService implements OnDestroy {
         return queueCount;
     }
 
+    private openEventSource(): void {
+        const url = new URL('api/events', window.location.href);
+
+        let connected = false;
+
+        if (this.eventSession) {
+            url.searchParams.set('session', this.eventSession);
+        }
+
+        this.eventSource = new EventSource(url.href);
+
+        this.eventSource.addEventListener('session', (ev: Event) => {
+            connected = true;
+
+            try {
+                const session = JSON.parse((ev as MessageEvent).data).session;
+
+                if (session !== this.eventSession) {
+                    this.eventSession = session;
+
+                    this.event.emit({ linked: true });
+
+                    this.sendtoWebsocket(WebsocketCommand.Config, { audioUrl: true });
+                }
+
+            } catch (error) {
+                console.warn(`Invalid session received, ${error}`);
+            }
+        });
+
+        this.eventSource.onerror = () => {
+            this.closeEventSource();
+
+            if (connected) {
+                this.openEventSource();
+
+            } else {
+                this.event.emit({ linked: false });
+
+                timer(2000).subscribe(() => this.openEventSource());
+            }
+        };
+
+        this.eventSource.onmessage = (ev: MessageEvent) => this.parseWebsocketMessage(ev.data);
+    }
+
     private openWebsocket(): void {
         const websocketUrl = window.location.href.replace(/^http/, 'ws');
 
+        let opened = false;
+
         this.websocket = new WebSocket(websocketUrl);
 
         this.websocket.onclose = (ev: CloseEvent) => {
             this.event.emit({ linked: false });
 
-            if (ev.code !== 1000) {
+            if (!opened && ++this.websocketFailures >= RdioScannerService.WEBSOCKET_FAILURES_BEFORE_FALLBACK && typeof EventSource !== 'undefined') {
+                this.closeWebsocket();
+
+                this.openEventSource();
+
+            } else if (ev.code !== 1000) {
                 timer(2000).subscribe(() => this.reconnectWebsocket());
             }
         };
 
         this.websocket.onopen = () => {
+            opened = true;
+
+            this.websocketFailures = 0;
+
             this.event.emit({ linked: true });
 
             if (this.websocket instanceof WebSocket) {
                 this.websocket.onmessage = (ev: MessageEvent) => this.parseWebsocketMessage(ev.data);
             }
 
-            this.sendtoWebsocket(WebsocketCommand.Config);
+            this.sendtoWebsocket(WebsocketCommand.Config, { audioUrl: true });
         };
     }
 
@@ -806,6 +987,13 @@ export class RdioScannerService implements OnDestroy {
 
         if (Array.isArray(message)) {
             switch (message[0]) {
+                case WebsocketCommand.Bookmark:
+                    if (message[1] && typeof message[1].action === 'string') {
+                        this.event.emit({ bookmark: message[1] });
+                    }
+
+                    break;
+
                 case WebsocketCommand.Call:
                     if (message[1] !== null) {
                         let call: RdioScannerCall = message[1];
@@ -830,11 +1018,16 @@ export class RdioScannerService implements OnDestroy {
                     const config = message[1];
 
                     this.config = {
+                        archive: typeof config.archive === 'boolean' ? config.archive : true,
                         dimmerDelay: typeof config.dimmerDelay === 'number' ? config.dimmerDelay : 5000,
+                        directory: typeof config.directory === 'boolean' ? config.directory : false,
                         groups: typeof config.groups !== null && typeof config.groups === 'object' ? config.groups : {},
                         keypadBeeps: config.keypadBeeps !== null && typeof config.keypadBeeps === 'object' ? config.keypadBeeps : {},
+                        listeningRooms: typeof config.listeningRooms === 'boolean' ? config.listeningRooms : false,
                         playbackGoesLive: typeof config.playbackGoesLive === 'boolean' ? config.playbackGoesLive : false,
+                        serverQueue: typeof config.serverQueue === 'boolean' ? config.serverQueue : false,
                         showListenersCount: typeof config.showListenersCount === 'boolean' ? config.showListenersCount : false,
+                        streamOnly: typeof config.streamOnly === 'boolean' ? config.streamOnly : false,
                         systems: Array.isArray(config.systems) ? config.systems.slice() : [],
                         tags: typeof config.tags !== null && typeof config.tags === 'object' ? config.tags : {},
                         tagsToggle: typeof config.tagsToggle === 'boolean' ? config.tagsToggle : false,
@@ -844,6 +1037,10 @@ export class RdioScannerService implements OnDestroy {
                         this.config['afs'] = config.afs;
                     }
 
+                    if (typeof config.archiveHours === 'number' && config.archiveHours > 0) {
+                        this.config['archiveHours'] = config.archiveHours;
+                    }
+
                     this.rebuildLivefeedMap();
 
                     if (this.livefeedMode === RdioScannerLivefeedMode.Online) {
@@ -862,11 +1059,21 @@ export class RdioScannerService implements OnDestroy {
                     break;
                 }
 
+                case WebsocketCommand.Directory:
+                    this.event.emit({ directory: Array.isArray(message[1]) ? message[1] : [] });
+
+                    break;
+
                 case WebsocketCommand.Expired:
                     this.event.emit({ auth: true, expired: true });
 
                     break;
 
+                case WebsocketCommand.Histogram:
+                    this.event.emit({ histogram: message[1] });
+
+                    break;
+
                 case WebsocketCommand.ListCall:
                     this.playbackList = message[1];
 
@@ -895,6 +1102,50 @@ export class RdioScannerService implements OnDestroy {
                 case WebsocketCommand.Pin:
                     this.event.emit({ auth: true });
 
+                    break;
+
+                case WebsocketCommand.Queue:
+                    this.event.emit({ serverQueue: message[1] || undefined });
+
+                    break;
+
+                case WebsocketCommand.RelatedCalls:
+                    if (message[1] && Array.isArray(message[1].results)) {
+                        this.event.emit({
+                            relatedCalls: {
+                                id: message[1].id,
+                                results: message[1].results.map((call: RdioScannerCall) => this.transformCall(call)),
+                            },
+                        });
+                    }
+
+                    break;
+
+                case WebsocketCommand.Room:
+                    this.event.emit({ room: message[1] || {} });
+
+                    break;
+
+                case WebsocketCommand.Timeline:
+                    if (message[1] && Array.isArray(message[1].results)) {
+                        this.event.emit({ timeline: message[1] });
+                    }
+
+                    break;
+
+                case WebsocketCommand.Transcript:
+                    if (message[1] && typeof message[1].id === 'number' && typeof message[1].transcript === 'string') {
+                        const transcript: RdioScannerTranscript = message[1];
+
+                        [this.call, this.callPrevious, ...this.callQueue].forEach((call) => {
+                            if (call && call.id === transcript.id) {
+                                call.transcript = transcript.transcript;
+                            }
+                        });
+
+                        this.event.emit({ transcript });
+                    }
+
                     break;
             }
         }
@@ -1043,18 +1294,31 @@ export class RdioScannerService implements OnDestroy {
     }
 
     private sendtoWebsocket(command: string, payload?: unknown, flags?: string): void {
-        if (this.websocket?.readyState === 1) {
-            const message: unknown[] = [command];
+        const message: unknown[] = [command];
 
-            if (payload) {
-                message.push(payload);
-            }
+        if (payload) {
+            message.push(payload);
+        }
 
-            if (flags !== null && flags !== undefined) {
-                message.push(flags);
-            }
+        if (flags !== null && flags !== undefined) {
+            message.push(flags);
+        }
 
+        if (this.websocket?.readyState === 1) {
             this.websocket.send(JSON.stringify(message));
+
+        } else if (this.eventSource && this.eventSession) {
+            const url = new URL('api/events', window.location.href);
+
+            url.searchParams.set('session', this.eventSession);
+
+            this.eventPost = this.eventPost
+                .then(() => fetch(url.href, {
+                    body: JSON.stringify(message),
+                    headers: { 'Content-Type': 'application/json' },
+                    method: 'POST',
+                }))
+                .catch(() => undefined);
         }
     }
 
@@ -38,24 +38,88 @@ export enum RdioScannerBeepStyle {
     Denied = 'denied',
 }
 
+export interface RdioScannerBookmark {
+    _id: number;
+    callDateTime: string;
+    callId: number;
+    dateTime: string;
+    note: string;
+    owner: string;
+    shared: boolean;
+    system: number;
+    systemLabel?: string;
+    talkgroup: number;
+    talkgroupLabel?: string;
+    updated: string;
+}
+
+export interface RdioScannerBookmarkEvent {
+    action: 'delete' | 'list' | 'save';
+    bookmark?: RdioScannerBookmark;
+    error?: string;
+    id?: number;
+    results?: { bookmarks: RdioScannerBookmark[]; count: number; };
+}
+
+export interface RdioScannerBookmarksOptions {
+    callId?: number;
+    limit?: number;
+    mine?: boolean;
+    offset?: number;
+    q?: string;
+    system?: number;
+    talkgroup?: number;
+}
+
 export interface RdioScannerCall {
+    alternate?: number;
+    alternates?: RdioScannerCallAlternate[];
     audio?: {
         type: 'Buffer';
         data: number[];
     };
     audioName?: string;
     audioType?: string;
+    audioUrl?: string;
     dateTime: Date;
+    duration?: number;
+    events?: RdioScannerCallEvent[];
     frequencies?: RdioScannerCallFrequency[];
     frequency?: number;
     id: number;
+    patchMembers?: RdioScannerCallPatchMember[];
     patches: number[];
+    serverTiming?: string;
+    site?: string;
     source?: number;
     sources?: RdioScannerCallSource[];
     system: number;
     talkgroup: number;
     talkgroupData?: RdioScannerTalkgroup;
     systemData?: RdioScannerSystem;
+    transcript?: string;
+    transcriptConfidence?: number;
+}
+
+export interface RdioScannerCallAlternate {
+    audioName?: string;
+    dateTime?: string;
+    id: number;
+    score: number;
+    site?: string;
+}
+
+export interface RdioScannerCallEvent {
+    emergency?: boolean;
+    errorCount?: number;
+    frequency?: number;
+    length: number;
+    offset: number;
+    patches?: number[];
+    source?: number;
+    spikeCount?: number;
+    tag?: string;
+    type: 'frequency' | 'patch' | 'source';
 }
 
 export interface RdioScannerCallFrequency {
@@ -66,9 +130,17 @@ export interface RdioScannerCallFrequency {
     spikeCount?: number;
 }
 
+export interface RdioScannerCallPatchMember {
+    id: number;
+    label?: string;
+    name?: string;
+}
+
 export interface RdioScannerCallSource {
+    emergency?: boolean;
     pos?: number;
     src?: number;
+    tag?: string;
 }
 
 export interface RdioScannerCategory {
@@ -90,22 +162,39 @@ export enum RdioScannerCategoryType {
 
 export interface RdioScannerConfig {
     afs?: string;
+    archive?: boolean;
+    archiveHours?: number;
     dimmerDelay: number | false;
+    directory?: boolean;
     groups: { [key: string]: { [key: number]: number[] } };
     keypadBeeps: RdioScannerKeypadBeeps | false;
+    listeningRooms?: boolean;
     playbackGoesLive: boolean;
+    serverQueue?: boolean;
     showListenersCount: boolean;
+    streamOnly?: boolean;
     systems: RdioScannerSystem[];
     tags: { [key: string]: { [key: number]: number[] } };
     tagsToggle: boolean;
 }
 
+export interface RdioScannerDirectoryEntry {
+    location?: string;
+    name: string;
+    systems?: { id: number; label: string }[];
+    url: string;
+    version?: string;
+}
+
 export interface RdioScannerEvent {
     auth?: boolean;
+    bookmark?: RdioScannerBookmarkEvent;
     categories?: RdioScannerCategory[];
     call?: RdioScannerCall;
     config?: RdioScannerConfig;
+    directory?: RdioScannerDirectoryEntry[];
     expired?: boolean;
+    histogram?: RdioScannerHistogram;
     holdSys?: boolean;
     holdTg?: boolean;
     linked?: boolean;
@@ -116,8 +205,19 @@ export interface RdioScannerEvent {
     playbackList?: RdioScannerPlaybackList;
     playbackPending?: number;
     queue?: number;
+    relatedCalls?: RdioScannerRelatedCalls;
+    room?: RdioScannerRoom;
+    serverQueue?: RdioScannerServerQueue;
     time?: number;
+    timeline?: RdioScannerTimeline;
     tooMany?: boolean;
+    transcript?: RdioScannerTranscript;
+}
+
+export interface RdioScannerHistogram {
+    days: RdioScannerSearchFacet[];
+    hours?: RdioScannerSearchFacet[];
+    options: RdioScannerSearchOptions;
 }
 
 export interface RdioScannerKeypadBeeps {
@@ -142,19 +242,63 @@ export interface RdioScannerPlaybackList {
     count: number;
     dateStart: Date;
     dateStop: Date;
+    facets?: RdioScannerSearchFacets;
     options: RdioScannerSearchOptions;
     results: RdioScannerCall[];
+    serverTiming?: string;
+}
+
+export interface RdioScannerRelatedCalls {
+    id: number;
+    results: RdioScannerCall[];
+}
+
+export interface RdioScannerRoom {
+    call?: number;
+    error?: string;
+    id?: string;
+    livefeed?: RdioScannerLivefeedMap;
+    members?: number;
+    name?: string;
+    paused?: boolean;
+    position?: number;
+    role?: 'follower' | 'leader';
+}
+
+export interface RdioScannerSearchFacet {
+    count: number;
+    date?: string;
+    system?: number;
+    tag?: string;
+    talkgroup?: number;
+}
+
+export interface RdioScannerSearchFacets {
+    days: RdioScannerSearchFacet[];
+    systems: RdioScannerSearchFacet[];
+    tags: RdioScannerSearchFacet[];
+    talkgroups: RdioScannerSearchFacet[];
 }
 
 export interface RdioScannerSearchOptions {
     date?: Date;
+    dateFrom?: Date;
+    dateTo?: Date;
+    facets?: boolean;
     group?: string;
+    hours?: boolean;
     limit: number;
     offset: number;
     sort: number;
     system?: number;
     tag?: string;
     talkgroup?: number;
+    transcript?: string;
+}
+
+export interface RdioScannerServerQueue {
+    depth: number;
+    maxDepth: number;
 }
 
 export interface RdioScannerSystem {
@@ -176,6 +320,31 @@ export interface RdioScannerTalkgroup {
     tag: string;
 }
 
+export interface RdioScannerTimeline {
+    dateTime: Date;
+    duration: number;
+    next?: Date;
+    prefetch: number[];
+    previous?: Date;
+    results: RdioScannerTimelineEntry[];
+    system: number;
+    talkgroup: number;
+}
+
+export interface RdioScannerTimelineEntry {
+    dateTime: Date;
+    duration: number;
+    events: RdioScannerCallEvent[];
+    id: number;
+    offset: number;
+    position: number;
+}
+
+export interface RdioScannerTranscript {
+    id: number;
+    transcript: string;
+}
+
 export interface RdioScannerUnit {
     id: number;
     label: string;
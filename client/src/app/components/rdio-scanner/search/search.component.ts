@@ -17,11 +17,13 @@
  * ****************************************************************************
  */
 
-import { ChangeDetectorRef, Component, OnDestroy, OnInit, ViewChild } from '@angular/core';
+import { DOCUMENT } from '@angular/common';
+import { ChangeDetectorRef, Component, Inject, OnDestroy, OnInit, ViewChild } from '@angular/core';
 import { FormBuilder } from '@angular/forms';
 import { MatPaginator } from '@angular/material/paginator';
 import { BehaviorSubject } from 'rxjs';
 import {
+    RdioScannerBookmark,
     RdioScannerCall,
     RdioScannerConfig,
     RdioScannerEvent,
@@ -39,9 +41,22 @@ import { RdioScannerService } from '../rdio-scanner.service';
     templateUrl: './search.component.html',
 })
 export class RdioScannerSearchComponent implements OnDestroy, OnInit {
+    bookmarks: { [callId: number]: RdioScannerBookmark } = {};
+    bookmarksEnabled = false;
+
     call: RdioScannerCall | undefined;
     callPending: number | undefined;
 
+    get columns(): string[] {
+        const columns = ['control', 'date', 'time', 'system', 'alpha', 'name'];
+
+        return this.bookmarksEnabled ? [...columns, 'bookmark'] : columns;
+    }
+
+    get streamOnly(): boolean {
+        return this.config?.streamOnly || false;
+    }
+
     form = this.ngFormBuilder.group({
         date: [null],
         group: [-1],
@@ -49,6 +64,7 @@ export class RdioScannerSearchComponent implements OnDestroy, OnInit {
         system: [-1],
         tag: [-1],
         talkgroup: [-1],
+        transcript: [''],
     });
 
     livefeedOnline = false;
@@ -77,6 +93,7 @@ export class RdioScannerSearchComponent implements OnDestroy, OnInit {
     @ViewChild(MatPaginator, { read: MatPaginator }) private paginator: MatPaginator | undefined;
 
     constructor(
+        @Inject(DOCUMENT) private document: Document,
         private rdioScannerService: RdioScannerService,
         private ngChangeDetectorRef: ChangeDetectorRef,
         private ngFormBuilder: FormBuilder,
@@ -211,6 +228,7 @@ export class RdioScannerSearchComponent implements OnDestroy, OnInit {
             system: -1,
             tag: -1,
             talkgroup: -1,
+            transcript: '',
         });
 
         this.paginator?.firstPage();
@@ -271,6 +289,10 @@ export class RdioScannerSearchComponent implements OnDestroy, OnInit {
             }
         }
 
+        if (typeof this.form.value.transcript === 'string' && this.form.value.transcript.trim()) {
+            options.transcript = this.form.value.transcript.trim();
+        }
+
         this.resultsPending = true;
 
         this.form.disable();
@@ -287,7 +309,48 @@ export class RdioScannerSearchComponent implements OnDestroy, OnInit {
         }
     }
 
+    toggleBookmark(id: number): void {
+        const bookmark = this.bookmarks[id];
+
+        if (bookmark) {
+            this.rdioScannerService.deleteBookmark(bookmark._id);
+
+        } else {
+            const note = this.document.defaultView?.prompt('Bookmark note (optional)', '');
+
+            if (typeof note === 'string') {
+                this.rdioScannerService.saveBookmark(id, note.trim());
+            }
+        }
+    }
+
     private eventHandler(event: RdioScannerEvent): void {
+        if (event.bookmark) {
+            const bookmark = event.bookmark;
+
+            switch (bookmark.action) {
+                case 'delete':
+                    Object.values(this.bookmarks)
+                        .filter((b) => b._id === bookmark.id)
+                        .forEach((b) => delete this.bookmarks[b.callId]);
+                    break;
+
+                case 'list':
+                    this.bookmarksEnabled = !bookmark.error;
+                    this.bookmarks = (bookmark.results?.bookmarks || []).reduce((bookmarks, b) => {
+                        bookmarks[b.callId] = b;
+                        return bookmarks;
+                    }, {} as { [callId: number]: RdioScannerBookmark });
+                    break;
+
+                case 'save':
+                    if (bookmark.bookmark) {
+                        this.bookmarks[bookmark.bookmark.callId] = bookmark.bookmark;
+                    }
+                    break;
+            }
+        }
+
         if ('call' in event) {
             this.call = event.call;
 
@@ -315,6 +378,8 @@ export class RdioScannerSearchComponent implements OnDestroy, OnInit {
             this.optionsGroup = Object.keys(this.config?.groups || []).sort((a, b) => a.localeCompare(b));
             this.optionsSystem = (this.config?.systems || []).map((system) => system.label);
             this.optionsTag = Object.keys(this.config?.tags || []).sort((a, b) => a.localeCompare(b));
+
+            this.rdioScannerService.getBookmarks({ limit: 500, mine: true });
         }
 
         if ('livefeedMode' in event) {
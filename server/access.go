@@ -19,19 +19,56 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 	"sync"
 	"time"
 )
 
+const (
+	AccessArchiveFull        = "full"
+	AccessArchiveLimited     = "limited"
+	AccessArchiveNone        = "none"
+	AccessLiveWindow         = 15 * time.Minute
+	AccessWatermarkIdent     = "ident"
+	AccessWatermarkInaudible = "inaudible"
+)
+
 type Access struct {
-	Id         interface{} `json:"_id"`
-	Code       string      `json:"code"`
-	Expiration interface{} `json:"expiration"`
-	Ident      string      `json:"ident"`
-	Limit      interface{} `json:"limit"`
-	Order      interface{} `json:"order"`
-	Systems    interface{} `json:"systems"`
+	Id           interface{}    `json:"_id"`
+	Archive      string         `json:"archive"`
+	ArchiveHours uint           `json:"archiveHours"`
+	Code         string         `json:"code"`
+	Deny         interface{}    `json:"deny"`
+	Expiration   interface{}    `json:"expiration"`
+	Hours        uint           `json:"hours"`
+	Ident        string         `json:"ident"`
+	Limit        interface{}    `json:"limit"`
+	Order        interface{}    `json:"order"`
+	Schedule     []AccessWindow `json:"schedule"`
+	StreamOnly   bool           `json:"streamOnly"`
+	Systems      interface{}    `json:"systems"`
+	Watermark    string         `json:"watermark"`
+	listened     uint64
+}
+
+type AccessQuota struct {
+	Id          interface{} `json:"_id"`
+	Code        string      `json:"code"`
+	Ident       string      `json:"ident"`
+	Connections uint        `json:"connections"`
+	Limit       interface{} `json:"limit"`
+	Expiration  interface{} `json:"expiration"`
+	Expired     bool        `json:"expired"`
+	Hours       uint        `json:"hours"`
+	Listened    uint64      `json:"listened"`
+	Remaining   interface{} `json:"remaining"`
+}
+
+type AccessWindow struct {
+	Days []uint `json:"days"`
+	From string `json:"from"`
+	To   string `json:"to"`
 }
 
 func NewAccess() *Access {
@@ -44,11 +81,32 @@ func (access *Access) FromMap(m map[string]interface{}) *Access {
 		access.Id = uint(v)
 	}
 
+	switch v := m["archive"].(type) {
+	case string:
+		access.Archive = v
+	}
+
+	switch v := m["archiveHours"].(type) {
+	case float64:
+		access.ArchiveHours = uint(v)
+	}
+
 	switch v := m["code"].(type) {
 	case string:
 		access.Code = v
 	}
 
+	switch v := m["deny"].(type) {
+	case []interface{}:
+		if len(v) > 0 {
+			access.Deny = v
+		}
+	case string:
+		if v == "*" {
+			access.Deny = v
+		}
+	}
+
 	switch v := m["expiration"].(type) {
 	case string:
 		if t, err := time.Parse(time.RFC3339, v); err == nil {
@@ -56,6 +114,11 @@ func (access *Access) FromMap(m map[string]interface{}) *Access {
 		}
 	}
 
+	switch v := m["hours"].(type) {
+	case float64:
+		access.Hours = uint(v)
+	}
+
 	switch v := m["ident"].(type) {
 	case string:
 		access.Ident = v
@@ -71,6 +134,19 @@ func (access *Access) FromMap(m map[string]interface{}) *Access {
 		access.Order = uint(v)
 	}
 
+	switch v := m["schedule"].(type) {
+	case []interface{}:
+		access.Schedule = []AccessWindow{}
+		if b, err := json.Marshal(v); err == nil {
+			json.Unmarshal(b, &access.Schedule)
+		}
+	}
+
+	switch v := m["streamOnly"].(type) {
+	case bool:
+		access.StreamOnly = v
+	}
+
 	switch v := m["systems"].(type) {
 	case []interface{}:
 		if b, err := json.Marshal(v); err == nil {
@@ -80,47 +156,51 @@ func (access *Access) FromMap(m map[string]interface{}) *Access {
 		access.Systems = v
 	}
 
+	switch v := m["watermark"].(type) {
+	case string:
+		access.Watermark = v
+	}
+
 	return access
 }
 
-func (access *Access) HasAccess(call *Call) bool {
-	if access.Systems != nil {
-		switch v := access.Systems.(type) {
-		case []interface{}:
-			for _, f := range v {
-				switch v := f.(type) {
-				case map[string]interface{}:
-					switch id := v["id"].(type) {
-					case float64:
-						if id == float64(call.System) {
-							switch tg := v["talkgroups"].(type) {
-							case string:
-								if tg == "*" {
-									return true
-								}
-							case []interface{}:
-								for _, f := range tg {
-									switch tg := f.(type) {
-									case float64:
-										if tg == float64(call.Talkgroup) {
-											return true
-										}
-									}
-								}
-							}
-						}
-					}
-				}
-			}
+func (access *Access) CanBrowseArchive() bool {
+	return access == nil || access.Archive != AccessArchiveNone
+}
 
-		case string:
-			if v == "*" {
-				return true
-			}
+func (access *Access) GetArchiveStart() (time.Time, bool) {
+	if access == nil {
+		return time.Time{}, false
+	}
+
+	switch access.Archive {
+	case AccessArchiveLimited:
+		if access.ArchiveHours > 0 {
+			return time.Now().Add(-time.Duration(access.ArchiveHours) * time.Hour), true
 		}
+		return time.Now().Add(-AccessLiveWindow), true
+	case AccessArchiveNone:
+		return time.Now().Add(-AccessLiveWindow), true
 	}
 
-	return false
+	return time.Time{}, false
+}
+
+func (access *Access) HasArchiveAccess(t time.Time) bool {
+	if start, ok := access.GetArchiveStart(); ok {
+		return !t.Before(start)
+	}
+
+	return true
+}
+
+func (access *Access) HasAccess(call *Call) bool {
+	return hasAccessScope(access.Systems, call) && !access.IsDenied(call) && access.IsInSchedule(time.Now())
+}
+
+func (access *Access) HasExceededHours(active time.Duration) bool {
+	remaining, ok := access.GetRemainingSeconds(active)
+	return ok && remaining == 0
 }
 
 func (access *Access) HasExpired() bool {
@@ -131,9 +211,121 @@ func (access *Access) HasExpired() bool {
 	return false
 }
 
+func (access *Access) IsDenied(call *Call) bool {
+	return access.Deny != nil && hasAccessScope(access.Deny, call)
+}
+
+func (access *Access) IsInSchedule(t time.Time) bool {
+	if len(access.Schedule) == 0 {
+		return true
+	}
+
+	parseTime := func(s string) (int, bool) {
+		var h, m int
+		if _, err := fmt.Sscanf(s, "%d:%d", &h, &m); err != nil || h < 0 || h > 24 || m < 0 || m > 59 {
+			return 0, false
+		}
+		return h*60 + m, true
+	}
+
+	isDay := func(w AccessWindow, d time.Weekday) bool {
+		if len(w.Days) == 0 {
+			return true
+		}
+		for _, day := range w.Days {
+			if day == uint(d) {
+				return true
+			}
+		}
+		return false
+	}
+
+	now := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+
+	for _, w := range access.Schedule {
+		from, ok := parseTime(w.From)
+		if !ok {
+			continue
+		}
+
+		to, ok := parseTime(w.To)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case from == to:
+			if isDay(w, today) {
+				return true
+			}
+
+		case from < to:
+			if isDay(w, today) && now >= from && now < to {
+				return true
+			}
+
+		default:
+			if (isDay(w, today) && now >= from) || (isDay(w, yesterday) && now < to) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (access *Access) IsSame(other *Access) bool {
+	if access == nil || other == nil {
+		return access == other
+	}
+
+	if access.Id != nil && other.Id != nil {
+		return access.Id == other.Id
+	}
+
+	return access == other
+}
+
+func (admin *Admin) AccessesHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		quotas := admin.Controller.Accesses.GetQuotas(admin.Controller.Clients)
+
+		if _, ok := admin.ValidateTokenRole(t, AdminRoleEditor); !ok {
+			for i := range quotas {
+				if len(quotas[i].Code) > 0 {
+					quotas[i].Code = AdminSecretMask
+				}
+			}
+		}
+
+		if b, err := json.Marshal(map[string]interface{}{"accesses": quotas}); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.accesseshandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 type Accesses struct {
-	List  []*Access
-	mutex sync.Mutex
+	List    []*Access
+	mutex   sync.Mutex
+	oidc    *Oidc
+	webhook *AuthWebhook
 }
 
 func NewAccesses() *Accesses {
@@ -151,10 +343,17 @@ func (accesses *Accesses) Add(access *Access) (*Accesses, bool) {
 
 	for _, a := range accesses.List {
 		if a.Code == access.Code {
+			a.Archive = access.Archive
+			a.ArchiveHours = access.ArchiveHours
+			a.Deny = access.Deny
 			a.Expiration = access.Expiration
+			a.Hours = access.Hours
 			a.Ident = access.Ident
 			a.Limit = access.Limit
+			a.Schedule = access.Schedule
+			a.StreamOnly = access.StreamOnly
 			a.Systems = access.Systems
+			a.Watermark = access.Watermark
 			added = false
 		}
 	}
@@ -166,6 +365,33 @@ func (accesses *Accesses) Add(access *Access) (*Accesses, bool) {
 	return accesses, added
 }
 
+func (accesses *Accesses) AddListened(db *Database, access *Access, listened time.Duration) error {
+	id, ok := access.Id.(uint)
+	if !ok || listened < time.Second {
+		return nil
+	}
+
+	seconds := uint64(listened.Seconds())
+
+	accesses.mutex.Lock()
+	for _, a := range accesses.List {
+		if a.Id == access.Id {
+			a.listened += seconds
+		}
+	}
+	accesses.mutex.Unlock()
+
+	if db.Config.ReadOnly {
+		return nil
+	}
+
+	if _, err := db.Sql.Exec("update `rdioScannerAccesses` set `listened` = `listened` + ? where `_id` = ?", seconds, id); err != nil {
+		return fmt.Errorf("accesses.addlistened: %v", err)
+	}
+
+	return nil
+}
+
 func (accesses *Accesses) FromMap(f []interface{}) *Accesses {
 	accesses.mutex.Lock()
 	defer accesses.mutex.Unlock()
@@ -197,23 +423,76 @@ func (accesses *Accesses) GetAccess(code string) (access *Access, ok bool) {
 	return nil, false
 }
 
+func (accesses *Accesses) GetExternalAccess(code string, address string) (access *Access, ok bool, err error) {
+	if accesses.oidc != nil {
+		if access, ok = accesses.oidc.GetAccess(code); ok {
+			return access, true, nil
+		}
+	}
+
+	if accesses.webhook == nil || !accesses.webhook.IsEnabled() {
+		return nil, false, nil
+	}
+
+	return accesses.webhook.Authenticate(code, address)
+}
+
+func (accesses *Accesses) GetQuotas(clients *Clients) []AccessQuota {
+	accesses.mutex.Lock()
+	defer accesses.mutex.Unlock()
+
+	quotas := []AccessQuota{}
+
+	for _, access := range accesses.List {
+		connections, active := clients.AccessUsage(access)
+
+		quota := AccessQuota{
+			Id:          access.Id,
+			Code:        access.Code,
+			Ident:       access.Ident,
+			Connections: uint(connections),
+			Limit:       access.Limit,
+			Expiration:  access.Expiration,
+			Expired:     access.HasExpired(),
+			Hours:       access.Hours,
+			Listened:    access.listened + uint64(active.Seconds()),
+		}
+
+		if remaining, ok := access.GetRemainingSeconds(active); ok {
+			quota.Remaining = remaining
+		}
+
+		quotas = append(quotas, quota)
+	}
+
+	return quotas
+}
+
 func (accesses *Accesses) IsRestricted() bool {
 	accesses.mutex.Lock()
 	defer accesses.mutex.Unlock()
 
-	return len(accesses.List) > 0
+	return len(accesses.List) > 0 || (accesses.oidc != nil && accesses.oidc.IsListenerEnabled()) || (accesses.webhook != nil && accesses.webhook.IsEnabled())
 }
 
 func (accesses *Accesses) Read(db *Database) error {
 	var (
-		err        error
-		expiration interface{}
-		id         sql.NullFloat64
-		limit      sql.NullFloat64
-		order      sql.NullFloat64
-		rows       *sql.Rows
-		systems    string
-		t          time.Time
+		archive      sql.NullString
+		archiveHours sql.NullFloat64
+		deny         sql.NullString
+		err          error
+		expiration   interface{}
+		hours        sql.NullFloat64
+		id           sql.NullFloat64
+		limit        sql.NullFloat64
+		listened     sql.NullFloat64
+		order        sql.NullFloat64
+		rows         *sql.Rows
+		schedule     sql.NullString
+		streamOnly   sql.NullBool
+		systems      string
+		t            time.Time
+		watermark    sql.NullString
 	)
 
 	accesses.mutex.Lock()
@@ -225,14 +504,14 @@ func (accesses *Accesses) Read(db *Database) error {
 		return fmt.Errorf("accesses.read: %v", err)
 	}
 
-	if rows, err = db.Sql.Query("select `_id`, `code`, `expiration`, `ident`, `limit`, `order`, `systems` from `rdioScannerAccesses`"); err != nil {
+	if rows, err = db.Sql.Query("select `_id`, `archive`, `archiveHours`, `code`, `deny`, `expiration`, `hours`, `ident`, `limit`, `listened`, `order`, `schedule`, `streamOnly`, `systems`, `watermark` from `rdioScannerAccesses`"); err != nil {
 		return formatError(err)
 	}
 
 	for rows.Next() {
 		access := &Access{}
 
-		if err = rows.Scan(&id, &access.Code, &expiration, &access.Ident, &limit, &order, &systems); err != nil {
+		if err = rows.Scan(&id, &archive, &archiveHours, &access.Code, &deny, &expiration, &hours, &access.Ident, &limit, &listened, &order, &schedule, &streamOnly, &systems, &watermark); err != nil {
 			break
 		}
 
@@ -244,10 +523,28 @@ func (accesses *Accesses) Read(db *Database) error {
 			continue
 		}
 
+		if archive.Valid {
+			access.Archive = archive.String
+		}
+
+		if archiveHours.Valid && archiveHours.Float64 > 0 {
+			access.ArchiveHours = uint(archiveHours.Float64)
+		}
+
+		if deny.Valid && len(deny.String) > 0 {
+			if err := json.Unmarshal([]byte(deny.String), &access.Deny); err != nil {
+				access.Deny = nil
+			}
+		}
+
 		if t, err = db.ParseDateTime(expiration); err == nil {
 			access.Expiration = t
 		}
 
+		if hours.Valid && hours.Float64 > 0 {
+			access.Hours = uint(hours.Float64)
+		}
+
 		if len(access.Ident) == 0 {
 			access.Ident = defaults.access.ident
 		}
@@ -256,14 +553,32 @@ func (accesses *Accesses) Read(db *Database) error {
 			access.Limit = uint(limit.Float64)
 		}
 
+		if listened.Valid && listened.Float64 > 0 {
+			access.listened = uint64(listened.Float64)
+		}
+
 		if order.Valid && order.Float64 > 0 {
 			access.Order = uint(order.Float64)
 		}
 
+		if schedule.Valid && len(schedule.String) > 0 {
+			if err := json.Unmarshal([]byte(schedule.String), &access.Schedule); err != nil {
+				access.Schedule = nil
+			}
+		}
+
+		if streamOnly.Valid {
+			access.StreamOnly = streamOnly.Bool
+		}
+
 		if err = json.Unmarshal([]byte(systems), &access.Systems); err != nil {
 			access.Systems = []interface{}{}
 		}
 
+		if watermark.Valid {
+			access.Watermark = watermark.String
+		}
+
 		accesses.List = append(accesses.List, access)
 	}
 
@@ -292,13 +607,23 @@ func (accesses *Accesses) Remove(access *Access) (*Accesses, bool) {
 	return accesses, removed
 }
 
+func (accesses *Accesses) setAuthWebhook(webhook *AuthWebhook) {
+	accesses.webhook = webhook
+}
+
+func (accesses *Accesses) setOidc(oidc *Oidc) {
+	accesses.oidc = oidc
+}
+
 func (accesses *Accesses) Write(db *Database) error {
 	var (
-		count   uint
-		err     error
-		rows    *sql.Rows
-		rowIds  = []uint{}
-		systems interface{}
+		count    uint
+		deny     interface{}
+		err      error
+		rows     *sql.Rows
+		rowIds   = []uint{}
+		schedule interface{}
+		systems  interface{}
 	)
 
 	accesses.mutex.Lock()
@@ -316,16 +641,30 @@ func (accesses *Accesses) Write(db *Database) error {
 			systems = access.Systems
 		}
 
+		deny = nil
+		if access.Deny != nil {
+			if b, err := json.Marshal(access.Deny); err == nil {
+				deny = string(b)
+			}
+		}
+
+		schedule = nil
+		if len(access.Schedule) > 0 {
+			if b, err := json.Marshal(access.Schedule); err == nil {
+				schedule = string(b)
+			}
+		}
+
 		if err = db.Sql.QueryRow("select count(*) from `rdioScannerAccesses` where `_id` = ?", access.Id).Scan(&count); err != nil {
 			break
 		}
 
 		if count == 0 {
-			if _, err = db.Sql.Exec("insert into `rdioScannerAccesses` (`_id`, `code`, `expiration`, `ident`, `limit`, `order`, `systems`) values (?, ?, ?, ?, ?, ?, ?)", access.Id, access.Code, access.Expiration, access.Ident, access.Limit, access.Order, systems); err != nil {
+			if _, err = db.Sql.Exec("insert into `rdioScannerAccesses` (`_id`, `archive`, `archiveHours`, `code`, `deny`, `expiration`, `hours`, `ident`, `limit`, `order`, `schedule`, `streamOnly`, `systems`, `watermark`) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", access.Id, access.Archive, access.ArchiveHours, access.Code, deny, access.Expiration, access.Hours, access.Ident, access.Limit, access.Order, schedule, access.StreamOnly, systems, access.Watermark); err != nil {
 				break
 			}
 
-		} else if _, err = db.Sql.Exec("update `rdioScannerAccesses` set `_id` = ?, `code` = ?, `expiration` = ?, `ident` = ?, `limit` = ?, `order` = ?, `systems` = ? where `_id` = ?", access.Id, access.Code, access.Expiration, access.Ident, access.Limit, access.Order, systems, access.Id); err != nil {
+		} else if _, err = db.Sql.Exec("update `rdioScannerAccesses` set `_id` = ?, `archive` = ?, `archiveHours` = ?, `code` = ?, `deny` = ?, `expiration` = ?, `hours` = ?, `ident` = ?, `limit` = ?, `order` = ?, `schedule` = ?, `streamOnly` = ?, `systems` = ?, `watermark` = ? where `_id` = ?", access.Id, access.Archive, access.ArchiveHours, access.Code, deny, access.Expiration, access.Hours, access.Ident, access.Limit, access.Order, schedule, access.StreamOnly, systems, access.Watermark, access.Id); err != nil {
 			break
 		}
 	}
@@ -375,3 +714,58 @@ func (accesses *Accesses) Write(db *Database) error {
 
 	return nil
 }
+
+func hasAccessScope(scope interface{}, call *Call) bool {
+	if scope != nil {
+		switch v := scope.(type) {
+		case []interface{}:
+			for _, f := range v {
+				switch v := f.(type) {
+				case map[string]interface{}:
+					switch id := v["id"].(type) {
+					case float64:
+						if id == float64(call.System) {
+							switch tg := v["talkgroups"].(type) {
+							case string:
+								if tg == "*" {
+									return true
+								}
+							case []interface{}:
+								for _, f := range tg {
+									switch tg := f.(type) {
+									case float64:
+										if tg == float64(call.Talkgroup) {
+											return true
+										}
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+
+		case string:
+			if v == "*" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (access *Access) GetRemainingSeconds(active time.Duration) (uint64, bool) {
+	if access.Hours == 0 {
+		return 0, false
+	}
+
+	quota := uint64(access.Hours) * 3600
+	used := access.listened + uint64(active.Seconds())
+
+	if used >= quota {
+		return 0, true
+	}
+
+	return quota - used, true
+}
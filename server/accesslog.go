@@ -0,0 +1,128 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AccessLogDatabase = "database"
+	AccessLogStdout   = "stdout"
+	RequestIdHeader   = "X-Request-Id"
+)
+
+type requestIdKey struct{}
+
+type AccessLog struct {
+	Controller *Controller
+}
+
+func NewAccessLog(controller *Controller) *AccessLog {
+	return &AccessLog{Controller: controller}
+}
+
+func (accessLog *AccessLog) Handler(next http.Handler) http.Handler {
+	re := regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIdHeader)
+		if !re.MatchString(id) {
+			if u, err := uuid.NewRandom(); err == nil {
+				id = u.String()
+			}
+		}
+
+		w.Header().Set(RequestIdHeader, id)
+
+		r = r.WithContext(context.WithValue(r.Context(), requestIdKey{}, id))
+
+		mode := accessLog.Controller.Options.AccessLog
+		if mode != AccessLogDatabase && mode != AccessLogStdout {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+
+		next.ServeHTTP(recorder, r)
+
+		message := fmt.Sprintf("http: method=%s path=%s status=%d duration=%s ip=%s id=%s", r.Method, r.URL.Path, recorder.status, time.Since(start).Round(time.Millisecond), GetRemoteAddr(r), id)
+
+		switch mode {
+		case AccessLogDatabase:
+			level := LogLevelInfo
+			if recorder.status >= http.StatusInternalServerError {
+				level = LogLevelError
+			} else if recorder.status >= http.StatusBadRequest {
+				level = LogLevelWarn
+			}
+			accessLog.Controller.Logs.LogEvent(level, message)
+
+		case AccessLogStdout:
+			log.Println(message)
+		}
+	})
+}
+
+func GetRequestId(r *http.Request) string {
+	if id, ok := r.Context().Value(requestIdKey{}).(string); ok {
+		return id
+	}
+
+	return ""
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (recorder *statusRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (recorder *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := recorder.ResponseWriter.(http.Hijacker); ok {
+		recorder.status = http.StatusSwitchingProtocols
+		return hijacker.Hijack()
+	}
+
+	return nil, nil, errors.New("hijacking not supported")
+}
+
+func (recorder *statusRecorder) WriteHeader(status int) {
+	if !recorder.wroteHeader {
+		recorder.status = status
+		recorder.wroteHeader = true
+	}
+
+	recorder.ResponseWriter.WriteHeader(status)
+}
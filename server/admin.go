@@ -16,6 +16,7 @@
 package main
 
 import (
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -23,55 +24,74 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
-	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"golang.org/x/crypto/bcrypt"
 )
 
+var AdminConfigSections = []string{"access", "alerts", "apiKeys", "dirWatch", "downstreams", "groups", "options", "retentions", "sdr", "systems", "tags", "webhooks"}
+
 type Admin struct {
-	Attempts         AdminLoginAttempts
-	AttemptsMax      uint
-	AttemptsMaxDelay time.Duration
-	Broadcast        chan *[]byte
-	Conns            map[*websocket.Conn]bool
-	Controller       *Controller
-	Register         chan *websocket.Conn
-	Tokens           []string
-	Unregister       chan *websocket.Conn
-	mutex            sync.Mutex
-	running          bool
+	Broadcast  chan *[]byte
+	Configs    chan *AdminConfigBroadcast
+	Conns      map[*websocket.Conn]bool
+	Controller *Controller
+	Presence   chan *AdminPresence
+	Register   chan *websocket.Conn
+	Sessions   *AdminSessions
+	Totp       *AdminTotp
+	Unregister chan *websocket.Conn
+	Users      *AdminUsers
+	connCount  int32
+	done       chan struct{}
+	mutex      sync.Mutex
+	presence   map[*websocket.Conn]*AdminPresence
+	running    bool
 }
 
-type AdminLoginAttempt struct {
-	Count uint
-	Date  time.Time
+type AdminConfigBroadcast struct {
+	editor []byte
+	viewer []byte
 }
 
-type AdminLoginAttempts map[string]*AdminLoginAttempt
-
 func NewAdmin(controller *Controller) *Admin {
 	return &Admin{
-		Attempts:         AdminLoginAttempts{},
-		AttemptsMax:      uint(3),
-		AttemptsMaxDelay: time.Duration(time.Duration.Minutes(10)),
-		Broadcast:        make(chan *[]byte),
-		Conns:            make(map[*websocket.Conn]bool),
-		Controller:       controller,
-		Register:         make(chan *websocket.Conn),
-		Tokens:           []string{},
-		Unregister:       make(chan *websocket.Conn),
-		mutex:            sync.Mutex{},
+		Broadcast:  make(chan *[]byte),
+		Configs:    make(chan *AdminConfigBroadcast),
+		Conns:      make(map[*websocket.Conn]bool),
+		Controller: controller,
+		Presence:   make(chan *AdminPresence),
+		Register:   make(chan *websocket.Conn),
+		Sessions:   NewAdminSessions(),
+		Totp:       NewAdminTotp(),
+		Unregister: make(chan *websocket.Conn),
+		Users:      NewAdminUsers(),
+		done:       make(chan struct{}),
+		mutex:      sync.Mutex{},
+		presence:   map[*websocket.Conn]*AdminPresence{},
 	}
 }
 
 func (admin *Admin) BroadcastConfig() {
-	if b, err := json.Marshal(admin.GetConfig()); err == nil {
-		for conn := range admin.Conns {
-			conn.WriteMessage(websocket.TextMessage, b)
-		}
+	var (
+		data = &AdminConfigBroadcast{}
+		err  error
+	)
+
+	if data.editor, err = json.Marshal(admin.GetRoleConfig(AdminRoleEditor)); err != nil {
+		return
+	}
+
+	if data.viewer, err = json.Marshal(admin.GetRoleConfig(AdminRoleViewer)); err != nil {
+		return
+	}
+
+	select {
+	case admin.Configs <- data:
+	case <-admin.done:
 	}
 }
 
@@ -112,6 +132,30 @@ func (admin *Admin) ChangePassword(currentPassword interface{}, newPassword stri
 	return nil
 }
 
+func (admin *Admin) ApplyConfigPassword() error {
+	password := admin.Controller.Config.AdminPassword
+
+	if len(password) == 0 {
+		return nil
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.Controller.Options.adminPassword), []byte(password)); err == nil {
+		return nil
+	}
+
+	if err := admin.ChangePassword(nil, password); err != nil {
+		return err
+	}
+
+	if admin.Totp.IsEnabled("admin") {
+		admin.Controller.Logs.LogEvent(LogLevelWarn, "admin password reset from the configuration, two-factor authentication disabled for user=\"admin\"")
+
+		return admin.Totp.Disable("admin", admin.Controller.Database)
+	}
+
+	return nil
+}
+
 func (admin *Admin) ConfigHandler(w http.ResponseWriter, r *http.Request) {
 	if strings.EqualFold(r.Header.Get("upgrade"), "websocket") {
 		upgrader := websocket.Upgrader{}
@@ -121,9 +165,18 @@ func (admin *Admin) ConfigHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		admin.Register <- conn
+		select {
+		case admin.Register <- conn:
+		case <-admin.done:
+			conn.Close()
+			return
+		}
 
 		go func() {
+			var authenticated bool
+
+			presence := NewAdminPresence(conn, GetRemoteAddr(r))
+
 			conn.SetReadDeadline(time.Time{})
 
 			for {
@@ -132,14 +185,36 @@ func (admin *Admin) ConfigHandler(w http.ResponseWriter, r *http.Request) {
 					break
 				}
 
-				if !admin.ValidateToken(string(b)) {
+				if authenticated && presence.FromMessage(b) {
+					p := *presence
+					select {
+					case admin.Presence <- &p:
+					case <-admin.done:
+					}
+					continue
+				}
+
+				user, ok := admin.GetTokenUser(string(b))
+				if !ok {
 					break
 				}
-			}
 
-			admin.Unregister <- conn
+				if !authenticated || presence.Username != user.Username || presence.Role != user.Role {
+					authenticated = true
+					presence.Role = user.Role
+					presence.Username = user.Username
+					p := *presence
+					select {
+					case admin.Presence <- &p:
+					case <-admin.done:
+					}
+				}
+			}
 
-			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(1000, ""))
+			select {
+			case admin.Unregister <- conn:
+			case <-admin.done:
+			}
 		}()
 
 	} else {
@@ -155,9 +230,24 @@ func (admin *Admin) ConfigHandler(w http.ResponseWriter, r *http.Request) {
 
 		switch r.Method {
 		case http.MethodGet:
-			admin.SendConfig(w)
+			role := AdminRoleViewer
+			if user, ok := admin.GetTokenUser(t); ok {
+				role = user.Role
+			}
+
+			admin.SendConfig(w, role)
 
 		case http.MethodPut:
+			user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+			if !ok {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			if !admin.CheckWritable(w) {
+				return
+			}
+
 			m := map[string]interface{}{}
 			err := json.NewDecoder(r.Body).Decode(&m)
 			if err != nil {
@@ -168,124 +258,79 @@ func (admin *Admin) ConfigHandler(w http.ResponseWriter, r *http.Request) {
 			admin.Controller.IngestLock()
 			admin.mutex.Lock()
 
-			admin.Controller.Dirwatches.Stop()
-
-			switch v := m["access"].(type) {
-			case []interface{}:
-				admin.Controller.Accesses.FromMap(v)
-				err := admin.Controller.Accesses.Write(admin.Controller.Database)
-				if err != nil {
-					logError(err)
-				} else {
-					err = admin.Controller.Accesses.Read(admin.Controller.Database)
-					if err != nil {
-						logError(err)
-					}
-				}
+			if !admin.CheckRevision(w, r, m) {
+				admin.mutex.Unlock()
+				admin.Controller.IngestUnlock()
+				return
 			}
 
-			switch v := m["apiKeys"].(type) {
-			case []interface{}:
-				admin.Controller.Apikeys.FromMap(v)
-				err = admin.Controller.Apikeys.Write(admin.Controller.Database)
-				if err != nil {
-					logError(err)
-				} else {
-					err = admin.Controller.Apikeys.Read(admin.Controller.Database)
-					if err != nil {
-						logError(err)
+			sections := []string{}
+			for _, section := range AdminConfigSections {
+				if _, ok := m[section]; ok {
+					if err = admin.checkConfigSection(section, m[section]); err != nil {
+						admin.mutex.Unlock()
+						admin.Controller.IngestUnlock()
+						w.WriteHeader(http.StatusBadRequest)
+						w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+						return
 					}
+					sections = append(sections, section)
 				}
 			}
 
-			switch v := m["dirWatch"].(type) {
-			case []interface{}:
-				admin.Controller.Dirwatches.FromMap(v)
-				err = admin.Controller.Dirwatches.Write(admin.Controller.Database)
-				if err != nil {
-					logError(err)
-				} else {
-					err = admin.Controller.Dirwatches.Read(admin.Controller.Database)
-					if err != nil {
-						logError(err)
-					}
-				}
-			}
+			admin.Controller.Dirwatches.Stop()
+			admin.Controller.Sdrs.Stop()
 
-			switch v := m["downstreams"].(type) {
-			case []interface{}:
-				admin.Controller.Downstreams.FromMap(v)
-				err = admin.Controller.Downstreams.Write(admin.Controller.Database)
-				if err != nil {
-					logError(err)
-				} else {
-					err = admin.Controller.Downstreams.Read(admin.Controller.Database)
-					if err != nil {
-						logError(err)
-					}
-				}
-			}
+			before := admin.getAuditSnapshot(sections)
 
-			switch v := m["groups"].(type) {
-			case []interface{}:
-				admin.Controller.Groups.FromMap(v)
-				err = admin.Controller.Groups.Write(admin.Controller.Database)
-				if err != nil {
+			var werr error
+			for _, section := range sections {
+				if err = admin.writeConfigSection(section, m[section]); err != nil {
 					logError(err)
-				} else {
-					err = admin.Controller.Groups.Read(admin.Controller.Database)
-					if err != nil {
-						logError(err)
+					if werr == nil {
+						werr = err
 					}
 				}
 			}
 
-			switch v := m["options"].(type) {
-			case map[string]interface{}:
-				admin.Controller.Options.FromMap(v)
-				err = admin.Controller.Options.Write(admin.Controller.Database)
-				if err != nil {
-					logError(err)
-				}
-			}
+			if werr != nil {
+				admin.mutex.Unlock()
+				admin.Controller.IngestUnlock()
 
-			switch v := m["systems"].(type) {
-			case []interface{}:
-				admin.Controller.Systems.FromMap(v)
-				err = admin.Controller.Systems.Write(admin.Controller.Database)
-				if err != nil {
-					logError(err)
-				} else {
-					err = admin.Controller.Systems.Read(admin.Controller.Database)
-					if err != nil {
-						logError(err)
-					}
-				}
+				admin.Controller.EmitConfig()
+				admin.Controller.Dirwatches.Start(admin.Controller)
+				admin.Controller.Sdrs.Start(admin.Controller)
+
+				w.WriteHeader(http.StatusExpectationFailed)
+				w.Write([]byte(fmt.Sprintf("%s\n", werr.Error())))
+				return
 			}
 
-			switch v := m["tags"].(type) {
-			case []interface{}:
-				admin.Controller.Tags.FromMap(v)
-				err = admin.Controller.Tags.Write(admin.Controller.Database)
-				if err != nil {
-					logError(err)
-				} else {
-					err = admin.Controller.Tags.Read(admin.Controller.Database)
-					if err != nil {
-						logError(err)
-					}
-				}
+			after := admin.getAuditSnapshot(sections)
+
+			revision, err := admin.IncrementRevision()
+			if err != nil {
+				logError(err)
 			}
 
 			admin.mutex.Unlock()
 			admin.Controller.IngestUnlock()
 
+			admin.Controller.AuditLog.Record(r, user, AuditLogActionConfig, GetAuditLogChanges(before, after))
+
+			admin.BroadcastNotice(map[string]interface{}{
+				"message":  "configuration changed by someone else",
+				"revision": revision,
+				"sections": sections,
+			})
+
 			admin.Controller.EmitConfig()
 			admin.Controller.Dirwatches.Start(admin.Controller)
+			admin.Controller.Sdrs.Start(admin.Controller)
 
-			admin.SendConfig(w)
+			admin.SendConfig(w, user.Role)
 
-			admin.Controller.Logs.LogEvent(LogLevelWarn, "configuration changed")
+			admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("configuration changed by user=\"%s\"", user.Username))
 
 		default:
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -293,215 +338,841 @@ func (admin *Admin) ConfigHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (admin *Admin) GetAuthorization(r *http.Request) string {
-	return r.Header.Get("Authorization")
-}
-
-func (admin *Admin) GetConfig() map[string]interface{} {
-	systems := []map[string]interface{}{}
-	for _, system := range admin.Controller.Systems.List {
-		systems = append(systems, map[string]interface{}{
-			"_id":          system.RowId,
-			"autoPopulate": system.AutoPopulate,
-			"blacklists":   system.Blacklists,
-			"id":           system.Id,
-			"label":        system.Label,
-			"led":          system.Led,
-			"order":        system.Order,
-			"talkgroups":   system.Talkgroups.List,
-			"units":        system.Units.List,
-		})
-	}
-
-	return map[string]interface{}{
-		"access":      admin.Controller.Accesses.List,
-		"apiKeys":     admin.Controller.Apikeys.List,
-		"dirWatch":    admin.Controller.Dirwatches.List,
-		"downstreams": admin.Controller.Downstreams.List,
-		"groups":      admin.Controller.Groups.List,
-		"options":     admin.Controller.Options,
-		"systems":     systems,
-		"tags":        admin.Controller.Tags.List,
+func (admin *Admin) ConfigSectionHandler(w http.ResponseWriter, r *http.Request) {
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.configsectionhandler: %s", err.Error()))
 	}
-}
 
-func (admin *Admin) LogsHandler(w http.ResponseWriter, r *http.Request) {
 	t := admin.GetAuthorization(r)
 	if !admin.ValidateToken(t) {
 		w.WriteHeader(http.StatusUnauthorized)
 		return
 	}
 
-	switch r.Method {
-	case http.MethodPost:
-		m := map[string]interface{}{}
-		err := json.NewDecoder(r.Body).Decode(&m)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
-			return
+	section, ok := admin.GetConfigSection(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/config/"), "/"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	role := AdminRoleViewer
+	if user, ok := admin.GetTokenUser(t); ok {
+		role = user.Role
+	}
+
+	sendSection := func() {
+		revision := admin.GetRevision()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, revision))
+		m := map[string]interface{}{section: admin.GetRoleConfig(role)[section], "revision": revision}
+		if restart := admin.Controller.Options.GetPendingRestart(); section == "options" && len(restart) > 0 {
+			m["restartRequired"] = restart
+		}
+		if err := WriteJsonStream(w, m, 3); err != nil {
+			logError(err)
 		}
+	}
 
-		logOptions := LogsSearchOptions{}
-		err = logOptions.FromMap(m)
-		if err != nil {
-			w.WriteHeader(http.StatusBadRequest)
+	switch r.Method {
+	case http.MethodGet:
+		sendSection()
+
+	case http.MethodPatch, http.MethodPut:
+		var (
+			changed []int
+			err     error
+			v       interface{}
+		)
+
+		user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
 			return
 		}
 
-		r, err := admin.Controller.Logs.Search(&logOptions, admin.Controller.Database)
-		if err != nil {
-			admin.Controller.Logs.LogEvent(LogLevelError, err.Error())
-			w.WriteHeader(http.StatusExpectationFailed)
+		if !admin.CheckWritable(w) {
 			return
 		}
 
-		b, err := json.Marshal(r)
-		if err != nil {
-			admin.Controller.Logs.LogEvent(LogLevelError, err.Error())
-			w.WriteHeader(http.StatusExpectationFailed)
+		if err = json.NewDecoder(r.Body).Decode(&v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
-		w.Write(b)
+		admin.Controller.IngestLock()
+		admin.mutex.Lock()
 
-	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
-	}
-}
+		submitted := map[string]interface{}{}
+		if r.Method == http.MethodPut {
+			submitted[section] = v
+		}
 
-func (admin *Admin) LoginHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		m := map[string]interface{}{}
+		if !admin.CheckRevision(w, r, submitted) {
+			admin.mutex.Unlock()
+			admin.Controller.IngestUnlock()
+			return
+		}
 
-		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		if r.Method == http.MethodPatch {
+			v, changed, err = admin.patchConfigSection(section, v)
+		} else {
+			err = admin.checkConfigSection(section, v)
+		}
+		if err != nil {
+			admin.mutex.Unlock()
+			admin.Controller.IngestUnlock()
 			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
 			return
 		}
 
-		remoteAddr := GetRemoteAddr(r)
-
-		attempt := admin.Attempts[remoteAddr]
+		before := admin.getAuditSnapshot([]string{section})
 
-		if attempt == nil {
-			admin.Attempts[remoteAddr] = &AdminLoginAttempt{
-				Count: 1,
-				Date:  time.Now(),
-			}
-			attempt = admin.Attempts[remoteAddr]
-		} else {
-			attempt.Count++
-			attempt.Date = time.Now()
+		if section == "dirWatch" {
+			admin.Controller.Dirwatches.Stop()
 		}
 
-		if attempt.Count > admin.AttemptsMax || time.Since(attempt.Date) < admin.AttemptsMaxDelay {
-			if attempt.Count == admin.AttemptsMax+1 {
-				admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("too many login attempts for ip=\"%v\"", remoteAddr))
-			}
+		if section == "sdr" {
+			admin.Controller.Sdrs.Stop()
+		}
 
-			w.WriteHeader(http.StatusUnauthorized)
-			return
+		if section == "systems" && r.Method == http.MethodPatch {
+			err = admin.writeSystems(v.([]interface{}), changed)
+		} else {
+			err = admin.writeConfigSection(section, v)
 		}
 
-		ok := false
+		after := admin.getAuditSnapshot([]string{section})
 
-		switch v := m["password"].(type) {
-		case string:
-			if len(v) > 0 {
-				if err := bcrypt.CompareHashAndPassword([]byte(admin.Controller.Options.adminPassword), []byte(v)); err == nil {
-					ok = true
-				}
+		if err == nil {
+			revision, rerr := admin.IncrementRevision()
+			if rerr != nil {
+				logError(rerr)
 			}
-		}
 
-		if !ok {
-			admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("invalid login attempt for ip=%v", remoteAddr))
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		}
+			admin.mutex.Unlock()
+			admin.Controller.IngestUnlock()
 
-		id, err := uuid.NewRandom()
+			admin.BroadcastNotice(map[string]interface{}{
+				"message":  "configuration changed by someone else",
+				"revision": revision,
+				"sections": []string{section},
+			})
 
-		if err != nil {
-			w.WriteHeader(http.StatusExpectationFailed)
-			return
+		} else {
+			admin.mutex.Unlock()
+			admin.Controller.IngestUnlock()
 		}
 
-		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{ID: id.String()})
-		sToken, err := token.SignedString([]byte(admin.Controller.Options.secret))
-
-		if err != nil {
-			w.WriteHeader(http.StatusExpectationFailed)
-			return
+		if section == "dirWatch" {
+			admin.Controller.Dirwatches.Start(admin.Controller)
 		}
 
-		if len(admin.Tokens) < 5 {
-			admin.Tokens = append(admin.Tokens, sToken)
-		} else {
-			admin.Tokens = append(admin.Tokens[1:], sToken)
+		if section == "sdr" {
+			admin.Controller.Sdrs.Start(admin.Controller)
 		}
 
-		b, err := json.Marshal(map[string]interface{}{
-			"passwordNeedChange": true,
-			"token":              sToken,
-		})
 		if err != nil {
+			logError(err)
 			w.WriteHeader(http.StatusExpectationFailed)
 			return
 		}
 
-		for k, v := range admin.Attempts {
-			if time.Since(v.Date) > admin.AttemptsMaxDelay {
-				delete(admin.Attempts, k)
-			}
-		}
+		admin.Controller.EmitConfig()
 
-		w.Write(b)
+		sendSection()
+
+		admin.Controller.AuditLog.Record(r, user, AuditLogActionConfig, GetAuditLogChanges(before, after))
+
+		admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("configuration changed, %s by user=\"%s\"", section, user.Username))
 
 	default:
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
 }
 
-func (admin *Admin) LogoutHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		t := admin.GetAuthorization(r)
-		if !admin.ValidateToken(t) {
-			w.WriteHeader(http.StatusUnauthorized)
-			return
-		}
-		for k, v := range admin.Tokens {
-			if v == t {
-				admin.Tokens = append(admin.Tokens[:k], admin.Tokens[k+1:]...)
-			}
+func (admin *Admin) GetConfigSection(name string) (string, bool) {
+	for _, section := range AdminConfigSections {
+		if strings.EqualFold(section, name) {
+			return section, true
 		}
-		w.WriteHeader(http.StatusOK)
-
-	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
+	return "", false
 }
 
-func (admin *Admin) PasswordHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		var (
-			b               []byte
-			currentPassword interface{}
-			newPassword     string
-		)
-
-		logError := func(err error) {
-			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.passwordhandler.post: %s", err.Error()))
-		}
+func (admin *Admin) GetConnsCount() int {
+	return int(atomic.LoadInt32(&admin.connCount))
+}
+
+func (admin *Admin) GetAuthorization(r *http.Request) string {
+	return r.Header.Get("Authorization")
+}
+
+func (admin *Admin) GetConfig() map[string]interface{} {
+	systems := []map[string]interface{}{}
+	for _, system := range admin.Controller.Systems.List {
+		systems = append(systems, map[string]interface{}{
+			"_id":                    system.RowId,
+			"audioBitrate":           system.AudioBitrate,
+			"audioChannels":          system.AudioChannels,
+			"audioCodec":             system.AudioCodec,
+			"audioConversion":        system.AudioConversion,
+			"audioSampleRate":        system.AudioSampleRate,
+			"autoPopulate":           system.AutoPopulate,
+			"blacklists":             system.Blacklists,
+			"broadcastifyApiKey":     system.BroadcastifyApiKey,
+			"broadcastifySystemId":   system.BroadcastifySystemId,
+			"duplicatePrimary":       system.DuplicatePrimary,
+			"duplicateStrategy":      system.DuplicateStrategy,
+			"id":                     system.Id,
+			"label":                  system.Label,
+			"led":                    system.Led,
+			"order":                  system.Order,
+			"radioReferenceConflict": system.RadioReferenceConflict,
+			"radioReferenceSystemId": system.RadioReferenceSystemId,
+			"streamOnly":             system.StreamOnly,
+			"talkgroups":             system.Talkgroups.List,
+			"unitBlacklists":         system.UnitBlacklists,
+			"units":                  system.Units.List,
+			"unitsImportConflict":    system.UnitsImportConflict,
+			"unitsImportUrl":         system.UnitsImportUrl,
+		})
+	}
+
+	return map[string]interface{}{
+		"access":      admin.Controller.Accesses.List,
+		"alerts":      admin.Controller.Alerts.List,
+		"apiKeys":     admin.Controller.Apikeys.List,
+		"dirWatch":    admin.Controller.Dirwatches.List,
+		"downstreams": admin.Controller.Downstreams.List,
+		"groups":      admin.Controller.Groups.List,
+		"options":     admin.Controller.Options,
+		"retentions":  admin.Controller.Retentions.List,
+		"sdr":         admin.Controller.Sdrs.List,
+		"systems":     systems,
+		"tags":        admin.Controller.Tags.List,
+		"webhooks":    admin.Controller.Webhooks.List,
+	}
+}
+
+func (admin *Admin) GetRoleConfig(role string) map[string]interface{} {
+	var config map[string]interface{}
+
+	if getAdminRoleRank(role) >= getAdminRoleRank(AdminRoleEditor) {
+		return admin.GetConfig()
+	}
+
+	b, err := json.Marshal(admin.GetConfig())
+	if err != nil {
+		return map[string]interface{}{}
+	}
+
+	if err = json.Unmarshal(b, &config); err != nil {
+		return map[string]interface{}{}
+	}
+
+	mask := func(m map[string]interface{}, key string) {
+		if s, ok := m[key].(string); ok && len(s) > 0 {
+			m[key] = AdminSecretMask
+		}
+	}
+
+	for section, keys := range map[string][]string{
+		"access":      {"code"},
+		"alerts":      {"target"},
+		"apiKeys":     {"key"},
+		"dirWatch":    {"password"},
+		"downstreams": {"apiKey"},
+		"webhooks":    {"headers", "url"},
+	} {
+		if list, ok := config[section].([]interface{}); ok {
+			for _, v := range list {
+				if m, ok := v.(map[string]interface{}); ok {
+					for _, key := range keys {
+						mask(m, key)
+					}
+				}
+			}
+		}
+	}
+
+	if options, ok := config["options"].(map[string]interface{}); ok {
+		for _, option := range GetOptionsSchema() {
+			if option.Secret {
+				mask(options, option.Name)
+			}
+		}
+	}
+
+	return config
+}
+
+func (admin *Admin) patchConfigSection(section string, patch interface{}) (interface{}, []int, error) {
+	var current interface{}
+
+	if b, err := json.Marshal(admin.GetConfig()[section]); err != nil {
+		return nil, nil, err
+	} else if err = json.Unmarshal(b, &current); err != nil {
+		return nil, nil, err
+	}
+
+	switch c := current.(type) {
+	case map[string]interface{}:
+		p, ok := patch.(map[string]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("%s patch must be an object", section)
+		}
+
+		for k, v := range p {
+			c[k] = v
+		}
+
+		return c, nil, nil
+
+	case []interface{}:
+		changed := []int{}
+
+		p, ok := patch.([]interface{})
+		if !ok {
+			return nil, nil, fmt.Errorf("%s patch must be an array", section)
+		}
+
+	Patch:
+		for _, f := range p {
+			m, ok := f.(map[string]interface{})
+			if !ok {
+				return nil, nil, fmt.Errorf("invalid %s patch entry", section)
+			}
+
+			if id, ok := m["_id"]; ok && id != nil {
+				for i, e := range c {
+					if e, ok := e.(map[string]interface{}); ok && fmt.Sprint(e["_id"]) == fmt.Sprint(id) {
+						if m["_delete"] == true {
+							c = append(c[:i], c[i+1:]...)
+							shifted := []int{}
+							for _, j := range changed {
+								if j > i {
+									shifted = append(shifted, j-1)
+								} else if j < i {
+									shifted = append(shifted, j)
+								}
+							}
+							changed = shifted
+						} else {
+							for k, v := range m {
+								e[k] = v
+							}
+							changed = append(changed, i)
+						}
+						continue Patch
+					}
+				}
+			}
+
+			if m["_delete"] != true {
+				c = append(c, m)
+				changed = append(changed, len(c)-1)
+			}
+		}
+
+		return c, changed, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown section %s", section)
+}
+
+func (admin *Admin) checkConfigSection(section string, v interface{}) error {
+	switch section {
+	case "options":
+		if _, ok := v.(map[string]interface{}); !ok {
+			return fmt.Errorf("%s must be an object", section)
+		}
+
+	default:
+		if _, ok := v.([]interface{}); !ok {
+			return fmt.Errorf("%s must be an array", section)
+		}
+	}
+
+	return nil
+}
+
+func (admin *Admin) writeConfigSection(section string, v interface{}) error {
+	write := func(w func(*Database) error, r func(*Database) error) error {
+		if err := w(admin.Controller.Database); err != nil {
+			return err
+		}
+		return r(admin.Controller.Database)
+	}
+
+	if err := admin.checkConfigSection(section, v); err != nil {
+		return err
+	}
+
+	switch section {
+	case "access":
+		if v, ok := v.([]interface{}); ok {
+			admin.Controller.Accesses.FromMap(v)
+			return write(admin.Controller.Accesses.Write, admin.Controller.Accesses.Read)
+		}
+
+	case "alerts":
+		if v, ok := v.([]interface{}); ok {
+			admin.Controller.Alerts.FromMap(v)
+			if err := admin.Controller.Alerts.Validate(); err != nil {
+				admin.Controller.Alerts.Read(admin.Controller.Database)
+				return err
+			}
+			return write(admin.Controller.Alerts.Write, admin.Controller.Alerts.Read)
+		}
+
+	case "apiKeys":
+		if v, ok := v.([]interface{}); ok {
+			admin.Controller.Apikeys.FromMap(v)
+			return write(admin.Controller.Apikeys.Write, admin.Controller.Apikeys.Read)
+		}
+
+	case "dirWatch":
+		if v, ok := v.([]interface{}); ok {
+			admin.Controller.Dirwatches.FromMap(v)
+			return write(admin.Controller.Dirwatches.Write, admin.Controller.Dirwatches.Read)
+		}
+
+	case "downstreams":
+		if v, ok := v.([]interface{}); ok {
+			admin.Controller.Downstreams.FromMap(v)
+			return write(admin.Controller.Downstreams.Write, admin.Controller.Downstreams.Read)
+		}
+
+	case "groups":
+		if v, ok := v.([]interface{}); ok {
+			admin.Controller.Groups.FromMap(v)
+			return write(admin.Controller.Groups.Write, admin.Controller.Groups.Read)
+		}
+
+	case "options":
+		if v, ok := v.(map[string]interface{}); ok {
+			admin.Controller.Options.FromMap(v)
+			return admin.Controller.Options.Write(admin.Controller.Database)
+		}
+
+	case "retentions":
+		if v, ok := v.([]interface{}); ok {
+			admin.Controller.Retentions.FromMap(v)
+			if err := admin.Controller.Retentions.Validate(); err != nil {
+				admin.Controller.Retentions.Read(admin.Controller.Database)
+				return err
+			}
+			return write(admin.Controller.Retentions.Write, admin.Controller.Retentions.Read)
+		}
+
+	case "sdr":
+		if v, ok := v.([]interface{}); ok {
+			admin.Controller.Sdrs.FromMap(v)
+			return write(admin.Controller.Sdrs.Write, admin.Controller.Sdrs.Read)
+		}
+
+	case "systems":
+		if v, ok := v.([]interface{}); ok {
+			admin.Controller.Systems.FromMap(v)
+			return write(admin.Controller.Systems.Write, admin.Controller.Systems.Read)
+		}
+
+	case "tags":
+		if v, ok := v.([]interface{}); ok {
+			admin.Controller.Tags.FromMap(v)
+			return write(admin.Controller.Tags.Write, admin.Controller.Tags.Read)
+		}
+
+	case "webhooks":
+		if v, ok := v.([]interface{}); ok {
+			admin.Controller.Webhooks.FromMap(v)
+			if err := admin.Controller.Webhooks.Validate(); err != nil {
+				admin.Controller.Webhooks.Read(admin.Controller.Database)
+				return err
+			}
+			return write(admin.Controller.Webhooks.Write, admin.Controller.Webhooks.Read)
+		}
+	}
+
+	return nil
+}
+
+func (admin *Admin) writeSystems(v []interface{}, changed []int) error {
+	systems := admin.Controller.Systems
+
+	if len(v) < len(systems.List) {
+		return admin.writeConfigSection("systems", v)
+	}
+
+	systems.FromMap(v)
+
+	list := []*System{}
+	for _, i := range changed {
+		if i < len(systems.List) {
+			list = append(list, systems.List[i])
+		}
+	}
+
+	if err := systems.WriteSystems(admin.Controller.Database, list); err != nil {
+		return err
+	}
+
+	return systems.Read(admin.Controller.Database)
+}
+
+func (admin *Admin) exportLogs(w http.ResponseWriter, logOptions *LogsSearchOptions, format string) {
+	var (
+		err error
+		fn  func(log Log) error
+	)
+
+	filename := fmt.Sprintf("rdio-scanner-logs-%s.%s", time.Now().UTC().Format("20060102150405"), format)
+
+	switch format {
+	case LogsExportCsv:
+		cw := csv.NewWriter(w)
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+		if err = cw.Write([]string{"id", "dateTime", "level", "message"}); err != nil {
+			return
+		}
+
+		fn = func(log Log) error {
+			return cw.Write([]string{fmt.Sprint(log.Id), log.DateTime.UTC().Format(time.RFC3339), log.Level, log.Message})
+		}
+
+		defer cw.Flush()
+
+	default:
+		enc := json.NewEncoder(w)
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+		fn = func(log Log) error {
+			return enc.Encode(log)
+		}
+	}
+
+	if err = admin.Controller.Logs.Export(logOptions, admin.Controller.Database, fn); err != nil {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.exportlogs: %s", err.Error()))
+	}
+}
+
+func (admin *Admin) LogsHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		m := map[string]interface{}{}
+		err := json.NewDecoder(r.Body).Decode(&m)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		logOptions := LogsSearchOptions{}
+		err = logOptions.FromMap(m)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+			return
+		}
+
+		if format, ok := logOptions.Format.(string); ok {
+			admin.exportLogs(w, &logOptions, format)
+			return
+		}
+
+		timing := NewServerTiming()
+
+		stop := timing.Start("db")
+		r, err := admin.Controller.Logs.Search(&logOptions, admin.Controller.Database)
+		if err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, err.Error())
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+		stop()
+
+		stop = timing.Start("serialize")
+		b, err := json.Marshal(r)
+		if err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, err.Error())
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+		stop()
+
+		if admin.Controller.Options.ServerTiming {
+			timing.SetHeader(w)
+		}
+
+		w.Write(b)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		m := map[string]interface{}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		remoteAddr := GetRemoteAddr(r)
+
+		var (
+			account  = "admin"
+			code     string
+			ok       bool
+			password string
+			role     = AdminRoleAdmin
+			subject  string
+			username string
+		)
+
+		switch v := m["code"].(type) {
+		case string:
+			code = v
+		}
+
+		switch v := m["password"].(type) {
+		case string:
+			password = v
+		}
+
+		switch v := m["username"].(type) {
+		case string:
+			username = strings.TrimSpace(v)
+		}
+
+		if len(username) > 0 {
+			if user, found := admin.Users.GetUser(username); found && !user.Disabled && user.CheckPassword(password) {
+				ok = true
+				account = user.GetTotpAccount()
+				role = user.Role
+				subject = fmt.Sprintf("user:%s", user.Username)
+				username = user.Username
+			}
+
+		} else if len(password) > 0 {
+			if err := bcrypt.CompareHashAndPassword([]byte(admin.Controller.Options.adminPassword), []byte(password)); err == nil {
+				ok = true
+				username = "admin"
+			}
+		}
+
+		if !ok {
+			admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("invalid login attempt for ip=%v", remoteAddr))
+			admin.Controller.RateLimiter.Fail(remoteAddr)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if admin.Totp.IsEnabled(account) && !admin.Totp.Validate(account, code) {
+			if len(code) > 0 {
+				admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("invalid two-factor code for user=\"%s\" ip=%v", username, remoteAddr))
+				admin.Controller.RateLimiter.Fail(remoteAddr)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"totp":true}`))
+			return
+		}
+
+		sToken, refresh, err := admin.issueToken(subject)
+		if err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.loginhandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		admin.Controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("admin login user=\"%s\" ip=%v", username, remoteAddr))
+
+		b, err := json.Marshal(map[string]interface{}{
+			"expiresIn":          int(admin.Controller.Options.GetAdminTokenExpiry().Seconds()),
+			"passwordNeedChange": true,
+			"refreshToken":       refresh,
+			"role":               role,
+			"token":              sToken,
+			"username":           username,
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		w.Write(b)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) issueToken(subject string) (string, string, error) {
+	session, refresh, err := admin.Sessions.Create(subject, admin.Controller.Options.GetAdminSessionExpiry(), admin.Controller.Database)
+	if err != nil {
+		return "", "", err
+	}
+
+	sToken, err := admin.signToken(session)
+	if err != nil {
+		admin.Sessions.Delete(session.Id, admin.Controller.Database)
+		return "", "", err
+	}
+
+	return sToken, refresh, nil
+}
+
+func (admin *Admin) signToken(session *AdminSession) (string, error) {
+	now := time.Now()
+
+	expires := now.Add(admin.Controller.Options.GetAdminTokenExpiry())
+	if expires.After(session.Expires) {
+		expires = session.Expires
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(expires),
+		ID:        session.Id,
+		IssuedAt:  jwt.NewNumericDate(now),
+		Subject:   session.Subject,
+	})
+
+	return token.SignedString([]byte(admin.Controller.Options.secret))
+}
+
+func (admin *Admin) parseToken(sToken string) (*jwt.RegisteredClaims, bool) {
+	claims := &jwt.RegisteredClaims{}
+
+	token, err := jwt.ParseWithClaims(sToken, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return []byte(admin.Controller.Options.secret), nil
+	})
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+
+	if _, ok := admin.Sessions.GetSession(claims.ID); !ok {
+		return nil, false
+	}
+
+	return claims, true
+}
+
+func (admin *Admin) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		claims, ok := admin.parseToken(admin.GetAuthorization(r))
+		if !ok {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := admin.Sessions.Delete(claims.ID, admin.Controller.Database); err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.logouthandler: %s", err.Error()))
+		}
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var refresh string
+
+		m := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch v := m["refreshToken"].(type) {
+		case string:
+			refresh = v
+		}
+
+		session, refresh, err := admin.Sessions.Rotate(refresh, admin.Controller.Options.GetAdminSessionExpiry(), admin.Controller.Database)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		user, ok := admin.getSubjectUser(session.Subject)
+		if !ok {
+			admin.Sessions.Delete(session.Id, admin.Controller.Database)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		sToken, err := admin.signToken(session)
+		if err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.refreshhandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		if b, err := json.Marshal(map[string]interface{}{
+			"expiresIn":    int(admin.Controller.Options.GetAdminTokenExpiry().Seconds()),
+			"refreshToken": refresh,
+			"role":         user.Role,
+			"token":        sToken,
+			"username":     user.Username,
+		}); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) PasswordHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		var (
+			b               []byte
+			currentPassword interface{}
+			newPassword     string
+		)
+
+		logError := func(err error) {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.passwordhandler.post: %s", err.Error()))
+		}
 
 		t := admin.GetAuthorization(r)
-		if !admin.ValidateToken(t) {
+		user, ok := admin.GetTokenUser(t)
+		if !ok {
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
 
+		if !admin.CheckWritable(w) {
+			return
+		}
+
 		m := map[string]interface{}{}
 		err := json.NewDecoder(r.Body).Decode(&m)
 		if err != nil {
@@ -509,6 +1180,39 @@ func (admin *Admin) PasswordHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if user.Id != nil {
+			current, _ := m["currentPassword"].(string)
+			next, _ := m["newPassword"].(string)
+
+			if !user.CheckPassword(current) {
+				logError(fmt.Errorf("unable to change password of user=\"%s\", current password is invalid", user.Username))
+				w.WriteHeader(http.StatusExpectationFailed)
+				return
+			}
+
+			u := *user
+			if err = admin.Users.Save(&u, next, admin.Controller.Database); err != nil {
+				logError(err)
+				w.WriteHeader(http.StatusExpectationFailed)
+				return
+			}
+
+			admin.Controller.AuditLog.Record(r, user, AuditLogActionPassword, nil)
+
+			admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("admin password changed for user=\"%s\"", user.Username))
+
+			if b, err = json.Marshal(map[string]interface{}{"passwordNeedChange": false}); err == nil {
+				w.Write(b)
+			} else {
+				w.WriteHeader(http.StatusExpectationFailed)
+			}
+			return
+
+		} else if !user.HasRole(AdminRoleAdmin) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
 		switch v := m["currentPassword"].(type) {
 		case string:
 			currentPassword = v
@@ -528,6 +1232,8 @@ func (admin *Admin) PasswordHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		admin.Controller.AuditLog.Record(r, user, AuditLogActionPassword, nil)
+
 		if b, err = json.Marshal(map[string]interface{}{"passwordNeedChange": admin.Controller.Options.adminPasswordNeedChange}); err == nil {
 			w.Write(b)
 		} else {
@@ -539,29 +1245,47 @@ func (admin *Admin) PasswordHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (admin *Admin) SendConfig(w http.ResponseWriter) {
+func (admin *Admin) SendConfig(w http.ResponseWriter, role string) {
 	var m map[string]interface{}
 	_, docker := os.LookupEnv("DOCKER")
 	if docker {
 		m = map[string]interface{}{
-			"config":             admin.GetConfig(),
+			"config":             admin.GetRoleConfig(role),
 			"docker":             docker,
 			"passwordNeedChange": admin.Controller.Options.adminPasswordNeedChange,
 		}
 	} else {
 		m = map[string]interface{}{
-			"config":             admin.GetConfig(),
+			"config":             admin.GetRoleConfig(role),
 			"passwordNeedChange": admin.Controller.Options.adminPasswordNeedChange,
 		}
 	}
-	if b, err := json.Marshal(m); err == nil {
-		w.Write(b)
-	} else {
-		w.WriteHeader(http.StatusExpectationFailed)
+	if restart := admin.Controller.GetPendingRestart(); len(restart) > 0 {
+		m["restartPending"] = restart
+	}
+	if restart := admin.Controller.Options.GetPendingRestart(); len(restart) > 0 {
+		m["restartRequired"] = restart
+	}
+	if admin.Controller.Config.ReadOnly {
+		m["readOnly"] = true
+	}
+	if admin.Controller.Options.CheckForUpdates {
+		m["update"] = admin.Controller.Updater.ToMap()
+	}
+	if unread, err := admin.Controller.Notifications.CountUnread(); err == nil {
+		m["notifications"] = unread
+	}
+	m["revision"] = admin.GetRevision()
+	w.Header().Set("ETag", fmt.Sprintf(`"%v"`, m["revision"]))
+	if err := WriteJsonStream(w, m, 3); err != nil {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.sendconfig: %s", err.Error()))
 	}
 }
 
 func (admin *Admin) Start() error {
+	admin.mutex.Lock()
+	defer admin.mutex.Unlock()
+
 	if admin.running {
 		return errors.New("admin already running")
 	} else {
@@ -569,28 +1293,63 @@ func (admin *Admin) Start() error {
 	}
 
 	go func() {
+		unregister := func(conn *websocket.Conn, code int) {
+			if _, ok := admin.Conns[conn]; ok {
+				delete(admin.Conns, conn)
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""))
+				conn.Close()
+			}
+			atomic.StoreInt32(&admin.connCount, int32(len(admin.Conns)))
+			if _, ok := admin.presence[conn]; ok {
+				delete(admin.presence, conn)
+				admin.emitPresence()
+			}
+		}
+
 		for {
 			select {
-			case data, ok := <-admin.Broadcast:
-				if !ok {
-					return
+			case <-admin.done:
+				for conn := range admin.Conns {
+					unregister(conn, websocket.CloseGoingAway)
 				}
+				return
 
+			case data := <-admin.Configs:
 				for conn := range admin.Conns {
-					err := conn.WriteMessage(websocket.TextMessage, *data)
-					if err != nil {
-						admin.Unregister <- conn
+					p, ok := admin.presence[conn]
+					if !ok {
+						continue
+					}
+
+					b := data.editor
+					if getAdminRoleRank(p.Role) < getAdminRoleRank(AdminRoleEditor) {
+						b = data.viewer
 					}
+
+					if err := conn.WriteMessage(websocket.TextMessage, b); err != nil {
+						unregister(conn, websocket.CloseNormalClosure)
+					}
+				}
+
+			case data := <-admin.Broadcast:
+				for conn := range admin.Conns {
+					if err := conn.WriteMessage(websocket.TextMessage, *data); err != nil {
+						unregister(conn, websocket.CloseNormalClosure)
+					}
+				}
+
+			case presence := <-admin.Presence:
+				if _, ok := admin.Conns[presence.conn]; ok {
+					admin.presence[presence.conn] = presence
+					admin.emitPresence()
 				}
 
 			case conn := <-admin.Register:
 				admin.Conns[conn] = true
+				atomic.StoreInt32(&admin.connCount, int32(len(admin.Conns)))
 
 			case conn := <-admin.Unregister:
-				if _, ok := admin.Conns[conn]; ok {
-					delete(admin.Conns, conn)
-					conn.Close()
-				}
+				unregister(conn, websocket.CloseNormalClosure)
 			}
 		}
 	}()
@@ -598,6 +1357,121 @@ func (admin *Admin) Start() error {
 	return nil
 }
 
+func (admin *Admin) Stop() error {
+	admin.mutex.Lock()
+	defer admin.mutex.Unlock()
+
+	if !admin.running {
+		return errors.New("admin not running")
+	}
+
+	close(admin.done)
+
+	admin.running = false
+
+	return nil
+}
+
+func (admin *Admin) FrequenciesHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		m := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		searchOptions := StatisticsSearchOptions{}
+		if err := searchOptions.FromMap(m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+			return
+		}
+
+		timing := NewServerTiming()
+
+		stop := timing.Start("db")
+		list, err := admin.Controller.Frequencies.Search(admin.Controller.Database, &searchOptions)
+		if err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, err.Error())
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+		stop()
+
+		stop = timing.Start("serialize")
+		if b, err := json.Marshal(map[string]interface{}{"frequencies": list, "options": searchOptions}); err == nil {
+			stop()
+			if admin.Controller.Options.ServerTiming {
+				timing.SetHeader(w)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) StatisticsHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		m := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		searchOptions := StatisticsSearchOptions{}
+		if err := searchOptions.FromMap(m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+			return
+		}
+
+		timing := NewServerTiming()
+
+		stop := timing.Start("db")
+		list, err := admin.Controller.Statistics.Search(admin.Controller.Database, &searchOptions)
+		if err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, err.Error())
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+		stop()
+
+		stop = timing.Start("serialize")
+		if b, err := json.Marshal(map[string]interface{}{"options": searchOptions, "statistics": list}); err == nil {
+			stop()
+			if admin.Controller.Options.ServerTiming {
+				timing.SetHeader(w)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (admin *Admin) UserAddHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
@@ -611,6 +1485,16 @@ func (admin *Admin) UserAddHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if !admin.CheckWritable(w) {
+			return
+		}
+
 		m := map[string]interface{}{}
 		err := json.NewDecoder(r.Body).Decode(&m)
 		if err != nil {
@@ -618,17 +1502,38 @@ func (admin *Admin) UserAddHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		admin.mutex.Lock()
+
+		before := admin.getAuditSnapshot([]string{"access"})
+
 		admin.Controller.Accesses.Add(NewAccess().FromMap(m))
 
 		if err := admin.Controller.Accesses.Write(admin.Controller.Database); err == nil {
 			if err := admin.Controller.Accesses.Read(admin.Controller.Database); err == nil {
+				after := admin.getAuditSnapshot([]string{"access"})
+
+				revision, err := admin.IncrementRevision()
+				if err != nil {
+					logError(err)
+				}
+
+				admin.mutex.Unlock()
+
+				admin.Controller.AuditLog.Record(r, user, AuditLogActionAccessAdd, GetAuditLogChanges(before, after))
+				admin.BroadcastNotice(map[string]interface{}{
+					"message":  "configuration changed by someone else",
+					"revision": revision,
+					"sections": []string{"access"},
+				})
 				admin.BroadcastConfig()
 				w.WriteHeader(http.StatusOK)
 			} else {
+				admin.mutex.Unlock()
 				logError(err)
 				w.WriteHeader(http.StatusExpectationFailed)
 			}
 		} else {
+			admin.mutex.Unlock()
 			logError(err)
 			w.WriteHeader(http.StatusExpectationFailed)
 		}
@@ -651,6 +1556,16 @@ func (admin *Admin) UserRemoveHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if !admin.CheckWritable(w) {
+			return
+		}
+
 		m := map[string]interface{}{}
 		err := json.NewDecoder(r.Body).Decode(&m)
 		if err != nil {
@@ -658,19 +1573,42 @@ func (admin *Admin) UserRemoveHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		admin.mutex.Lock()
+
+		before := admin.getAuditSnapshot([]string{"access"})
+
 		if _, ok := admin.Controller.Accesses.Remove(NewAccess().FromMap(m)); ok {
 			if err := admin.Controller.Accesses.Write(admin.Controller.Database); err == nil {
 				if err := admin.Controller.Accesses.Read(admin.Controller.Database); err == nil {
+					after := admin.getAuditSnapshot([]string{"access"})
+
+					revision, err := admin.IncrementRevision()
+					if err != nil {
+						logError(err)
+					}
+
+					admin.mutex.Unlock()
+
+					admin.Controller.AuditLog.Record(r, user, AuditLogActionAccessRemove, GetAuditLogChanges(before, after))
+					admin.BroadcastNotice(map[string]interface{}{
+						"message":  "configuration changed by someone else",
+						"revision": revision,
+						"sections": []string{"access"},
+					})
 					admin.BroadcastConfig()
 					w.WriteHeader(http.StatusOK)
 				} else {
+					admin.mutex.Unlock()
 					logError(err)
 					w.WriteHeader(http.StatusExpectationFailed)
 				}
 			} else {
+				admin.mutex.Unlock()
 				logError(err)
 				w.WriteHeader(http.StatusExpectationFailed)
 			}
+		} else {
+			admin.mutex.Unlock()
 		}
 
 	default:
@@ -678,28 +1616,157 @@ func (admin *Admin) UserRemoveHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (admin *Admin) ValidateToken(sToken string) bool {
-	found := false
-	for _, t := range admin.Tokens {
-		if t == sToken {
-			found = true
-			break
+func (admin *Admin) GetTokenUser(sToken string) (*AdminUser, bool) {
+	claims, ok := admin.parseToken(sToken)
+	if !ok {
+		return nil, false
+	}
+
+	return admin.getSubjectUser(claims.Subject)
+}
+
+func (admin *Admin) getSubjectUser(subject string) (*AdminUser, bool) {
+	switch {
+	case strings.HasPrefix(subject, "user:"):
+		user, ok := admin.Users.GetUser(strings.TrimPrefix(subject, "user:"))
+		if !ok || user.Disabled {
+			return nil, false
+		}
+		return user, true
+
+	case strings.HasPrefix(subject, "sso:"):
+		s := strings.SplitN(strings.TrimPrefix(subject, "sso:"), ":", 2)
+		if len(s) != 2 || getAdminRoleRank(s[0]) == 0 || len(s[1]) == 0 {
+			return nil, false
 		}
+		return &AdminUser{Role: s[0], Username: s[1], sso: true}, true
+
+	default:
+		return &AdminUser{Role: AdminRoleAdmin, Username: "admin"}, true
 	}
-	if !found {
-		return false
+}
+
+func (admin *Admin) UsersHandler(w http.ResponseWriter, r *http.Request) {
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.usershandler: %s", err.Error()))
 	}
 
-	token, err := jwt.Parse(sToken, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	self, ok := admin.ValidateTokenRole(t, AdminRoleAdmin)
+	if !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	getUsers := func() map[string]interface{} {
+		admin.Users.mutex.Lock()
+		defer admin.Users.mutex.Unlock()
+
+		return map[string]interface{}{"users": getAuditLogValue(admin.Users.List)}
+	}
+
+	sendUsers := func() {
+		admin.Users.mutex.Lock()
+		b, err := json.Marshal(admin.Users.List)
+		admin.Users.mutex.Unlock()
+
+		if err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
 		}
+	}
 
-		return []byte(admin.Controller.Options.secret), nil
-	})
-	if err != nil {
-		return false
+	switch r.Method {
+	case http.MethodGet:
+		sendUsers()
+
+	case http.MethodPost:
+		if !admin.CheckWritable(w) {
+			return
+		}
+
+		m := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		user := NewAdminUser().FromMap(m)
+		password, _ := m["password"].(string)
+
+		before := getUsers()
+
+		if err := admin.Users.Save(user, password, admin.Controller.Database); err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+			return
+		}
+
+		admin.Controller.AuditLog.Record(r, self, AuditLogActionUserSave, GetAuditLogChanges(before, getUsers()))
+
+		admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("admin user=\"%s\" role=%s saved by user=\"%s\"", user.Username, user.Role, self.Username))
+
+		if user.Disabled {
+			if err := admin.Sessions.DeleteSubject(fmt.Sprintf("user:%s", user.Username), admin.Controller.Database); err != nil {
+				logError(err)
+			}
+		}
+
+		sendUsers()
+
+	case http.MethodDelete:
+		if !admin.CheckWritable(w) {
+			return
+		}
+
+		username := r.URL.Query().Get("username")
+		if len(username) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		before := getUsers()
+
+		if err := admin.Users.Delete(username, admin.Controller.Database); err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		admin.Controller.AuditLog.Record(r, self, AuditLogActionUserDelete, GetAuditLogChanges(before, getUsers()))
+
+		admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("admin user=\"%s\" deleted by user=\"%s\"", username, self.Username))
+
+		if err := admin.Sessions.DeleteSubject(fmt.Sprintf("user:%s", username), admin.Controller.Database); err != nil {
+			logError(err)
+		}
+
+		sendUsers()
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
 	}
+}
 
-	return token.Valid
+func (admin *Admin) ValidateToken(sToken string) bool {
+	_, ok := admin.GetTokenUser(sToken)
+	return ok
+}
+
+func (admin *Admin) ValidateTokenRole(sToken string, role string) (*AdminUser, bool) {
+	user, ok := admin.GetTokenUser(sToken)
+	if !ok || !user.HasRole(role) {
+		return nil, false
+	}
+	return user, true
 }
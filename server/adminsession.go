@@ -0,0 +1,259 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const AdminSessionRefreshSize = 32
+
+type AdminSession struct {
+	Expires time.Time
+	Id      string
+	Subject string
+	refresh string
+}
+
+type AdminSessions struct {
+	List  map[string]*AdminSession
+	mutex sync.Mutex
+}
+
+func NewAdminSessions() *AdminSessions {
+	return &AdminSessions{
+		List:  map[string]*AdminSession{},
+		mutex: sync.Mutex{},
+	}
+}
+
+func (sessions *AdminSessions) Create(subject string, ttl time.Duration, db *Database) (*AdminSession, string, error) {
+	formatError := func(err error) error {
+		return fmt.Errorf("adminsessions.create: %v", err)
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, "", formatError(err)
+	}
+
+	refresh, err := newAdminSessionRefresh()
+	if err != nil {
+		return nil, "", formatError(err)
+	}
+
+	session := &AdminSession{
+		Expires: time.Now().Add(ttl),
+		Id:      id.String(),
+		Subject: subject,
+		refresh: hashAdminSessionRefresh(refresh),
+	}
+
+	sessions.mutex.Lock()
+	defer sessions.mutex.Unlock()
+
+	if err = sessions.prune(db); err != nil {
+		return nil, "", formatError(err)
+	}
+
+	if !db.Config.ReadOnly {
+		if _, err = db.Sql.Exec("insert into `rdioScannerAdminSessions` (`session`, `subject`, `refresh`, `expires`) values (?, ?, ?, ?)", session.Id, session.Subject, session.refresh, session.Expires.Unix()); err != nil {
+			return nil, "", formatError(err)
+		}
+	}
+
+	sessions.List[session.Id] = session
+
+	return session, refresh, nil
+}
+
+func (sessions *AdminSessions) Delete(id string, db *Database) error {
+	sessions.mutex.Lock()
+	defer sessions.mutex.Unlock()
+
+	delete(sessions.List, id)
+
+	if db.Config.ReadOnly {
+		return nil
+	}
+
+	if _, err := db.Sql.Exec("delete from `rdioScannerAdminSessions` where `session` = ?", id); err != nil {
+		return fmt.Errorf("adminsessions.delete: %v", err)
+	}
+
+	return nil
+}
+
+func (sessions *AdminSessions) DeleteSubject(subject string, db *Database) error {
+	sessions.mutex.Lock()
+	defer sessions.mutex.Unlock()
+
+	for id, session := range sessions.List {
+		if session.Subject == subject {
+			delete(sessions.List, id)
+		}
+	}
+
+	if db.Config.ReadOnly {
+		return nil
+	}
+
+	if _, err := db.Sql.Exec("delete from `rdioScannerAdminSessions` where `subject` = ?", subject); err != nil {
+		return fmt.Errorf("adminsessions.deletesubject: %v", err)
+	}
+
+	return nil
+}
+
+func (sessions *AdminSessions) GetSession(id string) (*AdminSession, bool) {
+	sessions.mutex.Lock()
+	defer sessions.mutex.Unlock()
+
+	session, ok := sessions.List[id]
+	if !ok || time.Now().After(session.Expires) {
+		return nil, false
+	}
+
+	return session, true
+}
+
+func (sessions *AdminSessions) Read(db *Database) error {
+	var (
+		err     error
+		expires int64
+		rows    *sql.Rows
+	)
+
+	sessions.mutex.Lock()
+	defer sessions.mutex.Unlock()
+
+	sessions.List = map[string]*AdminSession{}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("adminsessions.read: %v", err)
+	}
+
+	if rows, err = db.Sql.Query("select `session`, `subject`, `refresh`, `expires` from `rdioScannerAdminSessions` where `expires` > ?", time.Now().Unix()); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		session := &AdminSession{}
+
+		if err = rows.Scan(&session.Id, &session.Subject, &session.refresh, &expires); err != nil {
+			break
+		}
+
+		session.Expires = time.Unix(expires, 0)
+
+		sessions.List[session.Id] = session
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	return nil
+}
+
+func (sessions *AdminSessions) Rotate(refresh string, ttl time.Duration, db *Database) (*AdminSession, string, error) {
+	var session *AdminSession
+
+	formatError := func(err error) error {
+		return fmt.Errorf("adminsessions.rotate: %v", err)
+	}
+
+	hash := hashAdminSessionRefresh(refresh)
+
+	sessions.mutex.Lock()
+	defer sessions.mutex.Unlock()
+
+	for _, s := range sessions.List {
+		if s.refresh == hash {
+			session = s
+			break
+		}
+	}
+
+	if session == nil || time.Now().After(session.Expires) {
+		return nil, "", formatError(errors.New("invalid refresh token"))
+	}
+
+	next, err := newAdminSessionRefresh()
+	if err != nil {
+		return nil, "", formatError(err)
+	}
+
+	rotated := &AdminSession{
+		Expires: time.Now().Add(ttl),
+		Id:      session.Id,
+		Subject: session.Subject,
+		refresh: hashAdminSessionRefresh(next),
+	}
+
+	if !db.Config.ReadOnly {
+		if _, err = db.Sql.Exec("update `rdioScannerAdminSessions` set `refresh` = ?, `expires` = ? where `session` = ? and `refresh` = ?", rotated.refresh, rotated.Expires.Unix(), session.Id, session.refresh); err != nil {
+			return nil, "", formatError(err)
+		}
+	}
+
+	sessions.List[session.Id] = rotated
+
+	return rotated, next, nil
+}
+
+func (sessions *AdminSessions) prune(db *Database) error {
+	now := time.Now()
+
+	for id, session := range sessions.List {
+		if now.After(session.Expires) {
+			delete(sessions.List, id)
+		}
+	}
+
+	if db.Config.ReadOnly {
+		return nil
+	}
+
+	_, err := db.Sql.Exec("delete from `rdioScannerAdminSessions` where `expires` < ?", now.Unix())
+
+	return err
+}
+
+func hashAdminSessionRefresh(refresh string) string {
+	sum := sha256.Sum256([]byte(refresh))
+	return hex.EncodeToString(sum[:])
+}
+
+func newAdminSessionRefresh() (string, error) {
+	b := make([]byte, AdminSessionRefreshSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
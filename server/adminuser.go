@@ -0,0 +1,257 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	AdminRoleAdmin  string = "admin"
+	AdminRoleEditor string = "editor"
+	AdminRoleViewer string = "viewer"
+
+	AdminSecretMask = "********"
+)
+
+type AdminUser struct {
+	Id       interface{} `json:"_id"`
+	Disabled bool        `json:"disabled"`
+	Role     string      `json:"role"`
+	Username string      `json:"username"`
+	password string
+	sso      bool
+}
+
+func NewAdminUser() *AdminUser {
+	return &AdminUser{Role: AdminRoleViewer}
+}
+
+func (user *AdminUser) FromMap(m map[string]interface{}) *AdminUser {
+	switch v := m["_id"].(type) {
+	case float64:
+		user.Id = uint(v)
+	}
+
+	switch v := m["disabled"].(type) {
+	case bool:
+		user.Disabled = v
+	}
+
+	switch v := m["role"].(type) {
+	case string:
+		if getAdminRoleRank(v) > 0 {
+			user.Role = v
+		}
+	}
+
+	switch v := m["username"].(type) {
+	case string:
+		user.Username = strings.TrimSpace(v)
+	}
+
+	return user
+}
+
+func (user *AdminUser) CheckPassword(password string) bool {
+	if len(user.password) == 0 || len(password) == 0 {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.password), []byte(password)) == nil
+}
+
+func (user *AdminUser) HasRole(role string) bool {
+	return getAdminRoleRank(user.Role) >= getAdminRoleRank(role)
+}
+
+func (user *AdminUser) SetPassword(password string) error {
+	if len(password) == 0 {
+		return errors.New("password is empty")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.password = string(hash)
+
+	return nil
+}
+
+type AdminUsers struct {
+	List  []*AdminUser
+	mutex sync.Mutex
+}
+
+func NewAdminUsers() *AdminUsers {
+	return &AdminUsers{
+		List:  []*AdminUser{},
+		mutex: sync.Mutex{},
+	}
+}
+
+func (users *AdminUsers) Count() int {
+	users.mutex.Lock()
+	defer users.mutex.Unlock()
+
+	return len(users.List)
+}
+
+func (users *AdminUsers) Delete(username string, db *Database) error {
+	formatError := func(err error) error {
+		return fmt.Errorf("adminusers.delete: %v", err)
+	}
+
+	if _, err := db.Sql.Exec("delete from `rdioScannerAdminUsers` where `username` = ?", username); err != nil {
+		return formatError(err)
+	}
+
+	return users.Read(db)
+}
+
+func (users *AdminUsers) GetUser(username string) (*AdminUser, bool) {
+	users.mutex.Lock()
+	defer users.mutex.Unlock()
+
+	for _, user := range users.List {
+		if strings.EqualFold(user.Username, username) {
+			return user, true
+		}
+	}
+
+	return nil, false
+}
+
+func (users *AdminUsers) Read(db *Database) error {
+	var (
+		err  error
+		id   sql.NullFloat64
+		rows *sql.Rows
+	)
+
+	users.mutex.Lock()
+	defer users.mutex.Unlock()
+
+	users.List = []*AdminUser{}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("adminusers.read: %v", err)
+	}
+
+	if rows, err = db.Sql.Query("select `_id`, `disabled`, `password`, `role`, `username` from `rdioScannerAdminUsers`"); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		user := &AdminUser{}
+
+		if err = rows.Scan(&id, &user.Disabled, &user.password, &user.Role, &user.Username); err != nil {
+			break
+		}
+
+		if id.Valid && id.Float64 > 0 {
+			user.Id = uint(id.Float64)
+		}
+
+		if getAdminRoleRank(user.Role) == 0 {
+			user.Role = AdminRoleViewer
+		}
+
+		users.List = append(users.List, user)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	sort.Slice(users.List, func(i int, j int) bool {
+		return strings.ToLower(users.List[i].Username) < strings.ToLower(users.List[j].Username)
+	})
+
+	return nil
+}
+
+func (users *AdminUsers) Save(user *AdminUser, password string, db *Database) error {
+	var count uint
+
+	formatError := func(err error) error {
+		return fmt.Errorf("adminusers.save: %v", err)
+	}
+
+	if len(user.Username) == 0 {
+		return formatError(errors.New("username is empty"))
+	}
+
+	if len(password) > 0 {
+		if err := user.SetPassword(password); err != nil {
+			return formatError(err)
+		}
+	}
+
+	if err := db.Sql.QueryRow("select count(*) from `rdioScannerAdminUsers` where `username` = ?", user.Username).Scan(&count); err != nil {
+		return formatError(err)
+	}
+
+	if count == 0 {
+		if len(user.password) == 0 {
+			return formatError(errors.New("password is required for new users"))
+		}
+
+		if _, err := db.Sql.Exec("insert into `rdioScannerAdminUsers` (`disabled`, `password`, `role`, `username`) values (?, ?, ?, ?)", user.Disabled, user.password, user.Role, user.Username); err != nil {
+			return formatError(err)
+		}
+
+	} else if len(user.password) > 0 {
+		if _, err := db.Sql.Exec("update `rdioScannerAdminUsers` set `disabled` = ?, `password` = ?, `role` = ? where `username` = ?", user.Disabled, user.password, user.Role, user.Username); err != nil {
+			return formatError(err)
+		}
+
+	} else if _, err := db.Sql.Exec("update `rdioScannerAdminUsers` set `disabled` = ?, `role` = ? where `username` = ?", user.Disabled, user.Role, user.Username); err != nil {
+		return formatError(err)
+	}
+
+	return users.Read(db)
+}
+
+func GetSsoSubject(username string, role string) string {
+	if getAdminRoleRank(role) == 0 {
+		role = AdminRoleViewer
+	}
+
+	return fmt.Sprintf("sso:%s:%s", role, username)
+}
+
+func getAdminRoleRank(role string) int {
+	switch role {
+	case AdminRoleAdmin:
+		return 3
+	case AdminRoleEditor:
+		return 2
+	case AdminRoleViewer:
+		return 1
+	}
+	return 0
+}
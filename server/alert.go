@@ -0,0 +1,865 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AlertDeliveryEmail    string = "email"
+	AlertDeliveryPushover string = "pushover"
+	AlertDeliveryTelegram string = "telegram"
+	AlertDeliveryWebhook  string = "webhook"
+
+	AlertPushoverUrl = "https://api.pushover.net/1/messages.json"
+	AlertTelegramUrl = "https://api.telegram.org"
+
+	AlertTimeout = 10 * time.Second
+)
+
+type Alert struct {
+	Id          interface{} `json:"_id"`
+	Anomalies   bool        `json:"anomalies"`
+	BurstCount  uint        `json:"burstCount"`
+	BurstWindow uint        `json:"burstWindow"`
+	Cooldown    uint        `json:"cooldown"`
+	Delivery    string      `json:"delivery"`
+	Disabled    bool        `json:"disabled"`
+	Keywords    string      `json:"keywords"`
+	Label       string      `json:"label"`
+	Order       interface{} `json:"order"`
+	Systems     interface{} `json:"systems"`
+	Target      string      `json:"target"`
+	Units       string      `json:"units"`
+}
+
+func (alert *Alert) FromMap(m map[string]interface{}) *Alert {
+	switch v := m["_id"].(type) {
+	case float64:
+		alert.Id = uint(v)
+	}
+
+	switch v := m["anomalies"].(type) {
+	case bool:
+		alert.Anomalies = v
+	}
+
+	switch v := m["burstCount"].(type) {
+	case float64:
+		alert.BurstCount = uint(v)
+	}
+
+	switch v := m["burstWindow"].(type) {
+	case float64:
+		alert.BurstWindow = uint(v)
+	}
+
+	switch v := m["cooldown"].(type) {
+	case float64:
+		alert.Cooldown = uint(v)
+	}
+
+	switch v := m["delivery"].(type) {
+	case string:
+		alert.Delivery = v
+	}
+
+	switch v := m["disabled"].(type) {
+	case bool:
+		alert.Disabled = v
+	}
+
+	switch v := m["keywords"].(type) {
+	case string:
+		alert.Keywords = v
+	}
+
+	switch v := m["label"].(type) {
+	case string:
+		alert.Label = v
+	}
+
+	switch v := m["order"].(type) {
+	case float64:
+		alert.Order = uint(v)
+	}
+
+	switch v := m["systems"].(type) {
+	case []interface{}:
+		if b, err := json.Marshal(v); err == nil {
+			alert.Systems = string(b)
+		}
+	case string:
+		alert.Systems = v
+	}
+
+	switch v := m["target"].(type) {
+	case string:
+		alert.Target = v
+	}
+
+	switch v := m["units"].(type) {
+	case string:
+		alert.Units = v
+	}
+
+	return alert
+}
+
+func (alert *Alert) GetKeywords() []string {
+	keywords := []string{}
+
+	for _, s := range strings.Split(alert.Keywords, ",") {
+		if s = strings.ToLower(strings.TrimSpace(s)); len(s) > 0 {
+			keywords = append(keywords, s)
+		}
+	}
+
+	return keywords
+}
+
+func (alert *Alert) GetUnits() []uint {
+	units := []uint{}
+
+	for _, s := range strings.Split(alert.Units, ",") {
+		if i, err := strconv.Atoi(strings.TrimSpace(s)); err == nil && i > 0 {
+			units = append(units, uint(i))
+		}
+	}
+
+	return units
+}
+
+func (alert *Alert) HasAccess(call *Call) bool {
+	switch v := alert.Systems.(type) {
+	case []interface{}:
+		for _, f := range v {
+			switch v := f.(type) {
+			case map[string]interface{}:
+				switch id := v["id"].(type) {
+				case float64:
+					if id == float64(call.System) {
+						switch tg := v["talkgroups"].(type) {
+						case string:
+							if tg == "*" {
+								return true
+							}
+						case []interface{}:
+							for _, f := range tg {
+								switch tg := f.(type) {
+								case float64:
+									if tg == float64(call.Talkgroup) {
+										return true
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+
+	case string:
+		if v == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (alert *Alert) HasSystem(system uint) bool {
+	switch v := alert.Systems.(type) {
+	case []interface{}:
+		for _, f := range v {
+			switch v := f.(type) {
+			case map[string]interface{}:
+				switch id := v["id"].(type) {
+				case float64:
+					if id == float64(system) {
+						return true
+					}
+				}
+			}
+		}
+
+	case string:
+		if v == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (alert *Alert) MatchKeyword(transcript string) (string, bool) {
+	transcript = strings.ToLower(transcript)
+
+	for _, keyword := range alert.GetKeywords() {
+		if regexp.MustCompile(`\b` + regexp.QuoteMeta(keyword) + `\b`).MatchString(transcript) {
+			return keyword, true
+		}
+	}
+
+	return "", false
+}
+
+func (alert *Alert) MatchUnit(call *Call) bool {
+	units := alert.GetUnits()
+
+	if len(units) == 0 {
+		return true
+	}
+
+	sources := []uint{}
+
+	switch v := call.Source.(type) {
+	case uint:
+		sources = append(sources, v)
+	}
+
+	switch v := call.Sources.(type) {
+	case []map[string]interface{}:
+		for _, s := range v {
+			switch src := s["src"].(type) {
+			case uint:
+				sources = append(sources, src)
+			case float64:
+				sources = append(sources, uint(src))
+			}
+		}
+	}
+
+	for _, unit := range units {
+		for _, source := range sources {
+			if unit == source {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (alert *Alert) Validate() error {
+	switch alert.Delivery {
+	case AlertDeliveryEmail, AlertDeliveryPushover, AlertDeliveryTelegram:
+		if len(alert.Target) == 0 {
+			return errors.New("no target")
+		}
+
+	case AlertDeliveryWebhook:
+		if u, err := url.Parse(alert.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("invalid webhook url %s", alert.Target)
+		}
+
+	default:
+		return fmt.Errorf("unknown delivery %s", alert.Delivery)
+	}
+
+	return nil
+}
+
+type AlertState struct {
+	Calls []time.Time
+	Fired time.Time
+}
+
+type Alerts struct {
+	List       []*Alert
+	States     map[uint]*AlertState
+	controller *Controller
+	mutex      sync.Mutex
+}
+
+func NewAlerts(controller *Controller) *Alerts {
+	return &Alerts{
+		List:       []*Alert{},
+		States:     map[uint]*AlertState{},
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (alerts *Alerts) CheckAnomaly(anomaly *Anomaly) {
+	list := []*Alert{}
+
+	alerts.mutex.Lock()
+
+	for _, alert := range alerts.List {
+		if alert.Disabled || !alert.Anomalies {
+			continue
+		}
+
+		if anomaly.Talkgroup == 0 {
+			if !alert.HasSystem(anomaly.System) {
+				continue
+			}
+		} else if !alert.HasAccess(&Call{System: anomaly.System, Talkgroup: anomaly.Talkgroup}) {
+			continue
+		}
+
+		list = append(list, alert)
+	}
+
+	alerts.mutex.Unlock()
+
+	call := &Call{
+		DateTime:       anomaly.DateTime,
+		System:         anomaly.System,
+		Talkgroup:      anomaly.Talkgroup,
+		systemLabel:    anomaly.SystemLabel,
+		talkgroupLabel: anomaly.TalkgroupLabel,
+	}
+
+	for _, alert := range list {
+		go func(alert *Alert) {
+			logEvent := func(logLevel string, message string) {
+				alerts.controller.Logs.LogEvent(logLevel, fmt.Sprintf("alert: label=\"%s\" system=%v talkgroup=%v %s via %s %s", alert.Label, anomaly.System, anomaly.Talkgroup, anomaly.Reason, alert.Delivery, message))
+			}
+
+			if err := alerts.Send(alert, call, anomaly.Reason); err == nil {
+				logEvent(LogLevelInfo, "sent")
+			} else {
+				logEvent(LogLevelError, err.Error())
+			}
+		}(alert)
+	}
+}
+
+func (alerts *Alerts) CheckCall(call *Call) {
+	alerts.check(call, "")
+}
+
+func (alerts *Alerts) CheckTranscript(call *Call) {
+	if len(call.transcript) == 0 {
+		return
+	}
+
+	alerts.check(call, call.transcript)
+}
+
+func (alerts *Alerts) FromMap(f []interface{}) *Alerts {
+	alerts.mutex.Lock()
+	defer alerts.mutex.Unlock()
+
+	alerts.List = []*Alert{}
+
+	for _, r := range f {
+		switch m := r.(type) {
+		case map[string]interface{}:
+			alert := &Alert{}
+			alert.FromMap(m)
+			alerts.List = append(alerts.List, alert)
+		}
+	}
+
+	return alerts
+}
+
+func (alerts *Alerts) Read(db *Database) error {
+	var (
+		err     error
+		id      sql.NullFloat64
+		order   sql.NullFloat64
+		rows    *sql.Rows
+		systems string
+	)
+
+	alerts.mutex.Lock()
+	defer alerts.mutex.Unlock()
+
+	alerts.List = []*Alert{}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("alerts.read: %v", err)
+	}
+
+	if rows, err = db.Sql.Query("select `_id`, `anomalies`, `burstCount`, `burstWindow`, `cooldown`, `delivery`, `disabled`, `keywords`, `label`, `order`, `systems`, `target`, `units` from `rdioScannerAlerts`"); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		alert := &Alert{}
+
+		if err = rows.Scan(&id, &alert.Anomalies, &alert.BurstCount, &alert.BurstWindow, &alert.Cooldown, &alert.Delivery, &alert.Disabled, &alert.Keywords, &alert.Label, &order, &systems, &alert.Target, &alert.Units); err != nil {
+			break
+		}
+
+		if id.Valid && id.Float64 > 0 {
+			alert.Id = uint(id.Float64)
+		}
+
+		if order.Valid && order.Float64 > 0 {
+			alert.Order = uint(order.Float64)
+		}
+
+		if json.Unmarshal([]byte(systems), &alert.Systems) != nil {
+			alert.Systems = []interface{}{}
+		}
+
+		alerts.List = append(alerts.List, alert)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	for k := range alerts.States {
+		found := false
+		for _, alert := range alerts.List {
+			if alert.Id == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			delete(alerts.States, k)
+		}
+	}
+
+	return nil
+}
+
+func (alerts *Alerts) Send(alert *Alert, call *Call, reason string) error {
+	return alerts.send(alert, call, reason, nil)
+}
+
+func (alerts *Alerts) Test(alert *Alert, call *Call) *DeliveryTest {
+	test := &DeliveryTest{Delivery: alert.Delivery, Target: alert.Target}
+
+	start := time.Now()
+
+	if err := alerts.send(alert, call, "test", test); err != nil {
+		test.Error = err.Error()
+	} else {
+		test.Success = true
+	}
+
+	test.Duration = uint(time.Since(start).Milliseconds())
+
+	return test
+}
+
+func (alerts *Alerts) Validate() error {
+	for _, alert := range alerts.List {
+		if err := alert.Validate(); err != nil {
+			return fmt.Errorf("alert %s: %v", alert.Label, err)
+		}
+	}
+
+	return nil
+}
+
+func (alerts *Alerts) Write(db *Database) error {
+	var (
+		count   uint
+		err     error
+		rows    *sql.Rows
+		rowIds  = []uint{}
+		systems interface{}
+	)
+
+	alerts.mutex.Lock()
+	defer alerts.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("alerts.write: %v", err)
+	}
+
+	for _, alert := range alerts.List {
+		switch alert.Systems {
+		case "*":
+			systems = `"*"`
+		default:
+			systems = alert.Systems
+		}
+
+		if err = db.Sql.QueryRow("select count(*) from `rdioScannerAlerts` where `_id` = ?", alert.Id).Scan(&count); err != nil {
+			break
+		}
+
+		if count == 0 {
+			if _, err = db.Sql.Exec("insert into `rdioScannerAlerts` (`_id`, `anomalies`, `burstCount`, `burstWindow`, `cooldown`, `delivery`, `disabled`, `keywords`, `label`, `order`, `systems`, `target`, `units`) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", alert.Id, alert.Anomalies, alert.BurstCount, alert.BurstWindow, alert.Cooldown, alert.Delivery, alert.Disabled, alert.Keywords, alert.Label, alert.Order, systems, alert.Target, alert.Units); err != nil {
+				break
+			}
+
+		} else if _, err = db.Sql.Exec("update `rdioScannerAlerts` set `_id` = ?, `anomalies` = ?, `burstCount` = ?, `burstWindow` = ?, `cooldown` = ?, `delivery` = ?, `disabled` = ?, `keywords` = ?, `label` = ?, `order` = ?, `systems` = ?, `target` = ?, `units` = ? where `_id` = ?", alert.Id, alert.Anomalies, alert.BurstCount, alert.BurstWindow, alert.Cooldown, alert.Delivery, alert.Disabled, alert.Keywords, alert.Label, alert.Order, systems, alert.Target, alert.Units, alert.Id); err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if rows, err = db.Sql.Query("select `_id` from `rdioScannerAlerts`"); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		var rowId uint
+		if err = rows.Scan(&rowId); err != nil {
+			break
+		}
+		remove := true
+		for _, alert := range alerts.List {
+			if alert.Id == nil || alert.Id == rowId {
+				remove = false
+				break
+			}
+		}
+		if remove {
+			rowIds = append(rowIds, rowId)
+		}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if len(rowIds) > 0 {
+		if b, err := json.Marshal(rowIds); err == nil {
+			s := string(b)
+			s = strings.ReplaceAll(s, "[", "(")
+			s = strings.ReplaceAll(s, "]", ")")
+			q := fmt.Sprintf("delete from `rdioScannerAlerts` where `_id` in %v", s)
+			if _, err = db.Sql.Exec(q); err != nil {
+				return formatError(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (alerts *Alerts) check(call *Call, transcript string) {
+	type trigger struct {
+		alert  *Alert
+		reason string
+	}
+
+	triggers := []trigger{}
+
+	alerts.mutex.Lock()
+
+	now := time.Now()
+
+	for _, alert := range alerts.List {
+		id, ok := alert.Id.(uint)
+		if !ok || alert.Disabled || alert.Anomalies || !alert.HasAccess(call) || !alert.MatchUnit(call) {
+			continue
+		}
+
+		keywords := alert.GetKeywords()
+
+		reason := ""
+
+		if len(transcript) > 0 {
+			if len(keywords) == 0 {
+				continue
+			}
+			keyword, ok := alert.MatchKeyword(transcript)
+			if !ok {
+				continue
+			}
+			reason = fmt.Sprintf("keyword \"%s\"", keyword)
+
+		} else if len(keywords) > 0 {
+			continue
+		}
+
+		state := alerts.States[id]
+		if state == nil {
+			state = &AlertState{Calls: []time.Time{}}
+			alerts.States[id] = state
+		}
+
+		if alert.BurstCount > 1 && alert.BurstWindow > 0 {
+			window := time.Duration(alert.BurstWindow) * time.Second
+
+			calls := []time.Time{}
+			for _, t := range state.Calls {
+				if now.Sub(t) <= window {
+					calls = append(calls, t)
+				}
+			}
+			state.Calls = append(calls, now)
+
+			if uint(len(state.Calls)) < alert.BurstCount {
+				continue
+			}
+
+			state.Calls = []time.Time{}
+
+			if len(reason) > 0 {
+				reason = fmt.Sprintf("%s, ", reason)
+			}
+			reason = fmt.Sprintf("%s%d calls within %d seconds", reason, alert.BurstCount, alert.BurstWindow)
+		}
+
+		if alert.Cooldown > 0 && now.Sub(state.Fired) < time.Duration(alert.Cooldown)*time.Second {
+			continue
+		}
+
+		state.Fired = now
+
+		if len(reason) == 0 {
+			reason = "new call"
+		}
+
+		triggers = append(triggers, trigger{alert: alert, reason: reason})
+	}
+
+	alerts.mutex.Unlock()
+
+	for _, t := range triggers {
+		go func(alert *Alert, reason string) {
+			logEvent := func(logLevel string, message string) {
+				alerts.controller.Logs.LogEvent(logLevel, fmt.Sprintf("alert: label=\"%s\" system=%v talkgroup=%v %s via %s %s", alert.Label, call.System, call.Talkgroup, reason, alert.Delivery, message))
+			}
+
+			if err := alerts.Send(alert, call, reason); err == nil {
+				logEvent(LogLevelInfo, "sent")
+			} else {
+				logEvent(LogLevelError, err.Error())
+			}
+		}(t.alert, t.reason)
+	}
+}
+
+func (alerts *Alerts) getMessage(call *Call, reason string) string {
+	var (
+		system    = fmt.Sprintf("%v", call.System)
+		talkgroup = fmt.Sprintf("%v", call.Talkgroup)
+	)
+
+	switch v := call.systemLabel.(type) {
+	case string:
+		system = v
+	}
+
+	switch v := call.talkgroupLabel.(type) {
+	case string:
+		talkgroup = v
+	}
+
+	switch v := call.talkgroupName.(type) {
+	case string:
+		if len(v) > 0 {
+			talkgroup = fmt.Sprintf("%s (%s)", talkgroup, v)
+		}
+	}
+
+	lines := []string{
+		fmt.Sprintf("%s / %s", system, talkgroup),
+		call.DateTime.Local().Format("2006-01-02 15:04:05"),
+		fmt.Sprintf("Trigger: %s", reason),
+	}
+
+	switch v := call.Source.(type) {
+	case uint:
+		lines = append(lines, fmt.Sprintf("Unit: %v", v))
+	}
+
+	if len(call.transcript) > 0 {
+		lines = append(lines, fmt.Sprintf("Transcript: %s", call.transcript))
+	}
+
+	if s := GetWebhookAudioUrl(call, alerts.controller.Options); len(s) > 0 {
+		lines = append(lines, s)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (alerts *Alerts) post(u string, contentType string, body []byte, test *DeliveryTest) error {
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", fmt.Sprintf("rdio-scanner/%s", Version))
+
+	c := http.Client{Timeout: AlertTimeout}
+
+	res, err := c.Do(req)
+	if err != nil {
+		if e, ok := err.(*url.Error); ok {
+			return e.Err
+		}
+		return err
+	}
+
+	if test != nil {
+		test.Status = res.StatusCode
+		test.Response = ReadDeliveryTestResponse(res.Body)
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("bad status: %s", res.Status)
+	}
+
+	return nil
+}
+
+func (alerts *Alerts) send(alert *Alert, call *Call, reason string, test *DeliveryTest) error {
+	var (
+		err     error
+		message string
+		title   string
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("alerts.send: %v", err)
+	}
+
+	options := alerts.controller.Options
+
+	title = alert.Label
+	if len(title) == 0 {
+		title = "Rdio Scanner alert"
+	}
+
+	message = alerts.getMessage(call, reason)
+
+	switch alert.Delivery {
+	case AlertDeliveryEmail:
+		if len(options.AlertSmtpServer) == 0 {
+			return formatError(errors.New("no smtp server"))
+		}
+
+		from := options.AlertSmtpFrom
+		if len(from) == 0 {
+			from = options.AlertSmtpUsername
+		}
+
+		to := []string{}
+		for _, s := range strings.Split(alert.Target, ",") {
+			if s = strings.TrimSpace(s); len(s) > 0 {
+				to = append(to, s)
+			}
+		}
+
+		msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n", from, strings.Join(to, ", "), title, message)
+
+		var auth smtp.Auth
+		if len(options.AlertSmtpUsername) > 0 {
+			host := options.AlertSmtpServer
+			if i := strings.LastIndex(host, ":"); i > 0 {
+				host = host[:i]
+			}
+			auth = smtp.PlainAuth("", options.AlertSmtpUsername, options.AlertSmtpPassword, host)
+		}
+
+		if test != nil {
+			test.Payload = msg
+		}
+
+		err = smtp.SendMail(options.AlertSmtpServer, auth, from, to, []byte(msg))
+
+	case AlertDeliveryPushover:
+		if len(options.AlertPushoverToken) == 0 {
+			return formatError(errors.New("no pushover token"))
+		}
+
+		values := url.Values{
+			"message": []string{message},
+			"title":   []string{title},
+			"user":    []string{alert.Target},
+		}
+
+		if test != nil {
+			test.Payload = values.Encode()
+		}
+
+		values.Set("token", options.AlertPushoverToken)
+
+		err = alerts.post(AlertPushoverUrl, "application/x-www-form-urlencoded", []byte(values.Encode()), test)
+
+	case AlertDeliveryTelegram:
+		if len(options.AlertTelegramToken) == 0 {
+			return formatError(errors.New("no telegram token"))
+		}
+
+		var b []byte
+		if b, err = json.Marshal(map[string]interface{}{
+			"chat_id": alert.Target,
+			"text":    fmt.Sprintf("%s\n%s", title, message),
+		}); err == nil {
+			if test != nil {
+				test.Payload = string(b)
+			}
+			err = alerts.post(fmt.Sprintf("%s/bot%s/sendMessage", AlertTelegramUrl, options.AlertTelegramToken), "application/json", b, test)
+		}
+
+	case AlertDeliveryWebhook:
+		m := map[string]interface{}{
+			"alert":     alert.Label,
+			"dateTime":  call.DateTime.Format(time.RFC3339),
+			"id":        call.Id,
+			"message":   message,
+			"reason":    reason,
+			"system":    call.System,
+			"talkgroup": call.Talkgroup,
+		}
+
+		if len(call.transcript) > 0 {
+			m["transcript"] = call.transcript
+		}
+
+		if s := GetWebhookAudioUrl(call, options); len(s) > 0 {
+			m["audioUrl"] = s
+		}
+
+		var b []byte
+		if b, err = json.Marshal(m); err == nil {
+			if test != nil {
+				test.Payload = string(b)
+			}
+			err = alerts.post(alert.Target, "application/json", b, test)
+		}
+
+	default:
+		err = fmt.Errorf("unknown delivery %s", alert.Delivery)
+	}
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	return nil
+}
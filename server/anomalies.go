@@ -0,0 +1,249 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	AnomalyBaselineDays   = 28
+	AnomalyInterval       = time.Hour
+	AnomalyKindSilence    = "silence"
+	AnomalyKindVolume     = "volume"
+	AnomalyMinBaseline    = 7
+	AnomalyMinVolumeCalls = 20
+)
+
+type Anomaly struct {
+	DateTime       time.Time
+	Kind           string
+	Reason         string
+	System         uint
+	SystemLabel    string
+	Talkgroup      uint
+	TalkgroupLabel string
+}
+
+type AnomalyDetector struct {
+	controller *Controller
+	mutex      sync.Mutex
+	notified   map[string]time.Time
+	ticker     *time.Ticker
+}
+
+type anomalyStats struct {
+	first     int64
+	last      int64
+	recent    float64
+	silence   float64
+	volume    float64
+	system    uint
+	talkgroup uint
+}
+
+func NewAnomalyDetector(controller *Controller) *AnomalyDetector {
+	return &AnomalyDetector{
+		controller: controller,
+		mutex:      sync.Mutex{},
+		notified:   map[string]time.Time{},
+	}
+}
+
+func (detector *AnomalyDetector) Detect(now time.Time) ([]*Anomaly, error) {
+	var anomalies []*Anomaly
+
+	controller := detector.controller
+	db := controller.Database
+
+	formatError := func(err error) error {
+		return fmt.Errorf("anomalydetector.detect: %v", err)
+	}
+
+	silenceDays := controller.Options.AnomalySilenceDays
+	if silenceDays == 0 {
+		silenceDays = 1
+	}
+
+	factor := float64(controller.Options.AnomalyVolumeFactor)
+	if factor < 2 {
+		factor = 2
+	}
+
+	hour := now.UTC().Truncate(time.Hour).Unix() / 3600
+	recentStart := hour - 24
+	silenceStart := hour - int64(silenceDays)*24
+	volumeStart := recentStart - AnomalyBaselineDays*24
+	from := silenceStart - AnomalyBaselineDays*24
+
+	rows, err := db.Sql.Query("select `system`, `talkgroup`, min(`hour`), max(`hour`), sum(case when `hour` >= ? then `calls` else 0 end), sum(case when `hour` < ? then `calls` else 0 end), sum(case when `hour` >= ? and `hour` < ? then `calls` else 0 end) from `rdioScannerCallStats` where `hour` >= ? and `hour` < ? group by `system`, `talkgroup`", recentStart, silenceStart, volumeStart, recentStart, from, hour)
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	stats := []*anomalyStats{}
+
+	for rows.Next() {
+		s := &anomalyStats{}
+		if err = rows.Scan(&s.system, &s.talkgroup, &s.first, &s.last, &s.recent, &s.silence, &s.volume); err != nil {
+			break
+		}
+		stats = append(stats, s)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	systems := map[uint][]*anomalyStats{}
+	active := map[uint]bool{}
+
+	for _, s := range stats {
+		if s.last >= silenceStart {
+			active[s.system] = true
+		}
+
+		if s.last < silenceStart && s.silence/AnomalyBaselineDays >= 1 {
+			systems[s.system] = append(systems[s.system], s)
+		}
+
+		if s.recent < AnomalyMinVolumeCalls {
+			continue
+		}
+
+		first := s.first
+		if first < volumeStart {
+			first = volumeStart
+		}
+
+		days := float64(recentStart-first) / 24
+		if days < AnomalyMinBaseline {
+			continue
+		}
+
+		average := s.volume / days
+		if average > 0 && s.recent >= average*factor {
+			anomalies = append(anomalies, detector.newAnomaly(now, AnomalyKindVolume, s.system, s.talkgroup, fmt.Sprintf("%.0f calls in the last 24 hours, %.1f times the daily average of %.1f", s.recent, s.recent/average, average)))
+		}
+	}
+
+	for system, list := range systems {
+		if !active[system] && len(list) > 1 {
+			anomalies = append(anomalies, detector.newAnomaly(now, AnomalyKindSilence, system, 0, fmt.Sprintf("no calls on any talkgroup for %d days", silenceDays)))
+			continue
+		}
+
+		for _, s := range list {
+			anomalies = append(anomalies, detector.newAnomaly(now, AnomalyKindSilence, s.system, s.talkgroup, fmt.Sprintf("no calls for %d days", silenceDays)))
+		}
+	}
+
+	return anomalies, nil
+}
+
+func (detector *AnomalyDetector) Notify(now time.Time, anomalies []*Anomaly) {
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+
+	controller := detector.controller
+
+	current := map[string]bool{}
+
+	for _, anomaly := range anomalies {
+		key := fmt.Sprintf("%s:%d:%d", anomaly.Kind, anomaly.System, anomaly.Talkgroup)
+
+		current[key] = true
+
+		if t, ok := detector.notified[key]; ok && (anomaly.Kind == AnomalyKindSilence || now.Sub(t) < 24*time.Hour) {
+			continue
+		}
+
+		detector.notified[key] = now
+
+		controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("anomaly: %s / %s %s", anomaly.SystemLabel, anomaly.TalkgroupLabel, anomaly.Reason))
+
+		controller.Alerts.CheckAnomaly(anomaly)
+	}
+
+	for key := range detector.notified {
+		if !current[key] {
+			delete(detector.notified, key)
+		}
+	}
+}
+
+func (detector *AnomalyDetector) Start() {
+	run := func() {
+		controller := detector.controller
+
+		if !controller.Options.AnomalyDetection {
+			return
+		}
+
+		if ok, err := controller.Leases.Acquire(controller.Database, "anomalyDetector", LeaseTimeout); !ok {
+			if err != nil {
+				controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("anomalydetector.start: %v", err))
+			}
+			return
+		}
+
+		now := time.Now()
+
+		if anomalies, err := detector.Detect(now); err == nil {
+			detector.Notify(now, anomalies)
+		} else {
+			controller.Logs.LogEvent(LogLevelError, err.Error())
+		}
+	}
+
+	detector.ticker = time.NewTicker(AnomalyInterval)
+
+	go func() {
+		for range detector.ticker.C {
+			run()
+		}
+	}()
+}
+
+func (detector *AnomalyDetector) newAnomaly(now time.Time, kind string, systemId uint, talkgroupId uint, reason string) *Anomaly {
+	anomaly := &Anomaly{
+		DateTime:       now,
+		Kind:           kind,
+		Reason:         reason,
+		System:         systemId,
+		SystemLabel:    fmt.Sprintf("%d", systemId),
+		Talkgroup:      talkgroupId,
+		TalkgroupLabel: fmt.Sprintf("%d", talkgroupId),
+	}
+
+	if talkgroupId == 0 {
+		anomaly.TalkgroupLabel = "all talkgroups"
+	}
+
+	if system, ok := detector.controller.Systems.GetSystem(systemId); ok {
+		anomaly.SystemLabel = system.Label
+
+		if talkgroup, ok := system.Talkgroups.GetTalkgroup(talkgroupId); ok {
+			anomaly.TalkgroupLabel = talkgroup.Label
+		}
+	}
+
+	return anomaly
+}
@@ -16,14 +16,20 @@
 package main
 
 import (
+	"crypto/hmac"
+	"encoding/json"
 	"fmt"
 	"io"
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
+const ApiHistoricalTimeout = 8 * time.Second
+
 type Api struct {
 	Controller *Controller
 }
@@ -32,6 +38,61 @@ func NewApi(controller *Controller) *Api {
 	return &Api{Controller: controller}
 }
 
+func (api *Api) CallAudioHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+		if err != nil || id == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if len(api.Controller.Options.secret) == 0 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		sig := GetWebhookAudioSignature(uint(id), api.Controller.Options.secret)
+		if !hmac.Equal([]byte(sig), []byte(r.URL.Query().Get("sig"))) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		call, err := api.Controller.Calls.GetCall(uint(id), api.Controller.Database)
+		if err != nil || call == nil || len(call.Audio) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if api.Controller.IsStreamOnly(nil, call) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch v := call.AudioName.(type) {
+		case string:
+			w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": v}))
+		}
+
+		switch v := call.AudioType.(type) {
+		case string:
+			w.Header().Set("Content-Type", v)
+		default:
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(call.Audio)))
+		w.WriteHeader(http.StatusOK)
+
+		if r.Method == http.MethodGet {
+			w.Write(call.Audio)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (api *Api) CallUploadHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
@@ -40,6 +101,12 @@ func (api *Api) CallUploadHandler(w http.ResponseWriter, r *http.Request) {
 			key  string
 		)
 
+		if api.Controller.Config.ReadOnly {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("Server is in read-only mode\n"))
+			return
+		}
+
 		mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
@@ -55,6 +122,8 @@ func (api *Api) CallUploadHandler(w http.ResponseWriter, r *http.Request) {
 
 		mr := multipart.NewReader(r.Body, params["boundary"])
 
+		maxSize := api.Controller.Options.GetMaxCallSize()
+
 		for {
 			p, err := mr.NextPart()
 			if err == io.EOF {
@@ -63,9 +132,13 @@ func (api *Api) CallUploadHandler(w http.ResponseWriter, r *http.Request) {
 				continue
 			}
 
-			b, err := io.ReadAll(p)
+			b, err := ReadAllPooled(io.LimitReader(p, maxSize+1))
 			if err != nil {
 				continue
+			} else if int64(len(b)) > maxSize {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				w.Write([]byte("Call audio exceeds the maximum size\n"))
+				return
 			}
 
 			switch p.FormName() {
@@ -76,6 +149,8 @@ func (api *Api) CallUploadHandler(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
+		call.requestId = GetRequestId(r)
+
 		if ok, err := call.IsValid(); ok {
 			api.HandleCall(key, call, w)
 		} else {
@@ -93,9 +168,35 @@ func (api *Api) HandleCall(key string, call *Call, w http.ResponseWriter) {
 	msg := []byte(fmt.Sprintf("Invalid API key for system %v talkgroup %v.\n", call.System, call.Talkgroup))
 
 	if apikey, ok := api.Controller.Apikeys.GetApikey(key); ok {
-		if apikey.HasAccess(call) {
+		if apikey.HasAccess(call) && apikey.HasScope(ApikeyScopeUpload) {
+			if len(apikey.Ident) > 0 {
+				call.uploader = fmt.Sprintf("apikey %s", apikey.Ident)
+			} else {
+				call.uploader = fmt.Sprintf("apikey %v", apikey.Id)
+			}
+
+			if call.historical {
+				call.ingested = make(chan string, 1)
+			}
+
 			api.Controller.Ingest <- call
 
+			if call.historical {
+				select {
+				case outcome := <-call.ingested:
+					if call.Id == nil {
+						w.WriteHeader(http.StatusExpectationFailed)
+						w.Write([]byte(fmt.Sprintf("Call rejected: %s\n", outcome)))
+						return
+					}
+
+				case <-time.After(ApiHistoricalTimeout):
+					w.WriteHeader(http.StatusAccepted)
+					w.Write([]byte("Call queued.\n"))
+					return
+				}
+			}
+
 		} else {
 			w.WriteHeader(http.StatusUnauthorized)
 			w.Write(msg)
@@ -111,6 +212,53 @@ func (api *Api) HandleCall(key string, call *Call, w http.ResponseWriter) {
 	w.Write([]byte("Call imported successfully.\n"))
 }
 
+func (api *Api) HealthHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		status := http.StatusOK
+
+		details := api.Controller.Admin.ValidateToken(api.Controller.Admin.GetAuthorization(r))
+
+		m := map[string]interface{}{
+			"status":  "ok",
+			"version": Version,
+		}
+
+		if err := api.Controller.Database.Sql.Ping(); err != nil {
+			api.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("api.healthhandler: %s", err.Error()))
+			status = http.StatusServiceUnavailable
+			m["status"] = "unavailable"
+			if details {
+				m["error"] = err.Error()
+			}
+		}
+
+		if issues := api.Controller.Database.SchemaIssues; len(issues) > 0 && status == http.StatusOK {
+			status = http.StatusServiceUnavailable
+			m["status"] = "degraded"
+			if details {
+				m["schema"] = issues
+			}
+		}
+
+		if issues := api.Controller.Watchdog.GetIssues(); len(issues) > 0 && details {
+			m["watchdog"] = issues
+		}
+
+		if b, err := json.Marshal(m); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
 func (api *Api) TrunkRecorderCallUploadHandler(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case http.MethodPost:
@@ -119,6 +267,12 @@ func (api *Api) TrunkRecorderCallUploadHandler(w http.ResponseWriter, r *http.Re
 			key  string
 		)
 
+		if api.Controller.Config.ReadOnly {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte("Server is in read-only mode\n"))
+			return
+		}
+
 		mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
@@ -134,6 +288,8 @@ func (api *Api) TrunkRecorderCallUploadHandler(w http.ResponseWriter, r *http.Re
 
 		mr := multipart.NewReader(r.Body, params["boundary"])
 
+		maxSize := api.Controller.Options.GetMaxCallSize()
+
 		parts := map[*multipart.Part][]byte{}
 
 		for {
@@ -144,9 +300,13 @@ func (api *Api) TrunkRecorderCallUploadHandler(w http.ResponseWriter, r *http.Re
 				continue
 			}
 
-			b, err := io.ReadAll(p)
+			b, err := ReadAllPooled(io.LimitReader(p, maxSize+1))
 			if err != nil {
 				continue
+			} else if int64(len(b)) > maxSize {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				w.Write([]byte("Call audio exceeds the maximum size\n"))
+				return
 			}
 
 			switch p.FormName() {
@@ -167,6 +327,8 @@ func (api *Api) TrunkRecorderCallUploadHandler(w http.ResponseWriter, r *http.Re
 			ParseMultipartContent(call, p, b)
 		}
 
+		call.requestId = GetRequestId(r)
+
 		if ok, err := call.IsValid(); ok {
 			api.HandleCall(key, call, w)
 
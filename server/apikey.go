@@ -19,18 +19,28 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 	"sync"
 
 	"github.com/google/uuid"
 )
 
+const (
+	ApikeyScopeCallsRead   = "calls:read"
+	ApikeyScopeSystemsRead = "systems:read"
+	ApikeyScopeUpload      = "upload"
+)
+
+var ApikeyScopes = []string{ApikeyScopeCallsRead, ApikeyScopeSystemsRead, ApikeyScopeUpload}
+
 type Apikey struct {
 	Id       interface{} `json:"_id"`
 	Disabled bool        `json:"disabled"`
 	Ident    string      `json:"ident"`
 	Key      string      `json:"key"`
 	Order    interface{} `json:"order"`
+	Scopes   string      `json:"scopes"`
 	Systems  interface{} `json:"systems"`
 }
 
@@ -60,6 +70,11 @@ func (apikey *Apikey) FromMap(m map[string]interface{}) *Apikey {
 		apikey.Order = uint(v)
 	}
 
+	switch v := m["scopes"].(type) {
+	case string:
+		apikey.Scopes = v
+	}
+
 	switch v := m["systems"].(type) {
 	case []interface{}:
 		if b, err := json.Marshal(v); err == nil {
@@ -72,6 +87,68 @@ func (apikey *Apikey) FromMap(m map[string]interface{}) *Apikey {
 	return apikey
 }
 
+func (apikey *Apikey) GetScopes() []string {
+	scopes := []string{}
+
+	for _, s := range strings.Split(apikey.Scopes, ",") {
+		s = strings.ToLower(strings.TrimSpace(s))
+		for _, scope := range ApikeyScopes {
+			if s == scope {
+				scopes = append(scopes, s)
+				break
+			}
+		}
+	}
+
+	if len(scopes) == 0 {
+		scopes = append(scopes, ApikeyScopeUpload)
+	}
+
+	return scopes
+}
+
+func (apikey *Apikey) GetSqlFilter() string {
+	switch v := apikey.Systems.(type) {
+	case []interface{}:
+		a := []string{}
+		for _, f := range v {
+			switch v := f.(type) {
+			case map[string]interface{}:
+				id, ok := v["id"].(float64)
+				if !ok {
+					continue
+				}
+				switch tg := v["talkgroups"].(type) {
+				case string:
+					if tg == "*" {
+						a = append(a, fmt.Sprintf("`system` = %d", uint(id)))
+					}
+				case []interface{}:
+					ids := []string{}
+					for _, f := range tg {
+						if t, ok := f.(float64); ok {
+							ids = append(ids, fmt.Sprintf("%d", uint(t)))
+						}
+					}
+					if len(ids) > 0 {
+						a = append(a, fmt.Sprintf("(`system` = %d and `talkgroup` in (%s))", uint(id), strings.Join(ids, ", ")))
+					}
+				}
+			}
+		}
+		if len(a) > 0 {
+			return fmt.Sprintf("(%s)", strings.Join(a, " or "))
+		}
+
+	case string:
+		if v == "*" {
+			return "true"
+		}
+	}
+
+	return "false"
+}
+
 func (apikey *Apikey) HasAccess(call *Call) bool {
 	switch v := apikey.Systems.(type) {
 	case []interface{}:
@@ -110,6 +187,16 @@ func (apikey *Apikey) HasAccess(call *Call) bool {
 	return false
 }
 
+func (apikey *Apikey) HasScope(scope string) bool {
+	for _, s := range apikey.GetScopes() {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
 type Apikeys struct {
 	List  []*Apikey
 	mutex sync.Mutex
@@ -158,6 +245,7 @@ func (apikeys *Apikeys) Read(db *Database) error {
 		id      sql.NullFloat64
 		order   sql.NullFloat64
 		rows    *sql.Rows
+		scopes  sql.NullString
 		systems string
 	)
 
@@ -170,14 +258,14 @@ func (apikeys *Apikeys) Read(db *Database) error {
 		return fmt.Errorf("apikeys.read: %v", err)
 	}
 
-	if rows, err = db.Sql.Query("select `_id`, `disabled`, `ident`, `key`, `order`, `systems` from `rdioScannerApiKeys`"); err != nil {
+	if rows, err = db.Sql.Query("select `_id`, `disabled`, `ident`, `key`, `order`, `scopes`, `systems` from `rdioScannerApiKeys`"); err != nil {
 		return formatError(err)
 	}
 
 	for rows.Next() {
 		apikey := &Apikey{}
 
-		if err = rows.Scan(&id, &apikey.Disabled, &apikey.Ident, &apikey.Key, &order, &systems); err != nil {
+		if err = rows.Scan(&id, &apikey.Disabled, &apikey.Ident, &apikey.Key, &order, &scopes, &systems); err != nil {
 			break
 		}
 
@@ -197,6 +285,10 @@ func (apikeys *Apikeys) Read(db *Database) error {
 			apikey.Order = uint(order.Float64)
 		}
 
+		if scopes.Valid {
+			apikey.Scopes = scopes.String
+		}
+
 		if err = json.Unmarshal([]byte(systems), &apikey.Systems); err != nil {
 			apikey.Systems = []interface{}{}
 		}
@@ -242,11 +334,11 @@ func (apikeys *Apikeys) Write(db *Database) error {
 		}
 
 		if count == 0 {
-			if _, err = db.Sql.Exec("insert into `rdioScannerApiKeys` (`_id`, `disabled`, `ident`, `key`, `order`, `systems`) values (?, ?, ?, ?, ?, ?)", apikey.Id, apikey.Disabled, apikey.Ident, apikey.Key, apikey.Order, systems); err != nil {
+			if _, err = db.Sql.Exec("insert into `rdioScannerApiKeys` (`_id`, `disabled`, `ident`, `key`, `order`, `scopes`, `systems`) values (?, ?, ?, ?, ?, ?, ?)", apikey.Id, apikey.Disabled, apikey.Ident, apikey.Key, apikey.Order, apikey.Scopes, systems); err != nil {
 				break
 			}
 
-		} else if _, err = db.Sql.Exec("update `rdioScannerApiKeys` set `_id` = ?, `disabled` = ?, `ident` = ?, `key` = ?, `order` = ?, `systems` = ? where `_id` = ?", apikey.Id, apikey.Disabled, apikey.Ident, apikey.Key, apikey.Order, systems, apikey.Id); err != nil {
+		} else if _, err = db.Sql.Exec("update `rdioScannerApiKeys` set `_id` = ?, `disabled` = ?, `ident` = ?, `key` = ?, `order` = ?, `scopes` = ?, `systems` = ? where `_id` = ?", apikey.Id, apikey.Disabled, apikey.Ident, apikey.Key, apikey.Order, apikey.Scopes, systems, apikey.Id); err != nil {
 			break
 		}
 	}
@@ -296,3 +388,13 @@ func (apikeys *Apikeys) Write(db *Database) error {
 
 	return nil
 }
+
+func GetRequestApikey(r *http.Request) string {
+	key := r.Header.Get("X-Api-Key")
+
+	if s := r.Header.Get("Authorization"); len(key) == 0 && strings.HasPrefix(strings.ToLower(s), "bearer ") {
+		key = strings.TrimSpace(s[7:])
+	}
+
+	return key
+}
@@ -0,0 +1,978 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ApiV1ContextCount = 5
+	ApiV1DefaultLimit = 50
+	ApiV1MaxContext   = 50
+	ApiV1MaxLimit     = 500
+)
+
+var ApiV1CallFields = []string{
+	"audioName",
+	"audioType",
+	"audioUrl",
+	"dateTime",
+	"duration",
+	"frequencies",
+	"frequency",
+	"id",
+	"loudness",
+	"patches",
+	"source",
+	"sources",
+	"system",
+	"systemLabel",
+	"talkgroup",
+	"talkgroupGroup",
+	"talkgroupLabel",
+	"talkgroupName",
+	"talkgroupTag",
+	"transcript",
+	"transcriptConfidence",
+}
+
+var ApiV1PatchFields = []string{
+	"dateTime",
+	"system",
+	"systemLabel",
+	"talkgroups",
+	"updated",
+}
+
+var ApiV1SystemFields = []string{
+	"id",
+	"label",
+	"order",
+	"talkgroups",
+}
+
+var ApiV1TalkgroupFields = []string{
+	"frequency",
+	"group",
+	"id",
+	"label",
+	"name",
+	"system",
+	"tag",
+}
+
+type ApiV1Page struct {
+	Items      []map[string]interface{} `json:"items"`
+	NextCursor string                   `json:"nextCursor,omitempty"`
+}
+
+func (api *Api) V1CallHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		api.v1Error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	apikey, ok := api.v1Authorize(w, r, ApikeyScopeCallsRead)
+	if !ok {
+		return
+	}
+
+	s := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/calls/"), "/"), "/")
+	if len(s) > 2 || (len(s) == 2 && s[1] != "audio" && s[1] != "context") {
+		api.v1Error(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	id, err := strconv.ParseUint(s[0], 10, 64)
+	if err != nil || id == 0 {
+		api.v1Error(w, http.StatusBadRequest, "invalid call id")
+		return
+	}
+
+	audio := len(s) == 2 && s[1] == "audio"
+
+	var fields map[string]bool
+	if !audio {
+		if fields, err = api.v1Fields(r, ApiV1CallFields); err != nil {
+			api.v1Error(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	call, err := api.Controller.Calls.GetCall(uint(id), api.Controller.Database)
+	if err != nil {
+		api.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("api.v1: %v", err))
+		api.v1Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	if call == nil || call.DateTime.IsZero() || !apikey.HasAccess(call) {
+		api.v1Error(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if len(s) == 2 && s[1] == "context" {
+		api.v1CallContext(w, r, apikey, call, fields)
+		return
+	}
+
+	if !audio {
+		api.v1Write(w, api.v1CallMap(call, fields))
+		return
+	}
+
+	if len(call.Audio) == 0 {
+		api.v1Error(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	if api.Controller.IsStreamOnly(nil, call) {
+		api.v1Error(w, http.StatusForbidden, "audio of this call is stream only")
+		return
+	}
+
+	switch v := call.AudioName.(type) {
+	case string:
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": v}))
+	}
+
+	switch v := call.AudioType.(type) {
+	case string:
+		w.Header().Set("Content-Type", v)
+	default:
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(len(call.Audio)))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodGet {
+		w.Write(call.Audio)
+	}
+}
+
+func (api *Api) V1CallsHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		cursor uint
+		err    error
+		fields map[string]bool
+		limit  uint
+		order  string
+		where  []string
+	)
+
+	if r.Method != http.MethodGet {
+		api.v1Error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	apikey, ok := api.v1Authorize(w, r, ApikeyScopeCallsRead)
+	if !ok {
+		return
+	}
+
+	if fields, err = api.v1Fields(r, ApiV1CallFields); err != nil {
+		api.v1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if limit, err = api.v1Limit(r); err != nil {
+		api.v1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if where, order, cursor, err = api.v1CallsFilter(r); err != nil {
+		api.v1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	where = append(where, apikey.GetSqlFilter())
+
+	if cursor > 0 {
+		if order == "asc" {
+			where = append(where, fmt.Sprintf("`id` > %d", cursor))
+		} else {
+			where = append(where, fmt.Sprintf("`id` < %d", cursor))
+		}
+	}
+
+	calls, err := api.v1QueryCalls(where, fmt.Sprintf("`id` %s", order), limit+1)
+	if err != nil {
+		api.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("api.v1: %v", err))
+		api.v1Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	page := ApiV1Page{Items: []map[string]interface{}{}}
+
+	if uint(len(calls)) > limit {
+		calls = calls[:limit]
+		if id, ok := calls[len(calls)-1].Id.(uint); ok {
+			page.NextCursor = api.v1EncodeCursor(id, order)
+		}
+	}
+
+	for _, call := range calls {
+		page.Items = append(page.Items, api.v1CallMap(call, fields))
+	}
+
+	api.v1Write(w, page)
+}
+
+func (api *Api) V1PatchesHandler(w http.ResponseWriter, r *http.Request) {
+	var system uint
+
+	if r.Method != http.MethodGet {
+		api.v1Error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	apikey, ok := api.v1Authorize(w, r, ApikeyScopeSystemsRead)
+	if !ok {
+		return
+	}
+
+	fields, err := api.v1Fields(r, ApiV1PatchFields)
+	if err != nil {
+		api.v1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if s := r.URL.Query().Get("system"); len(s) > 0 {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			api.v1Error(w, http.StatusBadRequest, "invalid system")
+			return
+		}
+		system = uint(v)
+	}
+
+	patches, err := api.Controller.Patches.GetActive()
+	if err != nil {
+		api.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("api.v1: %v", err))
+		api.v1Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	items := []map[string]interface{}{}
+
+	for _, patch := range patches {
+		if system > 0 && patch.System != system {
+			continue
+		}
+
+		sys, ok := api.Controller.Systems.GetSystem(patch.System)
+		if !ok {
+			continue
+		}
+
+		talkgroups := []map[string]interface{}{}
+		for _, id := range patch.Talkgroups {
+			if !apikey.HasAccess(&Call{System: patch.System, Talkgroup: id}) {
+				continue
+			}
+
+			talkgroup := map[string]interface{}{"id": id}
+			if t, ok := sys.Talkgroups.GetTalkgroup(id); ok {
+				talkgroup["label"] = t.Label
+				talkgroup["name"] = t.Name
+			}
+			talkgroups = append(talkgroups, talkgroup)
+		}
+
+		if len(talkgroups) < 2 {
+			continue
+		}
+
+		items = append(items, api.v1Filter(map[string]interface{}{
+			"dateTime":    patch.DateTime.UTC().Format(time.RFC3339),
+			"system":      patch.System,
+			"systemLabel": sys.Label,
+			"talkgroups":  talkgroups,
+			"updated":     patch.Updated.UTC().Format(time.RFC3339),
+		}, fields))
+	}
+
+	api.v1Write(w, ApiV1Page{Items: items})
+}
+
+func (api *Api) V1SystemsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		api.v1Error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	apikey, ok := api.v1Authorize(w, r, ApikeyScopeSystemsRead)
+	if !ok {
+		return
+	}
+
+	fields, err := api.v1Fields(r, ApiV1SystemFields)
+	if err != nil {
+		api.v1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	items := []map[string]interface{}{}
+
+	for _, system := range api.v1Systems() {
+		count := 0
+		for _, talkgroup := range system.Talkgroups.List {
+			if apikey.HasAccess(&Call{System: system.Id, Talkgroup: talkgroup.Id}) {
+				count++
+			}
+		}
+
+		if count == 0 {
+			continue
+		}
+
+		items = append(items, api.v1Filter(map[string]interface{}{
+			"id":         system.Id,
+			"label":      system.Label,
+			"order":      system.Order,
+			"talkgroups": count,
+		}, fields))
+	}
+
+	api.v1Write(w, ApiV1Page{Items: items})
+}
+
+func (api *Api) V1TalkgroupsHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		offset uint
+		system uint
+	)
+
+	if r.Method != http.MethodGet {
+		api.v1Error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	apikey, ok := api.v1Authorize(w, r, ApikeyScopeSystemsRead)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+
+	fields, err := api.v1Fields(r, ApiV1TalkgroupFields)
+	if err != nil {
+		api.v1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, err := api.v1Limit(r)
+	if err != nil {
+		api.v1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if s := query.Get("cursor"); len(s) > 0 {
+		var o string
+		if offset, o, err = api.v1DecodeCursor(s); err != nil || o != "offset" {
+			api.v1Error(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+	}
+
+	if s := query.Get("system"); len(s) > 0 {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			api.v1Error(w, http.StatusBadRequest, "invalid system")
+			return
+		}
+		system = uint(v)
+	}
+
+	items := []map[string]interface{}{}
+
+	for _, sys := range api.v1Systems() {
+		if system > 0 && sys.Id != system {
+			continue
+		}
+
+		for _, talkgroup := range sys.Talkgroups.List {
+			if !apikey.HasAccess(&Call{System: sys.Id, Talkgroup: talkgroup.Id}) {
+				continue
+			}
+
+			item := map[string]interface{}{
+				"frequency": talkgroup.Frequency,
+				"id":        talkgroup.Id,
+				"label":     talkgroup.Label,
+				"name":      talkgroup.Name,
+				"system":    sys.Id,
+			}
+
+			if group, ok := api.Controller.Groups.GetGroup(talkgroup.GroupId); ok {
+				item["group"] = group.Label
+			}
+
+			if tag, ok := api.Controller.Tags.GetTag(talkgroup.TagId); ok {
+				item["tag"] = tag.Label
+			}
+
+			items = append(items, api.v1Filter(item, fields))
+		}
+	}
+
+	page := ApiV1Page{Items: []map[string]interface{}{}}
+
+	if offset < uint(len(items)) {
+		items = items[offset:]
+		if uint(len(items)) > limit {
+			items = items[:limit]
+			page.NextCursor = api.v1EncodeCursor(offset+limit, "offset")
+		}
+		page.Items = items
+	}
+
+	api.v1Write(w, page)
+}
+
+func (api *Api) V1UnitsHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		cursor uint
+		err    error
+		fields map[string]bool
+		limit  uint
+		order  string
+		where  []string
+	)
+
+	if r.Method != http.MethodGet {
+		api.v1Error(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	apikey, ok := api.v1Authorize(w, r, ApikeyScopeCallsRead)
+	if !ok {
+		return
+	}
+
+	s := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/units/"), "/"), "/")
+	if len(s) != 2 || s[1] != "calls" {
+		api.v1Error(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	unit, err := strconv.ParseUint(s[0], 10, 64)
+	if err != nil || unit == 0 {
+		api.v1Error(w, http.StatusBadRequest, "invalid unit")
+		return
+	}
+
+	if fields, err = api.v1Fields(r, ApiV1CallFields); err != nil {
+		api.v1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if limit, err = api.v1Limit(r); err != nil {
+		api.v1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if where, order, cursor, err = api.v1CallsFilter(r); err != nil {
+		api.v1Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	where = append(where, apikey.GetSqlFilter(), fmt.Sprintf("(`source` = %d or `sources` like '%%\"src\":%d,%%' or `sources` like '%%\"src\":%d}%%')", unit, unit, unit))
+
+	if cursor > 0 {
+		op := "<"
+		if order == "asc" {
+			op = ">"
+		}
+
+		dateTime := fmt.Sprintf("(select `dateTime` from `rdioScannerCalls` where `id` = %d)", cursor)
+
+		where = append(where, fmt.Sprintf("(`dateTime` %s %s or (`dateTime` = %s and `id` %s %d))", op, dateTime, dateTime, op, cursor))
+	}
+
+	calls, err := api.v1QueryCalls(where, fmt.Sprintf("`dateTime` %s, `id` %s", order, order), limit+1)
+	if err != nil {
+		api.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("api.v1: %v", err))
+		api.v1Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	page := ApiV1Page{Items: []map[string]interface{}{}}
+
+	if uint(len(calls)) > limit {
+		calls = calls[:limit]
+		if id, ok := calls[len(calls)-1].Id.(uint); ok {
+			page.NextCursor = api.v1EncodeCursor(id, order)
+		}
+	}
+
+	for _, call := range calls {
+		page.Items = append(page.Items, api.v1CallMap(call, fields))
+	}
+
+	api.v1Write(w, page)
+}
+
+func (api *Api) v1Authorize(w http.ResponseWriter, r *http.Request, scope string) (*Apikey, bool) {
+	key := GetRequestApikey(r)
+
+	if len(key) == 0 {
+		api.v1Error(w, http.StatusUnauthorized, "missing api key")
+		return nil, false
+	}
+
+	apikey, ok := api.Controller.Apikeys.GetApikey(key)
+	if !ok {
+		api.Controller.RateLimiter.Fail(GetRemoteAddr(r))
+		api.v1Error(w, http.StatusUnauthorized, "invalid api key")
+		return nil, false
+	}
+
+	if !apikey.HasScope(scope) {
+		api.v1Error(w, http.StatusForbidden, fmt.Sprintf("api key lacks the %s scope", scope))
+		return nil, false
+	}
+
+	return apikey, true
+}
+
+func (api *Api) v1CallContext(w http.ResponseWriter, r *http.Request, apikey *Apikey, call *Call, fields map[string]bool) {
+	count := uint(ApiV1ContextCount)
+
+	if s := r.URL.Query().Get("count"); len(s) > 0 {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || v == 0 {
+			api.v1Error(w, http.StatusBadRequest, "invalid count")
+			return
+		}
+		if v > ApiV1MaxContext {
+			v = ApiV1MaxContext
+		}
+		count = uint(v)
+	}
+
+	talkgroups := []uint{call.Talkgroup}
+
+	if r.URL.Query().Get("siblings") != "false" {
+		if system, ok := api.Controller.Systems.GetSystem(call.System); ok {
+			talkgroups = append(talkgroups, system.Talkgroups.GetGroupSiblings(call.Talkgroup)...)
+		}
+	}
+
+	b, err := json.Marshal(talkgroups)
+	if err != nil {
+		api.v1Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	in := strings.NewReplacer("[", "(", "]", ")").Replace(string(b))
+	dateTime := call.DateTime.Format(api.Controller.Database.DateTimeFormat)
+
+	getCalls := func(op string, order string) ([]map[string]interface{}, error) {
+		where := []string{
+			apikey.GetSqlFilter(),
+			fmt.Sprintf("`system` = %d", call.System),
+			fmt.Sprintf("`talkgroup` in %s", in),
+			fmt.Sprintf("(`dateTime` %s '%s' or (`dateTime` = '%s' and `id` %s %v))", op, dateTime, dateTime, op, call.Id),
+		}
+
+		calls, err := api.v1QueryCalls(where, fmt.Sprintf("`dateTime` %s, `id` %s", order, order), count)
+		if err != nil {
+			return nil, err
+		}
+
+		items := []map[string]interface{}{}
+
+		for _, c := range calls {
+			items = append(items, api.v1CallMap(c, fields))
+		}
+
+		return items, nil
+	}
+
+	before, err := getCalls("<", "desc")
+	if err != nil {
+		api.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("api.v1: %v", err))
+		api.v1Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	for i, j := 0, len(before)-1; i < j; i, j = i+1, j-1 {
+		before[i], before[j] = before[j], before[i]
+	}
+
+	after, err := getCalls(">", "asc")
+	if err != nil {
+		api.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("api.v1: %v", err))
+		api.v1Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	api.v1Write(w, map[string]interface{}{
+		"after":      after,
+		"before":     before,
+		"call":       api.v1CallMap(call, fields),
+		"talkgroups": talkgroups,
+	})
+}
+
+func (api *Api) v1CallMap(call *Call, fields map[string]bool) map[string]interface{} {
+	m := map[string]interface{}{
+		"dateTime":  call.DateTime.UTC().Format(time.RFC3339Nano),
+		"id":        call.Id,
+		"system":    call.System,
+		"talkgroup": call.Talkgroup,
+	}
+
+	for k, v := range map[string]interface{}{
+		"audioName":   call.AudioName,
+		"audioType":   call.AudioType,
+		"frequencies": call.Frequencies,
+		"frequency":   call.Frequency,
+		"patches":     call.Patches,
+		"source":      call.Source,
+		"sources":     call.Sources,
+	} {
+		if v != nil {
+			m[k] = v
+		}
+	}
+
+	if !api.Controller.IsStreamOnly(nil, call) {
+		m["audioUrl"] = fmt.Sprintf("%s/api/v1/calls/%v/audio", api.Controller.Config.GetBasePath(), call.Id)
+	}
+
+	if call.duration > 0 {
+		m["duration"] = call.duration
+	}
+
+	if len(call.loudness) > 0 {
+		m["loudness"] = call.loudness
+	}
+
+	if len(call.transcript) > 0 {
+		m["transcript"] = call.transcript
+	}
+
+	if call.transcriptScore != nil {
+		m["transcriptConfidence"] = call.transcriptScore
+	}
+
+	if system, ok := api.Controller.Systems.GetSystem(call.System); ok {
+		m["systemLabel"] = system.Label
+
+		if talkgroup, ok := system.Talkgroups.GetTalkgroup(call.Talkgroup); ok {
+			m["talkgroupLabel"] = talkgroup.Label
+			m["talkgroupName"] = talkgroup.Name
+
+			if group, ok := api.Controller.Groups.GetGroup(talkgroup.GroupId); ok {
+				m["talkgroupGroup"] = group.Label
+			}
+
+			if tag, ok := api.Controller.Tags.GetTag(talkgroup.TagId); ok {
+				m["talkgroupTag"] = tag.Label
+			}
+		}
+	}
+
+	return api.v1Filter(m, fields)
+}
+
+func (api *Api) v1CallsFilter(r *http.Request) (where []string, order string, cursor uint, err error) {
+	query := r.URL.Query()
+
+	switch v := strings.ToLower(query.Get("order")); v {
+	case "", "desc":
+		order = "desc"
+	case "asc":
+		order = "asc"
+	default:
+		return nil, "", 0, fmt.Errorf("invalid order")
+	}
+
+	if s := query.Get("cursor"); len(s) > 0 {
+		var o string
+		if cursor, o, err = api.v1DecodeCursor(s); err != nil || o != order {
+			return nil, "", 0, fmt.Errorf("invalid cursor")
+		}
+	}
+
+	if s := query.Get("system"); len(s) > 0 {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("invalid system")
+		}
+		where = append(where, fmt.Sprintf("`system` = %d", v))
+	}
+
+	if s := query.Get("talkgroup"); len(s) > 0 {
+		v, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("invalid talkgroup")
+		}
+		where = append(where, fmt.Sprintf("`talkgroup` = %d", v))
+	}
+
+	for _, f := range []struct {
+		name string
+		op   string
+	}{{"from", ">="}, {"to", "<="}} {
+		if s := query.Get(f.name); len(s) > 0 {
+			t, err := api.v1ParseTime(s)
+			if err != nil {
+				return nil, "", 0, fmt.Errorf("invalid %s", f.name)
+			}
+			where = append(where, fmt.Sprintf("`dateTime` %s '%s'", f.op, t.UTC().Format(api.Controller.Database.DateTimeFormat)))
+		}
+	}
+
+	return where, order, cursor, nil
+}
+
+func (api *Api) v1DecodeCursor(s string) (uint, string, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return 0, "", err
+	}
+
+	p := strings.SplitN(string(b), ":", 2)
+	if len(p) != 2 {
+		return 0, "", fmt.Errorf("invalid cursor")
+	}
+
+	v, err := strconv.ParseUint(p[0], 10, 64)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return uint(v), p[1], nil
+}
+
+func (api *Api) v1EncodeCursor(v uint, kind string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%s", v, kind)))
+}
+
+func (api *Api) v1Error(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+func (api *Api) v1Fields(r *http.Request, allowed []string) (map[string]bool, error) {
+	s := r.URL.Query().Get("fields")
+	if len(s) == 0 {
+		return nil, nil
+	}
+
+	fields := map[string]bool{}
+
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if len(f) == 0 {
+			continue
+		}
+
+		found := false
+		for _, a := range allowed {
+			if a == f {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown field %s", f)
+		}
+
+		fields[f] = true
+	}
+
+	return fields, nil
+}
+
+func (api *Api) v1Filter(m map[string]interface{}, fields map[string]bool) map[string]interface{} {
+	if fields == nil {
+		return m
+	}
+
+	for k := range m {
+		if !fields[k] {
+			delete(m, k)
+		}
+	}
+
+	return m
+}
+
+func (api *Api) v1Limit(r *http.Request) (uint, error) {
+	s := r.URL.Query().Get("limit")
+	if len(s) == 0 {
+		return ApiV1DefaultLimit, nil
+	}
+
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || v == 0 {
+		return 0, fmt.Errorf("invalid limit")
+	}
+
+	if v > ApiV1MaxLimit {
+		v = ApiV1MaxLimit
+	}
+
+	return uint(v), nil
+}
+
+func (api *Api) v1ParseTime(s string) (time.Time, error) {
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.UnixMilli(v), nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
+
+func (api *Api) v1QueryCalls(where []string, order string, limit uint) ([]*Call, error) {
+	var (
+		calls    = []*Call{}
+		dateTime interface{}
+	)
+
+	db := api.Controller.Database
+
+	q := fmt.Sprintf("select `id`, `audioName`, `audioType`, `dateTime`, `duration`, `frequencies`, `frequency`, `loudness`, `patches`, `source`, `sources`, `system`, `talkgroup`, `transcript`, `transcriptConfidence` from `rdioScannerCalls` where %s order by %s limit %d", strings.Join(where, " and "), order, limit)
+
+	rows, err := db.Sql.Query(q)
+	if err != nil {
+		return nil, fmt.Errorf("%v, %v", err, q)
+	}
+
+	for rows.Next() {
+		var (
+			audioName   sql.NullString
+			audioType   sql.NullString
+			confidence  sql.NullFloat64
+			duration    sql.NullFloat64
+			frequencies sql.NullString
+			frequency   sql.NullFloat64
+			id          uint
+			loudness    sql.NullString
+			patches     sql.NullString
+			source      sql.NullFloat64
+			sources     sql.NullString
+			transcript  sql.NullString
+		)
+
+		call := NewCall()
+
+		if err = rows.Scan(&id, &audioName, &audioType, &dateTime, &duration, &frequencies, &frequency, &loudness, &patches, &source, &sources, &call.System, &call.Talkgroup, &transcript, &confidence); err != nil {
+			break
+		}
+
+		call.Id = id
+
+		if audioName.Valid {
+			call.AudioName = audioName.String
+		}
+
+		if audioType.Valid {
+			call.AudioType = audioType.String
+		}
+
+		if t, err := db.ParseDateTime(dateTime); err == nil {
+			call.DateTime = t
+		}
+
+		if duration.Valid && duration.Float64 > 0 {
+			call.duration = uint(duration.Float64)
+		}
+
+		if frequencies.Valid && len(frequencies.String) > 0 {
+			if err := json.Unmarshal([]byte(frequencies.String), &call.Frequencies); err != nil {
+				call.Frequencies = []interface{}{}
+			}
+		}
+
+		if frequency.Valid && frequency.Float64 > 0 {
+			call.Frequency = uint(frequency.Float64)
+		}
+
+		if loudness.Valid && len(loudness.String) > 0 {
+			json.Unmarshal([]byte(loudness.String), &call.loudness)
+		}
+
+		if patches.Valid && len(patches.String) > 0 {
+			if err := json.Unmarshal([]byte(patches.String), &call.Patches); err != nil {
+				call.Patches = []interface{}{}
+			}
+		}
+
+		if source.Valid && source.Float64 > 0 {
+			call.Source = uint(source.Float64)
+		}
+
+		if sources.Valid && len(sources.String) > 0 {
+			if err := json.Unmarshal([]byte(sources.String), &call.Sources); err != nil {
+				call.Sources = []interface{}{}
+			}
+		}
+
+		if transcript.Valid {
+			call.transcript = transcript.String
+		}
+
+		if confidence.Valid {
+			call.transcriptScore = confidence.Float64
+		}
+
+		calls = append(calls, call)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return calls, nil
+}
+
+func (api *Api) v1Systems() []*System {
+	api.Controller.Systems.mutex.Lock()
+	systems := append([]*System{}, api.Controller.Systems.List...)
+	api.Controller.Systems.mutex.Unlock()
+
+	return systems
+}
+
+func (api *Api) v1Write(w http.ResponseWriter, v interface{}) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		api.v1Error(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,165 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const AudioUrlLifetime = 24 * time.Hour
+
+func (api *Api) AudioHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/api/audio/"), 10, 64)
+		if err != nil || id == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		expires, err := strconv.ParseInt(r.URL.Query().Get("exp"), 10, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if len(api.Controller.Options.secret) == 0 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		sig := GetAudioSignature(uint(id), expires, api.Controller.Options.secret)
+		if !hmac.Equal([]byte(sig), []byte(r.URL.Query().Get("sig"))) || time.Now().Unix() > expires {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		call, err := api.Controller.Calls.GetCall(uint(id), api.Controller.Database)
+		if err != nil || call == nil || len(call.Audio) == 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if api.Controller.IsStreamOnly(nil, call) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		disposition := "inline"
+		if r.URL.Query().Get("download") == "true" {
+			disposition = "attachment"
+		}
+
+		switch v := call.AudioName.(type) {
+		case string:
+			w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": v}))
+		}
+
+		switch v := call.AudioType.(type) {
+		case string:
+			w.Header().Set("Content-Type", v)
+		default:
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+
+		hash := sha256.Sum256(call.Audio)
+
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(time.Until(time.Unix(expires, 0)).Seconds())))
+		w.Header().Set("ETag", fmt.Sprintf(`"%s"`, hex.EncodeToString(hash[:16])))
+
+		http.ServeContent(w, r, "", call.DateTime, bytes.NewReader(call.Audio))
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (controller *Controller) CanSendAudioUrl(client *Client, call *Call) bool {
+	if client == nil || !client.audioUrl || len(call.Audio) == 0 {
+		return false
+	}
+
+	return controller.CanShareAudioUrl(client.Access, call)
+}
+
+func (controller *Controller) CanShareAudioUrl(access *Access, call *Call) bool {
+	if controller.IsStreamOnly(&Client{Access: access}, call) {
+		return false
+	}
+
+	if access != nil {
+		switch access.Watermark {
+		case AccessWatermarkIdent, AccessWatermarkInaudible:
+			return false
+		}
+	}
+
+	return true
+}
+
+func (controller *Controller) GetAudioUrlCall(client *Client, call *Call) *Call {
+	if !controller.CanSendAudioUrl(client, call) {
+		return call
+	}
+
+	u := GetAudioUrl(call, controller.Options.secret)
+	if len(u) == 0 {
+		return call
+	}
+
+	c := *call
+	c.audioUrl = u
+
+	return &c
+}
+
+func GetAudioSignature(id uint, expires int64, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("audio:%d:%d", id, expires)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func GetAudioUrl(call *Call, secret string) string {
+	var id uint
+
+	switch v := call.Id.(type) {
+	case uint:
+		id = v
+	default:
+		return ""
+	}
+
+	if len(secret) == 0 {
+		return ""
+	}
+
+	expires := time.Now().Add(AudioUrlLifetime).Unix()
+
+	return fmt.Sprintf("api/audio/%d?%s", id, url.Values{
+		"exp": []string{strconv.FormatInt(expires, 10)},
+		"sig": []string{GetAudioSignature(id, expires, secret)},
+	}.Encode())
+}
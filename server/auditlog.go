@@ -0,0 +1,472 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AuditLogActionAccessAdd          = "access.add"
+	AuditLogActionAccessRemove       = "access.remove"
+	AuditLogActionBackup             = "backup"
+	AuditLogActionBackupRestore      = "backup.restore"
+	AuditLogActionBanAdd             = "ban.add"
+	AuditLogActionBanRemove          = "ban.remove"
+	AuditLogActionConfig             = "config"
+	AuditLogActionImport             = "config.import"
+	AuditLogActionListenerDisconnect = "listener.disconnect"
+	AuditLogActionNotificationDelete = "notification.delete"
+	AuditLogActionNotificationRead   = "notification.read"
+	AuditLogActionPassword           = "password"
+	AuditLogActionTalkgroupsImport   = "talkgroups.import"
+	AuditLogActionTotpDisable        = "totp.disable"
+	AuditLogActionTotpEnable         = "totp.enable"
+	AuditLogActionUnitsAlias         = "units.alias"
+	AuditLogActionUnitsImport        = "units.import"
+	AuditLogActionUserDelete         = "user.delete"
+	AuditLogActionUserSave           = "user.save"
+
+	AuditLogMaxChanges = 500
+	AuditLogSecret     = "********"
+)
+
+type AuditLogChange struct {
+	From interface{} `json:"from,omitempty"`
+	Op   string      `json:"op"`
+	Path string      `json:"path"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+type AuditLogEntry struct {
+	Id       uint             `json:"_id"`
+	Action   string           `json:"action"`
+	Address  string           `json:"address"`
+	Changes  []AuditLogChange `json:"changes"`
+	DateTime time.Time        `json:"dateTime"`
+	Endpoint string           `json:"endpoint"`
+	Method   string           `json:"method"`
+	User     string           `json:"user"`
+}
+
+type AuditLogSearchOptions struct {
+	Action   interface{} `json:"action,omitempty"`
+	DateFrom interface{} `json:"dateFrom,omitempty"`
+	DateTo   interface{} `json:"dateTo,omitempty"`
+	Limit    interface{} `json:"limit,omitempty"`
+	Offset   interface{} `json:"offset,omitempty"`
+	User     interface{} `json:"user,omitempty"`
+}
+
+func (searchOptions *AuditLogSearchOptions) FromMap(m map[string]interface{}) error {
+	switch v := m["action"].(type) {
+	case string:
+		if len(v) > 0 {
+			searchOptions.Action = v
+		}
+	}
+
+	switch v := m["dateFrom"].(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			searchOptions.DateFrom = t
+		} else {
+			return fmt.Errorf("invalid dateFrom %s", v)
+		}
+	}
+
+	switch v := m["dateTo"].(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			searchOptions.DateTo = t
+		} else {
+			return fmt.Errorf("invalid dateTo %s", v)
+		}
+	}
+
+	switch v := m["limit"].(type) {
+	case float64:
+		searchOptions.Limit = uint(v)
+	}
+
+	switch v := m["offset"].(type) {
+	case float64:
+		searchOptions.Offset = uint(v)
+	}
+
+	switch v := m["user"].(type) {
+	case string:
+		if len(v) > 0 {
+			searchOptions.User = v
+		}
+	}
+
+	return nil
+}
+
+type AuditLogSearchResults struct {
+	Count   uint                   `json:"count"`
+	Entries []AuditLogEntry        `json:"entries"`
+	Options *AuditLogSearchOptions `json:"options"`
+}
+
+type AuditLog struct {
+	Controller *Controller
+	mutex      sync.Mutex
+}
+
+func NewAuditLog(controller *Controller) *AuditLog {
+	return &AuditLog{
+		Controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (auditLog *AuditLog) Record(r *http.Request, user *AdminUser, action string, changes []AuditLogChange) {
+	var (
+		b   []byte
+		err error
+	)
+
+	if auditLog.Controller.Config.ReadOnly {
+		return
+	}
+
+	if changes == nil {
+		changes = []AuditLogChange{}
+	}
+
+	username := ""
+	if user != nil {
+		username = user.Username
+	}
+
+	if b, err = json.Marshal(changes); err != nil {
+		auditLog.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("auditlog.record: %v", err))
+		return
+	}
+
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+
+	if _, err = auditLog.Controller.Database.Sql.Exec("insert into `rdioScannerAuditLog` (`action`, `address`, `changes`, `dateTime`, `endpoint`, `method`, `user`) values (?, ?, ?, ?, ?, ?, ?)", action, GetRemoteAddr(r), string(b), time.Now().UTC(), r.URL.Path, r.Method, username); err != nil {
+		auditLog.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("auditlog.record: %v", err))
+	}
+}
+
+func (auditLog *AuditLog) Search(db *Database, searchOptions *AuditLogSearchOptions) (*AuditLogSearchResults, error) {
+	var (
+		args     = []interface{}{}
+		changes  sql.NullString
+		dateTime interface{}
+		err      error
+		id       sql.NullFloat64
+		limit    uint = 200
+		offset   uint
+		rows     *sql.Rows
+		where    = "true"
+	)
+
+	auditLog.mutex.Lock()
+	defer auditLog.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("auditlog.search: %v", err)
+	}
+
+	results := &AuditLogSearchResults{
+		Entries: []AuditLogEntry{},
+		Options: searchOptions,
+	}
+
+	switch v := searchOptions.Action.(type) {
+	case string:
+		where += " and (`action` = ? or `action` like ?)"
+		args = append(args, v, v+".%")
+	}
+
+	switch v := searchOptions.DateFrom.(type) {
+	case time.Time:
+		where += " and `dateTime` >= ?"
+		args = append(args, v.UTC().Format(db.DateTimeFormat))
+	}
+
+	switch v := searchOptions.DateTo.(type) {
+	case time.Time:
+		where += " and `dateTime` <= ?"
+		args = append(args, v.UTC().Format(db.DateTimeFormat))
+	}
+
+	switch v := searchOptions.User.(type) {
+	case string:
+		where += " and `user` = ?"
+		args = append(args, v)
+	}
+
+	switch v := searchOptions.Limit.(type) {
+	case uint:
+		limit = uint(math.Min(float64(500), float64(v)))
+	}
+
+	switch v := searchOptions.Offset.(type) {
+	case uint:
+		offset = v
+	}
+
+	query := fmt.Sprintf("select count(*) from `rdioScannerAuditLog` where %s", where)
+	if err = db.Sql.QueryRow(query, args...).Scan(&results.Count); err != nil && err != sql.ErrNoRows {
+		return nil, formatError(fmt.Errorf("%v, %v", err, query))
+	}
+
+	query = fmt.Sprintf("select `_id`, `action`, `address`, `changes`, `dateTime`, `endpoint`, `method`, `user` from `rdioScannerAuditLog` where %s order by `dateTime` desc, `_id` desc limit %d offset %d", where, limit, offset)
+	if rows, err = db.Sql.Query(query, args...); err != nil {
+		return nil, formatError(fmt.Errorf("%v, %v", err, query))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := AuditLogEntry{Changes: []AuditLogChange{}}
+
+		if err = rows.Scan(&id, &entry.Action, &entry.Address, &changes, &dateTime, &entry.Endpoint, &entry.Method, &entry.User); err != nil {
+			return nil, formatError(err)
+		}
+
+		if id.Valid && id.Float64 > 0 {
+			entry.Id = uint(id.Float64)
+		}
+
+		if t, err := db.ParseDateTime(dateTime); err == nil {
+			entry.DateTime = t
+		}
+
+		if changes.Valid && len(changes.String) > 0 {
+			if err := json.Unmarshal([]byte(changes.String), &entry.Changes); err != nil {
+				auditLog.Controller.Logs.LogEvent(LogLevelError, formatError(err).Error())
+			}
+		}
+
+		results.Entries = append(results.Entries, entry)
+	}
+
+	return results, rows.Err()
+}
+
+func (admin *Admin) AuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if _, ok := admin.ValidateTokenRole(t, AdminRoleAdmin); !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		m := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		searchOptions := AuditLogSearchOptions{}
+		if err := searchOptions.FromMap(m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+			return
+		}
+
+		results, err := admin.Controller.AuditLog.Search(admin.Controller.Database, &searchOptions)
+		if err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, err.Error())
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		if b, err := json.Marshal(results); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) getAuditSnapshot(sections []string) map[string]interface{} {
+	config := admin.GetConfig()
+
+	snapshot := map[string]interface{}{}
+	for _, section := range sections {
+		if v, ok := config[section]; ok {
+			snapshot[section] = getAuditLogValue(v)
+		}
+	}
+
+	return snapshot
+}
+
+func GetAuditLogChanges(before interface{}, after interface{}) []AuditLogChange {
+	changes := []AuditLogChange{}
+
+	diffAuditLog("", getAuditLogValue(before), getAuditLogValue(after), &changes)
+
+	secrets := map[string]bool{}
+	for _, option := range GetOptionsSchema() {
+		if option.Secret {
+			secrets["options."+option.Name] = true
+		}
+	}
+
+	for i, change := range changes {
+		if secrets[change.Path] {
+			if change.From != nil {
+				changes[i].From = AuditLogSecret
+			}
+			if change.To != nil {
+				changes[i].To = AuditLogSecret
+			}
+		}
+	}
+
+	return changes
+}
+
+func diffAuditLog(path string, a interface{}, b interface{}, changes *[]AuditLogChange) {
+	if len(*changes) >= AuditLogMaxChanges {
+		return
+	}
+
+	join := func(key string) string {
+		return strings.TrimPrefix(path+"."+key, ".")
+	}
+
+	switch va := a.(type) {
+	case map[string]interface{}:
+		if vb, ok := b.(map[string]interface{}); ok {
+			keys := []string{}
+			for k := range va {
+				keys = append(keys, k)
+			}
+			for k := range vb {
+				if _, ok := va[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				diffAuditLog(join(k), va[k], vb[k], changes)
+			}
+			return
+		}
+
+	case []interface{}:
+		if vb, ok := b.([]interface{}); ok {
+			ia, okA := getAuditLogIds(va)
+			ib, okB := getAuditLogIds(vb)
+
+			if okA && okB {
+				keys := []string{}
+				for k := range ia {
+					keys = append(keys, k)
+				}
+				for k := range ib {
+					if _, ok := ia[k]; !ok {
+						keys = append(keys, k)
+					}
+				}
+				sort.Slice(keys, func(i int, j int) bool {
+					if len(keys[i]) != len(keys[j]) {
+						return len(keys[i]) < len(keys[j])
+					}
+					return keys[i] < keys[j]
+				})
+
+				for _, k := range keys {
+					diffAuditLog(fmt.Sprintf("%s[_id=%s]", path, k), ia[k], ib[k], changes)
+				}
+				return
+			}
+
+			for i := 0; i < len(va) || i < len(vb); i++ {
+				var ea, eb interface{}
+				if i < len(va) {
+					ea = va[i]
+				}
+				if i < len(vb) {
+					eb = vb[i]
+				}
+				diffAuditLog(fmt.Sprintf("%s[%d]", path, i), ea, eb, changes)
+			}
+			return
+		}
+	}
+
+	switch {
+	case a == nil && b == nil:
+	case a == nil:
+		*changes = append(*changes, AuditLogChange{Op: "add", Path: path, To: b})
+	case b == nil:
+		*changes = append(*changes, AuditLogChange{Op: "remove", Path: path, From: a})
+	case !reflect.DeepEqual(a, b):
+		*changes = append(*changes, AuditLogChange{Op: "replace", Path: path, From: a, To: b})
+	}
+}
+
+func getAuditLogIds(list []interface{}) (map[string]interface{}, bool) {
+	ids := map[string]interface{}{}
+
+	for _, f := range list {
+		m, ok := f.(map[string]interface{})
+		if !ok || m["_id"] == nil {
+			return nil, false
+		}
+
+		id := fmt.Sprintf("%v", m["_id"])
+		if _, ok := ids[id]; ok {
+			return nil, false
+		}
+
+		ids[id] = m
+	}
+
+	return ids, true
+}
+
+func getAuditLogValue(v interface{}) interface{} {
+	var f interface{}
+
+	if b, err := json.Marshal(v); err == nil {
+		json.Unmarshal(b, &f)
+	}
+
+	return f
+}
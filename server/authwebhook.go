@@ -0,0 +1,193 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	AuthWebhookCacheTtl = time.Minute
+	AuthWebhookErrorTtl = 10 * time.Second
+	AuthWebhookTimeout  = 5 * time.Second
+)
+
+type AuthWebhookEntry struct {
+	Access  *Access
+	Allowed bool
+	Err     error
+	Expires time.Time
+}
+
+type AuthWebhook struct {
+	Cache   map[string]*AuthWebhookEntry
+	noCache bool
+	options *Options
+	pending map[string]*authWebhookPending
+	mutex   sync.Mutex
+}
+
+type authWebhookPending struct {
+	done  chan struct{}
+	entry *AuthWebhookEntry
+}
+
+func NewAuthWebhook(options *Options, noCache bool) *AuthWebhook {
+	return &AuthWebhook{
+		Cache:   map[string]*AuthWebhookEntry{},
+		noCache: noCache,
+		options: options,
+		pending: map[string]*authWebhookPending{},
+		mutex:   sync.Mutex{},
+	}
+}
+
+func (webhook *AuthWebhook) Authenticate(code string, address string) (*Access, bool, error) {
+	webhook.mutex.Lock()
+
+	if entry, ok := webhook.Cache[code]; ok && time.Now().Before(entry.Expires) {
+		webhook.mutex.Unlock()
+		return entry.Access, entry.Allowed, entry.Err
+	}
+
+	if pending, ok := webhook.pending[code]; ok {
+		webhook.mutex.Unlock()
+		<-pending.done
+		return pending.entry.Access, pending.entry.Allowed, pending.entry.Err
+	}
+
+	pending := &authWebhookPending{done: make(chan struct{})}
+	webhook.pending[code] = pending
+
+	for k, entry := range webhook.Cache {
+		if time.Now().After(entry.Expires) {
+			delete(webhook.Cache, k)
+		}
+	}
+
+	webhook.mutex.Unlock()
+
+	pending.entry = webhook.request(code, address)
+
+	webhook.mutex.Lock()
+	if !webhook.noCache {
+		webhook.Cache[code] = pending.entry
+	}
+	delete(webhook.pending, code)
+	webhook.mutex.Unlock()
+
+	close(pending.done)
+
+	return pending.entry.Access, pending.entry.Allowed, pending.entry.Err
+}
+
+func (webhook *AuthWebhook) IsEnabled() bool {
+	return len(webhook.options.AuthWebhook) > 0
+}
+
+func (webhook *AuthWebhook) request(code string, address string) *AuthWebhookEntry {
+	var response struct {
+		Allowed    bool        `json:"allowed"`
+		Expiration string      `json:"expiration"`
+		Ident      string      `json:"ident"`
+		Limit      float64     `json:"limit"`
+		Systems    interface{} `json:"systems"`
+	}
+
+	fail := func(err error) *AuthWebhookEntry {
+		return &AuthWebhookEntry{
+			Err:     fmt.Errorf("authwebhook.authenticate: %v", err),
+			Expires: time.Now().Add(AuthWebhookErrorTtl),
+		}
+	}
+
+	b, err := json.Marshal(map[string]interface{}{
+		"address": address,
+		"code":    code,
+	})
+	if err != nil {
+		return fail(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook.options.AuthWebhook, bytes.NewReader(b))
+	if err != nil {
+		return fail(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("rdio-scanner/%s", Version))
+
+	if len(webhook.options.AuthWebhookSecret) > 0 {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", webhook.options.AuthWebhookSecret))
+	}
+
+	c := http.Client{Timeout: AuthWebhookTimeout}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	defer res.Body.Close()
+
+	entry := &AuthWebhookEntry{Expires: time.Now().Add(AuthWebhookCacheTtl)}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+			return fail(err)
+		}
+
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return entry
+
+	default:
+		return fail(fmt.Errorf("bad status: %s", res.Status))
+	}
+
+	if response.Allowed {
+		access := &Access{
+			Code:    code,
+			Ident:   response.Ident,
+			Systems: response.Systems,
+		}
+
+		if len(access.Ident) == 0 {
+			access.Ident = defaults.access.ident
+		}
+
+		if access.Systems == nil {
+			access.Systems = "*"
+		}
+
+		if t, err := time.Parse(time.RFC3339, response.Expiration); err == nil {
+			access.Expiration = t.UTC()
+		}
+
+		if response.Limit > 0 {
+			access.Limit = uint(response.Limit)
+		}
+
+		entry.Access = access
+		entry.Allowed = true
+	}
+
+	return entry
+}
@@ -0,0 +1,733 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BackupLocationLocal = "local"
+	BackupLocationS3    = "s3"
+
+	BackupPrefix        = "rdio-scanner-"
+	BackupRestoreSuffix = ".restore"
+)
+
+var backupNameRegexp = regexp.MustCompile(`^rdio-scanner-[0-9]{8}-[0-9]{6}\.(db|sql)$`)
+
+type Backup struct {
+	controller *Controller
+	lastError  string
+	lastRun    interface{}
+	mutex      sync.Mutex
+	running    bool
+	ticker     *time.Ticker
+}
+
+type BackupFile struct {
+	DateTime time.Time `json:"dateTime"`
+	Location string    `json:"location"`
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+}
+
+func NewBackup(controller *Controller) *Backup {
+	return &Backup{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (backup *Backup) GetStatus() map[string]interface{} {
+	backup.mutex.Lock()
+	defer backup.mutex.Unlock()
+
+	options := backup.controller.Options
+
+	m := map[string]interface{}{
+		"enabled": backup.IsEnabled(),
+		"lastRun": backup.lastRun,
+		"running": backup.running,
+	}
+
+	if len(backup.lastError) > 0 {
+		m["lastError"] = backup.lastError
+	}
+
+	if len(options.BackupSchedule) > 0 {
+		if schedule, err := ParseBackupSchedule(options.BackupSchedule); err == nil {
+			m["next"] = schedule.Next(time.Now())
+		} else {
+			m["lastError"] = err.Error()
+		}
+	}
+
+	return m
+}
+
+func (backup *Backup) IsEnabled() bool {
+	options := backup.controller.Options
+
+	return len(options.BackupDirectory) > 0 || len(options.BackupS3Bucket) > 0
+}
+
+func (backup *Backup) List() ([]BackupFile, error) {
+	options := backup.controller.Options
+
+	files := []BackupFile{}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("backup.list: %v", err)
+	}
+
+	if len(options.BackupDirectory) > 0 {
+		entries, err := os.ReadDir(backup.controller.Config.GetPath(options.BackupDirectory))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, formatError(err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || !backupNameRegexp.MatchString(entry.Name()) {
+				continue
+			}
+
+			fi, err := entry.Info()
+			if err != nil {
+				continue
+			}
+
+			files = append(files, BackupFile{
+				DateTime: fi.ModTime().UTC(),
+				Location: BackupLocationLocal,
+				Name:     entry.Name(),
+				Size:     fi.Size(),
+			})
+		}
+	}
+
+	if len(options.BackupS3Bucket) > 0 {
+		objects, err := backup.controller.Storage.ListObjects(options.BackupS3Bucket, BackupPrefix)
+		if err != nil {
+			return nil, formatError(err)
+		}
+
+		for _, object := range objects {
+			if !backupNameRegexp.MatchString(object.Key) {
+				continue
+			}
+
+			files = append(files, BackupFile{
+				DateTime: object.LastModified.UTC(),
+				Location: BackupLocationS3,
+				Name:     object.Key,
+				Size:     object.Size,
+			})
+		}
+	}
+
+	sort.SliceStable(files, func(i int, j int) bool {
+		if files[i].Name == files[j].Name {
+			return files[i].Location < files[j].Location
+		}
+		return files[i].Name > files[j].Name
+	})
+
+	return files, nil
+}
+
+func (backup *Backup) Prune() error {
+	var err error
+
+	options := backup.controller.Options
+
+	if options.BackupRetention == 0 {
+		return nil
+	}
+
+	files, err := backup.List()
+	if err != nil {
+		return fmt.Errorf("backup.prune: %v", err)
+	}
+
+	kept := map[string]uint{}
+
+	for _, file := range files {
+		kept[file.Location]++
+
+		if kept[file.Location] <= options.BackupRetention {
+			continue
+		}
+
+		switch file.Location {
+		case BackupLocationLocal:
+			if e := os.Remove(filepath.Join(backup.controller.Config.GetPath(options.BackupDirectory), file.Name)); e != nil {
+				err = e
+			}
+		case BackupLocationS3:
+			if e := backup.controller.Storage.DeleteObject(options.BackupS3Bucket, file.Name); e != nil {
+				err = e
+			}
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("backup.prune: %v", err)
+	}
+
+	return nil
+}
+
+func (backup *Backup) Restore(name string) error {
+	var (
+		err error
+		src io.ReadCloser
+	)
+
+	controller := backup.controller
+	options := controller.Options
+
+	formatError := func(err error) error {
+		return fmt.Errorf("backup.restore: %v", err)
+	}
+
+	if !backupNameRegexp.MatchString(name) {
+		return formatError(fmt.Errorf("invalid backup name %s", name))
+	}
+
+	if (controller.Config.DbType == DbTypeSqlite) != strings.HasSuffix(name, ".db") {
+		return formatError(fmt.Errorf("%s does not match the %s database type", name, controller.Config.DbType))
+	}
+
+	if err = backup.lock(); err != nil {
+		return formatError(err)
+	}
+	defer backup.unlock()
+
+	if len(options.BackupDirectory) > 0 {
+		src, err = os.Open(filepath.Join(controller.Config.GetPath(options.BackupDirectory), name))
+	} else {
+		err = os.ErrNotExist
+	}
+
+	if os.IsNotExist(err) && len(options.BackupS3Bucket) > 0 {
+		src, err = controller.Storage.GetObject(options.BackupS3Bucket, name)
+	}
+
+	if err != nil {
+		return formatError(err)
+	}
+	defer src.Close()
+
+	controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("database restore from %s", name))
+
+	controller.IngestLock()
+
+	switch controller.Config.DbType {
+	case DbTypeSqlite:
+		err = backup.restoreSqlite(src)
+	case DbTypeMariadb, DbTypeMysql:
+		err = backup.restoreMysql(src)
+	default:
+		err = fmt.Errorf("unsupported database type %s", controller.Config.DbType)
+	}
+
+	if err != nil {
+		controller.IngestUnlock()
+		return formatError(err)
+	}
+
+	time.AfterFunc(time.Second, controller.Restart)
+
+	return nil
+}
+
+func (backup *Backup) Run() (*BackupFile, error) {
+	var (
+		err  error
+		ext  = "db"
+		file = &BackupFile{}
+	)
+
+	controller := backup.controller
+	options := controller.Options
+
+	formatError := func(err error) error {
+		return fmt.Errorf("backup.run: %v", err)
+	}
+
+	if !backup.IsEnabled() {
+		return nil, formatError(errors.New("no backup directory or s3 bucket"))
+	}
+
+	if err = backup.lock(); err != nil {
+		return nil, formatError(err)
+	}
+
+	defer func() {
+		backup.mutex.Lock()
+		backup.lastRun = time.Now().UTC()
+		if err == nil {
+			backup.lastError = ""
+		} else {
+			backup.lastError = err.Error()
+		}
+		backup.mutex.Unlock()
+
+		backup.unlock()
+	}()
+
+	if controller.Config.DbType != DbTypeSqlite {
+		ext = "sql"
+	}
+
+	file.DateTime = time.Now().UTC()
+	file.Name = fmt.Sprintf("%s%s.%s", BackupPrefix, file.DateTime.Format("20060102-150405"), ext)
+
+	dir := os.TempDir()
+	if len(options.BackupDirectory) > 0 {
+		dir = controller.Config.GetPath(options.BackupDirectory)
+
+		if err = os.MkdirAll(dir, 0770); err != nil {
+			return nil, formatError(err)
+		}
+	}
+
+	tmp := filepath.Join(dir, file.Name+".tmp")
+
+	controller.IngestLock()
+
+	switch controller.Config.DbType {
+	case DbTypeSqlite:
+		_, err = controller.Database.Sql.Exec("vacuum into ?", tmp)
+	case DbTypeMariadb, DbTypeMysql:
+		err = backup.dumpMysql(tmp)
+	default:
+		err = fmt.Errorf("unsupported database type %s", controller.Config.DbType)
+	}
+
+	controller.IngestUnlock()
+
+	defer os.Remove(tmp)
+
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	fi, err := os.Stat(tmp)
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	file.Size = fi.Size()
+
+	if len(options.BackupS3Bucket) > 0 {
+		var f *os.File
+
+		if f, err = os.Open(tmp); err != nil {
+			return nil, formatError(err)
+		}
+
+		err = controller.Storage.PutObject(options.BackupS3Bucket, file.Name, f, file.Size, "application/octet-stream")
+		f.Close()
+		if err != nil {
+			return nil, formatError(err)
+		}
+
+		file.Location = BackupLocationS3
+	}
+
+	if len(options.BackupDirectory) > 0 {
+		if err = os.Rename(tmp, filepath.Join(dir, file.Name)); err != nil {
+			return nil, formatError(err)
+		}
+
+		file.Location = BackupLocationLocal
+	}
+
+	controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("database backup %s completed", file.Name))
+
+	if err = backup.Prune(); err != nil {
+		controller.Logs.LogEvent(LogLevelWarn, err.Error())
+		err = nil
+	}
+
+	return file, nil
+}
+
+func (backup *Backup) Start() {
+	backup.ticker = time.NewTicker(time.Minute)
+
+	go func() {
+		for t := range backup.ticker.C {
+			options := backup.controller.Options
+
+			if len(options.BackupSchedule) == 0 || !backup.IsEnabled() {
+				continue
+			}
+
+			schedule, err := ParseBackupSchedule(options.BackupSchedule)
+			if err != nil || !schedule.Match(t) {
+				continue
+			}
+
+			if ok, err := backup.controller.Leases.Acquire(backup.controller.Database, "backup", LeaseTimeout); !ok {
+				if err != nil {
+					backup.controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("backup.start: %v", err))
+				}
+				continue
+			}
+
+			if _, err = backup.Run(); err != nil {
+				backup.controller.Logs.LogEvent(LogLevelError, err.Error())
+			}
+		}
+	}()
+}
+
+func (backup *Backup) dumpMysql(p string) error {
+	var stderr bytes.Buffer
+
+	config := backup.controller.Config
+
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cmd := exec.Command("mysqldump", "--single-transaction", "--routines", "-h", config.DbHost, "-P", strconv.Itoa(int(config.DbPort)), "-u", config.DbUsername, config.DbName)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("MYSQL_PWD=%s", config.DbPassword))
+	cmd.Stderr = &stderr
+	cmd.Stdout = f
+
+	if err = cmd.Run(); err != nil {
+		return fmt.Errorf("mysqldump: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	return nil
+}
+
+func (backup *Backup) lock() error {
+	backup.mutex.Lock()
+	defer backup.mutex.Unlock()
+
+	if backup.running {
+		return errors.New("backup or restore already running")
+	}
+
+	backup.running = true
+
+	return nil
+}
+
+func (backup *Backup) restoreMysql(src io.Reader) error {
+	config := backup.controller.Config
+
+	cmd := exec.Command("mysql", "-h", config.DbHost, "-P", strconv.Itoa(int(config.DbPort)), "-u", config.DbUsername, config.DbName)
+	cmd.Env = append(os.Environ(), fmt.Sprintf("MYSQL_PWD=%s", config.DbPassword))
+	cmd.Stdin = src
+
+	if b, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("mysql: %v %s", err, strings.TrimSpace(string(b)))
+	}
+
+	return nil
+}
+
+func (backup *Backup) restoreSqlite(src io.Reader) error {
+	p := backup.controller.Config.GetDbFilePath() + BackupRestoreSuffix
+
+	f, err := os.Create(p + ".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, src)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		os.Remove(p + ".tmp")
+		return err
+	}
+
+	return os.Rename(p+".tmp", p)
+}
+
+func (backup *Backup) unlock() {
+	backup.mutex.Lock()
+	backup.running = false
+	backup.mutex.Unlock()
+}
+
+type BackupSchedule struct {
+	fields [5]map[int]bool
+	dom    bool
+	dow    bool
+}
+
+func ParseBackupSchedule(s string) (*BackupSchedule, error) {
+	formatError := func(err error) error {
+		return fmt.Errorf("backup schedule: %v", err)
+	}
+
+	switch strings.TrimSpace(s) {
+	case "@hourly":
+		s = "0 * * * *"
+	case "@daily", "@midnight":
+		s = "0 0 * * *"
+	case "@weekly":
+		s = "0 0 * * 0"
+	case "@monthly":
+		s = "0 0 1 * *"
+	}
+
+	parts := strings.Fields(s)
+	if len(parts) != 5 {
+		return nil, formatError(fmt.Errorf("%s is not a 5 fields cron expression", s))
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+	schedule := &BackupSchedule{
+		dom: parts[2] != "*",
+		dow: parts[4] != "*",
+	}
+
+	for i, part := range parts {
+		schedule.fields[i] = map[int]bool{}
+
+		for _, item := range strings.Split(part, ",") {
+			step := 1
+
+			if v := strings.SplitN(item, "/", 2); len(v) == 2 {
+				n, err := strconv.Atoi(v[1])
+				if err != nil || n < 1 {
+					return nil, formatError(fmt.Errorf("invalid step %s", item))
+				}
+				item, step = v[0], n
+			}
+
+			from, to := bounds[i][0], bounds[i][1]
+
+			if item != "*" {
+				v := strings.SplitN(item, "-", 2)
+
+				n, err := strconv.Atoi(v[0])
+				if err != nil {
+					return nil, formatError(fmt.Errorf("invalid value %s", item))
+				}
+				from, to = n, n
+
+				if len(v) == 2 {
+					if to, err = strconv.Atoi(v[1]); err != nil {
+						return nil, formatError(fmt.Errorf("invalid range %s", item))
+					}
+				} else if step > 1 {
+					to = bounds[i][1]
+				}
+			}
+
+			if from < bounds[i][0] || to > bounds[i][1] || from > to {
+				return nil, formatError(fmt.Errorf("%s is out of range", item))
+			}
+
+			for n := from; n <= to; n += step {
+				if i == 4 && n == 7 {
+					schedule.fields[i][0] = true
+				} else {
+					schedule.fields[i][n] = true
+				}
+			}
+		}
+	}
+
+	return schedule, nil
+}
+
+func (schedule *BackupSchedule) Match(t time.Time) bool {
+	if !schedule.fields[0][t.Minute()] || !schedule.fields[1][t.Hour()] || !schedule.fields[3][int(t.Month())] {
+		return false
+	}
+
+	dom := schedule.fields[2][t.Day()]
+	dow := schedule.fields[4][int(t.Weekday())]
+
+	if schedule.dom && schedule.dow {
+		return dom || dow
+	}
+
+	return dom && dow
+}
+
+func (schedule *BackupSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for i := 0; i < 366*24*60; i++ {
+		if schedule.Match(t) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+
+	return time.Time{}
+}
+
+func (admin *Admin) BackupRestoreHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var req struct {
+			Name string `json:"name"`
+		}
+
+		user, ok := admin.ValidateTokenRole(t, AdminRoleAdmin)
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if !admin.CheckWritable(w) {
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || !backupNameRegexp.MatchString(req.Name) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := admin.Controller.Backup.Restore(req.Name); err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, err.Error())
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusExpectationFailed)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		admin.Controller.AuditLog.Record(r, user, AuditLogActionBackupRestore, []AuditLogChange{{Op: "replace", Path: "database", To: req.Name}})
+
+		w.WriteHeader(http.StatusAccepted)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) BackupsHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.backupshandler: %s", err.Error()))
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		m := admin.Controller.Backup.GetStatus()
+
+		if files, err := admin.Controller.Backup.List(); err == nil {
+			m["backups"] = files
+		} else {
+			m["backups"] = []BackupFile{}
+			m["lastError"] = err.Error()
+		}
+
+		if b, err := json.Marshal(m); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	case http.MethodPost:
+		user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if !admin.CheckWritable(w) {
+			return
+		}
+
+		file, err := admin.Controller.Backup.Run()
+		if err != nil {
+			logError(err)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusExpectationFailed)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		admin.Controller.AuditLog.Record(r, user, AuditLogActionBackup, []AuditLogChange{{Op: "add", Path: "backups", To: file.Name}})
+
+		if b, err := json.Marshal(file); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func applyBackupRestore(dbFile string) {
+	p := dbFile + BackupRestoreSuffix
+
+	if _, err := os.Stat(p); err != nil {
+		return
+	}
+
+	for _, suffix := range []string{"-shm", "-wal"} {
+		os.Remove(dbFile + suffix)
+	}
+
+	if err := os.Rename(p, dbFile); err != nil {
+		log.Printf("backup restore: %v\n", err)
+		return
+	}
+
+	log.Printf("database restored from backup\n")
+}
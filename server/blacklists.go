@@ -16,15 +16,25 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BlacklistTypeTalkgroup = "talkgroup"
+	BlacklistTypeUnit      = "unit"
 )
 
 type Blacklists string
 
 func (blacklists *Blacklists) IsBlacklisted(id uint) bool {
 	for _, i := range strings.Split(blacklists.String(), ",") {
-		if fmt.Sprintf("%v", i) == fmt.Sprintf("%v", id) {
+		if strings.TrimSpace(i) == fmt.Sprintf("%v", id) {
 			return true
 		}
 	}
@@ -34,3 +44,130 @@ func (blacklists *Blacklists) IsBlacklisted(id uint) bool {
 func (blacklists *Blacklists) String() string {
 	return string(*blacklists)
 }
+
+type BlacklistCounter struct {
+	Count          uint64    `json:"count"`
+	DateTime       time.Time `json:"dateTime"`
+	System         uint      `json:"system"`
+	SystemLabel    string    `json:"systemLabel,omitempty"`
+	Talkgroup      uint      `json:"talkgroup"`
+	TalkgroupLabel string    `json:"talkgroupLabel,omitempty"`
+	Type           string    `json:"type"`
+	Unit           uint      `json:"unit,omitempty"`
+}
+
+type BlacklistCounters struct {
+	counters map[string]*BlacklistCounter
+	since    time.Time
+	mutex    sync.Mutex
+}
+
+func NewBlacklistCounters() *BlacklistCounters {
+	return &BlacklistCounters{
+		counters: map[string]*BlacklistCounter{},
+		since:    time.Now(),
+		mutex:    sync.Mutex{},
+	}
+}
+
+func (counters *BlacklistCounters) Add(call *Call, system *System, talkgroup *Talkgroup, unit uint) {
+	counters.mutex.Lock()
+	defer counters.mutex.Unlock()
+
+	kind := BlacklistTypeTalkgroup
+	if unit > 0 {
+		kind = BlacklistTypeUnit
+	}
+
+	key := fmt.Sprintf("%s:%d:%d:%d", kind, call.System, call.Talkgroup, unit)
+
+	counter, ok := counters.counters[key]
+	if !ok {
+		counter = &BlacklistCounter{
+			System:    call.System,
+			Talkgroup: call.Talkgroup,
+			Type:      kind,
+			Unit:      unit,
+		}
+		counters.counters[key] = counter
+	}
+
+	if system != nil {
+		counter.SystemLabel = system.Label
+	}
+
+	if talkgroup != nil {
+		counter.TalkgroupLabel = talkgroup.Label
+	}
+
+	counter.Count++
+	counter.DateTime = time.Now().UTC()
+}
+
+func (counters *BlacklistCounters) GetStatus() map[string]interface{} {
+	counters.mutex.Lock()
+	defer counters.mutex.Unlock()
+
+	list := []BlacklistCounter{}
+	totals := map[string]uint64{BlacklistTypeTalkgroup: 0, BlacklistTypeUnit: 0}
+
+	for _, counter := range counters.counters {
+		list = append(list, *counter)
+		totals[counter.Type] += counter.Count
+	}
+
+	sort.Slice(list, func(i int, j int) bool {
+		if list[i].Count == list[j].Count {
+			return list[i].DateTime.After(list[j].DateTime)
+		}
+		return list[i].Count > list[j].Count
+	})
+
+	return map[string]interface{}{
+		"counters":   list,
+		"since":      counters.since,
+		"talkgroups": totals[BlacklistTypeTalkgroup],
+		"units":      totals[BlacklistTypeUnit],
+	}
+}
+
+func (counters *BlacklistCounters) Reset() {
+	counters.mutex.Lock()
+	defer counters.mutex.Unlock()
+
+	counters.counters = map[string]*BlacklistCounter{}
+	counters.since = time.Now()
+}
+
+func (admin *Admin) BlacklistsHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		if _, ok := admin.ValidateTokenRole(t, AdminRoleEditor); !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		admin.Controller.BlacklistCounters.Reset()
+
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodGet:
+		if b, err := json.Marshal(admin.Controller.BlacklistCounters.GetStatus()); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.blacklistshandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,552 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	BookmarkNoteMaxLength = 4096
+	BookmarksExportCsv    = "csv"
+	BookmarksExportNdjson = "ndjson"
+	BookmarksMaxRows      = 100000
+)
+
+type Bookmark struct {
+	Id             interface{} `json:"_id"`
+	CallDateTime   time.Time   `json:"callDateTime"`
+	CallId         uint        `json:"callId"`
+	DateTime       time.Time   `json:"dateTime"`
+	Note           string      `json:"note"`
+	Owner          string      `json:"owner"`
+	Shared         bool        `json:"shared"`
+	System         uint        `json:"system"`
+	SystemLabel    string      `json:"systemLabel"`
+	Talkgroup      uint        `json:"talkgroup"`
+	TalkgroupLabel string      `json:"talkgroupLabel"`
+	Updated        time.Time   `json:"updated"`
+}
+
+type Bookmarks struct {
+	controller *Controller
+	mutex      sync.Mutex
+}
+
+type BookmarksSearchOptions struct {
+	CallId    uint   `json:"callId,omitempty"`
+	Limit     uint   `json:"limit,omitempty"`
+	Mine      bool   `json:"mine,omitempty"`
+	Offset    uint   `json:"offset,omitempty"`
+	Query     string `json:"q,omitempty"`
+	System    uint   `json:"system,omitempty"`
+	Talkgroup uint   `json:"talkgroup,omitempty"`
+	client    *Client
+	owner     string
+}
+
+type BookmarksSearchResults struct {
+	Count     uint                    `json:"count"`
+	Bookmarks []Bookmark              `json:"bookmarks"`
+	Options   *BookmarksSearchOptions `json:"options"`
+}
+
+func NewBookmarks(controller *Controller) *Bookmarks {
+	return &Bookmarks{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (bookmarks *Bookmarks) Delete(id uint, owner string, moderator bool) (bool, error) {
+	var (
+		err error
+		res sql.Result
+	)
+
+	bookmarks.mutex.Lock()
+	defer bookmarks.mutex.Unlock()
+
+	db := bookmarks.controller.Database
+
+	if moderator {
+		res, err = db.Sql.Exec("delete from `rdioScannerBookmarks` where `_id` = ? and (`owner` = ? or `shared` = 1)", id, owner)
+	} else {
+		res, err = db.Sql.Exec("delete from `rdioScannerBookmarks` where `_id` = ? and `owner` = ?", id, owner)
+	}
+	if err != nil {
+		return false, fmt.Errorf("bookmarks.delete: %v", err)
+	}
+
+	count, _ := res.RowsAffected()
+
+	return count > 0, nil
+}
+
+func (bookmarks *Bookmarks) Export(options *BookmarksSearchOptions, fn func(bookmark Bookmark) error) error {
+	bookmarks.mutex.Lock()
+	defer bookmarks.mutex.Unlock()
+
+	where, args := options.where(bookmarks.controller)
+
+	query := fmt.Sprintf("select b.`_id`, b.`callId`, b.`dateTime`, b.`note`, b.`owner`, b.`shared`, b.`updated`, c.`dateTime`, c.`system`, c.`talkgroup` from `rdioScannerBookmarks` as b inner join `rdioScannerCalls` as c on c.`id` = b.`callId` where %s order by c.`dateTime` asc limit %d", where, BookmarksMaxRows)
+
+	return bookmarks.scan(query, args, fn)
+}
+
+func (bookmarks *Bookmarks) Prune(db *Database) error {
+	bookmarks.mutex.Lock()
+	defer bookmarks.mutex.Unlock()
+
+	if _, err := db.Sql.Exec("delete from `rdioScannerBookmarks` where `callId` not in (select `id` from `rdioScannerCalls`)"); err != nil {
+		return fmt.Errorf("bookmarks.prune: %v", err)
+	}
+
+	return nil
+}
+
+func (bookmarks *Bookmarks) Save(callId uint, owner string, note string, shared bool, client *Client) (*Bookmark, error) {
+	var (
+		count int
+		err   error
+		id    sql.NullFloat64
+	)
+
+	bookmarks.mutex.Lock()
+	defer bookmarks.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("bookmarks.save: %v", err)
+	}
+
+	if len(owner) == 0 {
+		return nil, errors.New("no owner")
+	}
+
+	if len(note) > BookmarkNoteMaxLength {
+		return nil, fmt.Errorf("note exceeds %d characters", BookmarkNoteMaxLength)
+	}
+
+	db := bookmarks.controller.Database
+
+	call := &Call{}
+	if err = db.Sql.QueryRow("select `system`, `talkgroup` from `rdioScannerCalls` where `id` = ?", callId).Scan(&call.System, &call.Talkgroup); err == sql.ErrNoRows {
+		return nil, errors.New("call not found")
+	} else if err != nil {
+		return nil, formatError(err)
+	}
+
+	if client != nil && bookmarks.controller.Accesses.IsRestricted() && !client.Access.HasAccess(call) {
+		return nil, errors.New("call not found")
+	}
+
+	now := time.Now().UTC()
+
+	if err = db.Sql.QueryRow("select `_id` from `rdioScannerBookmarks` where `callId` = ? and `owner` = ?", callId, owner).Scan(&id); err != nil && err != sql.ErrNoRows {
+		return nil, formatError(err)
+	}
+
+	if id.Valid {
+		_, err = db.Sql.Exec("update `rdioScannerBookmarks` set `note` = ?, `shared` = ?, `updated` = ? where `_id` = ?", note, shared, now, uint(id.Float64))
+	} else {
+		if err = db.Sql.QueryRow("select count(*) from `rdioScannerBookmarks` where `owner` = ?", owner).Scan(&count); err != nil {
+			return nil, formatError(err)
+		}
+		if count >= BookmarksMaxRows {
+			return nil, errors.New("too many bookmarks")
+		}
+
+		_, err = db.Sql.Exec("insert into `rdioScannerBookmarks` (`callId`, `dateTime`, `note`, `owner`, `shared`, `updated`) values (?, ?, ?, ?, ?, ?)", callId, now, note, owner, shared, now)
+	}
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	var bookmark *Bookmark
+
+	query := "select b.`_id`, b.`callId`, b.`dateTime`, b.`note`, b.`owner`, b.`shared`, b.`updated`, c.`dateTime`, c.`system`, c.`talkgroup` from `rdioScannerBookmarks` as b inner join `rdioScannerCalls` as c on c.`id` = b.`callId` where b.`callId` = ? and b.`owner` = ?"
+	if err = bookmarks.scan(query, []interface{}{callId, owner}, func(b Bookmark) error {
+		bookmark = &b
+		return nil
+	}); err != nil {
+		return nil, formatError(err)
+	}
+
+	if bookmark == nil {
+		return nil, errors.New("bookmark not found")
+	}
+
+	return bookmark, nil
+}
+
+func (bookmarks *Bookmarks) Search(options *BookmarksSearchOptions) (*BookmarksSearchResults, error) {
+	var (
+		count uint
+		limit uint = 200
+		list       = []Bookmark{}
+	)
+
+	bookmarks.mutex.Lock()
+	defer bookmarks.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("bookmarks.search: %v", err)
+	}
+
+	if options.Limit > 0 {
+		limit = uint(math.Min(500, float64(options.Limit)))
+	}
+
+	where, args := options.where(bookmarks.controller)
+
+	query := fmt.Sprintf("select count(*) from `rdioScannerBookmarks` as b inner join `rdioScannerCalls` as c on c.`id` = b.`callId` where %s", where)
+	if err := bookmarks.controller.Database.Sql.QueryRow(query, args...).Scan(&count); err != nil {
+		return nil, formatError(fmt.Errorf("%v, %v", err, query))
+	}
+
+	query = fmt.Sprintf("select b.`_id`, b.`callId`, b.`dateTime`, b.`note`, b.`owner`, b.`shared`, b.`updated`, c.`dateTime`, c.`system`, c.`talkgroup` from `rdioScannerBookmarks` as b inner join `rdioScannerCalls` as c on c.`id` = b.`callId` where %s order by c.`dateTime` desc limit %d offset %d", where, limit, options.Offset)
+	if err := bookmarks.scan(query, args, func(bookmark Bookmark) error {
+		list = append(list, bookmark)
+		return nil
+	}); err != nil {
+		return nil, formatError(err)
+	}
+
+	return &BookmarksSearchResults{
+		Bookmarks: list,
+		Count:     count,
+		Options:   options,
+	}, nil
+}
+
+func (bookmarks *Bookmarks) scan(query string, args []interface{}, fn func(bookmark Bookmark) error) error {
+	var (
+		callDateTime interface{}
+		dateTime     interface{}
+		id           sql.NullFloat64
+		updated      interface{}
+	)
+
+	db := bookmarks.controller.Database
+
+	rows, err := db.Sql.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("%v, %v", err, query)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bookmark := Bookmark{}
+
+		if err = rows.Scan(&id, &bookmark.CallId, &dateTime, &bookmark.Note, &bookmark.Owner, &bookmark.Shared, &updated, &callDateTime, &bookmark.System, &bookmark.Talkgroup); err != nil {
+			return err
+		}
+
+		if id.Valid && id.Float64 > 0 {
+			bookmark.Id = uint(id.Float64)
+		}
+
+		if t, err := db.ParseDateTime(callDateTime); err == nil {
+			bookmark.CallDateTime = t
+		}
+
+		if t, err := db.ParseDateTime(dateTime); err == nil {
+			bookmark.DateTime = t
+		}
+
+		if t, err := db.ParseDateTime(updated); err == nil {
+			bookmark.Updated = t
+		}
+
+		if system, ok := bookmarks.controller.Systems.GetSystem(bookmark.System); ok {
+			bookmark.SystemLabel = system.Label
+			if talkgroup, ok := system.Talkgroups.GetTalkgroup(bookmark.Talkgroup); ok {
+				bookmark.TalkgroupLabel = talkgroup.Label
+			}
+		}
+
+		if err = fn(bookmark); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (options *BookmarksSearchOptions) FromMap(m map[string]interface{}) *BookmarksSearchOptions {
+	switch v := m["callId"].(type) {
+	case float64:
+		options.CallId = uint(v)
+	}
+
+	switch v := m["limit"].(type) {
+	case float64:
+		options.Limit = uint(v)
+	}
+
+	switch v := m["mine"].(type) {
+	case bool:
+		options.Mine = v
+	}
+
+	switch v := m["offset"].(type) {
+	case float64:
+		options.Offset = uint(v)
+	}
+
+	switch v := m["q"].(type) {
+	case string:
+		options.Query = strings.TrimSpace(v)
+	}
+
+	switch v := m["system"].(type) {
+	case float64:
+		options.System = uint(v)
+	}
+
+	switch v := m["talkgroup"].(type) {
+	case float64:
+		options.Talkgroup = uint(v)
+	}
+
+	return options
+}
+
+func (options *BookmarksSearchOptions) FromQuery(r *http.Request) *BookmarksSearchOptions {
+	m := map[string]interface{}{}
+
+	q := r.URL.Query()
+
+	for _, k := range []string{"callId", "limit", "offset", "system", "talkgroup"} {
+		if i, err := strconv.Atoi(q.Get(k)); err == nil && i >= 0 {
+			m[k] = float64(i)
+		}
+	}
+
+	if b, err := strconv.ParseBool(q.Get("mine")); err == nil {
+		m["mine"] = b
+	}
+
+	if v := q.Get("q"); len(v) > 0 {
+		m["q"] = v
+	}
+
+	return options.FromMap(m)
+}
+
+func (options *BookmarksSearchOptions) where(controller *Controller) (string, []interface{}) {
+	args := []interface{}{options.owner}
+
+	where := "(b.`owner` = ? or b.`shared` = 1)"
+	if options.Mine {
+		where = "b.`owner` = ?"
+	}
+
+	if options.client != nil && controller.Accesses.IsRestricted() {
+		where += fmt.Sprintf(" and %s", controller.Calls.getSearchFilter(&CallsSearchOptions{}, options.client))
+	}
+
+	if options.CallId > 0 {
+		where += " and b.`callId` = ?"
+		args = append(args, options.CallId)
+	}
+
+	if options.System > 0 {
+		where += " and c.`system` = ?"
+		args = append(args, options.System)
+	}
+
+	if options.Talkgroup > 0 {
+		where += " and c.`talkgroup` = ?"
+		args = append(args, options.Talkgroup)
+	}
+
+	if len(options.Query) > 0 {
+		where += " and b.`note` like ?"
+		args = append(args, fmt.Sprintf("%%%s%%", options.Query))
+	}
+
+	return where, args
+}
+
+func GetAdminBookmarkOwner(user *AdminUser) string {
+	return fmt.Sprintf("admin:%s", user.Username)
+}
+
+func GetListenerBookmarkOwner(client *Client) string {
+	if client == nil || client.Access == nil || len(client.Access.Ident) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("listener:%s", client.Access.Ident)
+}
+
+func (admin *Admin) BookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	user, ok := admin.GetTokenUser(t)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	owner := GetAdminBookmarkOwner(user)
+
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.bookmarkshandler: %s", err.Error()))
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		if !admin.CheckWritable(w) {
+			return
+		}
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if ok, err := admin.Controller.Bookmarks.Delete(uint(id), owner, user.HasRole(AdminRoleEditor)); err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+		} else if !ok {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	case http.MethodGet:
+		options := (&BookmarksSearchOptions{owner: owner}).FromQuery(r)
+
+		switch format := r.URL.Query().Get("format"); format {
+		case BookmarksExportCsv, BookmarksExportNdjson:
+			admin.exportBookmarks(w, options, format)
+
+		default:
+			results, err := admin.Controller.Bookmarks.Search(options)
+			if err != nil {
+				logError(err)
+				w.WriteHeader(http.StatusExpectationFailed)
+				return
+			}
+
+			if b, err := json.Marshal(results); err == nil {
+				w.Header().Set("Cache-Control", "no-store")
+				w.Header().Set("Content-Type", "application/json")
+				w.Write(b)
+			} else {
+				logError(err)
+				w.WriteHeader(http.StatusExpectationFailed)
+			}
+		}
+
+	case http.MethodPost:
+		var req struct {
+			CallId uint   `json:"callId"`
+			Note   string `json:"note"`
+			Shared bool   `json:"shared"`
+		}
+
+		if !admin.CheckWritable(w) {
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.CallId == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		bookmark, err := admin.Controller.Bookmarks.Save(req.CallId, owner, req.Note, req.Shared, nil)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bookmark)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) exportBookmarks(w http.ResponseWriter, options *BookmarksSearchOptions, format string) {
+	var fn func(bookmark Bookmark) error
+
+	filename := fmt.Sprintf("rdio-scanner-bookmarks-%s.%s", time.Now().UTC().Format("20060102150405"), format)
+
+	switch format {
+	case BookmarksExportCsv:
+		cw := csv.NewWriter(w)
+
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+		if err := cw.Write([]string{"id", "callId", "callDateTime", "system", "systemLabel", "talkgroup", "talkgroupLabel", "owner", "shared", "note", "dateTime", "updated"}); err != nil {
+			return
+		}
+
+		fn = func(b Bookmark) error {
+			return cw.Write([]string{
+				fmt.Sprint(b.Id),
+				fmt.Sprint(b.CallId),
+				b.CallDateTime.UTC().Format(time.RFC3339),
+				fmt.Sprint(b.System),
+				b.SystemLabel,
+				fmt.Sprint(b.Talkgroup),
+				b.TalkgroupLabel,
+				b.Owner,
+				strconv.FormatBool(b.Shared),
+				b.Note,
+				b.DateTime.UTC().Format(time.RFC3339),
+				b.Updated.UTC().Format(time.RFC3339),
+			})
+		}
+
+		defer cw.Flush()
+
+	default:
+		enc := json.NewEncoder(w)
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+		fn = func(b Bookmark) error {
+			return enc.Encode(b)
+		}
+	}
+
+	if err := admin.Controller.Bookmarks.Export(options, fn); err != nil {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.exportbookmarks: %s", err.Error()))
+	}
+}
@@ -0,0 +1,467 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	BotProtocol = "rdio-scanner.bot.v1"
+
+	BotAuthTimeout    = 10 * time.Second
+	BotPingInterval   = 30 * time.Second
+	BotSendQueueSize  = 256
+	BotMaxMessageSize = 1 << 20
+
+	BotMessageAuth          = "auth"
+	BotMessageAuthenticated = "authenticated"
+	BotMessageCall          = "call"
+	BotMessageError         = "error"
+	BotMessageHello         = "hello"
+	BotMessagePing          = "ping"
+	BotMessagePong          = "pong"
+	BotMessageSubscribe     = "subscribe"
+	BotMessageSubscribed    = "subscribed"
+	BotMessageUnsubscribe   = "unsubscribe"
+
+	BotErrorForbidden      = "forbidden"
+	BotErrorInvalidMessage = "invalid_message"
+	BotErrorUnauthorized   = "unauthorized"
+)
+
+type Bot struct {
+	Controller *Controller
+	Conn       *websocket.Conn
+	Send       chan map[string]interface{}
+	address    string
+	connected  time.Time
+	id         string
+	key        string
+	matrix     map[uint]map[uint]bool
+	mutex      sync.Mutex
+	subscribed bool
+}
+
+func (bot *Bot) Init(controller *Controller, request *http.Request, conn *websocket.Conn) {
+	const writeWait = 10 * time.Second
+
+	bot.Controller = controller
+	bot.Conn = conn
+	bot.Send = make(chan map[string]interface{}, BotSendQueueSize)
+	bot.address = GetRemoteAddr(request)
+	bot.connected = time.Now()
+	bot.id = strings.Split(uuid.New().String(), "-")[0]
+
+	controller.Bots.Add(bot)
+
+	go func() {
+		defer func() {
+			recover()
+
+			controller.Bots.Remove(bot)
+		}()
+
+		bot.Conn.SetReadLimit(BotMaxMessageSize)
+
+		bot.Conn.SetReadDeadline(time.Now().Add(2 * BotPingInterval))
+
+		bot.Conn.SetPongHandler(func(string) error {
+			bot.Conn.SetReadDeadline(time.Now().Add(2 * BotPingInterval))
+			return nil
+		})
+
+		for {
+			_, b, err := bot.Conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			bot.Conn.SetReadDeadline(time.Now().Add(2 * BotPingInterval))
+
+			bot.process(b)
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(BotPingInterval)
+
+		timer := time.AfterFunc(BotAuthTimeout, func() {
+			if !bot.IsAuthenticated() {
+				bot.Conn.Close()
+			}
+		})
+
+		defer func() {
+			recover()
+
+			ticker.Stop()
+			timer.Stop()
+
+			bot.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+			bot.Conn.Close()
+		}()
+
+		for {
+			select {
+			case message, ok := <-bot.Send:
+				if !ok || message == nil {
+					return
+				}
+
+				bot.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+
+				if err := bot.Conn.WriteJSON(message); err != nil {
+					return
+				}
+
+			case <-ticker.C:
+				bot.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+
+				if err := bot.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	if key := GetRequestApikey(request); len(key) > 0 && !bot.authenticate(key, "") {
+		bot.queue(nil)
+		return
+	}
+
+	bot.queue(map[string]interface{}{
+		"type":          BotMessageHello,
+		"protocol":      BotProtocol,
+		"version":       Version,
+		"pingInterval":  BotPingInterval.Seconds(),
+		"authenticated": bot.IsAuthenticated(),
+	})
+}
+
+func (bot *Bot) GetApikey() (*Apikey, bool) {
+	bot.mutex.Lock()
+	key := bot.key
+	bot.mutex.Unlock()
+
+	if len(key) == 0 {
+		return nil, false
+	}
+
+	return bot.Controller.Apikeys.GetApikey(key)
+}
+
+func (bot *Bot) IsAuthenticated() bool {
+	bot.mutex.Lock()
+	defer bot.mutex.Unlock()
+
+	return len(bot.key) > 0
+}
+
+func (bot *Bot) IsSubscribed(call *Call) bool {
+	bot.mutex.Lock()
+	defer bot.mutex.Unlock()
+
+	if !bot.subscribed {
+		return false
+	}
+
+	if bot.matrix == nil {
+		return true
+	}
+
+	talkgroups, ok := bot.matrix[call.System]
+	if !ok {
+		return false
+	}
+
+	if talkgroups == nil || talkgroups[call.Talkgroup] {
+		return true
+	}
+
+	switch v := call.Patches.(type) {
+	case []uint:
+		for _, p := range v {
+			if talkgroups[p] {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (bot *Bot) authenticate(key string, id string) bool {
+	apikey, ok := bot.Controller.Apikeys.GetApikey(key)
+	if !ok {
+		bot.Controller.RateLimiter.Fail(bot.address)
+		bot.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("bot: invalid api key ip=%s", bot.address))
+		bot.sendError(id, BotErrorUnauthorized, "invalid api key")
+		return false
+	}
+
+	if !apikey.HasScope(ApikeyScopeCallsRead) {
+		bot.sendError(id, BotErrorForbidden, fmt.Sprintf("api key lacks the %s scope", ApikeyScopeCallsRead))
+		return false
+	}
+
+	bot.mutex.Lock()
+	bot.key = key
+	bot.mutex.Unlock()
+
+	bot.Controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("bot: %s connected as \"%s\" ip=%s", bot.id, apikey.Ident, bot.address))
+
+	return true
+}
+
+func (bot *Bot) process(b []byte) {
+	var m map[string]interface{}
+
+	if err := json.Unmarshal(b, &m); err != nil {
+		bot.sendError("", BotErrorInvalidMessage, "message must be a json object")
+		return
+	}
+
+	id, _ := m["id"].(string)
+
+	if err := ValidateBotMessage(m); err != nil {
+		bot.sendError(id, BotErrorInvalidMessage, err.Error())
+		return
+	}
+
+	reply := func(t string, payload map[string]interface{}) {
+		if payload == nil {
+			payload = map[string]interface{}{}
+		}
+		payload["type"] = t
+		if len(id) > 0 {
+			payload["id"] = id
+		}
+		bot.queue(payload)
+	}
+
+	switch m["type"] {
+	case BotMessageAuth:
+		if bot.IsAuthenticated() {
+			bot.sendError(id, BotErrorInvalidMessage, "already authenticated")
+		} else if bot.authenticate(m["key"].(string), id) {
+			reply(BotMessageAuthenticated, nil)
+		} else {
+			bot.queue(nil)
+		}
+		return
+
+	case BotMessagePing:
+		reply(BotMessagePong, map[string]interface{}{"time": time.Now().UTC().Format(time.RFC3339Nano)})
+		return
+	}
+
+	if !bot.IsAuthenticated() {
+		bot.sendError(id, BotErrorUnauthorized, "not authenticated")
+		return
+	}
+
+	switch m["type"] {
+	case BotMessageSubscribe:
+		var matrix map[uint]map[uint]bool
+
+		switch v := m["systems"].(type) {
+		case []interface{}:
+			matrix = map[uint]map[uint]bool{}
+
+			for _, f := range v {
+				s := f.(map[string]interface{})
+				system := uint(s["system"].(float64))
+
+				switch tgs := s["talkgroups"].(type) {
+				case []interface{}:
+					if t, ok := matrix[system]; len(tgs) == 0 || (ok && t == nil) {
+						matrix[system] = nil
+						continue
+					}
+					if matrix[system] == nil {
+						matrix[system] = map[uint]bool{}
+					}
+					for _, tg := range tgs {
+						matrix[system][uint(tg.(float64))] = true
+					}
+				default:
+					matrix[system] = nil
+				}
+			}
+		}
+
+		bot.mutex.Lock()
+		bot.matrix = matrix
+		bot.subscribed = true
+		bot.mutex.Unlock()
+
+		reply(BotMessageSubscribed, map[string]interface{}{"systems": m["systems"]})
+
+	case BotMessageUnsubscribe:
+		bot.mutex.Lock()
+		bot.matrix = nil
+		bot.subscribed = false
+		bot.mutex.Unlock()
+
+		reply(BotMessageSubscribed, map[string]interface{}{"systems": []interface{}{}})
+	}
+}
+
+func (bot *Bot) queue(message map[string]interface{}) bool {
+	defer func() {
+		recover()
+	}()
+
+	select {
+	case bot.Send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+func (bot *Bot) sendError(id string, code string, message string) {
+	m := map[string]interface{}{
+		"type":    BotMessageError,
+		"code":    code,
+		"message": message,
+	}
+
+	if len(id) > 0 {
+		m["id"] = id
+	}
+
+	bot.queue(m)
+}
+
+type Bots struct {
+	Controller *Controller
+	Map        sync.Map
+}
+
+func NewBots(controller *Controller) *Bots {
+	return &Bots{
+		Controller: controller,
+		Map:        sync.Map{},
+	}
+}
+
+func (bots *Bots) Add(bot *Bot) {
+	bots.Map.Store(bot, true)
+}
+
+func (bots *Bots) Count() int {
+	count := 0
+
+	bots.Map.Range(func(k, v interface{}) bool {
+		count++
+
+		return true
+	})
+
+	return count
+}
+
+func (bots *Bots) EmitCall(call *Call) {
+	var payload map[string]interface{}
+
+	bots.Map.Range(func(k interface{}, _ interface{}) bool {
+		switch bot := k.(type) {
+		case *Bot:
+			if !bot.IsSubscribed(call) {
+				return true
+			}
+
+			apikey, ok := bot.GetApikey()
+			if !ok {
+				bot.Conn.Close()
+				return true
+			}
+
+			if !apikey.HasScope(ApikeyScopeCallsRead) || !apikey.HasAccess(call) {
+				return true
+			}
+
+			if payload == nil {
+				payload = bots.Controller.Api.v1CallMap(call, nil)
+			}
+
+			if !bot.queue(map[string]interface{}{"type": BotMessageCall, "call": payload}) {
+				bots.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("bot: %s is too slow, call %v dropped", bot.id, call.Id))
+			}
+		}
+
+		return true
+	})
+}
+
+func (bots *Bots) Remove(bot *Bot) {
+	defer func() {
+		recover()
+	}()
+
+	if _, ok := bots.Map.LoadAndDelete(bot); ok {
+		close(bot.Send)
+	}
+}
+
+func (api *Api) V1BotHandler(w http.ResponseWriter, r *http.Request) {
+	if !strings.EqualFold(r.Header.Get("upgrade"), "websocket") {
+		w.WriteHeader(http.StatusUpgradeRequired)
+		return
+	}
+
+	supported := false
+	for _, p := range websocket.Subprotocols(r) {
+		if p == BotProtocol {
+			supported = true
+			break
+		}
+	}
+
+	if !supported {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("unsupported subprotocol, expected %s\n", BotProtocol)))
+		return
+	}
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return true
+		},
+		ReadBufferSize:  1024,
+		Subprotocols:    []string{BotProtocol},
+		WriteBufferSize: 1024,
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		api.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("api.v1bothandler: %v", err))
+		return
+	}
+
+	bot := &Bot{}
+	bot.Init(api.Controller, r, conn)
+}
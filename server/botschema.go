@@ -0,0 +1,217 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var BotSchema = map[string]interface{}{
+	"$schema": "http://json-schema.org/draft-07/schema#",
+	"$id":     BotProtocol,
+	"title":   "Rdio Scanner bot protocol, inbound messages",
+	"definitions": map[string]interface{}{
+		BotMessageAuth: botMessageSchema(BotMessageAuth, map[string]interface{}{
+			"key": map[string]interface{}{"type": "string", "minLength": 1, "maxLength": 256},
+		}, "key"),
+		BotMessagePing: botMessageSchema(BotMessagePing, nil),
+		BotMessageSubscribe: botMessageSchema(BotMessageSubscribe, map[string]interface{}{
+			"systems": map[string]interface{}{
+				"type":     "array",
+				"maxItems": 1000,
+				"items": map[string]interface{}{
+					"type":                 "object",
+					"additionalProperties": false,
+					"required":             []interface{}{"system"},
+					"properties": map[string]interface{}{
+						"system": map[string]interface{}{"type": "integer", "minimum": 1},
+						"talkgroups": map[string]interface{}{
+							"type":     "array",
+							"maxItems": 10000,
+							"items":    map[string]interface{}{"type": "integer", "minimum": 1},
+						},
+					},
+				},
+			},
+		}),
+		BotMessageUnsubscribe: botMessageSchema(BotMessageUnsubscribe, nil),
+	},
+	"oneOf": []interface{}{
+		map[string]interface{}{"$ref": "#/definitions/" + BotMessageAuth},
+		map[string]interface{}{"$ref": "#/definitions/" + BotMessagePing},
+		map[string]interface{}{"$ref": "#/definitions/" + BotMessageSubscribe},
+		map[string]interface{}{"$ref": "#/definitions/" + BotMessageUnsubscribe},
+	},
+}
+
+func (api *Api) V1BotSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		if b, err := json.MarshalIndent(BotSchema, "", "  "); err == nil {
+			w.Header().Set("Content-Type", "application/schema+json")
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func ValidateBotMessage(m map[string]interface{}) error {
+	t, ok := m["type"].(string)
+	if !ok {
+		return fmt.Errorf("type: is required and must be a string")
+	}
+
+	definitions, _ := BotSchema["definitions"].(map[string]interface{})
+
+	schema, ok := definitions[t].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("type: unknown message type %s", t)
+	}
+
+	return validateBotSchema(schema, m, "")
+}
+
+func botMessageSchema(t string, properties map[string]interface{}, required ...string) map[string]interface{} {
+	p := map[string]interface{}{
+		"id":   map[string]interface{}{"type": "string", "maxLength": 64},
+		"type": map[string]interface{}{"const": t},
+	}
+
+	for k, v := range properties {
+		p[k] = v
+	}
+
+	r := []interface{}{"type"}
+	for _, s := range required {
+		r = append(r, s)
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           p,
+		"required":             r,
+	}
+}
+
+func validateBotSchema(schema map[string]interface{}, v interface{}, path string) error {
+	name := path
+	if len(name) == 0 {
+		name = "message"
+	}
+
+	if c, ok := schema["const"]; ok && c != v {
+		return fmt.Errorf("%s: must be %v", name, c)
+	}
+
+	switch t := schema["type"].(type) {
+	case string:
+		if !isBotSchemaType(t, v) {
+			return fmt.Errorf("%s: must be of type %s", name, t)
+		}
+	}
+
+	switch v := v.(type) {
+	case float64:
+		if min, ok := schema["minimum"].(int); ok && v < float64(min) {
+			return fmt.Errorf("%s: must be at least %d", name, min)
+		}
+
+	case string:
+		if min, ok := schema["minLength"].(int); ok && len(v) < min {
+			return fmt.Errorf("%s: must be at least %d characters", name, min)
+		}
+		if max, ok := schema["maxLength"].(int); ok && len(v) > max {
+			return fmt.Errorf("%s: must be at most %d characters", name, max)
+		}
+
+	case []interface{}:
+		if max, ok := schema["maxItems"].(int); ok && len(v) > max {
+			return fmt.Errorf("%s: must have at most %d items", name, max)
+		}
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateBotSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				if _, ok := v[r.(string)]; !ok {
+					return fmt.Errorf("%s: is required", joinBotSchemaPath(path, r.(string)))
+				}
+			}
+		}
+
+		keys := []string{}
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			p, ok := properties[k].(map[string]interface{})
+			if !ok {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					return fmt.Errorf("%s: is not allowed", joinBotSchemaPath(path, k))
+				}
+				continue
+			}
+			if err := validateBotSchema(p, v[k], joinBotSchemaPath(path, k)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func isBotSchemaType(t string, v interface{}) bool {
+	switch t {
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	}
+
+	return false
+}
+
+func joinBotSchemaPath(path string, key string) string {
+	return strings.TrimPrefix(path+"."+key, ".")
+}
@@ -0,0 +1,260 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+const BroadcastifyCallsUrl = "https://api.broadcastify.com/call-upload"
+
+type Broadcastify struct {
+	controller *Controller
+	client     *http.Client
+}
+
+func NewBroadcastify(controller *Controller) *Broadcastify {
+	return &Broadcastify{
+		controller: controller,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (broadcastify *Broadcastify) Send(call *Call) {
+	system, ok := broadcastify.controller.Systems.GetSystem(call.System)
+	if !ok || len(system.BroadcastifyApiKey) == 0 || system.BroadcastifySystemId == 0 {
+		return
+	}
+
+	talkgroup, ok := system.Talkgroups.GetTalkgroup(call.Talkgroup)
+	if !ok || !talkgroup.Broadcastify {
+		return
+	}
+
+	slot := talkgroup.BroadcastifySlot
+	if slot == 0 {
+		slot = talkgroup.Id
+	}
+
+	apiKey := system.BroadcastifyApiKey
+	systemId := system.BroadcastifySystemId
+
+	go func() {
+		logEvent := func(logLevel string, message string) {
+			broadcastify.controller.Logs.LogEvent(logLevel, fmt.Sprintf("broadcastify: system=%v talkgroup=%v file=%v to %v/%v %v", call.System, call.Talkgroup, call.AudioName, systemId, slot, message))
+		}
+
+		if skipped, err := broadcastify.upload(call, apiKey, systemId, slot); err != nil {
+			logEvent(LogLevelError, err.Error())
+		} else if skipped {
+			logEvent(LogLevelInfo, "skipped, already received")
+		} else {
+			logEvent(LogLevelInfo, "success")
+		}
+	}()
+}
+
+func (broadcastify *Broadcastify) getMetadata(call *Call, slot uint) ([]byte, error) {
+	var emergency uint
+
+	duration := float64(call.GetDuration()) / 1000
+	start := call.DateTime.Unix()
+
+	freqList := []map[string]interface{}{}
+	for _, f := range getCallEntries(call.Frequencies) {
+		freq := map[string]interface{}{}
+		if v, ok := getCallNumber(f["freq"]); ok {
+			freq["freq"] = uint(v)
+		}
+		if v, ok := getCallNumber(f["pos"]); ok {
+			freq["pos"] = v
+			freq["time"] = start + int64(v)
+		}
+		if v, ok := getCallNumber(f["len"]); ok {
+			freq["len"] = v
+		}
+		if v, ok := getCallNumber(f["errorCount"]); ok {
+			freq["error_count"] = uint(v)
+		}
+		if v, ok := getCallNumber(f["spikeCount"]); ok {
+			freq["spike_count"] = uint(v)
+		}
+		freqList = append(freqList, freq)
+	}
+
+	srcList := []map[string]interface{}{}
+	for _, s := range getCallEntries(call.Sources) {
+		src := map[string]interface{}{"emergency": 0, "tag": ""}
+		if v, ok := getCallNumber(s["src"]); ok {
+			src["src"] = uint(v)
+		}
+		if v, ok := getCallNumber(s["pos"]); ok {
+			src["pos"] = v
+			src["time"] = start + int64(v)
+		}
+		if v, ok := s["emergency"].(bool); ok && v {
+			src["emergency"] = 1
+			emergency = 1
+		}
+		if v, ok := s["tag"].(string); ok {
+			src["tag"] = v
+		}
+		srcList = append(srcList, src)
+	}
+
+	metadata := map[string]interface{}{
+		"call_length": duration,
+		"emergency":   emergency,
+		"freqList":    freqList,
+		"srcList":     srcList,
+		"start_time":  start,
+		"stop_time":   start + int64(duration),
+		"talkgroup":   slot,
+	}
+
+	switch v := call.Frequency.(type) {
+	case uint:
+		metadata["freq"] = v
+	}
+
+	switch v := call.talkgroupTag.(type) {
+	case string:
+		metadata["talkgroup_tag"] = v
+	}
+
+	return json.Marshal(metadata)
+}
+
+func (broadcastify *Broadcastify) isAudioSupported(call *Call) bool {
+	switch v := call.AudioType.(type) {
+	case string:
+		switch v {
+		case "audio/aac", "audio/mp4", "audio/x-m4a":
+			return true
+		}
+	}
+
+	switch v := call.AudioName.(type) {
+	case string:
+		switch strings.ToLower(path.Ext(v)) {
+		case ".aac", ".m4a":
+			return true
+		}
+	}
+
+	return false
+}
+
+func (broadcastify *Broadcastify) upload(call *Call, apiKey string, systemId uint, slot uint) (bool, error) {
+	formatError := func(err error) error {
+		return fmt.Errorf("broadcastify.upload: %v", err)
+	}
+
+	if !broadcastify.isAudioSupported(call) {
+		return false, formatError(fmt.Errorf("unsupported audio type %v, m4a/aac audio is required", call.AudioType))
+	}
+
+	if len(call.Audio) == 0 {
+		return false, formatError(fmt.Errorf("no audio"))
+	}
+
+	metadata, err := broadcastify.getMetadata(call, slot)
+	if err != nil {
+		return false, formatError(err)
+	}
+
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+
+	if w, err := mw.CreateFormFile("metadata", "metadata.json"); err == nil {
+		if _, err = w.Write(metadata); err != nil {
+			return false, formatError(err)
+		}
+	} else {
+		return false, formatError(err)
+	}
+
+	fields := [][2]string{
+		{"apiKey", apiKey},
+		{"callDuration", fmt.Sprintf("%.3f", float64(call.GetDuration())/1000)},
+		{"systemId", fmt.Sprintf("%v", systemId)},
+	}
+
+	for _, field := range fields {
+		if err := mw.WriteField(field[0], field[1]); err != nil {
+			return false, formatError(err)
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return false, formatError(err)
+	}
+
+	res, err := broadcastify.client.Post(BroadcastifyCallsUrl, mw.FormDataContentType(), buf)
+	if err != nil {
+		return false, formatError(err)
+	}
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, 4096))
+	res.Body.Close()
+	if err != nil {
+		return false, formatError(err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return false, formatError(fmt.Errorf("bad status: %s", res.Status))
+	}
+
+	reply := strings.TrimSpace(string(b))
+
+	switch {
+	case strings.HasPrefix(reply, "0 "):
+	case strings.HasPrefix(reply, "1 SKIPPED"):
+		return true, nil
+	default:
+		return false, formatError(fmt.Errorf("upload rejected: %s", reply))
+	}
+
+	uploadUrl := strings.TrimSpace(strings.TrimPrefix(reply, "0 "))
+
+	req, err := http.NewRequest(http.MethodPut, uploadUrl, bytes.NewReader(call.Audio))
+	if err != nil {
+		return false, formatError(err)
+	}
+
+	req.Header.Set("Content-Type", "audio/aac")
+
+	res, err = broadcastify.client.Do(req)
+	if err != nil {
+		return false, formatError(err)
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return false, formatError(fmt.Errorf("bad status: %s", res.Status))
+	}
+
+	return false, nil
+}
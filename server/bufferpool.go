@@ -0,0 +1,65 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	AudioBufferMaxPooledSize          = 8 << 20
+	AudioBufferMaxPooledSizeLowMemory = 1 << 20
+)
+
+var (
+	audioBufferMaxPooledSize int64 = AudioBufferMaxPooledSize
+	audioBufferPool                = sync.Pool{
+		New: func() interface{} {
+			return new(bytes.Buffer)
+		},
+	}
+)
+
+func GetAudioBuffer() *bytes.Buffer {
+	buf := audioBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func PutAudioBuffer(buf *bytes.Buffer) {
+	if buf == nil || int64(buf.Cap()) > atomic.LoadInt64(&audioBufferMaxPooledSize) {
+		return
+	}
+	audioBufferPool.Put(buf)
+}
+
+func ReadAllPooled(r io.Reader) ([]byte, error) {
+	buf := GetAudioBuffer()
+	defer PutAudioBuffer(buf)
+
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, err
+	}
+
+	return append([]byte(nil), buf.Bytes()...), nil
+}
+
+func SetAudioBufferMaxPooledSize(size int64) {
+	atomic.StoreInt64(&audioBufferMaxPooledSize, size)
+}
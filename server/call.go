@@ -16,35 +16,74 @@
 package main
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 )
 
 type Call struct {
-	Id             interface{} `json:"id"`
-	Audio          []byte      `json:"audio"`
-	AudioName      interface{} `json:"audioName"`
-	AudioType      interface{} `json:"audioType"`
-	DateTime       time.Time   `json:"dateTime"`
-	Frequencies    interface{} `json:"frequencies"`
-	Frequency      interface{} `json:"frequency"`
-	Patches        interface{} `json:"patches"`
-	Source         interface{} `json:"source"`
-	Sources        interface{} `json:"sources"`
-	System         uint        `json:"system"`
-	Talkgroup      uint        `json:"talkgroup"`
-	systemLabel    interface{}
-	talkgroupGroup interface{}
-	talkgroupLabel interface{}
-	talkgroupName  interface{}
-	talkgroupTag   interface{}
-	units          interface{}
+	Id               interface{} `json:"id"`
+	Audio            []byte      `json:"audio"`
+	AudioName        interface{} `json:"audioName"`
+	AudioType        interface{} `json:"audioType"`
+	Conversation     interface{} `json:"conversation"`
+	DateTime         time.Time   `json:"dateTime"`
+	Frequencies      interface{} `json:"frequencies"`
+	Frequency        interface{} `json:"frequency"`
+	Patches          interface{} `json:"patches"`
+	Source           interface{} `json:"source"`
+	Sources          interface{} `json:"sources"`
+	System           uint        `json:"system"`
+	Talkgroup        uint        `json:"talkgroup"`
+	alternate        interface{}
+	alternates       interface{}
+	audioFile        string
+	audioFileArchive string
+	audioFileOwned   bool
+	audioFingerprint string
+	audioHash        string
+	audioSize        int64
+	audioUrl         string
+	callback         string
+	controlChannel   interface{}
+	convertError     error
+	convertTime      time.Duration
+	converted        bool
+	decodeRate       interface{}
+	deferred         bool
+	duration         uint
+	historical       bool
+	ingested         chan string
+	loudness         map[string]float64
+	signal           interface{}
+	site             interface{}
+	systemLabel      interface{}
+	talkgroupGroup   interface{}
+	talkgroupLabel   interface{}
+	talkgroupName    interface{}
+	talkgroupTag     interface{}
+	transcript       string
+	transcriptScore  interface{}
+	uploader         string
+	patchMembers     interface{}
+	received         time.Time
+	requestId        string
+	streamOnly       bool
+	units            interface{}
+	voted            bool
+	watermarked      bool
 }
 
 func NewCall() *Call {
@@ -55,10 +94,209 @@ func NewCall() *Call {
 	}
 }
 
+func (call *Call) GetAudioHash() string {
+	if len(call.audioHash) > 0 {
+		return call.audioHash
+	}
+
+	h := sha256.New()
+
+	if call.Audio != nil {
+		h.Write(call.Audio)
+
+	} else if len(call.audioFile) > 0 {
+		f, err := os.Open(call.audioFile)
+		if err != nil {
+			return ""
+		}
+
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return ""
+		}
+
+	} else {
+		return ""
+	}
+
+	call.audioHash = hex.EncodeToString(h.Sum(nil))
+
+	return call.audioHash
+}
+
+func (call *Call) GetAudioSize() int64 {
+	if call.Audio != nil {
+		return int64(len(call.Audio))
+	}
+	return call.audioSize
+}
+
+func (call *Call) GetDuration() uint {
+	var duration uint
+
+	if call.duration > 0 {
+		return call.duration
+	}
+
+	for _, f := range getCallEntries(call.Frequencies) {
+		p, _ := getCallNumber(f["pos"])
+		l, _ := getCallNumber(f["len"])
+		if d := uint(math.Round((p + l) * 1000)); d > duration {
+			duration = d
+		}
+	}
+
+	return duration
+}
+
+func (call *Call) GetEvents() []CallEvent {
+	duration := call.GetDuration()
+
+	events := []CallEvent{}
+
+	getMs := func(f float64) uint {
+		return uint(math.Round(f * 1000))
+	}
+
+	if patches := call.GetPatches(); len(patches) > 0 {
+		events = append(events, CallEvent{Length: duration, Patches: patches, Type: CallEventPatch})
+	}
+
+	for _, f := range getCallEntries(call.Frequencies) {
+		freq, ok := getCallNumber(f["freq"])
+		if !ok {
+			continue
+		}
+
+		pos, _ := getCallNumber(f["pos"])
+		l, _ := getCallNumber(f["len"])
+
+		event := CallEvent{Frequency: uint(freq), Length: getMs(l), Offset: getMs(pos), Type: CallEventFrequency}
+
+		if v, ok := getCallNumber(f["errorCount"]); ok {
+			event.ErrorCount = uint(v)
+		}
+
+		if v, ok := getCallNumber(f["spikeCount"]); ok {
+			event.SpikeCount = uint(v)
+		}
+
+		events = append(events, event)
+	}
+
+	sources := getCallEntries(call.Sources)
+
+	for i, s := range sources {
+		src, ok := getCallNumber(s["src"])
+		if !ok {
+			continue
+		}
+
+		pos, _ := getCallNumber(s["pos"])
+
+		event := CallEvent{Offset: getMs(pos), Source: uint(src), Type: CallEventSource}
+
+		end := duration
+		if i < len(sources)-1 {
+			if p, ok := getCallNumber(sources[i+1]["pos"]); ok {
+				end = getMs(p)
+			}
+		}
+		if end > event.Offset {
+			event.Length = end - event.Offset
+		}
+
+		if v, ok := s["emergency"].(bool); ok {
+			event.Emergency = v
+		}
+
+		if v, ok := s["tag"].(string); ok {
+			event.Tag = v
+		}
+
+		events = append(events, event)
+	}
+
+	sort.SliceStable(events, func(i int, j int) bool {
+		return events[i].Offset < events[j].Offset
+	})
+
+	return events
+}
+
+func (call *Call) GetPatches() []uint {
+	patches := []uint{}
+
+	switch v := call.Patches.(type) {
+	case []uint:
+		patches = append(patches, v...)
+	case []interface{}:
+		for _, f := range v {
+			switch p := f.(type) {
+			case float64:
+				patches = append(patches, uint(p))
+			}
+		}
+	}
+
+	return patches
+}
+
+func (call *Call) GetUnits() []uint {
+	units := []uint{}
+
+	add := func(v float64) {
+		if v < 1 {
+			return
+		}
+		unit := uint(v)
+		for _, u := range units {
+			if u == unit {
+				return
+			}
+		}
+		units = append(units, unit)
+	}
+
+	if v, ok := getCallNumber(call.Source); ok {
+		add(v)
+	}
+
+	for _, s := range getCallEntries(call.Sources) {
+		if v, ok := getCallNumber(s["src"]); ok {
+			add(v)
+		}
+	}
+
+	return units
+}
+
+func (call *Call) IsPatchedWith(system uint, talkgroup uint, patches []uint) bool {
+	if call.System != system {
+		return false
+	}
+
+	members := append([]uint{call.Talkgroup}, call.GetPatches()...)
+
+	for _, m := range members {
+		if m == talkgroup {
+			return true
+		}
+		for _, p := range patches {
+			if m == p {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func (call *Call) IsValid() (ok bool, err error) {
 	ok = true
 
-	if len(call.Audio) <= 44 {
+	if call.GetAudioSize() <= 44 {
 		ok = false
 		err = errors.New("no audio")
 	}
@@ -81,27 +319,131 @@ func (call *Call) IsValid() (ok bool, err error) {
 	return ok, err
 }
 
+func (call *Call) LoadAudio(maxSize int64) error {
+	if call.Audio != nil || len(call.audioFile) == 0 {
+		return nil
+	}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("call.loadaudio: %v", err)
+	}
+
+	f, err := os.Open(call.audioFile)
+	if err != nil {
+		return formatError(err)
+	}
+
+	if call.audioSize > maxSize {
+		f.Close()
+		return formatError(fmt.Errorf("%s exceeds the maximum audio size", call.audioFile))
+	}
+
+	b := make([]byte, call.audioSize)
+	_, err = io.ReadFull(f, b)
+	f.Close()
+	if err != nil {
+		return formatError(err)
+	}
+
+	call.Audio = b
+
+	call.ReleaseAudio()
+
+	return nil
+}
+
 func (call *Call) MarshalJSON() ([]byte, error) {
 	audio := fmt.Sprintf("%v", call.Audio)
 	audio = strings.ReplaceAll(audio, " ", ",")
 
-	return json.Marshal(map[string]interface{}{
+	m := map[string]interface{}{
 		"id": call.Id,
 		"audio": map[string]interface{}{
 			"data": json.RawMessage(audio),
 			"type": "Buffer",
 		},
-		"audioName":   call.AudioName,
-		"audioType":   call.AudioType,
-		"dateTime":    call.DateTime.Format(time.RFC3339),
-		"frequencies": call.Frequencies,
-		"frequency":   call.Frequency,
-		"patches":     call.Patches,
-		"source":      call.Source,
-		"sources":     call.Sources,
-		"system":      call.System,
-		"talkgroup":   call.Talkgroup,
-	})
+		"audioName":    call.AudioName,
+		"audioType":    call.AudioType,
+		"conversation": call.Conversation,
+		"dateTime":     call.DateTime.Format(time.RFC3339),
+		"frequencies":  call.Frequencies,
+		"frequency":    call.Frequency,
+		"patches":      call.Patches,
+		"source":       call.Source,
+		"sources":      call.Sources,
+		"system":       call.System,
+		"talkgroup":    call.Talkgroup,
+	}
+
+	if call.alternate != nil {
+		m["alternate"] = call.alternate
+	}
+
+	if len(call.audioUrl) > 0 {
+		delete(m, "audio")
+		m["audioUrl"] = call.audioUrl
+	}
+
+	if duration := call.GetDuration(); duration > 0 {
+		m["duration"] = duration
+	}
+
+	if events := call.GetEvents(); len(events) > 0 {
+		m["events"] = events
+	}
+
+	switch v := call.alternates.(type) {
+	case []map[string]interface{}:
+		m["alternates"] = v
+	}
+
+	if call.patchMembers != nil {
+		m["patchMembers"] = call.patchMembers
+	}
+
+	if call.site != nil {
+		m["site"] = call.site
+	}
+
+	if len(call.transcript) > 0 {
+		m["transcript"] = call.transcript
+	}
+
+	if call.transcriptScore != nil {
+		m["transcriptConfidence"] = call.transcriptScore
+	}
+
+	return json.Marshal(m)
+}
+
+func (call *Call) ReleaseAudio() {
+	if len(call.audioFile) > 0 && call.audioFileOwned {
+		if len(call.audioFileArchive) > 0 {
+			ArchiveFile(call.audioFile, call.audioFileArchive)
+		} else {
+			os.Remove(call.audioFile)
+		}
+	}
+	call.audioFile = ""
+	call.audioFileOwned = false
+}
+
+func (call *Call) SetAudioFile(p string, owned bool, maxSize int64) error {
+	fi, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+
+	if fi.Size() > maxSize {
+		return fmt.Errorf("%s exceeds the maximum audio size", p)
+	}
+
+	call.Audio = nil
+	call.audioFile = p
+	call.audioFileOwned = owned
+	call.audioSize = fi.Size()
+
+	return nil
 }
 
 func (call *Call) ToJson() (string, error) {
@@ -110,111 +452,998 @@ func (call *Call) ToJson() (string, error) {
 	} else {
 		return "", fmt.Errorf("call.tojson: %v", err)
 	}
-}
+}
+
+const (
+	CallEventFrequency = "frequency"
+	CallEventPatch     = "patch"
+	CallEventSource    = "source"
+)
+
+type CallEvent struct {
+	Emergency  bool        `json:"emergency,omitempty"`
+	ErrorCount interface{} `json:"errorCount,omitempty"`
+	Frequency  uint        `json:"frequency,omitempty"`
+	Length     uint        `json:"length"`
+	Offset     uint        `json:"offset"`
+	Patches    []uint      `json:"patches,omitempty"`
+	Source     uint        `json:"source,omitempty"`
+	SpikeCount interface{} `json:"spikeCount,omitempty"`
+	Tag        string      `json:"tag,omitempty"`
+	Type       string      `json:"type"`
+}
+
+type Calls struct {
+	mutex   sync.Mutex
+	storage *Storage
+}
+
+func NewCalls() *Calls {
+	return &Calls{
+		mutex: sync.Mutex{},
+	}
+}
+
+func (calls *Calls) CheckDuplicate(call *Call, msTimeFrame uint, hash bool, db *Database) (uint, time.Time, bool) {
+	var (
+		found      bool
+		nearest    time.Duration
+		original   time.Time
+		originalId uint
+	)
+
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+
+	d := time.Duration(msTimeFrame) * time.Millisecond
+	from := call.DateTime.Add(-d).Format(db.DateTimeFormat)
+	to := call.DateTime.Add(d).Format(db.DateTimeFormat)
+
+	query := fmt.Sprintf("select `id`, `dateTime` from `rdioScannerCalls` where (`dateTime` between '%v' and '%v') and `system` = %v and `talkgroup` = %v", from, to, call.System, call.Talkgroup)
+	args := []interface{}{}
+
+	if hash {
+		audioHash := call.GetAudioHash()
+		if len(audioHash) == 0 {
+			return 0, time.Time{}, false
+		}
+
+		query += " and `audioHash` = ?"
+		args = append(args, audioHash)
+	}
+
+	rows, err := db.Sql.Query(query, args...)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+
+	for rows.Next() {
+		var (
+			dateTime interface{}
+			id       uint
+		)
+
+		if err = rows.Scan(&id, &dateTime); err != nil {
+			continue
+		}
+
+		t, err := db.ParseDateTime(dateTime)
+		if err != nil {
+			continue
+		}
+
+		delta := call.DateTime.Sub(t)
+		if delta < 0 {
+			delta = -delta
+		}
+
+		if !found || delta < nearest {
+			found = true
+			nearest = delta
+			original = t
+			originalId = id
+		}
+	}
+
+	rows.Close()
+
+	return originalId, original, found
+}
+
+func (calls *Calls) CheckFingerprintDuplicate(call *Call, msTimeFrame uint, threshold float64, db *Database) (uint, time.Time, bool) {
+	var (
+		best       float64
+		found      bool
+		original   time.Time
+		originalId uint
+	)
+
+	if len(call.audioFingerprint) == 0 {
+		return 0, time.Time{}, false
+	}
+
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+
+	d := time.Duration(msTimeFrame) * time.Millisecond
+	from := call.DateTime.Add(-d).Format(db.DateTimeFormat)
+	to := call.DateTime.Add(d).Format(db.DateTimeFormat)
+
+	query := fmt.Sprintf("select `id`, `audioFingerprint`, `dateTime` from `rdioScannerCalls` where (`dateTime` between '%v' and '%v') and `system` = %v and `talkgroup` = %v and `audioFingerprint` is not null", from, to, call.System, call.Talkgroup)
+
+	rows, err := db.Sql.Query(query)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+
+	for rows.Next() {
+		var (
+			dateTime    interface{}
+			fingerprint string
+			id          uint
+		)
+
+		if err = rows.Scan(&id, &fingerprint, &dateTime); err != nil {
+			continue
+		}
+
+		t, err := db.ParseDateTime(dateTime)
+		if err != nil {
+			continue
+		}
+
+		if score := CompareAudioFingerprints(call.audioFingerprint, fingerprint); score >= threshold && score > best {
+			best = score
+			found = true
+			original = t
+			originalId = id
+		}
+	}
+
+	rows.Close()
+
+	return originalId, original, found
+}
+
+func (calls *Calls) GetConversation(call *Call, gap uint, db *Database) interface{} {
+	var (
+		conversation sql.NullFloat64
+		id           uint
+	)
+
+	if gap == 0 {
+		return nil
+	}
+
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+
+	from := call.DateTime.Add(-time.Duration(gap) * time.Second).Format(db.DateTimeFormat)
+	to := call.DateTime.Format(db.DateTimeFormat)
+
+	if err := db.Sql.QueryRow("select `id`, `conversation` from `rdioScannerCalls` where (`dateTime` between ? and ?) and `system` = ? and `talkgroup` = ? order by `dateTime` desc limit 1", from, to, call.System, call.Talkgroup).Scan(&id, &conversation); err != nil {
+		return nil
+	}
+
+	if conversation.Valid && conversation.Float64 > 0 {
+		return uint(conversation.Float64)
+	}
+
+	return id
+}
+
+func (calls *Calls) GetAlternate(id uint, db *Database) (*Call, error) {
+	var (
+		audioName   sql.NullString
+		audioType   sql.NullString
+		callId      uint
+		dateTime    interface{}
+		frequencies string
+		site        sql.NullString
+		t           time.Time
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("calls.getalternate: %v", err)
+	}
+
+	calls.mutex.Lock()
+
+	call := Call{alternate: id}
+
+	err := db.Sql.QueryRow("select `callId`, `audio`, `audioName`, `audioType`, `dateTime`, `frequencies`, `site` from `rdioScannerCallAlternates` where `id` = ?", id).Scan(&callId, &call.Audio, &audioName, &audioType, &dateTime, &frequencies, &site)
+
+	calls.mutex.Unlock()
+
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	parent, err := calls.GetCall(callId, db)
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	call.Id = parent.Id
+	call.Conversation = parent.Conversation
+	call.Patches = parent.Patches
+	call.Source = parent.Source
+	call.Sources = parent.Sources
+	call.System = parent.System
+	call.Talkgroup = parent.Talkgroup
+
+	if audioName.Valid {
+		call.AudioName = audioName.String
+	}
+
+	if audioType.Valid {
+		call.AudioType = audioType.String
+	}
+
+	if site.Valid {
+		call.site = site.String
+	}
+
+	if t, err = db.ParseDateTime(dateTime); err == nil {
+		call.DateTime = t
+	} else {
+		call.DateTime = parent.DateTime
+	}
+
+	if len(frequencies) > 0 {
+		if err = json.Unmarshal([]byte(frequencies), &call.Frequencies); err != nil {
+			call.Frequencies = []interface{}{}
+		}
+	}
+
+	return &call, nil
+}
+
+func (calls *Calls) GetAlternates(id uint, db *Database) ([]map[string]interface{}, error) {
+	var (
+		alternateId uint
+		audioName   sql.NullString
+		dateTime    interface{}
+		err         error
+		rows        *sql.Rows
+		score       float64
+		site        sql.NullString
+		t           time.Time
+	)
+
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("calls.getalternates: %v", err)
+	}
+
+	alternates := []map[string]interface{}{}
+
+	if rows, err = db.Sql.Query("select `id`, `audioName`, `dateTime`, `score`, `site` from `rdioScannerCallAlternates` where `callId` = ? order by `score` desc", id); err != nil {
+		return nil, formatError(err)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&alternateId, &audioName, &dateTime, &score, &site); err != nil {
+			break
+		}
+
+		alternate := map[string]interface{}{
+			"id":    alternateId,
+			"score": score,
+		}
+
+		if audioName.Valid {
+			alternate["audioName"] = audioName.String
+		}
+
+		if t, err = db.ParseDateTime(dateTime); err == nil {
+			alternate["dateTime"] = t.Format(time.RFC3339)
+		}
+
+		if site.Valid {
+			alternate["site"] = site.String
+		}
+
+		alternates = append(alternates, alternate)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	return alternates, nil
+}
+
+func (calls *Calls) GetCall(id uint, db *Database) (*Call, error) {
+	var (
+		audioKey     sql.NullString
+		audioName    sql.NullString
+		audioType    sql.NullString
+		conversation sql.NullFloat64
+		dateTime     interface{}
+		duration     sql.NullFloat64
+		frequency    sql.NullFloat64
+		source       sql.NullFloat64
+		frequencies  string
+		loudness     sql.NullString
+		patches      string
+		sources      string
+		t            time.Time
+		transcript   sql.NullString
+		confidence   sql.NullFloat64
+	)
+
+	calls.mutex.Lock()
+
+	call := Call{Id: id}
+
+	query := fmt.Sprintf("select `audio`, `audioKey`, `audioName`, `audioType`, `conversation`, `DateTime`, `duration`, `frequencies`, `frequency`, `loudness`, `patches`, `source`, `sources`, `system`, `talkgroup`, `transcript`, `transcriptConfidence` from `rdioScannerCalls` where `id` = %v", id)
+	err := db.Sql.QueryRow(query).Scan(&call.Audio, &audioKey, &audioName, &audioType, &conversation, &dateTime, &duration, &frequencies, &frequency, &loudness, &patches, &source, &sources, &call.System, &call.Talkgroup, &transcript, &confidence)
+
+	calls.mutex.Unlock()
+
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("getcall: %v, %v", err, query)
+	}
+
+	if audioKey.Valid && len(audioKey.String) > 0 && len(call.Audio) == 0 && calls.storage != nil {
+		if call.Audio, err = calls.storage.Get(audioKey.String); err != nil {
+			return nil, fmt.Errorf("getcall: %v", err)
+		}
+	}
+
+	if audioName.Valid {
+		call.AudioName = audioName.String
+	}
+
+	if audioType.Valid {
+		call.AudioType = audioType.String
+	}
+
+	if conversation.Valid && conversation.Float64 > 0 {
+		call.Conversation = uint(conversation.Float64)
+	} else {
+		call.Conversation = id
+	}
+
+	if duration.Valid && duration.Float64 > 0 {
+		call.duration = uint(duration.Float64)
+	}
+
+	if frequency.Valid && frequency.Float64 > 0 {
+		call.Frequency = uint(frequency.Float64)
+	}
+
+	if t, err = db.ParseDateTime(dateTime); err == nil {
+		call.DateTime = t
+	} else {
+		call.DateTime = time.Time{}
+	}
+
+	if len(frequencies) > 0 {
+		if err = json.Unmarshal([]byte(frequencies), &call.Frequencies); err != nil {
+			call.Frequencies = []interface{}{}
+		}
+	}
+
+	if loudness.Valid && len(loudness.String) > 0 {
+		json.Unmarshal([]byte(loudness.String), &call.loudness)
+	}
+
+	if len(patches) > 0 {
+		if err = json.Unmarshal([]byte(patches), &call.Patches); err != nil {
+			call.Patches = []interface{}{}
+		}
+	}
+
+	if source.Valid && source.Float64 > 0 {
+		call.Source = uint(source.Float64)
+	}
+
+	if len(sources) > 0 {
+		if err = json.Unmarshal([]byte(sources), &call.Sources); err != nil {
+			call.Sources = []interface{}{}
+		}
+	}
+
+	if transcript.Valid {
+		call.transcript = transcript.String
+	}
+
+	if confidence.Valid {
+		call.transcriptScore = confidence.Float64
+	}
+
+	return &call, nil
+}
+
+func (calls *Calls) GetRelatedCalls(call *Call, window uint, client *Client) ([]CallsSearchResult, error) {
+	var (
+		conversation sql.NullFloat64
+		dateTime     interface{}
+		err          error
+		id           sql.NullFloat64
+		patches      string
+		rows         *sql.Rows
+		t            time.Time
+	)
+
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+
+	db := client.Controller.Database
+
+	formatError := func(err error) error {
+		return fmt.Errorf("calls.getrelatedcalls: %v", err)
+	}
+
+	results := []CallsSearchResult{}
+
+	if window == 0 {
+		return results, nil
+	}
+
+	from := call.DateTime.Add(-time.Duration(window) * time.Second).Format(db.DateTimeFormat)
+	to := call.DateTime.Add(time.Duration(window) * time.Second).Format(db.DateTimeFormat)
+
+	if rows, err = db.Sql.Query("select `id`, `conversation`, `dateTime`, `patches`, `system`, `talkgroup` from `rdioScannerCalls` where (`dateTime` between ? and ?) and `system` = ? and `id` <> ? order by `dateTime` asc", from, to, call.System, call.Id); err != nil {
+		return nil, formatError(err)
+	}
+
+	for rows.Next() {
+		related := &Call{}
+		result := CallsSearchResult{}
+
+		if err = rows.Scan(&id, &conversation, &dateTime, &patches, &related.System, &related.Talkgroup); err != nil {
+			break
+		}
+
+		if len(patches) > 0 {
+			if err = json.Unmarshal([]byte(patches), &related.Patches); err != nil {
+				related.Patches = []interface{}{}
+			}
+		}
+
+		if !related.IsPatchedWith(call.System, call.Talkgroup, call.GetPatches()) {
+			continue
+		}
+
+		if client.Controller.Accesses.IsRestricted() && !client.Access.HasAccess(related) {
+			continue
+		}
+
+		if t, err = db.ParseDateTime(dateTime); err != nil || !client.Access.HasArchiveAccess(t) {
+			continue
+		}
+
+		if id.Valid && id.Float64 > 0 {
+			result.Id = uint(id.Float64)
+		}
+
+		if conversation.Valid && conversation.Float64 > 0 {
+			result.Conversation = uint(conversation.Float64)
+		} else {
+			result.Conversation = result.Id
+		}
+
+		result.DateTime = t
+		result.System = related.System
+		result.Talkgroup = related.Talkgroup
+
+		results = append(results, result)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	return results, nil
+}
+
+func (calls *Calls) Prune(db *Database, pruneDays uint, retentions map[uint][]string) error {
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+
+	now := time.Now()
+
+	excluded := []string{}
+
+	for days, where := range retentions {
+		excluded = append(excluded, where...)
+
+		if days == 0 {
+			continue
+		}
+
+		if err := calls.prune(db, now.Add(-24*time.Hour*time.Duration(days)), strings.Join(where, " or ")); err != nil {
+			return err
+		}
+	}
+
+	if pruneDays > 0 {
+		where := "1 = 1"
+		if len(excluded) > 0 {
+			sort.Strings(excluded)
+			where = fmt.Sprintf("not (%s)", strings.Join(excluded, " or "))
+		}
+
+		if err := calls.prune(db, now.Add(-24*time.Hour*time.Duration(pruneDays)), where); err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Sql.Exec("delete from `rdioScannerCallAlternates` where `callId` not in (select `id` from `rdioScannerCalls`)")
+
+	return err
+}
+
+func (calls *Calls) prune(db *Database, before time.Time, where string) error {
+	date := before.Format(db.DateTimeFormat)
+
+	if calls.storage != nil {
+		if rows, err := db.Sql.Query(fmt.Sprintf("select `audioKey` from `rdioScannerCalls` where `dateTime` < ? and (%s) and `audioKey` is not null and `id` not in (select `callId` from `rdioScannerIncidentCalls`)", where), date); err == nil {
+			keys := []string{}
+			for rows.Next() {
+				var key sql.NullString
+				if err = rows.Scan(&key); err == nil && key.Valid && len(key.String) > 0 {
+					keys = append(keys, key.String)
+				}
+			}
+			rows.Close()
+
+			for _, key := range keys {
+				if err := calls.storage.Delete(key); err != nil {
+					calls.storage.controller.Logs.LogEvent(LogLevelWarn, err.Error())
+				}
+			}
+		}
+	}
+
+	_, err := db.Sql.Exec(fmt.Sprintf("delete from `rdioScannerCalls` where `dateTime` < ? and (%s) and `id` not in (select `callId` from `rdioScannerIncidentCalls`)", where), date)
+
+	return err
+}
+
+func (calls *Calls) getFacets(where string, client *Client) (*CallsSearchFacets, error) {
+	var (
+		count     uint
+		day       string
+		err       error
+		query     string
+		rows      *sql.Rows
+		system    uint
+		talkgroup uint
+	)
+
+	db := client.Controller.Database
+
+	facets := &CallsSearchFacets{
+		Days:       []CallsSearchFacet{},
+		Systems:    []CallsSearchFacet{},
+		Tags:       []CallsSearchFacet{},
+		Talkgroups: []CallsSearchFacet{},
+	}
+
+	query = fmt.Sprintf("select substr(`dateTime`, 1, 10) as `day`, count(*) from `rdioScannerCalls` where %v group by `day` order by `day` asc", where)
+	if rows, err = db.Sql.Query(query); err != nil {
+		return nil, fmt.Errorf("%v, %v", err, query)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&day, &count); err != nil {
+			break
+		}
+		facets.Days = append(facets.Days, CallsSearchFacet{Count: count, Date: day})
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	query = fmt.Sprintf("select `system`, `talkgroup`, count(*) from `rdioScannerCalls` where %v group by `system`, `talkgroup` order by `system` asc, `talkgroup` asc", where)
+	if rows, err = db.Sql.Query(query); err != nil {
+		return nil, fmt.Errorf("%v, %v", err, query)
+	}
+
+	systems := map[uint]uint{}
+	tags := map[string]uint{}
+
+	for rows.Next() {
+		if err = rows.Scan(&system, &talkgroup, &count); err != nil {
+			break
+		}
+
+		if systems[system] == 0 {
+			facets.Systems = append(facets.Systems, CallsSearchFacet{System: system})
+		}
+		systems[system] += count
+
+		facets.Talkgroups = append(facets.Talkgroups, CallsSearchFacet{Count: count, System: system, Talkgroup: talkgroup})
+
+		for label, m := range client.TagsMap {
+			for _, id := range m[system] {
+				if id == talkgroup {
+					if tags[label] == 0 {
+						facets.Tags = append(facets.Tags, CallsSearchFacet{Tag: label})
+					}
+					tags[label] += count
+					break
+				}
+			}
+		}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range facets.Systems {
+		facets.Systems[i].Count = systems[facets.Systems[i].System]
+	}
+
+	for i := range facets.Tags {
+		facets.Tags[i].Count = tags[facets.Tags[i].Tag]
+	}
+
+	sort.Slice(facets.Tags, func(i int, j int) bool {
+		return facets.Tags[i].Tag < facets.Tags[j].Tag
+	})
+
+	return facets, nil
+}
+
+func (calls *Calls) getSearchFilter(searchOptions *CallsSearchOptions, client *Client) string {
+	where := "true"
+
+	if client.Access != nil {
+		switch v := client.Access.Systems.(type) {
+		case []interface{}:
+			a := []string{}
+			for _, scope := range v {
+				var c string
+				switch v := scope.(type) {
+				case map[string]interface{}:
+					switch v["talkgroups"].(type) {
+					case []interface{}:
+						b := strings.ReplaceAll(fmt.Sprintf("%v", v["talkgroups"]), " ", ", ")
+						b = strings.ReplaceAll(b, "[", "(")
+						b = strings.ReplaceAll(b, "]", ")")
+						c = fmt.Sprintf("(`system` = %v and `talkgroup` in %v)", v["id"], b)
+					case string:
+						if v["talkgroups"] == "*" {
+							c = fmt.Sprintf("`system` = %v", v["id"])
+						}
+					}
+				}
+				if len(c) > 0 {
+					a = append(a, c)
+				}
+			}
+			where = fmt.Sprintf("(%s)", strings.Join(a, " or "))
+		}
+
+		switch v := client.Access.Deny.(type) {
+		case []interface{}:
+			a := []string{}
+			for _, scope := range v {
+				switch v := scope.(type) {
+				case map[string]interface{}:
+					system, ok := v["id"].(float64)
+					if !ok {
+						continue
+					}
+					switch tg := v["talkgroups"].(type) {
+					case []interface{}:
+						b := []string{}
+						for _, f := range tg {
+							if talkgroup, ok := f.(float64); ok {
+								b = append(b, strconv.FormatUint(uint64(talkgroup), 10))
+							}
+						}
+						if len(b) > 0 {
+							a = append(a, fmt.Sprintf("(`system` = %d and `talkgroup` in (%s))", uint(system), strings.Join(b, ", ")))
+						}
+					case string:
+						if tg == "*" {
+							a = append(a, fmt.Sprintf("`system` = %d", uint(system)))
+						}
+					}
+				}
+			}
+			if len(a) > 0 {
+				where += fmt.Sprintf(" and not (%s)", strings.Join(a, " or "))
+			}
+		case string:
+			if v == "*" {
+				where = "false"
+			}
+		}
+
+		if !client.Access.CanBrowseArchive() || !client.Access.IsInSchedule(time.Now()) {
+			where = "false"
+		} else if start, ok := client.Access.GetArchiveStart(); ok {
+			where += fmt.Sprintf(" and `dateTime` >= '%v'", start.UTC().Format(client.Controller.Database.DateTimeFormat))
+		}
+	}
+
+	switch v := searchOptions.System.(type) {
+	case uint:
+		refs := []TalkgroupRef{{System: v}}
+
+		_, talkgroup := searchOptions.Talkgroup.(uint)
+
+		switch t := searchOptions.Talkgroup.(type) {
+		case uint:
+			refs[0].Talkgroup = t
+			if searchOptions.linkedTalkgroups && client.Controller != nil {
+				refs = client.Controller.Systems.GetLinkedTalkgroups(v, t)
+			}
+		}
+
+		b := []string{}
+		for _, ref := range refs {
+			a := []string{
+				fmt.Sprintf("`system` = %v", ref.System),
+			}
+			if talkgroup {
+				if searchOptions.searchPatchedTalkgroups {
+					a = append(a, fmt.Sprintf("(`talkgroup` = %v or patches = '[%v]' or patches like '[%v,%%' or patches like '%%,%v,%%' or patches like '%%,%v]' or exists (select 1 from `rdioScannerPatches` as p where p.`system` = %v and p.`talkgroup` = %v and p.`patched` = `rdioScannerCalls`.`talkgroup` and `rdioScannerCalls`.`dateTime` between p.`dateTime` and p.`updated`))", ref.Talkgroup, ref.Talkgroup, ref.Talkgroup, ref.Talkgroup, ref.Talkgroup, ref.System, ref.Talkgroup))
+				} else {
+					a = append(a, fmt.Sprintf("`talkgroup` = %v", ref.Talkgroup))
+				}
+			}
+			b = append(b, strings.Join(a, " and "))
+		}
+
+		if len(b) == 1 {
+			where += fmt.Sprintf(" and (%s)", b[0])
+		} else {
+			where += fmt.Sprintf(" and ((%s))", strings.Join(b, ") or ("))
+		}
+	}
+
+	switch v := searchOptions.Conversation.(type) {
+	case uint:
+		where += fmt.Sprintf(" and (`conversation` = %v or `id` = %v)", v, v)
+	}
+
+	switch v := searchOptions.Group.(type) {
+	case string:
+		a := []string{}
+		for id, m := range client.GroupsMap[v] {
+			b := strings.ReplaceAll(fmt.Sprintf("%v", m), " ", ", ")
+			b = strings.ReplaceAll(b, "[", "(")
+			b = strings.ReplaceAll(b, "]", ")")
+			a = append(a, fmt.Sprintf("(`system` = %v and `talkgroup` in %v)", id, b))
+		}
+		if len(a) > 0 {
+			where += fmt.Sprintf(" and (%s)", strings.Join(a, " or "))
+		}
+	}
+
+	switch v := searchOptions.Tag.(type) {
+	case string:
+		a := []string{}
+		for id, m := range client.TagsMap[v] {
+			b := strings.ReplaceAll(fmt.Sprintf("%v", m), " ", ", ")
+			b = strings.ReplaceAll(b, "[", "(")
+			b = strings.ReplaceAll(b, "]", ")")
+			a = append(a, fmt.Sprintf("(`system` = %v and `talkgroup` in %v)", id, b))
+		}
+		if len(a) > 0 {
+			where += fmt.Sprintf(" and (%s)", strings.Join(a, " or "))
+		}
+	}
+
+	switch v := searchOptions.Transcript.(type) {
+	case string:
+		if s := regexp.MustCompile(`[^\p{L}\p{N} ]`).ReplaceAllString(strings.TrimSpace(v), "_"); len(s) > 0 {
+			where += fmt.Sprintf(" and `transcript` like '%%%s%%'", s)
+		}
+	}
+
+	return where
+}
+
+func (calls *Calls) GetTimeline(system uint, talkgroup uint, at time.Time, window uint, client *Client) (*CallsTimeline, error) {
+	const prefetchCount = 3
+
+	var (
+		dateTime    interface{}
+		duration    sql.NullFloat64
+		err         error
+		frequencies string
+		id          sql.NullFloat64
+		patches     string
+		rows        *sql.Rows
+		sources     string
+		t           time.Time
+	)
+
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+
+	db := client.Controller.Database
+
+	formatError := func(err error) error {
+		return fmt.Errorf("calls.gettimeline: %v", err)
+	}
+
+	timeline := &CallsTimeline{
+		DateTime:  at,
+		Prefetch:  []uint{},
+		Results:   []CallsTimelineEntry{},
+		System:    system,
+		Talkgroup: talkgroup,
+	}
+
+	if client.Controller.Accesses.IsRestricted() && !client.Access.HasAccess(&Call{System: system, Talkgroup: talkgroup}) {
+		return timeline, nil
+	}
+
+	from := at.Add(-time.Duration(window) * time.Second).Format(db.DateTimeFormat)
+	to := at.Add(time.Duration(window) * time.Second).Format(db.DateTimeFormat)
+
+	if rows, err = db.Sql.Query("select `id`, `dateTime`, `duration`, `frequencies`, `patches`, `sources` from `rdioScannerCalls` where (`dateTime` between ? and ?) and `system` = ? and `talkgroup` = ? order by `dateTime` asc", from, to, system, talkgroup); err != nil {
+		return nil, formatError(err)
+	}
+
+	current := -1
+
+	for rows.Next() {
+		call := &Call{}
+		entry := CallsTimelineEntry{}
+
+		if err = rows.Scan(&id, &dateTime, &duration, &frequencies, &patches, &sources); err != nil {
+			break
+		}
+
+		if id.Valid && id.Float64 > 0 {
+			entry.Id = uint(id.Float64)
+		}
+
+		if t, err = db.ParseDateTime(dateTime); err == nil && client.Access.HasArchiveAccess(t) {
+			entry.DateTime = t
+		} else {
+			continue
+		}
+
+		if duration.Valid && duration.Float64 > 0 {
+			call.duration = uint(duration.Float64)
+		}
+
+		if len(frequencies) > 0 {
+			json.Unmarshal([]byte(frequencies), &call.Frequencies)
+		}
+
+		if len(patches) > 0 {
+			json.Unmarshal([]byte(patches), &call.Patches)
+		}
+
+		if len(sources) > 0 {
+			json.Unmarshal([]byte(sources), &call.Sources)
+		}
+
+		entry.Duration = call.GetDuration()
+		entry.Events = call.GetEvents()
+		entry.Offset = t.Sub(at).Milliseconds()
+		entry.Position = timeline.Duration
+
+		if current < 0 && !t.Add(time.Duration(entry.Duration)*time.Millisecond).Before(at) {
+			current = len(timeline.Results)
+		}
+
+		timeline.Duration += entry.Duration
+		timeline.Results = append(timeline.Results, entry)
+	}
 
-type Calls struct {
-	mutex sync.Mutex
-}
+	rows.Close()
 
-func NewCalls() *Calls {
-	return &Calls{
-		mutex: sync.Mutex{},
+	if err != nil {
+		return nil, formatError(err)
 	}
-}
 
-func (calls *Calls) CheckDuplicate(call *Call, msTimeFrame uint, db *Database) bool {
-	var count uint
-
-	calls.mutex.Lock()
-	defer calls.mutex.Unlock()
+	if current >= 0 {
+		for i := current; i < len(timeline.Results) && i < current+prefetchCount; i++ {
+			timeline.Prefetch = append(timeline.Prefetch, timeline.Results[i].Id)
+		}
+	}
 
-	d := time.Duration(msTimeFrame) * time.Millisecond
-	from := call.DateTime.Add(-d).Format(db.DateTimeFormat)
-	to := call.DateTime.Add(d).Format(db.DateTimeFormat)
+	if err = db.Sql.QueryRow("select `dateTime` from `rdioScannerCalls` where `dateTime` < ? and `system` = ? and `talkgroup` = ? order by `dateTime` desc", from, system, talkgroup).Scan(&dateTime); err == nil {
+		if t, err = db.ParseDateTime(dateTime); err == nil {
+			timeline.Previous = t
+		}
+	} else if err != sql.ErrNoRows {
+		return nil, formatError(err)
+	}
 
-	query := fmt.Sprintf("select count(*) from `rdioScannerCalls` where (`dateTime` between '%v' and '%v') and `system` = %v and `talkgroup` = %v", from, to, call.System, call.Talkgroup)
-	if err := db.Sql.QueryRow(query).Scan(&count); err != nil {
-		return false
+	if err = db.Sql.QueryRow("select `dateTime` from `rdioScannerCalls` where `dateTime` > ? and `system` = ? and `talkgroup` = ? order by `dateTime` asc", to, system, talkgroup).Scan(&dateTime); err == nil {
+		if t, err = db.ParseDateTime(dateTime); err == nil {
+			timeline.Next = t
+		}
+	} else if err != sql.ErrNoRows {
+		return nil, formatError(err)
 	}
 
-	return count > 0
+	return timeline, nil
 }
 
-func (calls *Calls) GetCall(id uint, db *Database) (*Call, error) {
+func (calls *Calls) Histogram(searchOptions *CallsSearchOptions, client *Client) (*CallsHistogram, error) {
 	var (
-		audioName   sql.NullString
-		audioType   sql.NullString
-		dateTime    interface{}
-		frequency   sql.NullFloat64
-		source      sql.NullFloat64
-		frequencies string
-		patches     string
-		sources     string
-		t           time.Time
+		count uint
+		date  string
+		err   error
+		query string
+		rows  *sql.Rows
 	)
 
 	calls.mutex.Lock()
 	defer calls.mutex.Unlock()
 
-	call := Call{Id: id}
+	db := client.Controller.Database
 
-	query := fmt.Sprintf("select `audio`, `audioName`, `audioType`, `DateTime`, `frequencies`, `frequency`, `patches`, `source`, `sources`, `system`, `talkgroup` from `rdioScannerCalls` where `id` = %v", id)
-	err := db.Sql.QueryRow(query).Scan(&call.Audio, &audioName, &audioType, &dateTime, &frequencies, &frequency, &patches, &source, &sources, &call.System, &call.Talkgroup)
-	if err != nil && err != sql.ErrNoRows {
-		return nil, fmt.Errorf("getcall: %v, %v", err, query)
+	formatError := func(err error) error {
+		return fmt.Errorf("calls.histogram: %v", err)
 	}
 
-	if audioName.Valid {
-		call.AudioName = audioName.String
+	histogram := &CallsHistogram{
+		Days:    []CallsSearchFacet{},
+		Options: searchOptions,
 	}
 
-	if audioType.Valid {
-		call.AudioType = audioType.String
-	}
+	where := calls.getSearchFilter(searchOptions, client)
 
-	if frequency.Valid && frequency.Float64 > 0 {
-		call.Frequency = uint(frequency.Float64)
+	switch v := searchOptions.DateFrom.(type) {
+	case time.Time:
+		where += fmt.Sprintf(" and `dateTime` >= '%v'", v.UTC().Format(db.DateTimeFormat))
 	}
 
-	if t, err = db.ParseDateTime(dateTime); err == nil {
-		call.DateTime = t
-	} else {
-		call.DateTime = time.Time{}
+	switch v := searchOptions.DateTo.(type) {
+	case time.Time:
+		where += fmt.Sprintf(" and `dateTime` < '%v'", v.UTC().Format(db.DateTimeFormat))
 	}
 
-	if len(frequencies) > 0 {
-		if err = json.Unmarshal([]byte(frequencies), &call.Frequencies); err != nil {
-			call.Frequencies = []interface{}{}
+	histogramQuery := func(length int) ([]CallsSearchFacet, error) {
+		facets := []CallsSearchFacet{}
+
+		query = fmt.Sprintf("select substr(`dateTime`, 1, %v) as `period`, count(*) from `rdioScannerCalls` where %v group by `period` order by `period` asc", length, where)
+		if rows, err = db.Sql.Query(query); err != nil {
+			return nil, fmt.Errorf("%v, %v", err, query)
 		}
-	}
 
-	if len(patches) > 0 {
-		if err = json.Unmarshal([]byte(patches), &call.Patches); err != nil {
-			call.Patches = []interface{}{}
+		for rows.Next() {
+			if err = rows.Scan(&date, &count); err != nil {
+				break
+			}
+			facets = append(facets, CallsSearchFacet{Count: count, Date: date})
 		}
+
+		rows.Close()
+
+		return facets, err
 	}
 
-	if source.Valid && source.Float64 > 0 {
-		call.Source = uint(source.Float64)
+	if histogram.Days, err = histogramQuery(10); err != nil {
+		return nil, formatError(err)
 	}
 
-	if len(sources) > 0 {
-		if err = json.Unmarshal([]byte(sources), &call.Sources); err != nil {
-			call.Sources = []interface{}{}
+	if searchOptions.Hours == true {
+		if histogram.Hours, err = histogramQuery(13); err != nil {
+			return nil, formatError(err)
 		}
 	}
 
-	return &call, nil
-}
-
-func (calls *Calls) Prune(db *Database, pruneDays uint) error {
-	calls.mutex.Lock()
-	defer calls.mutex.Unlock()
-
-	date := time.Now().Add(-24 * time.Hour * time.Duration(pruneDays)).Format(db.DateTimeFormat)
-	_, err := db.Sql.Exec("delete from `rdioScannerCalls` where `dateTime` < ?", date)
-
-	return err
+	return histogram, nil
 }
 
 func (calls *Calls) Search(searchOptions *CallsSearchOptions, client *Client) (*CallsSearchResults, error) {
@@ -224,16 +1453,18 @@ func (calls *Calls) Search(searchOptions *CallsSearchOptions, client *Client) (*
 	)
 
 	var (
-		dateTime interface{}
-		err      error
-		id       sql.NullFloat64
-		limit    uint
-		offset   uint
-		order    string
-		query    string
-		rows     *sql.Rows
-		t        time.Time
-		where    string = "true"
+		conversation sql.NullFloat64
+		dateTime     interface{}
+		err          error
+		id           sql.NullFloat64
+		limit        uint
+		offset       uint
+		order        string
+		query        string
+		rows         *sql.Rows
+		t            time.Time
+		transcript   sql.NullString
+		where        string
 	)
 
 	calls.mutex.Lock()
@@ -250,77 +1481,7 @@ func (calls *Calls) Search(searchOptions *CallsSearchOptions, client *Client) (*
 		Results: []CallsSearchResult{},
 	}
 
-	if client.Access != nil {
-		switch v := client.Access.Systems.(type) {
-		case []interface{}:
-			a := []string{}
-			for _, scope := range v {
-				var c string
-				switch v := scope.(type) {
-				case map[string]interface{}:
-					switch v["talkgroups"].(type) {
-					case []interface{}:
-						b := strings.ReplaceAll(fmt.Sprintf("%v", v["talkgroups"]), " ", ", ")
-						b = strings.ReplaceAll(b, "[", "(")
-						b = strings.ReplaceAll(b, "]", ")")
-						c = fmt.Sprintf("(`system` = %v and `talkgroup` in %v)", v["id"], b)
-					case string:
-						if v["talkgroups"] == "*" {
-							c = fmt.Sprintf("`system` = %v", v["id"])
-						}
-					}
-				}
-				if len(c) > 0 {
-					a = append(a, c)
-				}
-			}
-			where = fmt.Sprintf("(%s)", strings.Join(a, " or "))
-		}
-	}
-
-	switch v := searchOptions.System.(type) {
-	case uint:
-		a := []string{
-			fmt.Sprintf("`system` = %v", v),
-		}
-		switch v := searchOptions.Talkgroup.(type) {
-		case uint:
-			if searchOptions.searchPatchedTalkgroups {
-				a = append(a, fmt.Sprintf("`talkgroup` = %v or patches = '%v' or patches like '[%v,%%' or patches like '%%,%v,%%' or patches like '%%,%v]'", v, v, v, v, v))
-			} else {
-				a = append(a, fmt.Sprintf("`talkgroup` = %v", v))
-			}
-		}
-		where += fmt.Sprintf(" and (%s)", strings.Join(a, " and "))
-	}
-
-	switch v := searchOptions.Group.(type) {
-	case string:
-		a := []string{}
-		for id, m := range client.GroupsMap[v] {
-			b := strings.ReplaceAll(fmt.Sprintf("%v", m), " ", ", ")
-			b = strings.ReplaceAll(b, "[", "(")
-			b = strings.ReplaceAll(b, "]", ")")
-			a = append(a, fmt.Sprintf("(`system` = %v and `talkgroup` in %v)", id, b))
-		}
-		if len(a) > 0 {
-			where += fmt.Sprintf(" and (%s)", strings.Join(a, " or "))
-		}
-	}
-
-	switch v := searchOptions.Tag.(type) {
-	case string:
-		a := []string{}
-		for id, m := range client.TagsMap[v] {
-			b := strings.ReplaceAll(fmt.Sprintf("%v", m), " ", ", ")
-			b = strings.ReplaceAll(b, "[", "(")
-			b = strings.ReplaceAll(b, "]", ")")
-			a = append(a, fmt.Sprintf("(`system` = %v and `talkgroup` in %v)", id, b))
-		}
-		if len(a) > 0 {
-			where += fmt.Sprintf(" and (%s)", strings.Join(a, " or "))
-		}
-	}
+	where = calls.getSearchFilter(searchOptions, client)
 
 	query = fmt.Sprintf("select `dateTime` from `rdioScannerCalls` where %v order by `dateTime` asc", where)
 	if err = db.Sql.QueryRow(query).Scan(&dateTime); err != nil && err != sql.ErrNoRows {
@@ -387,21 +1548,37 @@ func (calls *Calls) Search(searchOptions *CallsSearchOptions, client *Client) (*
 		return nil, formatError(fmt.Errorf("%v, %v", err, query))
 	}
 
-	query = fmt.Sprintf("select `id`, `DateTime`, `system`, `talkgroup` from `rdioScannerCalls` where %v order by `dateTime` %v limit %v offset %v", where, order, limit, offset)
+	if searchOptions.Facets == true {
+		if searchResults.Facets, err = calls.getFacets(where, client); err != nil {
+			return nil, formatError(err)
+		}
+	}
+
+	query = fmt.Sprintf("select `id`, `conversation`, `DateTime`, `system`, `talkgroup`, `transcript` from `rdioScannerCalls` where %v order by `dateTime` %v limit %v offset %v", where, order, limit, offset)
 	if rows, err = db.Sql.Query(query); err != nil && err != sql.ErrNoRows {
 		return nil, formatError(fmt.Errorf("%v, %v", err, query))
 	}
 
 	for rows.Next() {
 		searchResult := CallsSearchResult{}
-		if err = rows.Scan(&id, &dateTime, &searchResult.System, &searchResult.Talkgroup); err != nil {
+		if err = rows.Scan(&id, &conversation, &dateTime, &searchResult.System, &searchResult.Talkgroup, &transcript); err != nil {
 			break
 		}
 
+		if transcript.Valid {
+			searchResult.Transcript = transcript.String
+		}
+
 		if id.Valid && id.Float64 > 0 {
 			searchResult.Id = uint(id.Float64)
 		}
 
+		if conversation.Valid && conversation.Float64 > 0 {
+			searchResult.Conversation = uint(conversation.Float64)
+		} else {
+			searchResult.Conversation = searchResult.Id
+		}
+
 		if t, err = db.ParseDateTime(dateTime); err == nil {
 			searchResult.DateTime = t
 
@@ -421,24 +1598,87 @@ func (calls *Calls) Search(searchOptions *CallsSearchOptions, client *Client) (*
 	return searchResults, err
 }
 
+func (calls *Calls) WriteAlternates(id uint, alternates []*Call, maxSize int64, db *Database) error {
+	var (
+		b           []byte
+		err         error
+		frequencies string
+	)
+
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("calls.writealternates: %v", err)
+	}
+
+	for _, call := range alternates {
+		frequencies = ""
+
+		if err = call.LoadAudio(maxSize); err != nil {
+			return formatError(err)
+		}
+
+		switch v := call.Frequencies.(type) {
+		case []map[string]interface{}:
+			if b, err = json.Marshal(v); err == nil {
+				frequencies = string(b)
+			}
+		}
+
+		if _, err = db.Sql.Exec("insert into `rdioScannerCallAlternates` (`callId`, `audio`, `audioName`, `audioType`, `dateTime`, `frequencies`, `score`, `site`) values (?, ?, ?, ?, ?, ?, ?, ?)", id, call.Audio, call.AudioName, call.AudioType, call.DateTime, frequencies, GetVotingScore(call), call.site); err != nil {
+			return formatError(err)
+		}
+	}
+
+	return nil
+}
+
 func (calls *Calls) WriteCall(call *Call, db *Database) (uint, error) {
 	var (
+		audio       = call.Audio
+		fingerprint interface{}
+		audioHash   interface{}
+		audioKey    interface{}
 		b           []byte
 		err         error
 		frequencies string
 		id          int64
+		loudness    interface{}
 		patches     string
 		res         sql.Result
 		sources     string
+		transcript  interface{}
 	)
 
-	calls.mutex.Lock()
-	defer calls.mutex.Unlock()
-
 	formatError := func(err error) error {
 		return fmt.Errorf("call.write: %s", err.Error())
 	}
 
+	if len(call.transcript) > 0 {
+		transcript = call.transcript
+	}
+
+	if calls.storage != nil && calls.storage.IsRemote() {
+		contentType := ""
+		switch v := call.AudioType.(type) {
+		case string:
+			contentType = v
+		}
+
+		key := calls.storage.NewKey(call)
+
+		if err = calls.storage.Put(key, call.Audio, contentType); err == nil {
+			audio = []byte{}
+			audioKey = key
+		} else {
+			calls.storage.controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("%v, falling back to database audio storage", err))
+		}
+	}
+
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+
 	switch v := call.Frequencies.(type) {
 	case []map[string]interface{}:
 		if b, err = json.Marshal(v); err == nil {
@@ -466,30 +1706,102 @@ func (calls *Calls) WriteCall(call *Call, db *Database) (uint, error) {
 		}
 	}
 
-	if res, err = db.Sql.Exec("insert into `rdioScannerCalls` (`id`, `audio`, `audioName`, `audioType`, `dateTime`, `frequencies`, `frequency`, `patches`, `source`, `sources`, `system`, `talkgroup`) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", call.Id, call.Audio, call.AudioName, call.AudioType, call.DateTime, frequencies, call.Frequency, patches, call.Source, sources, call.System, call.Talkgroup); err != nil {
+	if len(call.loudness) > 0 {
+		if b, err = json.Marshal(call.loudness); err == nil {
+			loudness = string(b)
+		} else {
+			return 0, formatError(err)
+		}
+	}
+
+	if len(call.audioFingerprint) > 0 {
+		fingerprint = call.audioFingerprint
+	}
+
+	if len(call.audioHash) > 0 {
+		audioHash = call.audioHash
+	}
+
+	if res, err = db.Sql.Exec("insert into `rdioScannerCalls` (`id`, `audio`, `audioFingerprint`, `audioHash`, `audioKey`, `audioName`, `audioType`, `conversation`, `dateTime`, `duration`, `frequencies`, `frequency`, `loudness`, `patches`, `source`, `sources`, `system`, `talkgroup`, `transcript`, `transcriptConfidence`) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", call.Id, audio, fingerprint, audioHash, audioKey, call.AudioName, call.AudioType, call.Conversation, call.DateTime, call.GetDuration(), frequencies, call.Frequency, loudness, patches, call.Source, sources, call.System, call.Talkgroup, transcript, call.transcriptScore); err != nil {
 		return 0, formatError(err)
 	}
 
-	if id, err = res.LastInsertId(); err == nil {
-		return uint(id), nil
-	} else {
+	if id, err = res.LastInsertId(); err != nil {
 		return 0, formatError(err)
 	}
+
+	if call.Conversation == nil {
+		if _, err = db.Sql.Exec("update `rdioScannerCalls` set `conversation` = ? where `id` = ?", id, id); err != nil {
+			return 0, formatError(err)
+		}
+		call.Conversation = uint(id)
+	}
+
+	return uint(id), nil
+}
+
+func (calls *Calls) WriteTranscript(id uint, transcript string, db *Database) error {
+	calls.mutex.Lock()
+	defer calls.mutex.Unlock()
+
+	if _, err := db.Sql.Exec("update `rdioScannerCalls` set `transcript` = ? where `id` = ?", transcript, id); err != nil {
+		return fmt.Errorf("call.writetranscript: %v", err)
+	}
+
+	return nil
+}
+
+type CallsHistogram struct {
+	Days    []CallsSearchFacet  `json:"days"`
+	Hours   []CallsSearchFacet  `json:"hours,omitempty"`
+	Options *CallsSearchOptions `json:"options"`
+}
+
+type CallsTimeline struct {
+	DateTime  time.Time            `json:"dateTime"`
+	Duration  uint                 `json:"duration"`
+	Next      interface{}          `json:"next,omitempty"`
+	Prefetch  []uint               `json:"prefetch"`
+	Previous  interface{}          `json:"previous,omitempty"`
+	Results   []CallsTimelineEntry `json:"results"`
+	System    uint                 `json:"system"`
+	Talkgroup uint                 `json:"talkgroup"`
+}
+
+type CallsTimelineEntry struct {
+	Id       uint        `json:"id"`
+	DateTime time.Time   `json:"dateTime"`
+	Duration uint        `json:"duration"`
+	Events   []CallEvent `json:"events"`
+	Offset   int64       `json:"offset"`
+	Position uint        `json:"position"`
 }
 
 type CallsSearchOptions struct {
+	Conversation            interface{} `json:"conversation,omitempty"`
 	Date                    interface{} `json:"date,omitempty"`
+	DateFrom                interface{} `json:"dateFrom,omitempty"`
+	DateTo                  interface{} `json:"dateTo,omitempty"`
+	Facets                  interface{} `json:"facets,omitempty"`
 	Group                   interface{} `json:"group,omitempty"`
+	Hours                   interface{} `json:"hours,omitempty"`
 	Limit                   interface{} `json:"limit,omitempty"`
 	Offset                  interface{} `json:"offset,omitempty"`
 	Sort                    interface{} `json:"sort,omitempty"`
 	System                  interface{} `json:"system,omitempty"`
 	Tag                     interface{} `json:"tag,omitempty"`
 	Talkgroup               interface{} `json:"talkgroup,omitempty"`
+	Transcript              interface{} `json:"transcript,omitempty"`
+	linkedTalkgroups        bool
 	searchPatchedTalkgroups bool
 }
 
 func (searchOptions *CallsSearchOptions) fromMap(m map[string]interface{}) error {
+	switch v := m["conversation"].(type) {
+	case float64:
+		searchOptions.Conversation = uint(v)
+	}
+
 	switch v := m["date"].(type) {
 	case string:
 		if t, err := time.Parse(time.RFC3339, v); err == nil {
@@ -497,11 +1809,35 @@ func (searchOptions *CallsSearchOptions) fromMap(m map[string]interface{}) error
 		}
 	}
 
+	switch v := m["dateFrom"].(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			searchOptions.DateFrom = t
+		}
+	}
+
+	switch v := m["dateTo"].(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			searchOptions.DateTo = t
+		}
+	}
+
+	switch v := m["facets"].(type) {
+	case bool:
+		searchOptions.Facets = v
+	}
+
 	switch v := m["group"].(type) {
 	case string:
 		searchOptions.Group = v
 	}
 
+	switch v := m["hours"].(type) {
+	case bool:
+		searchOptions.Hours = v
+	}
+
 	switch v := m["limit"].(type) {
 	case float64:
 		searchOptions.Limit = uint(v)
@@ -532,20 +1868,74 @@ func (searchOptions *CallsSearchOptions) fromMap(m map[string]interface{}) error
 		searchOptions.Talkgroup = uint(v)
 	}
 
+	switch v := m["transcript"].(type) {
+	case string:
+		searchOptions.Transcript = v
+	}
+
 	return nil
 }
 
+type CallsSearchFacet struct {
+	Count     uint   `json:"count"`
+	Date      string `json:"date,omitempty"`
+	System    uint   `json:"system,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	Talkgroup uint   `json:"talkgroup,omitempty"`
+}
+
+type CallsSearchFacets struct {
+	Days       []CallsSearchFacet `json:"days"`
+	Systems    []CallsSearchFacet `json:"systems"`
+	Tags       []CallsSearchFacet `json:"tags"`
+	Talkgroups []CallsSearchFacet `json:"talkgroups"`
+}
+
 type CallsSearchResult struct {
-	Id        uint      `json:"id"`
-	DateTime  time.Time `json:"dateTime"`
-	System    uint      `json:"system"`
-	Talkgroup uint      `json:"talkgroup"`
+	Id           uint      `json:"id"`
+	Conversation uint      `json:"conversation"`
+	DateTime     time.Time `json:"dateTime"`
+	System       uint      `json:"system"`
+	Talkgroup    uint      `json:"talkgroup"`
+	Transcript   string    `json:"transcript,omitempty"`
 }
 
 type CallsSearchResults struct {
 	Count     uint                `json:"count"`
 	DateStart time.Time           `json:"dateStart"`
 	DateStop  time.Time           `json:"dateStop"`
+	Facets    *CallsSearchFacets  `json:"facets,omitempty"`
 	Options   *CallsSearchOptions `json:"options"`
 	Results   []CallsSearchResult `json:"results"`
 }
+
+func getCallEntries(v interface{}) []map[string]interface{} {
+	entries := []map[string]interface{}{}
+
+	switch v := v.(type) {
+	case []map[string]interface{}:
+		entries = append(entries, v...)
+	case []interface{}:
+		for _, e := range v {
+			switch e := e.(type) {
+			case map[string]interface{}:
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	return entries
+}
+
+func getCallNumber(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,728 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CallStatsBucketDay  = "day"
+	CallStatsBucketHour = "hour"
+	CallStatsBucketWeek = "week"
+	CallStatsBackfill   = 168
+	CallStatsInterval   = 5 * time.Minute
+	CallStatsMaxBuckets = 1000
+	CallStatsMaxTop     = 100
+)
+
+type CallStats struct {
+	controller *Controller
+	mutex      sync.Mutex
+	ticker     *time.Ticker
+}
+
+type CallStatsBucket struct {
+	DateTime time.Time `json:"dateTime"`
+	Calls    uint      `json:"calls"`
+	Airtime  uint      `json:"airtime"`
+}
+
+type CallStatsOptions struct {
+	Bucket    string      `json:"bucket"`
+	From      time.Time   `json:"from"`
+	Linked    bool        `json:"linked,omitempty"`
+	System    interface{} `json:"system,omitempty"`
+	Talkgroup interface{} `json:"talkgroup,omitempty"`
+	To        time.Time   `json:"to"`
+	Top       uint        `json:"top"`
+}
+
+type CallStatsResult struct {
+	Options    CallStatsOptions      `json:"options"`
+	Calls      uint                  `json:"calls"`
+	Airtime    uint                  `json:"airtime"`
+	Listeners  uint                  `json:"listeners"`
+	Buckets    []CallStatsBucket     `json:"buckets"`
+	Talkgroups []*CallStatsTalkgroup `json:"talkgroups"`
+	Units      []CallStatsUnit       `json:"units"`
+}
+
+type CallStatsTalkgroup struct {
+	System         uint              `json:"system"`
+	SystemLabel    string            `json:"systemLabel,omitempty"`
+	Talkgroup      uint              `json:"talkgroup"`
+	TalkgroupLabel string            `json:"talkgroupLabel,omitempty"`
+	Calls          uint              `json:"calls"`
+	Airtime        uint              `json:"airtime"`
+	Listeners      uint              `json:"listeners"`
+	Buckets        []CallStatsBucket `json:"buckets"`
+	Units          []CallStatsUnit   `json:"units"`
+}
+
+type CallStatsUnit struct {
+	System uint   `json:"system"`
+	Unit   uint   `json:"unit"`
+	Label  string `json:"label,omitempty"`
+	Calls  uint   `json:"calls"`
+}
+
+func NewCallStats(controller *Controller) *CallStats {
+	return &CallStats{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (callStats *CallStats) Aggregate(db *Database, hour int64) error {
+	type aggregate struct {
+		airtime uint64
+		calls   uint
+		units   map[uint]uint
+	}
+
+	var (
+		audioBytes sql.NullInt64
+		duration   sql.NullFloat64
+		err        error
+		rows       *sql.Rows
+		source     sql.NullFloat64
+		sources    string
+		system     uint
+		talkgroup  uint
+		tx         *sql.Tx
+	)
+
+	callStats.mutex.Lock()
+	defer callStats.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("callstats.aggregate: %v", err)
+	}
+
+	start := time.Unix(hour*3600, 0).UTC()
+	stop := start.Add(time.Hour - time.Millisecond)
+
+	aggregates := map[[2]uint]*aggregate{}
+
+	if rows, err = db.Sql.Query("select `system`, `talkgroup`, `duration`, length(`audio`), `source`, `sources` from `rdioScannerCalls` where `dateTime` between ? and ?", start.Format(db.DateTimeFormat), stop.Format(db.DateTimeFormat)); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&system, &talkgroup, &duration, &audioBytes, &source, &sources); err != nil {
+			break
+		}
+
+		key := [2]uint{system, talkgroup}
+
+		a := aggregates[key]
+		if a == nil {
+			a = &aggregate{units: map[uint]uint{}}
+			aggregates[key] = a
+		}
+
+		a.calls++
+
+		if duration.Valid && duration.Float64 > 0 {
+			a.airtime += uint64(duration.Float64)
+		} else if audioBytes.Valid && audioBytes.Int64 > 0 {
+			a.airtime += uint64(audioBytes.Int64) * 8 * 1000 / StatisticsAudioBitrate
+		}
+
+		units := map[uint]bool{}
+
+		if source.Valid && source.Float64 > 0 {
+			units[uint(source.Float64)] = true
+		}
+
+		var list []map[string]interface{}
+		if err := json.Unmarshal([]byte(sources), &list); err == nil {
+			for _, s := range list {
+				if src, ok := s["src"].(float64); ok && src > 0 {
+					units[uint(src)] = true
+				}
+			}
+		}
+
+		for unit := range units {
+			a.units[unit]++
+		}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if tx, err = db.Sql.Begin(); err != nil {
+		return formatError(err)
+	}
+
+	if _, err = tx.Exec("delete from `rdioScannerCallStats` where `hour` = ?", hour); err != nil {
+		tx.Rollback()
+		return formatError(err)
+	}
+
+	if _, err = tx.Exec("delete from `rdioScannerUnitStats` where `hour` = ?", hour); err != nil {
+		tx.Rollback()
+		return formatError(err)
+	}
+
+	for key, a := range aggregates {
+		if _, err = tx.Exec("insert into `rdioScannerCallStats` (`hour`, `system`, `talkgroup`, `calls`, `airtime`) values (?, ?, ?, ?, ?)", hour, key[0], key[1], a.calls, a.airtime); err != nil {
+			tx.Rollback()
+			return formatError(err)
+		}
+
+		for unit, calls := range a.units {
+			if _, err = tx.Exec("insert into `rdioScannerUnitStats` (`hour`, `system`, `talkgroup`, `unit`, `calls`) values (?, ?, ?, ?, ?)", hour, key[0], key[1], unit, calls); err != nil {
+				tx.Rollback()
+				return formatError(err)
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return formatError(err)
+	}
+
+	return nil
+}
+
+func (callStats *CallStats) AggregatePending(db *Database) (bool, error) {
+	var (
+		dateTime interface{}
+		last     string
+		start    int64
+	)
+
+	formatError := func(err error) (bool, error) {
+		return false, fmt.Errorf("callstats.aggregatepending: %v", err)
+	}
+
+	current := time.Now().Unix() / 3600
+
+	if err := db.Sql.QueryRow("select `val` from `rdioScannerConfigs` where `key` = 'callStatsAggregate'").Scan(&last); err == nil {
+		if start, err = strconv.ParseInt(last, 10, 64); err != nil {
+			return formatError(err)
+		}
+		start++
+
+	} else if err != sql.ErrNoRows {
+		return formatError(err)
+
+	} else if err = db.Sql.QueryRow("select `dateTime` from `rdioScannerCalls` order by `dateTime` asc limit 1").Scan(&dateTime); err == nil {
+		t, err := db.ParseDateTime(dateTime)
+		if err != nil {
+			return formatError(err)
+		}
+		start = t.Unix() / 3600
+
+	} else if err == sql.ErrNoRows {
+		start = current
+
+	} else {
+		return formatError(err)
+	}
+
+	if start > current-1 {
+		start = current - 1
+	}
+
+	stop := current
+	if stop-start >= CallStatsBackfill {
+		stop = start + CallStatsBackfill - 1
+	}
+
+	for hour := start; hour <= stop; hour++ {
+		if err := callStats.Aggregate(db, hour); err != nil {
+			return false, err
+		}
+	}
+
+	more := stop < current
+	if !more {
+		stop--
+	}
+
+	val := strconv.FormatInt(stop, 10)
+
+	if res, err := db.Sql.Exec("update `rdioScannerConfigs` set `val` = ? where `key` = 'callStatsAggregate'", val); err != nil {
+		return formatError(err)
+
+	} else if i, err := res.RowsAffected(); err == nil && i == 0 {
+		if _, err = db.Sql.Exec("insert into `rdioScannerConfigs` (`key`, `val`) values (?, ?)", "callStatsAggregate", val); err != nil {
+			return formatError(err)
+		}
+	}
+
+	return more, nil
+}
+
+func (callStats *CallStats) Query(db *Database, options *CallStatsOptions) (*CallStatsResult, error) {
+	var (
+		airtime    uint64
+		args       []interface{}
+		bucket     int64
+		calls      uint64
+		err        error
+		filter     string
+		filterArgs []interface{}
+		listener   string
+		rows       *sql.Rows
+		system     uint
+		talkgroup  uint
+		unit       uint
+		where      = "`hour` between ? and ?"
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("callstats.query: %v", err)
+	}
+
+	size, offset := options.GetBucketSize()
+
+	from := options.From.Unix() / 3600
+	from -= (from + offset) % size
+	to := options.To.Unix() / 3600
+
+	var links map[TalkgroupRef][]TalkgroupRef
+	if options.Linked {
+		links = callStats.controller.Systems.GetTalkgroupLinks()
+	}
+
+	system, hasSystem := options.System.(uint)
+	talkgroup, hasTalkgroup := options.Talkgroup.(uint)
+
+	if refs := links[TalkgroupRef{System: system, Talkgroup: talkgroup}]; hasSystem && hasTalkgroup && len(refs) > 0 {
+		a := []string{}
+		for _, ref := range refs {
+			a = append(a, "(`system` = ? and `talkgroup` = ?)")
+			filterArgs = append(filterArgs, ref.System, ref.Talkgroup)
+		}
+		filter += fmt.Sprintf(" and (%s)", strings.Join(a, " or "))
+
+	} else {
+		if hasSystem {
+			filter += " and `system` = ?"
+			filterArgs = append(filterArgs, system)
+		}
+
+		if hasTalkgroup {
+			filter += " and `talkgroup` = ?"
+			filterArgs = append(filterArgs, talkgroup)
+		}
+	}
+
+	where += filter
+	args = append(append(args, from, to), filterArgs...)
+
+	result := &CallStatsResult{
+		Options:    *options,
+		Buckets:    []CallStatsBucket{},
+		Talkgroups: []*CallStatsTalkgroup{},
+		Units:      []CallStatsUnit{},
+	}
+
+	buckets := map[int64]*CallStatsBucket{}
+	for h := from; h <= to; h += size {
+		result.Buckets = append(result.Buckets, CallStatsBucket{DateTime: time.Unix(h*3600, 0).UTC()})
+	}
+	for i := range result.Buckets {
+		buckets[result.Buckets[i].DateTime.Unix()/3600] = &result.Buckets[i]
+	}
+
+	talkgroups := map[[2]uint]*CallStatsTalkgroup{}
+
+	getTalkgroup := func(system uint, talkgroup uint) *CallStatsTalkgroup {
+		if refs := links[TalkgroupRef{System: system, Talkgroup: talkgroup}]; len(refs) > 0 {
+			system, talkgroup = refs[0].System, refs[0].Talkgroup
+		}
+
+		key := [2]uint{system, talkgroup}
+
+		if t := talkgroups[key]; t != nil {
+			return t
+		}
+
+		t := &CallStatsTalkgroup{
+			System:    system,
+			Talkgroup: talkgroup,
+			Buckets:   []CallStatsBucket{},
+			Units:     []CallStatsUnit{},
+		}
+
+		if s, ok := callStats.controller.Systems.GetSystem(system); ok {
+			t.SystemLabel = s.Label
+
+			if tg, ok := s.Talkgroups.GetTalkgroup(talkgroup); ok {
+				t.TalkgroupLabel = tg.Label
+			}
+		}
+
+		talkgroups[key] = t
+		result.Talkgroups = append(result.Talkgroups, t)
+
+		return t
+	}
+
+	query := fmt.Sprintf("select (`hour` - (`hour` + ?) %% ?) as `bucket`, `system`, `talkgroup`, sum(`calls`), sum(`airtime`) from `rdioScannerCallStats` where %s group by `bucket`, `system`, `talkgroup` order by `bucket`", where)
+	if rows, err = db.Sql.Query(query, append([]interface{}{offset, size}, args...)...); err != nil {
+		return nil, formatError(err)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&bucket, &system, &talkgroup, &calls, &airtime); err != nil {
+			break
+		}
+
+		seconds := uint((airtime + 500) / 1000)
+
+		dateTime := time.Unix(bucket*3600, 0).UTC()
+
+		t := getTalkgroup(system, talkgroup)
+		t.Calls += uint(calls)
+		t.Airtime += seconds
+
+		if l := len(t.Buckets); l > 0 && t.Buckets[l-1].DateTime.Equal(dateTime) {
+			t.Buckets[l-1].Calls += uint(calls)
+			t.Buckets[l-1].Airtime += seconds
+		} else {
+			t.Buckets = append(t.Buckets, CallStatsBucket{DateTime: dateTime, Calls: uint(calls), Airtime: seconds})
+		}
+
+		if b := buckets[bucket]; b != nil {
+			b.Calls += uint(calls)
+			b.Airtime += seconds
+		}
+
+		result.Calls += uint(calls)
+		result.Airtime += seconds
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	listeners := map[string]bool{}
+	talkgroupListeners := map[*CallStatsTalkgroup]map[string]bool{}
+
+	query = fmt.Sprintf("select distinct `system`, `talkgroup`, `listener` from `rdioScannerListenerStats` where `date` between ? and ?%s", filter)
+	if rows, err = db.Sql.Query(query, append([]interface{}{options.From.UTC().Format(StatisticsDateFormat), options.To.UTC().Format(StatisticsDateFormat)}, filterArgs...)...); err != nil {
+		return nil, formatError(err)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&system, &talkgroup, &listener); err != nil {
+			break
+		}
+
+		t := getTalkgroup(system, talkgroup)
+		if talkgroupListeners[t] == nil {
+			talkgroupListeners[t] = map[string]bool{}
+		}
+		talkgroupListeners[t][listener] = true
+
+		listeners[listener] = true
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	for t, m := range talkgroupListeners {
+		t.Listeners = uint(len(m))
+	}
+
+	result.Listeners = uint(len(listeners))
+
+	sort.Slice(result.Talkgroups, func(i int, j int) bool {
+		if result.Talkgroups[i].Calls == result.Talkgroups[j].Calls {
+			if result.Talkgroups[i].System == result.Talkgroups[j].System {
+				return result.Talkgroups[i].Talkgroup < result.Talkgroups[j].Talkgroup
+			}
+			return result.Talkgroups[i].System < result.Talkgroups[j].System
+		}
+		return result.Talkgroups[i].Calls > result.Talkgroups[j].Calls
+	})
+
+	getUnitLabel := func(system uint, unit uint) string {
+		if s, ok := callStats.controller.Systems.GetSystem(system); ok {
+			if u, ok := s.Units.GetUnit(unit); ok {
+				return u.Label
+			}
+		}
+		return ""
+	}
+
+	getUnits := func(m map[[2]uint]*CallStatsUnit) []CallStatsUnit {
+		list := []CallStatsUnit{}
+
+		for _, u := range m {
+			list = append(list, *u)
+		}
+
+		sort.Slice(list, func(i int, j int) bool {
+			if list[i].Calls == list[j].Calls {
+				if list[i].System == list[j].System {
+					return list[i].Unit < list[j].Unit
+				}
+				return list[i].System < list[j].System
+			}
+			return list[i].Calls > list[j].Calls
+		})
+
+		if uint(len(list)) > options.Top {
+			list = list[:options.Top]
+		}
+
+		for i := range list {
+			list[i].Label = getUnitLabel(list[i].System, list[i].Unit)
+		}
+
+		return list
+	}
+
+	addUnit := func(m map[[2]uint]*CallStatsUnit, system uint, unit uint, calls uint) {
+		key := [2]uint{system, unit}
+		if u := m[key]; u != nil {
+			u.Calls += calls
+		} else {
+			m[key] = &CallStatsUnit{System: system, Unit: unit, Calls: calls}
+		}
+	}
+
+	units := map[[2]uint]*CallStatsUnit{}
+	talkgroupUnits := map[*CallStatsTalkgroup]map[[2]uint]*CallStatsUnit{}
+
+	query = fmt.Sprintf("select `system`, `talkgroup`, `unit`, sum(`calls`) as `total` from `rdioScannerUnitStats` where %s group by `system`, `talkgroup`, `unit` order by `total` desc, `unit` asc", where)
+	if rows, err = db.Sql.Query(query, args...); err != nil {
+		return nil, formatError(err)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&system, &talkgroup, &unit, &calls); err != nil {
+			break
+		}
+
+		if t := getTalkgroup(system, talkgroup); t != nil {
+			if talkgroupUnits[t] == nil {
+				talkgroupUnits[t] = map[[2]uint]*CallStatsUnit{}
+			}
+			addUnit(talkgroupUnits[t], system, unit, uint(calls))
+		}
+
+		addUnit(units, system, unit, uint(calls))
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	for t, m := range talkgroupUnits {
+		t.Units = getUnits(m)
+	}
+
+	result.Units = getUnits(units)
+
+	return result, nil
+}
+
+func (callStats *CallStats) Start() {
+	run := func() {
+		controller := callStats.controller
+
+		for {
+			if ok, err := controller.Leases.Acquire(controller.Database, "callStats", LeaseTimeout); !ok {
+				if err != nil {
+					controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("callstats.start: %v", err))
+				}
+				return
+			}
+
+			more, err := callStats.AggregatePending(controller.Database)
+			if err != nil {
+				controller.Logs.LogEvent(LogLevelError, err.Error())
+			}
+
+			if err != nil || !more {
+				return
+			}
+		}
+	}
+
+	callStats.ticker = time.NewTicker(CallStatsInterval)
+
+	go func() {
+		run()
+
+		for range callStats.ticker.C {
+			run()
+		}
+	}()
+}
+
+func (options *CallStatsOptions) FromQuery(query url.Values) error {
+	parseTime := func(s string) (time.Time, error) {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.UnixMilli(i).UTC(), nil
+		}
+		return time.Parse(time.RFC3339, s)
+	}
+
+	options.Bucket = CallStatsBucketDay
+	options.To = time.Now().UTC()
+	options.Top = 10
+
+	switch v := query.Get("bucket"); v {
+	case "":
+	case CallStatsBucketDay, CallStatsBucketHour, CallStatsBucketWeek:
+		options.Bucket = v
+	default:
+		return fmt.Errorf("invalid bucket %s", v)
+	}
+
+	size, _ := options.GetBucketSize()
+
+	if v := query.Get("to"); len(v) > 0 {
+		t, err := parseTime(v)
+		if err != nil {
+			return fmt.Errorf("invalid to %s", v)
+		}
+		options.To = t.UTC()
+	}
+
+	if v := query.Get("from"); len(v) > 0 {
+		t, err := parseTime(v)
+		if err != nil {
+			return fmt.Errorf("invalid from %s", v)
+		}
+		options.From = t.UTC()
+	} else {
+		options.From = options.To.Add(-time.Duration(size*29) * time.Hour)
+	}
+
+	if options.From.After(options.To) {
+		return fmt.Errorf("from is after to")
+	}
+
+	if options.To.Sub(options.From)/(time.Duration(size)*time.Hour) >= CallStatsMaxBuckets {
+		return fmt.Errorf("too many %s buckets, maximum is %d", options.Bucket, CallStatsMaxBuckets)
+	}
+
+	switch query.Get("linked") {
+	case "1", "true":
+		options.Linked = true
+	}
+
+	if v := query.Get("system"); len(v) > 0 {
+		i, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid system %s", v)
+		}
+		options.System = uint(i)
+	}
+
+	if v := query.Get("talkgroup"); len(v) > 0 {
+		i, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid talkgroup %s", v)
+		}
+		options.Talkgroup = uint(i)
+	}
+
+	if v := query.Get("top"); len(v) > 0 {
+		i, err := strconv.ParseUint(v, 10, 32)
+		if err != nil || i > CallStatsMaxTop {
+			return fmt.Errorf("invalid top %s", v)
+		}
+		options.Top = uint(i)
+	}
+
+	return nil
+}
+
+func (options *CallStatsOptions) GetBucketSize() (size int64, offset int64) {
+	switch options.Bucket {
+	case CallStatsBucketHour:
+		return 1, 0
+	case CallStatsBucketWeek:
+		return 168, 72
+	default:
+		return 24, 0
+	}
+}
+
+func (admin *Admin) StatsHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		options := CallStatsOptions{}
+		if err := options.FromQuery(r.URL.Query()); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+			return
+		}
+
+		timing := NewServerTiming()
+
+		stop := timing.Start("db")
+		result, err := admin.Controller.CallStats.Query(admin.Controller.Database, &options)
+		if err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, err.Error())
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+		stop()
+
+		stop = timing.Start("serialize")
+		if b, err := json.Marshal(result); err == nil {
+			stop()
+			if admin.Controller.Options.ServerTiming {
+				timing.SetHeader(w)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
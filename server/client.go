@@ -20,12 +20,16 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
+const ClientSendQueueSize = 64
+
 type Client struct {
 	Access     *Access
 	AuthCount  int
@@ -37,7 +41,15 @@ type Client struct {
 	TagsMap    TagsMap
 	Livefeed   *Livefeed
 	SystemsMap SystemsMap
+	audioUrl   bool
+	connected  time.Time
+	delivered  map[uint]time.Time
+	dedupMutex sync.Mutex
+	id         string
+	listening  time.Time
+	queue      string
 	request    *http.Request
+	room       string
 }
 
 func (client *Client) Init(controller *Controller, request *http.Request, conn *websocket.Conn) error {
@@ -56,12 +68,8 @@ func (client *Client) Init(controller *Controller, request *http.Request, conn *
 		return nil
 	}
 
-	client.Access = &Access{}
-	client.Controller = controller
 	client.Conn = conn
-	client.Livefeed = NewLivefeed()
-	client.Send = make(chan *Message)
-	client.request = request
+	client.init(controller, request)
 
 	controller.Register <- client
 
@@ -126,16 +134,19 @@ func (client *Client) Init(controller *Controller, request *http.Request, conn *
 					timer.Stop()
 				}
 
-				b, err := message.ToJson()
+				client.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+
+				w, err := client.Conn.NextWriter(websocket.TextMessage)
 				if err != nil {
-					log.Println(fmt.Errorf("client.message.tojson: %v", err))
+					return
+				}
 
-				} else {
-					client.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+				if err = message.WriteJson(w); err != nil {
+					log.Println(fmt.Errorf("client.message.writejson: %v", err))
+				}
 
-					if err = client.Conn.WriteMessage(websocket.TextMessage, b); err != nil {
-						return
-					}
+				if err = w.Close(); err != nil {
+					return
 				}
 
 			case <-ticker.C:
@@ -151,10 +162,55 @@ func (client *Client) Init(controller *Controller, request *http.Request, conn *
 	return nil
 }
 
+func (client *Client) init(controller *Controller, request *http.Request) {
+	client.Access = &Access{}
+	client.Controller = controller
+	client.connected = time.Now()
+	client.delivered = map[uint]time.Time{}
+	client.Livefeed = NewLivefeed()
+	client.Send = make(chan *Message, ClientSendQueueSize)
+	client.id = strings.Split(uuid.New().String(), "-")[0]
+	client.request = request
+}
+
+func (client *Client) Close() {
+	if client.Conn != nil {
+		client.Conn.Close()
+	} else {
+		client.Controller.HttpClients.Remove(client)
+	}
+}
+
 func (client *Client) GetRemoteAddr() string {
 	return GetRemoteAddr(client.request)
 }
 
+func (client *Client) IsDelivered(call *Call, window time.Duration) bool {
+	id, ok := call.Id.(uint)
+	if !ok || window <= 0 {
+		return false
+	}
+
+	client.dedupMutex.Lock()
+	defer client.dedupMutex.Unlock()
+
+	now := time.Now()
+
+	for k, t := range client.delivered {
+		if now.Sub(t) > window {
+			delete(client.delivered, k)
+		}
+	}
+
+	if _, ok := client.delivered[id]; ok {
+		return true
+	}
+
+	client.delivered[id] = now
+
+	return false
+}
+
 func (client *Client) SendConfig(groups *Groups, options *Options, systems *Systems, tags *Tags) {
 	defer func() {
 		recover()
@@ -165,11 +221,16 @@ func (client *Client) SendConfig(groups *Groups, options *Options, systems *Syst
 	client.TagsMap = tags.GetTagsMap(&client.SystemsMap)
 
 	var payload = map[string]interface{}{
+		"archive":            client.Access.CanBrowseArchive(),
 		"dimmerDelay":        options.DimmerDelay,
+		"directory":          len(options.DirectoryUrl) > 0,
 		"groups":             client.GroupsMap,
 		"keypadBeeps":        GetKeypadBeeps(options),
+		"listeningRooms":     options.ListeningRooms,
 		"playbackGoesLive":   options.PlaybackGoesLive,
+		"serverQueue":        options.ServerQueue,
 		"showListenersCount": options.ShowListenersCount,
+		"streamOnly":         client.Access != nil && client.Access.StreamOnly,
 		"systems":            client.SystemsMap,
 		"tags":               client.TagsMap,
 		"tagsToggle":         options.TagsToggle,
@@ -179,6 +240,10 @@ func (client *Client) SendConfig(groups *Groups, options *Options, systems *Syst
 		payload["afs"] = options.AfsSystems
 	}
 
+	if client.Access != nil && client.Access.Archive == AccessArchiveLimited && client.Access.ArchiveHours > 0 {
+		payload["archiveHours"] = client.Access.ArchiveHours
+	}
+
 	client.Send <- &Message{Command: MessageCommandConfig, Payload: payload}
 }
 
@@ -217,6 +282,27 @@ func (clients *Clients) AccessCount(client *Client) int {
 	return count
 }
 
+func (clients *Clients) AccessUsage(access *Access) (int, time.Duration) {
+	var (
+		count  = 0
+		active time.Duration
+		now    = time.Now()
+	)
+
+	clients.Map.Range(func(k interface{}, _ interface{}) bool {
+		switch c := k.(type) {
+		case *Client:
+			if c.Access.IsSame(access) && !c.listening.IsZero() {
+				count++
+				active += now.Sub(c.listening)
+			}
+		}
+		return true
+	})
+
+	return count, active
+}
+
 func (clients *Clients) Add(client *Client) {
 	clients.Map.Store(client, true)
 }
@@ -233,7 +319,14 @@ func (clients *Clients) Count() int {
 	return count
 }
 
-func (clients *Clients) EmitCall(call *Call, restricted bool) {
+func (clients *Clients) EmitCall(call *Call, restricted bool, linked []TalkgroupRef) {
+	var (
+		audioUrlCall   *Call
+		streamOnlyCall *Call
+	)
+
+	watermarkedCalls := map[*Access]*Call{}
+
 	defer func() {
 		recover()
 	}()
@@ -241,8 +334,43 @@ func (clients *Clients) EmitCall(call *Call, restricted bool) {
 	clients.Map.Range(func(k interface{}, _ interface{}) bool {
 		switch c := k.(type) {
 		case *Client:
-			if (!restricted || c.Access.HasAccess(call)) && c.Livefeed.IsEnabled(call) {
-				c.Send <- &Message{Command: MessageCommandCall, Payload: call}
+			if (!restricted || c.Access.HasAccess(call)) && (c.Livefeed.IsEnabled(call) || (len(linked) > 1 && c.Livefeed.IsEnabledLinked(linked))) {
+				if c.IsDelivered(call, time.Duration(c.Controller.Options.BroadcastDedupWindow)*time.Second) {
+					return true
+				}
+
+				c.Controller.ListenerStats.Record(c, call)
+
+				if len(c.queue) > 0 && c.Controller.Options.ServerQueue {
+					if c.Controller.Queues.Enqueue(c.queue, call) {
+						c.Controller.Queues.EmitStatus(c.queue)
+					}
+				} else {
+					payload := call
+
+					if c.Controller.IsStreamOnly(c, call) {
+						if streamOnlyCall == nil {
+							streamOnlyCall = c.Controller.GetStreamOnlyCall(call)
+						}
+						payload = streamOnlyCall
+					}
+
+					if c.Access != nil && len(c.Access.Watermark) > 0 {
+						if watermarkedCalls[c.Access] == nil {
+							watermarkedCalls[c.Access] = c.Controller.GetWatermarkedCall(c, payload)
+						}
+						payload = watermarkedCalls[c.Access]
+					}
+
+					if payload == call && c.Controller.CanSendAudioUrl(c, call) {
+						if audioUrlCall == nil {
+							audioUrlCall = c.Controller.GetAudioUrlCall(c, call)
+						}
+						payload = audioUrlCall
+					}
+
+					c.Send <- &Message{Command: MessageCommandCall, Payload: payload}
+				}
 			}
 		}
 
@@ -288,6 +416,28 @@ func (clients *Clients) EmitListenersCount() {
 	})
 }
 
+func (clients *Clients) EmitTranscript(call *Call, restricted bool) {
+	defer func() {
+		recover()
+	}()
+
+	payload := map[string]interface{}{
+		"id":         call.Id,
+		"transcript": call.transcript,
+	}
+
+	clients.Map.Range(func(k interface{}, _ interface{}) bool {
+		switch c := k.(type) {
+		case *Client:
+			if !restricted || c.Access.HasAccess(call) {
+				c.Send <- &Message{Command: MessageCommandTranscript, Payload: payload}
+			}
+		}
+
+		return true
+	})
+}
+
 func (clients *Clients) Remove(client *Client) {
 	defer func() {
 		recover()
@@ -0,0 +1,180 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ClockSkewMinSamples = 10
+	ClockSkewSamples    = 20
+)
+
+type ClockSkewSource struct {
+	Correcting bool      `json:"correcting"`
+	LastCall   time.Time `json:"lastCall"`
+	Offset     int64     `json:"offset"`
+	Samples    int       `json:"samples"`
+	Skewed     bool      `json:"skewed"`
+	Source     string    `json:"source"`
+	deltas     []int64
+}
+
+type ClockSkews struct {
+	controller *Controller
+	mutex      sync.Mutex
+	sources    map[string]*ClockSkewSource
+}
+
+func (admin *Admin) ClockSkewHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if b, err := json.Marshal(map[string]interface{}{"sources": admin.Controller.ClockSkews.GetStatus()}); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.clockskewhandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func NewClockSkews(controller *Controller) *ClockSkews {
+	return &ClockSkews{
+		controller: controller,
+		mutex:      sync.Mutex{},
+		sources:    map[string]*ClockSkewSource{},
+	}
+}
+
+func (clockSkews *ClockSkews) Check(call *Call, received time.Time) {
+	var (
+		controller = clockSkews.controller
+		threshold  = int64(controller.Options.ClockSkewThreshold) * 1000
+	)
+
+	if threshold == 0 || call.historical || call.DateTime.IsZero() {
+		return
+	}
+
+	name := call.uploader
+	if len(name) == 0 {
+		name = "unknown"
+	}
+
+	clockSkews.mutex.Lock()
+
+	source, ok := clockSkews.sources[name]
+	if !ok {
+		source = &ClockSkewSource{Source: name}
+		clockSkews.sources[name] = source
+	}
+
+	source.LastCall = received
+	source.deltas = append(source.deltas, received.Sub(call.DateTime).Milliseconds()-int64(call.GetDuration()))
+	if len(source.deltas) > ClockSkewSamples {
+		source.deltas = source.deltas[len(source.deltas)-ClockSkewSamples:]
+	}
+	source.Samples = len(source.deltas)
+
+	if source.Samples < ClockSkewMinSamples {
+		clockSkews.mutex.Unlock()
+		return
+	}
+
+	sorted := append([]int64{}, source.deltas...)
+	sort.Slice(sorted, func(i int, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	source.Offset = sorted[len(sorted)/2]
+
+	skewed := source.Offset > threshold || source.Offset < -threshold
+	changed := skewed != source.Skewed
+
+	source.Skewed = skewed
+	source.Correcting = skewed && controller.Options.ClockSkewCorrection
+
+	if source.Correcting {
+		call.DateTime = call.DateTime.Add(time.Duration(source.Offset) * time.Millisecond)
+	}
+
+	offset := source.Offset
+
+	clockSkews.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+
+	if skewed {
+		message := fmt.Sprintf("clock of %s is off by %v, call timestamps are %s", name, (time.Duration(offset) * time.Millisecond).Round(time.Second), getClockSkewDirection(offset))
+
+		controller.Logs.LogEvent(LogLevelWarn, message)
+
+		if err := controller.Notifications.Notify(NotificationKindClockSkew, name, message); err != nil {
+			controller.Logs.LogEvent(LogLevelError, err.Error())
+		}
+
+	} else {
+		controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("clock of %s is back in sync", name))
+	}
+}
+
+func (clockSkews *ClockSkews) GetStatus() []ClockSkewSource {
+	clockSkews.mutex.Lock()
+	defer clockSkews.mutex.Unlock()
+
+	status := []ClockSkewSource{}
+	for _, source := range clockSkews.sources {
+		status = append(status, ClockSkewSource{
+			Correcting: source.Correcting,
+			LastCall:   source.LastCall,
+			Offset:     source.Offset,
+			Samples:    source.Samples,
+			Skewed:     source.Skewed,
+			Source:     source.Source,
+		})
+	}
+
+	sort.Slice(status, func(i int, j int) bool {
+		return status[i].Source < status[j].Source
+	})
+
+	return status
+}
+
+func getClockSkewDirection(offset int64) string {
+	if offset > 0 {
+		return "behind"
+	}
+	return "ahead"
+}
@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"runtime"
 	"strconv"
@@ -34,19 +35,28 @@ import (
 const (
 	COMMAND_ARG            = "cmd"
 	COMMAND_ARG_CODE       = "+code"
+	COMMAND_ARG_CSV        = "+csv"
 	COMMAND_ARG_EXPIRATION = "+expiration"
+	COMMAND_ARG_EXT        = "+ext"
 	COMMAND_ARG_IDENT      = "+ident"
 	COMMAND_ARG_IN         = "+in"
+	COMMAND_ARG_KEY        = "+key"
 	COMMAND_ARG_LIMIT      = "+limit"
+	COMMAND_ARG_MASK       = "+mask"
 	COMMAND_ARG_OUT        = "+out"
 	COMMAND_ARG_PASSWORD   = "+password"
+	COMMAND_ARG_STATE      = "+state"
+	COMMAND_ARG_SYSTEM     = "+system"
 	COMMAND_ARG_SYSTEMS    = "+systems"
+	COMMAND_ARG_TALKGROUP  = "+talkgroup"
 	COMMAND_ARG_TOKEN      = "+token"
+	COMMAND_ARG_TOTP       = "+totp"
 	COMMAND_ARG_URL        = "+url"
 	COMMAND_ADMIN_PASSWORD = "admin-password"
 	COMMAND_CONFIG_GET     = "config-get"
 	COMMAND_CONFIG_SET     = "config-set"
 	COMMAND_HELP           = "help"
+	COMMAND_IMPORT         = "import"
 	COMMAND_LOGIN          = "login"
 	COMMAND_LOGOUT         = "logout"
 	COMMAND_USER_ADD       = "user-add"
@@ -60,15 +70,23 @@ type Command struct {
 	app        string
 	code       string
 	command    string
+	csv        string
 	expiration string
+	ext        string
 	ident      string
 	in         string
+	key        string
 	limit      string
+	mask       string
 	out        string
 	password   string
+	state      string
+	system     string
 	systems    string
+	talkgroup  string
 	token      string
 	tokenFile  string
+	totp       string
 	url        string
 }
 
@@ -84,6 +102,7 @@ func NewCommand(baseDir string) *Command {
 		app:       path.Base(app),
 		command:   COMMAND_HELP,
 		password:  pass,
+		state:     filepath.Join(baseDir, path.Base(app)+".import"),
 		tokenFile: baseDir + path.Base(app) + ".token",
 		url:       COMMAND_DEF_URL,
 	}
@@ -107,18 +126,30 @@ func (command *Command) Do(action string) {
 		case COMMAND_ARG_CODE:
 			command.code = readVal()
 
+		case COMMAND_ARG_CSV:
+			command.csv = readVal()
+
 		case COMMAND_ARG_EXPIRATION:
 			command.expiration = readVal()
 
+		case COMMAND_ARG_EXT:
+			command.ext = readVal()
+
 		case COMMAND_ARG_IDENT:
 			command.ident = readVal()
 
 		case COMMAND_ARG_IN:
 			command.in = readVal()
 
+		case COMMAND_ARG_KEY:
+			command.key = readVal()
+
 		case COMMAND_ARG_LIMIT:
 			command.limit = readVal()
 
+		case COMMAND_ARG_MASK:
+			command.mask = readVal()
+
 		case COMMAND_ARG_OUT:
 			command.out = readVal()
 			if !strings.HasSuffix(strings.ToLower(command.out), ".json") {
@@ -128,12 +159,24 @@ func (command *Command) Do(action string) {
 		case COMMAND_ARG_PASSWORD:
 			command.password = readVal()
 
+		case COMMAND_ARG_STATE:
+			command.state = readVal()
+
+		case COMMAND_ARG_SYSTEM:
+			command.system = readVal()
+
 		case COMMAND_ARG_SYSTEMS:
 			command.systems = readVal()
 
+		case COMMAND_ARG_TALKGROUP:
+			command.talkgroup = readVal()
+
 		case COMMAND_ARG_TOKEN:
 			command.tokenFile = readVal()
 
+		case COMMAND_ARG_TOTP:
+			command.totp = readVal()
+
 		case COMMAND_ARG_URL:
 			command.url = readVal()
 
@@ -160,6 +203,9 @@ func (command *Command) Do(action string) {
 	case COMMAND_CONFIG_SET:
 		command.configSet()
 
+	case COMMAND_IMPORT:
+		command.importCalls()
+
 	case COMMAND_LOGIN:
 		command.login()
 
@@ -199,11 +245,22 @@ func (command *Command) printUsage() {
 	fmt.Printf("    %-11s %s%s -%s %s %s <file.json>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_CONFIG_GET, COMMAND_ARG_OUT)
 	fmt.Printf("  %-11s – Set server's configuration.\n\n", COMMAND_CONFIG_SET)
 	fmt.Printf("    %-11s %s%s -%s %s %s <file.json>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_CONFIG_SET, COMMAND_ARG_IN)
+	fmt.Printf("  %-11s – Import an existing archive of recordings with their original timestamps.\n\n", COMMAND_IMPORT)
+	fmt.Printf("    %-11s %s%s -%s %s %s <apikey> %s <directory> %s <mask>\n", "", prompt, command.app, COMMAND_ARG, COMMAND_IMPORT, COMMAND_ARG_KEY, COMMAND_ARG_IN, COMMAND_ARG_MASK)
+	fmt.Printf("    %-11s %s%s -%s %s %s <apikey> %s <file.csv>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_IMPORT, COMMAND_ARG_KEY, COMMAND_ARG_CSV)
+	fmt.Printf("    %-11s Optional:\n\n", "")
+	fmt.Printf("      %-11s %-11s <ext1[,ext2,...]>     – Audio file extensions. Default is `%s`.\n", "", COMMAND_ARG_EXT, ImportDefaultExtensions)
+	fmt.Printf("      %-11s %-11s <file.json>           – Save a summary report.\n", "", COMMAND_ARG_OUT)
+	fmt.Printf("      %-11s %-11s <file>                – Resume state file. Default is `%s.import`.\n", "", COMMAND_ARG_STATE, command.app)
+	fmt.Printf("      %-11s %-11s <id>                  – System id when not found in the mask or CSV.\n", "", COMMAND_ARG_SYSTEM)
+	fmt.Printf("      %-11s %-11s <id>                  – Talkgroup id when not found in the mask or CSV.\n\n", "", COMMAND_ARG_TALKGROUP)
 	fmt.Printf("  %-11s – Login to server.\n\n", COMMAND_LOGIN)
 	if runtime.GOOS != "windows" {
 		fmt.Printf("    %-11s $ RDIO_ADMIN_PASSWORD=<password> ./%s -%s %s\n", "", command.app, COMMAND_ARG, COMMAND_LOGIN)
 	}
 	fmt.Printf("    %-11s %s%s -%s %s %s <password>\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_LOGIN, COMMAND_ARG_PASSWORD)
+	fmt.Printf("    %-11s Optional:\n\n", "")
+	fmt.Printf("      %-11s %-11s <code>                – Two-factor authentication code.\n\n", "", COMMAND_ARG_TOTP)
 	fmt.Printf("  %-11s – Logout from server.\n\n", COMMAND_LOGOUT)
 	fmt.Printf("    %-11s %s%s -%s %s\n\n", "", prompt, command.app, COMMAND_ARG, COMMAND_LOGOUT)
 	fmt.Printf("  %-11s – Add a user access.\n\n", COMMAND_USER_ADD)
@@ -300,7 +357,7 @@ func (command *Command) configSet() {
 }
 
 func (command *Command) login() {
-	if body, err := command.writeBody(map[string]interface{}{"password": command.password}); err == nil {
+	if body, err := command.writeBody(map[string]interface{}{"code": command.totp, "password": command.password}); err == nil {
 		if res, err := command.submit(http.MethodPost, "/api/admin/login", body, false); err == nil {
 			if res.StatusCode == http.StatusOK {
 				if data, err := command.readBody(res.Body); err == nil {
@@ -19,13 +19,18 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
 	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -35,7 +40,9 @@ const (
 )
 
 type Config struct {
+	AdminPassword string
 	BaseDir       string
+	BasePath      string
 	ConfigFile    string
 	DbType        string
 	DbFile        string
@@ -44,7 +51,15 @@ type Config struct {
 	DbName        string
 	DbUsername    string
 	DbPassword    string
+	FfmpegHwAccel string
+	FfmpegHwDev   string
+	FfmpegMinVer  string
+	FfmpegPath    string
+	FfmpegTimeout uint
 	Listen        string
+	LowMemory     bool
+	ReadOnly      bool
+	Repair        bool
 	SslAutoCert   string
 	SslCaCertFile string
 	SslCaKeyFile  string
@@ -52,6 +67,31 @@ type Config struct {
 	SslKeyFile    string
 	SslListen     string
 	daemon        *Daemon
+	explicit      []string
+	modTime       time.Time
+}
+
+var ConfigKeys = []string{
+	"base_path",
+	"db_file",
+	"db_host",
+	"db_name",
+	"db_pass",
+	"db_port",
+	"db_type",
+	"db_user",
+	"ffmpeg_hwaccel",
+	"ffmpeg_hwaccel_device",
+	"ffmpeg_min_version",
+	"ffmpeg_path",
+	"ffmpeg_timeout",
+	"listen",
+	"low_memory",
+	"read_only",
+	"ssl_auto_cert",
+	"ssl_cert_file",
+	"ssl_key_file",
+	"ssl_listen",
 }
 
 func NewConfig() *Config {
@@ -62,6 +102,9 @@ func NewConfig() *Config {
 		defaultDbFile     = "rdio-scanner.db"
 		defaultDbHost     = "localhost"
 		defaultDbPort     = uint(3306)
+		defaultFfmpegMin  = "4.0"
+		defaultFfmpegPath = "ffmpeg"
+		defaultFfmpegTime = uint(60)
 		defaultListen     = ":3000"
 	)
 
@@ -69,6 +112,7 @@ func NewConfig() *Config {
 		command       = flag.String(COMMAND_ARG, "", fmt.Sprintf("advanced administrative tasks (use -%s %s for usage)", COMMAND_ARG, COMMAND_HELP))
 		config        = &Config{}
 		configSave    = flag.Bool("config_save", false, fmt.Sprintf("save configuration to %s", defaultConfigFile))
+		healthcheck   = flag.Bool("healthcheck", false, "query the health endpoint of the running server and exit")
 		serviceAction = flag.String("service", "", "service command, one of start, stop, restart, install, uninstall")
 		version       = flag.Bool("version", false, "show application version")
 	)
@@ -88,6 +132,7 @@ func NewConfig() *Config {
 	}
 
 	flag.StringVar(&config.BaseDir, "base_dir", config.BaseDir, "base directory where all data will be written")
+	flag.StringVar(&config.BasePath, "base_path", "", "url path prefix when served under a sub path, ie: /scanner")
 	flag.StringVar(&config.DbFile, "db_file", defaultDbFile, "sqlite database file")
 	flag.StringVar(&config.DbHost, "db_host", defaultDbHost, "database host ip or hostname")
 	flag.StringVar(&config.DbName, "db_name", "", "database name")
@@ -96,19 +141,41 @@ func NewConfig() *Config {
 	flag.StringVar(&config.DbType, "db_type", defaultDbType, fmt.Sprintf("database type, one of %s, %s, %s", DbTypeSqlite, DbTypeMariadb, DbTypeMysql))
 	flag.StringVar(&config.DbUsername, "db_user", "", "database user name")
 	flag.StringVar(&config.ConfigFile, "config", defaultConfigFile, "server config file")
+	flag.StringVar(&config.FfmpegHwAccel, "ffmpeg_hwaccel", "", "ffmpeg hardware acceleration, one of vaapi, nvenc, v4l2")
+	flag.StringVar(&config.FfmpegHwDev, "ffmpeg_hwaccel_device", "", "ffmpeg hardware acceleration device, ie: /dev/dri/renderD128")
+	flag.StringVar(&config.FfmpegMinVer, "ffmpeg_min_version", defaultFfmpegMin, "minimum ffmpeg version required for audio conversion")
+	flag.StringVar(&config.FfmpegPath, "ffmpeg_path", defaultFfmpegPath, "ffmpeg binary path")
+	flag.UintVar(&config.FfmpegTimeout, "ffmpeg_timeout", defaultFfmpegTime, "ffmpeg conversion timeout in seconds")
 	flag.StringVar(&config.Listen, "listen", defaultListen, "listening address")
+	flag.BoolVar(&config.LowMemory, "low_memory", false, "reduce memory usage for small devices such as the Raspberry Pi Zero")
+	flag.BoolVar(&config.ReadOnly, "read_only", false, "serve search, playback and listening only, without ingest, configuration changes or pruning")
+	flag.BoolVar(&config.Repair, "repair", false, "add the database tables and columns missing after a partial upgrade, then exit")
 	flag.StringVar(&config.SslAutoCert, "ssl_auto_cert", "", "domain name for Let's Encrypt automatic certificate")
 	flag.StringVar(&config.SslCertFile, "ssl_cert_file", "", "ssl PEM formated certificate")
 	flag.StringVar(&config.SslKeyFile, "ssl_key_file", "", "ssl PEM formated key")
 	flag.StringVar(&config.SslListen, "ssl_listen", "", "listening address for ssl")
 	flag.Parse()
 
+	flag.Visit(func(f *flag.Flag) {
+		config.explicit = append(config.explicit, f.Name)
+	})
+
+	if v := os.Getenv(config.getEnvName("base_dir")); len(v) > 0 && !config.isExplicit("base_dir") {
+		config.BaseDir = v
+	}
+
+	if v := os.Getenv(config.getEnvName("config")); len(v) > 0 && !config.isExplicit("config") {
+		config.ConfigFile = v
+	}
+
 	if !config.isBaseDirWritable() {
 		log.Fatalf("no write permissions in %s", config.BaseDir)
 	}
 
 	switch {
 	case *configSave:
+		config.loadEnv()
+
 		if err := config.saveConfig(); err == nil {
 			fmt.Printf("%s file created\n", config.ConfigFile)
 			os.Exit(0)
@@ -117,61 +184,35 @@ func NewConfig() *Config {
 			os.Exit(-1)
 		}
 
+	case *healthcheck:
+		if _, err := os.Stat(config.GetConfigFilePath()); err == nil {
+			config.loadConfigFile()
+		}
+
+		config.loadEnv()
+
+		if err := Healthcheck(config); err == nil {
+			os.Exit(0)
+		} else {
+			fmt.Printf("error: %s\n", err.Error())
+			os.Exit(1)
+		}
+
 	case *version:
 		fmt.Println(Version)
 		os.Exit(0)
 
 	default:
-		if cfg, err := ini.Load(config.GetConfigFilePath()); err == nil {
-			if v := cfg.Section("").Key("db_file").String(); len(v) > 0 {
-				config.DbFile = v
-			}
-
-			if v := cfg.Section("").Key("db_host").String(); len(v) > 0 {
-				config.DbHost = v
-			}
-
-			if v := cfg.Section("").Key("db_name").String(); len(v) > 0 {
-				config.DbName = v
-			}
-
-			if v := cfg.Section("").Key("db_pass").String(); len(v) > 0 {
-				config.DbPassword = v
-			}
-
-			if config.DbPort, err = cfg.Section("").Key("db_port").Uint(); err != nil {
-				config.DbPort = defaultDbPort
-			}
-
-			if v := cfg.Section("").Key("db_type").String(); len(v) > 0 {
-				config.DbType = v
-			}
+		if fi, err := os.Stat(config.GetConfigFilePath()); err == nil {
+			config.modTime = fi.ModTime()
 
-			if v := cfg.Section("").Key("db_user").String(); len(v) > 0 {
-				config.DbUsername = v
-			}
-
-			if v := cfg.Section("").Key("listen").String(); len(v) > 0 {
-				config.Listen = v
-			}
-
-			if v := cfg.Section("").Key("ssl_auto_cert").String(); len(v) > 0 {
-				config.SslAutoCert = v
-			}
-
-			if v := cfg.Section("").Key("ssl_cert_file").String(); len(v) > 0 {
-				config.SslCertFile = v
-			}
-
-			if v := cfg.Section("").Key("ssl_key_file").String(); len(v) > 0 {
-				config.SslKeyFile = v
-			}
-
-			if v := cfg.Section("").Key("ssl_listen").String(); len(v) > 0 {
-				config.SslListen = v
+			if err = config.loadConfigFile(); err != nil {
+				log.Printf("unable to load %s, %s", config.ConfigFile, err.Error())
 			}
 		}
 
+		config.loadEnv()
+
 		if !(config.DbType == DbTypeMariadb || config.DbType == DbTypeMysql || config.DbType == DbTypeSqlite) {
 			fmt.Printf("unknown database type %s\n", config.DbType)
 			return nil
@@ -189,6 +230,15 @@ func NewConfig() *Config {
 	return config
 }
 
+func (config *Config) GetBasePath() string {
+	s := strings.Trim(config.BasePath, "/ ")
+	if len(s) == 0 {
+		return ""
+	}
+
+	return path.Clean("/" + s)
+}
+
 func (config *Config) GetConfigFilePath() string {
 	return config.GetPath(config.ConfigFile)
 }
@@ -220,6 +270,144 @@ func (config *Config) GetSslKeyFilePath() string {
 	return config.GetPath(config.SslKeyFile)
 }
 
+func (config *Config) HasConfigFileChanged() bool {
+	if fi, err := os.Stat(config.GetConfigFilePath()); err == nil {
+		return !fi.ModTime().Equal(config.modTime)
+	}
+	return !config.modTime.IsZero()
+}
+
+func (config *Config) Get(key string) string {
+	switch key {
+	case "admin_password":
+		return config.AdminPassword
+	case "base_path":
+		return config.GetBasePath()
+	case "db_file":
+		return config.DbFile
+	case "db_host":
+		return config.DbHost
+	case "db_name":
+		return config.DbName
+	case "db_pass":
+		return config.DbPassword
+	case "db_port":
+		if config.DbPort > 0 {
+			return strconv.Itoa(int(config.DbPort))
+		}
+	case "db_type":
+		return config.DbType
+	case "db_user":
+		return config.DbUsername
+	case "ffmpeg_hwaccel":
+		return config.FfmpegHwAccel
+	case "ffmpeg_hwaccel_device":
+		return config.FfmpegHwDev
+	case "ffmpeg_min_version":
+		return config.FfmpegMinVer
+	case "ffmpeg_path":
+		return config.FfmpegPath
+	case "ffmpeg_timeout":
+		if config.FfmpegTimeout > 0 {
+			return strconv.Itoa(int(config.FfmpegTimeout))
+		}
+	case "listen":
+		return config.Listen
+	case "low_memory":
+		if config.LowMemory {
+			return "true"
+		}
+	case "read_only":
+		if config.ReadOnly {
+			return "true"
+		}
+	case "ssl_auto_cert":
+		return config.SslAutoCert
+	case "ssl_cert_file":
+		return config.SslCertFile
+	case "ssl_key_file":
+		return config.SslKeyFile
+	case "ssl_listen":
+		return config.SslListen
+	}
+	return ""
+}
+
+func (config *Config) Set(key string, value string) error {
+	switch key {
+	case "admin_password":
+		config.AdminPassword = value
+	case "base_path":
+		config.BasePath = value
+	case "db_file":
+		config.DbFile = value
+	case "db_host":
+		config.DbHost = value
+	case "db_name":
+		config.DbName = value
+	case "db_pass":
+		config.DbPassword = value
+	case "db_port":
+		if i, err := strconv.Atoi(value); err == nil && i > 0 {
+			config.DbPort = uint(i)
+		} else {
+			return fmt.Errorf("invalid value %s for %s", value, key)
+		}
+	case "db_type":
+		config.DbType = value
+	case "db_user":
+		config.DbUsername = value
+	case "ffmpeg_hwaccel":
+		switch value {
+		case "", "nvenc", "v4l2", "vaapi":
+			config.FfmpegHwAccel = value
+		default:
+			return fmt.Errorf("invalid value %s for %s", value, key)
+		}
+	case "ffmpeg_hwaccel_device":
+		config.FfmpegHwDev = value
+	case "ffmpeg_min_version":
+		config.FfmpegMinVer = value
+	case "ffmpeg_path":
+		config.FfmpegPath = value
+	case "ffmpeg_timeout":
+		if i, err := strconv.Atoi(value); err == nil && i > 0 {
+			config.FfmpegTimeout = uint(i)
+		} else {
+			return fmt.Errorf("invalid value %s for %s", value, key)
+		}
+	case "listen":
+		config.Listen = value
+	case "low_memory":
+		if b, err := strconv.ParseBool(value); err == nil {
+			config.LowMemory = b
+		} else {
+			return fmt.Errorf("invalid value %s for %s", value, key)
+		}
+	case "read_only":
+		if b, err := strconv.ParseBool(value); err == nil {
+			config.ReadOnly = b
+		} else {
+			return fmt.Errorf("invalid value %s for %s", value, key)
+		}
+	case "ssl_auto_cert":
+		config.SslAutoCert = value
+	case "ssl_cert_file":
+		config.SslCertFile = value
+	case "ssl_key_file":
+		config.SslKeyFile = value
+	case "ssl_listen":
+		config.SslListen = value
+	default:
+		return fmt.Errorf("unknown configuration key %s", key)
+	}
+	return nil
+}
+
+func (config *Config) getEnvName(key string) string {
+	return fmt.Sprintf("RDIO_%s", strings.ToUpper(key))
+}
+
 func (config *Config) isBaseDirWritable() bool {
 	if f, err := os.CreateTemp(config.BaseDir, ".tmp*"); err == nil {
 		f.Close()
@@ -229,58 +417,199 @@ func (config *Config) isBaseDirWritable() bool {
 	return false
 }
 
+func (config *Config) isExplicit(key string) bool {
+	for _, k := range config.explicit {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (config *Config) isToml() bool {
+	return strings.ToLower(filepath.Ext(config.ConfigFile)) == ".toml"
+}
+
+func (config *Config) isYaml() bool {
+	switch strings.ToLower(filepath.Ext(config.ConfigFile)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+func (config *Config) loadConfigFile() error {
+	values := map[string]string{}
+
+	if config.isToml() || config.isYaml() {
+		var m map[string]interface{}
+
+		b, err := os.ReadFile(config.GetConfigFilePath())
+		if err != nil {
+			return err
+		}
+
+		if config.isToml() {
+			err = toml.Unmarshal(b, &m)
+		} else {
+			err = yaml.Unmarshal(b, &m)
+		}
+		if err != nil {
+			return err
+		}
+
+		for k, v := range m {
+			if v != nil {
+				values[k] = fmt.Sprintf("%v", v)
+			}
+		}
+
+	} else {
+		cfg, err := ini.Load(config.GetConfigFilePath())
+		if err != nil {
+			return err
+		}
+
+		for _, k := range cfg.Section("").Keys() {
+			values[k.Name()] = k.String()
+		}
+	}
+
+	for _, key := range ConfigKeys {
+		if v := values[key]; len(v) > 0 && !config.isExplicit(key) {
+			if err := config.Set(key, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (config *Config) loadEnv() {
+	for _, key := range append([]string{"admin_password"}, ConfigKeys...) {
+		var (
+			name  = config.getEnvName(key)
+			value string
+		)
+
+		if config.isExplicit(key) {
+			continue
+		}
+
+		if v := os.Getenv(name); len(v) > 0 {
+			value = v
+
+		} else if f := os.Getenv(name + "_FILE"); len(f) > 0 {
+			if b, err := os.ReadFile(f); err == nil {
+				value = strings.TrimSpace(string(b))
+			} else {
+				log.Printf("%s_FILE: %s", name, err.Error())
+			}
+		}
+
+		if len(value) > 0 {
+			if err := config.Set(key, value); err != nil {
+				log.Printf("%s: %s", name, err.Error())
+			}
+		}
+	}
+
+	if v := os.Getenv(config.getEnvName("port")); len(v) > 0 && !config.isExplicit("listen") {
+		host, _, err := net.SplitHostPort(config.Listen)
+		if err != nil {
+			host = config.Listen
+		}
+		config.Listen = net.JoinHostPort(host, v)
+	}
+}
+
 func (config *Config) saveConfig() error {
+	var format string
+
+	if config.isToml() {
+		format = "%s = %q"
+	} else if config.isYaml() {
+		format = "%s: %q"
+	} else {
+		format = "%s = %s"
+	}
+
 	ini := []string{}
 
+	if config.GetBasePath() != "" {
+		ini = append(ini, fmt.Sprintf(format, "base_path", config.GetBasePath()))
+	}
+
 	if config.DbType == DbTypeSqlite {
 		if config.DbFile != "" {
-			ini = append(ini, fmt.Sprintf("db_file = %s", config.DbFile))
+			ini = append(ini, fmt.Sprintf(format, "db_file", config.DbFile))
 		}
 
 	} else {
 		if config.DbHost != "" {
-			ini = append(ini, fmt.Sprintf("db_host = %s", config.DbHost))
+			ini = append(ini, fmt.Sprintf(format, "db_host", config.DbHost))
 		}
 
 		if config.DbName != "" {
-			ini = append(ini, fmt.Sprintf("db_name = %s", config.DbName))
+			ini = append(ini, fmt.Sprintf(format, "db_name", config.DbName))
 		}
 
 		if config.DbPassword != "" {
-			ini = append(ini, fmt.Sprintf("db_pass = %s", config.DbPassword))
+			ini = append(ini, fmt.Sprintf(format, "db_pass", config.DbPassword))
 		}
 
 		if config.DbPort > 0 {
-			ini = append(ini, fmt.Sprintf("db_port = %s", strconv.Itoa(int(config.DbPort))))
+			ini = append(ini, fmt.Sprintf(format, "db_port", strconv.Itoa(int(config.DbPort))))
 		}
 	}
 
 	if config.DbType != "" {
-		ini = append(ini, fmt.Sprintf("db_type = %s", config.DbType))
+		ini = append(ini, fmt.Sprintf(format, "db_type", config.DbType))
 	}
 
 	if config.DbUsername != "" && config.DbType != DbTypeSqlite {
-		ini = append(ini, fmt.Sprintf("db_user = %s", config.DbUsername))
+		ini = append(ini, fmt.Sprintf(format, "db_user", config.DbUsername))
+	}
+
+	if config.FfmpegHwAccel != "" {
+		ini = append(ini, fmt.Sprintf(format, "ffmpeg_hwaccel", config.FfmpegHwAccel))
+	}
+
+	if config.FfmpegHwDev != "" {
+		ini = append(ini, fmt.Sprintf(format, "ffmpeg_hwaccel_device", config.FfmpegHwDev))
+	}
+
+	if config.FfmpegPath != "" && config.FfmpegPath != "ffmpeg" {
+		ini = append(ini, fmt.Sprintf(format, "ffmpeg_path", config.FfmpegPath))
 	}
 
 	if config.Listen != "" {
-		ini = append(ini, fmt.Sprintf("listen = %s", config.Listen))
+		ini = append(ini, fmt.Sprintf(format, "listen", config.Listen))
+	}
+
+	if config.LowMemory {
+		ini = append(ini, fmt.Sprintf(format, "low_memory", "true"))
+	}
+
+	if config.ReadOnly {
+		ini = append(ini, fmt.Sprintf(format, "read_only", "true"))
 	}
 
 	if config.SslAutoCert != "" {
-		ini = append(ini, fmt.Sprintf("ssl_auto_cert = %s", config.SslAutoCert))
+		ini = append(ini, fmt.Sprintf(format, "ssl_auto_cert", config.SslAutoCert))
 	}
 
 	if config.SslCertFile != "" {
-		ini = append(ini, fmt.Sprintf("ssl_cert_file = %s", config.SslCertFile))
+		ini = append(ini, fmt.Sprintf(format, "ssl_cert_file", config.SslCertFile))
 	}
 
 	if config.SslKeyFile != "" {
-		ini = append(ini, fmt.Sprintf("ssl_key_file = %s", config.SslKeyFile))
+		ini = append(ini, fmt.Sprintf(format, "ssl_key_file", config.SslKeyFile))
 	}
 
 	if config.SslListen != "" {
-		ini = append(ini, fmt.Sprintf("ssl_listen = %s", config.SslListen))
+		ini = append(ini, fmt.Sprintf(format, "ssl_listen", config.SslListen))
 	}
 
 	file, err := os.Create(config.GetConfigFilePath())
@@ -0,0 +1,503 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const ConfigBundleMaxSize = 64 << 20
+
+type ConfigBundleReport struct {
+	Count   int      `json:"count"`
+	Errors  []string `json:"errors"`
+	Section string   `json:"section"`
+}
+
+func (admin *Admin) ConfigExportHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if _, ok := admin.ValidateTokenRole(t, AdminRoleEditor); !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	sections, err := admin.getBundleSections(r.URL.Query().Get("sections"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+		return
+	}
+
+	bundle, err := admin.GetConfigBundle(sections)
+	if err != nil {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.configexporthandler: %s", err.Error()))
+		w.WriteHeader(http.StatusExpectationFailed)
+		return
+	}
+
+	filename := fmt.Sprintf("rdio-scanner-config-%s", time.Now().UTC().Format("20060102150405"))
+
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case "yaml", "yml":
+		b, err := yaml.Marshal(bundle)
+		if err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.yaml"`, filename))
+		w.Write(b)
+
+	default:
+		b, err := json.MarshalIndent(bundle, "", "  ")
+		if err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		w.Write(b)
+	}
+}
+
+func (admin *Admin) ConfigImportHandler(w http.ResponseWriter, r *http.Request) {
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.configimporthandler: %s", err.Error()))
+	}
+
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	dryRun := false
+	switch strings.ToLower(r.URL.Query().Get("dryRun")) {
+	case "1", "true", "yes":
+		dryRun = true
+	}
+
+	user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+	if !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if !dryRun && !admin.CheckWritable(w) {
+		return
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r.Body, ConfigBundleMaxSize))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	bundle, err := ParseConfigBundle(b)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+		return
+	}
+
+	selected, err := admin.getBundleSections(r.URL.Query().Get("sections"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+		return
+	}
+
+	config := map[string]interface{}{}
+	sections := []string{}
+	for _, section := range selected {
+		if v, ok := bundle[section]; ok {
+			config[section] = v
+			sections = append(sections, section)
+		}
+	}
+
+	if len(sections) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("no configuration section to import\n"))
+		return
+	}
+
+	reports, valid := admin.ValidateConfigBundle(config, sections)
+
+	res := map[string]interface{}{
+		"dryRun":   dryRun,
+		"sections": reports,
+		"valid":    valid,
+	}
+
+	if dryRun {
+		res["config"] = config
+	}
+
+	if !dryRun && valid {
+		admin.Controller.IngestLock()
+		admin.mutex.Lock()
+
+		admin.Controller.Dirwatches.Stop()
+		admin.Controller.Sdrs.Stop()
+
+		before := admin.getAuditSnapshot(sections)
+
+		for _, section := range sections {
+			if err = admin.writeConfigSection(section, config[section]); err != nil {
+				logError(err)
+				for _, report := range reports {
+					if report.Section == section {
+						report.Errors = append(report.Errors, err.Error())
+					}
+				}
+				valid = false
+			}
+		}
+
+		after := admin.getAuditSnapshot(sections)
+
+		revision, err := admin.IncrementRevision()
+		if err != nil {
+			logError(err)
+		}
+
+		admin.mutex.Unlock()
+		admin.Controller.IngestUnlock()
+
+		admin.BroadcastNotice(map[string]interface{}{
+			"message":  "configuration changed by someone else",
+			"revision": revision,
+			"sections": sections,
+		})
+
+		admin.Controller.EmitConfig()
+		admin.Controller.Dirwatches.Start(admin.Controller)
+		admin.Controller.Sdrs.Start(admin.Controller)
+
+		res["revision"] = revision
+		res["valid"] = valid
+
+		admin.Controller.AuditLog.Record(r, user, AuditLogActionImport, GetAuditLogChanges(before, after))
+
+		admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("configuration imported, %s by user=\"%s\"", strings.Join(sections, ", "), user.Username))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !valid {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		logError(err)
+	}
+}
+
+func (admin *Admin) GetConfigBundle(sections []string) (map[string]interface{}, error) {
+	var config map[string]interface{}
+
+	b, err := json.Marshal(admin.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(b, &config); err != nil {
+		return nil, err
+	}
+
+	bundle := map[string]interface{}{
+		"exported": time.Now().UTC().Format(time.RFC3339),
+		"version":  Version,
+	}
+
+	for _, section := range sections {
+		bundle[section] = config[section]
+	}
+
+	return bundle, nil
+}
+
+func (admin *Admin) ValidateConfigBundle(config map[string]interface{}, sections []string) ([]*ConfigBundleReport, bool) {
+	valid := true
+
+	groupIds := map[string]bool{}
+	if v, ok := config["groups"].([]interface{}); ok {
+		for _, group := range NewGroups().FromMap(v).List {
+			groupIds[fmt.Sprint(group.Id)] = true
+		}
+	} else {
+		for _, group := range admin.Controller.Groups.List {
+			groupIds[fmt.Sprint(group.Id)] = true
+		}
+	}
+
+	tagIds := map[string]bool{}
+	if v, ok := config["tags"].([]interface{}); ok {
+		for _, tag := range NewTags().FromMap(v).List {
+			tagIds[fmt.Sprint(tag.Id)] = true
+		}
+	} else {
+		for _, tag := range admin.Controller.Tags.List {
+			tagIds[fmt.Sprint(tag.Id)] = true
+		}
+	}
+
+	systemIds := map[uint]bool{}
+	if v, ok := config["systems"].([]interface{}); ok {
+		for _, system := range NewSystems().FromMap(v).List {
+			systemIds[system.Id] = true
+		}
+	} else {
+		for _, system := range admin.Controller.Systems.List {
+			systemIds[system.Id] = true
+		}
+	}
+
+	reports := []*ConfigBundleReport{}
+
+	for _, section := range sections {
+		report := &ConfigBundleReport{Errors: []string{}, Section: section}
+
+		addError := func(format string, a ...interface{}) {
+			report.Errors = append(report.Errors, fmt.Sprintf(format, a...))
+		}
+
+		v := config[section]
+
+		if section == "options" {
+			if m, ok := v.(map[string]interface{}); ok {
+				report.Count = len(m)
+			} else {
+				addError("options must be an object")
+			}
+
+		} else if f, ok := v.([]interface{}); !ok {
+			addError("%s must be an array", section)
+
+		} else {
+			report.Count = len(f)
+
+			switch section {
+			case "access":
+				codes := map[string]bool{}
+				for i, access := range NewAccesses().FromMap(f).List {
+					if len(access.Code) == 0 {
+						addError("access %d has no code", i+1)
+					} else if codes[access.Code] {
+						addError("access %d has a duplicate code", i+1)
+					}
+					codes[access.Code] = true
+				}
+
+			case "alerts":
+				if err := NewAlerts(admin.Controller).FromMap(f).Validate(); err != nil {
+					addError(err.Error())
+				}
+
+			case "apiKeys":
+				keys := map[string]bool{}
+				for i, apikey := range NewApikeys().FromMap(f).List {
+					if len(apikey.Key) == 0 {
+						addError("api key %d has no key", i+1)
+					} else if keys[apikey.Key] {
+						addError("api key %d is a duplicate", i+1)
+					}
+					keys[apikey.Key] = true
+				}
+
+			case "dirWatch":
+				for i, dirwatch := range NewDirwatches().FromMap(f).List {
+					if len(dirwatch.Directory) == 0 {
+						addError("dirwatch %d has no directory", i+1)
+					}
+					switch id := dirwatch.SystemId.(type) {
+					case uint:
+						if !systemIds[id] {
+							addError("dirwatch %d refers to unknown system %d", i+1, id)
+						}
+					}
+				}
+
+			case "downstreams":
+				for i, downstream := range NewDownstreams().FromMap(f).List {
+					if len(downstream.Url) == 0 {
+						addError("downstream %d has no url", i+1)
+					}
+				}
+
+			case "groups", "tags":
+				labels := map[string]bool{}
+				for i, entry := range f {
+					m, _ := entry.(map[string]interface{})
+					label, _ := m["label"].(string)
+					if len(label) == 0 {
+						addError("%s %d has no label", strings.TrimSuffix(section, "s"), i+1)
+					} else if labels[label] {
+						addError("%s %d has a duplicate label %s", strings.TrimSuffix(section, "s"), i+1, label)
+					}
+					labels[label] = true
+				}
+
+			case "retentions":
+				if err := NewRetentions().FromMap(f).Validate(); err != nil {
+					addError(err.Error())
+				}
+
+			case "sdr":
+				for i, sdr := range NewSdrs().FromMap(f).List {
+					if len(sdr.Command) == 0 {
+						addError("sdr %d has no command", i+1)
+					}
+					if !systemIds[sdr.SystemId] {
+						addError("sdr %d refers to unknown system %d", i+1, sdr.SystemId)
+					}
+				}
+
+			case "systems":
+				ids := map[uint]bool{}
+				links := map[TalkgroupRef]TalkgroupRef{}
+				refs := map[TalkgroupRef]bool{}
+				for i, system := range NewSystems().FromMap(f).List {
+					if system.Id == 0 {
+						addError("system %d has no id", i+1)
+					} else if ids[system.Id] {
+						addError("system %d has a duplicate id %d", i+1, system.Id)
+					}
+					ids[system.Id] = true
+
+					if len(system.Label) == 0 {
+						addError("system %d has no label", system.Id)
+					}
+
+					tgIds := map[uint]bool{}
+					for _, talkgroup := range system.Talkgroups.List {
+						if talkgroup.Id == 0 {
+							addError("system %d has a talkgroup without id", system.Id)
+						} else if tgIds[talkgroup.Id] {
+							addError("system %d has a duplicate talkgroup id %d", system.Id, talkgroup.Id)
+						}
+						tgIds[talkgroup.Id] = true
+
+						ref := TalkgroupRef{System: system.Id, Talkgroup: talkgroup.Id}
+						refs[ref] = true
+
+						if talkgroup.LinkedSystem > 0 || talkgroup.LinkedTalkgroup > 0 {
+							links[ref] = TalkgroupRef{System: talkgroup.LinkedSystem, Talkgroup: talkgroup.LinkedTalkgroup}
+						}
+
+						if !groupIds[fmt.Sprint(talkgroup.GroupId)] {
+							addError("system %d talkgroup %d refers to unknown group %d", system.Id, talkgroup.Id, talkgroup.GroupId)
+						}
+
+						if !tagIds[fmt.Sprint(talkgroup.TagId)] {
+							addError("system %d talkgroup %d refers to unknown tag %d", system.Id, talkgroup.Id, talkgroup.TagId)
+						}
+					}
+				}
+
+				for ref, linked := range links {
+					if !refs[linked] || linked == ref {
+						addError("system %d talkgroup %d is linked to unknown talkgroup %d on system %d", ref.System, ref.Talkgroup, linked.Talkgroup, linked.System)
+					}
+				}
+
+			case "webhooks":
+				if err := NewWebhooks().FromMap(f).Validate(); err != nil {
+					addError(err.Error())
+				}
+			}
+		}
+
+		if len(report.Errors) > 0 {
+			valid = false
+		}
+
+		reports = append(reports, report)
+	}
+
+	return reports, valid
+}
+
+func (admin *Admin) getBundleSections(s string) ([]string, error) {
+	if len(strings.TrimSpace(s)) == 0 {
+		return AdminConfigSections, nil
+	}
+
+	sections := []string{}
+	for _, name := range strings.Split(s, ",") {
+		section, ok := admin.GetConfigSection(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown section %s", strings.TrimSpace(name))
+		}
+		sections = append(sections, section)
+	}
+
+	return sections, nil
+}
+
+func ParseConfigBundle(b []byte) (map[string]interface{}, error) {
+	var (
+		bundle map[string]interface{}
+		v      interface{}
+	)
+
+	if err := json.Unmarshal(b, &bundle); err == nil {
+		return bundle, nil
+	}
+
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("invalid bundle, %v", err)
+	}
+
+	if _, ok := v.(map[string]interface{}); !ok {
+		return nil, errors.New("invalid bundle, not an object")
+	}
+
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bundle, %v", err)
+	}
+
+	if err = json.Unmarshal(j, &bundle); err != nil {
+		return nil, fmt.Errorf("invalid bundle, %v", err)
+	}
+
+	return bundle, nil
+}
@@ -17,9 +17,11 @@ package main
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"os/signal"
 	"strconv"
@@ -27,66 +29,236 @@ import (
 	"time"
 )
 
+const (
+	ControllerRestartExitCode = 75
+	IngestQueueSize           = 64
+)
+
 type Controller struct {
-	Admin       *Admin
-	Api         *Api
-	Calls       *Calls
-	Config      *Config
-	Database    *Database
-	Accesses    *Accesses
-	Apikeys     *Apikeys
-	Dirwatches  *Dirwatches
-	Downstreams *Downstreams
-	FFMpeg      *FFMpeg
-	Groups      *Groups
-	Logs        *Logs
-	Options     *Options
-	Scheduler   *Scheduler
-	Systems     *Systems
-	Tags        *Tags
-	Clients     *Clients
-	Register    chan *Client
-	Unregister  chan *Client
-	Ingest      chan *Call
-	ingestMutex sync.Mutex
-	running     bool
+	AccessLog         *AccessLog
+	Admin             *Admin
+	Alerts            *Alerts
+	AnomalyDetector   *AnomalyDetector
+	Api               *Api
+	AuditLog          *AuditLog
+	Calls             *Calls
+	CallStats         *CallStats
+	ClockSkews        *ClockSkews
+	Config            *Config
+	Conversions       *Conversions
+	Database          *Database
+	Directory         *Directory
+	Accesses          *Accesses
+	Apikeys           *Apikeys
+	Backup            *Backup
+	BlacklistCounters *BlacklistCounters
+	Bookmarks         *Bookmarks
+	Bots              *Bots
+	Broadcastify      *Broadcastify
+	Dirwatches        *Dirwatches
+	Downstreams       *Downstreams
+	Duplicates        *Duplicates
+	FFMpeg            *FFMpeg
+	Frequencies       *Frequencies
+	Groups            *Groups
+	Heartbeats        *Heartbeats
+	HttpClients       *HttpClients
+	IncidentDetector  *IncidentDetector
+	Incidents         *Incidents
+	IngestAck         *IngestAck
+	Leases            *Leases
+	ListenerStats     *ListenerStats
+	Logs              *Logs
+	Monitor           *Monitor
+	Notifications     *Notifications
+	Oidc              *Oidc
+	Options           *Options
+	Patches           *Patches
+	Poller            *Poller
+	Queues            *Queues
+	RadioReference    *RadioReference
+	RateLimiter       *RateLimiter
+	Retentions        *Retentions
+	Rooms             *Rooms
+	Saml              *Saml
+	Scheduler         *Scheduler
+	Sdrs              *Sdrs
+	Statistics        *Statistics
+	Storage           *Storage
+	Systems           *Systems
+	Tags              *Tags
+	Telemetry         *Telemetry
+	Transcriber       *Transcriber
+	UnitsImporter     *UnitsImporter
+	Updater           *Updater
+	Voter             *Voter
+	Watchdog          *Watchdog
+	Webhooks          *Webhooks
+	Clients           *Clients
+	Register          chan *Client
+	Unregister        chan *Client
+	Ingest            chan *Call
+	ingestMutex       sync.Mutex
+	restart           []string
+	restartLock       sync.Mutex
+	running           bool
+	startedAt         time.Time
 }
 
 func NewController(config *Config) *Controller {
 	controller := &Controller{
-		Config:      config,
-		Accesses:    NewAccesses(),
-		Apikeys:     NewApikeys(),
-		Calls:       NewCalls(),
-		Dirwatches:  NewDirwatches(),
-		Downstreams: NewDownstreams(),
-		FFMpeg:      NewFFMpeg(),
-		Groups:      NewGroups(),
-		Logs:        NewLogs(),
-		Options:     NewOptions(),
-		Systems:     NewSystems(),
-		Tags:        NewTags(),
-		Clients:     NewClients(),
-		Register:    make(chan *Client),
-		Unregister:  make(chan *Client),
-		Ingest:      make(chan *Call),
-		ingestMutex: sync.Mutex{},
+		Config:            config,
+		Accesses:          NewAccesses(),
+		Apikeys:           NewApikeys(),
+		BlacklistCounters: NewBlacklistCounters(),
+		Calls:             NewCalls(),
+		Dirwatches:        NewDirwatches(),
+		Downstreams:       NewDownstreams(),
+		Duplicates:        NewDuplicates(),
+		FFMpeg:            NewFFMpeg(config),
+		Frequencies:       NewFrequencies(),
+		Groups:            NewGroups(),
+		Heartbeats:        NewHeartbeats(),
+		Leases:            NewLeases(),
+		Logs:              NewLogs(),
+		Options:           NewOptions(),
+		Retentions:        NewRetentions(),
+		Rooms:             NewRooms(),
+		Sdrs:              NewSdrs(),
+		Statistics:        NewStatistics(),
+		Systems:           NewSystems(),
+		Tags:              NewTags(),
+		Updater:           NewUpdater(),
+		Webhooks:          NewWebhooks(),
+		Clients:           NewClients(),
+		Register:          make(chan *Client),
+		Unregister:        make(chan *Client),
+		Ingest:            make(chan *Call, IngestQueueSize),
+		ingestMutex:       sync.Mutex{},
 	}
 
+	controller.AccessLog = NewAccessLog(controller)
 	controller.Admin = NewAdmin(controller)
+	controller.Alerts = NewAlerts(controller)
+	controller.AnomalyDetector = NewAnomalyDetector(controller)
 	controller.Api = NewApi(controller)
+	controller.AuditLog = NewAuditLog(controller)
+	controller.Backup = NewBackup(controller)
+	controller.Bookmarks = NewBookmarks(controller)
+	controller.Bots = NewBots(controller)
+	controller.Broadcastify = NewBroadcastify(controller)
+	controller.CallStats = NewCallStats(controller)
+	controller.ClockSkews = NewClockSkews(controller)
+	controller.Conversions = NewConversions(controller)
 	controller.Database = NewDatabase(config)
+	controller.Directory = NewDirectory(controller)
+	controller.HttpClients = NewHttpClients(controller)
+	controller.IncidentDetector = NewIncidentDetector(controller)
+	controller.Incidents = NewIncidents(controller)
+	controller.IngestAck = NewIngestAck(controller)
+	controller.ListenerStats = NewListenerStats(controller)
+	controller.Monitor = NewMonitor(controller)
+	controller.Notifications = NewNotifications(controller)
+	controller.Oidc = NewOidc(controller)
+	controller.Patches = NewPatches(controller)
+	controller.Poller = NewPoller(controller)
+	controller.Queues = NewQueues(controller)
+	controller.RadioReference = NewRadioReference(controller)
+	controller.RateLimiter = NewRateLimiter(controller)
+	controller.Saml = NewSaml(controller)
 	controller.Scheduler = NewScheduler(controller)
+	controller.Storage = NewStorage(controller)
+	controller.Telemetry = NewTelemetry(controller)
+	controller.Transcriber = NewTranscriber(controller)
+	controller.UnitsImporter = NewUnitsImporter(controller)
+	controller.Voter = NewVoter(controller)
+	controller.Watchdog = NewWatchdog(controller)
+
+	controller.Calls.storage = controller.Storage
+
+	controller.Accesses.setAuthWebhook(NewAuthWebhook(controller.Options, config.LowMemory))
+	controller.Accesses.setOidc(controller.Oidc)
+
+	if config.LowMemory {
+		SetAudioBufferMaxPooledSize(AudioBufferMaxPooledSizeLowMemory)
+	}
 
 	controller.Logs.setDaemon(config.daemon)
-	controller.Logs.setDatabase(controller.Database)
+
+	if !config.ReadOnly {
+		controller.Logs.setDatabase(controller.Database)
+	}
 
 	return controller
 }
 
+func (controller *Controller) addListened(client *Client) {
+	if client.Access == nil || client.listening.IsZero() {
+		return
+	}
+
+	listened := time.Since(client.listening)
+	client.listening = time.Time{}
+
+	if err := controller.Accesses.AddListened(controller.Database, client.Access, listened); err != nil {
+		controller.Logs.LogEvent(LogLevelError, err.Error())
+	}
+}
+
+func (controller *Controller) CheckDuplicate(call *Call, system *System) (uint, time.Time, bool) {
+	strategy := system.GetDuplicateStrategy()
+
+	if strategy == "fingerprint" && controller.FFMpeg.available {
+		if _, err := call.GetAudioFingerprint(controller.FFMpeg); err == nil {
+			return controller.Calls.CheckFingerprintDuplicate(call, controller.Options.DuplicateFingerprintWindow, float64(controller.Options.DuplicateFingerprintMatch)/100, controller.Database)
+		} else {
+			controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("duplicate detection: %v, falling back to the time window", err))
+		}
+	}
+
+	return controller.Calls.CheckDuplicate(call, controller.Options.DuplicateDetectionTimeFrame, strategy == "hash", controller.Database)
+}
+
 func (controller *Controller) EmitCall(call *Call) {
-	controller.Clients.EmitCall(call, controller.Accesses.IsRestricted())
-	controller.Downstreams.Send(controller, call)
+	var delay, downstreamDelay time.Duration
+
+	if system, ok := controller.Systems.GetSystem(call.System); ok {
+		if talkgroup, ok := system.Talkgroups.GetTalkgroup(call.Talkgroup); ok {
+			delay = time.Duration(talkgroup.Delay) * time.Second
+			downstreamDelay = time.Duration(talkgroup.DownstreamDelay) * time.Second
+		}
+	}
+
+	broadcast := func() {
+		var linked []TalkgroupRef
+
+		done := controller.Watchdog.Enter("broadcast")
+		if controller.Options.LinkedTalkgroups {
+			linked = controller.Systems.GetLinkedTalkgroups(call.System, call.Talkgroup)
+		}
+		controller.Clients.EmitCall(call, controller.Accesses.IsRestricted(), linked)
+		controller.Bots.EmitCall(call)
+		controller.Poller.Add(call)
+		done()
+	}
+
+	if delay > 0 {
+		time.AfterFunc(delay, broadcast)
+	} else {
+		broadcast()
+	}
+
+	if downstreamDelay > 0 {
+		time.AfterFunc(downstreamDelay, func() {
+			controller.Downstreams.Send(controller, call)
+			controller.Broadcastify.Send(call)
+		})
+	} else {
+		controller.Downstreams.Send(controller, call)
+		controller.Broadcastify.Send(call)
+	}
+
+	controller.Webhooks.Send(controller, call)
 }
 
 func (controller *Controller) EmitConfig() {
@@ -94,6 +266,19 @@ func (controller *Controller) EmitConfig() {
 	controller.Admin.BroadcastConfig()
 }
 
+func (controller *Controller) GetPendingRestart() []string {
+	controller.restartLock.Lock()
+	defer controller.restartLock.Unlock()
+
+	restart := append([]string{}, controller.restart...)
+
+	for _, name := range controller.Options.GetPendingRestart() {
+		restart = append(restart, fmt.Sprintf("option %s changed", name))
+	}
+
+	return restart
+}
+
 func (controller *Controller) IngestCall(call *Call) {
 	var (
 		err        error
@@ -110,23 +295,82 @@ func (controller *Controller) IngestCall(call *Call) {
 		talkgroup  *Talkgroup
 	)
 
+	if controller.Options.VotingWindow > 0 && !call.voted && !call.historical {
+		controller.Voter.Submit(call, controller.Options.VotingWindow)
+		return
+	}
+
+	if !controller.Options.DisableDuplicateDetection && !call.deferred && !call.historical {
+		if s, ok := controller.Systems.GetSystem(call.System); ok && s.IsSecondarySource(call) {
+			call.deferred = true
+			time.AfterFunc(time.Duration(controller.Options.DuplicateDetectionTimeFrame)*time.Millisecond, func() {
+				controller.Ingest <- call
+			})
+			return
+		}
+	}
+
 	controller.IngestLock()
 	defer controller.IngestUnlock()
 
+	defer func() {
+		call.ReleaseAudio()
+		switch v := call.alternates.(type) {
+		case []*Call:
+			for _, alternate := range v {
+				alternate.ReleaseAudio()
+			}
+		}
+	}()
+
+	var outcome string
+
+	defer func() {
+		if call.Id != nil {
+			controller.IngestAck.Send(call, "success", outcome)
+		} else {
+			controller.IngestAck.Send(call, "rejected", outcome)
+		}
+
+		if call.ingested != nil {
+			call.ingested <- outcome
+		}
+	}()
+
 	logCall := func(call *Call, level string, message string) {
+		outcome = message
+		if len(call.requestId) > 0 {
+			message = fmt.Sprintf("id=%s %s", call.requestId, message)
+		}
 		controller.Logs.LogEvent(level, fmt.Sprintf("newcall: system=%v talkgroup=%v file=%v %v", call.System, call.Talkgroup, call.AudioName, message))
 	}
 
 	logError := func(err error) {
+		outcome = err.Error()
 		controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("controller.ingestcall: %v", err.Error()))
 	}
 
 	if system, ok = controller.Systems.GetSystem(call.System); ok {
 		if system.Blacklists.IsBlacklisted(call.Talkgroup) {
+			controller.BlacklistCounters.Add(call, system, nil, 0)
 			logCall(call, LogLevelInfo, "blacklisted")
 			return
 		}
 		talkgroup, _ = system.Talkgroups.GetTalkgroup(call.Talkgroup)
+
+		if units := call.GetUnits(); len(units) > 0 {
+			blacklisted := 0
+			for _, unit := range units {
+				if system.UnitBlacklists.IsBlacklisted(unit) || (talkgroup != nil && talkgroup.UnitBlacklists.IsBlacklisted(unit)) {
+					blacklisted++
+				}
+			}
+			if blacklisted == len(units) {
+				controller.BlacklistCounters.Add(call, system, talkgroup, units[0])
+				logCall(call, LogLevelInfo, fmt.Sprintf("unit %v blacklisted", units[0]))
+				return
+			}
+		}
 	}
 
 	if controller.Options.AutoPopulate && system == nil {
@@ -279,25 +523,91 @@ func (controller *Controller) IngestCall(call *Call) {
 		return
 	}
 
+	call.GetAudioHash()
+
 	if !controller.Options.DisableDuplicateDetection {
-		if controller.Calls.CheckDuplicate(call, controller.Options.DuplicateDetectionTimeFrame, controller.Database) {
+		strategy := system.GetDuplicateStrategy()
+
+		controller.Duplicates.Checked()
+		if id, dateTime, ok := controller.CheckDuplicate(call, system); ok {
+			if strategy == "keep" {
+				if err := controller.Calls.WriteAlternates(id, []*Call{call}, controller.Options.GetMaxCallSize(), controller.Database); err != nil {
+					logError(err)
+					return
+				}
+				controller.Duplicates.Add(call, system, talkgroup, id, dateTime, true)
+				logCall(call, LogLevelInfo, fmt.Sprintf("duplicate call grouped with call %v", id))
+				return
+			}
+
+			controller.Duplicates.Add(call, system, talkgroup, id, dateTime, false)
 			logCall(call, LogLevelWarn, "duplicate call rejected")
 			return
 		}
 	}
 
-	if !controller.Options.DisableAudioConversion {
-		if err := controller.FFMpeg.Convert(call, controller.Systems, controller.Tags); err != nil {
-			controller.Logs.LogEvent(LogLevelWarn, err.Error())
+	if call.GetAudioSize() > controller.Options.GetMaxCallSize() {
+		logCall(call, LogLevelWarn, "call exceeds the maximum size, rejected")
+		return
+	}
+
+	timing := NewServerTiming()
+
+	if call.converted {
+		timing.Entries = append(timing.Entries, ServerTimingEntry{Duration: call.convertTime, Name: "convert"})
+		if call.convertError != nil {
+			logCall(call, LogLevelWarn, call.convertError.Error())
 		}
+
+	} else if system.IsAudioConversionEnabled(controller.Options) {
+		stop := timing.Start("convert")
+		if err := controller.FFMpeg.Convert(call, controller.Systems, controller.Tags, controller.Options); err != nil {
+			logCall(call, LogLevelWarn, err.Error())
+		}
+		stop()
 	}
 
+	if err = call.LoadAudio(controller.Options.GetMaxCallSize()); err != nil {
+		logError(err)
+		return
+	}
+
+	if max := controller.Options.MaxCallDuration; max > 0 && call.GetDuration() > max*1000 {
+		if controller.Options.TruncateLongCalls && call.duration > 0 && call.duration <= max*1000+100 {
+			call.duration = max * 1000
+		} else {
+			logCall(call, LogLevelWarn, fmt.Sprintf("call exceeds the maximum duration of %vs, rejected", max))
+			return
+		}
+	}
+
+	stop := timing.Start("db")
+
+	call.Conversation = controller.Calls.GetConversation(call, controller.Options.ConversationGap, controller.Database)
+
 	if id, err = controller.Calls.WriteCall(call, controller.Database); err == nil {
+		stop()
+
 		call.Id = id
+
+		switch v := call.alternates.(type) {
+		case []*Call:
+			if len(v) > 0 {
+				if err = controller.Calls.WriteAlternates(id, v, controller.Options.GetMaxCallSize(), controller.Database); err != nil {
+					logError(err)
+				}
+			}
+		}
+		call.alternates = nil
+		call.streamOnly = system.StreamOnly
 		call.systemLabel = system.Label
 		call.talkgroupLabel = talkgroup.Label
 		call.talkgroupName = talkgroup.Name
 
+		if members := system.Talkgroups.GetPatchMembers(call); len(members) > 0 {
+			call.patchMembers = members
+		}
+
 		if group == nil {
 			if group, ok = controller.Groups.GetGroup(talkgroup.GroupId); ok {
 				call.talkgroupGroup = group.Label
@@ -310,9 +620,31 @@ func (controller *Controller) IngestCall(call *Call) {
 			}
 		}
 
-		logCall(call, LogLevelInfo, "success")
+		if controller.Options.ServerTiming {
+			logCall(call, LogLevelInfo, fmt.Sprintf("success timing=\"%s\"", timing.String()))
+		} else {
+			logCall(call, LogLevelInfo, "success")
+		}
+
+		if err = controller.Frequencies.Record(controller.Database, call); err != nil {
+			logError(err)
+		}
+
+		if err = controller.Patches.Record(call); err != nil {
+			logError(err)
+		}
+
+		if !call.historical {
+			controller.Monitor.Seen(call.System)
+
+			controller.EmitCall(call)
+
+			controller.Transcriber.Submit(call)
+
+			controller.Alerts.CheckCall(call)
 
-		controller.EmitCall(call)
+			controller.Alerts.CheckTranscript(call)
+		}
 
 	} else {
 		logError(err)
@@ -338,14 +670,42 @@ func (controller *Controller) ProcessMessage(client *Client, message *Message) e
 	} else if controller.Accesses.IsRestricted() && client.Access.Systems == nil && message.Command != MessageCommandPin {
 		client.Send <- &Message{Command: MessageCommandPin}
 
+	} else if message.Command == MessageCommandAlternate {
+		if err := controller.ProcessMessageCommandAlternate(client, message); err != nil {
+			return err
+		}
+
+	} else if message.Command == MessageCommandBookmark {
+		if err := controller.ProcessMessageCommandBookmark(client, message); err != nil {
+			return err
+		}
+
 	} else if message.Command == MessageCommandCall {
 		if err := controller.ProcessMessageCommandCall(client, message); err != nil {
 			return err
 		}
 
 	} else if message.Command == MessageCommandConfig {
+		switch v := message.Payload.(type) {
+		case map[string]interface{}:
+			switch v := v["audioUrl"].(type) {
+			case bool:
+				client.audioUrl = v
+			}
+		}
+
 		client.SendConfig(controller.Groups, controller.Options, controller.Systems, controller.Tags)
 
+	} else if message.Command == MessageCommandDirectory {
+		if err := controller.ProcessMessageCommandDirectory(client); err != nil {
+			return err
+		}
+
+	} else if message.Command == MessageCommandHistogram {
+		if err := controller.ProcessMessageCommandHistogram(client, message); err != nil {
+			return err
+		}
+
 	} else if message.Command == MessageCommandListCall {
 		if err := controller.ProcessMessageCommandListCall(client, message); err != nil {
 			return err
@@ -358,6 +718,121 @@ func (controller *Controller) ProcessMessage(client *Client, message *Message) e
 		if err := controller.ProcessMessageCommandPin(client, message); err != nil {
 			return err
 		}
+
+	} else if message.Command == MessageCommandQueue {
+		if err := controller.ProcessMessageCommandQueue(client, message); err != nil {
+			return err
+		}
+
+	} else if message.Command == MessageCommandRelatedCalls {
+		if err := controller.ProcessMessageCommandRelatedCalls(client, message); err != nil {
+			return err
+		}
+
+	} else if message.Command == MessageCommandRoom {
+		if err := controller.ProcessMessageCommandRoom(client, message); err != nil {
+			return err
+		}
+
+	} else if message.Command == MessageCommandTimeline {
+		if err := controller.ProcessMessageCommandTimeline(client, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (controller *Controller) ProcessMessageCommandAlternate(client *Client, message *Message) error {
+	var (
+		call *Call
+		err  error
+		i    int
+		id   uint
+	)
+
+	switch v := message.Payload.(type) {
+	case float64:
+		id = uint(v)
+	case string:
+		if i, err = strconv.Atoi(v); err == nil {
+			id = uint(i)
+		} else {
+			return err
+		}
+	}
+
+	if call, err = controller.Calls.GetAlternate(id, controller.Database); err != nil {
+		return err
+	}
+
+	if !client.Access.HasArchiveAccess(call.DateTime) {
+		return nil
+	}
+
+	if !controller.Accesses.IsRestricted() || client.Access.HasAccess(call) {
+		if controller.IsStreamOnly(client, call) {
+			if message.Flag == MessageFlagDownload {
+				return nil
+			}
+			call = controller.GetStreamOnlyCall(call)
+		}
+
+		call = controller.GetWatermarkedCall(client, call)
+
+		client.Send <- &Message{Command: MessageCommandCall, Payload: call, Flag: message.Flag}
+	}
+
+	return nil
+}
+
+func (controller *Controller) ProcessMessageCommandBookmark(client *Client, message *Message) error {
+	var req map[string]interface{}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("controller.processmessage.commandbookmark: %v", err)
+	}
+
+	switch v := message.Payload.(type) {
+	case map[string]interface{}:
+		req = v
+	default:
+		return nil
+	}
+
+	action, _ := req["action"].(string)
+
+	owner := GetListenerBookmarkOwner(client)
+	if len(owner) == 0 {
+		client.Send <- &Message{Command: MessageCommandBookmark, Payload: map[string]interface{}{"action": action, "error": "bookmarks require an identified access code"}}
+		return nil
+	}
+
+	switch action {
+	case "delete":
+		id, _ := req["id"].(float64)
+		if _, err := controller.Bookmarks.Delete(uint(id), owner, false); err != nil {
+			return formatError(err)
+		}
+		client.Send <- &Message{Command: MessageCommandBookmark, Payload: map[string]interface{}{"action": action, "id": uint(id)}}
+
+	case "list":
+		options := (&BookmarksSearchOptions{client: client, owner: owner}).FromMap(req)
+		results, err := controller.Bookmarks.Search(options)
+		if err != nil {
+			return formatError(err)
+		}
+		client.Send <- &Message{Command: MessageCommandBookmark, Payload: map[string]interface{}{"action": action, "results": results}}
+
+	case "save":
+		callId, _ := req["callId"].(float64)
+		note, _ := req["note"].(string)
+		shared, _ := req["shared"].(bool)
+		if bookmark, err := controller.Bookmarks.Save(uint(callId), owner, note, shared, client); err != nil {
+			client.Send <- &Message{Command: MessageCommandBookmark, Payload: map[string]interface{}{"action": action, "error": err.Error()}}
+		} else {
+			client.Send <- &Message{Command: MessageCommandBookmark, Payload: map[string]interface{}{"action": action, "bookmark": bookmark}}
+		}
 	}
 
 	return nil
@@ -382,24 +857,105 @@ func (controller *Controller) ProcessMessageCommandCall(client *Client, message
 		}
 	}
 
+	timing := NewServerTiming()
+
+	stop := timing.Start("db")
 	if call, err = controller.Calls.GetCall(id, controller.Database); err != nil {
 		return err
 	}
+	stop()
+
+	if system, ok := controller.Systems.GetSystem(call.System); ok {
+		if members := system.Talkgroups.GetPatchMembers(call); len(members) > 0 {
+			call.patchMembers = members
+		}
+	}
+
+	if alternates, err := controller.Calls.GetAlternates(id, controller.Database); err == nil && len(alternates) > 0 {
+		call.alternates = alternates
+	}
+
+	if !client.Access.HasArchiveAccess(call.DateTime) {
+		return nil
+	}
 
 	if !controller.Accesses.IsRestricted() || client.Access.HasAccess(call) {
-		client.Send <- &Message{Command: MessageCommandCall, Payload: call, Flag: message.Flag}
+		if controller.IsStreamOnly(client, call) {
+			if message.Flag == MessageFlagDownload {
+				return nil
+			}
+			call = controller.GetStreamOnlyCall(call)
+		}
+
+		call = controller.GetWatermarkedCall(client, call)
+		call = controller.GetAudioUrlCall(client, call)
+
+		controller.ListenerStats.Record(client, call)
+
+		if controller.Options.ServerTiming {
+			stop = timing.Start("serialize")
+			b, err := json.Marshal(call)
+			if err != nil {
+				return err
+			}
+			stop()
+
+			client.Send <- &Message{Command: MessageCommandCall, Payload: timing.Inject(b), Flag: message.Flag}
+
+		} else {
+			client.Send <- &Message{Command: MessageCommandCall, Payload: call, Flag: message.Flag}
+		}
+	}
+
+	return nil
+}
+
+func (controller *Controller) ProcessMessageCommandDirectory(client *Client) error {
+	listing, err := controller.Directory.GetListing()
+	if err != nil {
+		client.Send <- &Message{Command: MessageCommandDirectory}
+		return fmt.Errorf("controller.processmessage.commanddirectory: %v", err)
 	}
 
+	client.Send <- &Message{Command: MessageCommandDirectory, Payload: listing}
+
+	return nil
+}
+
+func (controller *Controller) ProcessMessageCommandHistogram(client *Client, message *Message) error {
+	switch v := message.Payload.(type) {
+	case map[string]interface{}:
+		searchOptions := CallsSearchOptions{linkedTalkgroups: controller.Options.LinkedTalkgroups, searchPatchedTalkgroups: controller.Options.SearchPatchedTalkgroups}
+		searchOptions.fromMap(v)
+		if histogram, err := controller.Calls.Histogram(&searchOptions, client); err == nil {
+			client.Send <- &Message{Command: MessageCommandHistogram, Payload: histogram}
+		} else {
+			return fmt.Errorf("controller.processmessage.commandhistogram: %v", err)
+		}
+	}
 	return nil
 }
 
 func (controller *Controller) ProcessMessageCommandListCall(client *Client, message *Message) error {
 	switch v := message.Payload.(type) {
 	case map[string]interface{}:
-		searchOptions := CallsSearchOptions{searchPatchedTalkgroups: controller.Options.SearchPatchedTalkgroups}
+		timing := NewServerTiming()
+		searchOptions := CallsSearchOptions{linkedTalkgroups: controller.Options.LinkedTalkgroups, searchPatchedTalkgroups: controller.Options.SearchPatchedTalkgroups}
 		searchOptions.fromMap(v)
+		stop := timing.Start("db")
 		if searchResults, err := controller.Calls.Search(&searchOptions, client); err == nil {
-			client.Send <- &Message{Command: MessageCommandListCall, Payload: searchResults}
+			stop()
+			if controller.Options.ServerTiming {
+				stop = timing.Start("serialize")
+				b, err := json.Marshal(searchResults)
+				if err != nil {
+					return fmt.Errorf("controller.processmessage.commandlistcall: %v", err)
+				}
+				stop()
+				client.Send <- &Message{Command: MessageCommandListCall, Payload: timing.Inject(b)}
+			} else {
+				client.Send <- &Message{Command: MessageCommandListCall, Payload: searchResults}
+			}
 		} else {
 			return fmt.Errorf("controller.processmessage.commandlistcall: %v", err)
 		}
@@ -429,11 +985,20 @@ func (controller *Controller) ProcessMessageCommandPin(client *Client, message *
 		}
 
 		if controller.Accesses.IsRestricted() {
+			controller.addListened(client)
+
 			code := string(b)
 			if access, ok := controller.Accesses.GetAccess(code); ok {
 				client.Access = access
+			} else if access, ok, err := controller.Accesses.GetExternalAccess(code, client.GetRemoteAddr()); ok {
+				client.Access = access
 			} else {
+				if err != nil {
+					controller.Logs.LogEvent(LogLevelError, err.Error())
+				}
+
 				controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("invalid access code=\"%s\" address=\"%s\"", code, client.GetRemoteAddr()))
+				controller.RateLimiter.Fail(client.GetRemoteAddr())
 				client.Send <- &Message{Command: MessageCommandPin}
 				return nil
 			}
@@ -450,6 +1015,12 @@ func (controller *Controller) ProcessMessageCommandPin(client *Client, message *
 				return nil
 			}
 
+			if _, active := controller.Clients.AccessUsage(client.Access); client.Access.HasExceededHours(active) {
+				controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("access ident=\"%s\" listening hours exhausted, limit is %d", client.Access.Ident, client.Access.Hours))
+				client.Send <- &Message{Command: MessageCommandExpired}
+				return nil
+			}
+
 			switch v := client.Access.Limit.(type) {
 			case uint:
 				if controller.Clients.AccessCount(client) > int(v) {
@@ -462,12 +1033,281 @@ func (controller *Controller) ProcessMessageCommandPin(client *Client, message *
 
 		client.AuthCount = 0
 
+		if controller.Accesses.IsRestricted() {
+			client.listening = time.Now()
+		}
+
 		client.SendConfig(controller.Groups, controller.Options, controller.Systems, controller.Tags)
 	}
 
 	return nil
 }
 
+func (controller *Controller) ProcessMessageCommandQueue(client *Client, message *Message) error {
+	var (
+		action     string
+		id         string
+		priorities interface{}
+	)
+
+	if !controller.Options.ServerQueue {
+		return nil
+	}
+
+	switch v := message.Payload.(type) {
+	case map[string]interface{}:
+		switch v := v["action"].(type) {
+		case string:
+			action = v
+		}
+
+		switch v := v["id"].(type) {
+		case string:
+			id = v
+		}
+
+		priorities = v["priorities"]
+	}
+
+	switch action {
+	case "attach":
+		if len(id) == 0 {
+			return errors.New("controller.processmessage.commandqueue: no queue id")
+		}
+		client.queue = controller.Queues.Attach(client, id, priorities)
+
+	case "clear":
+		controller.Queues.Clear(client.queue)
+
+	case "detach":
+		client.queue = ""
+		client.Send <- &Message{Command: MessageCommandQueue}
+		return nil
+
+	case "next":
+		if callId, ok := controller.Queues.Next(client.queue); ok {
+			if err := controller.ProcessMessageCommandCall(client, &Message{Command: MessageCommandCall, Payload: float64(callId)}); err != nil {
+				return fmt.Errorf("controller.processmessage.commandqueue: %v", err)
+			}
+		}
+	}
+
+	if len(client.queue) > 0 {
+		controller.Queues.EmitStatus(client.queue)
+	}
+
+	return nil
+}
+
+func (controller *Controller) ProcessMessageCommandRelatedCalls(client *Client, message *Message) error {
+	var (
+		call    *Call
+		err     error
+		i       int
+		id      uint
+		results []CallsSearchResult
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("controller.processmessage.commandrelatedcalls: %v", err)
+	}
+
+	switch v := message.Payload.(type) {
+	case float64:
+		id = uint(v)
+	case string:
+		if i, err = strconv.Atoi(v); err == nil {
+			id = uint(i)
+		} else {
+			return formatError(err)
+		}
+	}
+
+	if call, err = controller.Calls.GetCall(id, controller.Database); err != nil {
+		return formatError(err)
+	}
+
+	if controller.Accesses.IsRestricted() && !client.Access.HasAccess(call) {
+		return nil
+	}
+
+	if results, err = controller.Calls.GetRelatedCalls(call, controller.Options.RelatedCallsWindow, client); err != nil {
+		return formatError(err)
+	}
+
+	client.Send <- &Message{
+		Command: MessageCommandRelatedCalls,
+		Payload: map[string]interface{}{
+			"id":      id,
+			"results": results,
+		},
+	}
+
+	return nil
+}
+
+func (controller *Controller) ProcessMessageCommandRoom(client *Client, message *Message) error {
+	var (
+		err   error
+		room  *Room
+		state map[string]interface{}
+	)
+
+	if !controller.Options.ListeningRooms {
+		return nil
+	}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("controller.processmessage.commandroom: %v", err)
+	}
+
+	switch v := message.Payload.(type) {
+	case map[string]interface{}:
+		state = v
+	default:
+		return nil
+	}
+
+	switch state["action"] {
+	case "create":
+		name, _ := state["name"].(string)
+		if room, err = controller.Rooms.Create(client, name); err != nil {
+			return formatError(err)
+		}
+		controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("rooms: room %s created by %s", room.Id, client.GetRemoteAddr()))
+
+	case "join":
+		id, _ := state["id"].(string)
+		if room, err = controller.Rooms.Join(client, id); err != nil {
+			client.Send <- &Message{Command: MessageCommandRoom, Payload: map[string]interface{}{"error": err.Error()}}
+			return nil
+		}
+
+	case "leave":
+		controller.Rooms.Leave(client)
+		client.Send <- &Message{Command: MessageCommandRoom}
+		return nil
+
+	case "sync":
+		if room, err = controller.Rooms.Sync(client, state); err != nil {
+			client.Send <- &Message{Command: MessageCommandRoom, Payload: map[string]interface{}{"error": err.Error()}}
+			return nil
+		}
+
+	default:
+		return nil
+	}
+
+	controller.Rooms.Emit(room)
+
+	return nil
+}
+
+func (controller *Controller) ProcessMessageCommandTimeline(client *Client, message *Message) error {
+	const (
+		defaultWindow = 300
+		maxWindow     = 3600
+	)
+
+	var (
+		at        time.Time
+		err       error
+		system    uint
+		talkgroup uint
+		timeline  *CallsTimeline
+		window    uint = defaultWindow
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("controller.processmessage.commandtimeline: %v", err)
+	}
+
+	switch v := message.Payload.(type) {
+	case map[string]interface{}:
+		switch v := v["dateTime"].(type) {
+		case string:
+			if at, err = time.Parse(time.RFC3339, v); err != nil {
+				return formatError(err)
+			}
+		}
+
+		switch v := v["system"].(type) {
+		case float64:
+			system = uint(v)
+		}
+
+		switch v := v["talkgroup"].(type) {
+		case float64:
+			talkgroup = uint(v)
+		}
+
+		switch v := v["window"].(type) {
+		case float64:
+			if v > 0 {
+				window = uint(math.Min(v, maxWindow))
+			}
+		}
+	}
+
+	if system == 0 || talkgroup == 0 || at.IsZero() {
+		return nil
+	}
+
+	if timeline, err = controller.Calls.GetTimeline(system, talkgroup, at, window, client); err != nil {
+		return formatError(err)
+	}
+
+	client.Send <- &Message{Command: MessageCommandTimeline, Payload: timeline}
+
+	return nil
+}
+
+func (controller *Controller) RequestRestart(reason string) {
+	controller.restartLock.Lock()
+	defer controller.restartLock.Unlock()
+
+	for _, r := range controller.restart {
+		if r == reason {
+			return
+		}
+	}
+
+	controller.restart = append(controller.restart, reason)
+
+	controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("restart required: %s", reason))
+}
+
+func (controller *Controller) Restart() {
+	controller.Logs.LogEvent(LogLevelWarn, "server restarting")
+
+	controller.Dirwatches.Stop()
+
+	controller.Admin.Stop()
+
+	controller.Clients.Map.Range(func(k interface{}, _ interface{}) bool {
+		if client, ok := k.(*Client); ok {
+			controller.addListened(client)
+		}
+		return true
+	})
+
+	if err := controller.ListenerStats.Flush(controller.Database); err != nil {
+		log.Println(err)
+	}
+
+	if err := controller.Leases.ReleaseAll(controller.Database); err != nil {
+		log.Println(err)
+	}
+
+	if err := controller.Database.Sql.Close(); err != nil {
+		log.Println(err)
+	}
+
+	log.Println("restarting")
+
+	os.Exit(ControllerRestartExitCode)
+}
+
 func (controller *Controller) Start() error {
 	var err error
 
@@ -477,15 +1317,41 @@ func (controller *Controller) Start() error {
 		controller.running = true
 	}
 
+	controller.startedAt = time.Now()
+
 	controller.Logs.LogEvent(LogLevelWarn, "server started")
 
 	if len(controller.Config.BaseDir) > 0 {
 		log.Printf("base folder is %s\n", controller.Config.BaseDir)
 	}
 
+	if err = controller.FFMpeg.GetError(); err != nil {
+		controller.Logs.LogEvent(LogLevelWarn, err.Error())
+	} else {
+		log.Printf("using ffmpeg %s\n", controller.FFMpeg.version)
+
+		if err = controller.FFMpeg.GetHwAccelError(); err != nil {
+			controller.Logs.LogEvent(LogLevelWarn, err.Error())
+		} else if len(controller.FFMpeg.hwaccel) > 0 {
+			log.Printf("using ffmpeg hardware acceleration %s\n", controller.FFMpeg.hwaccel[1])
+		}
+	}
+
 	if err = controller.Accesses.Read(controller.Database); err != nil {
 		return err
 	}
+	if err = controller.Admin.Users.Read(controller.Database); err != nil {
+		return err
+	}
+	if err = controller.Admin.Totp.Read(controller.Database); err != nil {
+		return err
+	}
+	if err = controller.Admin.Sessions.Read(controller.Database); err != nil {
+		return err
+	}
+	if err = controller.Alerts.Read(controller.Database); err != nil {
+		return err
+	}
 	if err = controller.Apikeys.Read(controller.Database); err != nil {
 		return err
 	}
@@ -501,12 +1367,31 @@ func (controller *Controller) Start() error {
 	if err = controller.Options.Read(controller.Database); err != nil {
 		return err
 	}
+	if err = controller.Retentions.Read(controller.Database); err != nil {
+		return err
+	}
+	if err = controller.Sdrs.Read(controller.Database); err != nil {
+		return err
+	}
 	if err = controller.Systems.Read(controller.Database); err != nil {
 		return err
 	}
 	if err = controller.Tags.Read(controller.Database); err != nil {
 		return err
 	}
+	if err = controller.Webhooks.Read(controller.Database); err != nil {
+		return err
+	}
+	if err = controller.Monitor.Read(controller.Database); err != nil {
+		return err
+	}
+
+	if controller.Config.ReadOnly {
+		controller.Logs.LogEvent(LogLevelWarn, "read-only mode, ingest, configuration changes and pruning are disabled")
+
+	} else if err = controller.Admin.ApplyConfigPassword(); err != nil {
+		return err
+	}
 
 	if err = controller.Admin.Start(); err != nil {
 		return err
@@ -515,6 +1400,22 @@ func (controller *Controller) Start() error {
 		return err
 	}
 
+	if !controller.Config.ReadOnly {
+		controller.Backup.Start()
+		controller.AnomalyDetector.Start()
+		controller.CallStats.Start()
+		controller.IncidentDetector.Start()
+		controller.ListenerStats.Start()
+		controller.Monitor.Start()
+		controller.Notifications.Start()
+		controller.RadioReference.Start()
+		controller.Telemetry.Start()
+		controller.UnitsImporter.Start()
+	}
+	controller.Directory.Start()
+	controller.Transcriber.Start()
+	controller.Watchdog.Start()
+
 	go func() {
 		c := make(chan os.Signal)
 		signal.Notify(c, os.Interrupt)
@@ -522,10 +1423,18 @@ func (controller *Controller) Start() error {
 		controller.Terminate()
 	}()
 
+	controller.Conversions.Start()
+
 	go func() {
 		for {
 			call := <-controller.Ingest
-			controller.IngestCall(call)
+
+			if call.received.IsZero() {
+				call.received = time.Now()
+				controller.ClockSkews.Check(call, call.received)
+			}
+
+			controller.Conversions.Submit(call)
 		}
 	}()
 
@@ -553,18 +1462,41 @@ func (controller *Controller) Start() error {
 
 			case client := <-controller.Unregister:
 				controller.Clients.Remove(client)
+				controller.Rooms.Leave(client)
+				controller.addListened(client)
 				doClientsCount()
 			}
 		}
 	}()
 
-	controller.Dirwatches.Start(controller)
+	if !controller.Config.ReadOnly {
+		controller.Dirwatches.Start(controller)
+		controller.Sdrs.Start(controller)
+	}
 
 	return nil
 }
 
 func (controller *Controller) Terminate() {
 	controller.Dirwatches.Stop()
+	controller.Sdrs.Stop()
+
+	controller.Admin.Stop()
+
+	controller.Clients.Map.Range(func(k interface{}, _ interface{}) bool {
+		if client, ok := k.(*Client); ok {
+			controller.addListened(client)
+		}
+		return true
+	})
+
+	if err := controller.ListenerStats.Flush(controller.Database); err != nil {
+		log.Println(err)
+	}
+
+	if err := controller.Leases.ReleaseAll(controller.Database); err != nil {
+		log.Println(err)
+	}
 
 	if err := controller.Database.Sql.Close(); err != nil {
 		log.Println(err)
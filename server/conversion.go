@@ -0,0 +1,156 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const ConversionQueueSize = 32
+
+type Conversions struct {
+	controller *Controller
+	converting int32
+	jobs       chan *conversionJob
+	mutex      sync.Mutex
+	pending    chan *conversionJob
+	queued     int32
+	workers    int
+}
+
+type conversionJob struct {
+	call *Call
+	done chan struct{}
+}
+
+func NewConversions(controller *Controller) *Conversions {
+	return &Conversions{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (conversions *Conversions) Start() {
+	conversions.mutex.Lock()
+	defer conversions.mutex.Unlock()
+
+	if conversions.pending != nil {
+		return
+	}
+
+	conversions.workers = int(conversions.controller.Options.ConversionWorkers)
+	if conversions.workers < 1 {
+		conversions.workers = 1
+	}
+
+	conversions.jobs = make(chan *conversionJob, ConversionQueueSize+conversions.workers)
+	conversions.pending = make(chan *conversionJob, ConversionQueueSize+conversions.workers)
+
+	for i := 0; i < conversions.workers; i++ {
+		go conversions.work()
+	}
+
+	go func() {
+		for job := range conversions.pending {
+			<-job.done
+
+			done := conversions.controller.Watchdog.Enter("ingest")
+			conversions.controller.IngestCall(job.call)
+			done()
+		}
+	}()
+}
+
+func (conversions *Conversions) QueueDepth() (int, int) {
+	return len(conversions.pending), cap(conversions.pending)
+}
+
+func (conversions *Conversions) Stats() map[string]interface{} {
+	l, c := conversions.QueueDepth()
+
+	return map[string]interface{}{
+		"cap":        c,
+		"converting": atomic.LoadInt32(&conversions.converting),
+		"len":        l,
+		"queued":     atomic.LoadInt32(&conversions.queued),
+		"workers":    conversions.workers,
+	}
+}
+
+func (conversions *Conversions) Submit(call *Call) {
+	job := &conversionJob{call: call, done: make(chan struct{})}
+
+	conversions.pending <- job
+
+	atomic.AddInt32(&conversions.queued, 1)
+	conversions.jobs <- job
+}
+
+func (conversions *Conversions) convert(call *Call) {
+	var (
+		controller = conversions.controller
+		options    = controller.Options
+	)
+
+	if options.VotingWindow > 0 && !call.voted && !call.historical {
+		return
+	}
+
+	system, ok := controller.Systems.GetSystem(call.System)
+	if !ok || !system.IsAudioConversionEnabled(options) || system.Blacklists.IsBlacklisted(call.Talkgroup) {
+		return
+	}
+
+	if _, ok := system.Talkgroups.GetTalkgroup(call.Talkgroup); !ok {
+		return
+	}
+
+	if !options.DisableDuplicateDetection && !call.deferred && !call.historical && system.IsSecondarySource(call) {
+		return
+	}
+
+	call.GetAudioHash()
+
+	if call.GetAudioSize() > options.GetMaxCallSize() {
+		return
+	}
+
+	if !options.DisableDuplicateDetection {
+		if _, _, ok := controller.CheckDuplicate(call, system); ok {
+			return
+		}
+	}
+
+	start := time.Now()
+
+	call.convertError = controller.FFMpeg.Convert(call, controller.Systems, controller.Tags, options)
+	call.convertTime = time.Since(start)
+	call.converted = true
+}
+
+func (conversions *Conversions) work() {
+	for job := range conversions.jobs {
+		atomic.AddInt32(&conversions.queued, -1)
+		atomic.AddInt32(&conversions.converting, 1)
+
+		conversions.convert(job.call)
+
+		atomic.AddInt32(&conversions.converting, -1)
+		close(job.done)
+	}
+}
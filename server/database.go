@@ -30,6 +30,7 @@ import (
 type Database struct {
 	Config         *Config
 	DateTimeFormat string
+	SchemaIssues   []SchemaIssue
 	Sql            *sql.DB
 }
 
@@ -42,8 +43,20 @@ func NewDatabase(config *Config) *Database {
 	case DbTypeSqlite:
 		database.DateTimeFormat = "2006-01-02 15:04:05.000 -07:00"
 
+		if !config.ReadOnly {
+			applyBackupRestore(config.GetDbFilePath())
+		}
+
 		dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout%%3d10000", config.GetDbFilePath())
 
+		if config.LowMemory {
+			dsn += "&_pragma=cache_size%3d-512"
+		}
+
+		if config.ReadOnly {
+			dsn += "&_pragma=query_only%3d1"
+		}
+
 		if database.Sql, err = sql.Open("sqlite", dsn); err != nil {
 			log.Fatal(err)
 		}
@@ -62,14 +75,53 @@ func NewDatabase(config *Config) *Database {
 	}
 
 	database.Sql.SetConnMaxLifetime(time.Minute)
-	database.Sql.SetMaxIdleConns(25)
-	database.Sql.SetMaxOpenConns(25)
+	if config.LowMemory {
+		database.Sql.SetMaxIdleConns(1)
+		database.Sql.SetMaxOpenConns(2)
+	} else {
+		database.Sql.SetMaxIdleConns(25)
+		database.Sql.SetMaxOpenConns(25)
+	}
+
+	if config.ReadOnly {
+		if config.Repair {
+			log.Fatal("unable to repair the database schema in read-only mode")
+		}
+
+	} else if err = database.migrate(); err != nil && config.Repair {
+		log.Println(err)
+
+		if err = database.RepairSchema(database.CheckSchema()); err == nil {
+			err = database.migrate()
+		}
+	}
 
-	if err = database.migrate(); err != nil {
+	if err != nil {
 		log.Fatal(err)
 	}
 
-	if err = database.seed(); err != nil {
+	database.SchemaIssues = database.CheckSchema()
+
+	if config.Repair {
+		database.repair()
+	}
+
+	for _, issue := range database.SchemaIssues {
+		log.Printf("database schema drift: %s", issue.Message)
+	}
+
+	if len(database.SchemaIssues) > 0 {
+		if config.ReadOnly {
+			log.Println("database schema is incomplete, upgrade the primary instance to replicate the missing tables and columns")
+		} else {
+			log.Println("database schema is incomplete, run with -repair to add the missing tables and columns")
+		}
+	}
+
+	if config.ReadOnly {
+		log.Println("database opened in read-only mode")
+
+	} else if err = database.seed(); err != nil {
 		log.Fatal(err)
 	}
 
@@ -127,6 +179,159 @@ func (db *Database) migrate() error {
 	if err == nil {
 		err = db.migration20220101070000(verbose)
 	}
+	if err == nil {
+		err = db.migration20220601090000(verbose)
+	}
+	if err == nil {
+		err = db.migration20220603090000(verbose)
+	}
+	if err == nil {
+		err = db.migration20220606090000(verbose)
+	}
+	if err == nil {
+		err = db.migration20220608090000(verbose)
+	}
+	if err == nil {
+		err = db.migration20220610090000(verbose)
+	}
+	if err == nil {
+		err = db.migration20220612090000(verbose)
+	}
+	if err == nil {
+		err = db.migration20220614090000(verbose)
+	}
+	if err == nil {
+		err = db.migration20220616090000(verbose)
+	}
+	if err == nil {
+		err = db.migration20220618090000(verbose)
+	}
+	if err == nil {
+		err = db.migration20220620090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220622090000(verbose)
+	}
+	if err == nil {
+		err = db.migration20220624090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220626090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220628090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220630090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220702090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220704090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220706090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220708090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220710090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220712090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220714090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220716090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220718090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220720090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220722090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220724090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220726090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220728090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220730090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220801090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220803090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220805090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220807090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220809090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220810090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220811090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220812090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220813090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220814090000(verbose)
+	}
+
+	if err == nil {
+		err = db.migration20220815090000(verbose)
+	}
 
 	return err
 }
@@ -156,6 +361,11 @@ func (db *Database) migrateWithSchema(name string, schemas []string, verbose boo
 		if tx, err = db.Sql.Begin(); err == nil {
 			for _, query = range schemas {
 				if _, err = tx.Exec(query); err != nil {
+					if db.Config.Repair && isSchemaDuplicateError(err) {
+						log.Printf("skipping %s, %v", query, err)
+						continue
+					}
+
 					tx.Rollback()
 					return formatError(err, query)
 				}
@@ -459,6 +669,515 @@ func (db *Database) migration20220101070000(verbose bool) error {
 	return db.migrateWithSchema("20220101070000-v6.1.0", queries, verbose)
 }
 
+func (db *Database) migration20220601090000(verbose bool) error {
+	queries := []string{
+		"create table `rdioScannerLeases` (`name` varchar(255) not null primary key, `holder` varchar(255) not null, `expires` bigint not null)",
+	}
+
+	return db.migrateWithSchema("20220601090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220603090000(verbose bool) error {
+	var queries []string
+
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerStatistics` (`_id` integer primary key autoincrement, `date` varchar(10) not null, `system` integer not null, `talkgroup` integer not null, `calls` integer not null, `airtime` integer not null, `audioBytes` bigint not null, `units` integer not null)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerStatistics` (`_id` integer primary key auto_increment, `date` varchar(10) not null, `system` integer not null, `talkgroup` integer not null, `calls` integer not null, `airtime` integer not null, `audioBytes` bigint not null, `units` integer not null)",
+		}
+	}
+
+	queries = append(queries, "create unique index `rdio_scanner_statistics_date_system_talkgroup` on `rdioScannerStatistics` (`date`, `system`, `talkgroup`)")
+
+	return db.migrateWithSchema("20220603090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220606090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerCalls` add column `conversation` integer",
+		"create index `rdio_scanner_calls_conversation` on `rdioScannerCalls` (`conversation`)",
+	}
+
+	return db.migrateWithSchema("20220606090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220608090000(verbose bool) error {
+	var queries []string
+
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerCallAlternates` (`id` integer primary key autoincrement, `callId` integer not null, `audio` longblob not null, `audioName` varchar(255), `audioType` varchar(255), `dateTime` datetime not null, `frequencies` text not null, `score` real not null, `site` varchar(255))",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerCallAlternates` (`id` integer primary key auto_increment, `callId` integer not null, `audio` longblob not null, `audioName` varchar(255), `audioType` varchar(255), `dateTime` datetime not null, `frequencies` text not null, `score` real not null, `site` varchar(255))",
+		}
+	}
+
+	queries = append(queries, "create index `rdio_scanner_call_alternates_call_id` on `rdioScannerCallAlternates` (`callId`)")
+
+	return db.migrateWithSchema("20220608090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220610090000(verbose bool) error {
+	var queries []string
+
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerFrequencies` (`_id` integer primary key autoincrement, `date` varchar(10) not null, `system` integer not null, `frequency` integer not null, `calls` integer not null, `errors` integer not null, `spikes` integer not null, `controlSamples` integer not null, `decodeRate` real not null)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerFrequencies` (`_id` integer primary key auto_increment, `date` varchar(10) not null, `system` integer not null, `frequency` integer not null, `calls` integer not null, `errors` integer not null, `spikes` integer not null, `controlSamples` integer not null, `decodeRate` real not null)",
+		}
+	}
+
+	queries = append(queries, "create unique index `rdio_scanner_frequencies_date_system_frequency` on `rdioScannerFrequencies` (`date`, `system`, `frequency`)")
+
+	return db.migrateWithSchema("20220610090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220612090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerCalls` add column `duration` integer",
+	}
+
+	return db.migrateWithSchema("20220612090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220614090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerSystems` add column `audioBitrate` integer not null default 0",
+		"alter table `rdioScannerSystems` add column `audioChannels` integer not null default 0",
+		"alter table `rdioScannerSystems` add column `audioCodec` varchar(255)",
+		"alter table `rdioScannerSystems` add column `audioConversion` varchar(255)",
+		"alter table `rdioScannerSystems` add column `audioSampleRate` integer not null default 0",
+	}
+
+	return db.migrateWithSchema("20220614090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220616090000(verbose bool) error {
+	var queries []string
+
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerAdminUsers` (`_id` integer primary key autoincrement, `disabled` tinyint(1) default 0, `password` varchar(255) not null, `role` varchar(255) not null, `username` varchar(255) not null unique)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerAdminUsers` (`_id` integer primary key auto_increment, `disabled` tinyint(1) default 0, `password` varchar(255) not null, `role` varchar(255) not null, `username` varchar(255) not null unique)",
+		}
+	}
+
+	return db.migrateWithSchema("20220616090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220618090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerCalls` add column `audioKey` varchar(255)",
+	}
+
+	return db.migrateWithSchema("20220618090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220620090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerDirWatches` add column `archiveDir` varchar(255)",
+	}
+
+	return db.migrateWithSchema("20220620090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220622090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerCalls` add column `transcript` text",
+	}
+
+	return db.migrateWithSchema("20220622090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220624090000(verbose bool) error {
+	queries := []string{
+		"create table `rdioScannerAdminSessions` (`session` varchar(36) not null primary key, `subject` varchar(255) not null, `refresh` varchar(64) not null, `expires` bigint not null)",
+	}
+
+	return db.migrateWithSchema("20220624090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220626090000(verbose bool) error {
+	var queries []string
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerWebhooks` (`_id` integer primary key autoincrement, `audioLink` tinyint(1) default 0, `disabled` tinyint(1) default 0, `headers` text not null, `order` integer, `systems` text not null, `template` text not null, `url` varchar(255) not null)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerWebhooks` (`_id` integer primary key auto_increment, `audioLink` tinyint(1) default 0, `disabled` tinyint(1) default 0, `headers` text not null, `order` integer, `systems` text not null, `template` text not null, `url` varchar(255) not null)",
+		}
+	}
+
+	return db.migrateWithSchema("20220626090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220628090000(verbose bool) error {
+	var queries []string
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerAlerts` (`_id` integer primary key autoincrement, `burstCount` integer default 0, `burstWindow` integer default 0, `cooldown` integer default 0, `delivery` varchar(255) not null, `disabled` tinyint(1) default 0, `keywords` text not null, `label` varchar(255) not null, `order` integer, `systems` text not null, `target` text not null, `units` text not null)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerAlerts` (`_id` integer primary key auto_increment, `burstCount` integer default 0, `burstWindow` integer default 0, `cooldown` integer default 0, `delivery` varchar(255) not null, `disabled` tinyint(1) default 0, `keywords` text not null, `label` varchar(255) not null, `order` integer, `systems` text not null, `target` text not null, `units` text not null)",
+		}
+	}
+
+	return db.migrateWithSchema("20220628090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220630090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerTalkgroups` add column `delay` integer default 0",
+		"alter table `rdioScannerTalkgroups` add column `downstreamDelay` integer default 0",
+	}
+
+	return db.migrateWithSchema("20220630090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220702090000(verbose bool) error {
+	var queries []string
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerSdrs` (`_id` integer primary key autoincrement, `command` text not null, `disabled` tinyint(1) default 0, `frequency` integer, `hang` integer default 0, `maxDuration` integer default 0, `minDuration` integer default 0, `order` integer, `sampleRate` integer default 0, `squelch` integer default 0, `systemId` integer not null, `talkgroupId` integer not null)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerSdrs` (`_id` integer primary key auto_increment, `command` text not null, `disabled` tinyint(1) default 0, `frequency` integer, `hang` integer default 0, `maxDuration` integer default 0, `minDuration` integer default 0, `order` integer, `sampleRate` integer default 0, `squelch` integer default 0, `systemId` integer not null, `talkgroupId` integer not null)",
+		}
+	}
+
+	return db.migrateWithSchema("20220702090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220704090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerCalls` add column `audioHash` varchar(64)",
+		"alter table `rdioScannerSystems` add column `duplicatePrimary` varchar(255)",
+		"alter table `rdioScannerSystems` add column `duplicateStrategy` varchar(255)",
+	}
+
+	return db.migrateWithSchema("20220704090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220706090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerSystems` add column `unitBlacklists` text",
+		"alter table `rdioScannerTalkgroups` add column `unitBlacklists` text",
+	}
+
+	return db.migrateWithSchema("20220706090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220708090000(verbose bool) error {
+	var queries []string
+
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerCallStats` (`_id` integer primary key autoincrement, `hour` bigint not null, `system` integer not null, `talkgroup` integer not null, `calls` integer not null, `airtime` bigint not null)",
+			"create table `rdioScannerUnitStats` (`_id` integer primary key autoincrement, `hour` bigint not null, `system` integer not null, `talkgroup` integer not null, `unit` integer not null, `calls` integer not null)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerCallStats` (`_id` integer primary key auto_increment, `hour` bigint not null, `system` integer not null, `talkgroup` integer not null, `calls` integer not null, `airtime` bigint not null)",
+			"create table `rdioScannerUnitStats` (`_id` integer primary key auto_increment, `hour` bigint not null, `system` integer not null, `talkgroup` integer not null, `unit` integer not null, `calls` integer not null)",
+		}
+	}
+
+	queries = append(queries,
+		"create unique index `rdio_scanner_call_stats_hour_system_talkgroup` on `rdioScannerCallStats` (`hour`, `system`, `talkgroup`)",
+		"create unique index `rdio_scanner_unit_stats_hour_system_talkgroup_unit` on `rdioScannerUnitStats` (`hour`, `system`, `talkgroup`, `unit`)",
+	)
+
+	return db.migrateWithSchema("20220708090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220710090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerTalkgroups` add column `linkedSystem` integer",
+		"alter table `rdioScannerTalkgroups` add column `linkedTalkgroup` integer",
+	}
+
+	return db.migrateWithSchema("20220710090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220712090000(verbose bool) error {
+	var queries []string
+
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerBookmarks` (`_id` integer primary key autoincrement, `callId` integer not null, `dateTime` datetime not null, `note` text not null, `owner` varchar(255) not null, `shared` tinyint(1) default 0, `updated` datetime not null)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerBookmarks` (`_id` integer primary key auto_increment, `callId` integer not null, `dateTime` datetime not null, `note` text not null, `owner` varchar(255) not null, `shared` tinyint(1) default 0, `updated` datetime not null)",
+		}
+	}
+
+	queries = append(queries,
+		"create unique index `rdio_scanner_bookmarks_call_id_owner` on `rdioScannerBookmarks` (`callId`, `owner`)",
+		"create index `rdio_scanner_bookmarks_owner` on `rdioScannerBookmarks` (`owner`)",
+	)
+
+	return db.migrateWithSchema("20220712090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220714090000(verbose bool) error {
+	var queries []string
+
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerIncidentCalls` (`_id` integer primary key autoincrement, `callId` integer not null, `incidentId` integer not null)",
+			"create table `rdioScannerIncidentNotes` (`_id` integer primary key autoincrement, `author` varchar(255) not null, `dateTime` datetime not null, `incidentId` integer not null, `note` text not null)",
+			"create table `rdioScannerIncidents` (`_id` integer primary key autoincrement, `createdBy` varchar(255) not null, `dateTime` datetime not null, `description` text not null, `status` varchar(255) not null, `title` varchar(255) not null, `updated` datetime not null)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerIncidentCalls` (`_id` integer primary key auto_increment, `callId` integer not null, `incidentId` integer not null)",
+			"create table `rdioScannerIncidentNotes` (`_id` integer primary key auto_increment, `author` varchar(255) not null, `dateTime` datetime not null, `incidentId` integer not null, `note` text not null)",
+			"create table `rdioScannerIncidents` (`_id` integer primary key auto_increment, `createdBy` varchar(255) not null, `dateTime` datetime not null, `description` text not null, `status` varchar(255) not null, `title` varchar(255) not null, `updated` datetime not null)",
+		}
+	}
+
+	queries = append(queries,
+		"create unique index `rdio_scanner_incident_calls_incident_id_call_id` on `rdioScannerIncidentCalls` (`incidentId`, `callId`)",
+		"create index `rdio_scanner_incident_calls_call_id` on `rdioScannerIncidentCalls` (`callId`)",
+		"create index `rdio_scanner_incident_notes_incident_id` on `rdioScannerIncidentNotes` (`incidentId`)",
+	)
+
+	return db.migrateWithSchema("20220714090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220716090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerSystems` add column `broadcastifyApiKey` varchar(255)",
+		"alter table `rdioScannerSystems` add column `broadcastifySystemId` integer",
+		"alter table `rdioScannerTalkgroups` add column `broadcastify` tinyint(1) default 0",
+		"alter table `rdioScannerTalkgroups` add column `broadcastifySlot` integer",
+	}
+
+	return db.migrateWithSchema("20220716090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220718090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerAlerts` add column `anomalies` tinyint(1) default 0",
+	}
+
+	return db.migrateWithSchema("20220718090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220720090000(verbose bool) error {
+	var queries []string
+
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerRetentions` (`_id` integer primary key autoincrement, `days` integer not null default 0, `label` varchar(255) not null, `order` integer, `system` integer not null default 0, `tagId` integer not null default 0, `talkgroup` integer not null default 0)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerRetentions` (`_id` integer primary key auto_increment, `days` integer not null default 0, `label` varchar(255) not null, `order` integer, `system` integer not null default 0, `tagId` integer not null default 0, `talkgroup` integer not null default 0)",
+		}
+	}
+
+	return db.migrateWithSchema("20220720090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220722090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerApiKeys` add column `scopes` varchar(255)",
+	}
+
+	return db.migrateWithSchema("20220722090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220724090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerAccesses` add column `archive` varchar(16)",
+		"alter table `rdioScannerAccesses` add column `archiveHours` integer",
+	}
+
+	return db.migrateWithSchema("20220724090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220726090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerSystems` add column `unitsImportConflict` varchar(16)",
+		"alter table `rdioScannerSystems` add column `unitsImportUrl` text",
+	}
+
+	return db.migrateWithSchema("20220726090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220728090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerAccesses` add column `streamOnly` tinyint(1) default 0",
+		"alter table `rdioScannerSystems` add column `streamOnly` tinyint(1) default 0",
+	}
+
+	return db.migrateWithSchema("20220728090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220730090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerSystems` add column `radioReferenceConflict` varchar(16)",
+		"alter table `rdioScannerSystems` add column `radioReferenceSystemId` integer",
+	}
+
+	return db.migrateWithSchema("20220730090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220801090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerAccesses` add column `watermark` varchar(16)",
+	}
+
+	return db.migrateWithSchema("20220801090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220803090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerDirWatches` add column `password` varchar(255)",
+		"alter table `rdioScannerDirWatches` add column `pollInterval` integer",
+		"alter table `rdioScannerDirWatches` add column `username` varchar(255)",
+	}
+
+	return db.migrateWithSchema("20220803090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220805090000(verbose bool) error {
+	var queries []string
+
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerNotifications` (`_id` integer primary key autoincrement, `count` integer not null default 1, `dateTime` datetime not null, `key` varchar(255) not null, `kind` varchar(255) not null, `message` text not null, `read` tinyint(1) not null default 0, `updated` datetime not null)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerNotifications` (`_id` integer primary key auto_increment, `count` integer not null default 1, `dateTime` datetime not null, `key` varchar(255) not null, `kind` varchar(255) not null, `message` text not null, `read` tinyint(1) not null default 0, `updated` datetime not null)",
+		}
+	}
+
+	queries = append(queries,
+		"create index `rdio_scanner_notifications_kind_key` on `rdioScannerNotifications` (`kind`, `key`)",
+	)
+
+	return db.migrateWithSchema("20220805090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220807090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerCalls` add column `loudness` text",
+	}
+
+	return db.migrateWithSchema("20220807090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220809090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerCalls` add column `audioFingerprint` text",
+	}
+
+	return db.migrateWithSchema("20220809090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220810090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerCalls` add column `transcriptConfidence` real",
+	}
+
+	return db.migrateWithSchema("20220810090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220811090000(verbose bool) error {
+	var queries []string
+
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerPatches` (`_id` integer primary key autoincrement, `dateTime` datetime not null, `patched` integer not null, `system` integer not null, `talkgroup` integer not null, `updated` datetime not null)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerPatches` (`_id` integer primary key auto_increment, `dateTime` datetime not null, `patched` integer not null, `system` integer not null, `talkgroup` integer not null, `updated` datetime not null)",
+		}
+	}
+
+	queries = append(queries,
+		"create index `rdio_scanner_patches_system_talkgroup_patched` on `rdioScannerPatches` (`system`, `talkgroup`, `patched`)",
+	)
+
+	return db.migrateWithSchema("20220811090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220812090000(verbose bool) error {
+	var queries []string
+
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerListenerStats` (`_id` integer primary key autoincrement, `date` varchar(10) not null, `listener` varchar(16) not null, `system` integer not null, `talkgroup` integer not null)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerListenerStats` (`_id` integer primary key auto_increment, `date` varchar(10) not null, `listener` varchar(16) not null, `system` integer not null, `talkgroup` integer not null)",
+		}
+	}
+
+	queries = append(queries,
+		"create index `rdio_scanner_listener_stats_date_system_talkgroup` on `rdioScannerListenerStats` (`date`, `system`, `talkgroup`)",
+		"alter table `rdioScannerStatistics` add column `listeners` integer not null default 0",
+	)
+
+	return db.migrateWithSchema("20220812090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220813090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerAccesses` add column `hours` integer not null default 0",
+		"alter table `rdioScannerAccesses` add column `listened` bigint not null default 0",
+	}
+
+	return db.migrateWithSchema("20220813090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220814090000(verbose bool) error {
+	queries := []string{
+		"alter table `rdioScannerAccesses` add column `deny` text",
+		"alter table `rdioScannerAccesses` add column `schedule` text",
+	}
+
+	return db.migrateWithSchema("20220814090000-v6.5.0", queries, verbose)
+}
+
+func (db *Database) migration20220815090000(verbose bool) error {
+	var queries []string
+
+	if db.Config.DbType == DbTypeSqlite {
+		queries = []string{
+			"create table `rdioScannerAuditLog` (`_id` integer primary key autoincrement, `action` varchar(64) not null, `address` varchar(255) not null, `changes` text, `dateTime` datetime not null, `endpoint` varchar(255) not null, `method` varchar(16) not null, `user` varchar(255) not null)",
+		}
+	} else {
+		queries = []string{
+			"create table `rdioScannerAuditLog` (`_id` integer primary key auto_increment, `action` varchar(64) not null, `address` varchar(255) not null, `changes` longtext, `dateTime` datetime not null, `endpoint` varchar(255) not null, `method` varchar(16) not null, `user` varchar(255) not null)",
+		}
+	}
+
+	queries = append(queries, "create index `rdio_scanner_audit_log_date_time` on `rdioScannerAuditLog` (`dateTime`)")
+
+	return db.migrateWithSchema("20220815090000-v6.5.0", queries, verbose)
+}
+
 func (db *Database) prepareMigration() (bool, error) {
 	var (
 		err     error
@@ -50,19 +50,124 @@ type DefaultDownstream struct {
 }
 
 type DefaultOptions struct {
+	accessLog                   string
+	adminSessionExpiry          uint
+	adminTokenExpiry            uint
+	alertPushoverToken          string
+	alertSmtpFrom               string
+	alertSmtpPassword           string
+	alertSmtpServer             string
+	alertSmtpUsername           string
+	alertTelegramToken          string
+	anomalyDetection            bool
+	anomalySilenceDays          uint
+	anomalyVolumeFactor         uint
+	audioBitrate                uint
+	audioChannels               uint
+	audioCodec                  string
+	audioLoudnessTarget         int
+	audioNormalization          bool
+	audioStorage                string
+	audioStorageS3AccessKey     string
+	audioStorageS3Bucket        string
+	audioStorageS3Endpoint      string
+	audioStorageS3PathStyle     bool
+	audioStorageS3Prefix        string
+	audioStorageS3Region        string
+	audioStorageS3SecretKey     string
+	authWebhook                 string
+	authWebhookSecret           string
 	autoPopulate                bool
+	backupDirectory             string
+	backupRetention             uint
+	backupS3Bucket              string
+	backupSchedule              string
+	broadcastDedupWindow        uint
+	checkForUpdates             bool
+	clockSkewCorrection         bool
+	clockSkewThreshold          uint
+	conversationGap             uint
+	conversionWorkers           uint
 	dimmerDelay                 uint
+	directoryLocation           string
+	directoryName               string
+	directoryPublish            bool
+	directoryUrl                string
 	disableAudioConversion      bool
 	disableDuplicateDetection   bool
+	diskWarningPercent          uint
 	duplicateDetectionTimeFrame uint
+	duplicateFingerprintMatch   uint
+	duplicateFingerprintWindow  uint
+	heartbeatTimeout            uint
+	incidentDetection           bool
+	incidentDetectionMinCalls   uint
+	incidentDetectionThreshold  uint
+	incidentDetectionWindow     uint
+	ingestCallbacks             bool
 	keypadBeeps                 string
+	linkedTalkgroups            bool
+	listeningRooms              bool
+	maxCallDuration             uint
+	maxCallSize                 uint
 	maxClients                  uint
+	oidcAdmin                   bool
+	oidcAdminGroups             string
+	oidcAllowedGroups           string
+	oidcClientId                string
+	oidcClientSecret            string
+	oidcGroupsClaim             string
+	oidcIssuer                  string
+	oidcListeners               bool
 	playbackGoesLive            bool
 	pruneDays                   uint
+	publicUrl                   string
+	radioReferenceAppKey        string
+	radioReferenceInterval      uint
+	radioReferencePassword      string
+	radioReferenceUsername      string
+	rateLimitAdmin              uint
+	rateLimitBanDuration        uint
+	rateLimitBanFailures        uint
+	rateLimitBanRejections      uint
+	rateLimitUpload             uint
+	rateLimitWebsocket          uint
+	relatedCallsWindow          uint
+	samlAllowedGroups           string
+	samlAllowedUsers            string
+	samlEntityId                string
+	samlGroupRoles              string
+	samlGroupsAttribute         string
+	samlIdpCertificate          string
+	samlIdpUrl                  string
+	scheduledRestart            bool
+	scheduledRestartHour        uint
 	searchPatchedTalkgroups     bool
+	serverQueue                 bool
+	serverQueueMaxDepth         uint
+	serverQueueSkipPolicy       string
+	serverTiming                bool
 	showListenersCount          bool
+	silenceAlert                uint
+	silenceAlertFrom            uint
+	silenceAlertTo              uint
 	sortTalkgroups              bool
+	ssoRole                     string
 	tagsToggle                  bool
+	telemetry                   bool
+	telemetryUrl                string
+	transcription               string
+	transcriptionApiKey         string
+	transcriptionCommand        string
+	transcriptionLanguage       string
+	transcriptionModel          string
+	transcriptionUrl            string
+	truncateLongCalls           bool
+	trustedProxies              string
+	unitsImportInterval         uint
+	votingWindow                uint
+	watchdogSelfHeal            bool
+	watchdogStallTimeout        uint
 }
 
 var defaults Defaults = Defaults{
@@ -93,19 +198,124 @@ var defaults Defaults = Defaults{
 	},
 	keypadBeeps: "uniden",
 	options: DefaultOptions{
+		accessLog:                   "",
+		adminSessionExpiry:          7,
+		adminTokenExpiry:            60,
+		alertPushoverToken:          "",
+		alertSmtpFrom:               "",
+		alertSmtpPassword:           "",
+		alertSmtpServer:             "",
+		alertSmtpUsername:           "",
+		alertTelegramToken:          "",
+		anomalyDetection:            false,
+		anomalySilenceDays:          7,
+		anomalyVolumeFactor:         5,
+		audioBitrate:                32,
+		audioChannels:               0,
+		audioCodec:                  "aac",
+		audioLoudnessTarget:         -16,
+		audioNormalization:          true,
+		audioStorage:                "database",
+		audioStorageS3AccessKey:     "",
+		audioStorageS3Bucket:        "",
+		audioStorageS3Endpoint:      "",
+		audioStorageS3PathStyle:     true,
+		audioStorageS3Prefix:        "",
+		audioStorageS3Region:        "us-east-1",
+		audioStorageS3SecretKey:     "",
+		authWebhook:                 "",
+		authWebhookSecret:           "",
 		autoPopulate:                true,
+		backupDirectory:             "",
+		backupRetention:             7,
+		backupS3Bucket:              "",
+		backupSchedule:              "",
+		broadcastDedupWindow:        10,
+		checkForUpdates:             false,
+		clockSkewCorrection:         false,
+		clockSkewThreshold:          60,
+		conversationGap:             30,
+		conversionWorkers:           2,
 		dimmerDelay:                 5000,
+		directoryLocation:           "",
+		directoryName:               "",
+		directoryPublish:            false,
+		directoryUrl:                "",
 		disableAudioConversion:      false,
 		disableDuplicateDetection:   false,
+		diskWarningPercent:          10,
 		duplicateDetectionTimeFrame: 500,
+		duplicateFingerprintMatch:   80,
+		duplicateFingerprintWindow:  5000,
+		heartbeatTimeout:            300,
+		incidentDetection:           false,
+		incidentDetectionMinCalls:   10,
+		incidentDetectionThreshold:  3,
+		incidentDetectionWindow:     15,
+		ingestCallbacks:             false,
 		keypadBeeps:                 "uniden",
+		linkedTalkgroups:            false,
+		listeningRooms:              false,
+		maxCallDuration:             0,
+		maxCallSize:                 100,
 		maxClients:                  200,
+		oidcAdmin:                   false,
+		oidcAdminGroups:             "",
+		oidcAllowedGroups:           "",
+		oidcClientId:                "",
+		oidcClientSecret:            "",
+		oidcGroupsClaim:             "groups",
+		oidcIssuer:                  "",
+		oidcListeners:               false,
 		playbackGoesLive:            false,
 		pruneDays:                   7,
+		publicUrl:                   "",
+		radioReferenceAppKey:        "",
+		radioReferenceInterval:      24,
+		radioReferencePassword:      "",
+		radioReferenceUsername:      "",
+		rateLimitAdmin:              300,
+		rateLimitBanDuration:        10,
+		rateLimitBanFailures:        5,
+		rateLimitBanRejections:      100,
+		rateLimitUpload:             600,
+		rateLimitWebsocket:          30,
+		relatedCallsWindow:          60,
+		samlAllowedGroups:           "",
+		samlAllowedUsers:            "",
+		samlEntityId:                "",
+		samlGroupRoles:              "",
+		samlGroupsAttribute:         "groups",
+		samlIdpCertificate:          "",
+		samlIdpUrl:                  "",
+		scheduledRestart:            false,
+		scheduledRestartHour:        4,
 		searchPatchedTalkgroups:     false,
+		serverQueue:                 false,
+		serverQueueMaxDepth:         50,
+		serverQueueSkipPolicy:       "oldest",
+		serverTiming:                false,
 		showListenersCount:          false,
+		silenceAlert:                0,
+		silenceAlertFrom:            0,
+		silenceAlertTo:              24,
 		sortTalkgroups:              false,
+		ssoRole:                     "viewer",
 		tagsToggle:                  false,
+		telemetry:                   false,
+		telemetryUrl:                "",
+		transcription:               "",
+		transcriptionApiKey:         "",
+		transcriptionCommand:        "whisper-cli -nt -np -f {file}",
+		transcriptionLanguage:       "en",
+		transcriptionModel:          "whisper-1",
+		transcriptionUrl:            "",
+		truncateLongCalls:           false,
+		trustedProxies:              "",
+		unitsImportInterval:         24,
+		votingWindow:                0,
+		watchdogSelfHeal:            false,
+		watchdogStallTimeout:        120,
 	},
 	systems: []System{},
 	tags: []string{
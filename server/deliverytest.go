@@ -0,0 +1,199 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const DeliveryTestResponseLimit = 1024
+
+type DeliveryTest struct {
+	Delivery string `json:"delivery"`
+	Duration uint   `json:"duration"`
+	Error    string `json:"error,omitempty"`
+	Payload  string `json:"payload,omitempty"`
+	Response string `json:"response,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Success  bool   `json:"success"`
+	Target   string `json:"target"`
+}
+
+func ReadDeliveryTestResponse(r io.Reader) string {
+	b, err := io.ReadAll(io.LimitReader(r, DeliveryTestResponseLimit))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+func (admin *Admin) AlertTestHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+	if !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	m := map[string]interface{}{}
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	alert := (&Alert{}).FromMap(m)
+
+	if err := alert.Validate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+		return
+	}
+
+	test := admin.Controller.Alerts.Test(alert, admin.getTestCall(alert.HasAccess))
+
+	admin.sendDeliveryTest(w, test, user)
+}
+
+func (admin *Admin) WebhookTestHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+	if !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	m := map[string]interface{}{}
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	webhook := (&Webhook{}).FromMap(m)
+
+	if u, err := url.Parse(webhook.Url); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("invalid webhook url %s\n", webhook.Url)))
+		return
+	}
+
+	if err := webhook.parseTemplate(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(fmt.Sprintf("%s\n", err.Error())))
+		return
+	}
+
+	test := webhook.Test(admin.getTestCall(webhook.HasAccess), admin.Controller.Options)
+
+	admin.sendDeliveryTest(w, test, user)
+}
+
+func (admin *Admin) getTestCall(hasAccess func(call *Call) bool) *Call {
+	var (
+		call      = NewCall()
+		system    *System
+		talkgroup *Talkgroup
+	)
+
+	call.DateTime = time.Now().UTC()
+	call.Source = uint(1234)
+	call.duration = 3000
+	call.transcript = "This is a test message from Rdio Scanner."
+
+loop:
+	for _, s := range admin.Controller.Systems.List {
+		for _, tg := range s.Talkgroups.List {
+			if system == nil {
+				system, talkgroup = s, tg
+			}
+
+			if hasAccess(&Call{System: s.Id, Talkgroup: tg.Id}) {
+				system, talkgroup = s, tg
+				break loop
+			}
+		}
+	}
+
+	if system == nil || talkgroup == nil {
+		call.System = 1
+		call.Talkgroup = 1
+		call.systemLabel = "Test System"
+		call.talkgroupLabel = "TEST"
+		call.talkgroupName = "Test Talkgroup"
+
+		return call
+	}
+
+	call.System = system.Id
+	call.Talkgroup = talkgroup.Id
+	call.systemLabel = system.Label
+	call.talkgroupLabel = talkgroup.Label
+	call.talkgroupName = talkgroup.Name
+
+	if group, ok := admin.Controller.Groups.GetGroup(talkgroup.GroupId); ok {
+		call.talkgroupGroup = group.Label
+	}
+
+	if tag, ok := admin.Controller.Tags.GetTag(talkgroup.TagId); ok {
+		call.talkgroupTag = tag.Label
+	}
+
+	return call
+}
+
+func (admin *Admin) sendDeliveryTest(w http.ResponseWriter, test *DeliveryTest, user *AdminUser) {
+	if test.Success {
+		admin.Controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("test %s delivery to %s by user=\"%s\" success", test.Delivery, test.Target, user.Username))
+	} else {
+		admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("test %s delivery to %s by user=\"%s\" %s", test.Delivery, test.Target, user.Username, test.Error))
+	}
+
+	b, err := json.Marshal(test)
+	if err != nil {
+		w.WriteHeader(http.StatusExpectationFailed)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
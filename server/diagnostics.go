@@ -0,0 +1,243 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const DiagnosticsMaxSeconds = 8
+
+func (admin *Admin) DebugHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	} else if _, ok := admin.ValidateTokenRole(t, AdminRoleAdmin); !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	seconds := func(def int) int {
+		if i, err := strconv.Atoi(r.URL.Query().Get("seconds")); err == nil && i > 0 {
+			if i > DiagnosticsMaxSeconds {
+				return DiagnosticsMaxSeconds
+			}
+			return i
+		}
+		return def
+	}
+
+	name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/admin/debug/pprof"), "/")
+
+	switch name {
+	case "":
+		profiles := []string{"profile", "trace"}
+		for _, p := range pprof.Profiles() {
+			profiles = append(profiles, p.Name())
+		}
+		sort.Strings(profiles)
+
+		if b, err := json.Marshal(map[string]interface{}{"profiles": profiles}); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	case "profile":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="profile"`)
+
+		if err := pprof.StartCPUProfile(w); err != nil {
+			w.Header().Del("Content-Disposition")
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		admin.Controller.Logs.LogEvent(LogLevelWarn, "cpu profiling started")
+
+		select {
+		case <-time.After(time.Duration(seconds(5)) * time.Second):
+		case <-r.Context().Done():
+		}
+
+		pprof.StopCPUProfile()
+
+	case "trace":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="trace"`)
+
+		if err := trace.Start(w); err != nil {
+			w.Header().Del("Content-Disposition")
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+
+		admin.Controller.Logs.LogEvent(LogLevelWarn, "execution tracing started")
+
+		select {
+		case <-time.After(time.Duration(seconds(1)) * time.Second):
+		case <-r.Context().Done():
+		}
+
+		trace.Stop()
+
+	default:
+		p := pprof.Lookup(name)
+		if p == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		debug, _ := strconv.Atoi(r.URL.Query().Get("debug"))
+
+		if name == "heap" && r.URL.Query().Get("gc") != "" {
+			runtime.GC()
+		}
+
+		if debug == 0 {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+
+		p.WriteTo(w, debug)
+	}
+}
+
+func (admin *Admin) RuntimeHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	} else if _, ok := admin.ValidateTokenRole(t, AdminRoleAdmin); !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if b, err := json.Marshal(admin.Controller.GetRuntimeStats()); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.runtimehandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (controller *Controller) GetRuntimeStats() map[string]interface{} {
+	var (
+		clients    int
+		clientsMax int
+		clientsSum int
+		mem        runtime.MemStats
+	)
+
+	runtime.ReadMemStats(&mem)
+
+	controller.Clients.Map.Range(func(k interface{}, _ interface{}) bool {
+		switch c := k.(type) {
+		case *Client:
+			clients++
+			if n := len(c.Send); n > clientsMax {
+				clientsMax = n
+			}
+			clientsSum += len(c.Send)
+		}
+		return true
+	})
+
+	channel := func(l int, c int) map[string]interface{} {
+		return map[string]interface{}{"len": l, "cap": c}
+	}
+
+	m := map[string]interface{}{
+		"channels": map[string]interface{}{
+			"adminBroadcast":   channel(len(controller.Admin.Broadcast), cap(controller.Admin.Broadcast)),
+			"clientsSendMax":   clientsMax,
+			"clientsSendTotal": clientsSum,
+			"conversion":       controller.Conversions.Stats(),
+			"ingest":           channel(len(controller.Ingest), cap(controller.Ingest)),
+			"register":         channel(len(controller.Register), cap(controller.Register)),
+			"unregister":       channel(len(controller.Unregister), cap(controller.Unregister)),
+		},
+		"gc": map[string]interface{}{
+			"count":        mem.NumGC,
+			"cpuFraction":  mem.GCCPUFraction,
+			"forced":       mem.NumForcedGC,
+			"lastPauseNs":  mem.PauseNs[(mem.NumGC+255)%256],
+			"nextHeap":     mem.NextGC,
+			"pauseTotalNs": mem.PauseTotalNs,
+		},
+		"goVersion":  runtime.Version(),
+		"goroutines": runtime.NumGoroutine(),
+		"heap": map[string]interface{}{
+			"alloc":    mem.HeapAlloc,
+			"idle":     mem.HeapIdle,
+			"inuse":    mem.HeapInuse,
+			"objects":  mem.HeapObjects,
+			"released": mem.HeapReleased,
+			"sys":      mem.HeapSys,
+		},
+		"memory": map[string]interface{}{
+			"alloc":      mem.Alloc,
+			"frees":      mem.Frees,
+			"mallocs":    mem.Mallocs,
+			"stackInuse": mem.StackInuse,
+			"sys":        mem.Sys,
+			"totalAlloc": mem.TotalAlloc,
+		},
+		"numCpu":  runtime.NumCPU(),
+		"version": Version,
+		"websockets": map[string]interface{}{
+			"admins":    controller.Admin.GetConnsCount(),
+			"listeners": clients,
+		},
+	}
+
+	if mem.LastGC > 0 {
+		m["gc"].(map[string]interface{})["lastCollected"] = time.Unix(0, int64(mem.LastGC)).UTC().Format(time.RFC3339)
+	}
+
+	if !controller.startedAt.IsZero() {
+		m["startedAt"] = controller.startedAt.UTC().Format(time.RFC3339)
+		m["uptime"] = int64(time.Since(controller.startedAt).Seconds())
+	}
+
+	return m
+}
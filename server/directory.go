@@ -0,0 +1,179 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	DirectoryCacheTtl        = 10 * time.Minute
+	DirectoryPublishInterval = 30 * time.Minute
+	DirectoryTimeout         = 10 * time.Second
+)
+
+type Directory struct {
+	controller *Controller
+	listing    interface{}
+	listedAt   time.Time
+	mutex      sync.Mutex
+	ticker     *time.Ticker
+}
+
+func NewDirectory(controller *Controller) *Directory {
+	return &Directory{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (directory *Directory) GetListing() (interface{}, error) {
+	formatError := func(err error) error {
+		return fmt.Errorf("directory.getlisting: %v", err)
+	}
+
+	directory.mutex.Lock()
+	defer directory.mutex.Unlock()
+
+	if len(directory.controller.Options.DirectoryUrl) == 0 {
+		return nil, formatError(errors.New("no directory url"))
+	}
+
+	if directory.listing != nil && time.Since(directory.listedAt) < DirectoryCacheTtl {
+		return directory.listing, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, directory.controller.Options.DirectoryUrl, nil)
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("rdio-scanner/%s", Version))
+
+	c := http.Client{Timeout: DirectoryTimeout}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return nil, formatError(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, formatError(fmt.Errorf("bad status: %s", res.Status))
+	}
+
+	var listing interface{}
+	if err = json.NewDecoder(res.Body).Decode(&listing); err != nil {
+		return nil, formatError(err)
+	}
+
+	if !directory.controller.Config.LowMemory {
+		directory.listing = listing
+		directory.listedAt = time.Now()
+	}
+
+	return listing, nil
+}
+
+func (directory *Directory) Publish() error {
+	formatError := func(err error) error {
+		return fmt.Errorf("directory.publish: %v", err)
+	}
+
+	options := directory.controller.Options
+
+	if !options.DirectoryPublish || len(options.DirectoryUrl) == 0 {
+		return nil
+	}
+
+	if len(options.PublicUrl) == 0 {
+		return formatError(errors.New("no public url configured"))
+	}
+
+	if directory.controller.Accesses.IsRestricted() {
+		return formatError(errors.New("instance requires an access code, not publishing"))
+	}
+
+	systems := []map[string]interface{}{}
+	for _, system := range directory.controller.Systems.List {
+		systems = append(systems, map[string]interface{}{
+			"id":    system.Id,
+			"label": system.Label,
+		})
+	}
+
+	name := options.DirectoryName
+	if len(name) == 0 {
+		name = "Rdio Scanner"
+	}
+
+	b, err := json.Marshal(map[string]interface{}{
+		"location": options.DirectoryLocation,
+		"name":     name,
+		"systems":  systems,
+		"url":      options.PublicUrl,
+		"version":  Version,
+	})
+	if err != nil {
+		return formatError(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, options.DirectoryUrl, bytes.NewReader(b))
+	if err != nil {
+		return formatError(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("rdio-scanner/%s", Version))
+
+	c := http.Client{Timeout: DirectoryTimeout}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return formatError(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return formatError(fmt.Errorf("bad status: %s", res.Status))
+	}
+
+	return nil
+}
+
+func (directory *Directory) Start() {
+	directory.ticker = time.NewTicker(DirectoryPublishInterval)
+
+	publish := func() {
+		if err := directory.Publish(); err != nil {
+			directory.controller.Logs.LogEvent(LogLevelWarn, err.Error())
+		}
+	}
+
+	go func() {
+		publish()
+		for range directory.ticker.C {
+			publish()
+		}
+	}()
+}
@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"io/ioutil"
 	"math"
@@ -32,6 +33,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -44,22 +46,29 @@ const (
 )
 
 type Dirwatch struct {
-	Id          interface{} `json:"_id"`
-	Delay       interface{} `json:"delay"`
-	DeleteAfter bool        `json:"deleteAfter"`
-	Directory   string      `json:"directory"`
-	Disabled    bool        `json:"disabled"`
-	Extension   interface{} `json:"extension"`
-	Frequency   interface{} `json:"frequency"`
-	Mask        interface{} `json:"mask"`
-	Order       interface{} `json:"order"`
-	SystemId    interface{} `json:"systemId"`
-	TalkgroupId interface{} `json:"talkgroupId"`
-	Kind        interface{} `json:"type"`
-	UsePolling  bool        `json:"usePolling"`
-	controller  *Controller
-	dirs        map[string]bool
-	watcher     *fsnotify.Watcher
+	Id           interface{} `json:"_id"`
+	ArchiveDir   string      `json:"archiveDir"`
+	Delay        interface{} `json:"delay"`
+	DeleteAfter  bool        `json:"deleteAfter"`
+	Directory    string      `json:"directory"`
+	Disabled     bool        `json:"disabled"`
+	Extension    interface{} `json:"extension"`
+	Frequency    interface{} `json:"frequency"`
+	Mask         interface{} `json:"mask"`
+	Order        interface{} `json:"order"`
+	Password     string      `json:"password"`
+	PollInterval interface{} `json:"pollInterval"`
+	SystemId     interface{} `json:"systemId"`
+	TalkgroupId  interface{} `json:"talkgroupId"`
+	Kind         interface{} `json:"type"`
+	UsePolling   bool        `json:"usePolling"`
+	Username     string      `json:"username"`
+	controller   *Controller
+	dirs         map[string]bool
+	fingerprint  string
+	heartbeat    int64
+	stop         chan struct{}
+	watcher      *fsnotify.Watcher
 }
 
 func (dirwatch *Dirwatch) FromMap(m map[string]interface{}) *Dirwatch {
@@ -68,6 +77,11 @@ func (dirwatch *Dirwatch) FromMap(m map[string]interface{}) *Dirwatch {
 		dirwatch.Id = uint(v)
 	}
 
+	switch v := m["archiveDir"].(type) {
+	case string:
+		dirwatch.ArchiveDir = strings.TrimSpace(v)
+	}
+
 	switch v := m["delay"].(type) {
 	case float64:
 		dirwatch.Delay = uint(v)
@@ -108,6 +122,16 @@ func (dirwatch *Dirwatch) FromMap(m map[string]interface{}) *Dirwatch {
 		dirwatch.Order = uint(v)
 	}
 
+	switch v := m["password"].(type) {
+	case string:
+		dirwatch.Password = v
+	}
+
+	switch v := m["pollInterval"].(type) {
+	case float64:
+		dirwatch.PollInterval = uint(v)
+	}
+
 	switch v := m["systemId"].(type) {
 	case float64:
 		dirwatch.SystemId = uint(v)
@@ -128,23 +152,28 @@ func (dirwatch *Dirwatch) FromMap(m map[string]interface{}) *Dirwatch {
 		dirwatch.UsePolling = v
 	}
 
+	switch v := m["username"].(type) {
+	case string:
+		dirwatch.Username = strings.TrimSpace(v)
+	}
+
 	return dirwatch
 }
 
 func (dirwatch *Dirwatch) Ingest(p string) {
-	var err error
+	if err := dirwatch.ingest(p); err != nil {
+		dirwatch.controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("dirwatch.ingest: %v", err.Error()))
+	}
+}
 
+func (dirwatch *Dirwatch) ingest(p string) error {
 	switch dirwatch.Kind {
 	case DirwatchKindTrunkRecorder:
-		err = dirwatch.ingestTrunkRecorder(p)
+		return dirwatch.ingestTrunkRecorder(p)
 	case DirwatchKindSdrTrunk:
-		err = dirwatch.ingestSdrTrunk(p)
+		return dirwatch.ingestSdrTrunk(p)
 	default:
-		err = dirwatch.ingestDefault(p)
-	}
-
-	if err != nil {
-		dirwatch.controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("dirwatch.ingest: %v", err.Error()))
+		return dirwatch.ingestDefault(p)
 	}
 }
 
@@ -168,10 +197,6 @@ func (dirwatch *Dirwatch) ingestDefault(p string) error {
 		call.AudioType = mime.TypeByExtension(path.Ext(p))
 		call.Frequency = dirwatch.Frequency
 
-		if call.Audio, err = os.ReadFile(p); err != nil {
-			return err
-		}
-
 		dirwatch.parseMask(call)
 
 		switch v := dirwatch.SystemId.(type) {
@@ -184,16 +209,25 @@ func (dirwatch *Dirwatch) ingestDefault(p string) error {
 			call.Talkgroup = v
 		}
 
+		if err = dirwatch.readAudio(call, p); err != nil {
+			return err
+		}
+
 		if ok, err := call.IsValid(); ok {
+			passthrough := len(call.audioFile) > 0
+
+			call.uploader = fmt.Sprintf("dirwatch %s", dirwatch.Directory)
+
 			dirwatch.controller.Ingest <- call
 
-			if dirwatch.DeleteAfter {
-				if err = os.Remove(p); err != nil {
+			if dirwatch.DeleteAfter && !passthrough {
+				if err = dirwatch.removeFile(p); err != nil {
 					return err
 				}
 			}
 
 		} else {
+			call.audioFileOwned = false
 			return err
 		}
 	}
@@ -237,10 +271,12 @@ func (dirwatch *Dirwatch) ingestSdrTrunk(p string) error {
 	}
 
 	if ok, err := call.IsValid(); ok {
+		call.uploader = fmt.Sprintf("dirwatch %s", dirwatch.Directory)
+
 		dirwatch.controller.Ingest <- call
 
 		if dirwatch.DeleteAfter {
-			if err = os.Remove(p); err != nil {
+			if err = dirwatch.removeFile(p); err != nil {
 				return err
 			}
 		}
@@ -289,7 +325,7 @@ func (dirwatch *Dirwatch) ingestTrunkRecorder(p string) error {
 		call.System = v
 	}
 
-	if call.Audio, err = ioutil.ReadFile(audioName); err != nil {
+	if err = dirwatch.readAudio(call, audioName); err != nil {
 		return nil
 	}
 
@@ -301,25 +337,49 @@ func (dirwatch *Dirwatch) ingestTrunkRecorder(p string) error {
 		return err
 	}
 
+	passthrough := len(call.audioFile) > 0
+
 	if ok, err := call.IsValid(); ok {
+		call.uploader = fmt.Sprintf("dirwatch %s", dirwatch.Directory)
+
 		dirwatch.controller.Ingest <- call
 
 	} else {
+		call.audioFileOwned = false
 		return err
 	}
 
 	if dirwatch.DeleteAfter {
-		if err = os.Remove(p); err != nil {
+		if err = dirwatch.removeFile(p); err != nil {
 			return err
 		}
-		if err = os.Remove(audioName); err != nil {
-			return err
+		if !passthrough {
+			if err = dirwatch.removeFile(audioName); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+func (dirwatch *Dirwatch) readAudio(call *Call, p string) error {
+	if err := call.SetAudioFile(p, dirwatch.DeleteAfter, dirwatch.controller.Options.GetMaxCallSize()); err != nil {
+		return err
+	}
+
+	call.audioFileArchive = dirwatch.ArchiveDir
+
+	return nil
+}
+
+func (dirwatch *Dirwatch) removeFile(p string) error {
+	if len(dirwatch.ArchiveDir) > 0 {
+		return ArchiveFile(p, dirwatch.ArchiveDir)
+	}
+	return os.Remove(p)
+}
+
 func (dirwatch *Dirwatch) parseMask(call *Call) {
 	var meta = [][]string{
 		{"date", "#DATE", `[\d-_]+`},
@@ -442,7 +502,9 @@ func (dirwatch *Dirwatch) parseMask(call *Call) {
 	default:
 		switch v := metaval["syslbl"].(type) {
 		case string:
-			if system, ok := dirwatch.controller.Systems.GetSystem(v); ok {
+			if dirwatch.controller == nil {
+				call.systemLabel = v
+			} else if system, ok := dirwatch.controller.Systems.GetSystem(v); ok {
 				call.System = system.Id
 			} else {
 				call.System = dirwatch.controller.Systems.GetNewSystemId()
@@ -530,10 +592,14 @@ func (dirwatch *Dirwatch) Start(controller *Controller) error {
 		return nil
 	}
 
-	if dirwatch.watcher != nil {
+	if dirwatch.watcher != nil || dirwatch.stop != nil {
 		return errors.New("dirwatch.start: already started")
 	}
 
+	if IsDirwatchRemote(dirwatch.Directory) {
+		return dirwatch.startRemote(controller)
+	}
+
 	dirwatch.controller = controller
 	dirwatch.dirs = map[string]bool{}
 
@@ -548,10 +614,14 @@ func (dirwatch *Dirwatch) Start(controller *Controller) error {
 		delay = time.Duration(2000) * time.Millisecond
 	}
 
-	go func() {
+	atomic.StoreInt64(&dirwatch.heartbeat, time.Now().UnixNano())
+
+	go func(watcher *fsnotify.Watcher) {
 		// var timers = map[string]*time.Timer{}
 		var timers = sync.Map{}
 
+		ticker := time.NewTicker(WatchdogHeartbeat)
+
 		logError := func(err error) {
 			controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("dirwatch.watcher: %v", err.Error()))
 		}
@@ -567,6 +637,8 @@ func (dirwatch *Dirwatch) Start(controller *Controller) error {
 		}
 
 		defer func() {
+			ticker.Stop()
+
 			timers.Range(func(k interface{}, t interface{}) bool {
 				switch v := t.(type) {
 				case *time.Timer:
@@ -585,12 +657,15 @@ func (dirwatch *Dirwatch) Start(controller *Controller) error {
 		}()
 
 		for {
-			if dirwatch.watcher == nil {
+			if dirwatch.watcher != watcher {
 				break
 			}
 
 			select {
-			case event, ok := <-dirwatch.watcher.Events:
+			case <-ticker.C:
+				atomic.StoreInt64(&dirwatch.heartbeat, time.Now().UnixNano())
+
+			case event, ok := <-watcher.Events:
 				if ok {
 					switch event.Op {
 					case fsnotify.Create:
@@ -611,7 +686,7 @@ func (dirwatch *Dirwatch) Start(controller *Controller) error {
 
 					case fsnotify.Remove:
 						if dirwatch.dirs[event.Name] {
-							if err := dirwatch.watcher.Remove(event.Name); err == nil {
+							if err := watcher.Remove(event.Name); err == nil {
 								delete(dirwatch.dirs, event.Name)
 							} else {
 								logError(err)
@@ -629,7 +704,7 @@ func (dirwatch *Dirwatch) Start(controller *Controller) error {
 					}
 				}
 
-			case err, ok := <-dirwatch.watcher.Errors:
+			case err, ok := <-watcher.Errors:
 				if ok {
 					logError(err)
 
@@ -637,7 +712,7 @@ func (dirwatch *Dirwatch) Start(controller *Controller) error {
 				}
 			}
 		}
-	}()
+	}(dirwatch.watcher)
 
 	go func() {
 		defer func() {
@@ -670,6 +745,11 @@ func (dirwatch *Dirwatch) Start(controller *Controller) error {
 }
 
 func (dirwatch *Dirwatch) Stop() {
+	if dirwatch.stop != nil {
+		close(dirwatch.stop)
+		dirwatch.stop = nil
+	}
+
 	if dirwatch.watcher != nil {
 		dirwatch.watcher.Close()
 		dirwatch.watcher = nil
@@ -710,17 +790,21 @@ func (dirwatches *Dirwatches) FromMap(f []interface{}) *Dirwatches {
 
 func (dirwatches *Dirwatches) Read(db *Database) error {
 	var (
-		delay       sql.NullFloat64
-		err         error
-		extension   sql.NullString
-		id          sql.NullFloat64
-		frequency   sql.NullFloat64
-		kind        sql.NullString
-		mask        sql.NullString
-		order       sql.NullFloat64
-		rows        *sql.Rows
-		systemId    sql.NullFloat64
-		talkgroupId sql.NullFloat64
+		archiveDir   sql.NullString
+		delay        sql.NullFloat64
+		err          error
+		extension    sql.NullString
+		id           sql.NullFloat64
+		frequency    sql.NullFloat64
+		kind         sql.NullString
+		mask         sql.NullString
+		order        sql.NullFloat64
+		password     sql.NullString
+		pollInterval sql.NullFloat64
+		rows         *sql.Rows
+		systemId     sql.NullFloat64
+		talkgroupId  sql.NullFloat64
+		username     sql.NullString
 	)
 
 	dirwatches.mutex.Lock()
@@ -734,14 +818,14 @@ func (dirwatches *Dirwatches) Read(db *Database) error {
 		return fmt.Errorf("dirwatches.read: %v", err)
 	}
 
-	if rows, err = db.Sql.Query("select `_id`, `delay`, `deleteAfter`, `directory`, `disabled`, `extension`, `frequency`, `mask`, `order`, `systemId`, `talkgroupId`, `type`, `usePolling` from `rdioScannerDirWatches`"); err != nil {
+	if rows, err = db.Sql.Query("select `_id`, `archiveDir`, `delay`, `deleteAfter`, `directory`, `disabled`, `extension`, `frequency`, `mask`, `order`, `systemId`, `talkgroupId`, `type`, `usePolling`, `password`, `pollInterval`, `username` from `rdioScannerDirWatches`"); err != nil {
 		return formatError(err)
 	}
 
 	for rows.Next() {
 		dirwatch := &Dirwatch{}
 
-		if err = rows.Scan(&id, &delay, &dirwatch.DeleteAfter, &dirwatch.Directory, &dirwatch.Disabled, &extension, &frequency, &mask, &order, &systemId, &talkgroupId, &kind, &dirwatch.UsePolling); err != nil {
+		if err = rows.Scan(&id, &archiveDir, &delay, &dirwatch.DeleteAfter, &dirwatch.Directory, &dirwatch.Disabled, &extension, &frequency, &mask, &order, &systemId, &talkgroupId, &kind, &dirwatch.UsePolling, &password, &pollInterval, &username); err != nil {
 			break
 		}
 
@@ -749,6 +833,10 @@ func (dirwatches *Dirwatches) Read(db *Database) error {
 			dirwatch.Id = uint(id.Float64)
 		}
 
+		if archiveDir.Valid {
+			dirwatch.ArchiveDir = archiveDir.String
+		}
+
 		if delay.Valid && id.Float64 > 0 {
 			dirwatch.Delay = uint(delay.Float64)
 		}
@@ -781,6 +869,18 @@ func (dirwatches *Dirwatches) Read(db *Database) error {
 			dirwatch.Kind = kind.String
 		}
 
+		if password.Valid {
+			dirwatch.Password = password.String
+		}
+
+		if pollInterval.Valid && pollInterval.Float64 > 0 {
+			dirwatch.PollInterval = uint(pollInterval.Float64)
+		}
+
+		if username.Valid {
+			dirwatch.Username = username.String
+		}
+
 		dirwatches.List = append(dirwatches.List, dirwatch)
 	}
 
@@ -829,11 +929,11 @@ func (dirwatches *Dirwatches) Write(db *Database) error {
 		}
 
 		if count == 0 {
-			if _, err = db.Sql.Exec("insert into `rdioScannerDirWatches` (`_id`, `delay`, `deleteAfter`, `directory`, `disabled`, `extension`, `frequency`, `mask`, `order`, `systemId`, `talkgroupId`, `type`, `usePolling`) values (?, ?, ?, ?, ?, ?, ?, ?, ? ,? ,? ,? ,?)", dirwatch.Id, dirwatch.Delay, dirwatch.DeleteAfter, dirwatch.Directory, dirwatch.Disabled, dirwatch.Extension, dirwatch.Frequency, dirwatch.Mask, dirwatch.Order, dirwatch.SystemId, dirwatch.TalkgroupId, dirwatch.Kind, dirwatch.UsePolling); err != nil {
+			if _, err = db.Sql.Exec("insert into `rdioScannerDirWatches` (`_id`, `archiveDir`, `delay`, `deleteAfter`, `directory`, `disabled`, `extension`, `frequency`, `mask`, `order`, `systemId`, `talkgroupId`, `type`, `usePolling`, `password`, `pollInterval`, `username`) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ? ,? ,? ,? ,?, ?, ?, ?)", dirwatch.Id, dirwatch.ArchiveDir, dirwatch.Delay, dirwatch.DeleteAfter, dirwatch.Directory, dirwatch.Disabled, dirwatch.Extension, dirwatch.Frequency, dirwatch.Mask, dirwatch.Order, dirwatch.SystemId, dirwatch.TalkgroupId, dirwatch.Kind, dirwatch.UsePolling, dirwatch.Password, dirwatch.PollInterval, dirwatch.Username); err != nil {
 				break
 			}
 
-		} else if _, err = db.Sql.Exec("update `rdioScannerDirWatches` set `_id` = ?, `delay` = ?, `deleteAfter` = ?, `directory` = ?, `disabled` = ?, `extension` = ?, `frequency` = ?, `mask` = ?, `order` = ?, `systemId` = ?, `talkgroupId` = ?, `type` = ?, `usePolling` = ? where `_id` = ?", dirwatch.Id, dirwatch.Delay, dirwatch.DeleteAfter, dirwatch.Directory, dirwatch.Disabled, dirwatch.Extension, dirwatch.Frequency, dirwatch.Mask, dirwatch.Order, dirwatch.SystemId, dirwatch.TalkgroupId, dirwatch.Kind, dirwatch.UsePolling, dirwatch.Id); err != nil {
+		} else if _, err = db.Sql.Exec("update `rdioScannerDirWatches` set `_id` = ?, `archiveDir` = ?, `delay` = ?, `deleteAfter` = ?, `directory` = ?, `disabled` = ?, `extension` = ?, `frequency` = ?, `mask` = ?, `order` = ?, `systemId` = ?, `talkgroupId` = ?, `type` = ?, `usePolling` = ?, `password` = ?, `pollInterval` = ?, `username` = ? where `_id` = ?", dirwatch.Id, dirwatch.ArchiveDir, dirwatch.Delay, dirwatch.DeleteAfter, dirwatch.Directory, dirwatch.Disabled, dirwatch.Extension, dirwatch.Frequency, dirwatch.Mask, dirwatch.Order, dirwatch.SystemId, dirwatch.TalkgroupId, dirwatch.Kind, dirwatch.UsePolling, dirwatch.Password, dirwatch.PollInterval, dirwatch.Username, dirwatch.Id); err != nil {
 			break
 		}
 	}
@@ -908,3 +1008,55 @@ func (dirwatch *Dirwatch) walkDir(d string) error {
 		return err
 	})
 }
+
+func ArchiveFile(p string, archiveDir string) error {
+	formatError := func(err error) error {
+		return fmt.Errorf("archivefile: %v", err)
+	}
+
+	dir := filepath.Join(archiveDir, time.Now().Format("2006/01/02"))
+
+	if err := os.MkdirAll(dir, 0770); err != nil {
+		return formatError(err)
+	}
+
+	ext := filepath.Ext(p)
+	base := strings.TrimSuffix(filepath.Base(p), ext)
+
+	dst := filepath.Join(dir, base+ext)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(dst); os.IsNotExist(err) {
+			break
+		}
+		dst = filepath.Join(dir, fmt.Sprintf("%s-%d%s", base, i, ext))
+	}
+
+	if err := os.Rename(p, dst); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(p)
+	if err != nil {
+		return formatError(err)
+	}
+
+	f, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0660)
+	if err != nil {
+		src.Close()
+		return formatError(err)
+	}
+
+	_, err = io.Copy(f, src)
+	src.Close()
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		os.Remove(dst)
+		return formatError(err)
+	}
+
+	return os.Remove(p)
+}
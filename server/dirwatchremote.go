@@ -0,0 +1,1171 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	DirwatchRemoteChunkSize    = 32 * 1024
+	DirwatchRemotePollInterval = 30
+	DirwatchRemoteTimeout      = time.Minute
+)
+
+const (
+	sftpPacketInit    = 1
+	sftpPacketVersion = 2
+	sftpPacketOpen    = 3
+	sftpPacketClose   = 4
+	sftpPacketRead    = 5
+	sftpPacketOpendir = 11
+	sftpPacketReaddir = 12
+	sftpPacketRemove  = 13
+	sftpPacketStatus  = 101
+	sftpPacketHandle  = 102
+	sftpPacketData    = 103
+	sftpPacketName    = 104
+	sftpStatusOk      = 0
+	sftpStatusEof     = 1
+)
+
+type DirwatchRemote interface {
+	Close() error
+	List(dir string) ([]DirwatchRemoteFile, error)
+	Read(p string, w io.Writer) error
+	Remove(p string) error
+}
+
+type DirwatchRemoteFile struct {
+	ModTime time.Time
+	Path    string
+	Size    int64
+}
+
+func IsDirwatchRemote(directory string) bool {
+	u, err := url.Parse(directory)
+	if err != nil {
+		return false
+	}
+
+	switch u.Scheme {
+	case "ftp", "ftps", "s3", "sftp":
+		return len(u.Host) > 0
+	}
+
+	return false
+}
+
+func NewDirwatchRemote(dirwatch *Dirwatch) (DirwatchRemote, string, error) {
+	u, err := url.Parse(dirwatch.Directory)
+	if err != nil {
+		return nil, "", err
+	}
+
+	username := dirwatch.Username
+	password := dirwatch.Password
+
+	if u.User != nil {
+		if len(username) == 0 {
+			username = u.User.Username()
+		}
+		if p, ok := u.User.Password(); ok && len(password) == 0 {
+			password = p
+		}
+	}
+
+	if u.Scheme == "s3" {
+		return newDirwatchS3(dirwatch, u, username, password), strings.TrimPrefix(u.Path, "/"), nil
+	}
+
+	dir := u.Path
+	if len(dir) == 0 {
+		dir = "."
+	}
+
+	switch u.Scheme {
+	case "sftp":
+		sftp, err := dialDirwatchSftp(u, username, password)
+		if err != nil {
+			return nil, "", err
+		}
+		if fingerprint := u.Query().Get("fingerprint"); len(fingerprint) == 0 && sftp.fingerprint != dirwatch.fingerprint {
+			dirwatch.fingerprint = sftp.fingerprint
+			dirwatch.controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("dirwatch %s: host key %s not verified, add ?fingerprint=%s to the directory to verify it", u.Redacted(), sftp.fingerprint, sftp.fingerprint))
+		}
+		return sftp, dir, nil
+
+	default:
+		ftp, err := dialDirwatchFtp(u, username, password)
+		if err != nil {
+			return nil, "", err
+		}
+		return ftp, dir, nil
+	}
+}
+
+func (dirwatch *Dirwatch) startRemote(controller *Controller) error {
+	var (
+		immediate bool
+		interval  = time.Duration(DirwatchRemotePollInterval) * time.Second
+		pending   = map[string]string{}
+		seen      = map[string]string{}
+	)
+
+	dirwatch.controller = controller
+
+	switch v := dirwatch.PollInterval.(type) {
+	case uint:
+		if v > 0 {
+			interval = time.Duration(v) * time.Second
+		}
+	}
+
+	if interval < 5*time.Second {
+		interval = 5 * time.Second
+	}
+
+	if u, err := url.Parse(dirwatch.Directory); err == nil && u.Scheme == "s3" {
+		immediate = true
+	}
+
+	sqs, err := newDirwatchSqs(dirwatch)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.MkdirTemp("", "rdio-scanner-dirwatch-*")
+	if err != nil {
+		return err
+	}
+
+	local := *dirwatch
+	local.DeleteAfter = true
+
+	if !dirwatch.DeleteAfter {
+		local.ArchiveDir = ""
+	}
+
+	logError := func(err error) {
+		controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("dirwatch.remote: %s %v", dirwatch.getRedactedDirectory(), err))
+	}
+
+	heartbeat := func() {
+		atomic.StoreInt64(&dirwatch.heartbeat, time.Now().UnixNano())
+	}
+
+	download := func(remote DirwatchRemote, file DirwatchRemoteFile, dir string) (string, error) {
+		rel := strings.TrimPrefix(file.Path, strings.TrimSuffix(dir, "/"))
+
+		p := filepath.Join(tmp, filepath.FromSlash(path.Clean("/"+rel)))
+
+		if err := os.MkdirAll(filepath.Dir(p), 0770); err != nil {
+			return "", err
+		}
+
+		f, err := os.Create(p)
+		if err != nil {
+			return "", err
+		}
+
+		err = remote.Read(file.Path, f)
+
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+
+		if err != nil {
+			os.Remove(p)
+			return "", err
+		}
+
+		return p, nil
+	}
+
+	first := true
+
+	poll := func() {
+		heartbeat()
+
+		remote, dir, err := NewDirwatchRemote(dirwatch)
+		if err != nil {
+			logError(err)
+			return
+		}
+		defer remote.Close()
+
+		files, err := remote.List(dir)
+		if err != nil {
+			logError(err)
+			return
+		}
+
+		current := map[string]bool{}
+		for _, file := range files {
+			current[file.Path] = true
+		}
+
+		for p := range seen {
+			if !current[p] {
+				delete(seen, p)
+			}
+		}
+
+		for p := range pending {
+			if !current[p] {
+				delete(pending, p)
+			}
+		}
+
+		if first {
+			first = false
+
+			if !dirwatch.DeleteAfter {
+				for _, file := range files {
+					seen[file.Path] = file.getSignature()
+				}
+				return
+			}
+		}
+
+		stable := map[string]DirwatchRemoteFile{}
+
+		for _, file := range files {
+			signature := file.getSignature()
+
+			if seen[file.Path] == signature {
+				continue
+			}
+
+			if !immediate && pending[file.Path] != signature {
+				pending[file.Path] = signature
+				continue
+			}
+
+			stable[file.Path] = file
+		}
+
+		for _, group := range dirwatch.getRemoteGroups(stable, seen) {
+			var locals []string
+
+			heartbeat()
+
+			for _, file := range group {
+				p, err := download(remote, file, dir)
+				if err != nil {
+					break
+				}
+				locals = append(locals, p)
+			}
+
+			if len(locals) < len(group) {
+				for _, p := range locals {
+					os.Remove(p)
+				}
+				logError(fmt.Errorf("unable to download %s", group[0].Path))
+				continue
+			}
+
+			for _, file := range group {
+				delete(pending, file.Path)
+				seen[file.Path] = file.getSignature()
+			}
+
+			if err := local.ingest(locals[0]); err != nil {
+				for _, p := range locals {
+					os.Remove(p)
+				}
+				logError(err)
+				continue
+			}
+
+			if dirwatch.DeleteAfter {
+				for _, file := range group {
+					if err := remote.Remove(file.Path); err != nil {
+						logError(err)
+					}
+				}
+			}
+		}
+	}
+
+	stop := make(chan struct{})
+	wake := make(chan struct{}, 1)
+
+	dirwatch.stop = stop
+
+	heartbeat()
+
+	if sqs != nil {
+		go func() {
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				count, err := sqs.Receive()
+				if err != nil {
+					logError(err)
+
+					select {
+					case <-stop:
+						return
+					case <-time.After(interval):
+					}
+				}
+
+				if count > 0 {
+					select {
+					case wake <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}()
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		watchdog := time.NewTicker(WatchdogHeartbeat)
+
+		defer func() {
+			ticker.Stop()
+			watchdog.Stop()
+
+			os.Remove(tmp)
+
+			switch v := recover().(type) {
+			case error:
+				controller.Logs.LogEvent(LogLevelError, v.Error())
+			}
+		}()
+
+		poll()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-watchdog.C:
+				heartbeat()
+			case <-ticker.C:
+				poll()
+			case <-wake:
+				poll()
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (dirwatch *Dirwatch) getRedactedDirectory() string {
+	if u, err := url.Parse(dirwatch.Directory); err == nil {
+		return u.Redacted()
+	}
+	return dirwatch.Directory
+}
+
+func (dirwatch *Dirwatch) getRemoteExtension() string {
+	switch v := dirwatch.Extension.(type) {
+	case string:
+		if len(v) > 0 {
+			return fmt.Sprintf(".%s", v)
+		}
+	}
+
+	if dirwatch.Kind == DirwatchKindSdrTrunk {
+		return ".mp3"
+	}
+
+	return ".wav"
+}
+
+func (dirwatch *Dirwatch) getRemoteGroups(stable map[string]DirwatchRemoteFile, seen map[string]string) [][]DirwatchRemoteFile {
+	groups := [][]DirwatchRemoteFile{}
+
+	ext := dirwatch.getRemoteExtension()
+
+	paths := []string{}
+	for p := range stable {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		file := stable[p]
+
+		switch dirwatch.Kind {
+		case DirwatchKindTrunkRecorder:
+			if strings.EqualFold(path.Ext(p), ".json") {
+				if audio, ok := stable[strings.TrimSuffix(p, path.Ext(p))+ext]; ok {
+					groups = append(groups, []DirwatchRemoteFile{file, audio})
+				}
+			} else if !strings.EqualFold(path.Ext(p), ext) {
+				seen[p] = file.getSignature()
+			}
+
+		default:
+			if strings.EqualFold(path.Ext(p), ext) {
+				groups = append(groups, []DirwatchRemoteFile{file})
+			} else {
+				seen[p] = file.getSignature()
+			}
+		}
+	}
+
+	return groups
+}
+
+func (file DirwatchRemoteFile) getSignature() string {
+	return fmt.Sprintf("%d:%d", file.Size, file.ModTime.Unix())
+}
+
+type dirwatchFtp struct {
+	conn   net.Conn
+	host   string
+	reader *bufio.Reader
+	tls    *tls.Config
+}
+
+func dialDirwatchFtp(u *url.URL, username string, password string) (*dirwatchFtp, error) {
+	formatError := func(err error) error {
+		return fmt.Errorf("ftp: %v", err)
+	}
+
+	host := u.Host
+	if len(u.Port()) == 0 {
+		host = net.JoinHostPort(u.Hostname(), "21")
+	}
+
+	conn, err := net.DialTimeout("tcp", host, DirwatchRemoteTimeout)
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	ftp := &dirwatchFtp{
+		conn:   conn,
+		host:   u.Hostname(),
+		reader: bufio.NewReader(conn),
+	}
+
+	fail := func(err error) (*dirwatchFtp, error) {
+		conn.Close()
+		return nil, formatError(err)
+	}
+
+	if _, err = ftp.expect(2, ""); err != nil {
+		return fail(err)
+	}
+
+	if u.Scheme == "ftps" {
+		if _, err = ftp.expect(2, "AUTH TLS"); err != nil {
+			return fail(err)
+		}
+
+		ftp.tls = &tls.Config{
+			ClientSessionCache: tls.NewLRUClientSessionCache(0),
+			ServerName:         u.Hostname(),
+		}
+
+		tlsConn := tls.Client(conn, ftp.tls)
+		if err = tlsConn.Handshake(); err != nil {
+			return fail(err)
+		}
+
+		ftp.conn = tlsConn
+		ftp.reader = bufio.NewReader(tlsConn)
+
+		if _, err = ftp.expect(2, "PBSZ 0"); err != nil {
+			return fail(err)
+		}
+
+		if _, err = ftp.expect(2, "PROT P"); err != nil {
+			return fail(err)
+		}
+	}
+
+	if len(username) == 0 {
+		username, password = "anonymous", "anonymous"
+	}
+
+	code, message, err := ftp.cmd(fmt.Sprintf("USER %s", username))
+	if err != nil {
+		return fail(err)
+	}
+
+	if code == 331 {
+		if _, err = ftp.expect(2, fmt.Sprintf("PASS %s", password)); err != nil {
+			return fail(err)
+		}
+	} else if code/100 != 2 {
+		return fail(errors.New(message))
+	}
+
+	if _, err = ftp.expect(2, "TYPE I"); err != nil {
+		return fail(err)
+	}
+
+	return ftp, nil
+}
+
+func (ftp *dirwatchFtp) Close() error {
+	ftp.cmd("QUIT")
+	return ftp.conn.Close()
+}
+
+func (ftp *dirwatchFtp) List(dir string) ([]DirwatchRemoteFile, error) {
+	var b bytes.Buffer
+
+	if err := ftp.transfer(fmt.Sprintf("MLSD %s", dir), &b); err != nil {
+		return ftp.listNames(dir)
+	}
+
+	files := []DirwatchRemoteFile{}
+
+	scanner := bufio.NewScanner(&b)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		i := strings.Index(line, " ")
+		if i < 0 {
+			continue
+		}
+
+		name := line[i+1:]
+		file := DirwatchRemoteFile{Path: path.Join(dir, name)}
+		kind := ""
+
+		for _, fact := range strings.Split(line[:i], ";") {
+			kv := strings.SplitN(fact, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+
+			switch strings.ToLower(kv[0]) {
+			case "modify":
+				if len(kv[1]) >= 14 {
+					file.ModTime, _ = time.Parse("20060102150405", kv[1][:14])
+				}
+			case "size":
+				file.Size, _ = strconv.ParseInt(kv[1], 10, 64)
+			case "type":
+				kind = strings.ToLower(kv[1])
+			}
+		}
+
+		switch kind {
+		case "dir":
+			sub, err := ftp.List(file.Path)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, sub...)
+		case "file":
+			files = append(files, file)
+		}
+	}
+
+	return files, nil
+}
+
+func (ftp *dirwatchFtp) Read(p string, w io.Writer) error {
+	return ftp.transfer(fmt.Sprintf("RETR %s", p), w)
+}
+
+func (ftp *dirwatchFtp) Remove(p string) error {
+	_, err := ftp.expect(2, fmt.Sprintf("DELE %s", p))
+	return err
+}
+
+func (ftp *dirwatchFtp) cmd(command string) (int, string, error) {
+	ftp.conn.SetDeadline(time.Now().Add(DirwatchRemoteTimeout))
+
+	if len(command) > 0 {
+		if _, err := fmt.Fprintf(ftp.conn, "%s\r\n", command); err != nil {
+			return 0, "", err
+		}
+	}
+
+	line, err := ftp.reader.ReadString('\n')
+	if err != nil {
+		return 0, "", err
+	}
+
+	if len(line) < 4 {
+		return 0, "", fmt.Errorf("invalid response %q", line)
+	}
+
+	code, err := strconv.Atoi(line[:3])
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid response %q", line)
+	}
+
+	message := strings.TrimSpace(line[4:])
+
+	if line[3] == '-' {
+		for {
+			line, err = ftp.reader.ReadString('\n')
+			if err != nil {
+				return 0, "", err
+			}
+			if strings.HasPrefix(line, fmt.Sprintf("%d ", code)) {
+				break
+			}
+		}
+	}
+
+	return code, message, nil
+}
+
+func (ftp *dirwatchFtp) expect(class int, command string) (string, error) {
+	code, message, err := ftp.cmd(command)
+	if err != nil {
+		return "", err
+	}
+
+	if code/100 != class {
+		return "", fmt.Errorf("%d %s", code, message)
+	}
+
+	return message, nil
+}
+
+func (ftp *dirwatchFtp) listNames(dir string) ([]DirwatchRemoteFile, error) {
+	var b bytes.Buffer
+
+	if err := ftp.transfer(fmt.Sprintf("NLST %s", dir), &b); err != nil {
+		return nil, err
+	}
+
+	files := []DirwatchRemoteFile{}
+
+	scanner := bufio.NewScanner(&b)
+
+	for scanner.Scan() {
+		name := strings.TrimRight(scanner.Text(), "\r")
+		if len(name) == 0 {
+			continue
+		}
+
+		file := DirwatchRemoteFile{Path: name}
+		if !strings.Contains(name, "/") {
+			file.Path = path.Join(dir, name)
+		}
+
+		message, err := ftp.expect(2, fmt.Sprintf("SIZE %s", file.Path))
+		if err != nil {
+			continue
+		}
+		file.Size, _ = strconv.ParseInt(message, 10, 64)
+
+		if message, err = ftp.expect(2, fmt.Sprintf("MDTM %s", file.Path)); err == nil && len(message) >= 14 {
+			file.ModTime, _ = time.Parse("20060102150405", message[:14])
+		}
+
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+func (ftp *dirwatchFtp) passive() (net.Conn, error) {
+	var addr string
+
+	if message, err := ftp.expect(2, "EPSV"); err == nil {
+		i := strings.Index(message, "(|||")
+		j := strings.LastIndex(message, "|)")
+		if i < 0 || j < i+4 {
+			return nil, fmt.Errorf("invalid response %q", message)
+		}
+		addr = net.JoinHostPort(ftp.host, message[i+4:j])
+
+	} else if message, err = ftp.expect(2, "PASV"); err == nil {
+		i := strings.Index(message, "(")
+		j := strings.LastIndex(message, ")")
+		if i < 0 || j < i {
+			return nil, fmt.Errorf("invalid response %q", message)
+		}
+		f := strings.Split(message[i+1:j], ",")
+		if len(f) != 6 {
+			return nil, fmt.Errorf("invalid response %q", message)
+		}
+		hi, _ := strconv.Atoi(f[4])
+		lo, _ := strconv.Atoi(f[5])
+		addr = net.JoinHostPort(ftp.host, strconv.Itoa(hi<<8|lo))
+
+	} else {
+		return nil, err
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, DirwatchRemoteTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if ftp.tls != nil {
+		return tls.Client(conn, ftp.tls), nil
+	}
+
+	return conn, nil
+}
+
+func (ftp *dirwatchFtp) transfer(command string, w io.Writer) error {
+	conn, err := ftp.passive()
+	if err != nil {
+		return err
+	}
+
+	code, message, err := ftp.cmd(command)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	if code/100 != 1 {
+		conn.Close()
+		return fmt.Errorf("%d %s", code, message)
+	}
+
+	conn.SetDeadline(time.Now().Add(DirwatchRemoteTimeout))
+
+	_, err = io.Copy(w, conn)
+	conn.Close()
+
+	if _, rerr := ftp.expect(2, ""); err == nil {
+		err = rerr
+	}
+
+	return err
+}
+
+type dirwatchSftp struct {
+	client      *ssh.Client
+	fingerprint string
+	id          uint32
+	reader      io.Reader
+	session     *ssh.Session
+	writer      io.WriteCloser
+}
+
+func dialDirwatchSftp(u *url.URL, username string, password string) (*dirwatchSftp, error) {
+	var auth []ssh.AuthMethod
+
+	formatError := func(err error) error {
+		return fmt.Errorf("sftp: %v", err)
+	}
+
+	if strings.Contains(password, "PRIVATE KEY") {
+		signer, err := ssh.ParsePrivateKey([]byte(password))
+		if err != nil {
+			return nil, formatError(err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+
+	} else {
+		auth = append(auth, ssh.Password(password), ssh.KeyboardInteractive(func(user string, instruction string, questions []string, echos []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range answers {
+				answers[i] = password
+			}
+			return answers, nil
+		}))
+	}
+
+	sftp := &dirwatchSftp{}
+
+	expected := u.Query().Get("fingerprint")
+
+	host := u.Host
+	if len(u.Port()) == 0 {
+		host = net.JoinHostPort(u.Hostname(), "22")
+	}
+
+	client, err := ssh.Dial("tcp", host, &ssh.ClientConfig{
+		Auth: auth,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			sftp.fingerprint = ssh.FingerprintSHA256(key)
+			if len(expected) > 0 && sftp.fingerprint != expected {
+				return fmt.Errorf("host key %s does not match %s", sftp.fingerprint, expected)
+			}
+			return nil
+		},
+		Timeout: DirwatchRemoteTimeout,
+		User:    username,
+	})
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	sftp.client = client
+
+	fail := func(err error) (*dirwatchSftp, error) {
+		sftp.Close()
+		return nil, formatError(err)
+	}
+
+	if sftp.session, err = client.NewSession(); err != nil {
+		return fail(err)
+	}
+
+	if sftp.writer, err = sftp.session.StdinPipe(); err != nil {
+		return fail(err)
+	}
+
+	stdout, err := sftp.session.StdoutPipe()
+	if err != nil {
+		return fail(err)
+	}
+	sftp.reader = bufio.NewReader(stdout)
+
+	if err = sftp.session.RequestSubsystem("sftp"); err != nil {
+		return fail(err)
+	}
+
+	if err = sftp.write(sftpPacketInit, uint32(3)); err != nil {
+		return fail(err)
+	}
+
+	if kind, _, err := sftp.read(); err != nil {
+		return fail(err)
+	} else if kind != sftpPacketVersion {
+		return fail(fmt.Errorf("unexpected packet %d", kind))
+	}
+
+	return sftp, nil
+}
+
+func (sftp *dirwatchSftp) Close() error {
+	if sftp.writer != nil {
+		sftp.writer.Close()
+	}
+
+	if sftp.session != nil {
+		sftp.session.Close()
+	}
+
+	return sftp.client.Close()
+}
+
+func (sftp *dirwatchSftp) List(dir string) ([]DirwatchRemoteFile, error) {
+	handle, err := sftp.handle(sftpPacketOpendir, dir)
+	if err != nil {
+		return nil, fmt.Errorf("sftp: %s %v", dir, err)
+	}
+	defer sftp.request(sftpPacketClose, handle)
+
+	files := []DirwatchRemoteFile{}
+	dirs := []string{}
+
+	for {
+		kind, body, err := sftp.request(sftpPacketReaddir, handle)
+		if err != nil {
+			return nil, err
+		}
+
+		if kind == sftpPacketStatus {
+			if err = getSftpStatusError(body); err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		if kind != sftpPacketName {
+			return nil, fmt.Errorf("sftp: unexpected packet %d", kind)
+		}
+
+		r := &sftpReader{b: body}
+
+		for count := r.uint32(); count > 0 && r.err == nil; count-- {
+			name := r.string()
+			r.string()
+
+			flags := r.uint32()
+
+			var (
+				mode  uint32
+				mtime uint32
+				size  uint64
+			)
+
+			if flags&0x1 != 0 {
+				size = r.uint64()
+			}
+			if flags&0x2 != 0 {
+				r.uint32()
+				r.uint32()
+			}
+			if flags&0x4 != 0 {
+				mode = r.uint32()
+			}
+			if flags&0x8 != 0 {
+				r.uint32()
+				mtime = r.uint32()
+			}
+			if flags&0x80000000 != 0 {
+				for n := r.uint32(); n > 0 && r.err == nil; n-- {
+					r.string()
+					r.string()
+				}
+			}
+
+			if name == "." || name == ".." {
+				continue
+			}
+
+			switch mode & 0170000 {
+			case 0040000:
+				dirs = append(dirs, path.Join(dir, name))
+			case 0100000:
+				files = append(files, DirwatchRemoteFile{
+					ModTime: time.Unix(int64(mtime), 0),
+					Path:    path.Join(dir, name),
+					Size:    int64(size),
+				})
+			}
+		}
+
+		if r.err != nil {
+			return nil, fmt.Errorf("sftp: %v", r.err)
+		}
+	}
+
+	for _, d := range dirs {
+		sub, err := sftp.List(d)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, sub...)
+	}
+
+	return files, nil
+}
+
+func (sftp *dirwatchSftp) Read(p string, w io.Writer) error {
+	handle, err := sftp.handle(sftpPacketOpen, p, uint32(1), uint32(0))
+	if err != nil {
+		return fmt.Errorf("sftp: %s %v", p, err)
+	}
+	defer sftp.request(sftpPacketClose, handle)
+
+	for offset := uint64(0); ; {
+		kind, body, err := sftp.request(sftpPacketRead, handle, offset, uint32(DirwatchRemoteChunkSize))
+		if err != nil {
+			return err
+		}
+
+		switch kind {
+		case sftpPacketData:
+			r := &sftpReader{b: body}
+			data := r.bytes()
+			if r.err != nil {
+				return fmt.Errorf("sftp: %v", r.err)
+			}
+			if _, err = w.Write(data); err != nil {
+				return err
+			}
+			offset += uint64(len(data))
+
+		case sftpPacketStatus:
+			if err = getSftpStatusError(body); err == io.EOF {
+				return nil
+			}
+			return err
+
+		default:
+			return fmt.Errorf("sftp: unexpected packet %d", kind)
+		}
+	}
+}
+
+func (sftp *dirwatchSftp) Remove(p string) error {
+	kind, body, err := sftp.request(sftpPacketRemove, p)
+	if err != nil {
+		return err
+	}
+
+	if kind != sftpPacketStatus {
+		return fmt.Errorf("sftp: unexpected packet %d", kind)
+	}
+
+	if err = getSftpStatusError(body); err != nil {
+		return fmt.Errorf("sftp: %s %v", p, err)
+	}
+
+	return nil
+}
+
+func (sftp *dirwatchSftp) handle(kind byte, args ...interface{}) (string, error) {
+	kind, body, err := sftp.request(kind, args...)
+	if err != nil {
+		return "", err
+	}
+
+	switch kind {
+	case sftpPacketHandle:
+		r := &sftpReader{b: body}
+		handle := r.string()
+		return handle, r.err
+	case sftpPacketStatus:
+		if err = getSftpStatusError(body); err == nil {
+			err = errors.New("no handle")
+		}
+		return "", err
+	}
+
+	return "", fmt.Errorf("unexpected packet %d", kind)
+}
+
+func (sftp *dirwatchSftp) read() (byte, []byte, error) {
+	var length uint32
+
+	if err := binary.Read(sftp.reader, binary.BigEndian, &length); err != nil {
+		return 0, nil, err
+	}
+
+	if length == 0 || length > 4*DirwatchRemoteChunkSize+1024 {
+		return 0, nil, fmt.Errorf("invalid packet length %d", length)
+	}
+
+	b := make([]byte, length)
+	if _, err := io.ReadFull(sftp.reader, b); err != nil {
+		return 0, nil, err
+	}
+
+	return b[0], b[1:], nil
+}
+
+func (sftp *dirwatchSftp) request(kind byte, args ...interface{}) (byte, []byte, error) {
+	sftp.id++
+
+	id := sftp.id
+
+	if err := sftp.write(kind, append([]interface{}{id}, args...)...); err != nil {
+		return 0, nil, err
+	}
+
+	kind, body, err := sftp.read()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	r := &sftpReader{b: body}
+	if r.uint32() != id || r.err != nil {
+		return 0, nil, errors.New("sftp: unexpected response id")
+	}
+
+	return kind, r.b, nil
+}
+
+func (sftp *dirwatchSftp) write(kind byte, args ...interface{}) error {
+	b := bytes.NewBuffer([]byte{0, 0, 0, 0, kind})
+
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			binary.Write(b, binary.BigEndian, uint32(len(v)))
+			b.WriteString(v)
+		case uint32, uint64:
+			binary.Write(b, binary.BigEndian, v)
+		}
+	}
+
+	p := b.Bytes()
+	binary.BigEndian.PutUint32(p, uint32(len(p)-4))
+
+	_, err := sftp.writer.Write(p)
+
+	return err
+}
+
+type sftpReader struct {
+	b   []byte
+	err error
+}
+
+func (r *sftpReader) bytes() []byte {
+	n := int(r.uint32())
+	if r.err != nil || n > len(r.b) {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.b[:n]
+	r.b = r.b[n:]
+	return b
+}
+
+func (r *sftpReader) string() string {
+	return string(r.bytes())
+}
+
+func (r *sftpReader) uint32() uint32 {
+	if r.err != nil || len(r.b) < 4 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *sftpReader) uint64() uint64 {
+	if r.err != nil || len(r.b) < 8 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.b)
+	r.b = r.b[8:]
+	return v
+}
+
+func getSftpStatusError(body []byte) error {
+	r := &sftpReader{b: body}
+
+	code := r.uint32()
+	message := r.string()
+
+	switch {
+	case r.err != nil:
+		return r.err
+	case code == sftpStatusOk:
+		return nil
+	case code == sftpStatusEof:
+		return io.EOF
+	}
+
+	return fmt.Errorf("status %d %s", code, message)
+}
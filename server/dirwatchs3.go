@@ -0,0 +1,320 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DirwatchS3SqsWaitSeconds = 20
+)
+
+type dirwatchS3 struct {
+	bucket      string
+	client      *http.Client
+	credentials StorageCredentials
+}
+
+type dirwatchSqs struct {
+	accessKey string
+	client    *http.Client
+	queue     *url.URL
+	region    string
+	secretKey string
+}
+
+func newDirwatchS3(dirwatch *Dirwatch, u *url.URL, username string, password string) *dirwatchS3 {
+	options := dirwatch.controller.Options
+	query := u.Query()
+
+	credentials := StorageCredentials{
+		AccessKey: username,
+		Endpoint:  query.Get("endpoint"),
+		PathStyle: query.Get("pathStyle") == "true",
+		Region:    query.Get("region"),
+		SecretKey: password,
+	}
+
+	if len(credentials.AccessKey) == 0 {
+		credentials.AccessKey = options.AudioStorageS3AccessKey
+		credentials.SecretKey = options.AudioStorageS3SecretKey
+	}
+
+	if len(credentials.Endpoint) == 0 && len(credentials.Region) == 0 {
+		credentials.Endpoint = options.AudioStorageS3Endpoint
+		credentials.PathStyle = credentials.PathStyle || options.AudioStorageS3PathStyle
+		credentials.Region = options.AudioStorageS3Region
+	}
+
+	return &dirwatchS3{
+		bucket:      u.Host,
+		client:      &http.Client{Timeout: DirwatchRemoteTimeout},
+		credentials: credentials,
+	}
+}
+
+func (s3 *dirwatchS3) Close() error {
+	return nil
+}
+
+func (s3 *dirwatchS3) List(prefix string) ([]DirwatchRemoteFile, error) {
+	var result struct {
+		Contents              []StorageObject `xml:"Contents"`
+		IsTruncated           bool            `xml:"IsTruncated"`
+		NextContinuationToken string          `xml:"NextContinuationToken"`
+	}
+
+	files := []DirwatchRemoteFile{}
+
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", prefix)
+
+	for {
+		res, err := s3.request(http.MethodGet, "", query)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Contents = nil
+		result.IsTruncated = false
+
+		err = xml.NewDecoder(res.Body).Decode(&result)
+		res.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3: %v", err)
+		}
+
+		for _, object := range result.Contents {
+			if strings.HasSuffix(object.Key, "/") {
+				continue
+			}
+
+			files = append(files, DirwatchRemoteFile{
+				ModTime: object.LastModified,
+				Path:    object.Key,
+				Size:    object.Size,
+			})
+		}
+
+		if !result.IsTruncated || len(result.NextContinuationToken) == 0 {
+			break
+		}
+
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+
+	return files, nil
+}
+
+func (s3 *dirwatchS3) Read(key string, w io.Writer) error {
+	res, err := s3.request(http.MethodGet, key, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if _, err = io.Copy(w, res.Body); err != nil {
+		return fmt.Errorf("s3: %v", err)
+	}
+
+	return nil
+}
+
+func (s3 *dirwatchS3) Remove(key string) error {
+	res, err := s3.request(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+func (s3 *dirwatchS3) request(method string, key string, query url.Values) (*http.Response, error) {
+	req, err := NewS3Request(s3.credentials, s3.bucket, method, key, query, bytes.NewReader([]byte{}), 0, StorageEmptyPayloadHash, "")
+	if err != nil {
+		return nil, fmt.Errorf("s3: %v", err)
+	}
+
+	res, err := s3.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %v", err)
+	}
+
+	if res.StatusCode >= 300 && !(method == http.MethodDelete && res.StatusCode == http.StatusNotFound) {
+		res.Body.Close()
+		return nil, fmt.Errorf("s3: %s %s returned %s", method, key, res.Status)
+	}
+
+	return res, nil
+}
+
+func newDirwatchSqs(dirwatch *Dirwatch) (*dirwatchSqs, error) {
+	u, err := url.Parse(dirwatch.Directory)
+	if err != nil {
+		return nil, err
+	}
+
+	query := u.Query()
+
+	if len(query.Get("sqs")) == 0 {
+		return nil, nil
+	}
+
+	queue, err := url.Parse(query.Get("sqs"))
+	if err != nil {
+		return nil, fmt.Errorf("sqs: %v", err)
+	}
+
+	s3 := newDirwatchS3(dirwatch, u, dirwatch.Username, dirwatch.Password)
+
+	region := s3.credentials.Region
+	if labels := strings.Split(queue.Hostname(), "."); len(labels) > 2 && labels[0] == "sqs" {
+		region = labels[1]
+	}
+	if len(region) == 0 {
+		region = "us-east-1"
+	}
+
+	return &dirwatchSqs{
+		accessKey: s3.credentials.AccessKey,
+		client:    &http.Client{Timeout: (DirwatchS3SqsWaitSeconds + 10) * time.Second},
+		queue:     queue,
+		region:    region,
+		secretKey: s3.credentials.SecretKey,
+	}, nil
+}
+
+func (sqs *dirwatchSqs) Receive() (int, error) {
+	var result struct {
+		Messages []struct {
+			Body          string `xml:"Body"`
+			ReceiptHandle string `xml:"ReceiptHandle"`
+		} `xml:"ReceiveMessageResult>Message"`
+	}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("sqs: %v", err)
+	}
+
+	form := url.Values{}
+	form.Set("Action", "ReceiveMessage")
+	form.Set("MaxNumberOfMessages", "10")
+	form.Set("Version", "2012-11-05")
+	form.Set("WaitTimeSeconds", fmt.Sprintf("%d", DirwatchS3SqsWaitSeconds))
+
+	b, err := sqs.request(form)
+	if err != nil {
+		return 0, formatError(err)
+	}
+
+	if err = xml.Unmarshal(b, &result); err != nil {
+		return 0, formatError(err)
+	}
+
+	count := 0
+
+	for _, message := range result.Messages {
+		if isDirwatchSqsObjectCreated(message.Body) {
+			count++
+		}
+
+		form := url.Values{}
+		form.Set("Action", "DeleteMessage")
+		form.Set("ReceiptHandle", message.ReceiptHandle)
+		form.Set("Version", "2012-11-05")
+
+		if _, err = sqs.request(form); err != nil {
+			return count, formatError(err)
+		}
+	}
+
+	return count, nil
+}
+
+func (sqs *dirwatchSqs) request(form url.Values) ([]byte, error) {
+	body := []byte(form.Encode())
+	hash := sha256.Sum256(body)
+
+	req, err := http.NewRequest(http.MethodPost, sqs.queue.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Host = sqs.queue.Host
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	uri := sqs.queue.EscapedPath()
+	if len(uri) == 0 {
+		uri = "/"
+	}
+
+	SignAwsRequest(req, sqs.accessKey, sqs.secretKey, sqs.region, "sqs", uri, "", hex.EncodeToString(hash[:]))
+
+	res, err := sqs.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", sqs.queue.Redacted(), res.Status)
+	}
+
+	return b, nil
+}
+
+func isDirwatchSqsObjectCreated(body string) bool {
+	var event struct {
+		Message string `json:"Message"`
+		Records []struct {
+			EventName string `json:"eventName"`
+		} `json:"Records"`
+	}
+
+	if err := json.Unmarshal([]byte(body), &event); err != nil {
+		return false
+	}
+
+	if len(event.Message) > 0 {
+		return isDirwatchSqsObjectCreated(event.Message)
+	}
+
+	for _, record := range event.Records {
+		if strings.HasPrefix(record.EventName, "ObjectCreated:") {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,25 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+//go:build !darwin && !freebsd && !linux && !windows
+// +build !darwin,!freebsd,!linux,!windows
+
+package main
+
+import "errors"
+
+func GetDiskUsage(dir string) (uint64, uint64, error) {
+	return 0, 0, errors.New("disk usage not supported on this platform")
+}
@@ -0,0 +1,31 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+//go:build darwin || freebsd || linux
+// +build darwin freebsd linux
+
+package main
+
+import "syscall"
+
+func GetDiskUsage(dir string) (uint64, uint64, error) {
+	var stat syscall.Statfs_t
+
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, 0, err
+	}
+
+	return uint64(stat.Bavail) * uint64(stat.Bsize), uint64(stat.Blocks) * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,41 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+func GetDiskUsage(dir string) (uint64, uint64, error) {
+	var free, total uint64
+
+	p, err := syscall.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	proc := syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+	if r, _, err := proc.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&free)), uintptr(unsafe.Pointer(&total)), 0); r == 0 {
+		return 0, 0, err
+	}
+
+	return free, total, nil
+}
@@ -124,18 +124,21 @@ func (downstream *Downstream) HasAccess(call *Call) bool {
 func (downstream *Downstream) Send(call *Call) error {
 	var (
 		audioName string
-		buf       = bytes.Buffer{}
+		buf       *bytes.Buffer
 	)
 
 	if downstream.Disabled {
 		return nil
 	}
 
+	buf = GetAudioBuffer()
+	defer PutAudioBuffer(buf)
+
 	formatError := func(err error) error {
 		return fmt.Errorf("downstream.send: %s", err.Error())
 	}
 
-	mw := multipart.NewWriter(&buf)
+	mw := multipart.NewWriter(buf)
 
 	switch v := call.AudioName.(type) {
 	case string:
@@ -326,6 +329,27 @@ func (downstream *Downstream) Send(call *Call) error {
 		}
 	}
 
+	if len(call.transcript) > 0 {
+		if w, err := mw.CreateFormField("transcript"); err == nil {
+			if _, err = w.Write([]byte(call.transcript)); err != nil {
+				return formatError(err)
+			}
+		} else {
+			return formatError(err)
+		}
+	}
+
+	switch v := call.transcriptScore.(type) {
+	case float64:
+		if w, err := mw.CreateFormField("transcriptConfidence"); err == nil {
+			if _, err = w.Write([]byte(fmt.Sprintf("%v", v))); err != nil {
+				return formatError(err)
+			}
+		} else {
+			return formatError(err)
+		}
+	}
+
 	if err := mw.Close(); err != nil {
 		return formatError(err)
 	}
@@ -335,7 +359,7 @@ func (downstream *Downstream) Send(call *Call) error {
 
 		c := http.Client{Timeout: 10 * time.Second}
 
-		if res, err := c.Post(u.String(), mw.FormDataContentType(), &buf); err == nil {
+		if res, err := c.Post(u.String(), mw.FormDataContentType(), buf); err == nil {
 			if res.StatusCode != http.StatusOK {
 				return formatError(fmt.Errorf("bad status: %s", res.Status))
 			}
@@ -453,6 +477,10 @@ func (downstreams *Downstreams) Send(controller *Controller, call *Call) {
 				logEvent(LogLevelInfo, "success")
 			} else {
 				logEvent(LogLevelError, err.Error())
+
+				if err := controller.Notifications.Notify(NotificationKindDownstream, downstream.Url, fmt.Sprintf("downstream %s failed, %s", downstream.Url, err.Error())); err != nil {
+					controller.Logs.LogEvent(LogLevelError, err.Error())
+				}
 			}
 		}
 	}
@@ -0,0 +1,197 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const DuplicatesRecentSize = 100
+
+type Duplicate struct {
+	CallDateTime     time.Time `json:"callDateTime"`
+	DateTime         time.Time `json:"dateTime"`
+	Delta            int64     `json:"delta"`
+	Grouped          bool      `json:"grouped"`
+	OriginalDateTime time.Time `json:"originalDateTime"`
+	OriginalId       uint      `json:"originalId"`
+	System           uint      `json:"system"`
+	SystemLabel      string    `json:"systemLabel"`
+	Talkgroup        uint      `json:"talkgroup"`
+	TalkgroupLabel   string    `json:"talkgroupLabel"`
+	Uploader         string    `json:"uploader"`
+}
+
+type Duplicates struct {
+	checked   uint64
+	grouped   uint64
+	recent    []Duplicate
+	rejected  uint64
+	since     time.Time
+	uploaders map[string]uint64
+	mutex     sync.Mutex
+}
+
+func NewDuplicates() *Duplicates {
+	return &Duplicates{
+		recent:    []Duplicate{},
+		since:     time.Now(),
+		uploaders: map[string]uint64{},
+		mutex:     sync.Mutex{},
+	}
+}
+
+func (duplicates *Duplicates) Add(call *Call, system *System, talkgroup *Talkgroup, originalId uint, originalDateTime time.Time, grouped bool) {
+	duplicates.mutex.Lock()
+	defer duplicates.mutex.Unlock()
+
+	uploader := call.uploader
+	if len(uploader) == 0 {
+		uploader = "unknown"
+	}
+
+	delta := call.DateTime.Sub(originalDateTime).Milliseconds()
+	if delta < 0 {
+		delta = -delta
+	}
+
+	duplicate := Duplicate{
+		CallDateTime:     call.DateTime,
+		DateTime:         time.Now().UTC(),
+		Delta:            delta,
+		Grouped:          grouped,
+		OriginalDateTime: originalDateTime,
+		OriginalId:       originalId,
+		System:           call.System,
+		Talkgroup:        call.Talkgroup,
+		Uploader:         uploader,
+	}
+
+	if system != nil {
+		duplicate.SystemLabel = system.Label
+	}
+
+	if talkgroup != nil {
+		duplicate.TalkgroupLabel = talkgroup.Label
+	}
+
+	if grouped {
+		duplicates.grouped++
+	} else {
+		duplicates.rejected++
+	}
+
+	duplicates.uploaders[uploader]++
+
+	duplicates.recent = append([]Duplicate{duplicate}, duplicates.recent...)
+	if len(duplicates.recent) > DuplicatesRecentSize {
+		duplicates.recent = duplicates.recent[:DuplicatesRecentSize]
+	}
+}
+
+func (duplicates *Duplicates) Checked() {
+	duplicates.mutex.Lock()
+	defer duplicates.mutex.Unlock()
+
+	duplicates.checked++
+}
+
+func (duplicates *Duplicates) GetStatus(options *Options) map[string]interface{} {
+	duplicates.mutex.Lock()
+	defer duplicates.mutex.Unlock()
+
+	uploaders := []map[string]interface{}{}
+	for uploader, count := range duplicates.uploaders {
+		uploaders = append(uploaders, map[string]interface{}{
+			"count":    count,
+			"uploader": uploader,
+		})
+	}
+
+	sort.Slice(uploaders, func(i int, j int) bool {
+		return uploaders[i]["count"].(uint64) > uploaders[j]["count"].(uint64)
+	})
+
+	recent := make([]Duplicate, len(duplicates.recent))
+	copy(recent, duplicates.recent)
+
+	var ratio float64
+	if duplicates.checked > 0 {
+		ratio = float64(duplicates.rejected+duplicates.grouped) / float64(duplicates.checked)
+	}
+
+	return map[string]interface{}{
+		"checked":   duplicates.checked,
+		"disabled":  options.DisableDuplicateDetection,
+		"grouped":   duplicates.grouped,
+		"ratio":     ratio,
+		"recent":    recent,
+		"rejected":  duplicates.rejected,
+		"since":     duplicates.since,
+		"timeFrame": options.DuplicateDetectionTimeFrame,
+		"uploaders": uploaders,
+	}
+}
+
+func (duplicates *Duplicates) Reset() {
+	duplicates.mutex.Lock()
+	defer duplicates.mutex.Unlock()
+
+	duplicates.checked = 0
+	duplicates.grouped = 0
+	duplicates.recent = []Duplicate{}
+	duplicates.rejected = 0
+	duplicates.since = time.Now()
+	duplicates.uploaders = map[string]uint64{}
+}
+
+func (admin *Admin) DuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		if _, ok := admin.ValidateTokenRole(t, AdminRoleEditor); !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		admin.Controller.Duplicates.Reset()
+
+		w.WriteHeader(http.StatusOK)
+
+	case http.MethodGet:
+		if b, err := json.Marshal(admin.Controller.Duplicates.GetStatus(admin.Controller.Options)); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.duplicateshandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
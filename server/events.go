@@ -0,0 +1,267 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventsMaxMessageSize = 1 << 20
+	EventsSessionTimeout = 15 * time.Second
+	EventsStreamDuration = 8 * time.Second
+)
+
+type HttpClient struct {
+	Client  *Client
+	Token   string
+	expires *time.Timer
+	stream  chan struct{}
+}
+
+type HttpClients struct {
+	Controller *Controller
+	Map        map[string]*HttpClient
+	mutex      sync.Mutex
+}
+
+func NewHttpClients(controller *Controller) *HttpClients {
+	return &HttpClients{
+		Controller: controller,
+		Map:        map[string]*HttpClient{},
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (httpClients *HttpClients) Attach(token string) (*HttpClient, chan struct{}, bool) {
+	httpClients.mutex.Lock()
+	defer httpClients.mutex.Unlock()
+
+	httpClient, ok := httpClients.Map[token]
+	if !ok {
+		return nil, nil, false
+	}
+
+	httpClient.expires.Stop()
+
+	if httpClient.stream != nil {
+		close(httpClient.stream)
+	}
+
+	httpClient.stream = make(chan struct{})
+
+	return httpClient, httpClient.stream, true
+}
+
+func (httpClients *HttpClients) Create(request *http.Request) (*HttpClient, error) {
+	if httpClients.Controller.Clients.Count() >= int(httpClients.Controller.Options.MaxClients) {
+		return nil, errors.New("too many clients")
+	}
+
+	client := &Client{}
+	client.init(httpClients.Controller, request)
+
+	httpClient := &HttpClient{
+		Client: client,
+		Token:  uuid.New().String(),
+	}
+
+	httpClient.expires = time.AfterFunc(EventsSessionTimeout, func() {
+		httpClients.expire(httpClient)
+	})
+
+	httpClients.mutex.Lock()
+	httpClients.Map[httpClient.Token] = httpClient
+	httpClients.mutex.Unlock()
+
+	httpClients.Controller.Register <- client
+
+	return httpClient, nil
+}
+
+func (httpClients *HttpClients) Detach(httpClient *HttpClient, stream chan struct{}) {
+	httpClients.mutex.Lock()
+	defer httpClients.mutex.Unlock()
+
+	if httpClient.stream != stream {
+		return
+	}
+
+	httpClient.stream = nil
+	httpClient.expires.Reset(EventsSessionTimeout)
+}
+
+func (httpClients *HttpClients) GetClient(token string) (*Client, bool) {
+	httpClients.mutex.Lock()
+	defer httpClients.mutex.Unlock()
+
+	if httpClient, ok := httpClients.Map[token]; ok {
+		return httpClient.Client, true
+	}
+
+	return nil, false
+}
+
+func (httpClients *HttpClients) Remove(client *Client) {
+	httpClients.mutex.Lock()
+	defer httpClients.mutex.Unlock()
+
+	for token, httpClient := range httpClients.Map {
+		if httpClient.Client == client {
+			httpClients.remove(token)
+			return
+		}
+	}
+}
+
+func (httpClients *HttpClients) expire(httpClient *HttpClient) {
+	httpClients.mutex.Lock()
+	defer httpClients.mutex.Unlock()
+
+	if httpClient.stream == nil && httpClients.Map[httpClient.Token] == httpClient {
+		httpClients.remove(httpClient.Token)
+	}
+}
+
+func (httpClients *HttpClients) remove(token string) {
+	httpClient := httpClients.Map[token]
+
+	delete(httpClients.Map, token)
+
+	httpClient.expires.Stop()
+
+	if httpClient.stream != nil {
+		close(httpClient.stream)
+		httpClient.stream = nil
+	}
+
+	go func() {
+		httpClients.Controller.Unregister <- httpClient.Client
+	}()
+}
+
+func (api *Api) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			w.WriteHeader(http.StatusNotImplemented)
+			return
+		}
+
+		httpClient, stream, ok := api.Controller.HttpClients.Attach(r.URL.Query().Get("session"))
+		if !ok {
+			created, err := api.Controller.HttpClients.Create(r)
+			if err != nil {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			if httpClient, stream, ok = api.Controller.HttpClients.Attach(created.Token); !ok {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		}
+
+		defer api.Controller.HttpClients.Detach(httpClient, stream)
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("X-Accel-Buffering", "no")
+
+		b, err := json.Marshal(map[string]interface{}{"session": httpClient.Token})
+		if err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		if _, err = fmt.Fprintf(w, "event: session\ndata: %s\n\n", b); err != nil {
+			return
+		}
+
+		flusher.Flush()
+
+		timer := time.NewTimer(EventsStreamDuration)
+		defer timer.Stop()
+
+		for {
+			select {
+			case message, ok := <-httpClient.Client.Send:
+				if !ok {
+					return
+				}
+
+				b, err := message.ToJson()
+				if err != nil {
+					log.Println(fmt.Errorf("api.eventshandler.tojson: %v", err))
+					continue
+				}
+
+				if _, err = fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+					return
+				}
+
+				flusher.Flush()
+
+			case <-timer.C:
+				return
+
+			case <-stream:
+				return
+
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+	case http.MethodPost:
+		client, ok := api.Controller.HttpClients.GetClient(r.URL.Query().Get("session"))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		b, err := io.ReadAll(io.LimitReader(r.Body, EventsMaxMessageSize))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		message := &Message{}
+		if err = message.FromJson(b); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err = api.Controller.ProcessMessage(client, message); err != nil {
+			log.Println(fmt.Errorf("api.eventshandler.processmessage: %v", err))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
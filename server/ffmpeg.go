@@ -17,66 +17,129 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"math"
+	"os"
 	"os/exec"
 	"path"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 type FFMpeg struct {
 	available bool
+	err       error
+	hwaccel   []string
+	hwerr     error
+	path      string
+	timeout   time.Duration
+	version   string
 	version43 bool
-	warned    bool
+	warned    sync.Once
 }
 
-func NewFFMpeg() *FFMpeg {
-	ffmpeg := &FFMpeg{}
+func NewFFMpeg(config *Config) *FFMpeg {
+	ffmpeg := &FFMpeg{
+		path:    config.FfmpegPath,
+		timeout: time.Duration(config.FfmpegTimeout) * time.Second,
+	}
+
+	if len(ffmpeg.path) == 0 {
+		ffmpeg.path = "ffmpeg"
+	}
+
+	if ffmpeg.timeout == 0 {
+		ffmpeg.timeout = time.Minute
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
 	stdout := bytes.NewBuffer([]byte(nil))
 
-	cmd := exec.Command("ffmpeg", "-version")
+	cmd := exec.CommandContext(ctx, ffmpeg.path, "-version")
 	cmd.Stdout = stdout
 
-	if err := cmd.Run(); err == nil {
-		ffmpeg.available = true
-
-		if l, err := stdout.ReadString('\n'); err == nil {
-			s := regexp.MustCompile(`.*ffmpeg version .{0,1}([0-9])\.([0-9])\.[0-9].*`).ReplaceAllString(strings.TrimSuffix(l, "\n"), "$1.$2")
-			v := strings.Split(s, ".")
-			if len(v) > 1 {
-				if major, err := strconv.Atoi(v[0]); err == nil {
-					if minor, err := strconv.Atoi(v[1]); err == nil {
-						if major > 4 || (major == 4 && minor >= 3) {
-							ffmpeg.version43 = true
-						}
-					}
-				}
+	if err := cmd.Run(); err != nil {
+		ffmpeg.err = fmt.Errorf("ffmpeg is not available at %s, no audio conversion will be performed: %v", ffmpeg.path, err)
+		return ffmpeg
+	}
+
+	l, _ := stdout.ReadString('\n')
+
+	if m := regexp.MustCompile(`ffmpeg version \D{0,1}([0-9]+)\.([0-9]+)`).FindStringSubmatch(l); len(m) == 3 {
+		major, _ := strconv.Atoi(m[1])
+		minor, _ := strconv.Atoi(m[2])
+
+		ffmpeg.version = fmt.Sprintf("%v.%v", major, minor)
+
+		if major > 4 || (major == 4 && minor >= 3) {
+			ffmpeg.version43 = true
+		}
+
+		if minMajor, minMinor, ok := parseFFMpegVersion(config.FfmpegMinVer); ok {
+			if major < minMajor || (major == minMajor && minor < minMinor) {
+				ffmpeg.err = fmt.Errorf("ffmpeg version %s is older than the minimum required version %s, no audio conversion will be performed", ffmpeg.version, config.FfmpegMinVer)
+				return ffmpeg
 			}
 		}
+
+	} else if m := regexp.MustCompile(`ffmpeg version (\S+)`).FindStringSubmatch(l); len(m) == 2 {
+		ffmpeg.version = m[1]
+		ffmpeg.version43 = true
+
+	} else {
+		ffmpeg.err = fmt.Errorf("unable to determine the ffmpeg version of %s, no audio conversion will be performed", ffmpeg.path)
+		return ffmpeg
+	}
+
+	ffmpeg.available = true
+
+	if len(config.FfmpegHwAccel) > 0 {
+		ffmpeg.hwaccel, ffmpeg.hwerr = ffmpeg.getHwAccel(config.FfmpegHwAccel, config.FfmpegHwDev)
 	}
 
 	return ffmpeg
 }
 
-func (ffmpeg *FFMpeg) Convert(call *Call, systems *Systems, tags *Tags) error {
+func (ffmpeg *FFMpeg) Convert(call *Call, systems *Systems, tags *Tags, options *Options) error {
 	var (
-		args = []string{"-i", "-"}
-		err  error
+		args     = append(append([]string{}, ffmpeg.hwaccel...), "-i", "-")
+		bitrate  = options.AudioBitrate
+		channels = options.AudioChannels
+		codec    = options.AudioCodec
+		err      error
 	)
 
 	if !ffmpeg.available {
-		if !ffmpeg.warned {
-			ffmpeg.warned = true
-
-			return errors.New("ffmpeg is not available, no audio conversion will be performed.")
-		}
-		return nil
+		ffmpeg.warned.Do(func() {
+			err = ffmpeg.GetError()
+		})
+		return err
 	}
 
 	if system, ok := systems.GetSystem(call.System); ok {
+		if system.AudioBitrate > 0 {
+			bitrate = system.AudioBitrate
+		}
+
+		if system.AudioChannels > 0 {
+			channels = system.AudioChannels
+		}
+
+		if len(system.AudioCodec) > 0 {
+			codec = system.AudioCodec
+		}
+
+		if system.AudioSampleRate > 0 {
+			args = append(args, "-ar", fmt.Sprintf("%v", system.AudioSampleRate))
+		}
+
 		if talkgroup, ok := system.Talkgroups.GetTalkgroup(call.Talkgroup); ok {
 			if tag, ok := tags.GetTag(talkgroup.TagId); ok {
 				args = append(args,
@@ -90,33 +153,349 @@ func (ffmpeg *FFMpeg) Convert(call *Call, systems *Systems, tags *Tags) error {
 		}
 	}
 
+	if channels > 0 {
+		args = append(args, "-ac", fmt.Sprintf("%v", channels))
+	}
+
 	if ffmpeg.version43 {
-		args = append(args, "-af", "apad=whole_dur=3s,loudnorm=I=-16:TP=-1.5:LRA=11")
+		if options.AudioNormalization {
+			target := options.AudioLoudnessTarget
+			if target < -70 || target > -5 {
+				target = defaults.options.audioLoudnessTarget
+			}
+			args = append(args, "-af", fmt.Sprintf("apad=whole_dur=3s,loudnorm=I=%d:TP=-1.5:LRA=11:print_format=json", target))
+		} else {
+			args = append(args, "-af", "apad=whole_dur=3s")
+		}
+	}
+
+	if options.TruncateLongCalls && options.MaxCallDuration > 0 {
+		args = append(args, "-t", fmt.Sprintf("%v", options.MaxCallDuration))
 	}
 
-	args = append(args, "-c:a", "aac", "-b:a", "32k", "-movflags", "frag_keyframe+empty_moov", "-f", "ipod", "-")
+	if bitrate == 0 {
+		bitrate = 32
+	}
 
-	cmd := exec.Command("ffmpeg", args...)
-	cmd.Stdin = bytes.NewReader(call.Audio)
+	audioExt, audioType := ".m4a", "audio/mp4"
 
-	stdout := bytes.NewBuffer([]byte(nil))
-	cmd.Stdout = stdout
+	switch codec {
+	case "mp3":
+		audioExt, audioType = ".mp3", "audio/mpeg"
+		args = append(args, "-c:a", "libmp3lame", "-b:a", fmt.Sprintf("%vk", bitrate), "-f", "mp3", "-")
+	case "opus":
+		audioExt, audioType = ".ogg", "audio/ogg"
+		args = append(args, "-c:a", "libopus", "-b:a", fmt.Sprintf("%vk", bitrate), "-f", "ogg", "-")
+	default:
+		args = append(args, "-c:a", "aac", "-b:a", fmt.Sprintf("%vk", bitrate), "-movflags", "frag_keyframe+empty_moov", "-f", "ipod", "-")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ffmpeg.timeout)
+	defer cancel()
 
-	stderr := bytes.NewBuffer([]byte(nil))
+	cmd := exec.CommandContext(ctx, ffmpeg.path, args...)
+
+	if call.Audio == nil && len(call.audioFile) > 0 {
+		f, err := os.Open(call.audioFile)
+		if err != nil {
+			return fmt.Errorf("ffmpeg.convert: %v", err)
+		}
+		defer f.Close()
+		cmd.Stdin = f
+
+	} else {
+		cmd.Stdin = bytes.NewReader(call.Audio)
+	}
+
+	stdout := GetAudioBuffer()
+	defer PutAudioBuffer(stdout)
+	cmd.Stdout = &ffmpegOutput{buffer: stdout, max: options.GetMaxCallSize()}
+
+	stderr := GetAudioBuffer()
+	defer PutAudioBuffer(stderr)
 	cmd.Stderr = stderr
 
 	if err = cmd.Run(); err == nil {
-		call.Audio = stdout.Bytes()
-		call.AudioType = "audio/mp4"
+		call.Audio = append([]byte(nil), stdout.Bytes()...)
+		call.AudioType = audioType
+
+		call.ReleaseAudio()
+
+		if m := regexp.MustCompile(`time=([0-9]+):([0-9]+):([0-9.]+)`).FindAllStringSubmatch(stderr.String(), -1); len(m) > 0 {
+			h, _ := strconv.Atoi(m[len(m)-1][1])
+			n, _ := strconv.Atoi(m[len(m)-1][2])
+			s, _ := strconv.ParseFloat(m[len(m)-1][3], 64)
+			call.duration = uint((float64(h*3600+n*60) + s) * 1000)
+		}
+
+		if loudness := parseFFMpegLoudness(stderr.String()); len(loudness) > 0 {
+			call.loudness = loudness
+		}
 
 		switch v := call.AudioName.(type) {
 		case string:
-			call.AudioName = fmt.Sprintf("%v.m4a", strings.TrimSuffix(v, path.Ext((v))))
+			call.AudioName = fmt.Sprintf("%v%v", strings.TrimSuffix(v, path.Ext((v))), audioExt)
 		}
 
+	} else if int64(stdout.Len()) > options.GetMaxCallSize() {
+		return errors.New("ffmpeg.convert: converted audio exceeds the maximum size")
+
+	} else if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("ffmpeg.convert: conversion timed out after %v", ffmpeg.timeout)
+
 	} else {
-		fmt.Println(stderr.String())
+		return fmt.Errorf("ffmpeg.convert: %v: %s", err, getFFMpegStderrTail(stderr.String(), 5))
 	}
 
 	return nil
 }
+
+func (ffmpeg *FFMpeg) GetError() error {
+	if ffmpeg.available {
+		return nil
+	} else if ffmpeg.err != nil {
+		return ffmpeg.err
+	}
+	return errors.New("ffmpeg is not available, no audio conversion will be performed")
+}
+
+func (ffmpeg *FFMpeg) GetHwAccelError() error {
+	return ffmpeg.hwerr
+}
+
+func (ffmpeg *FFMpeg) Beep(audio []byte) ([]byte, error) {
+	if !ffmpeg.available {
+		return nil, errors.New("ffmpeg.beep: ffmpeg is not available")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ffmpeg.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ffmpeg.path,
+		"-i", "-",
+		"-f", "lavfi", "-i", "sine=frequency=1000:duration=0.25:sample_rate=22050",
+		"-filter_complex", "[0:a]aformat=sample_fmts=fltp:sample_rates=22050:channel_layouts=mono[a];[1:a]volume=0.3,aformat=sample_fmts=fltp:sample_rates=22050:channel_layouts=mono[b];[b][a]concat=n=2:v=0:a=1[out]",
+		"-map", "[out]",
+		"-c:a", "aac", "-b:a", "32k", "-movflags", "frag_keyframe+empty_moov", "-f", "ipod", "-",
+	)
+	cmd.Stdin = bytes.NewReader(audio)
+
+	stdout := bytes.NewBuffer(nil)
+	cmd.Stdout = stdout
+
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("ffmpeg.beep: conversion timed out after %v", ffmpeg.timeout)
+		}
+		return nil, fmt.Errorf("ffmpeg.beep: %v: %s", err, getFFMpegStderrTail(stderr.String(), 5))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func (ffmpeg *FFMpeg) Watermark(audio []byte, mark []byte, comment string) ([]byte, error) {
+	if !ffmpeg.available {
+		return nil, errors.New("ffmpeg.watermark: ffmpeg is not available")
+	}
+
+	f, err := os.CreateTemp("", "rdio-scanner-watermark-*.wav")
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg.watermark: %v", err)
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(mark)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg.watermark: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ffmpeg.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ffmpeg.path,
+		"-i", "-",
+		"-stream_loop", "-1", "-i", f.Name(),
+		"-filter_complex", fmt.Sprintf("[0:a]aformat=sample_fmts=fltp:sample_rates=%d:channel_layouts=mono[a];[1:a]aformat=sample_fmts=fltp:sample_rates=%d:channel_layouts=mono[b];[a][b]amerge=inputs=2,pan=mono|c0=c0+c1[out]", WatermarkSampleRate, WatermarkSampleRate),
+		"-map", "[out]",
+		"-metadata", fmt.Sprintf("comment=%s", comment),
+		"-c:a", "aac", "-b:a", "32k", "-movflags", "frag_keyframe+empty_moov", "-f", "ipod", "-",
+	)
+	cmd.Stdin = bytes.NewReader(audio)
+
+	stdout := bytes.NewBuffer(nil)
+	cmd.Stdout = stdout
+
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("ffmpeg.watermark: conversion timed out after %v", ffmpeg.timeout)
+		}
+		return nil, fmt.Errorf("ffmpeg.watermark: %v: %s", err, getFFMpegStderrTail(stderr.String(), 5))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func (ffmpeg *FFMpeg) ToWav(audio []byte) ([]byte, error) {
+	if !ffmpeg.available {
+		return nil, errors.New("ffmpeg.towav: ffmpeg is not available")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ffmpeg.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, ffmpeg.path, "-i", "-", "-ac", "1", "-ar", "16000", "-c:a", "pcm_s16le", "-f", "wav", "-")
+	cmd.Stdin = bytes.NewReader(audio)
+
+	stdout := bytes.NewBuffer(nil)
+	cmd.Stdout = stdout
+
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("ffmpeg.towav: conversion timed out after %v", ffmpeg.timeout)
+		}
+		return nil, fmt.Errorf("ffmpeg.towav: %v: %s", err, getFFMpegStderrTail(stderr.String(), 5))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func (ffmpeg *FFMpeg) getHwAccel(hwaccel string, device string) ([]string, error) {
+	var (
+		args []string
+		name string
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("ffmpeg.gethwaccel: %v, hardware acceleration disabled", err)
+	}
+
+	switch hwaccel {
+	case "nvenc":
+		name = "cuda"
+	case "v4l2":
+		name = "v4l2m2m"
+	case "vaapi":
+		name = "vaapi"
+	default:
+		return nil, formatError(fmt.Errorf("unknown hardware acceleration %s", hwaccel))
+	}
+
+	if len(device) > 0 && hwaccel != "nvenc" {
+		if _, err := os.Stat(device); err != nil {
+			return nil, formatError(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	stdout := bytes.NewBuffer([]byte(nil))
+
+	cmd := exec.CommandContext(ctx, ffmpeg.path, "-hide_banner", "-hwaccels")
+	cmd.Stdout = stdout
+
+	if err := cmd.Run(); err != nil {
+		return nil, formatError(err)
+	}
+
+	supported := false
+	for _, l := range strings.Split(stdout.String(), "\n") {
+		if strings.TrimSpace(l) == name {
+			supported = true
+			break
+		}
+	}
+
+	if !supported {
+		return nil, formatError(fmt.Errorf("%s is not supported by %s", name, ffmpeg.path))
+	}
+
+	args = []string{"-hwaccel", name}
+
+	if len(device) > 0 {
+		args = append(args, "-hwaccel_device", device)
+	}
+
+	return args, nil
+}
+
+type ffmpegOutput struct {
+	buffer *bytes.Buffer
+	max    int64
+}
+
+func (output *ffmpegOutput) Write(p []byte) (int, error) {
+	if int64(output.buffer.Len()+len(p)) > output.max {
+		output.buffer.Write(p[:output.max-int64(output.buffer.Len())+1])
+		return 0, errors.New("output exceeds the maximum size")
+	}
+	return output.buffer.Write(p)
+}
+
+func getFFMpegStderrTail(s string, n int) string {
+	lines := []string{}
+	for _, l := range strings.Split(strings.ReplaceAll(s, "\r", "\n"), "\n") {
+		if l = strings.TrimSpace(l); len(l) > 0 {
+			lines = append(lines, l)
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, " | ")
+}
+
+func parseFFMpegLoudness(s string) map[string]float64 {
+	loudness := map[string]float64{}
+
+	i := strings.LastIndex(s, "Parsed_loudnorm")
+	if i == -1 {
+		return loudness
+	}
+
+	for k, name := range map[string]string{
+		"input_i":      "integrated",
+		"input_lra":    "range",
+		"input_thresh": "threshold",
+		"input_tp":     "truePeak",
+		"output_i":     "output",
+	} {
+		if m := regexp.MustCompile(fmt.Sprintf(`"%s"\s*:\s*"([^"]+)"`, k)).FindStringSubmatch(s[i:]); m != nil {
+			if f, err := strconv.ParseFloat(m[1], 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+				loudness[name] = f
+			}
+		}
+	}
+
+	return loudness
+}
+
+func parseFFMpegVersion(s string) (major int, minor int, ok bool) {
+	v := strings.Split(strings.TrimSpace(s), ".")
+	if len(v) == 0 || len(v[0]) == 0 {
+		return 0, 0, false
+	}
+	if i, err := strconv.Atoi(v[0]); err == nil {
+		major = i
+	} else {
+		return 0, 0, false
+	}
+	if len(v) > 1 {
+		if i, err := strconv.Atoi(v[1]); err == nil {
+			minor = i
+		} else {
+			return 0, 0, false
+		}
+	}
+	return major, minor, true
+}
@@ -0,0 +1,160 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+)
+
+const (
+	FingerprintFrameRate  = 20
+	FingerprintMaxShift   = 3 * FingerprintFrameRate
+	FingerprintMinOverlap = FingerprintFrameRate
+)
+
+func (call *Call) GetAudioFingerprint(ffmpeg *FFMpeg) (string, error) {
+	if len(call.audioFingerprint) > 0 {
+		return call.audioFingerprint, nil
+	}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("call.getaudiofingerprint: %v", err)
+	}
+
+	audio := call.Audio
+	if audio == nil && len(call.audioFile) > 0 {
+		b, err := os.ReadFile(call.audioFile)
+		if err != nil {
+			return "", formatError(err)
+		}
+		audio = b
+	}
+
+	if len(audio) == 0 {
+		return "", formatError(errors.New("no audio"))
+	}
+
+	wav, err := ffmpeg.ToWav(audio)
+	if err != nil {
+		return "", formatError(err)
+	}
+
+	samples, sampleRate, ok := ParseWavPcm(wav)
+	if !ok {
+		return "", formatError(errors.New("unable to decode the audio"))
+	}
+
+	call.audioFingerprint = GetAudioFingerprint(samples, sampleRate)
+
+	return call.audioFingerprint, nil
+}
+
+func CompareAudioFingerprints(a string, b string) float64 {
+	fa := parseAudioFingerprint(a)
+	fb := parseAudioFingerprint(b)
+
+	if len(fa) < FingerprintMinOverlap || len(fb) < FingerprintMinOverlap {
+		return 0
+	}
+
+	shorter := len(fa)
+	if len(fb) < shorter {
+		shorter = len(fb)
+	}
+
+	var best float64
+
+	for shift := -FingerprintMaxShift; shift <= FingerprintMaxShift; shift++ {
+		var (
+			n                     int
+			sa, sb, saa, sbb, sab float64
+		)
+
+		for i := 0; i < len(fa); i++ {
+			j := i + shift
+			if j < 0 {
+				continue
+			}
+			if j >= len(fb) {
+				break
+			}
+
+			x, y := fa[i], fb[j]
+
+			n++
+			sa += x
+			sb += y
+			saa += x * x
+			sbb += y * y
+			sab += x * y
+		}
+
+		if n < FingerprintMinOverlap || n*2 < shorter {
+			continue
+		}
+
+		va := saa - sa*sa/float64(n)
+		vb := sbb - sb*sb/float64(n)
+		if va <= 0 || vb <= 0 {
+			continue
+		}
+
+		if score := (sab - sa*sb/float64(n)) / math.Sqrt(va*vb); score > best {
+			best = score
+		}
+	}
+
+	return best
+}
+
+func GetAudioFingerprint(samples []float64, sampleRate int) string {
+	size := sampleRate / FingerprintFrameRate
+	if size == 0 || len(samples) < size {
+		return ""
+	}
+
+	fingerprint := make([]byte, len(samples)/size)
+
+	for i := range fingerprint {
+		var energy float64
+		for _, s := range samples[i*size : (i+1)*size] {
+			energy += s * s
+		}
+
+		level := 2 * (10*math.Log10(energy/float64(size)+1e-10) + 100)
+		fingerprint[i] = byte(math.Max(0, math.Min(255, level)))
+	}
+
+	return hex.EncodeToString(fingerprint)
+}
+
+func parseAudioFingerprint(fingerprint string) []float64 {
+	b, err := hex.DecodeString(fingerprint)
+	if err != nil {
+		return nil
+	}
+
+	f := make([]float64, len(b))
+	for i, v := range b {
+		f[i] = float64(v)
+	}
+
+	return f
+}
@@ -0,0 +1,206 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+)
+
+type FrequencyUsage struct {
+	Date           string  `json:"date"`
+	System         uint    `json:"system"`
+	Frequency      uint    `json:"frequency"`
+	Calls          uint    `json:"calls"`
+	Errors         uint    `json:"errors"`
+	Spikes         uint    `json:"spikes"`
+	ControlSamples uint    `json:"controlSamples"`
+	DecodeRate     float64 `json:"decodeRate,omitempty"`
+	FirstSeen      string  `json:"firstSeen"`
+}
+
+type Frequencies struct {
+	mutex sync.Mutex
+}
+
+func NewFrequencies() *Frequencies {
+	return &Frequencies{
+		mutex: sync.Mutex{},
+	}
+}
+
+func (frequencies *Frequencies) Record(db *Database, call *Call) error {
+	type usage struct {
+		errors uint
+		spikes uint
+	}
+
+	frequencies.mutex.Lock()
+	defer frequencies.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("frequencies.record: %v", err)
+	}
+
+	date := call.DateTime.UTC().Format(StatisticsDateFormat)
+
+	usages := map[uint]*usage{}
+
+	switch v := call.Frequencies.(type) {
+	case []map[string]interface{}:
+		for _, f := range v {
+			freq, ok := f["freq"].(uint)
+			if !ok || freq == 0 {
+				continue
+			}
+			u := usages[freq]
+			if u == nil {
+				u = &usage{}
+				usages[freq] = u
+			}
+			if c, ok := f["errorCount"].(uint); ok {
+				u.errors += c
+			}
+			if c, ok := f["spikeCount"].(uint); ok {
+				u.spikes += c
+			}
+		}
+	}
+
+	if len(usages) == 0 {
+		switch v := call.Frequency.(type) {
+		case uint:
+			if v > 0 {
+				usages[v] = &usage{}
+			}
+		}
+	}
+
+	for freq, u := range usages {
+		if res, err := db.Sql.Exec("update `rdioScannerFrequencies` set `calls` = `calls` + 1, `errors` = `errors` + ?, `spikes` = `spikes` + ? where `date` = ? and `system` = ? and `frequency` = ?", u.errors, u.spikes, date, call.System, freq); err != nil {
+			return formatError(err)
+
+		} else if i, err := res.RowsAffected(); err == nil && i == 0 {
+			if _, err = db.Sql.Exec("insert into `rdioScannerFrequencies` (`date`, `system`, `frequency`, `calls`, `errors`, `spikes`, `controlSamples`, `decodeRate`) values (?, ?, ?, 1, ?, ?, 0, 0)", date, call.System, freq, u.errors, u.spikes); err != nil {
+				return formatError(err)
+			}
+		}
+	}
+
+	switch freq := call.controlChannel.(type) {
+	case uint:
+		var rate float64
+
+		switch v := call.decodeRate.(type) {
+		case float64:
+			rate = v
+		}
+
+		if res, err := db.Sql.Exec("update `rdioScannerFrequencies` set `controlSamples` = `controlSamples` + 1, `decodeRate` = `decodeRate` + ? where `date` = ? and `system` = ? and `frequency` = ?", rate, date, call.System, freq); err != nil {
+			return formatError(err)
+
+		} else if i, err := res.RowsAffected(); err == nil && i == 0 {
+			if _, err = db.Sql.Exec("insert into `rdioScannerFrequencies` (`date`, `system`, `frequency`, `calls`, `errors`, `spikes`, `controlSamples`, `decodeRate`) values (?, ?, ?, 0, 0, 0, 1, ?)", date, call.System, freq, rate); err != nil {
+				return formatError(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (frequencies *Frequencies) Search(db *Database, searchOptions *StatisticsSearchOptions) ([]FrequencyUsage, error) {
+	var (
+		args      = []interface{}{}
+		err       error
+		firstSeen = map[string]string{}
+		rows      *sql.Rows
+		where     = "true"
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("frequencies.search: %v", err)
+	}
+
+	if len(searchOptions.From) > 0 {
+		where += " and `date` >= ?"
+		args = append(args, searchOptions.From)
+	}
+
+	if len(searchOptions.To) > 0 {
+		where += " and `date` <= ?"
+		args = append(args, searchOptions.To)
+	}
+
+	switch v := searchOptions.System.(type) {
+	case uint:
+		where += " and `system` = ?"
+		args = append(args, v)
+	}
+
+	if rows, err = db.Sql.Query("select `system`, `frequency`, min(`date`) from `rdioScannerFrequencies` group by `system`, `frequency`"); err != nil {
+		return nil, formatError(err)
+	}
+
+	for rows.Next() {
+		var (
+			date      string
+			frequency uint
+			system    uint
+		)
+
+		if err = rows.Scan(&system, &frequency, &date); err != nil {
+			break
+		}
+
+		firstSeen[fmt.Sprintf("%d:%d", system, frequency)] = date
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	query := fmt.Sprintf("select `date`, `system`, `frequency`, `calls`, `errors`, `spikes`, `controlSamples`, `decodeRate` from `rdioScannerFrequencies` where %s order by `date`, `system`, `frequency`", where)
+	if rows, err = db.Sql.Query(query, args...); err != nil {
+		return nil, formatError(err)
+	}
+	defer rows.Close()
+
+	list := []FrequencyUsage{}
+
+	for rows.Next() {
+		var decodeRate float64
+
+		usage := FrequencyUsage{}
+
+		if err = rows.Scan(&usage.Date, &usage.System, &usage.Frequency, &usage.Calls, &usage.Errors, &usage.Spikes, &usage.ControlSamples, &decodeRate); err != nil {
+			return nil, formatError(err)
+		}
+
+		if usage.ControlSamples > 0 {
+			usage.DecodeRate = decodeRate / float64(usage.ControlSamples)
+		}
+
+		usage.FirstSeen = firstSeen[fmt.Sprintf("%d:%d", usage.System, usage.Frequency)]
+
+		list = append(list, usage)
+	}
+
+	return list, rows.Err()
+}
@@ -3,6 +3,8 @@ module rdio-scanner/server
 go 1.17
 
 require (
+	github.com/BurntSushi/toml v1.2.0
+	github.com/beevik/etree v1.1.0
 	github.com/dhowden/tag v0.0.0-20201120070457-d52dcb253c63
 	github.com/fsnotify/fsnotify v1.5.4
 	github.com/go-sql-driver/mysql v1.6.0
@@ -10,12 +12,15 @@ require (
 	github.com/google/uuid v1.3.0
 	github.com/gorilla/websocket v1.5.0
 	github.com/kardianos/service v1.2.1
+	github.com/russellhaering/goxmldsig v1.3.0
 	golang.org/x/crypto v0.0.0-20220518034528-6f7dac969898
 	gopkg.in/ini.v1 v1.66.4
+	gopkg.in/yaml.v3 v3.0.1
 	modernc.org/sqlite v1.17.2
 )
 
 require (
+	github.com/jonboulle/clockwork v0.2.2 // indirect
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51 // indirect
 	github.com/mattn/go-isatty v0.0.14 // indirect
 	github.com/remyoudompheng/bigfft v0.0.0-20200410134404-eec4a21b6bb0 // indirect
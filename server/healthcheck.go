@@ -0,0 +1,60 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+func Healthcheck(config *Config) error {
+	var (
+		host string
+		port string
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("healthcheck: %v", err)
+	}
+
+	if h, p, err := net.SplitHostPort(config.Listen); err == nil {
+		host = h
+		port = p
+	} else {
+		host = config.Listen
+		port = "3000"
+	}
+
+	if len(host) == 0 || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+
+	c := http.Client{Timeout: 5 * time.Second}
+
+	res, err := c.Get(fmt.Sprintf("http://%s%s/api/health", net.JoinHostPort(host, port), config.GetBasePath()))
+	if err != nil {
+		return formatError(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return formatError(fmt.Errorf("bad status: %s", res.Status))
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const HeartbeatMaxBodySize = 64 * 1024
+
+type Heartbeat struct {
+	Address  string      `json:"address"`
+	Apikey   interface{} `json:"apikey"`
+	Ident    string      `json:"ident"`
+	LastSeen time.Time   `json:"lastSeen"`
+	Silent   bool        `json:"silent"`
+	Status   interface{} `json:"status,omitempty"`
+}
+
+type Heartbeats struct {
+	Map   map[string]*Heartbeat
+	mutex sync.Mutex
+}
+
+func NewHeartbeats() *Heartbeats {
+	return &Heartbeats{
+		Map:   map[string]*Heartbeat{},
+		mutex: sync.Mutex{},
+	}
+}
+
+func (heartbeats *Heartbeats) Beat(apikey *Apikey, address string, status interface{}) bool {
+	heartbeats.mutex.Lock()
+	defer heartbeats.mutex.Unlock()
+
+	heartbeat, ok := heartbeats.Map[apikey.Key]
+	if !ok {
+		heartbeat = &Heartbeat{}
+		heartbeats.Map[apikey.Key] = heartbeat
+	}
+
+	recovered := heartbeat.Silent
+
+	heartbeat.Address = address
+	heartbeat.Apikey = apikey.Id
+	heartbeat.Ident = apikey.Ident
+	heartbeat.LastSeen = time.Now()
+	heartbeat.Silent = false
+	heartbeat.Status = status
+
+	return recovered
+}
+
+func (heartbeats *Heartbeats) Check(timeout uint) []Heartbeat {
+	heartbeats.mutex.Lock()
+	defer heartbeats.mutex.Unlock()
+
+	silent := []Heartbeat{}
+
+	if timeout == 0 {
+		return silent
+	}
+
+	for _, heartbeat := range heartbeats.Map {
+		if !heartbeat.Silent && time.Since(heartbeat.LastSeen) >= time.Duration(timeout)*time.Second {
+			heartbeat.Silent = true
+			silent = append(silent, *heartbeat)
+		}
+	}
+
+	return silent
+}
+
+func (heartbeats *Heartbeats) GetList() []Heartbeat {
+	heartbeats.mutex.Lock()
+	defer heartbeats.mutex.Unlock()
+
+	list := []Heartbeat{}
+
+	for _, heartbeat := range heartbeats.Map {
+		list = append(list, *heartbeat)
+	}
+
+	sort.Slice(list, func(i int, j int) bool {
+		return list[i].Ident < list[j].Ident
+	})
+
+	return list
+}
+
+func (admin *Admin) HeartbeatsHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if b, err := json.Marshal(admin.Controller.Heartbeats.GetList()); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.heartbeatshandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (api *Api) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		key    string
+		status interface{}
+	)
+
+	switch r.Method {
+	case http.MethodGet, http.MethodPost:
+		key = r.Header.Get("X-Api-Key")
+
+		if len(key) == 0 {
+			key = r.URL.Query().Get("key")
+		}
+
+		if r.Method == http.MethodPost {
+			m := map[string]interface{}{}
+
+			b, err := io.ReadAll(io.LimitReader(r.Body, HeartbeatMaxBodySize))
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("Invalid body\n"))
+				return
+			}
+
+			if len(b) > 0 {
+				if err = json.Unmarshal(b, &m); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte("Invalid JSON\n"))
+					return
+				}
+			}
+
+			switch v := m["key"].(type) {
+			case string:
+				if len(key) == 0 {
+					key = v
+				}
+			}
+
+			status = m["status"]
+		}
+
+		apikey, ok := api.Controller.Apikeys.GetApikey(key)
+		if !ok || !apikey.HasScope(ApikeyScopeUpload) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Invalid API key\n"))
+			return
+		}
+
+		if api.Controller.Heartbeats.Beat(apikey, GetRemoteAddr(r), status) {
+			api.Controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("heartbeat: recorder %s is back online", apikey.Ident))
+			api.Controller.Admin.BroadcastNotice(map[string]interface{}{
+				"ident": apikey.Ident,
+				"type":  "heartbeat",
+			})
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write([]byte("OK\n"))
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		w.Write([]byte("Unsupported method\n"))
+	}
+}
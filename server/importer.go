@@ -0,0 +1,475 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ImportDefaultExtensions = "aac,flac,m4a,mp3,ogg,opus,wav"
+	ImportTimeout           = 2 * time.Minute
+)
+
+type ImportEntry struct {
+	fields map[string]string
+	file   string
+	meta   string
+}
+
+type ImportReport struct {
+	Failed   []string  `json:"failed"`
+	Finished time.Time `json:"finished"`
+	Imported uint      `json:"imported"`
+	Queued   uint      `json:"queued"`
+	Rejected []string  `json:"rejected"`
+	Resumed  uint      `json:"resumed"`
+	Skipped  []string  `json:"skipped"`
+	Started  time.Time `json:"started"`
+}
+
+func (command *Command) importCalls() {
+	if command.in == "" && command.csv == "" {
+		command.exitWithError(fmt.Sprintf("Missing %s <directory> or %s <file.csv> arguments.", COMMAND_ARG_IN, COMMAND_ARG_CSV))
+	}
+	if command.key == "" {
+		command.exitWithError(fmt.Sprintf("Missing %s <apikey> arguments.", COMMAND_ARG_KEY))
+	}
+
+	var (
+		entries []*ImportEntry
+		err     error
+	)
+
+	if command.csv != "" {
+		entries, err = command.importReadCsv()
+	} else {
+		entries, err = command.importWalkDir()
+	}
+	if err != nil {
+		command.exitWithError(err)
+	}
+
+	done, err := command.importReadState()
+	if err != nil {
+		command.exitWithError(err)
+	}
+
+	state, err := os.OpenFile(command.state, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		command.exitWithError(err)
+	}
+	defer state.Close()
+
+	client := &http.Client{Timeout: ImportTimeout}
+
+	report := &ImportReport{
+		Failed:   []string{},
+		Rejected: []string{},
+		Skipped:  []string{},
+		Started:  time.Now().UTC(),
+	}
+
+	markDone := func(entry *ImportEntry) {
+		if _, err := state.WriteString(entry.file + "\n"); err != nil {
+			command.exitWithError(err)
+		}
+	}
+
+	fmt.Printf("Importing %d files from %s.\n", len(entries), command.url)
+
+	for _, entry := range entries {
+		if done[entry.file] {
+			report.Resumed++
+			continue
+		}
+
+		if entry.meta == "" {
+			if _, ok := entry.fields["system"]; !ok {
+				report.Skipped = append(report.Skipped, fmt.Sprintf("%s: no system", entry.file))
+				fmt.Printf("skipped  %s: no system\n", entry.file)
+				continue
+			}
+			if _, ok := entry.fields["talkgroup"]; !ok {
+				report.Skipped = append(report.Skipped, fmt.Sprintf("%s: no talkgroup", entry.file))
+				fmt.Printf("skipped  %s: no talkgroup\n", entry.file)
+				continue
+			}
+		}
+
+		status, message, err := command.importUpload(client, entry)
+
+		switch {
+		case err != nil:
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %s", entry.file, err.Error()))
+			fmt.Printf("failed   %s: %s\n", entry.file, err.Error())
+
+		case status == http.StatusOK:
+			report.Imported++
+			markDone(entry)
+			fmt.Printf("imported %s\n", entry.file)
+
+		case status == http.StatusAccepted:
+			report.Queued++
+			markDone(entry)
+			fmt.Printf("queued   %s\n", entry.file)
+
+		case status == http.StatusExpectationFailed || status == http.StatusRequestEntityTooLarge:
+			report.Rejected = append(report.Rejected, fmt.Sprintf("%s: %s", entry.file, message))
+			markDone(entry)
+			fmt.Printf("rejected %s: %s\n", entry.file, message)
+
+		case status == http.StatusForbidden:
+			command.exitWithError(message)
+
+		default:
+			report.Failed = append(report.Failed, fmt.Sprintf("%s: %s", entry.file, message))
+			fmt.Printf("failed   %s: %s\n", entry.file, message)
+		}
+	}
+
+	report.Finished = time.Now().UTC()
+
+	fmt.Printf("\nImport Summary:\n\n")
+	fmt.Printf("  %-9s %d\n", "Files", len(entries))
+	fmt.Printf("  %-9s %d\n", "Imported", report.Imported)
+	fmt.Printf("  %-9s %d\n", "Queued", report.Queued)
+	fmt.Printf("  %-9s %d\n", "Rejected", len(report.Rejected))
+	fmt.Printf("  %-9s %d\n", "Skipped", len(report.Skipped))
+	fmt.Printf("  %-9s %d\n", "Failed", len(report.Failed))
+	fmt.Printf("  %-9s %d\n", "Resumed", report.Resumed)
+	fmt.Printf("  %-9s %v\n\n", "Duration", report.Finished.Sub(report.Started).Round(time.Second))
+
+	if len(report.Failed) > 0 {
+		fmt.Printf("Failed files are retried on the next run.\n")
+	}
+
+	if command.out != "" {
+		if f, err := os.Create(command.out); err == nil {
+			j := json.NewEncoder(f)
+			j.SetIndent("", "  ")
+			j.Encode(report)
+			f.Close()
+			fmt.Printf("Import report saved to %s.\n", command.out)
+		} else {
+			command.exitWithError(err)
+		}
+	}
+}
+
+func (command *Command) importEntry(file string, fields map[string]string) (*ImportEntry, error) {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &ImportEntry{fields: fields, file: file}
+
+	setField := func(key string, value string) {
+		if _, ok := entry.fields[key]; !ok && len(value) > 0 && value != "-" {
+			entry.fields[key] = value
+		}
+	}
+
+	if meta := strings.TrimSuffix(file, path.Ext(file)) + ".json"; meta != file {
+		if _, err := os.Stat(meta); err == nil {
+			entry.meta = meta
+		}
+	}
+
+	if command.mask != "" {
+		call := NewCall()
+		call.AudioName = filepath.Base(file)
+
+		(&Dirwatch{Mask: command.mask}).parseMask(call)
+
+		if !call.DateTime.IsZero() {
+			setField("dateTime", call.DateTime.Format(time.RFC3339))
+		}
+
+		if call.System > 0 {
+			setField("system", strconv.Itoa(int(call.System)))
+		}
+
+		if call.Talkgroup > 0 {
+			setField("talkgroup", strconv.Itoa(int(call.Talkgroup)))
+		}
+
+		switch v := call.Frequency.(type) {
+		case uint:
+			if v > 0 {
+				setField("frequency", strconv.Itoa(int(v)))
+			}
+		}
+
+		switch v := call.Sources.(type) {
+		case []map[string]interface{}:
+			if len(v) > 0 {
+				setField("source", fmt.Sprintf("%v", v[0]["src"]))
+			}
+		}
+
+		for key, value := range map[string]interface{}{
+			"systemLabel":    call.systemLabel,
+			"talkgroupGroup": call.talkgroupGroup,
+			"talkgroupLabel": call.talkgroupLabel,
+			"talkgroupTag":   call.talkgroupTag,
+		} {
+			switch v := value.(type) {
+			case string:
+				setField(key, v)
+			}
+		}
+	}
+
+	setField("system", command.system)
+	setField("talkgroup", command.talkgroup)
+
+	if entry.meta == "" {
+		setField("dateTime", fi.ModTime().UTC().Format(time.RFC3339))
+	}
+
+	return entry, nil
+}
+
+func (command *Command) importExtensions() map[string]bool {
+	exts := command.ext
+
+	if exts == "" {
+		exts = ImportDefaultExtensions
+	}
+
+	m := map[string]bool{}
+	for _, ext := range strings.Split(exts, ",") {
+		if ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), ".")); len(ext) > 0 {
+			m["."+ext] = true
+		}
+	}
+
+	return m
+}
+
+func (command *Command) importReadCsv() ([]*ImportEntry, error) {
+	entries := []*ImportEntry{}
+
+	f, err := os.Open(command.csv)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir := command.in
+	if dir == "" {
+		dir = filepath.Dir(command.csv)
+	}
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	fileCol := -1
+	for i, h := range header {
+		header[i] = strings.TrimSpace(h)
+		if strings.EqualFold(header[i], "file") {
+			fileCol = i
+		}
+	}
+
+	if fileCol == -1 {
+		return nil, errors.New("CSV file has no file column")
+	}
+
+	for line := 2; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if fileCol >= len(record) || len(strings.TrimSpace(record[fileCol])) == 0 {
+			continue
+		}
+
+		fields := map[string]string{}
+		for i, v := range record {
+			if i != fileCol && i < len(header) && len(strings.TrimSpace(v)) > 0 {
+				fields[header[i]] = strings.TrimSpace(v)
+			}
+		}
+
+		file := strings.TrimSpace(record[fileCol])
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(dir, file)
+		}
+
+		if file, err = filepath.Abs(file); err != nil {
+			return nil, err
+		}
+
+		if entry, err := command.importEntry(file, fields); err == nil {
+			entries = append(entries, entry)
+		} else {
+			fmt.Printf("line %d: %s\n", line, err.Error())
+		}
+	}
+
+	return entries, nil
+}
+
+func (command *Command) importReadState() (map[string]bool, error) {
+	done := map[string]bool{}
+
+	f, err := os.Open(command.state)
+	if os.IsNotExist(err) {
+		return done, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if s := strings.TrimSpace(scanner.Text()); len(s) > 0 {
+			done[s] = true
+		}
+	}
+
+	return done, scanner.Err()
+}
+
+func (command *Command) importUpload(client *http.Client, entry *ImportEntry) (int, string, error) {
+	var (
+		body = &bytes.Buffer{}
+		url  = "/api/call-upload"
+	)
+
+	mw := multipart.NewWriter(body)
+
+	fields := map[string]string{
+		"historical": "true",
+		"key":        command.key,
+	}
+
+	if entry.meta != "" {
+		b, err := os.ReadFile(entry.meta)
+		if err != nil {
+			return 0, "", err
+		}
+
+		fields["meta"] = string(b)
+
+		url = "/api/trunk-recorder-call-upload"
+	}
+
+	for k, v := range entry.fields {
+		if _, ok := fields[k]; !ok {
+			fields[k] = v
+		}
+	}
+
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return 0, "", err
+		}
+	}
+
+	f, err := os.Open(entry.file)
+	if err != nil {
+		return 0, "", err
+	}
+
+	part, err := mw.CreateFormFile("audio", filepath.Base(entry.file))
+	if err == nil {
+		_, err = io.Copy(part, f)
+	}
+
+	f.Close()
+
+	if err != nil {
+		return 0, "", err
+	}
+
+	if err = mw.Close(); err != nil {
+		return 0, "", err
+	}
+
+	res, err := client.Post(strings.TrimSuffix(command.url, "/")+url, mw.FormDataContentType(), body)
+	if err != nil {
+		return 0, "", err
+	}
+	defer res.Body.Close()
+
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+
+	message := strings.TrimSpace(string(b))
+	if len(message) == 0 {
+		message = res.Status
+	}
+
+	return res.StatusCode, message, nil
+}
+
+func (command *Command) importWalkDir() ([]*ImportEntry, error) {
+	entries := []*ImportEntry{}
+
+	exts := command.importExtensions()
+
+	dir, err := filepath.Abs(command.in)
+	if err != nil {
+		return nil, err
+	}
+
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !exts[strings.ToLower(filepath.Ext(p))] {
+			return nil
+		}
+
+		if entry, err := command.importEntry(p, map[string]string{}); err == nil {
+			entries = append(entries, entry)
+		} else {
+			fmt.Printf("%s: %s\n", p, err.Error())
+		}
+
+		return nil
+	})
+
+	return entries, err
+}
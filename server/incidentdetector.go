@@ -0,0 +1,252 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	IncidentDetectorBaselineHours = 168
+	IncidentDetectorCreatedBy     = "detector"
+	IncidentDetectorInterval      = time.Minute
+)
+
+type IncidentDetector struct {
+	controller *Controller
+	active     map[string]*incidentDetection
+	mutex      sync.Mutex
+	ticker     *time.Ticker
+}
+
+type incidentDetection struct {
+	id   uint
+	last time.Time
+}
+
+type incidentDetectorCluster struct {
+	baseline float64
+	calls    []uint
+	first    uint
+	key      string
+	label    string
+	second   uint
+}
+
+func NewIncidentDetector(controller *Controller) *IncidentDetector {
+	return &IncidentDetector{
+		controller: controller,
+		active:     map[string]*incidentDetection{},
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (detector *IncidentDetector) Detect(now time.Time) error {
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+
+	controller := detector.controller
+	db := controller.Database
+
+	formatError := func(err error) error {
+		return fmt.Errorf("incidentdetector.detect: %v", err)
+	}
+
+	window := time.Duration(detector.getWindow()) * time.Minute
+	threshold := float64(controller.Options.IncidentDetectionThreshold)
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	keys, labels := detector.getClusters()
+
+	clusters := map[string]*incidentDetectorCluster{}
+
+	getCluster := func(ref TalkgroupRef) *incidentDetectorCluster {
+		key, ok := keys[ref]
+		if !ok {
+			return nil
+		}
+		if clusters[key] == nil {
+			clusters[key] = &incidentDetectorCluster{key: key, label: labels[key]}
+		}
+		return clusters[key]
+	}
+
+	hour := now.UTC().Truncate(time.Hour).Unix() / 3600
+
+	rows, err := db.Sql.Query("select `system`, `talkgroup`, sum(`calls`) from `rdioScannerCallStats` where `hour` >= ? and `hour` < ? group by `system`, `talkgroup`", hour-IncidentDetectorBaselineHours, hour)
+	if err != nil {
+		return formatError(err)
+	}
+	for rows.Next() {
+		var (
+			calls float64
+			ref   TalkgroupRef
+		)
+		if err = rows.Scan(&ref.System, &ref.Talkgroup, &calls); err != nil {
+			rows.Close()
+			return formatError(err)
+		}
+		if cluster := getCluster(ref); cluster != nil {
+			cluster.baseline += calls / IncidentDetectorBaselineHours * window.Hours()
+		}
+	}
+	rows.Close()
+
+	from := now.Add(-window)
+	middle := now.Add(-window / 2)
+
+	if rows, err = db.Sql.Query("select `id`, `dateTime`, `system`, `talkgroup` from `rdioScannerCalls` where `dateTime` >= ? order by `dateTime` asc", from.UTC().Format(db.DateTimeFormat)); err != nil {
+		return formatError(err)
+	}
+	for rows.Next() {
+		var (
+			dateTime interface{}
+			id       uint
+			ref      TalkgroupRef
+		)
+		if err = rows.Scan(&id, &dateTime, &ref.System, &ref.Talkgroup); err != nil {
+			rows.Close()
+			return formatError(err)
+		}
+		cluster := getCluster(ref)
+		if cluster == nil {
+			continue
+		}
+		cluster.calls = append(cluster.calls, id)
+		if t, err := db.ParseDateTime(dateTime); err == nil && t.Before(middle) {
+			cluster.first++
+		} else {
+			cluster.second++
+		}
+	}
+	rows.Close()
+
+	for key, detection := range detector.active {
+		if now.Sub(detection.last) > 2*window {
+			delete(detector.active, key)
+		}
+	}
+
+	for _, cluster := range clusters {
+		if !detector.isTriggered(cluster, threshold) {
+			continue
+		}
+
+		if detection := detector.active[cluster.key]; detection != nil {
+			if _, err := controller.Incidents.AttachCalls(detection.id, cluster.calls); err == nil {
+				detection.last = now
+				continue
+			}
+		}
+
+		incident := NewIncident()
+		incident.CreatedBy = IncidentDetectorCreatedBy
+		incident.Status = IncidentStatusDraft
+		incident.Title = fmt.Sprintf("%s, %s", cluster.label, now.UTC().Format("2006-01-02 15:04 UTC"))
+		incident.Description = fmt.Sprintf("%d calls in %d minutes while %.1f are expected.", len(cluster.calls), detector.getWindow(), cluster.baseline)
+
+		if err = controller.Incidents.Save(incident); err != nil {
+			return formatError(err)
+		}
+
+		id, _ := incident.Id.(uint)
+
+		if _, err = controller.Incidents.AttachCalls(id, cluster.calls); err != nil {
+			return formatError(err)
+		}
+
+		detector.active[cluster.key] = &incidentDetection{id: id, last: now}
+
+		controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("incident detector: draft incident %d created for %s", id, cluster.label))
+	}
+
+	return nil
+}
+
+func (detector *IncidentDetector) Start() {
+	run := func() {
+		controller := detector.controller
+
+		if !controller.Options.IncidentDetection {
+			return
+		}
+
+		if ok, err := controller.Leases.Acquire(controller.Database, "incidentDetector", LeaseTimeout); !ok {
+			if err != nil {
+				controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("incidentdetector.start: %v", err))
+			}
+			return
+		}
+
+		if err := detector.Detect(time.Now()); err != nil {
+			controller.Logs.LogEvent(LogLevelError, err.Error())
+		}
+	}
+
+	detector.ticker = time.NewTicker(IncidentDetectorInterval)
+
+	go func() {
+		for range detector.ticker.C {
+			run()
+		}
+	}()
+}
+
+func (detector *IncidentDetector) getClusters() (map[TalkgroupRef]string, map[string]string) {
+	keys := map[TalkgroupRef]string{}
+	labels := map[string]string{}
+
+	for _, system := range detector.controller.Systems.List {
+		for _, talkgroup := range system.Talkgroups.List {
+			var key, label string
+
+			if group, ok := detector.controller.Groups.GetGroup(talkgroup.GroupId); ok {
+				key = fmt.Sprintf("%d:g%d", system.Id, talkgroup.GroupId)
+				label = fmt.Sprintf("%s %s", system.Label, group.Label)
+			} else {
+				key = fmt.Sprintf("%d:t%d", system.Id, talkgroup.Id)
+				label = fmt.Sprintf("%s %s", system.Label, talkgroup.Label)
+			}
+
+			keys[TalkgroupRef{System: system.Id, Talkgroup: talkgroup.Id}] = key
+			labels[key] = strings.TrimSpace(label)
+		}
+	}
+
+	return keys, labels
+}
+
+func (detector *IncidentDetector) getWindow() uint {
+	if window := detector.controller.Options.IncidentDetectionWindow; window > 0 {
+		return window
+	}
+	return 1
+}
+
+func (detector *IncidentDetector) isTriggered(cluster *incidentDetectorCluster, threshold float64) bool {
+	if uint(len(cluster.calls)) < detector.controller.Options.IncidentDetectionMinCalls {
+		return false
+	}
+
+	half := cluster.baseline / 2 * threshold
+
+	return float64(cluster.first) > half && float64(cluster.second) > half
+}
@@ -0,0 +1,989 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"archive/zip"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	IncidentCallsMax     = 5000
+	IncidentStatusClosed = "closed"
+	IncidentStatusDraft  = "draft"
+	IncidentStatusOpen   = "open"
+)
+
+type Incident struct {
+	Id          interface{}    `json:"_id"`
+	Calls       []IncidentCall `json:"calls,omitempty"`
+	CallsCount  uint           `json:"callsCount"`
+	CreatedBy   string         `json:"createdBy"`
+	DateTime    time.Time      `json:"dateTime"`
+	Description string         `json:"description"`
+	Notes       []IncidentNote `json:"notes,omitempty"`
+	Status      string         `json:"status"`
+	Title       string         `json:"title"`
+	Updated     time.Time      `json:"updated"`
+}
+
+type IncidentCall struct {
+	CallId         uint      `json:"callId"`
+	DateTime       time.Time `json:"dateTime"`
+	Duration       uint      `json:"duration,omitempty"`
+	Source         uint      `json:"source,omitempty"`
+	SourceLabel    string    `json:"sourceLabel,omitempty"`
+	System         uint      `json:"system"`
+	SystemLabel    string    `json:"systemLabel"`
+	Talkgroup      uint      `json:"talkgroup"`
+	TalkgroupLabel string    `json:"talkgroupLabel"`
+	Transcript     string    `json:"transcript,omitempty"`
+}
+
+type IncidentCallsFilter struct {
+	From      time.Time `json:"from"`
+	System    uint      `json:"system"`
+	Talkgroup uint      `json:"talkgroup"`
+	To        time.Time `json:"to"`
+}
+
+type IncidentNote struct {
+	Id       interface{} `json:"_id"`
+	Author   string      `json:"author"`
+	DateTime time.Time   `json:"dateTime"`
+	Note     string      `json:"note"`
+}
+
+type Incidents struct {
+	controller *Controller
+	mutex      sync.Mutex
+}
+
+func NewIncident() *Incident {
+	return &Incident{Status: IncidentStatusOpen}
+}
+
+func (incident *Incident) FromMap(m map[string]interface{}) *Incident {
+	switch v := m["_id"].(type) {
+	case float64:
+		incident.Id = uint(v)
+	}
+
+	switch v := m["description"].(type) {
+	case string:
+		incident.Description = v
+	}
+
+	switch v := m["status"].(type) {
+	case string:
+		switch v {
+		case IncidentStatusClosed, IncidentStatusDraft, IncidentStatusOpen:
+			incident.Status = v
+		}
+	}
+
+	switch v := m["title"].(type) {
+	case string:
+		incident.Title = strings.TrimSpace(v)
+	}
+
+	return incident
+}
+
+func NewIncidents(controller *Controller) *Incidents {
+	return &Incidents{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (incidents *Incidents) AddNote(id uint, author string, note string) (*IncidentNote, error) {
+	incidents.mutex.Lock()
+	defer incidents.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("incidents.addnote: %v", err)
+	}
+
+	if len(strings.TrimSpace(note)) == 0 {
+		return nil, errors.New("empty note")
+	}
+
+	if ok, err := incidents.exists(id); err != nil {
+		return nil, formatError(err)
+	} else if !ok {
+		return nil, errors.New("incident not found")
+	}
+
+	n := &IncidentNote{
+		Author:   author,
+		DateTime: time.Now().UTC(),
+		Note:     note,
+	}
+
+	db := incidents.controller.Database
+
+	res, err := db.Sql.Exec("insert into `rdioScannerIncidentNotes` (`author`, `dateTime`, `incidentId`, `note`) values (?, ?, ?, ?)", n.Author, n.DateTime, id, n.Note)
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	if i, err := res.LastInsertId(); err == nil {
+		n.Id = uint(i)
+	}
+
+	incidents.touch(id)
+
+	return n, nil
+}
+
+func (incidents *Incidents) AttachCalls(id uint, callIds []uint) (int, error) {
+	var count int
+
+	incidents.mutex.Lock()
+	defer incidents.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("incidents.attachcalls: %v", err)
+	}
+
+	if ok, err := incidents.exists(id); err != nil {
+		return 0, formatError(err)
+	} else if !ok {
+		return 0, errors.New("incident not found")
+	}
+
+	db := incidents.controller.Database
+
+	attached := map[uint]bool{}
+
+	rows, err := db.Sql.Query("select `callId` from `rdioScannerIncidentCalls` where `incidentId` = ?", id)
+	if err != nil {
+		return 0, formatError(err)
+	}
+	for rows.Next() {
+		var callId uint
+		if err = rows.Scan(&callId); err == nil {
+			attached[callId] = true
+		}
+	}
+	rows.Close()
+
+	tx, err := db.Sql.Begin()
+	if err != nil {
+		return 0, formatError(err)
+	}
+
+	for _, callId := range callIds {
+		if attached[callId] {
+			continue
+		}
+
+		if len(attached) >= IncidentCallsMax {
+			break
+		}
+
+		var exists int
+		if err = tx.QueryRow("select count(*) from `rdioScannerCalls` where `id` = ?", callId).Scan(&exists); err != nil {
+			tx.Rollback()
+			return 0, formatError(err)
+		} else if exists == 0 {
+			continue
+		}
+
+		if _, err = tx.Exec("insert into `rdioScannerIncidentCalls` (`callId`, `incidentId`) values (?, ?)", callId, id); err != nil {
+			tx.Rollback()
+			return 0, formatError(err)
+		}
+
+		attached[callId] = true
+		count++
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, formatError(err)
+	}
+
+	if count > 0 {
+		incidents.touch(id)
+	}
+
+	return count, nil
+}
+
+func (incidents *Incidents) AttachFilter(id uint, filter *IncidentCallsFilter) (int, error) {
+	formatError := func(err error) error {
+		return fmt.Errorf("incidents.attachfilter: %v", err)
+	}
+
+	if filter.From.IsZero() || filter.To.IsZero() || !filter.To.After(filter.From) {
+		return 0, errors.New("invalid time range")
+	}
+
+	db := incidents.controller.Database
+
+	where := "`dateTime` between ? and ?"
+	args := []interface{}{filter.From.Format(db.DateTimeFormat), filter.To.Format(db.DateTimeFormat)}
+
+	if filter.System > 0 {
+		where += " and `system` = ?"
+		args = append(args, filter.System)
+
+		if filter.Talkgroup > 0 {
+			where += " and `talkgroup` = ?"
+			args = append(args, filter.Talkgroup)
+		}
+	}
+
+	rows, err := db.Sql.Query(fmt.Sprintf("select `id` from `rdioScannerCalls` where %s order by `dateTime` asc limit %d", where, IncidentCallsMax), args...)
+	if err != nil {
+		return 0, formatError(err)
+	}
+
+	callIds := []uint{}
+	for rows.Next() {
+		var callId uint
+		if err = rows.Scan(&callId); err == nil {
+			callIds = append(callIds, callId)
+		}
+	}
+	rows.Close()
+
+	return incidents.AttachCalls(id, callIds)
+}
+
+func (incidents *Incidents) Delete(id uint) (bool, error) {
+	incidents.mutex.Lock()
+	defer incidents.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("incidents.delete: %v", err)
+	}
+
+	db := incidents.controller.Database
+
+	tx, err := db.Sql.Begin()
+	if err != nil {
+		return false, formatError(err)
+	}
+
+	res, err := tx.Exec("delete from `rdioScannerIncidents` where `_id` = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return false, formatError(err)
+	}
+
+	for _, query := range []string{
+		"delete from `rdioScannerIncidentCalls` where `incidentId` = ?",
+		"delete from `rdioScannerIncidentNotes` where `incidentId` = ?",
+	} {
+		if _, err = tx.Exec(query, id); err != nil {
+			tx.Rollback()
+			return false, formatError(err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, formatError(err)
+	}
+
+	count, _ := res.RowsAffected()
+
+	return count > 0, nil
+}
+
+func (incidents *Incidents) DeleteNote(id uint, noteId uint) (bool, error) {
+	incidents.mutex.Lock()
+	defer incidents.mutex.Unlock()
+
+	res, err := incidents.controller.Database.Sql.Exec("delete from `rdioScannerIncidentNotes` where `_id` = ? and `incidentId` = ?", noteId, id)
+	if err != nil {
+		return false, fmt.Errorf("incidents.deletenote: %v", err)
+	}
+
+	count, _ := res.RowsAffected()
+
+	return count > 0, nil
+}
+
+func (incidents *Incidents) DetachCall(id uint, callId uint) (bool, error) {
+	incidents.mutex.Lock()
+	defer incidents.mutex.Unlock()
+
+	res, err := incidents.controller.Database.Sql.Exec("delete from `rdioScannerIncidentCalls` where `incidentId` = ? and `callId` = ?", id, callId)
+	if err != nil {
+		return false, fmt.Errorf("incidents.detachcall: %v", err)
+	}
+
+	count, _ := res.RowsAffected()
+
+	if count > 0 {
+		incidents.touch(id)
+	}
+
+	return count > 0, nil
+}
+
+func (incidents *Incidents) Export(id uint, w io.Writer) error {
+	formatError := func(err error) error {
+		return fmt.Errorf("incidents.export: %v", err)
+	}
+
+	incident, err := incidents.Get(id)
+	if err != nil {
+		return formatError(err)
+	} else if incident == nil {
+		return errors.New("incident not found")
+	}
+
+	zw := zip.NewWriter(w)
+
+	files := map[uint]string{}
+
+	for _, c := range incident.Calls {
+		call, err := incidents.controller.Calls.GetCall(c.CallId, incidents.controller.Database)
+		if err != nil {
+			return formatError(err)
+		}
+
+		if len(call.Audio) == 0 {
+			continue
+		}
+
+		name := fmt.Sprintf("audio/%s-%d%s", c.DateTime.UTC().Format("20060102T150405Z"), c.CallId, getIncidentAudioExtension(call))
+
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: c.DateTime})
+		if err != nil {
+			return formatError(err)
+		}
+
+		if _, err = f.Write(call.Audio); err != nil {
+			return formatError(err)
+		}
+
+		files[c.CallId] = name
+	}
+
+	f, err := zw.Create("timeline.csv")
+	if err != nil {
+		return formatError(err)
+	}
+
+	cw := csv.NewWriter(f)
+
+	cw.Write([]string{"dateTime", "offset", "callId", "system", "systemLabel", "talkgroup", "talkgroupLabel", "source", "sourceLabel", "duration", "file", "transcript"})
+
+	for _, c := range incident.Calls {
+		var offset time.Duration
+		if len(incident.Calls) > 0 {
+			offset = c.DateTime.Sub(incident.Calls[0].DateTime)
+		}
+
+		cw.Write([]string{
+			c.DateTime.UTC().Format(time.RFC3339),
+			strconv.FormatFloat(offset.Seconds(), 'f', 0, 64),
+			fmt.Sprint(c.CallId),
+			fmt.Sprint(c.System),
+			c.SystemLabel,
+			fmt.Sprint(c.Talkgroup),
+			c.TalkgroupLabel,
+			fmt.Sprint(c.Source),
+			c.SourceLabel,
+			fmt.Sprint(c.Duration),
+			files[c.CallId],
+			c.Transcript,
+		})
+	}
+
+	cw.Flush()
+	if err = cw.Error(); err != nil {
+		return formatError(err)
+	}
+
+	if f, err = zw.Create("incident.json"); err != nil {
+		return formatError(err)
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err = enc.Encode(incident); err != nil {
+		return formatError(err)
+	}
+
+	return zw.Close()
+}
+
+func (incidents *Incidents) Get(id uint) (*Incident, error) {
+	var (
+		callDateTime interface{}
+		dateTime     interface{}
+		duration     sql.NullFloat64
+		noteId       sql.NullFloat64
+		source       sql.NullFloat64
+		transcript   sql.NullString
+	)
+
+	incidents.mutex.Lock()
+	defer incidents.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("incidents.get: %v", err)
+	}
+
+	list, err := incidents.list("i.`_id` = ?", id)
+	if err != nil {
+		return nil, formatError(err)
+	} else if len(list) == 0 {
+		return nil, nil
+	}
+
+	incident := list[0]
+	incident.Calls = []IncidentCall{}
+	incident.Notes = []IncidentNote{}
+
+	db := incidents.controller.Database
+
+	rows, err := db.Sql.Query("select c.`id`, c.`dateTime`, c.`duration`, c.`source`, c.`system`, c.`talkgroup`, c.`transcript` from `rdioScannerIncidentCalls` as ic inner join `rdioScannerCalls` as c on c.`id` = ic.`callId` where ic.`incidentId` = ? order by c.`dateTime` asc", id)
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	for rows.Next() {
+		c := IncidentCall{}
+
+		if err = rows.Scan(&c.CallId, &callDateTime, &duration, &source, &c.System, &c.Talkgroup, &transcript); err != nil {
+			rows.Close()
+			return nil, formatError(err)
+		}
+
+		if t, err := db.ParseDateTime(callDateTime); err == nil {
+			c.DateTime = t
+		}
+
+		if duration.Valid && duration.Float64 > 0 {
+			c.Duration = uint(duration.Float64)
+		}
+
+		if transcript.Valid {
+			c.Transcript = transcript.String
+		}
+
+		if system, ok := incidents.controller.Systems.GetSystem(c.System); ok {
+			c.SystemLabel = system.Label
+
+			if talkgroup, ok := system.Talkgroups.GetTalkgroup(c.Talkgroup); ok {
+				c.TalkgroupLabel = talkgroup.Label
+			}
+
+			if source.Valid && source.Float64 > 0 {
+				c.Source = uint(source.Float64)
+
+				if unit, ok := system.Units.GetUnit(c.Source); ok {
+					c.SourceLabel = unit.Label
+				}
+			}
+
+		} else if source.Valid && source.Float64 > 0 {
+			c.Source = uint(source.Float64)
+		}
+
+		incident.Calls = append(incident.Calls, c)
+	}
+	rows.Close()
+
+	if rows, err = db.Sql.Query("select `_id`, `author`, `dateTime`, `note` from `rdioScannerIncidentNotes` where `incidentId` = ? order by `dateTime` asc", id); err != nil {
+		return nil, formatError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		n := IncidentNote{}
+
+		if err = rows.Scan(&noteId, &n.Author, &dateTime, &n.Note); err != nil {
+			return nil, formatError(err)
+		}
+
+		if noteId.Valid && noteId.Float64 > 0 {
+			n.Id = uint(noteId.Float64)
+		}
+
+		if t, err := db.ParseDateTime(dateTime); err == nil {
+			n.DateTime = t
+		}
+
+		incident.Notes = append(incident.Notes, n)
+	}
+
+	return &incident, rows.Err()
+}
+
+func (incidents *Incidents) List(status string) ([]Incident, error) {
+	incidents.mutex.Lock()
+	defer incidents.mutex.Unlock()
+
+	var (
+		list []Incident
+		err  error
+	)
+
+	if len(status) > 0 {
+		list, err = incidents.list("i.`status` = ?", status)
+	} else {
+		list, err = incidents.list("true")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("incidents.list: %v", err)
+	}
+
+	return list, nil
+}
+
+func (incidents *Incidents) Save(incident *Incident) error {
+	incidents.mutex.Lock()
+	defer incidents.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("incidents.save: %v", err)
+	}
+
+	if len(incident.Title) == 0 {
+		return errors.New("no title")
+	}
+
+	db := incidents.controller.Database
+
+	incident.Updated = time.Now().UTC()
+
+	switch v := incident.Id.(type) {
+	case uint:
+		res, err := db.Sql.Exec("update `rdioScannerIncidents` set `description` = ?, `status` = ?, `title` = ?, `updated` = ? where `_id` = ?", incident.Description, incident.Status, incident.Title, incident.Updated, v)
+		if err != nil {
+			return formatError(err)
+		}
+		if count, _ := res.RowsAffected(); count == 0 {
+			return errors.New("incident not found")
+		}
+
+	default:
+		incident.DateTime = incident.Updated
+
+		res, err := db.Sql.Exec("insert into `rdioScannerIncidents` (`createdBy`, `dateTime`, `description`, `status`, `title`, `updated`) values (?, ?, ?, ?, ?, ?)", incident.CreatedBy, incident.DateTime, incident.Description, incident.Status, incident.Title, incident.Updated)
+		if err != nil {
+			return formatError(err)
+		}
+		if id, err := res.LastInsertId(); err == nil {
+			incident.Id = uint(id)
+		}
+	}
+
+	return nil
+}
+
+func (incidents *Incidents) exists(id uint) (bool, error) {
+	var count int
+
+	if err := incidents.controller.Database.Sql.QueryRow("select count(*) from `rdioScannerIncidents` where `_id` = ?", id).Scan(&count); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+func (incidents *Incidents) list(where string, args ...interface{}) ([]Incident, error) {
+	var (
+		dateTime interface{}
+		id       sql.NullFloat64
+		updated  interface{}
+	)
+
+	db := incidents.controller.Database
+
+	list := []Incident{}
+
+	query := fmt.Sprintf("select i.`_id`, i.`createdBy`, i.`dateTime`, i.`description`, i.`status`, i.`title`, i.`updated`, (select count(*) from `rdioScannerIncidentCalls` as ic where ic.`incidentId` = i.`_id`) from `rdioScannerIncidents` as i where %s order by i.`dateTime` desc", where)
+
+	rows, err := db.Sql.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%v, %v", err, query)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		incident := Incident{}
+
+		if err = rows.Scan(&id, &incident.CreatedBy, &dateTime, &incident.Description, &incident.Status, &incident.Title, &updated, &incident.CallsCount); err != nil {
+			return nil, err
+		}
+
+		if id.Valid && id.Float64 > 0 {
+			incident.Id = uint(id.Float64)
+		}
+
+		if t, err := db.ParseDateTime(dateTime); err == nil {
+			incident.DateTime = t
+		}
+
+		if t, err := db.ParseDateTime(updated); err == nil {
+			incident.Updated = t
+		}
+
+		list = append(list, incident)
+	}
+
+	return list, rows.Err()
+}
+
+func (incidents *Incidents) touch(id uint) {
+	incidents.controller.Database.Sql.Exec("update `rdioScannerIncidents` set `updated` = ? where `_id` = ?", time.Now().UTC(), id)
+}
+
+func getIncidentAudioExtension(call *Call) string {
+	switch v := call.AudioName.(type) {
+	case string:
+		if ext := path.Ext(v); len(ext) > 0 {
+			return ext
+		}
+	}
+
+	switch call.AudioType {
+	case "audio/mp4":
+		return ".m4a"
+	case "audio/mpeg":
+		return ".mp3"
+	case "audio/ogg":
+		return ".ogg"
+	case "audio/wav":
+		return ".wav"
+	}
+
+	return ".bin"
+}
+
+func (admin *Admin) IncidentsHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.incidentshandler: %s", err.Error()))
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		if _, ok := admin.ValidateTokenRole(t, AdminRoleEditor); !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if !admin.CheckWritable(w) {
+			return
+		}
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if ok, err := admin.Controller.Incidents.Delete(uint(id)); err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+		} else if !ok {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	case http.MethodGet:
+		var res interface{}
+
+		if v := r.URL.Query().Get("id"); len(v) > 0 {
+			id, err := strconv.Atoi(v)
+			if err != nil || id <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			incident, err := admin.Controller.Incidents.Get(uint(id))
+			if err != nil {
+				logError(err)
+				w.WriteHeader(http.StatusExpectationFailed)
+				return
+			} else if incident == nil {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			res = incident
+
+		} else {
+			list, err := admin.Controller.Incidents.List(r.URL.Query().Get("status"))
+			if err != nil {
+				logError(err)
+				w.WriteHeader(http.StatusExpectationFailed)
+				return
+			}
+
+			res = map[string]interface{}{"incidents": list}
+		}
+
+		if b, err := json.Marshal(res); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	case http.MethodPost:
+		var m map[string]interface{}
+
+		user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if !admin.CheckWritable(w) {
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		incident := NewIncident().FromMap(m)
+		incident.CreatedBy = user.Username
+
+		if err := admin.Controller.Incidents.Save(incident); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		if _, ok := m["_id"]; !ok {
+			admin.Controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("incident %v \"%s\" created by %s", incident.Id, incident.Title, user.Username))
+		}
+
+		if id, ok := incident.Id.(uint); ok {
+			if saved, err := admin.Controller.Incidents.Get(id); err == nil && saved != nil {
+				incident = saved
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(incident)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) IncidentCallsHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if _, ok := admin.ValidateTokenRole(t, AdminRoleEditor); !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if !admin.CheckWritable(w) {
+		return
+	}
+
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.incidentcallshandler: %s", err.Error()))
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		id, err1 := strconv.Atoi(r.URL.Query().Get("incidentId"))
+		callId, err2 := strconv.Atoi(r.URL.Query().Get("callId"))
+		if err1 != nil || err2 != nil || id <= 0 || callId <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if ok, err := admin.Controller.Incidents.DetachCall(uint(id), uint(callId)); err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+		} else if !ok {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	case http.MethodPost:
+		var (
+			count int
+			err   error
+			req   struct {
+				CallIds    []uint               `json:"callIds"`
+				Filter     *IncidentCallsFilter `json:"filter"`
+				IncidentId uint                 `json:"incidentId"`
+			}
+		)
+
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil || req.IncidentId == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if req.Filter != nil {
+			count, err = admin.Controller.Incidents.AttachFilter(req.IncidentId, req.Filter)
+		} else {
+			count, err = admin.Controller.Incidents.AttachCalls(req.IncidentId, req.CallIds)
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"attached": count})
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (admin *Admin) IncidentExportHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil || id <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	incident, err := admin.Controller.Incidents.Get(uint(id))
+	if err != nil {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.incidentexporthandler: %s", err.Error()))
+		w.WriteHeader(http.StatusExpectationFailed)
+		return
+	} else if incident == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	name := strings.Trim(regexp.MustCompile(`[^a-zA-Z0-9]+`).ReplaceAllString(strings.ToLower(incident.Title), "-"), "-")
+	filename := fmt.Sprintf("rdio-scanner-incident-%d-%s.zip", id, name)
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	if err = admin.Controller.Incidents.Export(uint(id), w); err != nil {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.incidentexporthandler: %s", err.Error()))
+	}
+}
+
+func (admin *Admin) IncidentNotesHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+	if !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if !admin.CheckWritable(w) {
+		return
+	}
+
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.incidentnoteshandler: %s", err.Error()))
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		id, err1 := strconv.Atoi(r.URL.Query().Get("incidentId"))
+		noteId, err2 := strconv.Atoi(r.URL.Query().Get("id"))
+		if err1 != nil || err2 != nil || id <= 0 || noteId <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if ok, err := admin.Controller.Incidents.DeleteNote(uint(id), uint(noteId)); err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+		} else if !ok {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	case http.MethodPost:
+		var req struct {
+			IncidentId uint   `json:"incidentId"`
+			Note       string `json:"note"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.IncidentId == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		note, err := admin.Controller.Incidents.AddNote(req.IncidentId, user.Username, req.Note)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(note)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
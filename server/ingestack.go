@@ -0,0 +1,97 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	IngestAckRetries = 3
+	IngestAckTimeout = 10 * time.Second
+)
+
+type IngestAck struct {
+	controller *Controller
+	client     *http.Client
+}
+
+func NewIngestAck(controller *Controller) *IngestAck {
+	return &IngestAck{
+		controller: controller,
+		client:     &http.Client{Timeout: IngestAckTimeout},
+	}
+}
+
+func (ack *IngestAck) Send(call *Call, status string, message string) {
+	callback := call.callback
+
+	if len(callback) == 0 || !ack.controller.Options.IngestCallbacks {
+		return
+	}
+
+	if u, err := url.Parse(callback); err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		ack.controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("ingestack.send: invalid callback url %s", callback))
+		return
+	}
+
+	b, err := json.Marshal(map[string]interface{}{
+		"audioName": call.AudioName,
+		"dateTime":  call.DateTime,
+		"id":        call.Id,
+		"message":   message,
+		"requestId": call.requestId,
+		"status":    status,
+		"system":    call.System,
+		"talkgroup": call.Talkgroup,
+	})
+	if err != nil {
+		ack.controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("ingestack.send: %v", err))
+		return
+	}
+
+	go func() {
+		var err error
+
+		for i := 0; i < IngestAckRetries; i++ {
+			if i > 0 {
+				time.Sleep(time.Duration(i*i) * time.Second)
+			}
+
+			var res *http.Response
+
+			if res, err = ack.client.Post(callback, "application/json", bytes.NewReader(b)); err == nil {
+				res.Body.Close()
+
+				if res.StatusCode < 300 {
+					return
+				} else if res.StatusCode < 500 {
+					err = fmt.Errorf("callback %s returned %s", callback, res.Status)
+					break
+				}
+
+				err = fmt.Errorf("callback %s returned %s", callback, res.Status)
+			}
+		}
+
+		ack.controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("ingestack.send: %v", err))
+	}()
+}
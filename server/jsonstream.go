@@ -0,0 +1,210 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+func WriteJsonStream(w io.Writer, v interface{}, depth int) error {
+	if depth > 0 {
+		switch m := v.(type) {
+		case json.Marshaler, []byte:
+		case map[string]interface{}:
+			return writeJsonStreamObject(w, m, depth)
+		default:
+			rv := reflect.ValueOf(v)
+			if rv.Kind() == reflect.Ptr && !rv.IsNil() {
+				if _, ok := rv.Elem().Interface().(json.Marshaler); !ok {
+					rv = rv.Elem()
+				}
+			}
+
+			switch {
+			case rv.Kind() == reflect.Slice && !rv.IsNil():
+				return writeJsonStreamArray(w, rv, depth)
+			case rv.Kind() == reflect.Struct && !hasJsonEmbeddedField(rv):
+				return writeJsonStreamStruct(w, rv, depth)
+			}
+		}
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+
+	return err
+}
+
+func writeJsonStreamArray(w io.Writer, rv reflect.Value, depth int) error {
+	if _, err := io.WriteString(w, "["); err != nil {
+		return err
+	}
+
+	for i := 0; i < rv.Len(); i++ {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+
+		if err := WriteJsonStream(w, rv.Index(i).Interface(), depth-1); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "]")
+
+	return err
+}
+
+func writeJsonStreamObject(w io.Writer, m map[string]interface{}, depth int) error {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+
+	for i, k := range keys {
+		if i > 0 {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+
+		b, err := json.Marshal(k)
+		if err != nil {
+			return err
+		}
+
+		if _, err = w.Write(append(b, ':')); err != nil {
+			return err
+		}
+
+		if err = WriteJsonStream(w, m[k], depth-1); err != nil {
+			return err
+		}
+
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+
+	_, err := io.WriteString(w, "}")
+
+	return err
+}
+
+func writeJsonStreamStruct(w io.Writer, rv reflect.Value, depth int) error {
+	first := true
+
+	if _, err := io.WriteString(w, "{"); err != nil {
+		return err
+	}
+
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if len(field.PkgPath) > 0 {
+			continue
+		}
+
+		name := field.Name
+		omitEmpty := false
+
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			a := strings.Split(tag, ",")
+			if len(a[0]) > 0 {
+				name = a[0]
+			}
+			for _, o := range a[1:] {
+				if o == "omitempty" {
+					omitEmpty = true
+				}
+			}
+		}
+
+		value := rv.Field(i)
+		if omitEmpty && isJsonEmptyValue(value) {
+			continue
+		}
+
+		if !first {
+			if _, err := io.WriteString(w, ","); err != nil {
+				return err
+			}
+		}
+		first = false
+
+		b, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+
+		if _, err = w.Write(append(b, ':')); err != nil {
+			return err
+		}
+
+		if err = WriteJsonStream(w, value.Interface(), depth-1); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "}")
+
+	return err
+}
+
+func hasJsonEmbeddedField(rv reflect.Value) bool {
+	for i := 0; i < rv.NumField(); i++ {
+		if rv.Type().Field(i).Anonymous {
+			return true
+		}
+	}
+	return false
+}
+
+func isJsonEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const LeaseTimeout = 90 * time.Minute
+
+type Leases struct {
+	Holder string
+	mutex  sync.Mutex
+}
+
+func NewLeases() *Leases {
+	holder := uuid.New().String()
+
+	if h, err := os.Hostname(); err == nil {
+		holder = fmt.Sprintf("%s-%s", h, holder[:8])
+	}
+
+	return &Leases{
+		Holder: holder,
+		mutex:  sync.Mutex{},
+	}
+}
+
+func (leases *Leases) Acquire(db *Database, name string, ttl time.Duration) (bool, error) {
+	var count uint
+
+	leases.mutex.Lock()
+	defer leases.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("leases.acquire: %v", err)
+	}
+
+	now := time.Now()
+	expires := now.Add(ttl).Unix()
+
+	res, err := db.Sql.Exec("update `rdioScannerLeases` set `holder` = ?, `expires` = ? where `name` = ? and (`holder` = ? or `expires` < ?)", leases.Holder, expires, name, leases.Holder, now.Unix())
+	if err != nil {
+		return false, formatError(err)
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n > 0 {
+		return true, nil
+	}
+
+	if _, err = db.Sql.Exec("insert into `rdioScannerLeases` (`name`, `holder`, `expires`) values (?, ?, ?)", name, leases.Holder, expires); err == nil {
+		return true, nil
+	}
+
+	if err = db.Sql.QueryRow("select count(*) from `rdioScannerLeases` where `name` = ?", name).Scan(&count); err != nil {
+		return false, formatError(err)
+	}
+
+	if count == 0 {
+		return false, formatError(fmt.Errorf("unable to acquire lease %s", name))
+	}
+
+	return false, nil
+}
+
+func (leases *Leases) Release(db *Database, name string) error {
+	leases.mutex.Lock()
+	defer leases.mutex.Unlock()
+
+	if _, err := db.Sql.Exec("delete from `rdioScannerLeases` where `name` = ? and `holder` = ?", name, leases.Holder); err != nil {
+		return fmt.Errorf("leases.release: %v", err)
+	}
+
+	return nil
+}
+
+func (leases *Leases) ReleaseAll(db *Database) error {
+	if db.Config.ReadOnly {
+		return nil
+	}
+
+	leases.mutex.Lock()
+	defer leases.mutex.Unlock()
+
+	if _, err := db.Sql.Exec("delete from `rdioScannerLeases` where `holder` = ?", leases.Holder); err != nil {
+		return fmt.Errorf("leases.releaseall: %v", err)
+	}
+
+	return nil
+}
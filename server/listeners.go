@@ -0,0 +1,186 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+type ListenerSession struct {
+	Access     *ListenerSessionAccess `json:"access,omitempty"`
+	Address    string                 `json:"address"`
+	Connected  time.Time              `json:"connected"`
+	Id         string                 `json:"id"`
+	Room       string                 `json:"room,omitempty"`
+	Talkgroups map[uint][]uint        `json:"talkgroups"`
+	UserAgent  string                 `json:"userAgent"`
+}
+
+type ListenerSessionAccess struct {
+	Id    interface{} `json:"_id"`
+	Ident string      `json:"ident"`
+}
+
+func (admin *Admin) ListenersHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if b, err := json.Marshal(map[string]interface{}{"listeners": admin.Controller.Clients.GetSessions()}); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.listenershandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	case http.MethodDelete:
+		user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var ban uint64
+
+		if s := r.URL.Query().Get("ban"); len(s) > 0 {
+			if i, err := strconv.ParseUint(s, 10, 32); err == nil {
+				ban = i
+			} else {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		client := admin.Controller.Clients.GetClient(r.URL.Query().Get("id"))
+		if client == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		address := client.GetRemoteAddr()
+
+		if ban > 0 {
+			duration := time.Duration(ban) * time.Minute
+
+			admin.Controller.RateLimiter.Ban(address, duration)
+
+			count := admin.Controller.Clients.Disconnect(func(c *Client) bool {
+				return c.GetRemoteAddr() == address
+			})
+
+			admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("listeners: ip=%s banned for %s by administrator, %d session(s) disconnected", address, duration, count))
+
+			admin.Controller.AuditLog.Record(r, user, AuditLogActionBanAdd, []AuditLogChange{{Op: "add", Path: "bans", To: map[string]interface{}{"address": address, "duration": duration.String()}}})
+
+		} else {
+			admin.Controller.Clients.Disconnect(func(c *Client) bool {
+				return c == client
+			})
+
+			admin.Controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("listeners: session %s ip=%s disconnected by administrator", client.id, address))
+
+			admin.Controller.AuditLog.Record(r, user, AuditLogActionListenerDisconnect, []AuditLogChange{{Op: "remove", Path: "listeners", From: map[string]interface{}{"address": address, "id": client.id}}})
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (clients *Clients) Disconnect(match func(client *Client) bool) int {
+	count := 0
+
+	clients.Map.Range(func(k interface{}, _ interface{}) bool {
+		switch c := k.(type) {
+		case *Client:
+			if match(c) {
+				c.Close()
+				count++
+			}
+		}
+		return true
+	})
+
+	return count
+}
+
+func (clients *Clients) GetClient(id string) *Client {
+	var client *Client
+
+	if len(id) == 0 {
+		return nil
+	}
+
+	clients.Map.Range(func(k interface{}, _ interface{}) bool {
+		switch c := k.(type) {
+		case *Client:
+			if c.id == id {
+				client = c
+				return false
+			}
+		}
+		return true
+	})
+
+	return client
+}
+
+func (clients *Clients) GetSessions() []ListenerSession {
+	sessions := []ListenerSession{}
+
+	clients.Map.Range(func(k interface{}, _ interface{}) bool {
+		switch c := k.(type) {
+		case *Client:
+			session := ListenerSession{
+				Address:    c.GetRemoteAddr(),
+				Connected:  c.connected,
+				Id:         c.id,
+				Room:       c.room,
+				Talkgroups: c.Livefeed.GetEnabled(),
+				UserAgent:  c.request.UserAgent(),
+			}
+
+			if c.Access != nil && c.Access.Id != nil {
+				session.Access = &ListenerSessionAccess{
+					Id:    c.Access.Id,
+					Ident: c.Access.Ident,
+				}
+			}
+
+			sessions = append(sessions, session)
+		}
+		return true
+	})
+
+	sort.Slice(sessions, func(i int, j int) bool {
+		return sessions[i].Connected.Before(sessions[j].Connected)
+	})
+
+	return sessions
+}
@@ -0,0 +1,148 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const ListenerStatsInterval = time.Minute
+
+type listenerStat struct {
+	date      string
+	listener  string
+	system    uint
+	talkgroup uint
+}
+
+type ListenerStats struct {
+	controller *Controller
+	date       string
+	mutex      sync.Mutex
+	pending    []listenerStat
+	seen       map[listenerStat]bool
+	ticker     *time.Ticker
+}
+
+func NewListenerStats(controller *Controller) *ListenerStats {
+	return &ListenerStats{
+		controller: controller,
+		mutex:      sync.Mutex{},
+		pending:    []listenerStat{},
+		seen:       map[listenerStat]bool{},
+	}
+}
+
+func (listenerStats *ListenerStats) Flush(db *Database) error {
+	listenerStats.mutex.Lock()
+	pending := listenerStats.pending
+	listenerStats.pending = []listenerStat{}
+	listenerStats.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("listenerstats.flush: %v", err)
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	tx, err := db.Sql.Begin()
+	if err != nil {
+		return formatError(err)
+	}
+
+	for _, s := range pending {
+		if _, err = tx.Exec("insert into `rdioScannerListenerStats` (`date`, `listener`, `system`, `talkgroup`) values (?, ?, ?, ?)", s.date, s.listener, s.system, s.talkgroup); err != nil {
+			tx.Rollback()
+			return formatError(err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return formatError(err)
+	}
+
+	return nil
+}
+
+func (listenerStats *ListenerStats) Prune(db *Database, pruneDays uint) error {
+	date := time.Now().UTC().Add(-24 * time.Hour * time.Duration(pruneDays)).Format(StatisticsDateFormat)
+
+	if _, err := db.Sql.Exec("delete from `rdioScannerListenerStats` where `date` < ?", date); err != nil {
+		return fmt.Errorf("listenerstats.prune: %v", err)
+	}
+
+	return nil
+}
+
+func (listenerStats *ListenerStats) Record(client *Client, call *Call) {
+	if client == nil || call == nil || listenerStats.controller.Config.ReadOnly {
+		return
+	}
+
+	listenerStats.mutex.Lock()
+	defer listenerStats.mutex.Unlock()
+
+	date := time.Now().UTC().Format(StatisticsDateFormat)
+
+	if date != listenerStats.date {
+		listenerStats.date = date
+		listenerStats.seen = map[listenerStat]bool{}
+	}
+
+	stat := listenerStat{
+		date:      date,
+		listener:  GetListenerId(client),
+		system:    call.System,
+		talkgroup: call.Talkgroup,
+	}
+
+	if listenerStats.seen[stat] {
+		return
+	}
+
+	listenerStats.seen[stat] = true
+	listenerStats.pending = append(listenerStats.pending, stat)
+}
+
+func (listenerStats *ListenerStats) Start() {
+	listenerStats.ticker = time.NewTicker(ListenerStatsInterval)
+
+	go func() {
+		for range listenerStats.ticker.C {
+			if err := listenerStats.Flush(listenerStats.controller.Database); err != nil {
+				listenerStats.controller.Logs.LogEvent(LogLevelError, err.Error())
+			}
+		}
+	}()
+}
+
+func GetListenerId(client *Client) string {
+	id := client.GetRemoteAddr()
+
+	if client.Access != nil && client.Access.Id != nil {
+		id = fmt.Sprintf("access:%v", client.Access.Id)
+	}
+
+	sum := sha256.Sum256([]byte(id))
+
+	return hex.EncodeToString(sum[:8])
+}
@@ -16,6 +16,7 @@
 package main
 
 import (
+	"sort"
 	"strconv"
 	"sync"
 )
@@ -67,6 +68,29 @@ func (livefeed *Livefeed) FromMap(f interface{}) *Livefeed {
 	return livefeed
 }
 
+func (livefeed *Livefeed) GetEnabled() map[uint][]uint {
+	livefeed.mutex.Lock()
+	defer livefeed.mutex.Unlock()
+
+	enabled := map[uint][]uint{}
+
+	for sysId, sys := range livefeed.Matrix {
+		for tgId, tg := range sys {
+			if tg {
+				enabled[sysId] = append(enabled[sysId], tgId)
+			}
+		}
+
+		if enabled[sysId] != nil {
+			sort.Slice(enabled[sysId], func(i int, j int) bool {
+				return enabled[sysId][i] < enabled[sysId][j]
+			})
+		}
+	}
+
+	return enabled
+}
+
 func (livefeed *Livefeed) IsAllOff() bool {
 	livefeed.mutex.Lock()
 	defer livefeed.mutex.Unlock()
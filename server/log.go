@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"strings"
 	"sync"
 	"time"
 )
@@ -30,6 +31,14 @@ const (
 	LogLevelError = "error"
 )
 
+const (
+	LogsExportCsv     = "csv"
+	LogsExportMaxRows = 100000
+	LogsExportNdjson  = "ndjson"
+)
+
+var LogLevels = []string{LogLevelInfo, LogLevelWarn, LogLevelError}
+
 type Log struct {
 	Id       interface{} `json:"_id"`
 	DateTime time.Time   `json:"dateTime"`
@@ -107,7 +116,6 @@ func (logs *Logs) Search(searchOptions *LogsSearchOptions, db *Database) (*LogsS
 		order    string
 		query    string
 		rows     *sql.Rows
-		where    string = "true"
 	)
 
 	logs.mutex.Lock()
@@ -122,11 +130,6 @@ func (logs *Logs) Search(searchOptions *LogsSearchOptions, db *Database) (*LogsS
 		Logs:    []Log{},
 	}
 
-	switch v := searchOptions.Level.(type) {
-	case string:
-		where += fmt.Sprintf(" and `level` = '%v'", v)
-	}
-
 	switch v := searchOptions.Sort.(type) {
 	case int:
 		if v < 0 {
@@ -138,25 +141,7 @@ func (logs *Logs) Search(searchOptions *LogsSearchOptions, db *Database) (*LogsS
 		order = ascOrder
 	}
 
-	switch v := searchOptions.Date.(type) {
-	case time.Time:
-		var (
-			df    string = db.DateTimeFormat
-			start time.Time
-			stop  time.Time
-		)
-
-		if order == ascOrder {
-			start = time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), 0, 0, time.UTC)
-			stop = start.Add(time.Hour*24 - time.Millisecond)
-
-		} else {
-			start = time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), 0, 0, time.UTC).Add(time.Hour*-24 - time.Duration(v.Hour())).Add(time.Minute * time.Duration(-v.Minute()))
-			stop = start.Add(time.Hour*24 - time.Millisecond - time.Duration(v.Hour())).Add(time.Minute * time.Duration(-v.Minute()))
-		}
-
-		where += fmt.Sprintf(" and (`dateTime` between '%v' and '%v')", start.Format(df), stop.Format(df))
-	}
+	where, args := searchOptions.where(db, order)
 
 	switch v := searchOptions.Limit.(type) {
 	case uint:
@@ -171,7 +156,7 @@ func (logs *Logs) Search(searchOptions *LogsSearchOptions, db *Database) (*LogsS
 	}
 
 	query = fmt.Sprintf("select `dateTime` from `rdioScannerLogs` where %v order by `dateTime` asc", where)
-	if err = db.Sql.QueryRow(query).Scan(&dateTime); err != nil && err != sql.ErrNoRows {
+	if err = db.Sql.QueryRow(query, args...).Scan(&dateTime); err != nil && err != sql.ErrNoRows {
 		return nil, formatError(fmt.Errorf("%v, %v", err, query))
 	}
 
@@ -182,7 +167,7 @@ func (logs *Logs) Search(searchOptions *LogsSearchOptions, db *Database) (*LogsS
 	}
 
 	query = fmt.Sprintf("select `dateTime` from `rdioScannerLogs` where %v order by `dateTime` asc", where)
-	if err = db.Sql.QueryRow(query).Scan(&dateTime); err != nil && err != sql.ErrNoRows {
+	if err = db.Sql.QueryRow(query, args...).Scan(&dateTime); err != nil && err != sql.ErrNoRows {
 		return nil, formatError(fmt.Errorf("%v, %v", err, query))
 	}
 
@@ -193,12 +178,12 @@ func (logs *Logs) Search(searchOptions *LogsSearchOptions, db *Database) (*LogsS
 	}
 
 	query = fmt.Sprintf("select count(*) from `rdioScannerLogs` where %v", where)
-	if err = db.Sql.QueryRow(query).Scan(&logResults.Count); err != nil && err != sql.ErrNoRows {
+	if err = db.Sql.QueryRow(query, args...).Scan(&logResults.Count); err != nil && err != sql.ErrNoRows {
 		return nil, formatError(fmt.Errorf("%v, %v", err, query))
 	}
 
 	query = fmt.Sprintf("select `_id`, `DateTime`, `level`, `message` from `rdioScannerLogs` where %v order by `dateTime` %v limit %v offset %v", where, order, limit, offset)
-	if rows, err = db.Sql.Query(query); err != nil && err != sql.ErrNoRows {
+	if rows, err = db.Sql.Query(query, args...); err != nil && err != sql.ErrNoRows {
 		return nil, formatError(fmt.Errorf("%v, %v", err, query))
 	}
 
@@ -231,6 +216,63 @@ func (logs *Logs) Search(searchOptions *LogsSearchOptions, db *Database) (*LogsS
 	return logResults, nil
 }
 
+func (logs *Logs) Export(searchOptions *LogsSearchOptions, db *Database, fn func(log Log) error) error {
+	var (
+		dateTime interface{}
+		err      error
+		id       sql.NullFloat64
+		order    string
+		rows     *sql.Rows
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("logs.export: %v", err)
+	}
+
+	switch v := searchOptions.Sort.(type) {
+	case int:
+		if v < 0 {
+			order = "desc"
+		} else {
+			order = "asc"
+		}
+	default:
+		order = "asc"
+	}
+
+	where, args := searchOptions.where(db, order)
+
+	query := fmt.Sprintf("select `_id`, `dateTime`, `level`, `message` from `rdioScannerLogs` where %v order by `dateTime` %v limit %v", where, order, LogsExportMaxRows)
+	if rows, err = db.Sql.Query(query, args...); err != nil {
+		return formatError(fmt.Errorf("%v, %v", err, query))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		log := Log{}
+
+		if err = rows.Scan(&id, &dateTime, &log.Level, &log.Message); err != nil {
+			return formatError(err)
+		}
+
+		if id.Valid && id.Float64 > 0 {
+			log.Id = uint(id.Float64)
+		}
+
+		if t, err := db.ParseDateTime(dateTime); err == nil {
+			log.DateTime = t
+		} else {
+			continue
+		}
+
+		if err = fn(log); err != nil {
+			return formatError(err)
+		}
+	}
+
+	return rows.Err()
+}
+
 func (logs *Logs) setDaemon(d *Daemon) {
 	logs.daemon = d
 }
@@ -240,11 +282,18 @@ func (logs *Logs) setDatabase(d *Database) {
 }
 
 type LogsSearchOptions struct {
-	Date   interface{} `json:"date,omitempty"`
-	Level  interface{} `json:"level,omitempty"`
-	Limit  interface{} `json:"limit,omitempty"`
-	Offset interface{} `json:"offset,omitempty"`
-	Sort   interface{} `json:"sort,omitempty"`
+	Date     interface{} `json:"date,omitempty"`
+	DateFrom interface{} `json:"dateFrom,omitempty"`
+	DateTo   interface{} `json:"dateTo,omitempty"`
+	Format   interface{} `json:"format,omitempty"`
+	Level    interface{} `json:"level,omitempty"`
+	LevelMax interface{} `json:"levelMax,omitempty"`
+	LevelMin interface{} `json:"levelMin,omitempty"`
+	Limit    interface{} `json:"limit,omitempty"`
+	Module   interface{} `json:"module,omitempty"`
+	Offset   interface{} `json:"offset,omitempty"`
+	Sort     interface{} `json:"sort,omitempty"`
+	Text     interface{} `json:"text,omitempty"`
 }
 
 func (searchOptions *LogsSearchOptions) FromMap(m map[string]interface{}) error {
@@ -255,16 +304,61 @@ func (searchOptions *LogsSearchOptions) FromMap(m map[string]interface{}) error
 		}
 	}
 
+	switch v := m["dateFrom"].(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			searchOptions.DateFrom = t
+		} else {
+			return fmt.Errorf("invalid dateFrom %s", v)
+		}
+	}
+
+	switch v := m["dateTo"].(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			searchOptions.DateTo = t
+		} else {
+			return fmt.Errorf("invalid dateTo %s", v)
+		}
+	}
+
+	switch v := m["format"].(type) {
+	case string:
+		switch v {
+		case LogsExportCsv, LogsExportNdjson:
+			searchOptions.Format = v
+		default:
+			return fmt.Errorf("unknown format %s", v)
+		}
+	}
+
 	switch v := m["level"].(type) {
 	case string:
 		searchOptions.Level = v
 	}
 
+	switch v := m["levelMax"].(type) {
+	case string:
+		searchOptions.LevelMax = v
+	}
+
+	switch v := m["levelMin"].(type) {
+	case string:
+		searchOptions.LevelMin = v
+	}
+
 	switch v := m["limit"].(type) {
 	case float64:
 		searchOptions.Limit = uint(v)
 	}
 
+	switch v := m["module"].(type) {
+	case string:
+		if len(v) > 0 {
+			searchOptions.Module = v
+		}
+	}
+
 	switch v := m["offset"].(type) {
 	case float64:
 		searchOptions.Offset = uint(v)
@@ -275,9 +369,105 @@ func (searchOptions *LogsSearchOptions) FromMap(m map[string]interface{}) error
 		searchOptions.Sort = int(v)
 	}
 
+	switch v := m["text"].(type) {
+	case string:
+		if len(v) > 0 {
+			searchOptions.Text = v
+		}
+	}
+
 	return nil
 }
 
+func (searchOptions *LogsSearchOptions) where(db *Database, order string) (string, []interface{}) {
+	var (
+		args  = []interface{}{}
+		where = "true"
+	)
+
+	escape := func(s string) string {
+		return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+	}
+
+	switch v := searchOptions.Level.(type) {
+	case string:
+		where += " and `level` = ?"
+		args = append(args, v)
+	}
+
+	if searchOptions.LevelMin != nil || searchOptions.LevelMax != nil {
+		min, max := 0, len(LogLevels)-1
+
+		for i, level := range LogLevels {
+			if level == searchOptions.LevelMin {
+				min = i
+			}
+			if level == searchOptions.LevelMax {
+				max = i
+			}
+		}
+
+		levels := []string{}
+		for i := min; i <= max; i++ {
+			levels = append(levels, "?")
+			args = append(args, LogLevels[i])
+		}
+
+		if len(levels) > 0 {
+			where += fmt.Sprintf(" and `level` in (%s)", strings.Join(levels, ", "))
+		} else {
+			where += " and false"
+		}
+	}
+
+	switch v := searchOptions.Module.(type) {
+	case string:
+		where += " and `message` like ? escape '!'"
+		args = append(args, escape(v)+"%")
+	}
+
+	switch v := searchOptions.Text.(type) {
+	case string:
+		where += " and lower(`message`) like ? escape '!'"
+		args = append(args, "%"+escape(strings.ToLower(v))+"%")
+	}
+
+	switch v := searchOptions.Date.(type) {
+	case time.Time:
+		var (
+			df    string = db.DateTimeFormat
+			start time.Time
+			stop  time.Time
+		)
+
+		if order == "asc" {
+			start = time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), 0, 0, time.UTC)
+			stop = start.Add(time.Hour*24 - time.Millisecond)
+
+		} else {
+			start = time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), 0, 0, time.UTC).Add(time.Hour*-24 - time.Duration(v.Hour())).Add(time.Minute * time.Duration(-v.Minute()))
+			stop = start.Add(time.Hour*24 - time.Millisecond - time.Duration(v.Hour())).Add(time.Minute * time.Duration(-v.Minute()))
+		}
+
+		where += " and (`dateTime` between ? and ?)"
+		args = append(args, start.Format(df), stop.Format(df))
+	}
+
+	switch v := searchOptions.DateFrom.(type) {
+	case time.Time:
+		where += " and `dateTime` >= ?"
+		args = append(args, v.UTC().Format(db.DateTimeFormat))
+	}
+
+	switch v := searchOptions.DateTo.(type) {
+	case time.Time:
+		where += " and `dateTime` <= ?"
+		args = append(args, v.UTC().Format(db.DateTimeFormat))
+	}
+
+	return where, args
+}
+
 type LogsSearchResults struct {
 	Count     uint               `json:"count"`
 	DateStart time.Time          `json:"dateStart"`
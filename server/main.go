@@ -21,10 +21,11 @@ import (
 	"io/ioutil"
 	"log"
 	"mime"
+	"net"
 	"net/http"
 	"os"
 	"path"
-	"regexp"
+	"runtime/debug"
 	"strings"
 	"time"
 
@@ -45,6 +46,10 @@ func main() {
 
 	config := NewConfig()
 
+	if config != nil && config.LowMemory {
+		debug.SetGCPercent(50)
+	}
+
 	controller := NewController(config)
 
 	fmt.Printf("\nRdio Scanner v%s\n", Version)
@@ -82,24 +87,130 @@ func main() {
 		sslAddr = defaultAddr
 	}
 
+	http.HandleFunc("/api/admin/2fa", controller.Admin.TotpHandler)
+
+	http.HandleFunc("/api/admin/accesses", controller.Admin.AccessesHandler)
+
+	http.HandleFunc("/api/admin/alerts/test", controller.Admin.AlertTestHandler)
+
+	http.HandleFunc("/api/admin/audit", controller.Admin.AuditLogHandler)
+
+	http.HandleFunc("/api/admin/backups", controller.Admin.BackupsHandler)
+
+	http.HandleFunc("/api/admin/backups/restore", controller.Admin.BackupRestoreHandler)
+
+	http.HandleFunc("/api/admin/bans", controller.Admin.BansHandler)
+
+	http.HandleFunc("/api/admin/blacklists", controller.Admin.BlacklistsHandler)
+
+	http.HandleFunc("/api/admin/bookmarks", controller.Admin.BookmarksHandler)
+
+	http.HandleFunc("/api/admin/clockskew", controller.Admin.ClockSkewHandler)
+
 	http.HandleFunc("/api/admin/config", controller.Admin.ConfigHandler)
 
+	http.HandleFunc("/api/admin/config/", controller.Admin.ConfigSectionHandler)
+
+	http.HandleFunc("/api/admin/config/export", controller.Admin.ConfigExportHandler)
+
+	http.HandleFunc("/api/admin/config/import", controller.Admin.ConfigImportHandler)
+
+	http.HandleFunc("/api/admin/debug/pprof/", controller.Admin.DebugHandler)
+
+	http.HandleFunc("/api/admin/duplicates", controller.Admin.DuplicatesHandler)
+
+	http.HandleFunc("/api/admin/frequencies", controller.Admin.FrequenciesHandler)
+
+	http.HandleFunc("/api/admin/heartbeats", controller.Admin.HeartbeatsHandler)
+
 	http.HandleFunc("/api/admin/login", controller.Admin.LoginHandler)
 
 	http.HandleFunc("/api/admin/logout", controller.Admin.LogoutHandler)
 
+	http.HandleFunc("/api/admin/incidents", controller.Admin.IncidentsHandler)
+
+	http.HandleFunc("/api/admin/incidents/calls", controller.Admin.IncidentCallsHandler)
+
+	http.HandleFunc("/api/admin/incidents/export", controller.Admin.IncidentExportHandler)
+
+	http.HandleFunc("/api/admin/incidents/notes", controller.Admin.IncidentNotesHandler)
+
+	http.HandleFunc("/api/admin/listeners", controller.Admin.ListenersHandler)
+
 	http.HandleFunc("/api/admin/logs", controller.Admin.LogsHandler)
 
+	http.HandleFunc("/api/admin/monitor", controller.Admin.MonitorHandler)
+
+	http.HandleFunc("/api/admin/notifications", controller.Admin.NotificationsHandler)
+
+	http.HandleFunc("/api/admin/options/schema", controller.Admin.OptionsSchemaHandler)
+
 	http.HandleFunc("/api/admin/password", controller.Admin.PasswordHandler)
 
+	http.HandleFunc("/api/admin/refresh", controller.Admin.RefreshHandler)
+
+	http.HandleFunc("/api/admin/retentions/test", controller.Admin.RetentionTestHandler)
+
+	http.HandleFunc("/api/admin/runtime", controller.Admin.RuntimeHandler)
+
+	http.HandleFunc("/api/admin/saml/", controller.Admin.SamlHandler)
+
+	http.HandleFunc("/api/admin/statistics", controller.Admin.StatisticsHandler)
+
+	http.HandleFunc("/api/admin/stats", controller.Admin.StatsHandler)
+
+	http.HandleFunc("/api/admin/talkgroups/import", controller.Admin.TalkgroupsImportHandler)
+
+	http.HandleFunc("/api/admin/telemetry", controller.Admin.TelemetryHandler)
+
+	http.HandleFunc("/api/admin/units", controller.Admin.UnitAliasesHandler)
+
+	http.HandleFunc("/api/admin/units/import", controller.Admin.UnitsImportHandler)
+
 	http.HandleFunc("/api/admin/user-add", controller.Admin.UserAddHandler)
 
 	http.HandleFunc("/api/admin/user-remove", controller.Admin.UserRemoveHandler)
 
+	http.HandleFunc("/api/admin/users", controller.Admin.UsersHandler)
+
+	http.HandleFunc("/api/admin/watermark", controller.Admin.WatermarkHandler)
+
+	http.HandleFunc("/api/admin/webhooks/test", controller.Admin.WebhookTestHandler)
+
+	http.HandleFunc("/api/audio/", controller.Api.AudioHandler)
+
+	http.HandleFunc("/api/call-audio", controller.Api.CallAudioHandler)
+
 	http.HandleFunc("/api/call-upload", controller.Api.CallUploadHandler)
 
+	http.HandleFunc("/api/events", controller.Api.EventsHandler)
+
+	http.HandleFunc("/api/health", controller.Api.HealthHandler)
+
+	http.HandleFunc("/api/heartbeat", controller.Api.HeartbeatHandler)
+
+	http.HandleFunc("/api/oidc/", controller.Api.OidcHandler)
+
+	http.HandleFunc("/api/poll", controller.Api.PollHandler)
+
 	http.HandleFunc("/api/trunk-recorder-call-upload", controller.Api.TrunkRecorderCallUploadHandler)
 
+	http.HandleFunc("/api/v1/bot", controller.Api.V1BotHandler)
+
+	http.HandleFunc("/api/v1/bot/schema", controller.Api.V1BotSchemaHandler)
+
+	http.HandleFunc("/api/v1/calls", controller.Api.V1CallsHandler)
+
+	http.HandleFunc("/api/v1/calls/", controller.Api.V1CallHandler)
+
+	http.HandleFunc("/api/v1/patches", controller.Api.V1PatchesHandler)
+
+	http.HandleFunc("/api/v1/systems", controller.Api.V1SystemsHandler)
+
+	http.HandleFunc("/api/v1/talkgroups", controller.Api.V1TalkgroupsHandler)
+
+	http.HandleFunc("/api/v1/units/", controller.Api.V1UnitsHandler)
+
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		url := r.URL.Path[1:]
 
@@ -112,6 +223,11 @@ func main() {
 				WriteBufferSize: 1024,
 			}
 
+			if controller.Config.LowMemory {
+				upgrader.ReadBufferSize = 512
+				upgrader.WriteBufferSize = 512
+			}
+
 			conn, err := upgrader.Upgrade(w, r, nil)
 			if err != nil {
 				log.Println(err)
@@ -152,26 +268,34 @@ func main() {
 		}
 	})
 
+	basePath := controller.Config.GetBasePath()
+
 	if port == "80" {
-		log.Printf("main interface at http://%s", hostname)
+		log.Printf("main interface at http://%s%s/", hostname, basePath)
 	} else {
-		log.Printf("main interface at http://%s:%s", hostname, port)
+		log.Printf("main interface at http://%s:%s%s/", hostname, port, basePath)
 	}
 
 	sslPrintInfo := func() {
 		if sslPort == "443" {
-			log.Printf("main interface at https://%s", hostname)
-			log.Printf("admin interface at https://%s/admin", hostname)
+			log.Printf("main interface at https://%s%s/", hostname, basePath)
+			log.Printf("admin interface at https://%s%s/admin", hostname, basePath)
 
 		} else {
-			log.Printf("main interface at https://%s:%s", hostname, sslPort)
-			log.Printf("admin interface at https://%s:%s/admin", hostname, sslPort)
+			log.Printf("main interface at https://%s:%s%s/", hostname, sslPort, basePath)
+			log.Printf("admin interface at https://%s:%s%s/admin", hostname, sslPort, basePath)
 		}
 	}
 
+	handler := controller.AccessLog.Handler(controller.RateLimiter.Handler(http.DefaultServeMux))
+	if len(basePath) > 0 {
+		handler = GetBasePathHandler(basePath, handler)
+	}
+
 	newServer := func(addr string, tlsConfig *tls.Config) *http.Server {
 		s := &http.Server{
 			Addr:         addr,
+			Handler:      handler,
 			TLSConfig:    tlsConfig,
 			ReadTimeout:  10 * time.Second,
 			WriteTimeout: 10 * time.Second,
@@ -215,10 +339,10 @@ func main() {
 		}()
 
 	} else if port == "80" {
-		log.Printf("admin interface at http://%s/admin", hostname)
+		log.Printf("admin interface at http://%s%s/admin", hostname, basePath)
 
 	} else {
-		log.Printf("admin interface at http://%s:%s/admin", hostname, port)
+		log.Printf("admin interface at http://%s:%s%s/admin", hostname, port, basePath)
 	}
 
 	server := newServer(fmt.Sprintf("%s:%s", addr, port), nil)
@@ -228,18 +352,72 @@ func main() {
 	}
 }
 
-func GetRemoteAddr(r *http.Request) string {
-	re := regexp.MustCompile(`(\[[^\]]+\]|[^:]+):.*`)
+func GetBasePathHandler(basePath string, next http.Handler) http.Handler {
+	strip := http.StripPrefix(basePath, next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == basePath:
+			u := *r.URL
+			u.Path = basePath + "/"
+			http.Redirect(w, r, u.RequestURI(), http.StatusMovedPermanently)
+
+		case strings.HasPrefix(r.URL.Path, basePath+"/"):
+			strip.ServeHTTP(w, r)
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+}
 
-	for _, addr := range strings.Split(r.Header.Get("X-Forwarded-For"), ",") {
-		if ip := re.ReplaceAllString(addr, "$1"); len(ip) > 0 {
-			return ip
+func GetRequestScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil && trustedProxies.Contains(net.ParseIP(host)) {
+		switch proto := strings.ToLower(r.Header.Get("X-Forwarded-Proto")); proto {
+		case "http", "https":
+			return proto
+		}
+	}
+
+	return "http"
+}
+
+func GetRemoteAddr(r *http.Request) string {
+	getHost := func(addr string) string {
+		addr = strings.TrimSpace(addr)
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			return host
 		}
+		return strings.Trim(addr, "[]")
 	}
 
-	if ip := re.ReplaceAllString(r.RemoteAddr, "$1"); len(ip) > 0 {
-		return ip
+	addr := getHost(r.RemoteAddr)
+	if len(addr) == 0 {
+		return "unknown"
+	}
+
+	if !trustedProxies.Contains(net.ParseIP(addr)) {
+		return addr
+	}
+
+	forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		ip := getHost(forwarded[i])
+		if len(ip) == 0 {
+			continue
+		}
+
+		addr = ip
+
+		if !trustedProxies.Contains(net.ParseIP(ip)) {
+			break
+		}
 	}
 
-	return "unknown"
+	return addr
 }
@@ -17,12 +17,17 @@ package main
 
 import (
 	"encoding/json"
+	"io"
 )
 
 const (
+	MessageCommandAlternate      = "ALT"
+	MessageCommandBookmark       = "BMK"
 	MessageCommandCall           = "CAL"
 	MessageCommandConfig         = "CFG"
+	MessageCommandDirectory      = "DIR"
 	MessageCommandExpired        = "XPR"
+	MessageCommandHistogram      = "HST"
 	MessageCommandIOS            = "IOS"
 	MessageCommandListCall       = "LCL"
 	MessagecommandListenersCount = "LSC"
@@ -30,10 +35,17 @@ const (
 	MessageCommandMax            = "MAX"
 	MessageCommandPin            = "PIN"
 	MessageCommandPushId         = "PID"
+	MessageCommandQueue          = "QUE"
+	MessageCommandRelatedCalls   = "RLC"
+	MessageCommandRoom           = "ROM"
 	MessageCommandServer         = "SRV"
+	MessageCommandTimeline       = "TML"
+	MessageCommandTranscript     = "TRS"
 	MessageCommandVersion        = "VER"
 )
 
+const MessageFlagDownload = "d"
+
 type Message struct {
 	Command interface{}
 	Payload interface{}
@@ -67,6 +79,20 @@ func (message *Message) FromJson(b []byte) error {
 	return nil
 }
 
+func (message *Message) WriteJson(w io.Writer) error {
+	a := []interface{}{message.Command}
+
+	if message.Payload != nil && message.Payload != "" {
+		a = append(a, message.Payload)
+	}
+
+	if message.Flag != nil && message.Flag != "" {
+		a = append(a, message.Flag)
+	}
+
+	return WriteJsonStream(w, a, 3)
+}
+
 func (message *Message) ToJson() ([]byte, error) {
 	str := []interface{}{message.Command}
 
@@ -0,0 +1,236 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	MonitorCheckInterval = time.Minute
+	MonitorHistory       = 7 * 24 * time.Hour
+)
+
+type MonitorSystem struct {
+	LastCall    time.Time  `json:"lastCall"`
+	Silent      bool       `json:"silent"`
+	SilentSince *time.Time `json:"silentSince,omitempty"`
+	System      uint       `json:"system"`
+}
+
+type Monitor struct {
+	Controller *Controller
+	Systems    map[uint]*MonitorSystem
+	mutex      sync.Mutex
+	ticker     *time.Ticker
+}
+
+func (admin *Admin) MonitorHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if b, err := json.Marshal(admin.Controller.Monitor.GetStatus()); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.monitorhandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func NewMonitor(controller *Controller) *Monitor {
+	return &Monitor{
+		Controller: controller,
+		Systems:    map[uint]*MonitorSystem{},
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (monitor *Monitor) Check() {
+	var notices []map[string]interface{}
+
+	options := monitor.Controller.Options
+
+	for _, heartbeat := range monitor.Controller.Heartbeats.Check(options.HeartbeatTimeout) {
+		monitor.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("monitor: no heartbeat received from recorder %s since %v", heartbeat.Ident, heartbeat.LastSeen.UTC().Format(time.RFC3339)))
+		monitor.Controller.Admin.BroadcastNotice(map[string]interface{}{
+			"ident":    heartbeat.Ident,
+			"lastSeen": heartbeat.LastSeen.UTC().Format(time.RFC3339),
+			"type":     "heartbeatLost",
+		})
+	}
+
+	if options.SilenceAlert == 0 || !monitor.IsExpectedHour(time.Now().Hour()) {
+		return
+	}
+
+	threshold := time.Duration(options.SilenceAlert) * time.Minute
+
+	monitor.mutex.Lock()
+
+	for _, status := range monitor.Systems {
+		if status.Silent || time.Since(status.LastCall) < threshold {
+			continue
+		}
+
+		status.Silent = true
+		since := status.LastCall.Add(threshold)
+		status.SilentSince = &since
+
+		notices = append(notices, map[string]interface{}{
+			"lastCall": status.LastCall.UTC().Format(time.RFC3339),
+			"system":   status.System,
+			"type":     "silence",
+		})
+	}
+
+	monitor.mutex.Unlock()
+
+	for _, notice := range notices {
+		label := fmt.Sprintf("%v", notice["system"])
+		if system, ok := monitor.Controller.Systems.GetSystem(notice["system"]); ok {
+			label = system.Label
+		}
+
+		monitor.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("monitor: no calls received on system %s since %v", label, notice["lastCall"]))
+		monitor.Controller.Admin.BroadcastNotice(notice)
+	}
+}
+
+func (monitor *Monitor) GetStatus() []MonitorSystem {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	list := []MonitorSystem{}
+
+	for _, status := range monitor.Systems {
+		list = append(list, *status)
+	}
+
+	sort.Slice(list, func(i int, j int) bool {
+		return list[i].System < list[j].System
+	})
+
+	return list
+}
+
+func (monitor *Monitor) IsExpectedHour(hour int) bool {
+	from := int(monitor.Controller.Options.SilenceAlertFrom)
+	to := int(monitor.Controller.Options.SilenceAlertTo)
+
+	if from == to {
+		return true
+	} else if from < to {
+		return hour >= from && hour < to
+	} else {
+		return hour >= from || hour < to
+	}
+}
+
+func (monitor *Monitor) Read(db *Database) error {
+	var (
+		dateTime interface{}
+		err      error
+		rows     *sql.Rows
+		system   uint
+		t        time.Time
+	)
+
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("monitor.read: %v", err)
+	}
+
+	since := time.Now().Add(-MonitorHistory).Format(db.DateTimeFormat)
+
+	if rows, err = db.Sql.Query("select `system`, max(`dateTime`) from `rdioScannerCalls` where `dateTime` > ? group by `system`", since); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&system, &dateTime); err != nil {
+			break
+		}
+
+		if t, err = db.ParseDateTime(dateTime); err != nil {
+			continue
+		}
+
+		monitor.Systems[system] = &MonitorSystem{LastCall: t, System: system}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	return nil
+}
+
+func (monitor *Monitor) Seen(system uint) {
+	monitor.mutex.Lock()
+
+	status, ok := monitor.Systems[system]
+	if !ok {
+		status = &MonitorSystem{System: system}
+		monitor.Systems[system] = status
+	}
+
+	recovered := status.Silent
+
+	status.LastCall = time.Now()
+	status.Silent = false
+	status.SilentSince = nil
+
+	monitor.mutex.Unlock()
+
+	if recovered {
+		monitor.Controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("monitor: calls resumed on system %v", system))
+		monitor.Controller.Admin.BroadcastNotice(map[string]interface{}{
+			"system": system,
+			"type":   "resumed",
+		})
+	}
+}
+
+func (monitor *Monitor) Start() {
+	monitor.ticker = time.NewTicker(MonitorCheckInterval)
+
+	go func() {
+		for range monitor.ticker.C {
+			monitor.Check()
+		}
+	}()
+}
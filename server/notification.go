@@ -0,0 +1,451 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	NotificationCheckInterval  = 15 * time.Minute
+	NotificationKindClockSkew  = "clockskew"
+	NotificationKindDisk       = "disk"
+	NotificationKindDownstream = "downstream"
+	NotificationKindUpdate     = "update"
+	NotificationsLimit         = 200
+)
+
+type Notification struct {
+	Id       interface{} `json:"_id"`
+	Count    uint        `json:"count"`
+	DateTime time.Time   `json:"dateTime"`
+	Key      string      `json:"key"`
+	Kind     string      `json:"kind"`
+	Message  string      `json:"message"`
+	Read     bool        `json:"read"`
+	Updated  time.Time   `json:"updated"`
+}
+
+type Notifications struct {
+	controller *Controller
+	diskLow    bool
+	mutex      sync.Mutex
+	ticker     *time.Ticker
+}
+
+func (admin *Admin) NotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.notificationshandler: %s", err.Error()))
+	}
+
+	switch r.Method {
+	case http.MethodDelete:
+		user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if !admin.CheckWritable(w) {
+			return
+		}
+
+		id, err := strconv.Atoi(r.URL.Query().Get("id"))
+		if err != nil || id <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if ok, err := admin.Controller.Notifications.Delete(uint(id)); err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+		} else if !ok {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			admin.Controller.AuditLog.Record(r, user, AuditLogActionNotificationDelete, []AuditLogChange{{Op: "remove", Path: fmt.Sprintf("notifications[_id=%d]", id)}})
+			admin.Controller.Notifications.broadcast(nil)
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	case http.MethodGet:
+		list, unread, err := admin.Controller.Notifications.List(r.URL.Query().Get("unread") == "true")
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		if b, err := json.Marshal(map[string]interface{}{"notifications": list, "unread": unread}); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	case http.MethodPost:
+		var (
+			ids  []uint
+			m    map[string]interface{}
+			read = true
+		)
+
+		if !admin.CheckWritable(w) {
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch v := m["ids"].(type) {
+		case []interface{}:
+			for _, id := range v {
+				switch id := id.(type) {
+				case float64:
+					ids = append(ids, uint(id))
+				}
+			}
+		}
+
+		switch v := m["read"].(type) {
+		case bool:
+			read = v
+		}
+
+		if len(ids) == 0 && m["all"] != true {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := admin.Controller.Notifications.MarkRead(ids, read); err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		changes := []AuditLogChange{}
+		if len(ids) > 0 {
+			for _, id := range ids {
+				changes = append(changes, AuditLogChange{Op: "replace", Path: fmt.Sprintf("notifications[_id=%d].read", id), To: read})
+			}
+		} else {
+			changes = append(changes, AuditLogChange{Op: "replace", Path: "notifications[*].read", To: read})
+		}
+
+		user, _ := admin.GetTokenUser(t)
+		admin.Controller.AuditLog.Record(r, user, AuditLogActionNotificationRead, changes)
+
+		admin.Controller.Notifications.broadcast(nil)
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func NewNotifications(controller *Controller) *Notifications {
+	return &Notifications{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (notifications *Notifications) CheckDisk() {
+	threshold := notifications.controller.Options.DiskWarningPercent
+
+	if threshold == 0 {
+		notifications.diskLow = false
+		return
+	}
+
+	dir := notifications.controller.Config.BaseDir
+
+	free, total, err := GetDiskUsage(dir)
+	if err != nil || total == 0 {
+		return
+	}
+
+	percent := float64(free) * 100 / float64(total)
+
+	if percent >= float64(threshold) {
+		notifications.diskLow = false
+		return
+	}
+
+	if notifications.diskLow {
+		return
+	}
+
+	notifications.diskLow = true
+
+	message := fmt.Sprintf("low disk space on %s, %.1f%% free (%d MB)", dir, percent, free/1024/1024)
+
+	notifications.controller.Logs.LogEvent(LogLevelWarn, message)
+
+	if err := notifications.Notify(NotificationKindDisk, dir, message); err != nil {
+		notifications.controller.Logs.LogEvent(LogLevelError, err.Error())
+	}
+}
+
+func (notifications *Notifications) CountUnread() (uint, error) {
+	var unread uint
+
+	if err := notifications.controller.Database.Sql.QueryRow("select count(*) from `rdioScannerNotifications` where `read` = 0").Scan(&unread); err != nil {
+		return 0, fmt.Errorf("notifications.countunread: %v", err)
+	}
+
+	return unread, nil
+}
+
+func (notifications *Notifications) Delete(id uint) (bool, error) {
+	notifications.mutex.Lock()
+	defer notifications.mutex.Unlock()
+
+	res, err := notifications.controller.Database.Sql.Exec("delete from `rdioScannerNotifications` where `_id` = ?", id)
+	if err != nil {
+		return false, fmt.Errorf("notifications.delete: %v", err)
+	}
+
+	count, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("notifications.delete: %v", err)
+	}
+
+	return count > 0, nil
+}
+
+func (notifications *Notifications) List(unreadOnly bool) ([]Notification, uint, error) {
+	var (
+		dateTime interface{}
+		id       sql.NullInt64
+		read     bool
+		updated  interface{}
+	)
+
+	notifications.mutex.Lock()
+	defer notifications.mutex.Unlock()
+
+	db := notifications.controller.Database
+
+	formatError := func(err error) error {
+		return fmt.Errorf("notifications.list: %v", err)
+	}
+
+	unread, err := notifications.CountUnread()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	where := "1 = 1"
+	if unreadOnly {
+		where = "`read` = 0"
+	}
+
+	rows, err := db.Sql.Query(fmt.Sprintf("select `_id`, `count`, `dateTime`, `key`, `kind`, `message`, `read`, `updated` from `rdioScannerNotifications` where %s order by `updated` desc limit %d", where, NotificationsLimit))
+	if err != nil {
+		return nil, 0, formatError(err)
+	}
+	defer rows.Close()
+
+	list := []Notification{}
+
+	for rows.Next() {
+		notification := Notification{}
+
+		if err = rows.Scan(&id, &notification.Count, &dateTime, &notification.Key, &notification.Kind, &notification.Message, &read, &updated); err != nil {
+			return nil, 0, formatError(err)
+		}
+
+		if id.Valid {
+			notification.Id = uint(id.Int64)
+		}
+
+		notification.Read = read
+
+		if t, err := db.ParseDateTime(dateTime); err == nil {
+			notification.DateTime = t
+		}
+
+		if t, err := db.ParseDateTime(updated); err == nil {
+			notification.Updated = t
+		}
+
+		list = append(list, notification)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, formatError(err)
+	}
+
+	return list, unread, nil
+}
+
+func (notifications *Notifications) MarkRead(ids []uint, read bool) error {
+	notifications.mutex.Lock()
+	defer notifications.mutex.Unlock()
+
+	db := notifications.controller.Database
+
+	formatError := func(err error) error {
+		return fmt.Errorf("notifications.markread: %v", err)
+	}
+
+	if len(ids) == 0 {
+		if _, err := db.Sql.Exec("update `rdioScannerNotifications` set `read` = ?", read); err != nil {
+			return formatError(err)
+		}
+		return nil
+	}
+
+	for _, id := range ids {
+		if _, err := db.Sql.Exec("update `rdioScannerNotifications` set `read` = ? where `_id` = ?", read, id); err != nil {
+			return formatError(err)
+		}
+	}
+
+	return nil
+}
+
+func (notifications *Notifications) Notify(kind string, key string, message string) error {
+	return notifications.notify(kind, key, message, false)
+}
+
+func (notifications *Notifications) NotifyOnce(kind string, key string, message string) error {
+	return notifications.notify(kind, key, message, true)
+}
+
+func (notifications *Notifications) Prune(db *Database, pruneDays uint) error {
+	notifications.mutex.Lock()
+	defer notifications.mutex.Unlock()
+
+	date := time.Now().Add(-24 * time.Hour * time.Duration(pruneDays)).Format(db.DateTimeFormat)
+	_, err := db.Sql.Exec("delete from `rdioScannerNotifications` where `read` = 1 and `updated` < ?", date)
+
+	return err
+}
+
+func (notifications *Notifications) Start() {
+	notifications.ticker = time.NewTicker(NotificationCheckInterval)
+
+	notifications.CheckDisk()
+
+	go func() {
+		for range notifications.ticker.C {
+			notifications.CheckDisk()
+		}
+	}()
+}
+
+func (notifications *Notifications) broadcast(notification *Notification) {
+	unread, err := notifications.CountUnread()
+	if err != nil {
+		return
+	}
+
+	m := map[string]interface{}{"unread": unread}
+
+	if notification != nil {
+		m["notification"] = notification
+	}
+
+	if b, err := json.Marshal(map[string]interface{}{"notifications": m}); err == nil {
+		select {
+		case notifications.controller.Admin.Broadcast <- &b:
+		case <-notifications.controller.Admin.done:
+		}
+	}
+}
+
+func (notifications *Notifications) notify(kind string, key string, message string, once bool) error {
+	var (
+		count uint
+		id    sql.NullInt64
+		read  bool
+	)
+
+	if notifications.controller.Config.ReadOnly {
+		return nil
+	}
+
+	notifications.mutex.Lock()
+	defer notifications.mutex.Unlock()
+
+	db := notifications.controller.Database
+
+	formatError := func(err error) error {
+		return fmt.Errorf("notifications.notify: %v", err)
+	}
+
+	notification := Notification{
+		Count:    1,
+		DateTime: time.Now().UTC(),
+		Key:      key,
+		Kind:     kind,
+		Message:  message,
+	}
+	notification.Updated = notification.DateTime
+
+	query := "select `_id`, `count`, `read` from `rdioScannerNotifications` where `kind` = ? and `key` = ? and `read` = 0"
+	if once {
+		query = "select `_id`, `count`, `read` from `rdioScannerNotifications` where `kind` = ? and `key` = ? order by `read` asc"
+	}
+
+	err := db.Sql.QueryRow(query, kind, key).Scan(&id, &count, &read)
+
+	switch {
+	case err == sql.ErrNoRows:
+		res, err := db.Sql.Exec("insert into `rdioScannerNotifications` (`count`, `dateTime`, `key`, `kind`, `message`, `read`, `updated`) values (?, ?, ?, ?, ?, ?, ?)", notification.Count, notification.DateTime, notification.Key, notification.Kind, notification.Message, false, notification.Updated)
+		if err != nil {
+			return formatError(err)
+		}
+		if v, err := res.LastInsertId(); err == nil {
+			notification.Id = uint(v)
+		}
+
+	case err != nil:
+		return formatError(err)
+
+	case once:
+		return nil
+
+	default:
+		notification.Count = count + 1
+		notification.Id = uint(id.Int64)
+		if _, err = db.Sql.Exec("update `rdioScannerNotifications` set `count` = ?, `message` = ?, `updated` = ? where `_id` = ?", notification.Count, notification.Message, notification.Updated, id.Int64); err != nil {
+			return formatError(err)
+		}
+	}
+
+	notifications.broadcast(&notification)
+
+	return nil
+}
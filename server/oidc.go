@@ -0,0 +1,653 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+const (
+	OidcDiscoveryTtl   = time.Hour
+	OidcKeysTtl        = time.Hour
+	OidcListenerExpiry = 7 * 24 * time.Hour
+	OidcRequestTimeout = 10 * time.Minute
+	OidcTimeout        = 10 * time.Second
+)
+
+type OidcProvider struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	Issuer                string `json:"issuer"`
+	JwksUri               string `json:"jwks_uri"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+type OidcRequest struct {
+	Created time.Time
+	Nonce   string
+	Target  string
+}
+
+type OidcUser struct {
+	Email  string
+	Groups []string
+	Name   string
+}
+
+type Oidc struct {
+	Controller  *Controller
+	issuer      string
+	keys        map[string]interface{}
+	keysFetched time.Time
+	provider    *OidcProvider
+	fetched     time.Time
+	requests    map[string]*OidcRequest
+	mutex       sync.Mutex
+}
+
+func NewOidc(controller *Controller) *Oidc {
+	return &Oidc{
+		Controller: controller,
+		keys:       map[string]interface{}{},
+		requests:   map[string]*OidcRequest{},
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (oidc *Oidc) Exchange(r *http.Request, code string, state string) (*OidcUser, string, error) {
+	var response struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+		IdToken          string `json:"id_token"`
+	}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("oidc.exchange: %v", err)
+	}
+
+	oidc.mutex.Lock()
+	request, ok := oidc.requests[state]
+	delete(oidc.requests, state)
+	oidc.mutex.Unlock()
+
+	if !ok || time.Since(request.Created) > OidcRequestTimeout {
+		return nil, "", formatError(errors.New("unknown or expired state"))
+	}
+
+	if len(code) == 0 {
+		return nil, "", formatError(errors.New("no authorization code"))
+	}
+
+	provider, err := oidc.GetProvider()
+	if err != nil {
+		return nil, "", formatError(err)
+	}
+
+	options := oidc.Controller.Options
+
+	form := url.Values{}
+	form.Set("client_id", options.OidcClientId)
+	form.Set("client_secret", options.OidcClientSecret)
+	form.Set("code", code)
+	form.Set("grant_type", "authorization_code")
+	form.Set("redirect_uri", oidc.GetCallbackUrl(r))
+
+	c := http.Client{Timeout: OidcTimeout}
+
+	res, err := c.PostForm(provider.TokenEndpoint, form)
+	if err != nil {
+		return nil, "", formatError(oidcStripUrlError(err))
+	}
+	defer res.Body.Close()
+
+	if err = json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, "", formatError(err)
+	}
+
+	if len(response.Error) > 0 {
+		return nil, "", formatError(fmt.Errorf("%s %s", response.Error, response.ErrorDescription))
+	}
+
+	if res.StatusCode != http.StatusOK || len(response.IdToken) == 0 {
+		return nil, "", formatError(fmt.Errorf("bad token response: %s", res.Status))
+	}
+
+	claims := jwt.MapClaims{}
+
+	if _, err = jwt.ParseWithClaims(response.IdToken, claims, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodECDSA, *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		default:
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		kid, _ := token.Header["kid"].(string)
+
+		return oidc.GetKey(provider, kid)
+	}); err != nil {
+		return nil, "", formatError(err)
+	}
+
+	if !claims.VerifyIssuer(provider.Issuer, true) {
+		return nil, "", formatError(errors.New("invalid issuer"))
+	}
+
+	if !claims.VerifyAudience(options.OidcClientId, true) {
+		return nil, "", formatError(errors.New("invalid audience"))
+	}
+
+	if nonce, _ := claims["nonce"].(string); nonce != request.Nonce {
+		return nil, "", formatError(errors.New("invalid nonce"))
+	}
+
+	user := &OidcUser{Groups: []string{}}
+
+	if email, ok := claims["email"].(string); ok && claims["email_verified"] == true {
+		user.Email = email
+	}
+
+	for _, s := range []interface{}{claims["preferred_username"], user.Email, claims["sub"]} {
+		if s, ok := s.(string); ok && len(s) > 0 {
+			user.Name = s
+			break
+		}
+	}
+
+	if len(user.Name) == 0 {
+		return nil, "", formatError(errors.New("no subject"))
+	}
+
+	groupsClaim := options.OidcGroupsClaim
+	if len(groupsClaim) == 0 {
+		groupsClaim = defaults.options.oidcGroupsClaim
+	}
+
+	switch v := claims[groupsClaim].(type) {
+	case []interface{}:
+		for _, g := range v {
+			if s, ok := g.(string); ok {
+				user.Groups = append(user.Groups, s)
+			}
+		}
+	case string:
+		user.Groups = append(user.Groups, v)
+	}
+
+	if request.Target == "admin" {
+		if !user.IsMember(options.OidcAdminGroups) {
+			return nil, "", formatError(fmt.Errorf("user %s not allowed for admin", user.Name))
+		}
+
+		if len(strings.TrimSpace(options.OidcAllowedGroups)) > 0 && !user.IsMember(options.OidcAllowedGroups) {
+			return nil, "", formatError(fmt.Errorf("user %s not allowed", user.Name))
+		}
+
+	} else if !user.IsMember(options.OidcAllowedGroups) {
+		return nil, "", formatError(fmt.Errorf("user %s not allowed", user.Name))
+	}
+
+	return user, request.Target, nil
+}
+
+func (oidc *Oidc) GetAccess(code string) (*Access, bool) {
+	if !oidc.IsListenerEnabled() || !strings.HasPrefix(code, "oidc:") {
+		return nil, false
+	}
+
+	claims := &jwt.RegisteredClaims{}
+
+	token, err := jwt.ParseWithClaims(strings.TrimPrefix(code, "oidc:"), claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		return oidc.getListenerKey(), nil
+	})
+	if err != nil || !token.Valid || len(claims.Subject) == 0 {
+		return nil, false
+	}
+
+	access := &Access{
+		Code:    code,
+		Ident:   fmt.Sprintf("sso:%s", claims.Subject),
+		Systems: "*",
+	}
+
+	if claims.ExpiresAt != nil {
+		access.Expiration = claims.ExpiresAt.Time.UTC()
+	}
+
+	return access, true
+}
+
+func (oidc *Oidc) GetCallbackUrl(r *http.Request) string {
+	return fmt.Sprintf("%s/api/oidc/callback", oidc.getBaseUrl(r))
+}
+
+func (oidc *Oidc) GetKey(provider *OidcProvider, kid string) (interface{}, error) {
+	oidc.mutex.Lock()
+	defer oidc.mutex.Unlock()
+
+	if key, ok := oidc.lookupKey(kid); ok && time.Since(oidc.keysFetched) < OidcKeysTtl {
+		return key, nil
+	}
+
+	if time.Since(oidc.keysFetched) > time.Minute {
+		keys, err := oidcFetchKeys(provider.JwksUri)
+		if err != nil {
+			return nil, err
+		}
+
+		oidc.keys = keys
+		oidc.keysFetched = time.Now()
+	}
+
+	if key, ok := oidc.lookupKey(kid); ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unknown key %s", kid)
+}
+
+func (oidc *Oidc) GetListenerCode(user *OidcUser) (string, error) {
+	now := time.Now()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(OidcListenerExpiry)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		Subject:   user.Name,
+	})
+
+	s, err := token.SignedString(oidc.getListenerKey())
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("oidc:%s", s), nil
+}
+
+func (oidc *Oidc) GetLoginUrl(r *http.Request, target string) (string, error) {
+	provider, err := oidc.GetProvider()
+	if err != nil {
+		return "", err
+	}
+
+	state, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	nonce, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(provider.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("client_id", oidc.Controller.Options.OidcClientId)
+	q.Set("nonce", nonce.String())
+	q.Set("redirect_uri", oidc.GetCallbackUrl(r))
+	q.Set("response_type", "code")
+	q.Set("scope", "openid profile email")
+	q.Set("state", state.String())
+	u.RawQuery = q.Encode()
+
+	oidc.mutex.Lock()
+	for k, request := range oidc.requests {
+		if time.Since(request.Created) > OidcRequestTimeout {
+			delete(oidc.requests, k)
+		}
+	}
+	oidc.requests[state.String()] = &OidcRequest{
+		Created: time.Now(),
+		Nonce:   nonce.String(),
+		Target:  target,
+	}
+	oidc.mutex.Unlock()
+
+	return u.String(), nil
+}
+
+func (oidc *Oidc) GetProvider() (*OidcProvider, error) {
+	issuer := strings.TrimSuffix(strings.TrimSpace(oidc.Controller.Options.OidcIssuer), "/")
+
+	oidc.mutex.Lock()
+	defer oidc.mutex.Unlock()
+
+	if oidc.provider != nil && oidc.issuer == issuer && time.Since(oidc.fetched) < OidcDiscoveryTtl {
+		return oidc.provider, nil
+	}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("oidc.getprovider: %v", err)
+	}
+
+	c := http.Client{Timeout: OidcTimeout}
+
+	res, err := c.Get(fmt.Sprintf("%s/.well-known/openid-configuration", issuer))
+	if err != nil {
+		return nil, formatError(oidcStripUrlError(err))
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, formatError(fmt.Errorf("bad status: %s", res.Status))
+	}
+
+	provider := &OidcProvider{}
+	if err = json.NewDecoder(res.Body).Decode(provider); err != nil {
+		return nil, formatError(err)
+	}
+
+	if strings.TrimSuffix(provider.Issuer, "/") != issuer {
+		return nil, formatError(fmt.Errorf("issuer mismatch %s", provider.Issuer))
+	}
+
+	if len(provider.AuthorizationEndpoint) == 0 || len(provider.JwksUri) == 0 || len(provider.TokenEndpoint) == 0 {
+		return nil, formatError(errors.New("incomplete provider configuration"))
+	}
+
+	if oidc.issuer != issuer {
+		oidc.keys = map[string]interface{}{}
+		oidc.keysFetched = time.Time{}
+	}
+
+	oidc.fetched = time.Now()
+	oidc.issuer = issuer
+	oidc.provider = provider
+
+	return provider, nil
+}
+
+func (oidc *Oidc) IsAdminEnabled() bool {
+	return oidc.IsEnabled() && oidc.Controller.Options.OidcAdmin && len(strings.TrimSpace(oidc.Controller.Options.OidcAdminGroups)) > 0
+}
+
+func (oidc *Oidc) IsEnabled() bool {
+	return len(oidc.Controller.Options.OidcIssuer) > 0 && len(oidc.Controller.Options.OidcClientId) > 0
+}
+
+func (oidc *Oidc) IsListenerEnabled() bool {
+	return oidc.IsEnabled() && oidc.Controller.Options.OidcListeners && len(strings.TrimSpace(oidc.Controller.Options.OidcAllowedGroups)) > 0
+}
+
+func (oidc *Oidc) getBaseUrl(r *http.Request) string {
+	if len(oidc.Controller.Options.PublicUrl) > 0 {
+		return strings.TrimSuffix(oidc.Controller.Options.PublicUrl, "/")
+	}
+
+	return fmt.Sprintf("%s://%s%s", GetRequestScheme(r), r.Host, oidc.Controller.Config.GetBasePath())
+}
+
+func (oidc *Oidc) getListenerKey() []byte {
+	return []byte(fmt.Sprintf("%s:oidc-listener", oidc.Controller.Options.secret))
+}
+
+func (oidc *Oidc) lookupKey(kid string) (interface{}, bool) {
+	if len(kid) == 0 && len(oidc.keys) == 1 {
+		for _, key := range oidc.keys {
+			return key, true
+		}
+	}
+
+	key, ok := oidc.keys[kid]
+
+	return key, ok
+}
+
+func (user *OidcUser) IsMember(list string) bool {
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+
+		switch {
+		case len(s) == 0:
+			continue
+
+		case strings.HasPrefix(s, "@"):
+			if len(user.Email) > 0 && strings.HasSuffix(strings.ToLower(user.Email), strings.ToLower(s)) {
+				return true
+			}
+
+		case strings.EqualFold(s, user.Email), strings.EqualFold(s, user.Name):
+			return true
+
+		default:
+			for _, group := range user.Groups {
+				if group == s || strings.TrimPrefix(group, "/") == s {
+					return true
+				}
+			}
+		}
+	}
+
+	return false
+}
+
+func oidcFetchKeys(jwksUri string) (map[string]interface{}, error) {
+	var jwks struct {
+		Keys []struct {
+			Crv string `json:"crv"`
+			E   string `json:"e"`
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+			Use string `json:"use"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("oidc.fetchkeys: %v", err)
+	}
+
+	c := http.Client{Timeout: OidcTimeout}
+
+	res, err := c.Get(jwksUri)
+	if err != nil {
+		return nil, formatError(oidcStripUrlError(err))
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, formatError(fmt.Errorf("bad status: %s", res.Status))
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&jwks); err != nil {
+		return nil, formatError(err)
+	}
+
+	keys := map[string]interface{}{}
+
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	for _, k := range jwks.Keys {
+		if len(k.Use) > 0 && k.Use != "sig" {
+			continue
+		}
+
+		switch k.Kty {
+		case "EC":
+			var curve elliptic.Curve
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+
+			x, err := decode(k.X)
+			if err != nil {
+				continue
+			}
+
+			y, err := decode(k.Y)
+			if err != nil {
+				continue
+			}
+
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+
+		case "RSA":
+			n, err := decode(k.N)
+			if err != nil {
+				continue
+			}
+
+			e, err := decode(k.E)
+			if err != nil || !e.IsInt64() {
+				continue
+			}
+
+			keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, formatError(errors.New("no usable signing keys"))
+	}
+
+	return keys, nil
+}
+
+func oidcStripUrlError(err error) error {
+	if e, ok := err.(*url.Error); ok {
+		return e.Err
+	}
+
+	return err
+}
+
+func (api *Api) OidcHandler(w http.ResponseWriter, r *http.Request) {
+	oidc := api.Controller.Oidc
+
+	switch strings.TrimPrefix(r.URL.Path, "/api/oidc/") {
+	case "":
+		if b, err := json.Marshal(map[string]interface{}{"admin": oidc.IsAdminEnabled(), "listener": oidc.IsListenerEnabled()}); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	case "callback":
+		if !oidc.IsEnabled() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if e := r.URL.Query().Get("error"); len(e) > 0 {
+			api.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("oidc: identity provider error %s ip=%v", e, GetRemoteAddr(r)))
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Single sign-on failed\n"))
+			return
+		}
+
+		user, target, err := oidc.Exchange(r, r.URL.Query().Get("code"), r.URL.Query().Get("state"))
+		if err != nil {
+			api.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("%s ip=%v", err.Error(), GetRemoteAddr(r)))
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Single sign-on failed\n"))
+			return
+		}
+
+		switch target {
+		case "admin":
+			if !oidc.IsAdminEnabled() {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			token, refresh, err := api.Controller.Admin.issueToken(GetSsoSubject(user.Name, api.Controller.Options.SsoRole))
+			if err != nil {
+				w.WriteHeader(http.StatusExpectationFailed)
+				return
+			}
+
+			api.Controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("admin single sign-on user=\"%s\" ip=%v", user.Name, GetRemoteAddr(r)))
+
+			w.Header().Set("Location", fmt.Sprintf("../../admin#token=%s&refresh=%s", url.QueryEscape(token), url.QueryEscape(refresh)))
+			w.WriteHeader(http.StatusSeeOther)
+
+		default:
+			if !oidc.IsListenerEnabled() {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
+			code, err := oidc.GetListenerCode(user)
+			if err != nil {
+				w.WriteHeader(http.StatusExpectationFailed)
+				return
+			}
+
+			api.Controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("listener single sign-on user=\"%s\" ip=%v", user.Name, GetRemoteAddr(r)))
+
+			w.Header().Set("Location", fmt.Sprintf("../../#pin=%s", url.QueryEscape(code)))
+			w.WriteHeader(http.StatusSeeOther)
+		}
+
+	case "login":
+		target := r.URL.Query().Get("target")
+
+		if (target == "admin" && !oidc.IsAdminEnabled()) || (target != "admin" && !oidc.IsListenerEnabled()) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if target != "admin" {
+			target = "listener"
+		}
+
+		if u, err := oidc.GetLoginUrl(r, target); err == nil {
+			http.Redirect(w, r, u, http.StatusFound)
+		} else {
+			api.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("api.oidchandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
@@ -19,30 +19,139 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"math"
 	"sync"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 type Options struct {
+	AccessLog                   string `json:"accessLog" enum:",database,stdout"`
+	AdminSessionExpiry          uint   `json:"adminSessionExpiry" min:"1"`
+	AdminTokenExpiry            uint   `json:"adminTokenExpiry" min:"1"`
 	AfsSystems                  string `json:"afsSystems"`
+	AlertPushoverToken          string `json:"alertPushoverToken" secret:"true"`
+	AlertSmtpFrom               string `json:"alertSmtpFrom"`
+	AlertSmtpPassword           string `json:"alertSmtpPassword" secret:"true"`
+	AlertSmtpServer             string `json:"alertSmtpServer"`
+	AlertSmtpUsername           string `json:"alertSmtpUsername"`
+	AlertTelegramToken          string `json:"alertTelegramToken" secret:"true"`
+	AnomalyDetection            bool   `json:"anomalyDetection"`
+	AnomalySilenceDays          uint   `json:"anomalySilenceDays" min:"1"`
+	AnomalyVolumeFactor         uint   `json:"anomalyVolumeFactor" min:"2"`
+	AudioBitrate                uint   `json:"audioBitrate" min:"8" max:"320"`
+	AudioChannels               uint   `json:"audioChannels" max:"2"`
+	AudioCodec                  string `json:"audioCodec" enum:"aac,mp3,opus"`
+	AudioLoudnessTarget         int    `json:"audioLoudnessTarget" min:"-70" max:"-5"`
+	AudioNormalization          bool   `json:"audioNormalization"`
+	AudioStorage                string `json:"audioStorage" enum:"database,s3"`
+	AudioStorageS3AccessKey     string `json:"audioStorageS3AccessKey"`
+	AudioStorageS3Bucket        string `json:"audioStorageS3Bucket"`
+	AudioStorageS3Endpoint      string `json:"audioStorageS3Endpoint"`
+	AudioStorageS3PathStyle     bool   `json:"audioStorageS3PathStyle"`
+	AudioStorageS3Prefix        string `json:"audioStorageS3Prefix"`
+	AudioStorageS3Region        string `json:"audioStorageS3Region"`
+	AudioStorageS3SecretKey     string `json:"audioStorageS3SecretKey" secret:"true"`
+	AuthWebhook                 string `json:"authWebhook"`
+	AuthWebhookSecret           string `json:"authWebhookSecret" secret:"true"`
 	AutoPopulate                bool   `json:"autoPopulate"`
+	BackupDirectory             string `json:"backupDirectory"`
+	BackupRetention             uint   `json:"backupRetention"`
+	BackupS3Bucket              string `json:"backupS3Bucket"`
+	BackupSchedule              string `json:"backupSchedule"`
+	BroadcastDedupWindow        uint   `json:"broadcastDedupWindow"`
+	CheckForUpdates             bool   `json:"checkForUpdates"`
+	ClockSkewCorrection         bool   `json:"clockSkewCorrection"`
+	ClockSkewThreshold          uint   `json:"clockSkewThreshold" max:"86400"`
+	ConversationGap             uint   `json:"conversationGap"`
+	ConversionWorkers           uint   `json:"conversionWorkers" min:"1" max:"64" restart:"true"`
 	DimmerDelay                 uint   `json:"dimmerDelay"`
+	DirectoryLocation           string `json:"directoryLocation"`
+	DirectoryName               string `json:"directoryName"`
+	DirectoryPublish            bool   `json:"directoryPublish"`
+	DirectoryUrl                string `json:"directoryUrl"`
 	DisableAudioConversion      bool   `json:"disableAudioConversion"`
 	DisableDuplicateDetection   bool   `json:"disableDuplicateDetection"`
+	DiskWarningPercent          uint   `json:"diskWarningPercent" max:"100"`
 	DuplicateDetectionTimeFrame uint   `json:"duplicateDetectionTimeFrame"`
+	DuplicateFingerprintMatch   uint   `json:"duplicateFingerprintMatch" min:"50" max:"100"`
+	DuplicateFingerprintWindow  uint   `json:"duplicateFingerprintWindow" max:"60000"`
+	HeartbeatTimeout            uint   `json:"heartbeatTimeout"`
+	IncidentDetection           bool   `json:"incidentDetection"`
+	IncidentDetectionMinCalls   uint   `json:"incidentDetectionMinCalls" min:"1"`
+	IncidentDetectionThreshold  uint   `json:"incidentDetectionThreshold" min:"1"`
+	IncidentDetectionWindow     uint   `json:"incidentDetectionWindow" min:"1"`
+	IngestCallbacks             bool   `json:"ingestCallbacks"`
 	KeypadBeeps                 string `json:"keypadBeeps"`
-	MaxClients                  uint   `json:"maxClients"`
+	LinkedTalkgroups            bool   `json:"linkedTalkgroups"`
+	ListeningRooms              bool   `json:"listeningRooms"`
+	MaxCallDuration             uint   `json:"maxCallDuration"`
+	MaxCallSize                 uint   `json:"maxCallSize"`
+	MaxClients                  uint   `json:"maxClients" min:"1"`
+	OidcAdmin                   bool   `json:"oidcAdmin"`
+	OidcAdminGroups             string `json:"oidcAdminGroups"`
+	OidcAllowedGroups           string `json:"oidcAllowedGroups"`
+	OidcClientId                string `json:"oidcClientId"`
+	OidcClientSecret            string `json:"oidcClientSecret" secret:"true"`
+	OidcGroupsClaim             string `json:"oidcGroupsClaim"`
+	OidcIssuer                  string `json:"oidcIssuer"`
+	OidcListeners               bool   `json:"oidcListeners"`
 	PlaybackGoesLive            bool   `json:"playbackGoesLive"`
 	PruneDays                   uint   `json:"pruneDays"`
+	PublicUrl                   string `json:"publicUrl"`
+	RadioReferenceAppKey        string `json:"radioReferenceAppKey"`
+	RadioReferenceInterval      uint   `json:"radioReferenceInterval" min:"1"`
+	RadioReferencePassword      string `json:"radioReferencePassword" secret:"true"`
+	RadioReferenceUsername      string `json:"radioReferenceUsername"`
+	RateLimitAdmin              uint   `json:"rateLimitAdmin"`
+	RateLimitBanDuration        uint   `json:"rateLimitBanDuration"`
+	RateLimitBanFailures        uint   `json:"rateLimitBanFailures"`
+	RateLimitBanRejections      uint   `json:"rateLimitBanRejections"`
+	RateLimitUpload             uint   `json:"rateLimitUpload"`
+	RateLimitWebsocket          uint   `json:"rateLimitWebsocket"`
+	RelatedCallsWindow          uint   `json:"relatedCallsWindow"`
+	SamlAllowedGroups           string `json:"samlAllowedGroups"`
+	SamlAllowedUsers            string `json:"samlAllowedUsers"`
+	SamlEntityId                string `json:"samlEntityId"`
+	SamlGroupRoles              string `json:"samlGroupRoles"`
+	SamlGroupsAttribute         string `json:"samlGroupsAttribute"`
+	SamlIdpCertificate          string `json:"samlIdpCertificate"`
+	SamlIdpUrl                  string `json:"samlIdpUrl"`
+	ScheduledRestart            bool   `json:"scheduledRestart"`
+	ScheduledRestartHour        uint   `json:"scheduledRestartHour" max:"23"`
 	SearchPatchedTalkgroups     bool   `json:"searchPatchedTalkgroups"`
+	ServerQueue                 bool   `json:"serverQueue"`
+	ServerQueueMaxDepth         uint   `json:"serverQueueMaxDepth"`
+	ServerQueueSkipPolicy       string `json:"serverQueueSkipPolicy" enum:"lowest,newest,oldest"`
+	ServerTiming                bool   `json:"serverTiming"`
 	ShowListenersCount          bool   `json:"showListenersCount"`
+	SilenceAlert                uint   `json:"silenceAlert"`
+	SilenceAlertFrom            uint   `json:"silenceAlertFrom" max:"23"`
+	SilenceAlertTo              uint   `json:"silenceAlertTo" min:"1" max:"24"`
 	SortTalkgroups              bool   `json:"sortTalkgroups"`
+	SsoRole                     string `json:"ssoRole" enum:"admin,editor,viewer"`
 	TagsToggle                  bool   `json:"tagsToggle"`
+	Telemetry                   bool   `json:"telemetry"`
+	TelemetryUrl                string `json:"telemetryUrl"`
+	Transcription               string `json:"transcription" enum:",google,webhook,whisper,whisper-api"`
+	TranscriptionApiKey         string `json:"transcriptionApiKey" secret:"true"`
+	TranscriptionCommand        string `json:"transcriptionCommand"`
+	TranscriptionLanguage       string `json:"transcriptionLanguage"`
+	TranscriptionModel          string `json:"transcriptionModel"`
+	TranscriptionUrl            string `json:"transcriptionUrl"`
+	TruncateLongCalls           bool   `json:"truncateLongCalls"`
+	TrustedProxies              string `json:"trustedProxies"`
+	UnitsImportInterval         uint   `json:"unitsImportInterval" min:"1"`
+	VotingWindow                uint   `json:"votingWindow"`
+	WatchdogSelfHeal            bool   `json:"watchdogSelfHeal"`
+	WatchdogStallTimeout        uint   `json:"watchdogStallTimeout" min:"10"`
 	adminPassword               string
 	adminPasswordNeedChange     bool
 	mutex                       sync.Mutex
+	pending                     map[string]interface{}
 	secret                      string
+	started                     bool
 }
 
 func NewOptions() *Options {
@@ -54,71 +163,653 @@ func NewOptions() *Options {
 func (options *Options) FromMap(m map[string]interface{}) *Options {
 	options.mutex.Lock()
 	defer options.mutex.Unlock()
+	defer options.queueRestartValues(options.getRunningValues())
+
+	switch v := m["accessLog"].(type) {
+	case string:
+		options.AccessLog = v
+	default:
+		options.AccessLog = defaults.options.accessLog
+	}
+
+	switch v := m["adminSessionExpiry"].(type) {
+	case float64:
+		options.AdminSessionExpiry = uint(v)
+	default:
+		options.AdminSessionExpiry = defaults.options.adminSessionExpiry
+	}
+
+	switch v := m["adminTokenExpiry"].(type) {
+	case float64:
+		options.AdminTokenExpiry = uint(v)
+	default:
+		options.AdminTokenExpiry = defaults.options.adminTokenExpiry
+	}
 
 	switch v := m["afsSystems"].(type) {
 	case string:
-		options.AfsSystems = v
+		options.AfsSystems = v
+	}
+
+	switch v := m["alertPushoverToken"].(type) {
+	case string:
+		options.AlertPushoverToken = v
+	default:
+		options.AlertPushoverToken = defaults.options.alertPushoverToken
+	}
+
+	switch v := m["alertSmtpFrom"].(type) {
+	case string:
+		options.AlertSmtpFrom = v
+	default:
+		options.AlertSmtpFrom = defaults.options.alertSmtpFrom
+	}
+
+	switch v := m["alertSmtpPassword"].(type) {
+	case string:
+		options.AlertSmtpPassword = v
+	default:
+		options.AlertSmtpPassword = defaults.options.alertSmtpPassword
+	}
+
+	switch v := m["alertSmtpServer"].(type) {
+	case string:
+		options.AlertSmtpServer = v
+	default:
+		options.AlertSmtpServer = defaults.options.alertSmtpServer
+	}
+
+	switch v := m["alertSmtpUsername"].(type) {
+	case string:
+		options.AlertSmtpUsername = v
+	default:
+		options.AlertSmtpUsername = defaults.options.alertSmtpUsername
+	}
+
+	switch v := m["alertTelegramToken"].(type) {
+	case string:
+		options.AlertTelegramToken = v
+	default:
+		options.AlertTelegramToken = defaults.options.alertTelegramToken
+	}
+
+	switch v := m["anomalyDetection"].(type) {
+	case bool:
+		options.AnomalyDetection = v
+	default:
+		options.AnomalyDetection = defaults.options.anomalyDetection
+	}
+
+	switch v := m["anomalySilenceDays"].(type) {
+	case float64:
+		options.AnomalySilenceDays = uint(v)
+	default:
+		options.AnomalySilenceDays = defaults.options.anomalySilenceDays
+	}
+
+	switch v := m["anomalyVolumeFactor"].(type) {
+	case float64:
+		options.AnomalyVolumeFactor = uint(v)
+	default:
+		options.AnomalyVolumeFactor = defaults.options.anomalyVolumeFactor
+	}
+
+	switch v := m["audioBitrate"].(type) {
+	case float64:
+		options.AudioBitrate = uint(v)
+	default:
+		options.AudioBitrate = defaults.options.audioBitrate
+	}
+
+	switch v := m["audioChannels"].(type) {
+	case float64:
+		options.AudioChannels = uint(v)
+	default:
+		options.AudioChannels = defaults.options.audioChannels
+	}
+
+	switch v := m["audioCodec"].(type) {
+	case string:
+		options.AudioCodec = v
+	default:
+		options.AudioCodec = defaults.options.audioCodec
+	}
+
+	switch v := m["audioLoudnessTarget"].(type) {
+	case float64:
+		options.AudioLoudnessTarget = int(v)
+	default:
+		options.AudioLoudnessTarget = defaults.options.audioLoudnessTarget
+	}
+
+	switch v := m["audioNormalization"].(type) {
+	case bool:
+		options.AudioNormalization = v
+	default:
+		options.AudioNormalization = defaults.options.audioNormalization
+	}
+
+	switch v := m["audioStorage"].(type) {
+	case string:
+		options.AudioStorage = v
+	default:
+		options.AudioStorage = defaults.options.audioStorage
+	}
+
+	switch v := m["audioStorageS3AccessKey"].(type) {
+	case string:
+		options.AudioStorageS3AccessKey = v
+	default:
+		options.AudioStorageS3AccessKey = defaults.options.audioStorageS3AccessKey
+	}
+
+	switch v := m["audioStorageS3Bucket"].(type) {
+	case string:
+		options.AudioStorageS3Bucket = v
+	default:
+		options.AudioStorageS3Bucket = defaults.options.audioStorageS3Bucket
+	}
+
+	switch v := m["audioStorageS3Endpoint"].(type) {
+	case string:
+		options.AudioStorageS3Endpoint = v
+	default:
+		options.AudioStorageS3Endpoint = defaults.options.audioStorageS3Endpoint
+	}
+
+	switch v := m["audioStorageS3PathStyle"].(type) {
+	case bool:
+		options.AudioStorageS3PathStyle = v
+	default:
+		options.AudioStorageS3PathStyle = defaults.options.audioStorageS3PathStyle
+	}
+
+	switch v := m["audioStorageS3Prefix"].(type) {
+	case string:
+		options.AudioStorageS3Prefix = v
+	default:
+		options.AudioStorageS3Prefix = defaults.options.audioStorageS3Prefix
+	}
+
+	switch v := m["audioStorageS3Region"].(type) {
+	case string:
+		options.AudioStorageS3Region = v
+	default:
+		options.AudioStorageS3Region = defaults.options.audioStorageS3Region
+	}
+
+	switch v := m["audioStorageS3SecretKey"].(type) {
+	case string:
+		options.AudioStorageS3SecretKey = v
+	default:
+		options.AudioStorageS3SecretKey = defaults.options.audioStorageS3SecretKey
+	}
+
+	switch v := m["authWebhook"].(type) {
+	case string:
+		options.AuthWebhook = v
+	default:
+		options.AuthWebhook = defaults.options.authWebhook
+	}
+
+	switch v := m["authWebhookSecret"].(type) {
+	case string:
+		options.AuthWebhookSecret = v
+	default:
+		options.AuthWebhookSecret = defaults.options.authWebhookSecret
+	}
+
+	switch v := m["autoPopulate"].(type) {
+	case bool:
+		options.AutoPopulate = v
+	default:
+		options.AutoPopulate = defaults.options.autoPopulate
+	}
+
+	switch v := m["backupDirectory"].(type) {
+	case string:
+		options.BackupDirectory = v
+	default:
+		options.BackupDirectory = defaults.options.backupDirectory
+	}
+
+	switch v := m["backupRetention"].(type) {
+	case float64:
+		options.BackupRetention = uint(v)
+	default:
+		options.BackupRetention = defaults.options.backupRetention
+	}
+
+	switch v := m["backupS3Bucket"].(type) {
+	case string:
+		options.BackupS3Bucket = v
+	default:
+		options.BackupS3Bucket = defaults.options.backupS3Bucket
+	}
+
+	switch v := m["backupSchedule"].(type) {
+	case string:
+		options.BackupSchedule = v
+	default:
+		options.BackupSchedule = defaults.options.backupSchedule
+	}
+
+	switch v := m["broadcastDedupWindow"].(type) {
+	case float64:
+		options.BroadcastDedupWindow = uint(v)
+	default:
+		options.BroadcastDedupWindow = defaults.options.broadcastDedupWindow
+	}
+
+	switch v := m["checkForUpdates"].(type) {
+	case bool:
+		options.CheckForUpdates = v
+	default:
+		options.CheckForUpdates = defaults.options.checkForUpdates
+	}
+
+	switch v := m["clockSkewCorrection"].(type) {
+	case bool:
+		options.ClockSkewCorrection = v
+	default:
+		options.ClockSkewCorrection = defaults.options.clockSkewCorrection
+	}
+
+	switch v := m["clockSkewThreshold"].(type) {
+	case float64:
+		options.ClockSkewThreshold = uint(v)
+	default:
+		options.ClockSkewThreshold = defaults.options.clockSkewThreshold
+	}
+
+	switch v := m["conversationGap"].(type) {
+	case float64:
+		options.ConversationGap = uint(v)
+	default:
+		options.ConversationGap = defaults.options.conversationGap
+	}
+
+	switch v := m["conversionWorkers"].(type) {
+	case float64:
+		options.ConversionWorkers = uint(v)
+	default:
+		options.ConversionWorkers = defaults.options.conversionWorkers
+	}
+
+	switch v := m["dimmerDelay"].(type) {
+	case float64:
+		options.DimmerDelay = uint(v)
+	default:
+		options.DimmerDelay = defaults.options.dimmerDelay
+	}
+
+	switch v := m["directoryLocation"].(type) {
+	case string:
+		options.DirectoryLocation = v
+	default:
+		options.DirectoryLocation = defaults.options.directoryLocation
+	}
+
+	switch v := m["directoryName"].(type) {
+	case string:
+		options.DirectoryName = v
+	default:
+		options.DirectoryName = defaults.options.directoryName
+	}
+
+	switch v := m["directoryPublish"].(type) {
+	case bool:
+		options.DirectoryPublish = v
+	default:
+		options.DirectoryPublish = defaults.options.directoryPublish
+	}
+
+	switch v := m["directoryUrl"].(type) {
+	case string:
+		options.DirectoryUrl = v
+	default:
+		options.DirectoryUrl = defaults.options.directoryUrl
+	}
+
+	switch v := m["disableAudioConversion"].(type) {
+	case bool:
+		options.DisableAudioConversion = v
+	default:
+		options.DisableAudioConversion = defaults.options.disableAudioConversion
+	}
+
+	switch v := m["disableDuplicateDetection"].(type) {
+	case bool:
+		options.DisableDuplicateDetection = v
+	default:
+		options.DisableDuplicateDetection = defaults.options.disableDuplicateDetection
+	}
+
+	switch v := m["diskWarningPercent"].(type) {
+	case float64:
+		options.DiskWarningPercent = uint(v)
+	default:
+		options.DiskWarningPercent = defaults.options.diskWarningPercent
+	}
+
+	switch v := m["duplicateDetectionTimeFrame"].(type) {
+	case float64:
+		options.DuplicateDetectionTimeFrame = uint(v)
+	default:
+		options.DuplicateDetectionTimeFrame = defaults.options.duplicateDetectionTimeFrame
+	}
+
+	switch v := m["duplicateFingerprintMatch"].(type) {
+	case float64:
+		options.DuplicateFingerprintMatch = uint(v)
+	default:
+		options.DuplicateFingerprintMatch = defaults.options.duplicateFingerprintMatch
+	}
+
+	switch v := m["duplicateFingerprintWindow"].(type) {
+	case float64:
+		options.DuplicateFingerprintWindow = uint(v)
+	default:
+		options.DuplicateFingerprintWindow = defaults.options.duplicateFingerprintWindow
+	}
+
+	switch v := m["heartbeatTimeout"].(type) {
+	case float64:
+		options.HeartbeatTimeout = uint(v)
+	default:
+		options.HeartbeatTimeout = defaults.options.heartbeatTimeout
+	}
+
+	switch v := m["incidentDetection"].(type) {
+	case bool:
+		options.IncidentDetection = v
+	default:
+		options.IncidentDetection = defaults.options.incidentDetection
+	}
+
+	switch v := m["incidentDetectionMinCalls"].(type) {
+	case float64:
+		options.IncidentDetectionMinCalls = uint(v)
+	default:
+		options.IncidentDetectionMinCalls = defaults.options.incidentDetectionMinCalls
+	}
+
+	switch v := m["incidentDetectionThreshold"].(type) {
+	case float64:
+		options.IncidentDetectionThreshold = uint(v)
+	default:
+		options.IncidentDetectionThreshold = defaults.options.incidentDetectionThreshold
+	}
+
+	switch v := m["incidentDetectionWindow"].(type) {
+	case float64:
+		options.IncidentDetectionWindow = uint(v)
+	default:
+		options.IncidentDetectionWindow = defaults.options.incidentDetectionWindow
+	}
+
+	switch v := m["ingestCallbacks"].(type) {
+	case bool:
+		options.IngestCallbacks = v
+	default:
+		options.IngestCallbacks = defaults.options.ingestCallbacks
+	}
+
+	switch v := m["keypadBeeps"].(type) {
+	case string:
+		options.KeypadBeeps = v
+	default:
+		options.KeypadBeeps = defaults.options.keypadBeeps
+	}
+
+	switch v := m["linkedTalkgroups"].(type) {
+	case bool:
+		options.LinkedTalkgroups = v
+	default:
+		options.LinkedTalkgroups = defaults.options.linkedTalkgroups
+	}
+
+	switch v := m["listeningRooms"].(type) {
+	case bool:
+		options.ListeningRooms = v
+	default:
+		options.ListeningRooms = defaults.options.listeningRooms
+	}
+
+	switch v := m["maxCallDuration"].(type) {
+	case float64:
+		options.MaxCallDuration = uint(v)
+	default:
+		options.MaxCallDuration = defaults.options.maxCallDuration
+	}
+
+	switch v := m["maxCallSize"].(type) {
+	case float64:
+		options.MaxCallSize = uint(v)
+	default:
+		options.MaxCallSize = defaults.options.maxCallSize
+	}
+
+	switch v := m["maxClients"].(type) {
+	case float64:
+		options.MaxClients = uint(v)
+	default:
+		options.MaxClients = defaults.options.maxClients
+	}
+
+	switch v := m["oidcAdmin"].(type) {
+	case bool:
+		options.OidcAdmin = v
+	default:
+		options.OidcAdmin = defaults.options.oidcAdmin
+	}
+
+	switch v := m["oidcAdminGroups"].(type) {
+	case string:
+		options.OidcAdminGroups = v
+	default:
+		options.OidcAdminGroups = defaults.options.oidcAdminGroups
+	}
+
+	switch v := m["oidcAllowedGroups"].(type) {
+	case string:
+		options.OidcAllowedGroups = v
+	default:
+		options.OidcAllowedGroups = defaults.options.oidcAllowedGroups
+	}
+
+	switch v := m["oidcClientId"].(type) {
+	case string:
+		options.OidcClientId = v
+	default:
+		options.OidcClientId = defaults.options.oidcClientId
+	}
+
+	switch v := m["oidcClientSecret"].(type) {
+	case string:
+		options.OidcClientSecret = v
+	default:
+		options.OidcClientSecret = defaults.options.oidcClientSecret
+	}
+
+	switch v := m["oidcGroupsClaim"].(type) {
+	case string:
+		options.OidcGroupsClaim = v
+	default:
+		options.OidcGroupsClaim = defaults.options.oidcGroupsClaim
+	}
+
+	switch v := m["oidcIssuer"].(type) {
+	case string:
+		options.OidcIssuer = v
+	default:
+		options.OidcIssuer = defaults.options.oidcIssuer
+	}
+
+	switch v := m["oidcListeners"].(type) {
+	case bool:
+		options.OidcListeners = v
+	default:
+		options.OidcListeners = defaults.options.oidcListeners
+	}
+
+	switch v := m["playbackGoesLive"].(type) {
+	case bool:
+		options.PlaybackGoesLive = v
+	}
+
+	switch v := m["pruneDays"].(type) {
+	case float64:
+		options.PruneDays = uint(v)
+	default:
+		options.PruneDays = defaults.options.pruneDays
+	}
+
+	switch v := m["publicUrl"].(type) {
+	case string:
+		options.PublicUrl = v
+	default:
+		options.PublicUrl = defaults.options.publicUrl
+	}
+
+	switch v := m["radioReferenceAppKey"].(type) {
+	case string:
+		options.RadioReferenceAppKey = v
+	default:
+		options.RadioReferenceAppKey = defaults.options.radioReferenceAppKey
+	}
+
+	switch v := m["radioReferenceInterval"].(type) {
+	case float64:
+		options.RadioReferenceInterval = uint(v)
+	default:
+		options.RadioReferenceInterval = defaults.options.radioReferenceInterval
+	}
+
+	switch v := m["radioReferencePassword"].(type) {
+	case string:
+		options.RadioReferencePassword = v
+	default:
+		options.RadioReferencePassword = defaults.options.radioReferencePassword
 	}
 
-	switch v := m["autoPopulate"].(type) {
-	case bool:
-		options.AutoPopulate = v
+	switch v := m["radioReferenceUsername"].(type) {
+	case string:
+		options.RadioReferenceUsername = v
 	default:
-		options.AutoPopulate = defaults.options.autoPopulate
+		options.RadioReferenceUsername = defaults.options.radioReferenceUsername
 	}
 
-	switch v := m["dimmerDelay"].(type) {
+	switch v := m["rateLimitAdmin"].(type) {
 	case float64:
-		options.DimmerDelay = uint(v)
+		options.RateLimitAdmin = uint(v)
 	default:
-		options.DimmerDelay = defaults.options.dimmerDelay
+		options.RateLimitAdmin = defaults.options.rateLimitAdmin
 	}
 
-	switch v := m["disableAudioConversion"].(type) {
-	case bool:
-		options.DisableAudioConversion = v
+	switch v := m["rateLimitBanDuration"].(type) {
+	case float64:
+		options.RateLimitBanDuration = uint(v)
 	default:
-		options.DisableAudioConversion = defaults.options.disableAudioConversion
+		options.RateLimitBanDuration = defaults.options.rateLimitBanDuration
 	}
 
-	switch v := m["disableDuplicateDetection"].(type) {
-	case bool:
-		options.DisableDuplicateDetection = v
+	switch v := m["rateLimitBanFailures"].(type) {
+	case float64:
+		options.RateLimitBanFailures = uint(v)
 	default:
-		options.DisableDuplicateDetection = defaults.options.disableDuplicateDetection
+		options.RateLimitBanFailures = defaults.options.rateLimitBanFailures
 	}
 
-	switch v := m["duplicateDetectionTimeFrame"].(type) {
+	switch v := m["rateLimitBanRejections"].(type) {
 	case float64:
-		options.DuplicateDetectionTimeFrame = uint(v)
+		options.RateLimitBanRejections = uint(v)
 	default:
-		options.DuplicateDetectionTimeFrame = defaults.options.duplicateDetectionTimeFrame
+		options.RateLimitBanRejections = defaults.options.rateLimitBanRejections
 	}
 
-	switch v := m["keypadBeeps"].(type) {
-	case string:
-		options.KeypadBeeps = v
+	switch v := m["rateLimitUpload"].(type) {
+	case float64:
+		options.RateLimitUpload = uint(v)
 	default:
-		options.KeypadBeeps = defaults.options.keypadBeeps
+		options.RateLimitUpload = defaults.options.rateLimitUpload
 	}
 
-	switch v := m["maxClients"].(type) {
+	switch v := m["rateLimitWebsocket"].(type) {
 	case float64:
-		options.MaxClients = uint(v)
+		options.RateLimitWebsocket = uint(v)
 	default:
-		options.MaxClients = defaults.options.maxClients
+		options.RateLimitWebsocket = defaults.options.rateLimitWebsocket
 	}
 
-	switch v := m["playbackGoesLive"].(type) {
+	switch v := m["relatedCallsWindow"].(type) {
+	case float64:
+		options.RelatedCallsWindow = uint(v)
+	default:
+		options.RelatedCallsWindow = defaults.options.relatedCallsWindow
+	}
+
+	switch v := m["samlAllowedGroups"].(type) {
+	case string:
+		options.SamlAllowedGroups = v
+	default:
+		options.SamlAllowedGroups = defaults.options.samlAllowedGroups
+	}
+
+	switch v := m["samlAllowedUsers"].(type) {
+	case string:
+		options.SamlAllowedUsers = v
+	default:
+		options.SamlAllowedUsers = defaults.options.samlAllowedUsers
+	}
+
+	switch v := m["samlEntityId"].(type) {
+	case string:
+		options.SamlEntityId = v
+	default:
+		options.SamlEntityId = defaults.options.samlEntityId
+	}
+
+	switch v := m["samlGroupRoles"].(type) {
+	case string:
+		options.SamlGroupRoles = v
+	default:
+		options.SamlGroupRoles = defaults.options.samlGroupRoles
+	}
+
+	switch v := m["samlGroupsAttribute"].(type) {
+	case string:
+		options.SamlGroupsAttribute = v
+	default:
+		options.SamlGroupsAttribute = defaults.options.samlGroupsAttribute
+	}
+
+	switch v := m["samlIdpCertificate"].(type) {
+	case string:
+		options.SamlIdpCertificate = v
+	default:
+		options.SamlIdpCertificate = defaults.options.samlIdpCertificate
+	}
+
+	switch v := m["samlIdpUrl"].(type) {
+	case string:
+		options.SamlIdpUrl = v
+	default:
+		options.SamlIdpUrl = defaults.options.samlIdpUrl
+	}
+
+	switch v := m["scheduledRestart"].(type) {
 	case bool:
-		options.PlaybackGoesLive = v
+		options.ScheduledRestart = v
+	default:
+		options.ScheduledRestart = defaults.options.scheduledRestart
 	}
 
-	switch v := m["pruneDays"].(type) {
+	switch v := m["scheduledRestartHour"].(type) {
 	case float64:
-		options.PruneDays = uint(v)
+		options.ScheduledRestartHour = uint(v)
 	default:
-		options.PruneDays = defaults.options.pruneDays
+		options.ScheduledRestartHour = defaults.options.scheduledRestartHour
 	}
 
 	switch v := m["searchPatchedTalkgroups"].(type) {
@@ -128,6 +819,34 @@ func (options *Options) FromMap(m map[string]interface{}) *Options {
 		options.SearchPatchedTalkgroups = defaults.options.searchPatchedTalkgroups
 	}
 
+	switch v := m["serverQueue"].(type) {
+	case bool:
+		options.ServerQueue = v
+	default:
+		options.ServerQueue = defaults.options.serverQueue
+	}
+
+	switch v := m["serverQueueMaxDepth"].(type) {
+	case float64:
+		options.ServerQueueMaxDepth = uint(v)
+	default:
+		options.ServerQueueMaxDepth = defaults.options.serverQueueMaxDepth
+	}
+
+	switch v := m["serverQueueSkipPolicy"].(type) {
+	case string:
+		options.ServerQueueSkipPolicy = v
+	default:
+		options.ServerQueueSkipPolicy = defaults.options.serverQueueSkipPolicy
+	}
+
+	switch v := m["serverTiming"].(type) {
+	case bool:
+		options.ServerTiming = v
+	default:
+		options.ServerTiming = defaults.options.serverTiming
+	}
+
 	switch v := m["showListenersCount"].(type) {
 	case bool:
 		options.ShowListenersCount = v
@@ -135,6 +854,27 @@ func (options *Options) FromMap(m map[string]interface{}) *Options {
 		options.ShowListenersCount = defaults.options.showListenersCount
 	}
 
+	switch v := m["silenceAlert"].(type) {
+	case float64:
+		options.SilenceAlert = uint(v)
+	default:
+		options.SilenceAlert = defaults.options.silenceAlert
+	}
+
+	switch v := m["silenceAlertFrom"].(type) {
+	case float64:
+		options.SilenceAlertFrom = uint(v)
+	default:
+		options.SilenceAlertFrom = defaults.options.silenceAlertFrom
+	}
+
+	switch v := m["silenceAlertTo"].(type) {
+	case float64:
+		options.SilenceAlertTo = uint(v)
+	default:
+		options.SilenceAlertTo = defaults.options.silenceAlertTo
+	}
+
 	switch v := m["sortTalkgroups"].(type) {
 	case bool:
 		options.SortTalkgroups = v
@@ -142,6 +882,13 @@ func (options *Options) FromMap(m map[string]interface{}) *Options {
 		options.SortTalkgroups = defaults.options.sortTalkgroups
 	}
 
+	switch v := m["ssoRole"].(type) {
+	case string:
+		options.SsoRole = v
+	default:
+		options.SsoRole = defaults.options.ssoRole
+	}
+
 	switch v := m["tagsToggle"].(type) {
 	case bool:
 		options.TagsToggle = v
@@ -149,9 +896,128 @@ func (options *Options) FromMap(m map[string]interface{}) *Options {
 		options.TagsToggle = defaults.options.tagsToggle
 	}
 
+	switch v := m["telemetry"].(type) {
+	case bool:
+		options.Telemetry = v
+	default:
+		options.Telemetry = defaults.options.telemetry
+	}
+
+	switch v := m["telemetryUrl"].(type) {
+	case string:
+		options.TelemetryUrl = v
+	default:
+		options.TelemetryUrl = defaults.options.telemetryUrl
+	}
+
+	switch v := m["transcription"].(type) {
+	case string:
+		options.Transcription = v
+	default:
+		options.Transcription = defaults.options.transcription
+	}
+
+	switch v := m["transcriptionApiKey"].(type) {
+	case string:
+		options.TranscriptionApiKey = v
+	default:
+		options.TranscriptionApiKey = defaults.options.transcriptionApiKey
+	}
+
+	switch v := m["transcriptionCommand"].(type) {
+	case string:
+		options.TranscriptionCommand = v
+	default:
+		options.TranscriptionCommand = defaults.options.transcriptionCommand
+	}
+
+	switch v := m["transcriptionLanguage"].(type) {
+	case string:
+		options.TranscriptionLanguage = v
+	default:
+		options.TranscriptionLanguage = defaults.options.transcriptionLanguage
+	}
+
+	switch v := m["transcriptionModel"].(type) {
+	case string:
+		options.TranscriptionModel = v
+	default:
+		options.TranscriptionModel = defaults.options.transcriptionModel
+	}
+
+	switch v := m["transcriptionUrl"].(type) {
+	case string:
+		options.TranscriptionUrl = v
+	default:
+		options.TranscriptionUrl = defaults.options.transcriptionUrl
+	}
+
+	switch v := m["truncateLongCalls"].(type) {
+	case bool:
+		options.TruncateLongCalls = v
+	default:
+		options.TruncateLongCalls = defaults.options.truncateLongCalls
+	}
+
+	switch v := m["trustedProxies"].(type) {
+	case string:
+		options.TrustedProxies = v
+	default:
+		options.TrustedProxies = defaults.options.trustedProxies
+	}
+
+	switch v := m["unitsImportInterval"].(type) {
+	case float64:
+		options.UnitsImportInterval = uint(v)
+	default:
+		options.UnitsImportInterval = defaults.options.unitsImportInterval
+	}
+
+	switch v := m["votingWindow"].(type) {
+	case float64:
+		options.VotingWindow = uint(v)
+	default:
+		options.VotingWindow = defaults.options.votingWindow
+	}
+
+	switch v := m["watchdogSelfHeal"].(type) {
+	case bool:
+		options.WatchdogSelfHeal = v
+	default:
+		options.WatchdogSelfHeal = defaults.options.watchdogSelfHeal
+	}
+
+	switch v := m["watchdogStallTimeout"].(type) {
+	case float64:
+		options.WatchdogStallTimeout = uint(v)
+	default:
+		options.WatchdogStallTimeout = defaults.options.watchdogStallTimeout
+	}
+
 	return options
 }
 
+func (options *Options) GetAdminSessionExpiry() time.Duration {
+	if options.AdminSessionExpiry == 0 {
+		return time.Duration(defaults.options.adminSessionExpiry) * 24 * time.Hour
+	}
+	return time.Duration(options.AdminSessionExpiry) * 24 * time.Hour
+}
+
+func (options *Options) GetAdminTokenExpiry() time.Duration {
+	if options.AdminTokenExpiry == 0 {
+		return time.Duration(defaults.options.adminTokenExpiry) * time.Minute
+	}
+	return time.Duration(options.AdminTokenExpiry) * time.Minute
+}
+
+func (options *Options) GetMaxCallSize() int64 {
+	if options.MaxCallSize == 0 {
+		return math.MaxInt32
+	}
+	return int64(options.MaxCallSize) << 20
+}
+
 func (options *Options) Read(db *Database) error {
 	var (
 		defaultPassword []byte
@@ -161,24 +1027,130 @@ func (options *Options) Read(db *Database) error {
 
 	options.mutex.Lock()
 	defer options.mutex.Unlock()
+	defer options.queueRestartValues(options.getRunningValues())
 
 	defaultPassword, _ = bcrypt.GenerateFromPassword([]byte(defaults.adminPassword), bcrypt.DefaultCost)
 
 	options.adminPassword = string(defaultPassword)
 	options.adminPasswordNeedChange = defaults.adminPasswordNeedChange
+	options.AccessLog = defaults.options.accessLog
+	options.AdminSessionExpiry = defaults.options.adminSessionExpiry
+	options.AdminTokenExpiry = defaults.options.adminTokenExpiry
+	options.AlertPushoverToken = defaults.options.alertPushoverToken
+	options.AlertSmtpFrom = defaults.options.alertSmtpFrom
+	options.AlertSmtpPassword = defaults.options.alertSmtpPassword
+	options.AlertSmtpServer = defaults.options.alertSmtpServer
+	options.AlertSmtpUsername = defaults.options.alertSmtpUsername
+	options.AlertTelegramToken = defaults.options.alertTelegramToken
+	options.AnomalyDetection = defaults.options.anomalyDetection
+	options.AnomalySilenceDays = defaults.options.anomalySilenceDays
+	options.AnomalyVolumeFactor = defaults.options.anomalyVolumeFactor
+	options.AudioBitrate = defaults.options.audioBitrate
+	options.AudioChannels = defaults.options.audioChannels
+	options.AudioCodec = defaults.options.audioCodec
+	options.AudioLoudnessTarget = defaults.options.audioLoudnessTarget
+	options.AudioNormalization = defaults.options.audioNormalization
+	options.AudioStorage = defaults.options.audioStorage
+	options.AudioStorageS3AccessKey = defaults.options.audioStorageS3AccessKey
+	options.AudioStorageS3Bucket = defaults.options.audioStorageS3Bucket
+	options.AudioStorageS3Endpoint = defaults.options.audioStorageS3Endpoint
+	options.AudioStorageS3PathStyle = defaults.options.audioStorageS3PathStyle
+	options.AudioStorageS3Prefix = defaults.options.audioStorageS3Prefix
+	options.AudioStorageS3Region = defaults.options.audioStorageS3Region
+	options.AudioStorageS3SecretKey = defaults.options.audioStorageS3SecretKey
+	options.AuthWebhook = defaults.options.authWebhook
+	options.AuthWebhookSecret = defaults.options.authWebhookSecret
 	options.AutoPopulate = defaults.options.autoPopulate
+	options.BackupDirectory = defaults.options.backupDirectory
+	options.BackupRetention = defaults.options.backupRetention
+	options.BackupS3Bucket = defaults.options.backupS3Bucket
+	options.BackupSchedule = defaults.options.backupSchedule
+	options.BroadcastDedupWindow = defaults.options.broadcastDedupWindow
+	options.CheckForUpdates = defaults.options.checkForUpdates
+	options.ClockSkewCorrection = defaults.options.clockSkewCorrection
+	options.ClockSkewThreshold = defaults.options.clockSkewThreshold
+	options.ConversationGap = defaults.options.conversationGap
+	options.ConversionWorkers = defaults.options.conversionWorkers
 	options.DimmerDelay = defaults.options.dimmerDelay
+	options.DirectoryLocation = defaults.options.directoryLocation
+	options.DirectoryName = defaults.options.directoryName
+	options.DirectoryPublish = defaults.options.directoryPublish
+	options.DirectoryUrl = defaults.options.directoryUrl
 	options.DisableAudioConversion = defaults.options.disableAudioConversion
 	options.DisableDuplicateDetection = defaults.options.disableDuplicateDetection
+	options.DiskWarningPercent = defaults.options.diskWarningPercent
 	options.DuplicateDetectionTimeFrame = defaults.options.duplicateDetectionTimeFrame
+	options.DuplicateFingerprintMatch = defaults.options.duplicateFingerprintMatch
+	options.DuplicateFingerprintWindow = defaults.options.duplicateFingerprintWindow
+	options.HeartbeatTimeout = defaults.options.heartbeatTimeout
+	options.IncidentDetection = defaults.options.incidentDetection
+	options.IncidentDetectionMinCalls = defaults.options.incidentDetectionMinCalls
+	options.IncidentDetectionThreshold = defaults.options.incidentDetectionThreshold
+	options.IncidentDetectionWindow = defaults.options.incidentDetectionWindow
+	options.IngestCallbacks = defaults.options.ingestCallbacks
 	options.KeypadBeeps = defaults.options.keypadBeeps
+	options.LinkedTalkgroups = defaults.options.linkedTalkgroups
+	options.ListeningRooms = defaults.options.listeningRooms
+	options.MaxCallDuration = defaults.options.maxCallDuration
+	options.MaxCallSize = defaults.options.maxCallSize
 	options.MaxClients = defaults.options.maxClients
+	options.OidcAdmin = defaults.options.oidcAdmin
+	options.OidcAdminGroups = defaults.options.oidcAdminGroups
+	options.OidcAllowedGroups = defaults.options.oidcAllowedGroups
+	options.OidcClientId = defaults.options.oidcClientId
+	options.OidcClientSecret = defaults.options.oidcClientSecret
+	options.OidcGroupsClaim = defaults.options.oidcGroupsClaim
+	options.OidcIssuer = defaults.options.oidcIssuer
+	options.OidcListeners = defaults.options.oidcListeners
 	options.PlaybackGoesLive = defaults.options.playbackGoesLive
 	options.PruneDays = defaults.options.pruneDays
+	options.PublicUrl = defaults.options.publicUrl
+	options.RadioReferenceAppKey = defaults.options.radioReferenceAppKey
+	options.RadioReferenceInterval = defaults.options.radioReferenceInterval
+	options.RadioReferencePassword = defaults.options.radioReferencePassword
+	options.RadioReferenceUsername = defaults.options.radioReferenceUsername
+	options.RateLimitAdmin = defaults.options.rateLimitAdmin
+	options.RateLimitBanDuration = defaults.options.rateLimitBanDuration
+	options.RateLimitBanFailures = defaults.options.rateLimitBanFailures
+	options.RateLimitBanRejections = defaults.options.rateLimitBanRejections
+	options.RateLimitUpload = defaults.options.rateLimitUpload
+	options.RateLimitWebsocket = defaults.options.rateLimitWebsocket
+	options.RelatedCallsWindow = defaults.options.relatedCallsWindow
+	options.SamlAllowedGroups = defaults.options.samlAllowedGroups
+	options.SamlAllowedUsers = defaults.options.samlAllowedUsers
+	options.SamlEntityId = defaults.options.samlEntityId
+	options.SamlGroupRoles = defaults.options.samlGroupRoles
+	options.SamlGroupsAttribute = defaults.options.samlGroupsAttribute
+	options.SamlIdpCertificate = defaults.options.samlIdpCertificate
+	options.SamlIdpUrl = defaults.options.samlIdpUrl
+	options.ScheduledRestart = defaults.options.scheduledRestart
+	options.ScheduledRestartHour = defaults.options.scheduledRestartHour
 	options.SearchPatchedTalkgroups = defaults.options.searchPatchedTalkgroups
+	options.ServerQueue = defaults.options.serverQueue
+	options.ServerQueueMaxDepth = defaults.options.serverQueueMaxDepth
+	options.ServerQueueSkipPolicy = defaults.options.serverQueueSkipPolicy
+	options.ServerTiming = defaults.options.serverTiming
 	options.ShowListenersCount = defaults.options.showListenersCount
+	options.SilenceAlert = defaults.options.silenceAlert
+	options.SilenceAlertFrom = defaults.options.silenceAlertFrom
+	options.SilenceAlertTo = defaults.options.silenceAlertTo
 	options.SortTalkgroups = defaults.options.sortTalkgroups
+	options.SsoRole = defaults.options.ssoRole
 	options.TagsToggle = defaults.options.tagsToggle
+	options.Telemetry = defaults.options.telemetry
+	options.TelemetryUrl = defaults.options.telemetryUrl
+	options.Transcription = defaults.options.transcription
+	options.TranscriptionApiKey = defaults.options.transcriptionApiKey
+	options.TranscriptionCommand = defaults.options.transcriptionCommand
+	options.TranscriptionLanguage = defaults.options.transcriptionLanguage
+	options.TranscriptionModel = defaults.options.transcriptionModel
+	options.TranscriptionUrl = defaults.options.transcriptionUrl
+	options.TruncateLongCalls = defaults.options.truncateLongCalls
+	options.TrustedProxies = defaults.options.trustedProxies
+	options.UnitsImportInterval = defaults.options.unitsImportInterval
+	options.VotingWindow = defaults.options.votingWindow
+	options.WatchdogSelfHeal = defaults.options.watchdogSelfHeal
+	options.WatchdogStallTimeout = defaults.options.watchdogStallTimeout
 
 	err = db.Sql.QueryRow("select `val` from `rdioScannerConfigs` where `key` = 'adminPassword'").Scan(&s)
 	if err == nil {
@@ -187,22 +1159,157 @@ func (options *Options) Read(db *Database) error {
 		}
 	}
 
-	err = db.Sql.QueryRow("select `val` from `rdioScannerConfigs` where `key` = 'adminPasswordNeedChange'").Scan(&s)
-	if err == nil {
-		var b bool
-		if err = json.Unmarshal([]byte(s), &b); err == nil {
-			options.adminPasswordNeedChange = b
-		}
-	}
+	err = db.Sql.QueryRow("select `val` from `rdioScannerConfigs` where `key` = 'adminPasswordNeedChange'").Scan(&s)
+	if err == nil {
+		var b bool
+		if err = json.Unmarshal([]byte(s), &b); err == nil {
+			options.adminPasswordNeedChange = b
+		}
+	}
+
+	err = db.Sql.QueryRow("select `val` from `rdioScannerConfigs` where `key` = 'options'").Scan(&s)
+	if err == nil {
+		var m map[string]interface{}
+
+		if err = json.Unmarshal([]byte(s), &m); err == nil {
+			switch v := m["accessLog"].(type) {
+			case string:
+				options.AccessLog = v
+			}
+
+			switch v := m["adminSessionExpiry"].(type) {
+			case float64:
+				options.AdminSessionExpiry = uint(v)
+			}
+
+			switch v := m["adminTokenExpiry"].(type) {
+			case float64:
+				options.AdminTokenExpiry = uint(v)
+			}
+
+			switch v := m["afsSystems"].(type) {
+			case string:
+				options.AfsSystems = v
+			}
+
+			switch v := m["alertPushoverToken"].(type) {
+			case string:
+				options.AlertPushoverToken = v
+			}
+
+			switch v := m["alertSmtpFrom"].(type) {
+			case string:
+				options.AlertSmtpFrom = v
+			}
+
+			switch v := m["alertSmtpPassword"].(type) {
+			case string:
+				options.AlertSmtpPassword = v
+			}
+
+			switch v := m["alertSmtpServer"].(type) {
+			case string:
+				options.AlertSmtpServer = v
+			}
+
+			switch v := m["alertSmtpUsername"].(type) {
+			case string:
+				options.AlertSmtpUsername = v
+			}
+
+			switch v := m["alertTelegramToken"].(type) {
+			case string:
+				options.AlertTelegramToken = v
+			}
+
+			switch v := m["anomalyDetection"].(type) {
+			case bool:
+				options.AnomalyDetection = v
+			}
+
+			switch v := m["anomalySilenceDays"].(type) {
+			case float64:
+				options.AnomalySilenceDays = uint(v)
+			}
+
+			switch v := m["anomalyVolumeFactor"].(type) {
+			case float64:
+				options.AnomalyVolumeFactor = uint(v)
+			}
+
+			switch v := m["audioBitrate"].(type) {
+			case float64:
+				options.AudioBitrate = uint(v)
+			}
+
+			switch v := m["audioChannels"].(type) {
+			case float64:
+				options.AudioChannels = uint(v)
+			}
+
+			switch v := m["audioCodec"].(type) {
+			case string:
+				options.AudioCodec = v
+			}
+
+			switch v := m["audioLoudnessTarget"].(type) {
+			case float64:
+				options.AudioLoudnessTarget = int(v)
+			}
+
+			switch v := m["audioNormalization"].(type) {
+			case bool:
+				options.AudioNormalization = v
+			}
+
+			switch v := m["audioStorage"].(type) {
+			case string:
+				options.AudioStorage = v
+			}
+
+			switch v := m["audioStorageS3AccessKey"].(type) {
+			case string:
+				options.AudioStorageS3AccessKey = v
+			}
+
+			switch v := m["audioStorageS3Bucket"].(type) {
+			case string:
+				options.AudioStorageS3Bucket = v
+			}
+
+			switch v := m["audioStorageS3Endpoint"].(type) {
+			case string:
+				options.AudioStorageS3Endpoint = v
+			}
+
+			switch v := m["audioStorageS3PathStyle"].(type) {
+			case bool:
+				options.AudioStorageS3PathStyle = v
+			}
+
+			switch v := m["audioStorageS3Prefix"].(type) {
+			case string:
+				options.AudioStorageS3Prefix = v
+			}
+
+			switch v := m["audioStorageS3Region"].(type) {
+			case string:
+				options.AudioStorageS3Region = v
+			}
+
+			switch v := m["audioStorageS3SecretKey"].(type) {
+			case string:
+				options.AudioStorageS3SecretKey = v
+			}
 
-	err = db.Sql.QueryRow("select `val` from `rdioScannerConfigs` where `key` = 'options'").Scan(&s)
-	if err == nil {
-		var m map[string]interface{}
+			switch v := m["authWebhook"].(type) {
+			case string:
+				options.AuthWebhook = v
+			}
 
-		if err = json.Unmarshal([]byte(s), &m); err == nil {
-			switch v := m["afsSystems"].(type) {
+			switch v := m["authWebhookSecret"].(type) {
 			case string:
-				options.AfsSystems = v
+				options.AuthWebhookSecret = v
 			}
 
 			switch v := m["autoPopulate"].(type) {
@@ -210,11 +1317,81 @@ func (options *Options) Read(db *Database) error {
 				options.AutoPopulate = v
 			}
 
+			switch v := m["backupDirectory"].(type) {
+			case string:
+				options.BackupDirectory = v
+			}
+
+			switch v := m["backupRetention"].(type) {
+			case float64:
+				options.BackupRetention = uint(v)
+			}
+
+			switch v := m["backupS3Bucket"].(type) {
+			case string:
+				options.BackupS3Bucket = v
+			}
+
+			switch v := m["backupSchedule"].(type) {
+			case string:
+				options.BackupSchedule = v
+			}
+
+			switch v := m["broadcastDedupWindow"].(type) {
+			case float64:
+				options.BroadcastDedupWindow = uint(v)
+			}
+
+			switch v := m["checkForUpdates"].(type) {
+			case bool:
+				options.CheckForUpdates = v
+			}
+
+			switch v := m["clockSkewCorrection"].(type) {
+			case bool:
+				options.ClockSkewCorrection = v
+			}
+
+			switch v := m["clockSkewThreshold"].(type) {
+			case float64:
+				options.ClockSkewThreshold = uint(v)
+			}
+
+			switch v := m["conversationGap"].(type) {
+			case float64:
+				options.ConversationGap = uint(v)
+			}
+
+			switch v := m["conversionWorkers"].(type) {
+			case float64:
+				options.ConversionWorkers = uint(v)
+			}
+
 			switch v := m["dimmerDelay"].(type) {
 			case float64:
 				options.DimmerDelay = uint(v)
 			}
 
+			switch v := m["directoryLocation"].(type) {
+			case string:
+				options.DirectoryLocation = v
+			}
+
+			switch v := m["directoryName"].(type) {
+			case string:
+				options.DirectoryName = v
+			}
+
+			switch v := m["directoryPublish"].(type) {
+			case bool:
+				options.DirectoryPublish = v
+			}
+
+			switch v := m["directoryUrl"].(type) {
+			case string:
+				options.DirectoryUrl = v
+			}
+
 			switch v := m["disableAudioConversion"].(type) {
 			case bool:
 				options.DisableAudioConversion = v
@@ -225,21 +1402,126 @@ func (options *Options) Read(db *Database) error {
 				options.DisableDuplicateDetection = v
 			}
 
+			switch v := m["diskWarningPercent"].(type) {
+			case float64:
+				options.DiskWarningPercent = uint(v)
+			}
+
 			switch v := m["duplicateDetectionTimeFrame"].(type) {
 			case float64:
 				options.DuplicateDetectionTimeFrame = uint(v)
 			}
 
+			switch v := m["duplicateFingerprintMatch"].(type) {
+			case float64:
+				options.DuplicateFingerprintMatch = uint(v)
+			}
+
+			switch v := m["duplicateFingerprintWindow"].(type) {
+			case float64:
+				options.DuplicateFingerprintWindow = uint(v)
+			}
+
+			switch v := m["heartbeatTimeout"].(type) {
+			case float64:
+				options.HeartbeatTimeout = uint(v)
+			}
+
+			switch v := m["incidentDetection"].(type) {
+			case bool:
+				options.IncidentDetection = v
+			}
+
+			switch v := m["incidentDetectionMinCalls"].(type) {
+			case float64:
+				options.IncidentDetectionMinCalls = uint(v)
+			}
+
+			switch v := m["incidentDetectionThreshold"].(type) {
+			case float64:
+				options.IncidentDetectionThreshold = uint(v)
+			}
+
+			switch v := m["incidentDetectionWindow"].(type) {
+			case float64:
+				options.IncidentDetectionWindow = uint(v)
+			}
+
+			switch v := m["ingestCallbacks"].(type) {
+			case bool:
+				options.IngestCallbacks = v
+			}
+
 			switch v := m["keypadBeeps"].(type) {
 			case string:
 				options.KeypadBeeps = v
 			}
 
+			switch v := m["linkedTalkgroups"].(type) {
+			case bool:
+				options.LinkedTalkgroups = v
+			}
+
+			switch v := m["listeningRooms"].(type) {
+			case bool:
+				options.ListeningRooms = v
+			}
+
+			switch v := m["maxCallDuration"].(type) {
+			case float64:
+				options.MaxCallDuration = uint(v)
+			}
+
+			switch v := m["maxCallSize"].(type) {
+			case float64:
+				options.MaxCallSize = uint(v)
+			}
+
 			switch v := m["maxClients"].(type) {
 			case float64:
 				options.MaxClients = uint(v)
 			}
 
+			switch v := m["oidcAdmin"].(type) {
+			case bool:
+				options.OidcAdmin = v
+			}
+
+			switch v := m["oidcAdminGroups"].(type) {
+			case string:
+				options.OidcAdminGroups = v
+			}
+
+			switch v := m["oidcAllowedGroups"].(type) {
+			case string:
+				options.OidcAllowedGroups = v
+			}
+
+			switch v := m["oidcClientId"].(type) {
+			case string:
+				options.OidcClientId = v
+			}
+
+			switch v := m["oidcClientSecret"].(type) {
+			case string:
+				options.OidcClientSecret = v
+			}
+
+			switch v := m["oidcGroupsClaim"].(type) {
+			case string:
+				options.OidcGroupsClaim = v
+			}
+
+			switch v := m["oidcIssuer"].(type) {
+			case string:
+				options.OidcIssuer = v
+			}
+
+			switch v := m["oidcListeners"].(type) {
+			case bool:
+				options.OidcListeners = v
+			}
+
 			switch v := m["playbackGoesLive"].(type) {
 			case bool:
 				options.PlaybackGoesLive = v
@@ -250,26 +1532,241 @@ func (options *Options) Read(db *Database) error {
 				options.PruneDays = uint(v)
 			}
 
+			switch v := m["publicUrl"].(type) {
+			case string:
+				options.PublicUrl = v
+			}
+
+			switch v := m["radioReferenceAppKey"].(type) {
+			case string:
+				options.RadioReferenceAppKey = v
+			}
+
+			switch v := m["radioReferenceInterval"].(type) {
+			case float64:
+				options.RadioReferenceInterval = uint(v)
+			}
+
+			switch v := m["radioReferencePassword"].(type) {
+			case string:
+				options.RadioReferencePassword = v
+			}
+
+			switch v := m["radioReferenceUsername"].(type) {
+			case string:
+				options.RadioReferenceUsername = v
+			}
+
+			switch v := m["rateLimitAdmin"].(type) {
+			case float64:
+				options.RateLimitAdmin = uint(v)
+			}
+
+			switch v := m["rateLimitBanDuration"].(type) {
+			case float64:
+				options.RateLimitBanDuration = uint(v)
+			}
+
+			switch v := m["rateLimitBanFailures"].(type) {
+			case float64:
+				options.RateLimitBanFailures = uint(v)
+			}
+
+			switch v := m["rateLimitBanRejections"].(type) {
+			case float64:
+				options.RateLimitBanRejections = uint(v)
+			}
+
+			switch v := m["rateLimitUpload"].(type) {
+			case float64:
+				options.RateLimitUpload = uint(v)
+			}
+
+			switch v := m["rateLimitWebsocket"].(type) {
+			case float64:
+				options.RateLimitWebsocket = uint(v)
+			}
+
+			switch v := m["relatedCallsWindow"].(type) {
+			case float64:
+				options.RelatedCallsWindow = uint(v)
+			}
+
+			switch v := m["samlAllowedGroups"].(type) {
+			case string:
+				options.SamlAllowedGroups = v
+			}
+
+			switch v := m["samlAllowedUsers"].(type) {
+			case string:
+				options.SamlAllowedUsers = v
+			}
+
+			switch v := m["samlEntityId"].(type) {
+			case string:
+				options.SamlEntityId = v
+			}
+
+			switch v := m["samlGroupRoles"].(type) {
+			case string:
+				options.SamlGroupRoles = v
+			}
+
+			switch v := m["samlGroupsAttribute"].(type) {
+			case string:
+				options.SamlGroupsAttribute = v
+			}
+
+			switch v := m["samlIdpCertificate"].(type) {
+			case string:
+				options.SamlIdpCertificate = v
+			}
+
+			switch v := m["samlIdpUrl"].(type) {
+			case string:
+				options.SamlIdpUrl = v
+			}
+
+			switch v := m["scheduledRestart"].(type) {
+			case bool:
+				options.ScheduledRestart = v
+			}
+
+			switch v := m["scheduledRestartHour"].(type) {
+			case float64:
+				options.ScheduledRestartHour = uint(v)
+			}
+
 			switch v := m["searchPatchedTalkgroups"].(type) {
 			case bool:
 				options.SearchPatchedTalkgroups = v
 			}
 
+			switch v := m["serverQueue"].(type) {
+			case bool:
+				options.ServerQueue = v
+			}
+
+			switch v := m["serverQueueMaxDepth"].(type) {
+			case float64:
+				options.ServerQueueMaxDepth = uint(v)
+			}
+
+			switch v := m["serverQueueSkipPolicy"].(type) {
+			case string:
+				options.ServerQueueSkipPolicy = v
+			}
+
+			switch v := m["serverTiming"].(type) {
+			case bool:
+				options.ServerTiming = v
+			}
+
 			switch v := m["showListenersCount"].(type) {
 			case bool:
 				options.ShowListenersCount = v
 			}
 
+			switch v := m["silenceAlert"].(type) {
+			case float64:
+				options.SilenceAlert = uint(v)
+			}
+
+			switch v := m["silenceAlertFrom"].(type) {
+			case float64:
+				options.SilenceAlertFrom = uint(v)
+			}
+
+			switch v := m["silenceAlertTo"].(type) {
+			case float64:
+				options.SilenceAlertTo = uint(v)
+			}
+
 			switch v := m["sortTalkgroups"].(type) {
 			case bool:
 				options.SortTalkgroups = v
 			}
 
+			switch v := m["ssoRole"].(type) {
+			case string:
+				options.SsoRole = v
+			}
+
 			switch v := m["tagsToggle"].(type) {
 			case bool:
 				options.TagsToggle = v
 			}
 
+			switch v := m["telemetry"].(type) {
+			case bool:
+				options.Telemetry = v
+			}
+
+			switch v := m["telemetryUrl"].(type) {
+			case string:
+				options.TelemetryUrl = v
+			}
+
+			switch v := m["transcription"].(type) {
+			case string:
+				options.Transcription = v
+			}
+
+			switch v := m["transcriptionApiKey"].(type) {
+			case string:
+				options.TranscriptionApiKey = v
+			}
+
+			switch v := m["transcriptionCommand"].(type) {
+			case string:
+				options.TranscriptionCommand = v
+			}
+
+			switch v := m["transcriptionLanguage"].(type) {
+			case string:
+				options.TranscriptionLanguage = v
+			}
+
+			switch v := m["transcriptionModel"].(type) {
+			case string:
+				options.TranscriptionModel = v
+			}
+
+			switch v := m["transcriptionUrl"].(type) {
+			case string:
+				options.TranscriptionUrl = v
+			}
+
+			switch v := m["truncateLongCalls"].(type) {
+			case bool:
+				options.TruncateLongCalls = v
+			}
+
+			switch v := m["trustedProxies"].(type) {
+			case string:
+				options.TrustedProxies = v
+			}
+
+			switch v := m["unitsImportInterval"].(type) {
+			case float64:
+				options.UnitsImportInterval = uint(v)
+			}
+
+			switch v := m["votingWindow"].(type) {
+			case float64:
+				options.VotingWindow = uint(v)
+			}
+
+			switch v := m["watchdogSelfHeal"].(type) {
+			case bool:
+				options.WatchdogSelfHeal = v
+			}
+
+			switch v := m["watchdogStallTimeout"].(type) {
+			case float64:
+				options.WatchdogStallTimeout = uint(v)
+			}
+
 		}
 	}
 
@@ -280,6 +1777,8 @@ func (options *Options) Read(db *Database) error {
 		}
 	}
 
+	options.started = true
+
 	return nil
 }
 
@@ -322,22 +1821,133 @@ func (options *Options) Write(db *Database) error {
 		db.Sql.Exec("insert into `rdioScannerConfigs` (`key`, `val`) values (?, ?)", "adminPasswordNeedChange", string(b))
 	}
 
-	if b, err = json.Marshal(map[string]interface{}{
+	m := map[string]interface{}{
+		"accessLog":                   options.AccessLog,
+		"adminSessionExpiry":          options.AdminSessionExpiry,
+		"adminTokenExpiry":            options.AdminTokenExpiry,
 		"afsSystems":                  options.AfsSystems,
+		"alertPushoverToken":          options.AlertPushoverToken,
+		"alertSmtpFrom":               options.AlertSmtpFrom,
+		"alertSmtpPassword":           options.AlertSmtpPassword,
+		"alertSmtpServer":             options.AlertSmtpServer,
+		"alertSmtpUsername":           options.AlertSmtpUsername,
+		"alertTelegramToken":          options.AlertTelegramToken,
+		"anomalyDetection":            options.AnomalyDetection,
+		"anomalySilenceDays":          options.AnomalySilenceDays,
+		"anomalyVolumeFactor":         options.AnomalyVolumeFactor,
+		"audioBitrate":                options.AudioBitrate,
+		"audioChannels":               options.AudioChannels,
+		"audioCodec":                  options.AudioCodec,
+		"audioLoudnessTarget":         options.AudioLoudnessTarget,
+		"audioNormalization":          options.AudioNormalization,
+		"audioStorage":                options.AudioStorage,
+		"audioStorageS3AccessKey":     options.AudioStorageS3AccessKey,
+		"audioStorageS3Bucket":        options.AudioStorageS3Bucket,
+		"audioStorageS3Endpoint":      options.AudioStorageS3Endpoint,
+		"audioStorageS3PathStyle":     options.AudioStorageS3PathStyle,
+		"audioStorageS3Prefix":        options.AudioStorageS3Prefix,
+		"audioStorageS3Region":        options.AudioStorageS3Region,
+		"audioStorageS3SecretKey":     options.AudioStorageS3SecretKey,
+		"authWebhook":                 options.AuthWebhook,
+		"authWebhookSecret":           options.AuthWebhookSecret,
 		"autoPopulate":                options.AutoPopulate,
+		"backupDirectory":             options.BackupDirectory,
+		"backupRetention":             options.BackupRetention,
+		"backupS3Bucket":              options.BackupS3Bucket,
+		"backupSchedule":              options.BackupSchedule,
+		"broadcastDedupWindow":        options.BroadcastDedupWindow,
+		"checkForUpdates":             options.CheckForUpdates,
+		"clockSkewCorrection":         options.ClockSkewCorrection,
+		"clockSkewThreshold":          options.ClockSkewThreshold,
+		"conversationGap":             options.ConversationGap,
+		"conversionWorkers":           options.ConversionWorkers,
 		"dimmerDelay":                 options.DimmerDelay,
+		"directoryLocation":           options.DirectoryLocation,
+		"directoryName":               options.DirectoryName,
+		"directoryPublish":            options.DirectoryPublish,
+		"directoryUrl":                options.DirectoryUrl,
 		"disableAudioConversion":      options.DisableAudioConversion,
 		"disableDuplicateDetection":   options.DisableDuplicateDetection,
+		"diskWarningPercent":          options.DiskWarningPercent,
 		"duplicateDetectionTimeFrame": options.DuplicateDetectionTimeFrame,
+		"duplicateFingerprintMatch":   options.DuplicateFingerprintMatch,
+		"duplicateFingerprintWindow":  options.DuplicateFingerprintWindow,
+		"heartbeatTimeout":            options.HeartbeatTimeout,
+		"incidentDetection":           options.IncidentDetection,
+		"incidentDetectionMinCalls":   options.IncidentDetectionMinCalls,
+		"incidentDetectionThreshold":  options.IncidentDetectionThreshold,
+		"incidentDetectionWindow":     options.IncidentDetectionWindow,
+		"ingestCallbacks":             options.IngestCallbacks,
 		"keypadBeeps":                 options.KeypadBeeps,
+		"linkedTalkgroups":            options.LinkedTalkgroups,
+		"listeningRooms":              options.ListeningRooms,
+		"maxCallDuration":             options.MaxCallDuration,
+		"maxCallSize":                 options.MaxCallSize,
 		"maxClients":                  options.MaxClients,
+		"oidcAdmin":                   options.OidcAdmin,
+		"oidcAdminGroups":             options.OidcAdminGroups,
+		"oidcAllowedGroups":           options.OidcAllowedGroups,
+		"oidcClientId":                options.OidcClientId,
+		"oidcClientSecret":            options.OidcClientSecret,
+		"oidcGroupsClaim":             options.OidcGroupsClaim,
+		"oidcIssuer":                  options.OidcIssuer,
+		"oidcListeners":               options.OidcListeners,
 		"playbackGoesLive":            options.PlaybackGoesLive,
 		"pruneDays":                   options.PruneDays,
+		"publicUrl":                   options.PublicUrl,
+		"radioReferenceAppKey":        options.RadioReferenceAppKey,
+		"radioReferenceInterval":      options.RadioReferenceInterval,
+		"radioReferencePassword":      options.RadioReferencePassword,
+		"radioReferenceUsername":      options.RadioReferenceUsername,
+		"rateLimitAdmin":              options.RateLimitAdmin,
+		"rateLimitBanDuration":        options.RateLimitBanDuration,
+		"rateLimitBanFailures":        options.RateLimitBanFailures,
+		"rateLimitBanRejections":      options.RateLimitBanRejections,
+		"rateLimitUpload":             options.RateLimitUpload,
+		"rateLimitWebsocket":          options.RateLimitWebsocket,
+		"relatedCallsWindow":          options.RelatedCallsWindow,
+		"samlAllowedGroups":           options.SamlAllowedGroups,
+		"samlAllowedUsers":            options.SamlAllowedUsers,
+		"samlEntityId":                options.SamlEntityId,
+		"samlGroupRoles":              options.SamlGroupRoles,
+		"samlGroupsAttribute":         options.SamlGroupsAttribute,
+		"samlIdpCertificate":          options.SamlIdpCertificate,
+		"samlIdpUrl":                  options.SamlIdpUrl,
+		"scheduledRestart":            options.ScheduledRestart,
+		"scheduledRestartHour":        options.ScheduledRestartHour,
 		"searchPatchedTalkgroups":     options.SearchPatchedTalkgroups,
+		"serverQueue":                 options.ServerQueue,
+		"serverQueueMaxDepth":         options.ServerQueueMaxDepth,
+		"serverQueueSkipPolicy":       options.ServerQueueSkipPolicy,
+		"serverTiming":                options.ServerTiming,
 		"showListenersCount":          options.ShowListenersCount,
+		"silenceAlert":                options.SilenceAlert,
+		"silenceAlertFrom":            options.SilenceAlertFrom,
+		"silenceAlertTo":              options.SilenceAlertTo,
 		"sortTalkgroups":              options.SortTalkgroups,
+		"ssoRole":                     options.SsoRole,
 		"tagsToggle":                  options.TagsToggle,
-	}); err != nil {
+		"telemetry":                   options.Telemetry,
+		"telemetryUrl":                options.TelemetryUrl,
+		"transcription":               options.Transcription,
+		"transcriptionApiKey":         options.TranscriptionApiKey,
+		"transcriptionCommand":        options.TranscriptionCommand,
+		"transcriptionLanguage":       options.TranscriptionLanguage,
+		"transcriptionModel":          options.TranscriptionModel,
+		"transcriptionUrl":            options.TranscriptionUrl,
+		"truncateLongCalls":           options.TruncateLongCalls,
+		"trustedProxies":              options.TrustedProxies,
+		"unitsImportInterval":         options.UnitsImportInterval,
+		"votingWindow":                options.VotingWindow,
+		"watchdogSelfHeal":            options.WatchdogSelfHeal,
+		"watchdogStallTimeout":        options.WatchdogStallTimeout,
+	}
+
+	for name, value := range options.pending {
+		m[name] = value
+	}
+
+	if b, err = json.Marshal(m); err != nil {
 		return formatError(err)
 	}
 
@@ -0,0 +1,105 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+func (options *Options) GetPendingRestart() []string {
+	options.mutex.Lock()
+	defer options.mutex.Unlock()
+
+	names := []string{}
+	for name := range options.pending {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func (options *Options) MarshalJSON() ([]byte, error) {
+	type optionsJson Options
+
+	options.mutex.Lock()
+	defer options.mutex.Unlock()
+
+	b, err := json.Marshal((*optionsJson)(options))
+	if err != nil || len(options.pending) == 0 {
+		return b, err
+	}
+
+	m := map[string]interface{}{}
+	if err = json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	for name, value := range options.pending {
+		m[name] = value
+	}
+
+	return json.Marshal(m)
+}
+
+func (options *Options) getRunningValues() map[string]interface{} {
+	if !options.started {
+		return nil
+	}
+
+	values := map[string]interface{}{}
+
+	v := reflect.ValueOf(options).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if field := v.Type().Field(i); field.Tag.Get("restart") == "true" {
+			values[strings.Split(field.Tag.Get("json"), ",")[0]] = v.Field(i).Interface()
+		}
+	}
+
+	return values
+}
+
+func (options *Options) queueRestartValues(running map[string]interface{}) {
+	if running == nil {
+		return
+	}
+
+	v := reflect.ValueOf(options).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if field.Tag.Get("restart") != "true" {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		value := v.Field(i).Interface()
+		if reflect.DeepEqual(value, running[name]) {
+			delete(options.pending, name)
+			continue
+		}
+
+		if options.pending == nil {
+			options.pending = map[string]interface{}{}
+		}
+		options.pending[name] = value
+
+		v.Field(i).Set(reflect.ValueOf(running[name]))
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type OptionSchema struct {
+	Default interface{} `json:"default"`
+	Enum    []string    `json:"enum,omitempty"`
+	Max     *int64      `json:"max,omitempty"`
+	Min     *int64      `json:"min,omitempty"`
+	Name    string      `json:"name"`
+	Restart bool        `json:"restart"`
+	Secret  bool        `json:"secret,omitempty"`
+	Type    string      `json:"type"`
+}
+
+func (admin *Admin) OptionsSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if b, err := json.Marshal(map[string]interface{}{"options": GetOptionsSchema()}); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.optionsschemahandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func GetOptionsSchema() []OptionSchema {
+	schema := []OptionSchema{}
+
+	defaultOptions := reflect.ValueOf(defaults.options)
+
+	optionsType := reflect.TypeOf(Options{})
+
+	for i := 0; i < optionsType.NumField(); i++ {
+		field := optionsType.Field(i)
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if len(name) == 0 || name == "-" {
+			continue
+		}
+
+		option := OptionSchema{
+			Name:    name,
+			Restart: field.Tag.Get("restart") == "true",
+			Secret:  field.Tag.Get("secret") == "true",
+		}
+
+		if v, ok := field.Tag.Lookup("enum"); ok {
+			option.Enum = strings.Split(v, ",")
+		}
+
+		if v, err := strconv.ParseInt(field.Tag.Get("max"), 10, 64); err == nil {
+			option.Max = &v
+		}
+
+		if v, err := strconv.ParseInt(field.Tag.Get("min"), 10, 64); err == nil {
+			option.Min = &v
+		}
+
+		value := defaultOptions.FieldByName(name)
+		if !value.IsValid() {
+			value = reflect.Zero(field.Type)
+		}
+
+		switch field.Type.Kind() {
+		case reflect.Bool:
+			option.Type = "boolean"
+			option.Default = value.Bool()
+
+		case reflect.Int, reflect.Int64:
+			option.Type = "integer"
+			option.Default = value.Int()
+
+		case reflect.Uint, reflect.Uint64:
+			option.Type = "integer"
+			if option.Min == nil {
+				min := int64(0)
+				option.Min = &min
+			}
+			option.Default = value.Uint()
+
+		case reflect.String:
+			option.Type = "string"
+			option.Default = value.String()
+
+		default:
+			continue
+		}
+
+		if option.Secret {
+			option.Default = ""
+		}
+
+		schema = append(schema, option)
+	}
+
+	return schema
+}
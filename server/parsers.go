@@ -18,11 +18,13 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"math"
 	"mime"
 	"mime/multipart"
 	"path"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dhowden/tag"
@@ -140,6 +142,14 @@ func ParseMultipartContent(call *Call, p *multipart.Part, b []byte) {
 		call.AudioName = string(b)
 		call.AudioType = mime.TypeByExtension(path.Ext(string(b)))
 
+	case "callback":
+		call.callback = strings.TrimSpace(string(b))
+
+	case "controlChannel":
+		if i, err := strconv.Atoi(string(b)); err == nil && i > 0 {
+			call.controlChannel = uint(i)
+		}
+
 	case "dateTime":
 		if regexp.MustCompile(`^[0-9]+$`).Match(b) {
 			if i, err := strconv.Atoi(string(b)); err == nil {
@@ -150,6 +160,11 @@ func ParseMultipartContent(call *Call, p *multipart.Part, b []byte) {
 			call.DateTime = call.DateTime.UTC()
 		}
 
+	case "decodeRate":
+		if f, err := strconv.ParseFloat(string(b), 64); err == nil && f >= 0 {
+			call.decodeRate = f
+		}
+
 	case "frequencies":
 		var f interface{}
 		if err := json.Unmarshal(b, &f); err == nil {
@@ -175,13 +190,13 @@ func ParseMultipartContent(call *Call, p *multipart.Part, b []byte) {
 						switch v := v["len"].(type) {
 						case float64:
 							if v >= 0 {
-								freq["len"] = uint(v)
+								freq["len"] = math.Round(v*1000) / 1000
 							}
 						}
 						switch v := v["pos"].(type) {
 						case float64:
 							if v >= 0 {
-								freq["pos"] = uint(v)
+								freq["pos"] = math.Round(v*1000) / 1000
 							}
 						}
 						switch v := v["spikeCount"].(type) {
@@ -202,6 +217,11 @@ func ParseMultipartContent(call *Call, p *multipart.Part, b []byte) {
 			call.Frequency = uint(i)
 		}
 
+	case "historical":
+		if v, err := strconv.ParseBool(string(b)); err == nil {
+			call.historical = v
+		}
+
 	case "patches", "patched_talkgroups":
 		var (
 			f       interface{}
@@ -222,6 +242,16 @@ func ParseMultipartContent(call *Call, p *multipart.Part, b []byte) {
 			call.Patches = patches
 		}
 
+	case "signal":
+		if f, err := strconv.ParseFloat(string(b), 64); err == nil {
+			call.signal = f
+		}
+
+	case "site", "siteId":
+		if s := string(b); len(s) > 0 {
+			call.site = s
+		}
+
 	case "source":
 		if i, err := strconv.Atoi(string(b)); err == nil {
 			call.Source = int(i)
@@ -243,7 +273,7 @@ func ParseMultipartContent(call *Call, p *multipart.Part, b []byte) {
 						switch v := v["pos"].(type) {
 						case float64:
 							if v >= 0 {
-								src["pos"] = uint(v)
+								src["pos"] = math.Round(v*1000) / 1000
 							}
 						}
 						switch s := v["src"].(type) {
@@ -252,16 +282,26 @@ func ParseMultipartContent(call *Call, p *multipart.Part, b []byte) {
 								src["src"] = uint(s)
 								switch t := v["tag"].(type) {
 								case string:
-									if units == nil {
-										units = NewUnits()
-									}
-									switch units := call.units.(type) {
-									case *Units:
+									if len(t) > 0 {
+										if units == nil {
+											units = NewUnits()
+										}
 										units.Add(uint(s), t)
+										src["tag"] = t
 									}
 								}
 							}
 						}
+						switch e := v["emergency"].(type) {
+						case bool:
+							if e {
+								src["emergency"] = true
+							}
+						case float64:
+							if e > 0 {
+								src["emergency"] = true
+							}
+						}
 					}
 					sources = append(sources, src)
 				}
@@ -302,6 +342,14 @@ func ParseMultipartContent(call *Call, p *multipart.Part, b []byte) {
 		if s := string(b); len(s) > 0 && s != "-" {
 			call.talkgroupTag = s
 		}
+
+	case "transcript":
+		call.transcript = strings.Join(strings.Fields(string(b)), " ")
+
+	case "transcriptConfidence":
+		if f, err := strconv.ParseFloat(string(b), 64); err == nil && f >= 0 && f <= 1 {
+			call.transcriptScore = f
+		}
 	}
 }
 
@@ -343,14 +391,14 @@ func ParseTrunkRecorderMeta(call *Call, b []byte) error {
 				switch v := v["len"].(type) {
 				case float64:
 					if v >= 0 {
-						freq["len"] = uint(v)
+						freq["len"] = math.Round(v*1000) / 1000
 					}
 				}
 
 				switch v := v["pos"].(type) {
 				case float64:
 					if v >= 0 {
-						freq["pos"] = uint(v)
+						freq["pos"] = math.Round(v*1000) / 1000
 					}
 				}
 
@@ -383,6 +431,11 @@ func ParseTrunkRecorderMeta(call *Call, b []byte) error {
 		}
 	}
 
+	switch v := m["signal"].(type) {
+	case float64:
+		call.signal = v
+	}
+
 	switch v := m["srcList"].(type) {
 	case []interface{}:
 		sources := []map[string]interface{}{}
@@ -393,7 +446,7 @@ func ParseTrunkRecorderMeta(call *Call, b []byte) error {
 				switch v := v["pos"].(type) {
 				case float64:
 					if v >= 0 {
-						source["pos"] = uint(v)
+						source["pos"] = math.Round(v*1000) / 1000
 					}
 				}
 				switch s := v["src"].(type) {
@@ -410,10 +463,17 @@ func ParseTrunkRecorderMeta(call *Call, b []byte) error {
 								case *Units:
 									v.Add(uint(s), t)
 								}
+								source["tag"] = t
 							}
 						}
 					}
 				}
+				switch e := v["emergency"].(type) {
+				case float64:
+					if e > 0 {
+						source["emergency"] = true
+					}
+				}
 				sources = append(sources, source)
 			}
 		}
@@ -442,5 +502,17 @@ func ParseTrunkRecorderMeta(call *Call, b []byte) error {
 		}
 	}
 
+	switch v := m["transcript"].(type) {
+	case string:
+		call.transcript = strings.Join(strings.Fields(v), " ")
+	}
+
+	switch v := m["transcript_confidence"].(type) {
+	case float64:
+		if v >= 0 && v <= 1 {
+			call.transcriptScore = v
+		}
+	}
+
 	return nil
 }
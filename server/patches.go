@@ -0,0 +1,257 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const PatchesActiveTimeout = 10 * time.Minute
+
+type Patch struct {
+	DateTime   time.Time `json:"dateTime"`
+	System     uint      `json:"system"`
+	Talkgroups []uint    `json:"talkgroups"`
+	Updated    time.Time `json:"updated"`
+}
+
+type Patches struct {
+	controller *Controller
+	mutex      sync.Mutex
+}
+
+func NewPatches(controller *Controller) *Patches {
+	return &Patches{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (patches *Patches) GetActive() ([]*Patch, error) {
+	type pair struct {
+		dateTime  time.Time
+		patched   uint
+		system    uint
+		talkgroup uint
+		updated   time.Time
+	}
+
+	patches.mutex.Lock()
+	defer patches.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("patches.getactive: %v", err)
+	}
+
+	db := patches.controller.Database
+
+	from := time.Now().Add(-PatchesActiveTimeout).Format(db.DateTimeFormat)
+
+	rows, err := db.Sql.Query("select `dateTime`, `patched`, `system`, `talkgroup`, `updated` from `rdioScannerPatches` where `updated` >= ?", from)
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	pairs := []pair{}
+
+	for rows.Next() {
+		var (
+			dateTime interface{}
+			p        pair
+			updated  interface{}
+		)
+
+		if err = rows.Scan(&dateTime, &p.patched, &p.system, &p.talkgroup, &updated); err != nil {
+			break
+		}
+
+		if p.dateTime, err = db.ParseDateTime(dateTime); err != nil {
+			continue
+		}
+
+		if p.updated, err = db.ParseDateTime(updated); err != nil {
+			continue
+		}
+
+		pairs = append(pairs, p)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	parents := map[string]string{}
+
+	key := func(system uint, talkgroup uint) string {
+		return fmt.Sprintf("%d:%d", system, talkgroup)
+	}
+
+	var find func(k string) string
+	find = func(k string) string {
+		if p, ok := parents[k]; ok && p != k {
+			parents[k] = find(p)
+			return parents[k]
+		}
+		parents[k] = k
+		return k
+	}
+
+	for _, p := range pairs {
+		a, b := find(key(p.system, p.talkgroup)), find(key(p.system, p.patched))
+		if a != b {
+			parents[b] = a
+		}
+	}
+
+	groups := map[string]*Patch{}
+	members := map[string]map[uint]bool{}
+
+	for _, p := range pairs {
+		root := find(key(p.system, p.talkgroup))
+
+		patch := groups[root]
+		if patch == nil {
+			patch = &Patch{DateTime: p.dateTime, System: p.system, Talkgroups: []uint{}, Updated: p.updated}
+			groups[root] = patch
+			members[root] = map[uint]bool{}
+		}
+
+		if p.dateTime.Before(patch.DateTime) {
+			patch.DateTime = p.dateTime
+		}
+
+		if p.updated.After(patch.Updated) {
+			patch.Updated = p.updated
+		}
+
+		for _, id := range []uint{p.talkgroup, p.patched} {
+			if !members[root][id] {
+				members[root][id] = true
+				patch.Talkgroups = append(patch.Talkgroups, id)
+			}
+		}
+	}
+
+	active := []*Patch{}
+
+	for _, patch := range groups {
+		sort.Slice(patch.Talkgroups, func(i int, j int) bool {
+			return patch.Talkgroups[i] < patch.Talkgroups[j]
+		})
+		active = append(active, patch)
+	}
+
+	sort.Slice(active, func(i int, j int) bool {
+		if active[i].System != active[j].System {
+			return active[i].System < active[j].System
+		}
+		return active[i].Talkgroups[0] < active[j].Talkgroups[0]
+	})
+
+	return active, nil
+}
+
+func (patches *Patches) Prune(db *Database, pruneDays uint) error {
+	patches.mutex.Lock()
+	defer patches.mutex.Unlock()
+
+	date := time.Now().Add(-24 * time.Hour * time.Duration(pruneDays)).Format(db.DateTimeFormat)
+	if _, err := db.Sql.Exec("delete from `rdioScannerPatches` where `updated` < ?", date); err != nil {
+		return fmt.Errorf("patches.prune: %v", err)
+	}
+
+	return nil
+}
+
+func (patches *Patches) Record(call *Call) error {
+	talkgroups := []uint{call.Talkgroup}
+
+	for _, id := range call.GetPatches() {
+		found := false
+		for _, t := range talkgroups {
+			if t == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			talkgroups = append(talkgroups, id)
+		}
+	}
+
+	if len(talkgroups) < 2 {
+		return nil
+	}
+
+	patches.mutex.Lock()
+	defer patches.mutex.Unlock()
+
+	for _, talkgroup := range talkgroups {
+		for _, patched := range talkgroups {
+			if talkgroup == patched {
+				continue
+			}
+
+			if err := patches.record(call.System, talkgroup, patched, call.DateTime); err != nil {
+				return fmt.Errorf("patches.record: %v", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (patches *Patches) record(system uint, talkgroup uint, patched uint, dateTime time.Time) error {
+	var (
+		id      uint
+		start   interface{}
+		updated interface{}
+	)
+
+	db := patches.controller.Database
+
+	from := dateTime.Add(-PatchesActiveTimeout).Format(db.DateTimeFormat)
+	to := dateTime.Add(PatchesActiveTimeout).Format(db.DateTimeFormat)
+
+	err := db.Sql.QueryRow("select `_id`, `dateTime`, `updated` from `rdioScannerPatches` where `system` = ? and `talkgroup` = ? and `patched` = ? and `updated` >= ? and `dateTime` <= ? order by `updated` desc limit 1", system, talkgroup, patched, from, to).Scan(&id, &start, &updated)
+
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = db.Sql.Exec("insert into `rdioScannerPatches` (`dateTime`, `patched`, `system`, `talkgroup`, `updated`) values (?, ?, ?, ?, ?)", dateTime, patched, system, talkgroup, dateTime)
+		return err
+
+	case err != nil:
+		return err
+	}
+
+	if t, err := db.ParseDateTime(start); err == nil && dateTime.Before(t) {
+		_, err = db.Sql.Exec("update `rdioScannerPatches` set `dateTime` = ? where `_id` = ?", dateTime, id)
+		return err
+	}
+
+	if t, err := db.ParseDateTime(updated); err == nil && dateTime.After(t) {
+		_, err = db.Sql.Exec("update `rdioScannerPatches` set `updated` = ? where `_id` = ?", dateTime, id)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,318 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PollBufferSize = 500
+	PollLimit      = 50
+	PollMaxLimit   = 200
+	PollMaxWait    = 8 * time.Second
+)
+
+type Poller struct {
+	Controller *Controller
+	calls      []*pollCall
+	epoch      string
+	mutex      sync.Mutex
+	notify     chan struct{}
+	seq        uint64
+}
+
+type pollCall struct {
+	call *Call
+	seq  uint64
+}
+
+func NewPoller(controller *Controller) *Poller {
+	return &Poller{
+		Controller: controller,
+		calls:      []*pollCall{},
+		epoch:      strconv.FormatInt(time.Now().Unix(), 36),
+		mutex:      sync.Mutex{},
+		notify:     make(chan struct{}),
+	}
+}
+
+func (poller *Poller) Add(call *Call) {
+	poller.mutex.Lock()
+	defer poller.mutex.Unlock()
+
+	c := *call
+	c.Audio = nil
+
+	poller.seq++
+	poller.calls = append(poller.calls, &pollCall{call: &c, seq: poller.seq})
+
+	if len(poller.calls) > PollBufferSize {
+		poller.calls = poller.calls[len(poller.calls)-PollBufferSize:]
+	}
+
+	close(poller.notify)
+	poller.notify = make(chan struct{})
+}
+
+func (poller *Poller) GetCursor(seq uint64) string {
+	return fmt.Sprintf("%s-%d", poller.epoch, seq)
+}
+
+func (poller *Poller) ParseCursor(cursor string) (uint64, bool) {
+	poller.mutex.Lock()
+	defer poller.mutex.Unlock()
+
+	if len(cursor) == 0 {
+		return poller.seq, true
+	}
+
+	s := strings.SplitN(cursor, "-", 2)
+	if len(s) != 2 {
+		return 0, false
+	}
+
+	seq, err := strconv.ParseUint(s[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	if s[0] != poller.epoch || seq > poller.seq {
+		return 0, true
+	}
+
+	return seq, true
+}
+
+func (poller *Poller) Since(seq uint64) ([]*pollCall, uint64, bool, chan struct{}) {
+	poller.mutex.Lock()
+	defer poller.mutex.Unlock()
+
+	calls := []*pollCall{}
+	missed := false
+
+	if len(poller.calls) > 0 && poller.calls[0].seq > seq+1 {
+		missed = true
+	}
+
+	for _, c := range poller.calls {
+		if c.seq > seq {
+			calls = append(calls, c)
+		}
+	}
+
+	return calls, poller.seq, missed, poller.notify
+}
+
+func (api *Api) PollHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		access     *Access
+		limit      = PollLimit
+		systems    = map[uint]bool{}
+		talkgroups = map[uint]bool{}
+		wait       = PollMaxWait
+	)
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	controller := api.Controller
+	query := r.URL.Query()
+
+	sendError := func(status int, message string) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if b, err := json.Marshal(map[string]interface{}{"error": message}); err == nil {
+			w.Write(b)
+		}
+	}
+
+	if controller.Accesses.IsRestricted() {
+		code := r.Header.Get("X-Access-Code")
+		if len(code) == 0 {
+			code = query.Get("code")
+		}
+
+		if len(code) == 0 {
+			sendError(http.StatusUnauthorized, "missing access code")
+			return
+		}
+
+		if a, ok := controller.Accesses.GetAccess(code); ok {
+			access = a
+		} else if a, ok, err := controller.Accesses.GetExternalAccess(code, GetRemoteAddr(r)); ok {
+			access = a
+		} else {
+			if err != nil {
+				controller.Logs.LogEvent(LogLevelError, err.Error())
+			}
+			controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("poll: invalid access code=\"%s\" address=\"%s\"", code, GetRemoteAddr(r)))
+			controller.RateLimiter.Fail(GetRemoteAddr(r))
+			sendError(http.StatusUnauthorized, "invalid access code")
+			return
+		}
+
+		if access.HasExpired() {
+			sendError(http.StatusForbidden, "access code expired")
+			return
+		}
+
+		if _, active := controller.Clients.AccessUsage(access); access.HasExceededHours(active) {
+			sendError(http.StatusForbidden, "listening hours exhausted")
+			return
+		}
+	}
+
+	parseIds := func(name string, ids map[uint]bool) bool {
+		for _, s := range strings.Split(query.Get(name), ",") {
+			if s = strings.TrimSpace(s); len(s) == 0 {
+				continue
+			}
+			if id, err := strconv.ParseUint(s, 10, 32); err == nil {
+				ids[uint(id)] = true
+			} else {
+				return false
+			}
+		}
+		return true
+	}
+
+	if !parseIds("systems", systems) {
+		sendError(http.StatusBadRequest, "invalid systems")
+		return
+	}
+
+	if !parseIds("talkgroups", talkgroups) {
+		sendError(http.StatusBadRequest, "invalid talkgroups")
+		return
+	}
+
+	if s := query.Get("limit"); len(s) > 0 {
+		if v, err := strconv.ParseUint(s, 10, 32); err == nil && v > 0 {
+			if v > PollMaxLimit {
+				v = PollMaxLimit
+			}
+			limit = int(v)
+		} else {
+			sendError(http.StatusBadRequest, "invalid limit")
+			return
+		}
+	}
+
+	if s := query.Get("wait"); len(s) > 0 {
+		if v, err := strconv.ParseUint(s, 10, 32); err == nil {
+			if d := time.Duration(v) * time.Second; d < wait {
+				wait = d
+			}
+		} else {
+			sendError(http.StatusBadRequest, "invalid wait")
+			return
+		}
+	}
+
+	since, ok := controller.Poller.ParseCursor(query.Get("since"))
+	if !ok {
+		sendError(http.StatusBadRequest, "invalid cursor")
+		return
+	}
+
+	if len(query.Get("since")) == 0 {
+		wait = 0
+	}
+
+	isMatch := func(call *Call) bool {
+		if access != nil && !access.HasAccess(call) {
+			return false
+		}
+		if len(systems) > 0 && !systems[call.System] {
+			return false
+		}
+		if len(talkgroups) > 0 && !talkgroups[call.Talkgroup] {
+			return false
+		}
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	missed := false
+
+	for {
+		calls, head, m, notify := controller.Poller.Since(since)
+
+		items := []map[string]interface{}{}
+		cursor := head
+		missed = missed || m
+
+		for _, c := range calls {
+			if len(items) == limit {
+				cursor = c.seq - 1
+				break
+			}
+
+			if !isMatch(c.call) {
+				continue
+			}
+
+			m := api.v1CallMap(c.call, nil)
+			delete(m, "audioUrl")
+
+			if controller.CanShareAudioUrl(access, c.call) {
+				if u := GetAudioUrl(c.call, controller.Options.secret); len(u) > 0 {
+					m["audioUrl"] = u
+				}
+			}
+
+			items = append(items, m)
+		}
+
+		if len(items) == 0 {
+			since = cursor
+
+			select {
+			case <-notify:
+				continue
+			case <-timer.C:
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if b, err := json.Marshal(map[string]interface{}{
+			"calls":  items,
+			"cursor": controller.Poller.GetCursor(cursor),
+			"missed": missed,
+		}); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+		return
+	}
+}
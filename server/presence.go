@@ -0,0 +1,94 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+type AdminPresence struct {
+	Address  string    `json:"address"`
+	Id       string    `json:"id"`
+	Role     string    `json:"role"`
+	Section  string    `json:"section"`
+	Since    time.Time `json:"since"`
+	Username string    `json:"username"`
+	conn     *websocket.Conn
+}
+
+func NewAdminPresence(conn *websocket.Conn, address string) *AdminPresence {
+	return &AdminPresence{
+		Address: address,
+		Id:      strings.Split(uuid.New().String(), "-")[0],
+		Since:   time.Now(),
+		conn:    conn,
+	}
+}
+
+func (presence *AdminPresence) FromMessage(b []byte) bool {
+	var m struct {
+		Presence *struct {
+			Section string `json:"section"`
+		} `json:"presence"`
+	}
+
+	if err := json.Unmarshal(b, &m); err != nil || m.Presence == nil {
+		return false
+	}
+
+	section := ""
+	for _, s := range AdminConfigSections {
+		if strings.EqualFold(s, m.Presence.Section) {
+			section = s
+			break
+		}
+	}
+
+	if section != presence.Section {
+		presence.Section = section
+		presence.Since = time.Now()
+	}
+
+	return true
+}
+
+func (admin *Admin) emitPresence() {
+	list := []*AdminPresence{}
+	for _, p := range admin.presence {
+		list = append(list, p)
+	}
+
+	sort.Slice(list, func(i int, j int) bool {
+		return list[i].Id < list[j].Id
+	})
+
+	for conn := range admin.Conns {
+		p, ok := admin.presence[conn]
+		if !ok {
+			continue
+		}
+
+		if b, err := json.Marshal(map[string]interface{}{"presence": list, "self": p.Id}); err == nil {
+			conn.WriteMessage(websocket.TextMessage, b)
+		}
+	}
+}
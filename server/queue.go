@@ -0,0 +1,233 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	QueueSkipLowest = "lowest"
+	QueueSkipNewest = "newest"
+	QueueSkipOldest = "oldest"
+)
+
+type QueueEntry struct {
+	Id        uint
+	DateTime  time.Time
+	Priority  int
+	System    uint
+	Talkgroup uint
+}
+
+type Queue struct {
+	Entries    []*QueueEntry
+	Priorities map[uint]map[uint]int
+	lastSeen   time.Time
+}
+
+type Queues struct {
+	Map        map[string]*Queue
+	controller *Controller
+	mutex      sync.Mutex
+}
+
+func NewQueues(controller *Controller) *Queues {
+	return &Queues{
+		Map:        map[string]*Queue{},
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (queues *Queues) Attach(client *Client, id string, priorities interface{}) string {
+	const idleTimeout = time.Hour
+
+	queues.mutex.Lock()
+	defer queues.mutex.Unlock()
+
+	for k, q := range queues.Map {
+		if time.Since(q.lastSeen) > idleTimeout {
+			delete(queues.Map, k)
+		}
+	}
+
+	key := id
+	if client.Access != nil && len(client.Access.Code) > 0 {
+		key = fmt.Sprintf("%s:%s", client.Access.Code, id)
+	}
+
+	queue := queues.Map[key]
+	if queue == nil {
+		queue = &Queue{Entries: []*QueueEntry{}}
+		queues.Map[key] = queue
+	}
+
+	queue.Priorities = map[uint]map[uint]int{}
+
+	switch v := priorities.(type) {
+	case map[string]interface{}:
+		for s, f := range v {
+			if systemId, err := strconv.Atoi(s); err == nil {
+				switch v := f.(type) {
+				case map[string]interface{}:
+					for t, f := range v {
+						switch p := f.(type) {
+						case float64:
+							if talkgroupId, err := strconv.Atoi(t); err == nil {
+								if queue.Priorities[uint(systemId)] == nil {
+									queue.Priorities[uint(systemId)] = map[uint]int{}
+								}
+								queue.Priorities[uint(systemId)][uint(talkgroupId)] = int(p)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	queue.lastSeen = time.Now()
+
+	return key
+}
+
+func (queues *Queues) Clear(key string) {
+	queues.mutex.Lock()
+	defer queues.mutex.Unlock()
+
+	if queue := queues.Map[key]; queue != nil {
+		queue.Entries = []*QueueEntry{}
+		queue.lastSeen = time.Now()
+	}
+}
+
+func (queues *Queues) EmitStatus(key string) {
+	status := queues.GetStatus(key)
+
+	queues.controller.Clients.Map.Range(func(k interface{}, _ interface{}) bool {
+		switch c := k.(type) {
+		case *Client:
+			if c.queue == key {
+				c.Send <- &Message{Command: MessageCommandQueue, Payload: status}
+			}
+		}
+
+		return true
+	})
+}
+
+func (queues *Queues) Enqueue(key string, call *Call) bool {
+	queues.mutex.Lock()
+	defer queues.mutex.Unlock()
+
+	queue := queues.Map[key]
+	if queue == nil {
+		return false
+	}
+
+	id, ok := call.Id.(uint)
+	if !ok {
+		return false
+	}
+
+	for _, entry := range queue.Entries {
+		if entry.Id == id {
+			return false
+		}
+	}
+
+	entry := &QueueEntry{
+		Id:        id,
+		DateTime:  call.DateTime,
+		Priority:  queue.Priorities[call.System][call.Talkgroup],
+		System:    call.System,
+		Talkgroup: call.Talkgroup,
+	}
+
+	maxDepth := int(queues.controller.Options.ServerQueueMaxDepth)
+
+	if maxDepth > 0 && len(queue.Entries) >= maxDepth {
+		switch queues.controller.Options.ServerQueueSkipPolicy {
+		case QueueSkipLowest:
+			lowest := 0
+			for i, e := range queue.Entries {
+				if e.Priority < queue.Entries[lowest].Priority || (e.Priority == queue.Entries[lowest].Priority && e.DateTime.Before(queue.Entries[lowest].DateTime)) {
+					lowest = i
+				}
+			}
+			if entry.Priority <= queue.Entries[lowest].Priority {
+				return false
+			}
+			queue.Entries = append(queue.Entries[:lowest], queue.Entries[lowest+1:]...)
+		case QueueSkipNewest:
+			return false
+		default:
+			queue.Entries = queue.Entries[1:]
+		}
+	}
+
+	queue.Entries = append(queue.Entries, entry)
+
+	sort.SliceStable(queue.Entries, func(i int, j int) bool {
+		if queue.Entries[i].Priority != queue.Entries[j].Priority {
+			return queue.Entries[i].Priority > queue.Entries[j].Priority
+		}
+		return queue.Entries[i].DateTime.Before(queue.Entries[j].DateTime)
+	})
+
+	return true
+}
+
+func (queues *Queues) GetStatus(key string) map[string]interface{} {
+	queues.mutex.Lock()
+	defer queues.mutex.Unlock()
+
+	depth := 0
+	if queue := queues.Map[key]; queue != nil {
+		depth = len(queue.Entries)
+	}
+
+	return map[string]interface{}{
+		"depth":    depth,
+		"maxDepth": queues.controller.Options.ServerQueueMaxDepth,
+	}
+}
+
+func (queues *Queues) Next(key string) (uint, bool) {
+	queues.mutex.Lock()
+	defer queues.mutex.Unlock()
+
+	queue := queues.Map[key]
+	if queue == nil {
+		return 0, false
+	}
+
+	queue.lastSeen = time.Now()
+
+	if len(queue.Entries) == 0 {
+		return 0, false
+	}
+
+	entry := queue.Entries[0]
+	queue.Entries = queue.Entries[1:]
+
+	return entry.Id, true
+}
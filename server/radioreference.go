@@ -0,0 +1,807 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RadioReferenceConflictKeep      = "keep"
+	RadioReferenceConflictOverwrite = "overwrite"
+	RadioReferenceInterval          = 10 * time.Minute
+	RadioReferenceMaxSize           = 16 << 20
+	RadioReferenceUrl               = "https://api.radioreference.com/soap2/"
+)
+
+var radioReferenceTags = map[uint]string{
+	1:  "Multi-Dispatch",
+	2:  "Law Dispatch",
+	3:  "Fire Dispatch",
+	4:  "EMS Dispatch",
+	6:  "Multi-Tac",
+	7:  "Law Tac",
+	8:  "Fire-Tac",
+	9:  "EMS-Tac",
+	11: "Interop",
+	12: "Hospital",
+	13: "Ham",
+	14: "Public Works",
+	15: "Aircraft",
+	16: "Federal",
+	17: "Business",
+	20: "Railroad",
+	21: "Other",
+	22: "Multi-Talk",
+	23: "Law Talk",
+	24: "Fire-Talk",
+	25: "EMS-Talk",
+	26: "Transportation",
+	29: "Emergency Ops",
+	30: "Military",
+	31: "Media",
+	32: "Schools",
+	33: "Security",
+	34: "Utilities",
+	37: "Corrections",
+}
+
+type RadioReferenceImportRequest struct {
+	Conflict string `json:"conflict"`
+	DryRun   bool   `json:"dryRun"`
+	File     string `json:"file"`
+	SystemId uint   `json:"radioReferenceSystemId"`
+	System   uint   `json:"system"`
+}
+
+type RadioReferenceTalkgroup struct {
+	Group string `json:"group"`
+	Id    uint   `json:"id"`
+	Label string `json:"label"`
+	Name  string `json:"name"`
+	Tag   string `json:"tag"`
+}
+
+type RadioReferenceChange struct {
+	Added    bool                     `json:"added,omitempty"`
+	Group    string                   `json:"group"`
+	Id       uint                     `json:"id"`
+	Label    string                   `json:"label"`
+	Name     string                   `json:"name"`
+	Previous *RadioReferenceTalkgroup `json:"previous,omitempty"`
+	System   uint                     `json:"system"`
+	Tag      string                   `json:"tag"`
+}
+
+type RadioReference struct {
+	client     *http.Client
+	controller *Controller
+	imported   map[uint]time.Time
+	mutex      sync.Mutex
+	ticker     *time.Ticker
+}
+
+func NewRadioReference(controller *Controller) *RadioReference {
+	return &RadioReference{
+		client:     &http.Client{Timeout: time.Minute},
+		controller: controller,
+		imported:   map[uint]time.Time{},
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (rr *RadioReference) Fetch(sid uint) ([]*RadioReferenceTalkgroup, error) {
+	var (
+		cats struct {
+			Fault string `xml:"Body>Fault>faultstring"`
+			Items []struct {
+				Id   uint   `xml:"tgCid"`
+				Name string `xml:"tgCname"`
+			} `xml:"Body>getTrsTalkgroupCatsResponse>return>item"`
+		}
+		tgs struct {
+			Fault string `xml:"Body>Fault>faultstring"`
+			Items []struct {
+				Alpha string `xml:"tgAlpha"`
+				Cid   uint   `xml:"tgCid"`
+				Dec   uint   `xml:"tgDec"`
+				Descr string `xml:"tgDescr"`
+				Tags  []struct {
+					Id uint `xml:"tagId"`
+				} `xml:"tags>item"`
+			} `xml:"Body>getTrsTalkgroupsResponse>return>item"`
+		}
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("radioreference.fetch: %v", err)
+	}
+
+	options := rr.controller.Options
+
+	if len(options.RadioReferenceAppKey) == 0 || len(options.RadioReferenceUsername) == 0 || len(options.RadioReferencePassword) == 0 {
+		return nil, formatError(errors.New("radioreference credentials not configured"))
+	}
+
+	if sid == 0 {
+		return nil, formatError(errors.New("no radioreference system id"))
+	}
+
+	if err := rr.call("getTrsTalkgroupCats", fmt.Sprintf("<sid>%d</sid>", sid), &cats); err != nil {
+		return nil, formatError(err)
+	}
+
+	if len(cats.Fault) > 0 {
+		return nil, formatError(errors.New(cats.Fault))
+	}
+
+	if err := rr.call("getTrsTalkgroups", fmt.Sprintf("<sid>%d</sid><tgCid>0</tgCid><tgTag>0</tgTag><tgDec>0</tgDec>", sid), &tgs); err != nil {
+		return nil, formatError(err)
+	}
+
+	if len(tgs.Fault) > 0 {
+		return nil, formatError(errors.New(tgs.Fault))
+	}
+
+	groups := map[uint]string{}
+	for _, cat := range cats.Items {
+		groups[cat.Id] = strings.TrimSpace(cat.Name)
+	}
+
+	talkgroups := []*RadioReferenceTalkgroup{}
+
+	for _, item := range tgs.Items {
+		talkgroup := &RadioReferenceTalkgroup{
+			Group: groups[item.Cid],
+			Id:    item.Dec,
+			Label: strings.TrimSpace(item.Alpha),
+			Name:  strings.TrimSpace(item.Descr),
+		}
+
+		if len(item.Tags) > 0 {
+			talkgroup.Tag = radioReferenceTags[item.Tags[0].Id]
+		}
+
+		talkgroups = append(talkgroups, talkgroup)
+	}
+
+	return normalizeRadioReferenceTalkgroups(talkgroups)
+}
+
+func (rr *RadioReference) GetChanges(system *System, talkgroups []*RadioReferenceTalkgroup, conflict string) []RadioReferenceChange {
+	changes := []RadioReferenceChange{}
+
+	current := map[uint]*RadioReferenceTalkgroup{}
+
+	system.Talkgroups.mutex.Lock()
+	for _, talkgroup := range system.Talkgroups.List {
+		previous := &RadioReferenceTalkgroup{
+			Id:    talkgroup.Id,
+			Label: talkgroup.Label,
+			Name:  talkgroup.Name,
+		}
+		if group, ok := rr.controller.Groups.GetGroup(talkgroup.GroupId); ok {
+			previous.Group = group.Label
+		}
+		if tag, ok := rr.controller.Tags.GetTag(talkgroup.TagId); ok {
+			previous.Tag = tag.Label
+		}
+		current[talkgroup.Id] = previous
+	}
+	system.Talkgroups.mutex.Unlock()
+
+	for _, talkgroup := range talkgroups {
+		change := RadioReferenceChange{
+			Group:  talkgroup.Group,
+			Id:     talkgroup.Id,
+			Label:  talkgroup.Label,
+			Name:   talkgroup.Name,
+			System: system.Id,
+			Tag:    talkgroup.Tag,
+		}
+
+		previous, ok := current[talkgroup.Id]
+
+		if !ok {
+			change.Added = true
+			changes = append(changes, change)
+			continue
+		}
+
+		if conflict != RadioReferenceConflictOverwrite {
+			continue
+		}
+
+		if previous.Group == talkgroup.Group && previous.Label == talkgroup.Label && previous.Name == talkgroup.Name && previous.Tag == talkgroup.Tag {
+			continue
+		}
+
+		change.Previous = previous
+		changes = append(changes, change)
+	}
+
+	sort.Slice(changes, func(i int, j int) bool {
+		return changes[i].Id < changes[j].Id
+	})
+
+	return changes
+}
+
+func (rr *RadioReference) Import(system *System, talkgroups []*RadioReferenceTalkgroup, conflict string) ([]RadioReferenceChange, uint, error) {
+	var (
+		err      error
+		revision uint
+	)
+
+	admin := rr.controller.Admin
+
+	formatError := func(err error) error {
+		return fmt.Errorf("radioreference.import: %v", err)
+	}
+
+	admin.mutex.Lock()
+
+	changes := rr.GetChanges(system, talkgroups, conflict)
+
+	if len(changes) == 0 {
+		admin.mutex.Unlock()
+		return changes, revision, nil
+	}
+
+	groupIds, err := rr.getGroupIds(changes)
+	if err != nil {
+		admin.mutex.Unlock()
+		return nil, revision, formatError(err)
+	}
+
+	tagIds, err := rr.getTagIds(changes)
+	if err != nil {
+		admin.mutex.Unlock()
+		return nil, revision, formatError(err)
+	}
+
+	for _, change := range changes {
+		if change.Added {
+			system.Talkgroups.mutex.Lock()
+			system.Talkgroups.List = append(system.Talkgroups.List, &Talkgroup{
+				GroupId: groupIds[change.Group],
+				Id:      change.Id,
+				Label:   change.Label,
+				Name:    change.Name,
+				TagId:   tagIds[change.Tag],
+			})
+			system.Talkgroups.mutex.Unlock()
+
+		} else if talkgroup, ok := system.Talkgroups.GetTalkgroup(change.Id); ok {
+			talkgroup.GroupId = groupIds[change.Group]
+			talkgroup.Label = change.Label
+			talkgroup.Name = change.Name
+			talkgroup.TagId = tagIds[change.Tag]
+		}
+	}
+
+	if err = system.Talkgroups.Write(rr.controller.Database, system.Id); err != nil {
+		admin.mutex.Unlock()
+		return nil, revision, formatError(err)
+	}
+
+	revision, err = admin.IncrementRevision()
+	if err != nil {
+		rr.controller.Logs.LogEvent(LogLevelError, err.Error())
+	}
+
+	admin.mutex.Unlock()
+
+	admin.BroadcastNotice(map[string]interface{}{
+		"message":  "configuration changed by someone else",
+		"revision": revision,
+		"sections": []string{"groups", "systems", "tags"},
+	})
+
+	rr.controller.EmitConfig()
+
+	return changes, revision, nil
+}
+
+func (rr *RadioReference) Start() {
+	run := func() {
+		controller := rr.controller
+
+		if controller.Options.RadioReferenceInterval == 0 || len(controller.Options.RadioReferenceAppKey) == 0 {
+			return
+		}
+
+		if ok, err := controller.Leases.Acquire(controller.Database, "radioReference", LeaseTimeout); !ok {
+			if err != nil {
+				controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("radioreference.start: %v", err))
+			}
+			return
+		}
+
+		interval := time.Duration(controller.Options.RadioReferenceInterval) * time.Hour
+
+		for _, system := range controller.Systems.List {
+			if system.RadioReferenceSystemId == 0 {
+				continue
+			}
+
+			rr.mutex.Lock()
+			if t, ok := rr.imported[system.Id]; ok && time.Since(t) < interval {
+				rr.mutex.Unlock()
+				continue
+			}
+			rr.imported[system.Id] = time.Now()
+			rr.mutex.Unlock()
+
+			talkgroups, err := rr.Fetch(system.RadioReferenceSystemId)
+			if err != nil {
+				controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("radioreference import: system=%d %v", system.Id, err))
+				continue
+			}
+
+			changes, _, err := rr.Import(system, talkgroups, system.RadioReferenceConflict)
+			if err != nil {
+				controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("radioreference import: system=%d %v", system.Id, err))
+				continue
+			}
+
+			if len(changes) > 0 {
+				controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("radioreference import: system=%d %d talkgroups changed", system.Id, len(changes)))
+			}
+		}
+	}
+
+	rr.ticker = time.NewTicker(RadioReferenceInterval)
+
+	go func() {
+		for range rr.ticker.C {
+			run()
+		}
+	}()
+}
+
+func (rr *RadioReference) call(method string, params string, v interface{}) error {
+	options := rr.controller.Options
+
+	escape := func(s string) string {
+		var b bytes.Buffer
+		xml.EscapeText(&b, []byte(s))
+		return b.String()
+	}
+
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?><SOAP-ENV:Envelope xmlns:SOAP-ENV="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ns1="http://api.radioreference.com/soap2"><SOAP-ENV:Body><ns1:%s>%s<authInfo><username>%s</username><password>%s</password><appKey>%s</appKey><version>latest</version><style>rpc</style></authInfo></ns1:%s></SOAP-ENV:Body></SOAP-ENV:Envelope>`,
+		method, params, escape(options.RadioReferenceUsername), escape(options.RadioReferencePassword), escape(options.RadioReferenceAppKey), method)
+
+	req, err := http.NewRequest(http.MethodPost, RadioReferenceUrl, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", fmt.Sprintf("http://api.radioreference.com/soap2#%s", method))
+
+	res, err := rr.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if err = xml.NewDecoder(io.LimitReader(res.Body, RadioReferenceMaxSize)).Decode(v); err != nil {
+		return fmt.Errorf("%s returned %s", method, res.Status)
+	}
+
+	return nil
+}
+
+func (rr *RadioReference) getGroupIds(changes []RadioReferenceChange) (map[string]uint, error) {
+	var err error
+
+	groups := rr.controller.Groups
+	ids := map[string]uint{}
+	missing := false
+
+	for _, change := range changes {
+		if _, ok := groups.GetGroup(change.Group); !ok {
+			groups.mutex.Lock()
+			groups.List = append(groups.List, &Group{Label: change.Group})
+			groups.mutex.Unlock()
+			missing = true
+		}
+	}
+
+	if missing {
+		if err = groups.Write(rr.controller.Database); err != nil {
+			return nil, err
+		}
+
+		if err = groups.Read(rr.controller.Database); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, change := range changes {
+		group, ok := groups.GetGroup(change.Group)
+		if !ok {
+			return nil, fmt.Errorf("unable to get group %s", change.Group)
+		}
+
+		switch v := group.Id.(type) {
+		case uint:
+			ids[change.Group] = v
+		default:
+			return nil, fmt.Errorf("unable to get group id for group %s", change.Group)
+		}
+	}
+
+	return ids, nil
+}
+
+func (rr *RadioReference) getTagIds(changes []RadioReferenceChange) (map[string]uint, error) {
+	var err error
+
+	tags := rr.controller.Tags
+	ids := map[string]uint{}
+	missing := false
+
+	for _, change := range changes {
+		if _, ok := tags.GetTag(change.Tag); !ok {
+			tags.mutex.Lock()
+			tags.List = append(tags.List, &Tag{Label: change.Tag})
+			tags.mutex.Unlock()
+			missing = true
+		}
+	}
+
+	if missing {
+		if err = tags.Write(rr.controller.Database); err != nil {
+			return nil, err
+		}
+
+		if err = tags.Read(rr.controller.Database); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, change := range changes {
+		tag, ok := tags.GetTag(change.Tag)
+		if !ok {
+			return nil, fmt.Errorf("unable to get tag %s", change.Tag)
+		}
+
+		switch v := tag.Id.(type) {
+		case uint:
+			ids[change.Tag] = v
+		default:
+			return nil, fmt.Errorf("unable to get tag id for tag %s", change.Tag)
+		}
+	}
+
+	return ids, nil
+}
+
+func (admin *Admin) TalkgroupsImportHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err        error
+		req        RadioReferenceImportRequest
+		talkgroups []*RadioReferenceTalkgroup
+	)
+
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.talkgroupsimporthandler: %s", err.Error()))
+	}
+
+	writeError := func(err error) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err = json.NewDecoder(io.LimitReader(r.Body, RadioReferenceMaxSize)).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+	if !req.DryRun {
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if !admin.CheckWritable(w) {
+			return
+		}
+	}
+
+	system, ok := admin.Controller.Systems.GetSystem(req.System)
+	if !ok {
+		writeError(fmt.Errorf("unknown system %d", req.System))
+		return
+	}
+
+	if len(req.Conflict) == 0 {
+		req.Conflict = system.RadioReferenceConflict
+	}
+
+	switch req.Conflict {
+	case "":
+		req.Conflict = RadioReferenceConflictKeep
+	case RadioReferenceConflictKeep, RadioReferenceConflictOverwrite:
+	default:
+		writeError(fmt.Errorf("invalid conflict rule %s", req.Conflict))
+		return
+	}
+
+	if len(req.File) > 0 {
+		talkgroups, err = ParseRadioReferenceFile(strings.NewReader(req.File))
+	} else {
+		if req.SystemId == 0 {
+			req.SystemId = system.RadioReferenceSystemId
+		}
+		talkgroups, err = admin.Controller.RadioReference.Fetch(req.SystemId)
+	}
+
+	if err != nil {
+		writeError(err)
+		return
+	}
+
+	res := map[string]interface{}{
+		"dryRun":     req.DryRun,
+		"talkgroups": len(talkgroups),
+	}
+
+	if req.DryRun {
+		res["changes"] = admin.Controller.RadioReference.GetChanges(system, talkgroups, req.Conflict)
+
+	} else {
+		changes, revision, err := admin.Controller.RadioReference.Import(system, talkgroups, req.Conflict)
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		if len(changes) > 0 {
+			admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("%d talkgroups imported by user=\"%s\"", len(changes), user.Username))
+			admin.Controller.AuditLog.Record(r, user, AuditLogActionTalkgroupsImport, GetRadioReferenceAuditLogChanges(changes))
+			res["revision"] = revision
+		}
+
+		res["changes"] = changes
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		logError(err)
+	}
+}
+
+func GetRadioReferenceAuditLogChanges(changes []RadioReferenceChange) []AuditLogChange {
+	auditChanges := []AuditLogChange{}
+
+	for _, change := range changes {
+		if len(auditChanges) >= AuditLogMaxChanges {
+			break
+		}
+
+		path := fmt.Sprintf("systems[id=%d].talkgroups[id=%d]", change.System, change.Id)
+
+		to := &RadioReferenceTalkgroup{
+			Group: change.Group,
+			Id:    change.Id,
+			Label: change.Label,
+			Name:  change.Name,
+			Tag:   change.Tag,
+		}
+
+		if change.Added || change.Previous == nil {
+			auditChanges = append(auditChanges, AuditLogChange{Op: "add", Path: path, To: to})
+		} else {
+			auditChanges = append(auditChanges, AuditLogChange{Op: "replace", Path: path, From: change.Previous, To: to})
+		}
+	}
+
+	return auditChanges
+}
+
+func ParseRadioReferenceFile(r io.Reader) ([]*RadioReferenceTalkgroup, error) {
+	var b bytes.Buffer
+
+	if _, err := io.Copy(&b, io.LimitReader(r, RadioReferenceMaxSize)); err != nil {
+		return nil, err
+	}
+
+	for _, line := range strings.SplitN(b.String(), "\n", 64) {
+		if strings.HasPrefix(line, "TGID\t") || strings.HasPrefix(line, "T-Group\t") {
+			return ParseRadioReferenceHpd(&b)
+		}
+	}
+
+	return ParseRadioReferenceCsv(&b)
+}
+
+func ParseRadioReferenceCsv(r io.Reader) ([]*RadioReferenceTalkgroup, error) {
+	idCol, labelCol, nameCol, tagCol, groupCol := 0, 2, 4, 5, 6
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) > 0 && len(records[0]) > 0 {
+		if _, err := strconv.ParseUint(strings.TrimSpace(records[0][0]), 10, 64); err != nil {
+			header := map[string]int{}
+			for i, name := range records[0] {
+				name = strings.ToLower(name)
+				name = strings.NewReplacer(" ", "", "_", "", "-", "", "\ufeff", "").Replace(name)
+				if _, ok := header[name]; !ok {
+					header[name] = i
+				}
+			}
+
+			for name, col := range map[string]*int{"decimal": &idCol, "alphatag": &labelCol, "description": &nameCol, "tag": &tagCol, "category": &groupCol} {
+				if i, ok := header[name]; ok {
+					*col = i
+				}
+			}
+
+			records = records[1:]
+		}
+	}
+
+	talkgroups := []*RadioReferenceTalkgroup{}
+
+	field := func(record []string, i int) string {
+		if i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	for _, record := range records {
+		id, err := strconv.ParseUint(field(record, idCol), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		talkgroups = append(talkgroups, &RadioReferenceTalkgroup{
+			Group: field(record, groupCol),
+			Id:    uint(id),
+			Label: field(record, labelCol),
+			Name:  field(record, nameCol),
+			Tag:   field(record, tagCol),
+		})
+	}
+
+	return normalizeRadioReferenceTalkgroups(talkgroups)
+}
+
+func ParseRadioReferenceHpd(r io.Reader) ([]*RadioReferenceTalkgroup, error) {
+	groups := map[string]string{}
+	talkgroups := []*RadioReferenceTalkgroup{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), RadioReferenceMaxSize)
+
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimRight(scanner.Text(), "\r"), "\t")
+
+		switch fields[0] {
+		case "T-Group":
+			if len(fields) > 3 {
+				groups[fields[1]] = strings.TrimSpace(fields[3])
+			}
+
+		case "TGID":
+			if len(fields) < 6 {
+				continue
+			}
+
+			id, err := strconv.ParseUint(strings.TrimSpace(fields[5]), 10, 64)
+			if err != nil {
+				continue
+			}
+
+			talkgroup := &RadioReferenceTalkgroup{
+				Group: groups[fields[2]],
+				Id:    uint(id),
+				Label: strings.TrimSpace(fields[3]),
+			}
+
+			if len(fields) > 7 {
+				if tagId, err := strconv.ParseUint(strings.TrimSpace(fields[7]), 10, 64); err == nil {
+					talkgroup.Tag = radioReferenceTags[uint(tagId)]
+				}
+			}
+
+			talkgroups = append(talkgroups, talkgroup)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return normalizeRadioReferenceTalkgroups(talkgroups)
+}
+
+func normalizeRadioReferenceTalkgroups(talkgroups []*RadioReferenceTalkgroup) ([]*RadioReferenceTalkgroup, error) {
+	seen := map[uint]bool{}
+	normalized := []*RadioReferenceTalkgroup{}
+
+	for _, talkgroup := range talkgroups {
+		if talkgroup.Id == 0 || seen[talkgroup.Id] {
+			continue
+		}
+
+		seen[talkgroup.Id] = true
+
+		if len(talkgroup.Label) == 0 {
+			talkgroup.Label = fmt.Sprintf("%d", talkgroup.Id)
+		}
+
+		if len(talkgroup.Name) == 0 {
+			talkgroup.Name = talkgroup.Label
+		}
+
+		if len(talkgroup.Group) == 0 {
+			talkgroup.Group = "Unknown"
+		}
+
+		if len(talkgroup.Tag) == 0 {
+			talkgroup.Tag = "Untagged"
+		}
+
+		normalized = append(normalized, talkgroup)
+	}
+
+	if len(normalized) == 0 {
+		return nil, errors.New("no talkgroup found")
+	}
+
+	return normalized, nil
+}
@@ -0,0 +1,455 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RateLimitAdmin     = "admin"
+	RateLimitUpload    = "upload"
+	RateLimitWebsocket = "websocket"
+
+	RateLimitReasonAdmin      = "admin"
+	RateLimitReasonFailures   = "failures"
+	RateLimitReasonRejections = "rejections"
+)
+
+var trustedProxies = &TrustedProxies{}
+
+type RateLimitBan struct {
+	Address string    `json:"address"`
+	Count   uint      `json:"count"`
+	Reason  string    `json:"reason"`
+	Since   time.Time `json:"since"`
+	Until   time.Time `json:"until"`
+}
+
+type RateLimiter struct {
+	Controller *Controller
+	bans       map[string]*RateLimitBan
+	buckets    map[string]*rateLimitBucket
+	cleaned    time.Time
+	mutex      sync.Mutex
+	strikes    map[string]*rateLimitStrikes
+}
+
+type rateLimitBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+type rateLimitStrikes struct {
+	failures   uint
+	rejections uint
+	since      time.Time
+}
+
+func NewRateLimiter(controller *Controller) *RateLimiter {
+	return &RateLimiter{
+		Controller: controller,
+		bans:       map[string]*RateLimitBan{},
+		buckets:    map[string]*rateLimitBucket{},
+		cleaned:    time.Now(),
+		mutex:      sync.Mutex{},
+		strikes:    map[string]*rateLimitStrikes{},
+	}
+}
+
+func (limiter *RateLimiter) Allow(address string, class string, rate uint) (bool, time.Duration) {
+	if rate == 0 {
+		return true, 0
+	}
+
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now()
+	limiter.cleanup(now)
+
+	perSecond := float64(rate) / 60
+
+	key := fmt.Sprintf("%s|%s", class, address)
+
+	bucket := limiter.buckets[key]
+	if bucket == nil {
+		bucket = &rateLimitBucket{tokens: float64(rate), updated: now}
+		limiter.buckets[key] = bucket
+	} else {
+		bucket.tokens = math.Min(float64(rate), bucket.tokens+now.Sub(bucket.updated).Seconds()*perSecond)
+		bucket.updated = now
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	strikes := limiter.getStrikes(address, now)
+	strikes.rejections++
+
+	if threshold := limiter.Controller.Options.RateLimitBanRejections; threshold > 0 && strikes.rejections >= threshold {
+		limiter.ban(address, RateLimitReasonRejections, strikes.rejections, now)
+	} else if strikes.rejections == 1 {
+		limiter.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("ratelimit: too many %s requests from ip=%s", class, address))
+	}
+
+	return false, time.Duration(math.Ceil((1-bucket.tokens)/perSecond)) * time.Second
+}
+
+func (limiter *RateLimiter) Ban(address string, duration time.Duration) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now()
+
+	limiter.bans[address] = &RateLimitBan{
+		Address: address,
+		Count:   1,
+		Reason:  RateLimitReasonAdmin,
+		Since:   now,
+		Until:   now.Add(duration),
+	}
+
+	delete(limiter.strikes, address)
+}
+
+func (limiter *RateLimiter) Fail(address string) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now()
+
+	strikes := limiter.getStrikes(address, now)
+	strikes.failures++
+
+	if threshold := limiter.Controller.Options.RateLimitBanFailures; threshold > 0 && strikes.failures >= threshold {
+		limiter.ban(address, RateLimitReasonFailures, strikes.failures, now)
+	}
+}
+
+func (limiter *RateLimiter) GetBans() []RateLimitBan {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now()
+
+	bans := []RateLimitBan{}
+
+	for _, ban := range limiter.bans {
+		if ban.Until.After(now) {
+			bans = append(bans, *ban)
+		}
+	}
+
+	sort.Slice(bans, func(i int, j int) bool {
+		return bans[i].Since.After(bans[j].Since)
+	})
+
+	return bans
+}
+
+func (limiter *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		options := limiter.Controller.Options
+
+		trustedProxies.Update(options.TrustedProxies)
+
+		address := GetRemoteAddr(r)
+
+		if limiter.IsBanned(address) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var rate uint
+
+		class := limiter.getClass(r)
+		switch class {
+		case RateLimitAdmin:
+			rate = options.RateLimitAdmin
+		case RateLimitUpload:
+			rate = options.RateLimitUpload
+		case RateLimitWebsocket:
+			rate = options.RateLimitWebsocket
+		}
+
+		if ok, retry := limiter.Allow(address, class, rate); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retry.Seconds())))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (limiter *RateLimiter) IsBanned(address string) bool {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if ban := limiter.bans[address]; ban != nil {
+		if ban.Until.After(time.Now()) {
+			return true
+		}
+		delete(limiter.bans, address)
+	}
+
+	return false
+}
+
+func (limiter *RateLimiter) Unban(address string) bool {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	if len(address) == 0 {
+		limiter.bans = map[string]*RateLimitBan{}
+		limiter.strikes = map[string]*rateLimitStrikes{}
+		return true
+	}
+
+	if limiter.bans[address] == nil {
+		return false
+	}
+
+	delete(limiter.bans, address)
+	delete(limiter.strikes, address)
+
+	return true
+}
+
+func (limiter *RateLimiter) ban(address string, reason string, count uint, now time.Time) {
+	duration := time.Duration(limiter.Controller.Options.RateLimitBanDuration) * time.Minute
+	if duration == 0 {
+		return
+	}
+
+	limiter.bans[address] = &RateLimitBan{
+		Address: address,
+		Count:   count,
+		Reason:  reason,
+		Since:   now,
+		Until:   now.Add(duration),
+	}
+
+	delete(limiter.strikes, address)
+
+	limiter.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("ratelimit: ip=%s banned for %s after %d %s", address, duration, count, reason))
+}
+
+func (limiter *RateLimiter) cleanup(now time.Time) {
+	if now.Sub(limiter.cleaned) < time.Minute {
+		return
+	}
+
+	limiter.cleaned = now
+
+	for key, bucket := range limiter.buckets {
+		if now.Sub(bucket.updated) > time.Minute {
+			delete(limiter.buckets, key)
+		}
+	}
+
+	for address, ban := range limiter.bans {
+		if !ban.Until.After(now) {
+			delete(limiter.bans, address)
+		}
+	}
+
+	window := limiter.getWindow()
+	for address, strikes := range limiter.strikes {
+		if now.Sub(strikes.since) > window {
+			delete(limiter.strikes, address)
+		}
+	}
+}
+
+func (limiter *RateLimiter) getClass(r *http.Request) string {
+	if strings.EqualFold(r.Header.Get("upgrade"), "websocket") {
+		return RateLimitWebsocket
+	}
+
+	switch r.URL.Path {
+	case "/api/call-upload", "/api/heartbeat", "/api/trunk-recorder-call-upload":
+		return RateLimitUpload
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/api/admin/") {
+		return RateLimitAdmin
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/api/v1/") {
+		return RateLimitUpload
+	}
+
+	return ""
+}
+
+func (limiter *RateLimiter) getStrikes(address string, now time.Time) *rateLimitStrikes {
+	strikes := limiter.strikes[address]
+
+	if strikes == nil || now.Sub(strikes.since) > limiter.getWindow() {
+		strikes = &rateLimitStrikes{since: now}
+		limiter.strikes[address] = strikes
+	}
+
+	return strikes
+}
+
+func (limiter *RateLimiter) getWindow() time.Duration {
+	if minutes := limiter.Controller.Options.RateLimitBanDuration; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+
+	return 10 * time.Minute
+}
+
+type TrustedProxies struct {
+	all    bool
+	mutex  sync.RWMutex
+	nets   []*net.IPNet
+	parsed bool
+	value  string
+}
+
+func (proxies *TrustedProxies) Contains(ip net.IP) bool {
+	proxies.mutex.RLock()
+	parsed := proxies.parsed
+	proxies.mutex.RUnlock()
+
+	if !parsed {
+		proxies.Update("")
+	}
+
+	proxies.mutex.RLock()
+	defer proxies.mutex.RUnlock()
+
+	if ip == nil {
+		return false
+	}
+
+	if proxies.all {
+		return true
+	}
+
+	for _, n := range proxies.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (proxies *TrustedProxies) Update(value string) {
+	proxies.mutex.RLock()
+	unchanged := proxies.parsed && proxies.value == value
+	proxies.mutex.RUnlock()
+
+	if unchanged {
+		return
+	}
+
+	proxies.mutex.Lock()
+	defer proxies.mutex.Unlock()
+
+	proxies.all = false
+	proxies.nets = []*net.IPNet{}
+	proxies.parsed = true
+	proxies.value = value
+
+	if len(strings.TrimSpace(value)) == 0 {
+		value = "127.0.0.0/8,::1/128"
+	}
+
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+
+		if s == "*" {
+			proxies.all = true
+			continue
+		}
+
+		if !strings.Contains(s, "/") {
+			if ip := net.ParseIP(s); ip == nil {
+				continue
+			} else if ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+
+		if _, n, err := net.ParseCIDR(s); err == nil {
+			proxies.nets = append(proxies.nets, n)
+		}
+	}
+}
+
+func (admin *Admin) BansHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if b, err := json.Marshal(map[string]interface{}{"bans": admin.Controller.RateLimiter.GetBans()}); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	case http.MethodDelete:
+		user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		address := r.URL.Query().Get("address")
+
+		if !admin.Controller.RateLimiter.Unban(address) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if len(address) > 0 {
+			admin.Controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("ratelimit: ip=%s unbanned", address))
+		} else {
+			admin.Controller.Logs.LogEvent(LogLevelInfo, "ratelimit: all bans lifted")
+		}
+
+		admin.Controller.AuditLog.Record(r, user, AuditLogActionBanRemove, []AuditLogChange{{Op: "remove", Path: "bans", From: address}})
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
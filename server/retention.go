@@ -0,0 +1,495 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RetentionScopeDefault   = "default"
+	RetentionScopeSystem    = "system"
+	RetentionScopeTag       = "tag"
+	RetentionScopeTalkgroup = "talkgroup"
+)
+
+type Retention struct {
+	Id        interface{} `json:"_id"`
+	Days      uint        `json:"days"`
+	Label     string      `json:"label"`
+	Order     interface{} `json:"order"`
+	System    uint        `json:"system"`
+	TagId     uint        `json:"tagId"`
+	Talkgroup uint        `json:"talkgroup"`
+}
+
+func (retention *Retention) FromMap(m map[string]interface{}) *Retention {
+	switch v := m["_id"].(type) {
+	case float64:
+		retention.Id = uint(v)
+	}
+
+	switch v := m["days"].(type) {
+	case float64:
+		retention.Days = uint(v)
+	}
+
+	switch v := m["label"].(type) {
+	case string:
+		retention.Label = v
+	}
+
+	switch v := m["order"].(type) {
+	case float64:
+		retention.Order = uint(v)
+	}
+
+	switch v := m["system"].(type) {
+	case float64:
+		retention.System = uint(v)
+	}
+
+	switch v := m["tagId"].(type) {
+	case float64:
+		retention.TagId = uint(v)
+	}
+
+	switch v := m["talkgroup"].(type) {
+	case float64:
+		retention.Talkgroup = uint(v)
+	}
+
+	return retention
+}
+
+func (retention *Retention) GetScope() string {
+	switch {
+	case retention.TagId > 0:
+		return RetentionScopeTag
+	case retention.Talkgroup > 0:
+		return RetentionScopeTalkgroup
+	default:
+		return RetentionScopeSystem
+	}
+}
+
+func (retention *Retention) Validate() error {
+	if retention.TagId > 0 && (retention.System > 0 || retention.Talkgroup > 0) {
+		return errors.New("applies to either a tag or a system")
+	}
+
+	if retention.TagId == 0 && retention.System == 0 {
+		return errors.New("no system nor tag")
+	}
+
+	return nil
+}
+
+type RetentionMatch struct {
+	Days     uint        `json:"days"`
+	Expires  interface{} `json:"expires,omitempty"`
+	Label    string      `json:"label,omitempty"`
+	PolicyId interface{} `json:"policyId,omitempty"`
+	Scope    string      `json:"scope"`
+}
+
+type Retentions struct {
+	List  []*Retention
+	mutex sync.Mutex
+}
+
+func NewRetentions() *Retentions {
+	return &Retentions{
+		List:  []*Retention{},
+		mutex: sync.Mutex{},
+	}
+}
+
+func (retentions *Retentions) FromMap(f []interface{}) *Retentions {
+	retentions.mutex.Lock()
+	defer retentions.mutex.Unlock()
+
+	retentions.List = []*Retention{}
+
+	for _, r := range f {
+		switch m := r.(type) {
+		case map[string]interface{}:
+			retention := &Retention{}
+			retention.FromMap(m)
+			retentions.List = append(retentions.List, retention)
+		}
+	}
+
+	return retentions
+}
+
+func (retentions *Retentions) GetPruneGroups(systems *Systems, pruneDays uint) map[uint][]string {
+	retentions.mutex.Lock()
+	defer retentions.mutex.Unlock()
+
+	groups := map[uint][]string{}
+
+	if len(retentions.List) == 0 {
+		return groups
+	}
+
+	joinIds := func(ids []uint) string {
+		s := []string{}
+		for _, id := range ids {
+			s = append(s, strconv.FormatUint(uint64(id), 10))
+		}
+		return strings.Join(s, ", ")
+	}
+
+	type scope struct {
+		system     uint
+		talkgroups map[uint]uint
+	}
+
+	scopes := map[uint]*scope{}
+
+	for _, system := range systems.List {
+		s := &scope{system: system.Id, talkgroups: map[uint]uint{}}
+		for _, talkgroup := range system.Talkgroups.List {
+			s.talkgroups[talkgroup.Id] = talkgroup.TagId
+		}
+		scopes[system.Id] = s
+	}
+
+	for _, retention := range retentions.List {
+		if retention.System == 0 {
+			continue
+		}
+		s := scopes[retention.System]
+		if s == nil {
+			s = &scope{system: retention.System, talkgroups: map[uint]uint{}}
+			scopes[retention.System] = s
+		}
+		if _, ok := s.talkgroups[retention.Talkgroup]; retention.Talkgroup > 0 && !ok {
+			s.talkgroups[retention.Talkgroup] = 0
+		}
+	}
+
+	for _, s := range scopes {
+		systemDays := retentions.resolve(s.system, 0, 0, pruneDays).Days
+
+		excluded := []uint{}
+		exceptions := map[uint][]uint{}
+
+		for talkgroupId, tagId := range s.talkgroups {
+			if days := retentions.resolve(s.system, talkgroupId, tagId, pruneDays).Days; days != systemDays {
+				excluded = append(excluded, talkgroupId)
+				exceptions[days] = append(exceptions[days], talkgroupId)
+			}
+		}
+
+		sort.Slice(excluded, func(i int, j int) bool { return excluded[i] < excluded[j] })
+
+		if systemDays != pruneDays {
+			where := fmt.Sprintf("`system` = %d", s.system)
+			if len(excluded) > 0 {
+				where = fmt.Sprintf("(%s and `talkgroup` not in (%s))", where, joinIds(excluded))
+			}
+			groups[systemDays] = append(groups[systemDays], where)
+		}
+
+		for days, talkgroupIds := range exceptions {
+			if days == pruneDays {
+				continue
+			}
+			sort.Slice(talkgroupIds, func(i int, j int) bool { return talkgroupIds[i] < talkgroupIds[j] })
+			groups[days] = append(groups[days], fmt.Sprintf("(`system` = %d and `talkgroup` in (%s))", s.system, joinIds(talkgroupIds)))
+		}
+	}
+
+	for days := range groups {
+		sort.Strings(groups[days])
+	}
+
+	return groups
+}
+
+func (retentions *Retentions) Read(db *Database) error {
+	var (
+		err   error
+		id    sql.NullFloat64
+		order sql.NullFloat64
+		rows  *sql.Rows
+	)
+
+	retentions.mutex.Lock()
+	defer retentions.mutex.Unlock()
+
+	retentions.List = []*Retention{}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("retentions.read: %v", err)
+	}
+
+	if rows, err = db.Sql.Query("select `_id`, `days`, `label`, `order`, `system`, `tagId`, `talkgroup` from `rdioScannerRetentions`"); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		retention := &Retention{}
+
+		if err = rows.Scan(&id, &retention.Days, &retention.Label, &order, &retention.System, &retention.TagId, &retention.Talkgroup); err != nil {
+			break
+		}
+
+		if id.Valid && id.Float64 > 0 {
+			retention.Id = uint(id.Float64)
+		}
+
+		if order.Valid && order.Float64 > 0 {
+			retention.Order = uint(order.Float64)
+		}
+
+		retentions.List = append(retentions.List, retention)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	return nil
+}
+
+func (retentions *Retentions) Resolve(systems *Systems, systemId uint, talkgroupId uint, pruneDays uint) RetentionMatch {
+	var tagId uint
+
+	if system, ok := systems.GetSystem(systemId); ok {
+		if talkgroup, ok := system.Talkgroups.GetTalkgroup(talkgroupId); ok {
+			tagId = talkgroup.TagId
+		}
+	}
+
+	retentions.mutex.Lock()
+	defer retentions.mutex.Unlock()
+
+	return retentions.resolve(systemId, talkgroupId, tagId, pruneDays)
+}
+
+func (retentions *Retentions) Validate() error {
+	scopes := map[string]bool{}
+
+	for i, retention := range retentions.List {
+		if err := retention.Validate(); err != nil {
+			return fmt.Errorf("retention policy %d: %v", i+1, err)
+		}
+
+		key := fmt.Sprintf("%d:%d:%d", retention.System, retention.Talkgroup, retention.TagId)
+		if scopes[key] {
+			return fmt.Errorf("retention policy %d: duplicate %s policy", i+1, retention.GetScope())
+		}
+		scopes[key] = true
+	}
+
+	return nil
+}
+
+func (retentions *Retentions) Write(db *Database) error {
+	var (
+		count  uint
+		err    error
+		rows   *sql.Rows
+		rowIds = []uint{}
+	)
+
+	retentions.mutex.Lock()
+	defer retentions.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("retentions.write: %v", err)
+	}
+
+	for _, retention := range retentions.List {
+		if err = db.Sql.QueryRow("select count(*) from `rdioScannerRetentions` where `_id` = ?", retention.Id).Scan(&count); err != nil {
+			break
+		}
+
+		if count == 0 {
+			if _, err = db.Sql.Exec("insert into `rdioScannerRetentions` (`_id`, `days`, `label`, `order`, `system`, `tagId`, `talkgroup`) values (?, ?, ?, ?, ?, ?, ?)", retention.Id, retention.Days, retention.Label, retention.Order, retention.System, retention.TagId, retention.Talkgroup); err != nil {
+				break
+			}
+
+		} else if _, err = db.Sql.Exec("update `rdioScannerRetentions` set `_id` = ?, `days` = ?, `label` = ?, `order` = ?, `system` = ?, `tagId` = ?, `talkgroup` = ? where `_id` = ?", retention.Id, retention.Days, retention.Label, retention.Order, retention.System, retention.TagId, retention.Talkgroup, retention.Id); err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if rows, err = db.Sql.Query("select `_id` from `rdioScannerRetentions`"); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		var rowId uint
+		if err = rows.Scan(&rowId); err != nil {
+			break
+		}
+		remove := true
+		for _, retention := range retentions.List {
+			if retention.Id == nil || retention.Id == rowId {
+				remove = false
+				break
+			}
+		}
+		if remove {
+			rowIds = append(rowIds, rowId)
+		}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if len(rowIds) > 0 {
+		if b, err := json.Marshal(rowIds); err == nil {
+			s := string(b)
+			s = strings.ReplaceAll(s, "[", "(")
+			s = strings.ReplaceAll(s, "]", ")")
+			q := fmt.Sprintf("delete from `rdioScannerRetentions` where `_id` in %v", s)
+			if _, err = db.Sql.Exec(q); err != nil {
+				return formatError(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (retentions *Retentions) resolve(systemId uint, talkgroupId uint, tagId uint, pruneDays uint) RetentionMatch {
+	var bySystem, byTag, byTalkgroup *Retention
+
+	for _, retention := range retentions.List {
+		switch retention.GetScope() {
+		case RetentionScopeSystem:
+			if retention.System == systemId {
+				bySystem = retention
+			}
+		case RetentionScopeTag:
+			if tagId > 0 && retention.TagId == tagId {
+				byTag = retention
+			}
+		case RetentionScopeTalkgroup:
+			if talkgroupId > 0 && retention.System == systemId && retention.Talkgroup == talkgroupId {
+				byTalkgroup = retention
+			}
+		}
+	}
+
+	for _, retention := range []*Retention{byTalkgroup, byTag, bySystem} {
+		if retention != nil {
+			return RetentionMatch{
+				Days:     retention.Days,
+				Label:    retention.Label,
+				PolicyId: retention.Id,
+				Scope:    retention.GetScope(),
+			}
+		}
+	}
+
+	return RetentionMatch{Days: pruneDays, Scope: RetentionScopeDefault}
+}
+
+func (admin *Admin) RetentionTestHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		dateTime    interface{}
+		systemId    uint
+		talkgroupId uint
+	)
+
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+
+	if s := query.Get("callId"); len(s) > 0 {
+		id, err := strconv.Atoi(s)
+		if err != nil || id <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		db := admin.Controller.Database
+
+		if err = db.Sql.QueryRow("select `dateTime`, `system`, `talkgroup` from `rdioScannerCalls` where `id` = ?", id).Scan(&dateTime, &systemId, &talkgroupId); err == sql.ErrNoRows {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		} else if err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.retentiontesthandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+	} else {
+		system, err := strconv.Atoi(query.Get("system"))
+		if err != nil || system <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		systemId = uint(system)
+
+		if s := query.Get("talkgroup"); len(s) > 0 {
+			talkgroup, err := strconv.Atoi(s)
+			if err != nil || talkgroup <= 0 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			talkgroupId = uint(talkgroup)
+		}
+	}
+
+	match := admin.Controller.Retentions.Resolve(admin.Controller.Systems, systemId, talkgroupId, admin.Controller.Options.PruneDays)
+
+	if dateTime != nil && match.Days > 0 {
+		if d, err := admin.Controller.Database.ParseDateTime(dateTime); err == nil {
+			match.Expires = d.Add(24 * time.Hour * time.Duration(match.Days)).Format(time.RFC3339)
+		}
+	}
+
+	if b, err := json.Marshal(match); err == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+func (admin *Admin) GetRevision() uint {
+	var (
+		revision uint
+		s        string
+	)
+
+	if err := admin.Controller.Database.Sql.QueryRow("select `val` from `rdioScannerConfigs` where `key` = 'revision'").Scan(&s); err == nil {
+		json.Unmarshal([]byte(s), &revision)
+	}
+
+	return revision
+}
+
+func (admin *Admin) GetRequestRevision(r *http.Request, m map[string]interface{}) (uint, bool) {
+	if v := strings.Trim(strings.TrimPrefix(r.Header.Get("If-Match"), "W/"), `"`); len(v) > 0 {
+		if i, err := strconv.ParseUint(v, 10, 32); err == nil {
+			return uint(i), true
+		}
+	}
+
+	switch v := m["revision"].(type) {
+	case float64:
+		return uint(v), true
+	}
+
+	return 0, false
+}
+
+func (admin *Admin) IncrementRevision() (uint, error) {
+	formatError := func(err error) error {
+		return fmt.Errorf("admin.incrementrevision: %v", err)
+	}
+
+	revision := admin.GetRevision() + 1
+
+	b, err := json.Marshal(revision)
+	if err != nil {
+		return 0, formatError(err)
+	}
+
+	res, err := admin.Controller.Database.Sql.Exec("update `rdioScannerConfigs` set `val` = ? where `key` = 'revision'", string(b))
+	if err != nil {
+		return 0, formatError(err)
+	}
+
+	if i, err := res.RowsAffected(); err == nil && i == 0 {
+		if _, err = admin.Controller.Database.Sql.Exec("insert into `rdioScannerConfigs` (`key`, `val`) values (?, ?)", "revision", string(b)); err != nil {
+			return 0, formatError(err)
+		}
+	}
+
+	return revision, nil
+}
+
+func (admin *Admin) BroadcastNotice(notice map[string]interface{}) {
+	if b, err := json.Marshal(map[string]interface{}{"notice": notice}); err == nil {
+		select {
+		case admin.Broadcast <- &b:
+		case <-admin.done:
+		}
+	}
+}
+
+func (admin *Admin) CheckWritable(w http.ResponseWriter) bool {
+	if !admin.Controller.Config.ReadOnly {
+		return true
+	}
+
+	w.WriteHeader(http.StatusForbidden)
+	w.Write([]byte("Server is in read-only mode\n"))
+
+	return false
+}
+
+func (admin *Admin) CheckRevision(w http.ResponseWriter, r *http.Request, m map[string]interface{}) bool {
+	revision, ok := admin.GetRequestRevision(r, m)
+	if !ok {
+		w.WriteHeader(http.StatusPreconditionRequired)
+		w.Write([]byte("Missing revision\n"))
+		return false
+	}
+
+	current := admin.GetRevision()
+
+	if revision == current {
+		return true
+	}
+
+	submitted := map[string]interface{}{}
+	for _, section := range AdminConfigSections {
+		if v, ok := m[section]; ok {
+			submitted[section] = v
+		}
+	}
+
+	if b, err := json.Marshal(map[string]interface{}{
+		"diff":     admin.GetConfigDiff(submitted),
+		"error":    "configuration was changed by someone else",
+		"revision": current,
+	}); err == nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, current))
+		w.WriteHeader(http.StatusConflict)
+		w.Write(b)
+	} else {
+		w.WriteHeader(http.StatusConflict)
+	}
+
+	return false
+}
+
+func (admin *Admin) GetConfigDiff(submitted map[string]interface{}) map[string]interface{} {
+	var current map[string]interface{}
+
+	diff := map[string]interface{}{}
+
+	if b, err := json.Marshal(admin.GetConfig()); err != nil {
+		return diff
+	} else if err = json.Unmarshal(b, &current); err != nil {
+		return diff
+	}
+
+	for section, s := range submitted {
+		switch c := current[section].(type) {
+		case map[string]interface{}:
+			s, ok := s.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			changed := map[string]interface{}{}
+			for k, v := range c {
+				if !reflect.DeepEqual(v, s[k]) {
+					changed[k] = v
+				}
+			}
+
+			if len(changed) > 0 {
+				diff[section] = map[string]interface{}{"changed": changed}
+			}
+
+		case []interface{}:
+			s, ok := s.([]interface{})
+			if !ok {
+				continue
+			}
+
+			index := func(l []interface{}) map[string]interface{} {
+				m := map[string]interface{}{}
+				for _, e := range l {
+					if e, ok := e.(map[string]interface{}); ok && e["_id"] != nil {
+						m[fmt.Sprint(e["_id"])] = e
+					}
+				}
+				return m
+			}
+
+			ci := index(c)
+			si := index(s)
+
+			added := []interface{}{}
+			changed := []interface{}{}
+			removed := []string{}
+
+			for id, v := range ci {
+				if sv, ok := si[id]; !ok {
+					added = append(added, v)
+				} else if !reflect.DeepEqual(v, sv) {
+					changed = append(changed, v)
+				}
+			}
+
+			for id := range si {
+				if _, ok := ci[id]; !ok {
+					removed = append(removed, id)
+				}
+			}
+
+			if len(added)+len(changed)+len(removed) > 0 {
+				diff[section] = map[string]interface{}{
+					"added":   added,
+					"changed": changed,
+					"removed": removed,
+				}
+			}
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,242 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Room struct {
+	Id        string
+	Name      string
+	Leader    *Client
+	Members   []*Client
+	Livefeed  interface{}
+	Call      interface{}
+	Paused    bool
+	Position  float64
+	UpdatedAt time.Time
+}
+
+func (room *Room) GetState(client *Client) map[string]interface{} {
+	position := room.Position
+	if !room.Paused && room.Call != nil {
+		position += time.Since(room.UpdatedAt).Seconds()
+	}
+
+	role := "follower"
+	if client == room.Leader {
+		role = "leader"
+	}
+
+	return map[string]interface{}{
+		"call":     room.Call,
+		"id":       room.Id,
+		"livefeed": room.Livefeed,
+		"members":  len(room.Members),
+		"name":     room.Name,
+		"paused":   room.Paused,
+		"position": position,
+		"role":     role,
+	}
+}
+
+type Rooms struct {
+	Map   map[string]*Room
+	mutex sync.Mutex
+}
+
+func NewRooms() *Rooms {
+	return &Rooms{
+		Map:   map[string]*Room{},
+		mutex: sync.Mutex{},
+	}
+}
+
+func (rooms *Rooms) Create(client *Client, name string) (*Room, error) {
+	rooms.Leave(client)
+
+	rooms.mutex.Lock()
+	defer rooms.mutex.Unlock()
+
+	u, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	room := &Room{
+		Id:        strings.ToUpper(u.String()[:8]),
+		Name:      name,
+		Leader:    client,
+		Members:   []*Client{client},
+		UpdatedAt: time.Now(),
+	}
+
+	rooms.Map[room.Id] = room
+
+	client.room = room.Id
+
+	return room, nil
+}
+
+func (rooms *Rooms) Emit(room *Room) {
+	rooms.mutex.Lock()
+	members := append([]*Client{}, room.Members...)
+	rooms.mutex.Unlock()
+
+	for _, member := range members {
+		rooms.send(room, member)
+	}
+}
+
+func (rooms *Rooms) GetRoom(client *Client) (*Room, bool) {
+	rooms.mutex.Lock()
+	defer rooms.mutex.Unlock()
+
+	room, ok := rooms.Map[client.room]
+
+	return room, ok
+}
+
+func (rooms *Rooms) Join(client *Client, id string) (*Room, error) {
+	id = strings.ToUpper(strings.TrimSpace(id))
+
+	if client.room == id {
+		if room, ok := rooms.GetRoom(client); ok {
+			return room, nil
+		}
+	}
+
+	rooms.Leave(client)
+
+	rooms.mutex.Lock()
+	defer rooms.mutex.Unlock()
+
+	room, ok := rooms.Map[id]
+	if !ok {
+		return nil, errors.New("no such room")
+	}
+
+	room.Members = append(room.Members, client)
+
+	client.room = room.Id
+
+	if room.Livefeed != nil {
+		client.Livefeed.FromMap(room.Livefeed)
+	}
+
+	return room, nil
+}
+
+func (rooms *Rooms) Leave(client *Client) {
+	if room := rooms.leave(client); room != nil {
+		go rooms.Emit(room)
+	}
+}
+
+func (rooms *Rooms) Sync(client *Client, state map[string]interface{}) (*Room, error) {
+	rooms.mutex.Lock()
+	defer rooms.mutex.Unlock()
+
+	room, ok := rooms.Map[client.room]
+	if !ok {
+		return nil, errors.New("not in a room")
+	}
+
+	if room.Leader != client {
+		return nil, errors.New("only the room leader can control playback")
+	}
+
+	if v, ok := state["call"]; ok {
+		switch v := v.(type) {
+		case float64:
+			room.Call = uint(v)
+		default:
+			room.Call = nil
+		}
+	}
+
+	if v, ok := state["livefeed"]; ok {
+		room.Livefeed = v
+		for _, member := range room.Members {
+			if member != client {
+				member.Livefeed.FromMap(v)
+			}
+		}
+	}
+
+	switch v := state["paused"].(type) {
+	case bool:
+		room.Paused = v
+	}
+
+	switch v := state["position"].(type) {
+	case float64:
+		room.Position = v
+	}
+
+	room.UpdatedAt = time.Now()
+
+	return room, nil
+}
+
+func (rooms *Rooms) leave(client *Client) *Room {
+	rooms.mutex.Lock()
+	defer rooms.mutex.Unlock()
+
+	room, ok := rooms.Map[client.room]
+
+	client.room = ""
+
+	if !ok {
+		return nil
+	}
+
+	for i, member := range room.Members {
+		if member == client {
+			room.Members = append(room.Members[:i], room.Members[i+1:]...)
+			break
+		}
+	}
+
+	if len(room.Members) == 0 {
+		delete(rooms.Map, room.Id)
+		return nil
+	}
+
+	if room.Leader == client {
+		room.Leader = room.Members[0]
+	}
+
+	return room
+}
+
+func (rooms *Rooms) send(room *Room, client *Client) {
+	defer func() {
+		recover()
+	}()
+
+	rooms.mutex.Lock()
+	state := room.GetState(client)
+	rooms.mutex.Unlock()
+
+	client.Send <- &Message{Command: MessageCommandRoom, Payload: state}
+}
@@ -0,0 +1,515 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/google/uuid"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const (
+	SamlClockSkew      = 3 * time.Minute
+	SamlRequestTimeout = 10 * time.Minute
+)
+
+type Saml struct {
+	Controller *Controller
+	assertions map[string]time.Time
+	requests   map[string]time.Time
+	mutex      sync.Mutex
+}
+
+func NewSaml(controller *Controller) *Saml {
+	return &Saml{
+		Controller: controller,
+		assertions: map[string]time.Time{},
+		requests:   map[string]time.Time{},
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (saml *Saml) GetAcsUrl(r *http.Request) string {
+	return fmt.Sprintf("%s/api/admin/saml/acs", saml.getBaseUrl(r))
+}
+
+func (saml *Saml) GetCertificate() (*x509.Certificate, error) {
+	s := strings.TrimSpace(saml.Controller.Options.SamlIdpCertificate)
+
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		return x509.ParseCertificate(block.Bytes)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return nil, err
+	}
+
+	return x509.ParseCertificate(b)
+}
+
+func (saml *Saml) GetEntityId(r *http.Request) string {
+	if len(saml.Controller.Options.SamlEntityId) > 0 {
+		return saml.Controller.Options.SamlEntityId
+	}
+
+	return fmt.Sprintf("%s/api/admin/saml/metadata", saml.getBaseUrl(r))
+}
+
+func (saml *Saml) GetLoginUrl(r *http.Request) (string, error) {
+	var b bytes.Buffer
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	requestId := fmt.Sprintf("_%s", id.String())
+
+	request := etree.NewDocument()
+
+	authn := request.CreateElement("samlp:AuthnRequest")
+	authn.CreateAttr("xmlns:samlp", "urn:oasis:names:tc:SAML:2.0:protocol")
+	authn.CreateAttr("xmlns:saml", "urn:oasis:names:tc:SAML:2.0:assertion")
+	authn.CreateAttr("ID", requestId)
+	authn.CreateAttr("Version", "2.0")
+	authn.CreateAttr("IssueInstant", time.Now().UTC().Format(time.RFC3339))
+	authn.CreateAttr("Destination", saml.Controller.Options.SamlIdpUrl)
+	authn.CreateAttr("AssertionConsumerServiceURL", saml.GetAcsUrl(r))
+	authn.CreateAttr("ProtocolBinding", "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST")
+	authn.CreateElement("saml:Issuer").SetText(saml.GetEntityId(r))
+
+	policy := authn.CreateElement("samlp:NameIDPolicy")
+	policy.CreateAttr("AllowCreate", "true")
+	policy.CreateAttr("Format", "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified")
+
+	s, err := request.WriteToString()
+	if err != nil {
+		return "", err
+	}
+
+	w, err := flate.NewWriter(&b, flate.DefaultCompression)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = w.Write([]byte(s)); err != nil {
+		return "", err
+	}
+
+	if err = w.Close(); err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(saml.Controller.Options.SamlIdpUrl)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("SAMLRequest", base64.StdEncoding.EncodeToString(b.Bytes()))
+	u.RawQuery = q.Encode()
+
+	saml.mutex.Lock()
+	for k, t := range saml.requests {
+		if time.Since(t) > SamlRequestTimeout {
+			delete(saml.requests, k)
+		}
+	}
+	saml.requests[requestId] = time.Now()
+	saml.mutex.Unlock()
+
+	return u.String(), nil
+}
+
+func (saml *Saml) GetMetadata(r *http.Request) (string, error) {
+	metadata := etree.NewDocument()
+	metadata.CreateProcInst("xml", `version="1.0" encoding="UTF-8"`)
+
+	descriptor := metadata.CreateElement("md:EntityDescriptor")
+	descriptor.CreateAttr("xmlns:md", "urn:oasis:names:tc:SAML:2.0:metadata")
+	descriptor.CreateAttr("entityID", saml.GetEntityId(r))
+
+	sp := descriptor.CreateElement("md:SPSSODescriptor")
+	sp.CreateAttr("AuthnRequestsSigned", "false")
+	sp.CreateAttr("WantAssertionsSigned", "true")
+	sp.CreateAttr("protocolSupportEnumeration", "urn:oasis:names:tc:SAML:2.0:protocol")
+
+	sp.CreateElement("md:NameIDFormat").SetText("urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified")
+
+	acs := sp.CreateElement("md:AssertionConsumerService")
+	acs.CreateAttr("Binding", "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST")
+	acs.CreateAttr("Location", saml.GetAcsUrl(r))
+	acs.CreateAttr("index", "1")
+
+	metadata.Indent(2)
+
+	return metadata.WriteToString()
+}
+
+func (saml *Saml) IsEnabled() bool {
+	options := saml.Controller.Options
+
+	if len(strings.TrimSpace(options.SamlAllowedGroups)) == 0 && len(strings.TrimSpace(options.SamlAllowedUsers)) == 0 {
+		return false
+	}
+
+	return len(options.SamlIdpUrl) > 0 && len(options.SamlIdpCertificate) > 0
+}
+
+func (saml *Saml) ParseResponse(r *http.Request, encoded string) (string, string, error) {
+	var (
+		assertion *etree.Element
+		err       error
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("saml.parseresponse: %v", err)
+	}
+
+	b, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", "", formatError(err)
+	}
+
+	cert, err := saml.GetCertificate()
+	if err != nil {
+		return "", "", formatError(fmt.Errorf("invalid idp certificate, %v", err))
+	}
+
+	doc := etree.NewDocument()
+	if err = doc.ReadFromBytes(b); err != nil {
+		return "", "", formatError(err)
+	}
+
+	response := doc.Root()
+	if response == nil || response.Tag != "Response" {
+		return "", "", formatError(errors.New("not a saml response"))
+	}
+
+	if destination := response.SelectAttrValue("Destination", ""); len(destination) > 0 && destination != saml.GetAcsUrl(r) {
+		return "", "", formatError(fmt.Errorf("invalid destination %s", destination))
+	}
+
+	if status := samlChild(samlChild(response, "Status"), "StatusCode"); status == nil || status.SelectAttrValue("Value", "") != "urn:oasis:names:tc:SAML:2.0:status:Success" {
+		return "", "", formatError(errors.New("authentication failed at identity provider"))
+	}
+
+	ctx := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{
+		Roots: []*x509.Certificate{cert},
+	})
+
+	if samlChild(response, "Signature") != nil {
+		if response, err = ctx.Validate(response); err != nil {
+			return "", "", formatError(err)
+		}
+		assertion = samlChild(response, "Assertion")
+
+	} else if assertion = samlChild(response, "Assertion"); assertion != nil {
+		if assertion, err = ctx.Validate(assertion); err != nil {
+			return "", "", formatError(err)
+		}
+	}
+
+	if assertion == nil {
+		return "", "", formatError(errors.New("no signed assertion found"))
+	}
+
+	now := time.Now()
+
+	expires := time.Time{}
+
+	if conditions := samlChild(assertion, "Conditions"); conditions != nil {
+		if t, err := time.Parse(time.RFC3339, conditions.SelectAttrValue("NotBefore", "")); err == nil && now.Add(SamlClockSkew).Before(t) {
+			return "", "", formatError(errors.New("assertion not yet valid"))
+		}
+
+		if t, err := time.Parse(time.RFC3339, conditions.SelectAttrValue("NotOnOrAfter", "")); err == nil {
+			if !now.Add(-SamlClockSkew).Before(t) {
+				return "", "", formatError(errors.New("assertion expired"))
+			}
+			expires = t
+		}
+
+		if restriction := samlChild(conditions, "AudienceRestriction"); restriction != nil {
+			valid := false
+			for _, audience := range restriction.ChildElements() {
+				if audience.Tag == "Audience" && strings.TrimSpace(audience.Text()) == saml.GetEntityId(r) {
+					valid = true
+				}
+			}
+			if !valid {
+				return "", "", formatError(errors.New("invalid audience"))
+			}
+		}
+	}
+
+	subject := samlChild(assertion, "Subject")
+
+	data := samlChild(samlChild(subject, "SubjectConfirmation"), "SubjectConfirmationData")
+	if data == nil {
+		return "", "", formatError(errors.New("no subject confirmation"))
+	}
+
+	t, err := time.Parse(time.RFC3339, data.SelectAttrValue("NotOnOrAfter", ""))
+	if err != nil {
+		return "", "", formatError(errors.New("no subject confirmation expiry"))
+	} else if !now.Add(-SamlClockSkew).Before(t) {
+		return "", "", formatError(errors.New("subject confirmation expired"))
+	}
+
+	if expires.IsZero() || t.Before(expires) {
+		expires = t
+	}
+
+	requestId := data.SelectAttrValue("InResponseTo", "")
+	if len(requestId) == 0 {
+		return "", "", formatError(errors.New("unsolicited response"))
+	}
+
+	if id := response.SelectAttrValue("InResponseTo", ""); len(id) > 0 && id != requestId {
+		return "", "", formatError(fmt.Errorf("mismatched request %s", id))
+	}
+
+	assertionId := assertion.SelectAttrValue("ID", "")
+	if len(assertionId) == 0 {
+		return "", "", formatError(errors.New("no assertion id"))
+	}
+
+	saml.mutex.Lock()
+	for k, t := range saml.assertions {
+		if now.After(t) {
+			delete(saml.assertions, k)
+		}
+	}
+	_, replayed := saml.assertions[assertionId]
+	_, requested := saml.requests[requestId]
+	if !replayed && requested {
+		saml.assertions[assertionId] = expires.Add(SamlClockSkew)
+		delete(saml.requests, requestId)
+	}
+	saml.mutex.Unlock()
+
+	if replayed {
+		return "", "", formatError(fmt.Errorf("replayed assertion %s", assertionId))
+	}
+
+	if !requested {
+		return "", "", formatError(fmt.Errorf("unknown request %s", requestId))
+	}
+
+	nameId := samlChild(subject, "NameID")
+	if nameId == nil || len(strings.TrimSpace(nameId.Text())) == 0 {
+		return "", "", formatError(errors.New("no name id"))
+	}
+
+	user := strings.TrimSpace(nameId.Text())
+
+	groups := saml.getGroups(assertion)
+
+	if !saml.isAllowed(user, groups) {
+		return "", "", formatError(fmt.Errorf("user %s not allowed", user))
+	}
+
+	return user, saml.getRole(groups), nil
+}
+
+func (saml *Saml) getBaseUrl(r *http.Request) string {
+	if len(saml.Controller.Options.PublicUrl) > 0 {
+		return strings.TrimSuffix(saml.Controller.Options.PublicUrl, "/")
+	}
+
+	return fmt.Sprintf("%s://%s%s", GetRequestScheme(r), r.Host, saml.Controller.Config.GetBasePath())
+}
+
+func (saml *Saml) getGroups(assertion *etree.Element) []string {
+	groups := []string{}
+
+	name := strings.TrimSpace(saml.Controller.Options.SamlGroupsAttribute)
+	if len(name) == 0 {
+		return groups
+	}
+
+	for _, statement := range assertion.ChildElements() {
+		if statement.Tag != "AttributeStatement" {
+			continue
+		}
+
+		for _, attribute := range statement.ChildElements() {
+			if attribute.Tag != "Attribute" || (attribute.SelectAttrValue("Name", "") != name && attribute.SelectAttrValue("FriendlyName", "") != name) {
+				continue
+			}
+
+			for _, value := range attribute.ChildElements() {
+				if s := strings.TrimSpace(value.Text()); value.Tag == "AttributeValue" && len(s) > 0 {
+					groups = append(groups, s)
+				}
+			}
+		}
+	}
+
+	return groups
+}
+
+func (saml *Saml) getRole(groups []string) string {
+	role := saml.Controller.Options.SsoRole
+	if getAdminRoleRank(role) == 0 {
+		role = AdminRoleViewer
+	}
+
+	mapped := ""
+	for _, s := range strings.Split(saml.Controller.Options.SamlGroupRoles, ",") {
+		group, groupRole := s, ""
+		if i := strings.LastIndex(s, "="); i >= 0 {
+			group, groupRole = strings.TrimSpace(s[:i]), strings.TrimSpace(strings.ToLower(s[i+1:]))
+		}
+
+		if len(group) == 0 || getAdminRoleRank(groupRole) <= getAdminRoleRank(mapped) {
+			continue
+		}
+
+		for _, g := range groups {
+			if g == group {
+				mapped = groupRole
+				break
+			}
+		}
+	}
+
+	if len(mapped) > 0 {
+		return mapped
+	}
+
+	return role
+}
+
+func (saml *Saml) isAllowed(user string, groups []string) bool {
+	for _, s := range strings.Split(saml.Controller.Options.SamlAllowedUsers, ",") {
+		if s = strings.TrimSpace(s); len(s) > 0 && strings.EqualFold(s, user) {
+			return true
+		}
+	}
+
+	for _, s := range strings.Split(saml.Controller.Options.SamlAllowedGroups, ",") {
+		if s = strings.TrimSpace(s); len(s) == 0 {
+			continue
+		}
+
+		for _, group := range groups {
+			if group == s {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func samlChild(el *etree.Element, tag string) *etree.Element {
+	if el == nil {
+		return nil
+	}
+
+	for _, child := range el.ChildElements() {
+		if child.Tag == tag {
+			return child
+		}
+	}
+
+	return nil
+}
+
+func (admin *Admin) SamlHandler(w http.ResponseWriter, r *http.Request) {
+	saml := admin.Controller.Saml
+
+	switch strings.TrimPrefix(r.URL.Path, "/api/admin/saml/") {
+	case "":
+		if b, err := json.Marshal(map[string]interface{}{"enabled": saml.IsEnabled()}); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	case "acs":
+		if !saml.IsEnabled() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		user, role, err := saml.ParseResponse(r, r.PostFormValue("SAMLResponse"))
+		if err != nil {
+			admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("%s ip=%v", err.Error(), GetRemoteAddr(r)))
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte("Single sign-on failed\n"))
+			return
+		}
+
+		token, refresh, err := admin.issueToken(GetSsoSubject(user, role))
+		if err != nil {
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		admin.Controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("admin single sign-on user=\"%s\" role=%s ip=%v", user, role, GetRemoteAddr(r)))
+
+		w.Header().Set("Location", fmt.Sprintf("../../../admin#token=%s&refresh=%s", url.QueryEscape(token), url.QueryEscape(refresh)))
+		w.WriteHeader(http.StatusSeeOther)
+
+	case "login":
+		if !saml.IsEnabled() {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if u, err := saml.GetLoginUrl(r); err == nil {
+			http.Redirect(w, r, u, http.StatusFound)
+		} else {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.samlhandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	case "metadata":
+		if s, err := saml.GetMetadata(r); err == nil {
+			w.Header().Set("Content-Type", "application/samlmetadata+xml")
+			w.Write([]byte(s))
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
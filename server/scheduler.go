@@ -37,27 +37,100 @@ func NewScheduler(controller *Controller) *Scheduler {
 	}
 }
 
+func (scheduler *Scheduler) checkForUpdates() error {
+	updater := scheduler.Controller.Updater
+
+	if !scheduler.Controller.Options.CheckForUpdates || !updater.IsDue() {
+		return nil
+	}
+
+	if err := updater.Check(); err != nil {
+		return err
+	}
+
+	if updater.IsUpdateAvailable() {
+		latest := fmt.Sprintf("%v", updater.ToMap()["latest"])
+
+		scheduler.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("new version available, %s", latest))
+
+		if !scheduler.Controller.Config.ReadOnly {
+			if err := scheduler.Controller.Notifications.NotifyOnce(NotificationKindUpdate, latest, fmt.Sprintf("new version %s available, currently running %s", latest, Version)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (scheduler *Scheduler) isLeader(job string) (bool, error) {
+	return scheduler.Controller.Leases.Acquire(scheduler.Controller.Database, fmt.Sprintf("scheduler.%s", job), LeaseTimeout)
+}
+
 func (scheduler *Scheduler) pruneDatabase() error {
-	if scheduler.Controller.Options.PruneDays == 0 {
+	if scheduler.Controller.Options.PruneDays == 0 && len(scheduler.Controller.Retentions.List) == 0 {
 		return nil
 	}
 
+	if ok, err := scheduler.isLeader("pruneDatabase"); !ok {
+		return err
+	}
+
+	for more := true; more; {
+		var err error
+		if more, err = scheduler.Controller.CallStats.AggregatePending(scheduler.Controller.Database); err != nil {
+			return err
+		}
+	}
+
+	if err := scheduler.Controller.Statistics.SnapshotPending(scheduler.Controller.Database); err != nil {
+		return err
+	}
+
 	scheduler.Controller.IngestLock()
 	defer scheduler.Controller.IngestUnlock()
 
 	scheduler.Controller.Logs.LogEvent(LogLevelInfo, "database pruning")
 
-	if err := scheduler.Controller.Calls.Prune(scheduler.Controller.Database, scheduler.Controller.Options.PruneDays); err != nil {
+	retentions := scheduler.Controller.Retentions.GetPruneGroups(scheduler.Controller.Systems, scheduler.Controller.Options.PruneDays)
+
+	if err := scheduler.Controller.Calls.Prune(scheduler.Controller.Database, scheduler.Controller.Options.PruneDays, retentions); err != nil {
 		return err
 	}
 
-	if err := scheduler.Controller.Logs.Prune(scheduler.Controller.Database, scheduler.Controller.Options.PruneDays); err != nil {
+	if err := scheduler.Controller.Bookmarks.Prune(scheduler.Controller.Database); err != nil {
 		return err
 	}
 
+	if scheduler.Controller.Options.PruneDays > 0 {
+		if err := scheduler.Controller.ListenerStats.Prune(scheduler.Controller.Database, scheduler.Controller.Options.PruneDays); err != nil {
+			return err
+		}
+
+		if err := scheduler.Controller.Logs.Prune(scheduler.Controller.Database, scheduler.Controller.Options.PruneDays); err != nil {
+			return err
+		}
+
+		if err := scheduler.Controller.Notifications.Prune(scheduler.Controller.Database, scheduler.Controller.Options.PruneDays); err != nil {
+			return err
+		}
+
+		if err := scheduler.Controller.Patches.Prune(scheduler.Controller.Database, scheduler.Controller.Options.PruneDays); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+func (scheduler *Scheduler) snapshotStatistics() error {
+	if ok, err := scheduler.isLeader("snapshotStatistics"); !ok {
+		return err
+	}
+
+	return scheduler.Controller.Statistics.SnapshotPending(scheduler.Controller.Database)
+}
+
 func (scheduler *Scheduler) run() {
 	scheduler.mutex.Lock()
 	defer scheduler.mutex.Unlock()
@@ -66,9 +139,43 @@ func (scheduler *Scheduler) run() {
 		scheduler.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("scheduler.run: %s", err.Error()))
 	}
 
-	if err := scheduler.pruneDatabase(); err != nil {
+	if !scheduler.Controller.Config.ReadOnly {
+		if err := scheduler.snapshotStatistics(); err != nil {
+			logError(err)
+		}
+
+		if err := scheduler.pruneDatabase(); err != nil {
+			logError(err)
+		}
+	}
+
+	if err := scheduler.checkForUpdates(); err != nil {
 		logError(err)
 	}
+
+	scheduler.scheduledRestart()
+}
+
+func (scheduler *Scheduler) scheduledRestart() {
+	if scheduler.Controller.Config.HasConfigFileChanged() {
+		scheduler.Controller.RequestRestart("configuration file changed")
+	}
+
+	if !scheduler.Controller.Options.ScheduledRestart {
+		return
+	}
+
+	if uint(time.Now().Hour()) != scheduler.Controller.Options.ScheduledRestartHour {
+		return
+	}
+
+	if len(scheduler.Controller.GetPendingRestart()) == 0 {
+		return
+	}
+
+	scheduler.Controller.IngestLock()
+
+	scheduler.Controller.Restart()
 }
 
 func (scheduler *Scheduler) Start() error {
@@ -0,0 +1,494 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+type DatabaseTable struct {
+	Name    string
+	Columns []string
+}
+
+var DatabaseSchema = []DatabaseTable{
+	{"rdioScannerAccesses", []string{
+		"`_id` integer primary key autoincrement",
+		"`archive` varchar(16)",
+		"`archiveHours` integer",
+		"`code` varchar(255) not null unique",
+		"`deny` text",
+		"`expiration` datetime",
+		"`hours` integer not null default 0",
+		"`ident` varchar(255)",
+		"`limit` integer",
+		"`listened` bigint not null default 0",
+		"`order` integer",
+		"`schedule` text",
+		"`streamOnly` tinyint(1) default 0",
+		"`systems` text not null",
+		"`watermark` varchar(16)",
+	}},
+	{"rdioScannerAdminSessions", []string{
+		"`session` varchar(36) not null primary key",
+		"`subject` varchar(255) not null",
+		"`refresh` varchar(64) not null",
+		"`expires` bigint not null",
+	}},
+	{"rdioScannerAdminUsers", []string{
+		"`_id` integer primary key autoincrement",
+		"`disabled` tinyint(1) default 0",
+		"`password` varchar(255) not null",
+		"`role` varchar(255) not null",
+		"`username` varchar(255) not null unique",
+	}},
+	{"rdioScannerAlerts", []string{
+		"`_id` integer primary key autoincrement",
+		"`anomalies` tinyint(1) default 0",
+		"`burstCount` integer default 0",
+		"`burstWindow` integer default 0",
+		"`cooldown` integer default 0",
+		"`delivery` varchar(255) not null",
+		"`disabled` tinyint(1) default 0",
+		"`keywords` text not null",
+		"`label` varchar(255) not null",
+		"`order` integer",
+		"`systems` text not null",
+		"`target` text not null",
+		"`units` text not null",
+	}},
+	{"rdioScannerApiKeys", []string{
+		"`_id` integer primary key autoincrement",
+		"`disabled` tinyint(1) default 0",
+		"`ident` varchar(255)",
+		"`key` varchar(255) not null unique",
+		"`order` integer",
+		"`scopes` varchar(255)",
+		"`systems` text not null",
+	}},
+	{"rdioScannerAuditLog", []string{
+		"`_id` integer primary key autoincrement",
+		"`action` varchar(64) not null",
+		"`address` varchar(255) not null",
+		"`changes` text",
+		"`dateTime` datetime not null",
+		"`endpoint` varchar(255) not null",
+		"`method` varchar(16) not null",
+		"`user` varchar(255) not null",
+	}},
+	{"rdioScannerBookmarks", []string{
+		"`_id` integer primary key autoincrement",
+		"`callId` integer not null",
+		"`dateTime` datetime not null",
+		"`note` text not null",
+		"`owner` varchar(255) not null",
+		"`shared` tinyint(1) default 0",
+		"`updated` datetime not null",
+	}},
+	{"rdioScannerCallAlternates", []string{
+		"`id` integer primary key autoincrement",
+		"`callId` integer not null",
+		"`audio` longblob not null",
+		"`audioName` varchar(255)",
+		"`audioType` varchar(255)",
+		"`dateTime` datetime not null",
+		"`frequencies` text not null",
+		"`score` real not null",
+		"`site` varchar(255)",
+	}},
+	{"rdioScannerCallStats", []string{
+		"`_id` integer primary key autoincrement",
+		"`hour` bigint not null",
+		"`system` integer not null",
+		"`talkgroup` integer not null",
+		"`calls` integer not null",
+		"`airtime` bigint not null",
+	}},
+	{"rdioScannerCalls", []string{
+		"`id` integer primary key autoincrement",
+		"`audio` longblob not null",
+		"`audioFingerprint` text",
+		"`audioHash` varchar(64)",
+		"`audioKey` varchar(255)",
+		"`audioName` varchar(255)",
+		"`audioType` varchar(255)",
+		"`conversation` integer",
+		"`dateTime` datetime not null",
+		"`duration` integer",
+		"`frequencies` text not null",
+		"`frequency` integer",
+		"`loudness` text",
+		"`patches` text not null",
+		"`source` integer",
+		"`sources` text not null",
+		"`system` integer not null",
+		"`talkgroup` integer not null",
+		"`transcript` text",
+		"`transcriptConfidence` real",
+	}},
+	{"rdioScannerConfigs", []string{
+		"`_id` integer primary key autoincrement",
+		"`key` varchar(255) not null unique",
+		"`val` text not null",
+	}},
+	{"rdioScannerDirWatches", []string{
+		"`_id` integer primary key autoincrement",
+		"`archiveDir` varchar(255)",
+		"`delay` integer default 0",
+		"`deleteAfter` tinyint(1) default 0",
+		"`directory` varchar(255) not null unique",
+		"`disabled` tinyint(1) default 0",
+		"`extension` varchar(255)",
+		"`frequency` integer",
+		"`mask` varchar(255)",
+		"`order` integer",
+		"`systemId` integer",
+		"`talkgroupId` integer",
+		"`type` varchar(255)",
+		"`usePolling` tinyint(1) default 0",
+		"`password` varchar(255)",
+		"`pollInterval` integer",
+		"`username` varchar(255)",
+	}},
+	{"rdioScannerDownstreams", []string{
+		"`_id` integer primary key autoincrement",
+		"`apiKey` varchar(255) not null",
+		"`disabled` tinyint(1) default 0",
+		"`order` integer",
+		"`systems` text not null",
+		"`url` varchar(255) not null",
+	}},
+	{"rdioScannerFrequencies", []string{
+		"`_id` integer primary key autoincrement",
+		"`date` varchar(10) not null",
+		"`system` integer not null",
+		"`frequency` integer not null",
+		"`calls` integer not null",
+		"`errors` integer not null",
+		"`spikes` integer not null",
+		"`controlSamples` integer not null",
+		"`decodeRate` real not null",
+	}},
+	{"rdioScannerGroups", []string{
+		"`_id` integer primary key autoincrement",
+		"`label` varchar(255) not null",
+	}},
+	{"rdioScannerIncidentCalls", []string{
+		"`_id` integer primary key autoincrement",
+		"`callId` integer not null",
+		"`incidentId` integer not null",
+	}},
+	{"rdioScannerIncidentNotes", []string{
+		"`_id` integer primary key autoincrement",
+		"`author` varchar(255) not null",
+		"`dateTime` datetime not null",
+		"`incidentId` integer not null",
+		"`note` text not null",
+	}},
+	{"rdioScannerIncidents", []string{
+		"`_id` integer primary key autoincrement",
+		"`createdBy` varchar(255) not null",
+		"`dateTime` datetime not null",
+		"`description` text not null",
+		"`status` varchar(255) not null",
+		"`title` varchar(255) not null",
+		"`updated` datetime not null",
+	}},
+	{"rdioScannerLeases", []string{
+		"`name` varchar(255) not null primary key",
+		"`holder` varchar(255) not null",
+		"`expires` bigint not null",
+	}},
+	{"rdioScannerListenerStats", []string{
+		"`_id` integer primary key autoincrement",
+		"`date` varchar(10) not null",
+		"`listener` varchar(16) not null",
+		"`system` integer not null",
+		"`talkgroup` integer not null",
+	}},
+	{"rdioScannerLogs", []string{
+		"`_id` integer primary key autoincrement",
+		"`dateTime` datetime not null",
+		"`level` varchar(255) not null",
+		"`message` varchar(255) not null",
+	}},
+	{"rdioScannerNotifications", []string{
+		"`_id` integer primary key autoincrement",
+		"`count` integer not null default 1",
+		"`dateTime` datetime not null",
+		"`key` varchar(255) not null",
+		"`kind` varchar(255) not null",
+		"`message` text not null",
+		"`read` tinyint(1) not null default 0",
+		"`updated` datetime not null",
+	}},
+	{"rdioScannerPatches", []string{
+		"`_id` integer primary key autoincrement",
+		"`dateTime` datetime not null",
+		"`patched` integer not null",
+		"`system` integer not null",
+		"`talkgroup` integer not null",
+		"`updated` datetime not null",
+	}},
+	{"rdioScannerRetentions", []string{
+		"`_id` integer primary key autoincrement",
+		"`days` integer not null default 0",
+		"`label` varchar(255) not null",
+		"`order` integer",
+		"`system` integer not null default 0",
+		"`tagId` integer not null default 0",
+		"`talkgroup` integer not null default 0",
+	}},
+	{"rdioScannerSdrs", []string{
+		"`_id` integer primary key autoincrement",
+		"`command` text not null",
+		"`disabled` tinyint(1) default 0",
+		"`frequency` integer",
+		"`hang` integer default 0",
+		"`maxDuration` integer default 0",
+		"`minDuration` integer default 0",
+		"`order` integer",
+		"`sampleRate` integer default 0",
+		"`squelch` integer default 0",
+		"`systemId` integer not null",
+		"`talkgroupId` integer not null",
+	}},
+	{"rdioScannerStatistics", []string{
+		"`_id` integer primary key autoincrement",
+		"`date` varchar(10) not null",
+		"`system` integer not null",
+		"`talkgroup` integer not null",
+		"`calls` integer not null",
+		"`airtime` integer not null",
+		"`audioBytes` bigint not null",
+		"`units` integer not null",
+		"`listeners` integer not null default 0",
+	}},
+	{"rdioScannerSystems", []string{
+		"`_id` integer primary key autoincrement",
+		"`audioBitrate` integer not null default 0",
+		"`audioChannels` integer not null default 0",
+		"`audioCodec` varchar(255)",
+		"`audioConversion` varchar(255)",
+		"`audioSampleRate` integer not null default 0",
+		"`autoPopulate` tinyint(1) default 0",
+		"`blacklists` text not null",
+		"`duplicatePrimary` varchar(255)",
+		"`duplicateStrategy` varchar(255)",
+		"`id` integer not null unique",
+		"`label` varchar(255) not null",
+		"`led` varchar(255)",
+		"`order` integer",
+		"`unitBlacklists` text",
+		"`broadcastifyApiKey` varchar(255)",
+		"`broadcastifySystemId` integer",
+		"`unitsImportConflict` varchar(16)",
+		"`unitsImportUrl` text",
+		"`streamOnly` tinyint(1) default 0",
+		"`radioReferenceConflict` varchar(16)",
+		"`radioReferenceSystemId` integer",
+	}},
+	{"rdioScannerTags", []string{
+		"`_id` integer primary key autoincrement",
+		"`label` varchar(255) not null",
+	}},
+	{"rdioScannerTalkgroups", []string{
+		"`_id` integer primary key autoincrement",
+		"`frequency` integer",
+		"`groupId` integer not null",
+		"`id` integer not null",
+		"`label` varchar(255) not null",
+		"`led` varchar(255)",
+		"`name` varchar(255) not null",
+		"`order` integer",
+		"`systemId` integer not null",
+		"`tagId` integer not null",
+		"`delay` integer default 0",
+		"`downstreamDelay` integer default 0",
+		"`unitBlacklists` text",
+		"`linkedSystem` integer",
+		"`linkedTalkgroup` integer",
+		"`broadcastify` tinyint(1) default 0",
+		"`broadcastifySlot` integer",
+	}},
+	{"rdioScannerUnitStats", []string{
+		"`_id` integer primary key autoincrement",
+		"`hour` bigint not null",
+		"`system` integer not null",
+		"`talkgroup` integer not null",
+		"`unit` integer not null",
+		"`calls` integer not null",
+	}},
+	{"rdioScannerUnits", []string{
+		"`_id` integer primary key autoincrement",
+		"`id` integer not null",
+		"`label` varchar(255) not null",
+		"`order` integer",
+		"`systemId` integer not null",
+	}},
+	{"rdioScannerWebhooks", []string{
+		"`_id` integer primary key autoincrement",
+		"`audioLink` tinyint(1) default 0",
+		"`disabled` tinyint(1) default 0",
+		"`headers` text not null",
+		"`order` integer",
+		"`systems` text not null",
+		"`template` text not null",
+		"`url` varchar(255) not null",
+	}},
+}
+
+type SchemaIssue struct {
+	Column  string `json:"column,omitempty"`
+	Message string `json:"message"`
+	Table   string `json:"table"`
+	column  string
+}
+
+func (db *Database) CheckSchema() []SchemaIssue {
+	issues := []SchemaIssue{}
+
+	for _, table := range DatabaseSchema {
+		columns, err := db.getColumns(table.Name)
+		if err != nil {
+			issues = append(issues, SchemaIssue{
+				Message: fmt.Sprintf("table %s is missing or unreadable: %v", table.Name, err),
+				Table:   table.Name,
+			})
+			continue
+		}
+
+		for _, column := range table.Columns {
+			name := getSchemaColumnName(column)
+
+			if !columns[strings.ToLower(name)] {
+				issues = append(issues, SchemaIssue{
+					Column:  name,
+					Message: fmt.Sprintf("column %s.%s is missing", table.Name, name),
+					Table:   table.Name,
+					column:  column,
+				})
+			}
+		}
+	}
+
+	return issues
+}
+
+func (db *Database) RepairSchema(issues []SchemaIssue) error {
+	formatError := func(err error, query string) error {
+		return fmt.Errorf("database.repairschema: %v while doing %s", err, query)
+	}
+
+	for _, issue := range issues {
+		var queries []string
+
+		if len(issue.Column) == 0 {
+			for _, table := range DatabaseSchema {
+				if table.Name == issue.Table {
+					queries = append(queries, db.getSchemaQuery(fmt.Sprintf("create table `%s` (%s)", table.Name, strings.Join(table.Columns, ", "))))
+				}
+			}
+
+		} else if strings.Contains(issue.column, " not null") && !strings.Contains(issue.column, " default ") {
+			zero := "''"
+			if regexp.MustCompile(`^\S+ (integer|bigint|real|tinyint)`).MatchString(issue.column) {
+				zero = "0"
+			}
+
+			queries = append(queries,
+				fmt.Sprintf("alter table `%s` add column %s", issue.Table, strings.Replace(issue.column, " not null", "", 1)),
+				fmt.Sprintf("update `%s` set `%s` = %s where `%s` is null", issue.Table, issue.Column, zero, issue.Column),
+			)
+
+		} else {
+			queries = append(queries, fmt.Sprintf("alter table `%s` add column %s", issue.Table, issue.column))
+		}
+
+		for _, query := range queries {
+			log.Printf("repairing %s", query)
+
+			if _, err := db.Sql.Exec(query); err != nil {
+				return formatError(err, query)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (db *Database) repair() {
+	if len(db.SchemaIssues) == 0 {
+		log.Println("database schema is complete, nothing to repair")
+		os.Exit(0)
+	}
+
+	if err := db.RepairSchema(db.SchemaIssues); err != nil {
+		log.Fatal(err)
+	}
+
+	if db.SchemaIssues = db.CheckSchema(); len(db.SchemaIssues) > 0 {
+		for _, issue := range db.SchemaIssues {
+			log.Printf("database schema drift: %s", issue.Message)
+		}
+		os.Exit(1)
+	}
+
+	log.Println("database schema repaired")
+	os.Exit(0)
+}
+
+func (db *Database) getColumns(table string) (map[string]bool, error) {
+	rows, err := db.Sql.Query(fmt.Sprintf("select * from `%s` limit 0", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := map[string]bool{}
+	for _, name := range names {
+		columns[strings.ToLower(name)] = true
+	}
+
+	return columns, nil
+}
+
+func (db *Database) getSchemaQuery(query string) string {
+	if db.Config.DbType == DbTypeSqlite {
+		return query
+	}
+	return strings.ReplaceAll(query, " autoincrement", " auto_increment")
+}
+
+func isSchemaDuplicateError(err error) bool {
+	return regexp.MustCompile(`(?i)duplicate column|duplicate key name|already exists`).MatchString(err.Error())
+}
+
+func getSchemaColumnName(column string) string {
+	if m := regexp.MustCompile("^`([^`]+)`").FindStringSubmatch(column); len(m) == 2 {
+		return m[1]
+	}
+	return column
+}
@@ -0,0 +1,610 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SdrDefaultHang        = 2000
+	SdrDefaultMaxDuration = 60
+	SdrDefaultMinDuration = 500
+	SdrDefaultSampleRate  = 16000
+	SdrDefaultSquelch     = 500
+
+	SdrFrameDuration  = 20 * time.Millisecond
+	SdrRestartDelay   = 5 * time.Second
+	SdrPrerollFrames  = 10
+	SdrReadBufferSize = 4096
+)
+
+type Sdr struct {
+	Id          interface{} `json:"_id"`
+	Command     string      `json:"command"`
+	Disabled    bool        `json:"disabled"`
+	Frequency   interface{} `json:"frequency"`
+	Hang        uint        `json:"hang"`
+	MaxDuration uint        `json:"maxDuration"`
+	MinDuration uint        `json:"minDuration"`
+	Order       interface{} `json:"order"`
+	SampleRate  uint        `json:"sampleRate"`
+	Squelch     uint        `json:"squelch"`
+	SystemId    uint        `json:"systemId"`
+	TalkgroupId uint        `json:"talkgroupId"`
+	controller  *Controller
+	cmd         *exec.Cmd
+	mutex       sync.Mutex
+	stop        chan struct{}
+}
+
+func (sdr *Sdr) FromMap(m map[string]interface{}) *Sdr {
+	switch v := m["_id"].(type) {
+	case float64:
+		sdr.Id = uint(v)
+	}
+
+	switch v := m["command"].(type) {
+	case string:
+		sdr.Command = v
+	}
+
+	switch v := m["disabled"].(type) {
+	case bool:
+		sdr.Disabled = v
+	}
+
+	switch v := m["frequency"].(type) {
+	case float64:
+		sdr.Frequency = uint(v)
+	}
+
+	switch v := m["hang"].(type) {
+	case float64:
+		sdr.Hang = uint(v)
+	}
+
+	switch v := m["maxDuration"].(type) {
+	case float64:
+		sdr.MaxDuration = uint(v)
+	}
+
+	switch v := m["minDuration"].(type) {
+	case float64:
+		sdr.MinDuration = uint(v)
+	}
+
+	switch v := m["order"].(type) {
+	case float64:
+		sdr.Order = uint(v)
+	}
+
+	switch v := m["sampleRate"].(type) {
+	case float64:
+		sdr.SampleRate = uint(v)
+	}
+
+	switch v := m["squelch"].(type) {
+	case float64:
+		sdr.Squelch = uint(v)
+	}
+
+	switch v := m["systemId"].(type) {
+	case float64:
+		sdr.SystemId = uint(v)
+	}
+
+	switch v := m["talkgroupId"].(type) {
+	case float64:
+		sdr.TalkgroupId = uint(v)
+	}
+
+	return sdr
+}
+
+func (sdr *Sdr) Start(controller *Controller) error {
+	if sdr.Disabled {
+		return nil
+	}
+
+	sdr.mutex.Lock()
+	defer sdr.mutex.Unlock()
+
+	if sdr.stop != nil {
+		return errors.New("sdr.start: already started")
+	}
+
+	if len(strings.Fields(sdr.Command)) == 0 {
+		return errors.New("sdr.start: no command")
+	}
+
+	if sdr.SystemId == 0 || sdr.TalkgroupId == 0 {
+		return errors.New("sdr.start: no system or talkgroup")
+	}
+
+	sdr.controller = controller
+	sdr.stop = make(chan struct{})
+
+	go func(stop chan struct{}) {
+		for {
+			if err := sdr.run(stop); err != nil {
+				controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("sdr: command=\"%s\" %v", sdr.Command, err))
+			}
+
+			select {
+			case <-stop:
+				return
+			case <-time.After(SdrRestartDelay):
+			}
+		}
+	}(sdr.stop)
+
+	return nil
+}
+
+func (sdr *Sdr) Stop() {
+	sdr.mutex.Lock()
+	defer sdr.mutex.Unlock()
+
+	if sdr.stop != nil {
+		close(sdr.stop)
+		sdr.stop = nil
+	}
+
+	if sdr.cmd != nil && sdr.cmd.Process != nil {
+		sdr.cmd.Process.Kill()
+	}
+}
+
+func (sdr *Sdr) getHang() time.Duration {
+	if sdr.Hang > 0 {
+		return time.Duration(sdr.Hang) * time.Millisecond
+	}
+	return SdrDefaultHang * time.Millisecond
+}
+
+func (sdr *Sdr) getMaxDuration() time.Duration {
+	if sdr.MaxDuration > 0 {
+		return time.Duration(sdr.MaxDuration) * time.Second
+	}
+	return SdrDefaultMaxDuration * time.Second
+}
+
+func (sdr *Sdr) getMinDuration() time.Duration {
+	if sdr.MinDuration > 0 {
+		return time.Duration(sdr.MinDuration) * time.Millisecond
+	}
+	return SdrDefaultMinDuration * time.Millisecond
+}
+
+func (sdr *Sdr) getSampleRate() uint {
+	if sdr.SampleRate > 0 {
+		return sdr.SampleRate
+	}
+	return SdrDefaultSampleRate
+}
+
+func (sdr *Sdr) getSquelch() float64 {
+	if sdr.Squelch > 0 {
+		return float64(sdr.Squelch)
+	}
+	return SdrDefaultSquelch
+}
+
+func (sdr *Sdr) ingest(pcm []byte, start time.Time) {
+	var (
+		sampleRate = sdr.getSampleRate()
+		duration   = time.Duration(len(pcm)/2) * time.Second / time.Duration(sampleRate)
+	)
+
+	if duration < sdr.getMinDuration() {
+		return
+	}
+
+	call := NewCall()
+
+	call.Audio = sdrWav(pcm, sampleRate)
+	call.AudioName = fmt.Sprintf("%s-%d-%d.wav", start.UTC().Format("20060102_150405"), sdr.SystemId, sdr.TalkgroupId)
+	call.AudioType = "audio/wav"
+	call.DateTime = start.UTC()
+	call.Frequency = sdr.Frequency
+	call.System = sdr.SystemId
+	call.Talkgroup = sdr.TalkgroupId
+	call.uploader = fmt.Sprintf("sdr %v", sdr.Frequency)
+
+	if ok, err := call.IsValid(); ok {
+		sdr.controller.Ingest <- call
+	} else {
+		sdr.controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("sdr: command=\"%s\" %v", sdr.Command, err))
+	}
+}
+
+func (sdr *Sdr) run(stop chan struct{}) error {
+	var (
+		args       = strings.Fields(sdr.Command)
+		frameSize  = int(sdr.getSampleRate()) * 2 * int(SdrFrameDuration) / int(time.Second)
+		hang       = sdr.getHang()
+		maxSize    = int(sdr.getSampleRate()) * 2 * int(sdr.getMaxDuration()/time.Second)
+		squelch    = sdr.getSquelch()
+		active     bool
+		frames     = make(chan []byte, 64)
+		lastVoice  time.Time
+		pcm        = bytes.Buffer{}
+		preroll    = [][]byte{}
+		silence    int
+		start      time.Time
+		stderr     = bytes.Buffer{}
+		hangFrames = int(hang / SdrFrameDuration)
+	)
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+
+	sdr.mutex.Lock()
+	sdr.cmd = cmd
+	sdr.mutex.Unlock()
+
+	sdr.controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("sdr: command=\"%s\" started", sdr.Command))
+
+	go func() {
+		defer close(frames)
+
+		reader := bufio.NewReaderSize(stdout, SdrReadBufferSize)
+
+		for {
+			frame := make([]byte, frameSize)
+			if _, err := io.ReadFull(reader, frame); err != nil {
+				return
+			}
+			frames <- frame
+		}
+	}()
+
+	flush := func() {
+		if active && pcm.Len() > 0 {
+			b := append([]byte(nil), pcm.Bytes()...)
+			sdr.ingest(b, start)
+		}
+		active = false
+		pcm.Reset()
+		preroll = [][]byte{}
+		silence = 0
+	}
+
+	ticker := time.NewTicker(hang / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			flush()
+			cmd.Process.Kill()
+			cmd.Wait()
+			return nil
+
+		case <-ticker.C:
+			if active && time.Since(lastVoice) > hang {
+				flush()
+			}
+
+		case frame, ok := <-frames:
+			if !ok {
+				flush()
+				err := cmd.Wait()
+				sdr.mutex.Lock()
+				sdr.cmd = nil
+				sdr.mutex.Unlock()
+				select {
+				case <-stop:
+					return nil
+				default:
+				}
+				if msg := strings.TrimSpace(stderr.String()); len(msg) > 0 {
+					lines := strings.Split(msg, "\n")
+					return fmt.Errorf("exited: %v, %s", err, lines[len(lines)-1])
+				}
+				return fmt.Errorf("exited: %v", err)
+			}
+
+			voice := sdrRms(frame) >= squelch
+
+			if !active {
+				if voice {
+					active = true
+					lastVoice = time.Now()
+					start = time.Now().Add(-time.Duration(len(preroll)) * SdrFrameDuration)
+					for _, f := range preroll {
+						pcm.Write(f)
+					}
+					pcm.Write(frame)
+					preroll = [][]byte{}
+				} else {
+					preroll = append(preroll, frame)
+					if len(preroll) > SdrPrerollFrames {
+						preroll = preroll[1:]
+					}
+				}
+				continue
+			}
+
+			pcm.Write(frame)
+
+			if voice {
+				lastVoice = time.Now()
+				silence = 0
+			} else {
+				silence++
+			}
+
+			if silence >= hangFrames {
+				pcm.Truncate(pcm.Len() - silence*frameSize)
+				flush()
+
+			} else if pcm.Len() >= maxSize {
+				flush()
+			}
+		}
+	}
+}
+
+type Sdrs struct {
+	List  []*Sdr
+	mutex sync.Mutex
+}
+
+func NewSdrs() *Sdrs {
+	return &Sdrs{
+		List:  []*Sdr{},
+		mutex: sync.Mutex{},
+	}
+}
+
+func (sdrs *Sdrs) FromMap(f []interface{}) *Sdrs {
+	sdrs.mutex.Lock()
+	defer sdrs.mutex.Unlock()
+
+	sdrs.stop()
+
+	sdrs.List = []*Sdr{}
+
+	for _, r := range f {
+		switch m := r.(type) {
+		case map[string]interface{}:
+			sdr := &Sdr{}
+			sdr.FromMap(m)
+			sdrs.List = append(sdrs.List, sdr)
+		}
+	}
+
+	return sdrs
+}
+
+func (sdrs *Sdrs) Read(db *Database) error {
+	var (
+		err       error
+		frequency sql.NullFloat64
+		id        sql.NullFloat64
+		order     sql.NullFloat64
+		rows      *sql.Rows
+	)
+
+	sdrs.mutex.Lock()
+	defer sdrs.mutex.Unlock()
+
+	sdrs.stop()
+
+	sdrs.List = []*Sdr{}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("sdrs.read: %v", err)
+	}
+
+	if rows, err = db.Sql.Query("select `_id`, `command`, `disabled`, `frequency`, `hang`, `maxDuration`, `minDuration`, `order`, `sampleRate`, `squelch`, `systemId`, `talkgroupId` from `rdioScannerSdrs`"); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		sdr := &Sdr{}
+
+		if err = rows.Scan(&id, &sdr.Command, &sdr.Disabled, &frequency, &sdr.Hang, &sdr.MaxDuration, &sdr.MinDuration, &order, &sdr.SampleRate, &sdr.Squelch, &sdr.SystemId, &sdr.TalkgroupId); err != nil {
+			break
+		}
+
+		if id.Valid && id.Float64 > 0 {
+			sdr.Id = uint(id.Float64)
+		}
+
+		if frequency.Valid && frequency.Float64 > 0 {
+			sdr.Frequency = uint(frequency.Float64)
+		}
+
+		if order.Valid && order.Float64 > 0 {
+			sdr.Order = uint(order.Float64)
+		}
+
+		sdrs.List = append(sdrs.List, sdr)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	return nil
+}
+
+func (sdrs *Sdrs) Start(controller *Controller) {
+	sdrs.mutex.Lock()
+	defer sdrs.mutex.Unlock()
+
+	for _, sdr := range sdrs.List {
+		if err := sdr.Start(controller); err != nil {
+			controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("sdrs.start: %s", err.Error()))
+		}
+	}
+}
+
+func (sdrs *Sdrs) Stop() {
+	sdrs.mutex.Lock()
+	defer sdrs.mutex.Unlock()
+
+	sdrs.stop()
+}
+
+func (sdrs *Sdrs) Write(db *Database) error {
+	var (
+		count  uint
+		err    error
+		rows   *sql.Rows
+		rowIds = []uint{}
+	)
+
+	sdrs.mutex.Lock()
+	defer sdrs.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("sdrs.write: %v", err)
+	}
+
+	for _, sdr := range sdrs.List {
+		if err = db.Sql.QueryRow("select count(*) from `rdioScannerSdrs` where `_id` = ?", sdr.Id).Scan(&count); err != nil {
+			break
+		}
+
+		if count == 0 {
+			if _, err = db.Sql.Exec("insert into `rdioScannerSdrs` (`_id`, `command`, `disabled`, `frequency`, `hang`, `maxDuration`, `minDuration`, `order`, `sampleRate`, `squelch`, `systemId`, `talkgroupId`) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", sdr.Id, sdr.Command, sdr.Disabled, sdr.Frequency, sdr.Hang, sdr.MaxDuration, sdr.MinDuration, sdr.Order, sdr.SampleRate, sdr.Squelch, sdr.SystemId, sdr.TalkgroupId); err != nil {
+				break
+			}
+
+		} else if _, err = db.Sql.Exec("update `rdioScannerSdrs` set `_id` = ?, `command` = ?, `disabled` = ?, `frequency` = ?, `hang` = ?, `maxDuration` = ?, `minDuration` = ?, `order` = ?, `sampleRate` = ?, `squelch` = ?, `systemId` = ?, `talkgroupId` = ? where `_id` = ?", sdr.Id, sdr.Command, sdr.Disabled, sdr.Frequency, sdr.Hang, sdr.MaxDuration, sdr.MinDuration, sdr.Order, sdr.SampleRate, sdr.Squelch, sdr.SystemId, sdr.TalkgroupId, sdr.Id); err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if rows, err = db.Sql.Query("select `_id` from `rdioScannerSdrs`"); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		var rowId uint
+		if err = rows.Scan(&rowId); err != nil {
+			break
+		}
+		remove := true
+		for _, sdr := range sdrs.List {
+			if sdr.Id == nil || sdr.Id == rowId {
+				remove = false
+				break
+			}
+		}
+		if remove {
+			rowIds = append(rowIds, rowId)
+		}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if len(rowIds) > 0 {
+		if b, err := json.Marshal(rowIds); err == nil {
+			s := string(b)
+			s = strings.ReplaceAll(s, "[", "(")
+			s = strings.ReplaceAll(s, "]", ")")
+			q := fmt.Sprintf("delete from `rdioScannerSdrs` where `_id` in %v", s)
+			if _, err = db.Sql.Exec(q); err != nil {
+				return formatError(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (sdrs *Sdrs) stop() {
+	for _, sdr := range sdrs.List {
+		sdr.Stop()
+	}
+}
+
+func sdrRms(frame []byte) float64 {
+	var sum float64
+
+	n := len(frame) / 2
+	if n == 0 {
+		return 0
+	}
+
+	for i := 0; i < n; i++ {
+		s := float64(int16(binary.LittleEndian.Uint16(frame[i*2:])))
+		sum += s * s
+	}
+
+	return math.Sqrt(sum / float64(n))
+}
+
+func sdrWav(pcm []byte, sampleRate uint) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, len(pcm)+44))
+
+	write := func(v interface{}) {
+		binary.Write(buf, binary.LittleEndian, v)
+	}
+
+	buf.WriteString("RIFF")
+	write(uint32(36 + len(pcm)))
+	buf.WriteString("WAVE")
+	buf.WriteString("fmt ")
+	write(uint32(16))
+	write(uint16(1))
+	write(uint16(1))
+	write(uint32(sampleRate))
+	write(uint32(sampleRate * 2))
+	write(uint16(2))
+	write(uint16(16))
+	buf.WriteString("data")
+	write(uint32(len(pcm)))
+	buf.Write(pcm)
+
+	return buf.Bytes()
+}
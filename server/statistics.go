@@ -0,0 +1,353 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	StatisticsAudioBitrate = 32000
+	StatisticsDateFormat   = "2006-01-02"
+)
+
+type Statistic struct {
+	Date       string `json:"date"`
+	System     uint   `json:"system"`
+	Talkgroup  uint   `json:"talkgroup"`
+	Calls      uint   `json:"calls"`
+	Airtime    uint   `json:"airtime"`
+	AudioBytes uint64 `json:"audioBytes"`
+	Units      uint   `json:"units"`
+	Listeners  uint   `json:"listeners"`
+}
+
+type Statistics struct {
+	mutex sync.Mutex
+}
+
+func NewStatistics() *Statistics {
+	return &Statistics{
+		mutex: sync.Mutex{},
+	}
+}
+
+func (statistics *Statistics) Search(db *Database, searchOptions *StatisticsSearchOptions) ([]Statistic, error) {
+	var (
+		args  = []interface{}{}
+		err   error
+		rows  *sql.Rows
+		where = "true"
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("statistics.search: %v", err)
+	}
+
+	if len(searchOptions.From) > 0 {
+		where += " and `date` >= ?"
+		args = append(args, searchOptions.From)
+	}
+
+	if len(searchOptions.To) > 0 {
+		where += " and `date` <= ?"
+		args = append(args, searchOptions.To)
+	}
+
+	switch v := searchOptions.System.(type) {
+	case uint:
+		where += " and `system` = ?"
+		args = append(args, v)
+	}
+
+	switch v := searchOptions.Talkgroup.(type) {
+	case uint:
+		where += " and `talkgroup` = ?"
+		args = append(args, v)
+	}
+
+	query := fmt.Sprintf("select `date`, `system`, `talkgroup`, `calls`, `airtime`, `audioBytes`, `units`, `listeners` from `rdioScannerStatistics` where %s order by `date`, `system`, `talkgroup`", where)
+	if rows, err = db.Sql.Query(query, args...); err != nil {
+		return nil, formatError(err)
+	}
+	defer rows.Close()
+
+	list := []Statistic{}
+
+	for rows.Next() {
+		statistic := Statistic{}
+
+		if err = rows.Scan(&statistic.Date, &statistic.System, &statistic.Talkgroup, &statistic.Calls, &statistic.Airtime, &statistic.AudioBytes, &statistic.Units, &statistic.Listeners); err != nil {
+			return nil, formatError(err)
+		}
+
+		list = append(list, statistic)
+	}
+
+	return list, rows.Err()
+}
+
+func (statistics *Statistics) Snapshot(db *Database, day time.Time) error {
+	var (
+		airtime    uint64
+		audioBytes sql.NullInt64
+		calls      uint64
+		err        error
+		listeners  uint
+		rows       *sql.Rows
+		system     uint
+		talkgroup  uint
+		tx         *sql.Tx
+		units      uint
+	)
+
+	statistics.mutex.Lock()
+	defer statistics.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("statistics.snapshot: %v", err)
+	}
+
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	stop := start.Add(24*time.Hour - time.Millisecond)
+	date := start.Format(StatisticsDateFormat)
+	hour := start.Unix() / 3600
+
+	aggregates := map[[2]uint]*Statistic{}
+
+	getStatistic := func(system uint, talkgroup uint) *Statistic {
+		key := [2]uint{system, talkgroup}
+
+		s := aggregates[key]
+		if s == nil {
+			s = &Statistic{Date: date, System: system, Talkgroup: talkgroup}
+			aggregates[key] = s
+		}
+
+		return s
+	}
+
+	if rows, err = db.Sql.Query("select `system`, `talkgroup`, sum(`calls`), sum(`airtime`) from `rdioScannerCallStats` where `hour` >= ? and `hour` < ? group by `system`, `talkgroup`", hour, hour+24); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&system, &talkgroup, &calls, &airtime); err != nil {
+			break
+		}
+
+		s := getStatistic(system, talkgroup)
+		s.Calls = uint(calls)
+		s.Airtime = uint((airtime + 500) / 1000)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if rows, err = db.Sql.Query("select `system`, `talkgroup`, count(distinct `unit`) from `rdioScannerUnitStats` where `hour` >= ? and `hour` < ? group by `system`, `talkgroup`", hour, hour+24); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&system, &talkgroup, &units); err != nil {
+			break
+		}
+
+		getStatistic(system, talkgroup).Units = units
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if rows, err = db.Sql.Query("select `system`, `talkgroup`, sum(length(`audio`)) from `rdioScannerCalls` where `dateTime` between ? and ? group by `system`, `talkgroup`", start.Format(db.DateTimeFormat), stop.Format(db.DateTimeFormat)); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&system, &talkgroup, &audioBytes); err != nil {
+			break
+		}
+
+		if audioBytes.Valid && audioBytes.Int64 > 0 {
+			getStatistic(system, talkgroup).AudioBytes = uint64(audioBytes.Int64)
+		}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if rows, err = db.Sql.Query("select `system`, `talkgroup`, count(distinct `listener`) from `rdioScannerListenerStats` where `date` = ? group by `system`, `talkgroup`", date); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(&system, &talkgroup, &listeners); err != nil {
+			break
+		}
+
+		getStatistic(system, talkgroup).Listeners = listeners
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if tx, err = db.Sql.Begin(); err != nil {
+		return formatError(err)
+	}
+
+	if _, err = tx.Exec("delete from `rdioScannerStatistics` where `date` = ?", date); err != nil {
+		tx.Rollback()
+		return formatError(err)
+	}
+
+	for _, s := range aggregates {
+		if _, err = tx.Exec("insert into `rdioScannerStatistics` (`date`, `system`, `talkgroup`, `calls`, `airtime`, `audioBytes`, `units`, `listeners`) values (?, ?, ?, ?, ?, ?, ?, ?)", s.Date, s.System, s.Talkgroup, s.Calls, s.Airtime, s.AudioBytes, s.Units, s.Listeners); err != nil {
+			tx.Rollback()
+			return formatError(err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return formatError(err)
+	}
+
+	return nil
+}
+
+func (statistics *Statistics) SnapshotPending(db *Database) error {
+	var (
+		aggregated int64
+		dateTime   interface{}
+		hour       string
+		last       string
+		start      time.Time
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("statistics.snapshotpending: %v", err)
+	}
+
+	if err := db.Sql.QueryRow("select `val` from `rdioScannerConfigs` where `key` = 'callStatsAggregate'").Scan(&hour); err == nil {
+		if aggregated, err = strconv.ParseInt(hour, 10, 64); err != nil {
+			return formatError(err)
+		}
+	} else if err == sql.ErrNoRows {
+		return nil
+	} else {
+		return formatError(err)
+	}
+
+	if err := db.Sql.QueryRow("select `val` from `rdioScannerConfigs` where `key` = 'statisticsSnapshot'").Scan(&last); err == nil {
+		if err = json.Unmarshal([]byte(last), &last); err != nil {
+			return formatError(err)
+		}
+	} else if err != sql.ErrNoRows {
+		return formatError(err)
+	}
+
+	if t, err := time.Parse(StatisticsDateFormat, last); err == nil {
+		start = t.Add(24 * time.Hour)
+
+	} else if err := db.Sql.QueryRow("select `dateTime` from `rdioScannerCalls` order by `dateTime` asc limit 1").Scan(&dateTime); err == nil {
+		if start, err = db.ParseDateTime(dateTime); err != nil {
+			return formatError(err)
+		}
+
+	} else if err == sql.ErrNoRows {
+		return nil
+
+	} else {
+		return formatError(err)
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	for day := start.UTC().Truncate(24 * time.Hour); day.Before(today) && day.Unix()/3600+23 <= aggregated; day = day.Add(24 * time.Hour) {
+		if err := statistics.Snapshot(db, day); err != nil {
+			return err
+		}
+
+		b, err := json.Marshal(day.Format(StatisticsDateFormat))
+		if err != nil {
+			return formatError(err)
+		}
+
+		if res, err := db.Sql.Exec("update `rdioScannerConfigs` set `val` = ? where `key` = 'statisticsSnapshot'", string(b)); err != nil {
+			return formatError(err)
+
+		} else if i, err := res.RowsAffected(); err == nil && i == 0 {
+			if _, err = db.Sql.Exec("insert into `rdioScannerConfigs` (`key`, `val`) values (?, ?)", "statisticsSnapshot", string(b)); err != nil {
+				return formatError(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+type StatisticsSearchOptions struct {
+	From      string      `json:"from,omitempty"`
+	System    interface{} `json:"system,omitempty"`
+	Talkgroup interface{} `json:"talkgroup,omitempty"`
+	To        string      `json:"to,omitempty"`
+}
+
+func (searchOptions *StatisticsSearchOptions) FromMap(m map[string]interface{}) error {
+	switch v := m["from"].(type) {
+	case string:
+		if _, err := time.Parse(StatisticsDateFormat, v); err != nil {
+			return fmt.Errorf("invalid from date %s", v)
+		}
+		searchOptions.From = v
+	}
+
+	switch v := m["system"].(type) {
+	case float64:
+		searchOptions.System = uint(v)
+	}
+
+	switch v := m["talkgroup"].(type) {
+	case float64:
+		searchOptions.Talkgroup = uint(v)
+	}
+
+	switch v := m["to"].(type) {
+	case string:
+		if _, err := time.Parse(StatisticsDateFormat, v); err != nil {
+			return fmt.Errorf("invalid to date %s", v)
+		}
+		searchOptions.To = v
+	}
+
+	return nil
+}
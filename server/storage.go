@@ -0,0 +1,347 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AudioStorageDatabase string = "database"
+	AudioStorageS3       string = "s3"
+
+	StorageEmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	StorageTimeout          = 30 * time.Second
+)
+
+type StorageCredentials struct {
+	AccessKey string
+	Endpoint  string
+	PathStyle bool
+	Region    string
+	SecretKey string
+}
+
+type StorageObject struct {
+	Key          string    `xml:"Key"`
+	LastModified time.Time `xml:"LastModified"`
+	Size         int64     `xml:"Size"`
+}
+
+type Storage struct {
+	controller *Controller
+	client     *http.Client
+	transfer   *http.Client
+}
+
+func NewStorage(controller *Controller) *Storage {
+	return &Storage{
+		controller: controller,
+		client:     &http.Client{Timeout: StorageTimeout},
+		transfer:   &http.Client{},
+	}
+}
+
+func (storage *Storage) Delete(key string) error {
+	res, err := storage.s3Request(http.MethodDelete, key, nil, "")
+	if err != nil {
+		return fmt.Errorf("storage.delete: %v", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= 300 && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("storage.delete: %s returned %s", key, res.Status)
+	}
+
+	return nil
+}
+
+func (storage *Storage) Get(key string) ([]byte, error) {
+	res, err := storage.s3Request(http.MethodGet, key, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("storage.get: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("storage.get: %s returned %s", key, res.Status)
+	}
+
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("storage.get: %v", err)
+	}
+
+	return b, nil
+}
+
+func (storage *Storage) DeleteObject(bucket string, key string) error {
+	req, err := storage.s3NewRequest(bucket, http.MethodDelete, key, nil, bytes.NewReader([]byte{}), 0, StorageEmptyPayloadHash, "")
+	if err != nil {
+		return fmt.Errorf("storage.deleteobject: %v", err)
+	}
+
+	res, err := storage.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("storage.deleteobject: %v", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= 300 && res.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("storage.deleteobject: %s returned %s", key, res.Status)
+	}
+
+	return nil
+}
+
+func (storage *Storage) GetObject(bucket string, key string) (io.ReadCloser, error) {
+	req, err := storage.s3NewRequest(bucket, http.MethodGet, key, nil, bytes.NewReader([]byte{}), 0, StorageEmptyPayloadHash, "")
+	if err != nil {
+		return nil, fmt.Errorf("storage.getobject: %v", err)
+	}
+
+	res, err := storage.transfer.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("storage.getobject: %v", err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, fmt.Errorf("storage.getobject: %s returned %s", key, res.Status)
+	}
+
+	return res.Body, nil
+}
+
+func (storage *Storage) ListObjects(bucket string, prefix string) ([]StorageObject, error) {
+	var result struct {
+		Contents []StorageObject `xml:"Contents"`
+	}
+
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", prefix)
+
+	req, err := storage.s3NewRequest(bucket, http.MethodGet, "", query, bytes.NewReader([]byte{}), 0, StorageEmptyPayloadHash, "")
+	if err != nil {
+		return nil, fmt.Errorf("storage.listobjects: %v", err)
+	}
+
+	res, err := storage.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("storage.listobjects: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("storage.listobjects: %s returned %s", bucket, res.Status)
+	}
+
+	if err = xml.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("storage.listobjects: %v", err)
+	}
+
+	return result.Contents, nil
+}
+
+func (storage *Storage) IsRemote() bool {
+	return storage.controller.Options.AudioStorage == AudioStorageS3 && len(storage.controller.Options.AudioStorageS3Bucket) > 0
+}
+
+func (storage *Storage) NewKey(call *Call) string {
+	ext := ""
+	switch v := call.AudioName.(type) {
+	case string:
+		ext = path.Ext(v)
+	}
+
+	return path.Join(storage.controller.Options.AudioStorageS3Prefix, call.DateTime.UTC().Format("2006/01/02"), uuid.New().String()+ext)
+}
+
+func (storage *Storage) Put(key string, b []byte, contentType string) error {
+	res, err := storage.s3Request(http.MethodPut, key, b, contentType)
+	if err != nil {
+		return fmt.Errorf("storage.put: %v", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("storage.put: %s returned %s", key, res.Status)
+	}
+
+	return nil
+}
+
+func (storage *Storage) PutObject(bucket string, key string, body io.Reader, size int64, contentType string) error {
+	req, err := storage.s3NewRequest(bucket, http.MethodPut, key, nil, body, size, "UNSIGNED-PAYLOAD", contentType)
+	if err != nil {
+		return fmt.Errorf("storage.putobject: %v", err)
+	}
+
+	res, err := storage.transfer.Do(req)
+	if err != nil {
+		return fmt.Errorf("storage.putobject: %v", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("storage.putobject: %s returned %s", key, res.Status)
+	}
+
+	return nil
+}
+
+func (storage *Storage) s3Request(method string, key string, body []byte, contentType string) (*http.Response, error) {
+	if body == nil {
+		body = []byte{}
+	}
+
+	hash := sha256.Sum256(body)
+
+	req, err := storage.s3NewRequest(storage.controller.Options.AudioStorageS3Bucket, method, key, nil, bytes.NewReader(body), int64(len(body)), hex.EncodeToString(hash[:]), contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.client.Do(req)
+}
+
+func (storage *Storage) s3NewRequest(bucket string, method string, key string, query url.Values, body io.Reader, size int64, payloadHash string, contentType string) (*http.Request, error) {
+	options := storage.controller.Options
+
+	credentials := StorageCredentials{
+		AccessKey: options.AudioStorageS3AccessKey,
+		Endpoint:  options.AudioStorageS3Endpoint,
+		PathStyle: options.AudioStorageS3PathStyle,
+		Region:    options.AudioStorageS3Region,
+		SecretKey: options.AudioStorageS3SecretKey,
+	}
+
+	return NewS3Request(credentials, bucket, method, key, query, body, size, payloadHash, contentType)
+}
+
+func NewS3Request(credentials StorageCredentials, bucket string, method string, key string, query url.Values, body io.Reader, size int64, payloadHash string, contentType string) (*http.Request, error) {
+	if len(bucket) == 0 {
+		return nil, errors.New("no bucket configured")
+	}
+
+	region := credentials.Region
+	if len(region) == 0 {
+		region = "us-east-1"
+	}
+
+	endpoint := strings.TrimSuffix(credentials.Endpoint, "/")
+	if len(endpoint) == 0 {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := strings.Split(strings.TrimPrefix(key, "/"), "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+
+	host := u.Host
+	uri := "/" + strings.Join(segments, "/")
+
+	if credentials.PathStyle {
+		uri = "/" + url.PathEscape(bucket) + uri
+	} else {
+		host = bucket + "." + host
+	}
+
+	rawQuery := strings.ReplaceAll(query.Encode(), "+", "%20")
+
+	rawUrl := fmt.Sprintf("%s://%s%s", u.Scheme, host, uri)
+	if len(rawQuery) > 0 {
+		rawUrl += "?" + rawQuery
+	}
+
+	req, err := http.NewRequest(method, rawUrl, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.ContentLength = size
+	req.Host = host
+
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	SignAwsRequest(req, credentials.AccessKey, credentials.SecretKey, region, "s3", uri, rawQuery, payloadHash)
+
+	return req, nil
+}
+
+func SignAwsRequest(req *http.Request, accessKey string, secretKey string, region string, service string, uri string, rawQuery string, payloadHash string) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+
+	canonical := strings.Join([]string{
+		req.Method,
+		uri,
+		rawQuery,
+		fmt.Sprintf("host:%s\nx-amz-content-sha256:%s\nx-amz-date:%s\n", req.Host, payloadHash, amzDate),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+
+	canonicalHash := sha256.Sum256([]byte(canonical))
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+
+	sign := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+
+	signingKey := sign(sign(sign(sign([]byte("AWS4"+secretKey), date), region), service), "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, hex.EncodeToString(sign(signingKey, stringToSign))))
+}
@@ -0,0 +1,63 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+func (controller *Controller) GetStreamOnlyCall(call *Call) *Call {
+	if call.watermarked || len(call.Audio) == 0 {
+		return call
+	}
+
+	audio, err := controller.FFMpeg.Beep(call.Audio)
+	if err != nil {
+		controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("stream only: call=%v %v", call.Id, err))
+		return call
+	}
+
+	c := *call
+	c.Audio = audio
+	c.AudioType = "audio/mp4"
+	c.streamOnly = true
+	c.watermarked = true
+
+	switch v := call.AudioName.(type) {
+	case string:
+		c.AudioName = fmt.Sprintf("%v.m4a", strings.TrimSuffix(v, path.Ext(v)))
+	}
+
+	return &c
+}
+
+func (controller *Controller) IsStreamOnly(client *Client, call *Call) bool {
+	if call.streamOnly {
+		return true
+	}
+
+	if client != nil && client.Access != nil && client.Access.StreamOnly {
+		return true
+	}
+
+	if system, ok := controller.Systems.GetSystem(call.System); ok {
+		return system.StreamOnly
+	}
+
+	return false
+}
@@ -26,15 +26,30 @@ import (
 )
 
 type System struct {
-	Id           uint        `json:"id"`
-	AutoPopulate bool        `json:"autoPopulate"`
-	Blacklists   Blacklists  `json:"blacklists"`
-	Label        string      `json:"label"`
-	Led          interface{} `json:"led"`
-	Order        uint        `json:"order"`
-	RowId        interface{} `json:"_id"`
-	Talkgroups   *Talkgroups `json:"talkgroups"`
-	Units        *Units      `json:"units"`
+	Id                     uint        `json:"id"`
+	AudioBitrate           uint        `json:"audioBitrate"`
+	AudioChannels          uint        `json:"audioChannels"`
+	AudioCodec             string      `json:"audioCodec"`
+	AudioConversion        string      `json:"audioConversion"`
+	AudioSampleRate        uint        `json:"audioSampleRate"`
+	AutoPopulate           bool        `json:"autoPopulate"`
+	Blacklists             Blacklists  `json:"blacklists"`
+	BroadcastifyApiKey     string      `json:"broadcastifyApiKey"`
+	BroadcastifySystemId   uint        `json:"broadcastifySystemId"`
+	DuplicatePrimary       string      `json:"duplicatePrimary"`
+	DuplicateStrategy      string      `json:"duplicateStrategy"`
+	Label                  string      `json:"label"`
+	Led                    interface{} `json:"led"`
+	Order                  uint        `json:"order"`
+	RadioReferenceConflict string      `json:"radioReferenceConflict"`
+	RadioReferenceSystemId uint        `json:"radioReferenceSystemId"`
+	RowId                  interface{} `json:"_id"`
+	Talkgroups             *Talkgroups `json:"talkgroups"`
+	UnitBlacklists         Blacklists  `json:"unitBlacklists"`
+	StreamOnly             bool        `json:"streamOnly"`
+	Units                  *Units      `json:"units"`
+	UnitsImportConflict    string      `json:"unitsImportConflict"`
+	UnitsImportUrl         string      `json:"unitsImportUrl"`
 }
 
 func NewSystem() *System {
@@ -55,6 +70,39 @@ func (system *System) FromMap(m map[string]interface{}) *System {
 		system.Id = uint(v)
 	}
 
+	switch v := m["audioBitrate"].(type) {
+	case float64:
+		system.AudioBitrate = uint(v)
+	}
+
+	switch v := m["audioChannels"].(type) {
+	case float64:
+		if v == 1 || v == 2 {
+			system.AudioChannels = uint(v)
+		}
+	}
+
+	switch v := m["audioCodec"].(type) {
+	case string:
+		switch v {
+		case "aac", "mp3", "opus":
+			system.AudioCodec = v
+		}
+	}
+
+	switch v := m["audioConversion"].(type) {
+	case string:
+		switch v {
+		case "disabled", "enabled":
+			system.AudioConversion = v
+		}
+	}
+
+	switch v := m["audioSampleRate"].(type) {
+	case float64:
+		system.AudioSampleRate = uint(v)
+	}
+
 	switch v := m["autoPopulate"].(type) {
 	case bool:
 		system.AutoPopulate = v
@@ -65,6 +113,59 @@ func (system *System) FromMap(m map[string]interface{}) *System {
 		system.Blacklists = Blacklists(v)
 	}
 
+	switch v := m["unitBlacklists"].(type) {
+	case string:
+		system.UnitBlacklists = Blacklists(v)
+	}
+
+	switch v := m["streamOnly"].(type) {
+	case bool:
+		system.StreamOnly = v
+	}
+
+	switch v := m["unitsImportConflict"].(type) {
+	case string:
+		system.UnitsImportConflict = strings.TrimSpace(v)
+	}
+
+	switch v := m["unitsImportUrl"].(type) {
+	case string:
+		system.UnitsImportUrl = strings.TrimSpace(v)
+	}
+
+	switch v := m["radioReferenceConflict"].(type) {
+	case string:
+		system.RadioReferenceConflict = strings.TrimSpace(v)
+	}
+
+	switch v := m["radioReferenceSystemId"].(type) {
+	case float64:
+		system.RadioReferenceSystemId = uint(v)
+	}
+
+	switch v := m["broadcastifyApiKey"].(type) {
+	case string:
+		system.BroadcastifyApiKey = strings.TrimSpace(v)
+	}
+
+	switch v := m["broadcastifySystemId"].(type) {
+	case float64:
+		system.BroadcastifySystemId = uint(v)
+	}
+
+	switch v := m["duplicatePrimary"].(type) {
+	case string:
+		system.DuplicatePrimary = strings.TrimSpace(v)
+	}
+
+	switch v := m["duplicateStrategy"].(type) {
+	case string:
+		switch v {
+		case "fingerprint", "hash", "keep", "priority", "time":
+			system.DuplicateStrategy = v
+		}
+	}
+
 	switch v := m["label"].(type) {
 	case string:
 		system.Label = v
@@ -93,6 +194,34 @@ func (system *System) FromMap(m map[string]interface{}) *System {
 	return system
 }
 
+func (system *System) IsAudioConversionEnabled(options *Options) bool {
+	switch system.AudioConversion {
+	case "disabled":
+		return false
+	case "enabled":
+		return true
+	default:
+		return !options.DisableAudioConversion
+	}
+}
+
+func (system *System) GetDuplicateStrategy() string {
+	switch system.DuplicateStrategy {
+	case "fingerprint", "hash", "keep", "time":
+		return system.DuplicateStrategy
+	case "priority":
+		if len(system.DuplicatePrimary) > 0 {
+			return system.DuplicateStrategy
+		}
+	}
+
+	return "time"
+}
+
+func (system *System) IsSecondarySource(call *Call) bool {
+	return system.GetDuplicateStrategy() == "priority" && call.uploader != system.DuplicatePrimary
+}
+
 type SystemMap map[string]interface{}
 
 type Systems struct {
@@ -237,6 +366,27 @@ func (systems *Systems) GetScopedSystems(client *Client, groups *Groups, tags *T
 				}
 			}
 		}
+
+		if client.Access.Deny != nil {
+			allowedSystems := []System{}
+
+			for _, rawSystem := range rawSystems {
+				talkgroups := NewTalkgroups()
+
+				for _, rawTalkgroup := range rawSystem.Talkgroups.List {
+					if !client.Access.IsDenied(&Call{System: rawSystem.Id, Talkgroup: rawTalkgroup.Id}) {
+						talkgroups.List = append(talkgroups.List, rawTalkgroup)
+					}
+				}
+
+				if len(talkgroups.List) > 0 {
+					rawSystem.Talkgroups = talkgroups
+					allowedSystems = append(allowedSystems, rawSystem)
+				}
+			}
+
+			rawSystems = allowedSystems
+		}
 	}
 
 	for i, rawSystem := range rawSystems {
@@ -322,12 +472,27 @@ func (systems *Systems) GetScopedSystems(client *Client, groups *Groups, tags *T
 
 func (systems *Systems) Read(db *Database) error {
 	var (
-		blacklists sql.NullString
-		err        error
-		led        sql.NullString
-		order      sql.NullFloat64
-		rowId      sql.NullFloat64
-		rows       *sql.Rows
+		audioBitrate    sql.NullFloat64
+		audioChannels   sql.NullFloat64
+		audioCodec      sql.NullString
+		audioConversion sql.NullString
+		audioSampleRate sql.NullFloat64
+		blacklists      sql.NullString
+		bcfyApiKey      sql.NullString
+		bcfySystemId    sql.NullFloat64
+		dupPrimary      sql.NullString
+		dupStrategy     sql.NullString
+		err             error
+		led             sql.NullString
+		order           sql.NullFloat64
+		rrConflict      sql.NullString
+		rrSystemId      sql.NullFloat64
+		rowId           sql.NullFloat64
+		rows            *sql.Rows
+		streamOnly      sql.NullBool
+		unitBlacklists  sql.NullString
+		unitsConflict   sql.NullString
+		unitsUrl        sql.NullString
 	)
 
 	systems.mutex.Lock()
@@ -339,7 +504,7 @@ func (systems *Systems) Read(db *Database) error {
 		return fmt.Errorf("systems.read: %v", err)
 	}
 
-	if rows, err = db.Sql.Query("select `_id`, `autoPopulate`, `blacklists`, `id`, `label`, `led`, `order` from `rdioScannerSystems`"); err != nil {
+	if rows, err = db.Sql.Query("select `_id`, `audioBitrate`, `audioChannels`, `audioCodec`, `audioConversion`, `audioSampleRate`, `autoPopulate`, `blacklists`, `broadcastifyApiKey`, `broadcastifySystemId`, `duplicatePrimary`, `duplicateStrategy`, `id`, `label`, `led`, `order`, `unitBlacklists`, `unitsImportConflict`, `unitsImportUrl`, `streamOnly`, `radioReferenceConflict`, `radioReferenceSystemId` from `rdioScannerSystems`"); err != nil {
 		return formatError(err)
 	}
 
@@ -349,7 +514,7 @@ func (systems *Systems) Read(db *Database) error {
 			Units:      NewUnits(),
 		}
 
-		if err = rows.Scan(&rowId, &system.AutoPopulate, &blacklists, &system.Id, &system.Label, &led, &order); err != nil {
+		if err = rows.Scan(&rowId, &audioBitrate, &audioChannels, &audioCodec, &audioConversion, &audioSampleRate, &system.AutoPopulate, &blacklists, &bcfyApiKey, &bcfySystemId, &dupPrimary, &dupStrategy, &system.Id, &system.Label, &led, &order, &unitBlacklists, &unitsConflict, &unitsUrl, &streamOnly, &rrConflict, &rrSystemId); err != nil {
 			break
 		}
 
@@ -357,12 +522,72 @@ func (systems *Systems) Read(db *Database) error {
 			system.RowId = uint(rowId.Float64)
 		}
 
+		if audioBitrate.Valid && audioBitrate.Float64 > 0 {
+			system.AudioBitrate = uint(audioBitrate.Float64)
+		}
+
+		if audioChannels.Valid && audioChannels.Float64 > 0 {
+			system.AudioChannels = uint(audioChannels.Float64)
+		}
+
+		if audioCodec.Valid {
+			system.AudioCodec = audioCodec.String
+		}
+
+		if audioConversion.Valid {
+			system.AudioConversion = audioConversion.String
+		}
+
+		if audioSampleRate.Valid && audioSampleRate.Float64 > 0 {
+			system.AudioSampleRate = uint(audioSampleRate.Float64)
+		}
+
 		if blacklists.Valid && len(blacklists.String) > 0 {
 			blacklists.String = strings.ReplaceAll(blacklists.String, "[", "")
 			blacklists.String = strings.ReplaceAll(blacklists.String, "]", "")
 			system.Blacklists = Blacklists(blacklists.String)
 		}
 
+		if bcfyApiKey.Valid {
+			system.BroadcastifyApiKey = bcfyApiKey.String
+		}
+
+		if bcfySystemId.Valid && bcfySystemId.Float64 > 0 {
+			system.BroadcastifySystemId = uint(bcfySystemId.Float64)
+		}
+
+		if dupPrimary.Valid {
+			system.DuplicatePrimary = dupPrimary.String
+		}
+
+		if dupStrategy.Valid {
+			system.DuplicateStrategy = dupStrategy.String
+		}
+
+		if unitBlacklists.Valid {
+			system.UnitBlacklists = Blacklists(unitBlacklists.String)
+		}
+
+		if streamOnly.Valid {
+			system.StreamOnly = streamOnly.Bool
+		}
+
+		if unitsConflict.Valid {
+			system.UnitsImportConflict = unitsConflict.String
+		}
+
+		if unitsUrl.Valid {
+			system.UnitsImportUrl = unitsUrl.String
+		}
+
+		if rrConflict.Valid {
+			system.RadioReferenceConflict = rrConflict.String
+		}
+
+		if rrSystemId.Valid && rrSystemId.Float64 > 0 {
+			system.RadioReferenceSystemId = uint(rrSystemId.Float64)
+		}
+
 		if led.Valid && len(led.String) > 0 {
 			system.Led = led.String
 		}
@@ -397,12 +622,10 @@ func (systems *Systems) Read(db *Database) error {
 
 func (systems *Systems) Write(db *Database) error {
 	var (
-		blacklists string
-		count      uint
-		err        error
-		rows       *sql.Rows
-		rowIds     = []uint{}
-		systemIds  = []uint{}
+		err       error
+		rows      *sql.Rows
+		rowIds    = []uint{}
+		systemIds = []uint{}
 	)
 
 	systems.mutex.Lock()
@@ -413,38 +636,11 @@ func (systems *Systems) Write(db *Database) error {
 	}
 
 	for _, system := range systems.List {
-		if len(system.Blacklists) > 0 {
-			blacklists = strings.Join([]string{"[", system.Blacklists.String(), "]"}, "")
-		} else {
-			blacklists = "[]"
-		}
-
-		if err = db.Sql.QueryRow("select count(*) from `rdioScannerSystems` where `_id` = ?", system.RowId).Scan(&count); err != nil {
-			break
-		}
-
-		if count == 0 {
-			if _, err = db.Sql.Exec("insert into `rdioScannerSystems` (`_id`, `autoPopulate`, `blacklists`, `id`, `label`, `led`, `order`) values (?, ?, ?, ?, ?, ?, ?)", system.RowId, system.AutoPopulate, blacklists, system.Id, system.Label, system.Led, system.Order); err != nil {
-				break
-			}
-
-		} else if _, err = db.Sql.Exec("update `rdioScannerSystems` set `_id` = ?, `autoPopulate` = ?, `blacklists` = ?, `id` = ?, `label` = ?, `led` = ?, `order` = ? where `_id` = ?", system.RowId, system.AutoPopulate, blacklists, system.Id, system.Label, system.Led, system.Order, system.RowId); err != nil {
-			break
-		}
-
-		if err = system.Talkgroups.Write(db, system.Id); err != nil {
-			return err
-		}
-
-		if err = system.Units.Write(db, system.Id); err != nil {
+		if err = systems.writeSystem(db, system); err != nil {
 			return err
 		}
 	}
 
-	if err != nil {
-		return formatError(err)
-	}
-
 	if rows, err = db.Sql.Query("select `_id`, `id` from `rdioScannerSystems`"); err != nil {
 		return formatError(err)
 	}
@@ -505,4 +701,54 @@ func (systems *Systems) Write(db *Database) error {
 	return nil
 }
 
+func (systems *Systems) WriteSystems(db *Database, list []*System) error {
+	systems.mutex.Lock()
+	defer systems.mutex.Unlock()
+
+	for _, system := range list {
+		if err := systems.writeSystem(db, system); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (systems *Systems) writeSystem(db *Database, system *System) error {
+	var (
+		blacklists string
+		count      uint
+		err        error
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("systems.writesystem: %v", err)
+	}
+
+	if len(system.Blacklists) > 0 {
+		blacklists = strings.Join([]string{"[", system.Blacklists.String(), "]"}, "")
+	} else {
+		blacklists = "[]"
+	}
+
+	if err = db.Sql.QueryRow("select count(*) from `rdioScannerSystems` where `_id` = ?", system.RowId).Scan(&count); err != nil {
+		return formatError(err)
+	}
+
+	if count == 0 {
+		if _, err = db.Sql.Exec("insert into `rdioScannerSystems` (`_id`, `audioBitrate`, `audioChannels`, `audioCodec`, `audioConversion`, `audioSampleRate`, `autoPopulate`, `blacklists`, `broadcastifyApiKey`, `broadcastifySystemId`, `duplicatePrimary`, `duplicateStrategy`, `id`, `label`, `led`, `order`, `unitBlacklists`, `unitsImportConflict`, `unitsImportUrl`, `streamOnly`, `radioReferenceConflict`, `radioReferenceSystemId`) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", system.RowId, system.AudioBitrate, system.AudioChannels, system.AudioCodec, system.AudioConversion, system.AudioSampleRate, system.AutoPopulate, blacklists, system.BroadcastifyApiKey, system.BroadcastifySystemId, system.DuplicatePrimary, system.DuplicateStrategy, system.Id, system.Label, system.Led, system.Order, system.UnitBlacklists.String(), system.UnitsImportConflict, system.UnitsImportUrl, system.StreamOnly, system.RadioReferenceConflict, system.RadioReferenceSystemId); err != nil {
+			return formatError(err)
+		}
+
+	} else if _, err = db.Sql.Exec("update `rdioScannerSystems` set `_id` = ?, `audioBitrate` = ?, `audioChannels` = ?, `audioCodec` = ?, `audioConversion` = ?, `audioSampleRate` = ?, `autoPopulate` = ?, `blacklists` = ?, `broadcastifyApiKey` = ?, `broadcastifySystemId` = ?, `duplicatePrimary` = ?, `duplicateStrategy` = ?, `id` = ?, `label` = ?, `led` = ?, `order` = ?, `unitBlacklists` = ?, `unitsImportConflict` = ?, `unitsImportUrl` = ?, `streamOnly` = ?, `radioReferenceConflict` = ?, `radioReferenceSystemId` = ? where `_id` = ?", system.RowId, system.AudioBitrate, system.AudioChannels, system.AudioCodec, system.AudioConversion, system.AudioSampleRate, system.AutoPopulate, blacklists, system.BroadcastifyApiKey, system.BroadcastifySystemId, system.DuplicatePrimary, system.DuplicateStrategy, system.Id, system.Label, system.Led, system.Order, system.UnitBlacklists.String(), system.UnitsImportConflict, system.UnitsImportUrl, system.StreamOnly, system.RadioReferenceConflict, system.RadioReferenceSystemId, system.RowId); err != nil {
+		return formatError(err)
+	}
+
+	if err = system.Talkgroups.Write(db, system.Id); err != nil {
+		return err
+	}
+
+	return system.Units.Write(db, system.Id)
+}
+
 type SystemsMap []SystemMap
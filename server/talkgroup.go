@@ -25,16 +25,23 @@ import (
 )
 
 type Talkgroup struct {
-	Frequency interface{} `json:"frequency"`
-	group     string
-	GroupId   uint        `json:"groupId"`
-	Id        uint        `json:"id"`
-	Label     string      `json:"label"`
-	Led       interface{} `json:"led"`
-	Name      string      `json:"name"`
-	Order     uint        `json:"order"`
-	TagId     uint        `json:"tagId"`
-	tag       string
+	Broadcastify     bool        `json:"broadcastify"`
+	BroadcastifySlot uint        `json:"broadcastifySlot"`
+	Delay            uint        `json:"delay"`
+	DownstreamDelay  uint        `json:"downstreamDelay"`
+	Frequency        interface{} `json:"frequency"`
+	group            string
+	GroupId          uint        `json:"groupId"`
+	Id               uint        `json:"id"`
+	Label            string      `json:"label"`
+	Led              interface{} `json:"led"`
+	LinkedSystem     uint        `json:"linkedSystem"`
+	LinkedTalkgroup  uint        `json:"linkedTalkgroup"`
+	Name             string      `json:"name"`
+	Order            uint        `json:"order"`
+	TagId            uint        `json:"tagId"`
+	tag              string
+	UnitBlacklists   Blacklists `json:"unitBlacklists"`
 }
 
 func (talkgroup *Talkgroup) FromMap(m map[string]interface{}) *Talkgroup {
@@ -43,6 +50,26 @@ func (talkgroup *Talkgroup) FromMap(m map[string]interface{}) *Talkgroup {
 		talkgroup.Id = uint(v)
 	}
 
+	switch v := m["broadcastify"].(type) {
+	case bool:
+		talkgroup.Broadcastify = v
+	}
+
+	switch v := m["broadcastifySlot"].(type) {
+	case float64:
+		talkgroup.BroadcastifySlot = uint(v)
+	}
+
+	switch v := m["delay"].(type) {
+	case float64:
+		talkgroup.Delay = uint(v)
+	}
+
+	switch v := m["downstreamDelay"].(type) {
+	case float64:
+		talkgroup.DownstreamDelay = uint(v)
+	}
+
 	switch v := m["frequency"].(type) {
 	case float64:
 		talkgroup.Frequency = uint(v)
@@ -68,6 +95,16 @@ func (talkgroup *Talkgroup) FromMap(m map[string]interface{}) *Talkgroup {
 		talkgroup.Led = v
 	}
 
+	switch v := m["linkedSystem"].(type) {
+	case float64:
+		talkgroup.LinkedSystem = uint(v)
+	}
+
+	switch v := m["linkedTalkgroup"].(type) {
+	case float64:
+		talkgroup.LinkedTalkgroup = uint(v)
+	}
+
 	switch v := m["name"].(type) {
 	case string:
 		talkgroup.Name = v
@@ -88,6 +125,11 @@ func (talkgroup *Talkgroup) FromMap(m map[string]interface{}) *Talkgroup {
 		talkgroup.TagId = uint(v)
 	}
 
+	switch v := m["unitBlacklists"].(type) {
+	case string:
+		talkgroup.UnitBlacklists = Blacklists(v)
+	}
+
 	return talkgroup
 }
 
@@ -145,12 +187,66 @@ func (talkgroups *Talkgroups) GetTalkgroup(f interface{}) (system *Talkgroup, ok
 	return nil, false
 }
 
+func (talkgroups *Talkgroups) GetGroupSiblings(id uint) []uint {
+	talkgroups.mutex.Lock()
+	defer talkgroups.mutex.Unlock()
+
+	siblings := []uint{}
+
+	var groupId uint
+	for _, talkgroup := range talkgroups.List {
+		if talkgroup.Id == id {
+			groupId = talkgroup.GroupId
+			break
+		}
+	}
+
+	if groupId == 0 {
+		return siblings
+	}
+
+	for _, talkgroup := range talkgroups.List {
+		if talkgroup.GroupId == groupId && talkgroup.Id != id {
+			siblings = append(siblings, talkgroup.Id)
+		}
+	}
+
+	return siblings
+}
+
+func (talkgroups *Talkgroups) GetPatchMembers(call *Call) []map[string]interface{} {
+	members := []map[string]interface{}{}
+
+	patches := call.GetPatches()
+	if len(patches) == 0 {
+		return members
+	}
+
+	for _, id := range append([]uint{call.Talkgroup}, patches...) {
+		member := map[string]interface{}{"id": id}
+
+		if talkgroup, ok := talkgroups.GetTalkgroup(id); ok {
+			member["label"] = talkgroup.Label
+			member["name"] = talkgroup.Name
+		}
+
+		members = append(members, member)
+	}
+
+	return members
+}
+
 func (talkgroups *Talkgroups) Read(db *Database, systemId uint) error {
 	var (
-		err       error
-		frequency sql.NullFloat64
-		led       sql.NullString
-		rows      *sql.Rows
+		bcfy            sql.NullBool
+		bcfySlot        sql.NullFloat64
+		err             error
+		frequency       sql.NullFloat64
+		led             sql.NullString
+		linkedSystem    sql.NullFloat64
+		linkedTalkgroup sql.NullFloat64
+		rows            *sql.Rows
+		unitBlacklists  sql.NullString
 	)
 
 	talkgroups.mutex.Lock()
@@ -162,17 +258,25 @@ func (talkgroups *Talkgroups) Read(db *Database, systemId uint) error {
 		return fmt.Errorf("talkgroups.read: %v", err)
 	}
 
-	if rows, err = db.Sql.Query("select `frequency`, `groupId`, `id`, `label`, `led`, `name`, `order`, `tagId` from `rdioScannerTalkgroups` where `systemId` = ?", systemId); err != nil {
+	if rows, err = db.Sql.Query("select `broadcastify`, `broadcastifySlot`, `delay`, `downstreamDelay`, `frequency`, `groupId`, `id`, `label`, `led`, `linkedSystem`, `linkedTalkgroup`, `name`, `order`, `tagId`, `unitBlacklists` from `rdioScannerTalkgroups` where `systemId` = ?", systemId); err != nil {
 		return formatError(err)
 	}
 
 	for rows.Next() {
 		talkgroup := &Talkgroup{}
 
-		if err = rows.Scan(&frequency, &talkgroup.GroupId, &talkgroup.Id, &talkgroup.Label, &led, &talkgroup.Name, &talkgroup.Order, &talkgroup.TagId); err != nil {
+		if err = rows.Scan(&bcfy, &bcfySlot, &talkgroup.Delay, &talkgroup.DownstreamDelay, &frequency, &talkgroup.GroupId, &talkgroup.Id, &talkgroup.Label, &led, &linkedSystem, &linkedTalkgroup, &talkgroup.Name, &talkgroup.Order, &talkgroup.TagId, &unitBlacklists); err != nil {
 			break
 		}
 
+		if bcfy.Valid {
+			talkgroup.Broadcastify = bcfy.Bool
+		}
+
+		if bcfySlot.Valid && bcfySlot.Float64 > 0 {
+			talkgroup.BroadcastifySlot = uint(bcfySlot.Float64)
+		}
+
 		if frequency.Valid && frequency.Float64 > 0 {
 			talkgroup.Frequency = uint(frequency.Float64)
 		}
@@ -181,6 +285,15 @@ func (talkgroups *Talkgroups) Read(db *Database, systemId uint) error {
 			talkgroup.Led = led.String
 		}
 
+		if linkedSystem.Valid && linkedTalkgroup.Valid {
+			talkgroup.LinkedSystem = uint(linkedSystem.Float64)
+			talkgroup.LinkedTalkgroup = uint(linkedTalkgroup.Float64)
+		}
+
+		if unitBlacklists.Valid {
+			talkgroup.UnitBlacklists = Blacklists(unitBlacklists.String)
+		}
+
 		talkgroups.List = append(talkgroups.List, talkgroup)
 	}
 
@@ -218,11 +331,11 @@ func (talkgroups *Talkgroups) Write(db *Database, systemId uint) error {
 		}
 
 		if count == 0 {
-			if _, err = db.Sql.Exec("insert into `rdioScannerTalkgroups` (`frequency`, `groupId`, `id`, `label`, `led`, `name`, `order`, `systemId`, `tagId`) values (?, ?, ?, ?, ?, ?, ?, ?, ?)", talkgroup.Frequency, talkgroup.GroupId, talkgroup.Id, talkgroup.Label, talkgroup.Led, talkgroup.Name, talkgroup.Order, systemId, talkgroup.TagId); err != nil {
+			if _, err = db.Sql.Exec("insert into `rdioScannerTalkgroups` (`broadcastify`, `broadcastifySlot`, `delay`, `downstreamDelay`, `frequency`, `groupId`, `id`, `label`, `led`, `linkedSystem`, `linkedTalkgroup`, `name`, `order`, `systemId`, `tagId`, `unitBlacklists`) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", talkgroup.Broadcastify, talkgroup.BroadcastifySlot, talkgroup.Delay, talkgroup.DownstreamDelay, talkgroup.Frequency, talkgroup.GroupId, talkgroup.Id, talkgroup.Label, talkgroup.Led, talkgroup.LinkedSystem, talkgroup.LinkedTalkgroup, talkgroup.Name, talkgroup.Order, systemId, talkgroup.TagId, talkgroup.UnitBlacklists.String()); err != nil {
 				break
 			}
 
-		} else if _, err = db.Sql.Exec("update `rdioScannerTalkgroups` set `frequency` = ?, `groupId` = ?, `label` = ?, `led` = ?, `name` = ?, `order` = ?, `tagId` = ? where `id` = ? and `systemId` = ?", talkgroup.Frequency, talkgroup.GroupId, talkgroup.Label, talkgroup.Led, talkgroup.Name, talkgroup.Order, talkgroup.TagId, talkgroup.Id, systemId); err != nil {
+		} else if _, err = db.Sql.Exec("update `rdioScannerTalkgroups` set `broadcastify` = ?, `broadcastifySlot` = ?, `delay` = ?, `downstreamDelay` = ?, `frequency` = ?, `groupId` = ?, `label` = ?, `led` = ?, `linkedSystem` = ?, `linkedTalkgroup` = ?, `name` = ?, `order` = ?, `tagId` = ?, `unitBlacklists` = ? where `id` = ? and `systemId` = ?", talkgroup.Broadcastify, talkgroup.BroadcastifySlot, talkgroup.Delay, talkgroup.DownstreamDelay, talkgroup.Frequency, talkgroup.GroupId, talkgroup.Label, talkgroup.Led, talkgroup.LinkedSystem, talkgroup.LinkedTalkgroup, talkgroup.Name, talkgroup.Order, talkgroup.TagId, talkgroup.UnitBlacklists.String(), talkgroup.Id, systemId); err != nil {
 			break
 		}
 	}
@@ -0,0 +1,110 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import "sort"
+
+type TalkgroupRef struct {
+	System    uint `json:"system"`
+	Talkgroup uint `json:"talkgroup"`
+}
+
+func (systems *Systems) GetLinkedTalkgroups(system uint, talkgroup uint) []TalkgroupRef {
+	ref := TalkgroupRef{System: system, Talkgroup: talkgroup}
+
+	if refs := systems.GetTalkgroupLinks()[ref]; len(refs) > 0 {
+		return refs
+	}
+
+	return []TalkgroupRef{ref}
+}
+
+func (systems *Systems) GetTalkgroupLinks() map[TalkgroupRef][]TalkgroupRef {
+	edges := map[TalkgroupRef][]TalkgroupRef{}
+	primaries := map[TalkgroupRef]bool{}
+
+	systems.mutex.Lock()
+	for _, system := range systems.List {
+		system.Talkgroups.mutex.Lock()
+		for _, talkgroup := range system.Talkgroups.List {
+			ref := TalkgroupRef{System: system.Id, Talkgroup: talkgroup.Id}
+
+			if talkgroup.LinkedSystem == 0 || talkgroup.LinkedTalkgroup == 0 {
+				primaries[ref] = true
+				continue
+			}
+
+			linked := TalkgroupRef{System: talkgroup.LinkedSystem, Talkgroup: talkgroup.LinkedTalkgroup}
+			if linked == ref {
+				continue
+			}
+
+			edges[ref] = append(edges[ref], linked)
+			edges[linked] = append(edges[linked], ref)
+		}
+		system.Talkgroups.mutex.Unlock()
+	}
+	systems.mutex.Unlock()
+
+	links := map[TalkgroupRef][]TalkgroupRef{}
+
+	for start := range edges {
+		if links[start] != nil {
+			continue
+		}
+
+		refs := []TalkgroupRef{start}
+		seen := map[TalkgroupRef]bool{start: true}
+
+		for i := 0; i < len(refs); i++ {
+			for _, ref := range edges[refs[i]] {
+				if !seen[ref] {
+					seen[ref] = true
+					refs = append(refs, ref)
+				}
+			}
+		}
+
+		sort.Slice(refs, func(i int, j int) bool {
+			if primaries[refs[i]] != primaries[refs[j]] {
+				return primaries[refs[i]]
+			}
+			if refs[i].System == refs[j].System {
+				return refs[i].Talkgroup < refs[j].Talkgroup
+			}
+			return refs[i].System < refs[j].System
+		})
+
+		for _, ref := range refs {
+			links[ref] = refs
+		}
+	}
+
+	return links
+}
+
+func (livefeed *Livefeed) IsEnabledLinked(refs []TalkgroupRef) bool {
+	livefeed.mutex.Lock()
+	defer livefeed.mutex.Unlock()
+
+	for _, ref := range refs {
+		if livefeed.Matrix[ref.System][ref.Talkgroup] {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,270 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TelemetryInterval = 24 * time.Hour
+	TelemetryTimeout  = 10 * time.Second
+)
+
+type Telemetry struct {
+	controller *Controller
+	instanceId string
+	lastError  string
+	lastSent   interface{}
+	mutex      sync.Mutex
+	ticker     *time.Ticker
+}
+
+func NewTelemetry(controller *Controller) *Telemetry {
+	return &Telemetry{
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (telemetry *Telemetry) GetInstanceId() (string, error) {
+	var s string
+
+	if len(telemetry.instanceId) > 0 {
+		return telemetry.instanceId, nil
+	}
+
+	db := telemetry.controller.Database
+
+	if err := db.Sql.QueryRow("select `val` from `rdioScannerConfigs` where `key` = 'telemetryId'").Scan(&s); err == nil && len(s) > 0 {
+		telemetry.instanceId = s
+		return s, nil
+	}
+
+	u, err := uuid.NewRandom()
+	if err != nil {
+		return "", err
+	}
+
+	if _, err = db.Sql.Exec("insert into `rdioScannerConfigs` (`key`, `val`) values (?, ?)", "telemetryId", u.String()); err != nil {
+		return "", err
+	}
+
+	telemetry.instanceId = u.String()
+
+	return telemetry.instanceId, nil
+}
+
+func (telemetry *Telemetry) GetReport() (map[string]interface{}, error) {
+	var count uint
+
+	controller := telemetry.controller
+	options := controller.Options
+
+	formatError := func(err error) error {
+		return fmt.Errorf("telemetry.getreport: %v", err)
+	}
+
+	id, err := telemetry.GetInstanceId()
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	since := time.Now().Add(-24 * time.Hour).UTC().Format(controller.Database.DateTimeFormat)
+	if err = controller.Database.Sql.QueryRow("select count(*) from `rdioScannerCalls` where `dateTime` > ?", since).Scan(&count); err != nil {
+		return nil, formatError(err)
+	}
+
+	talkgroups := 0
+	for _, system := range controller.Systems.List {
+		talkgroups += len(system.Talkgroups.List)
+	}
+
+	return map[string]interface{}{
+		"arch":       runtime.GOARCH,
+		"callVolume": getTelemetryBucket(int(count)),
+		"dbType":     controller.Config.DbType,
+		"features": map[string]bool{
+			"accessCodes":    len(controller.Accesses.List) > 0,
+			"accessLog":      len(options.AccessLog) > 0,
+			"authWebhook":    len(options.AuthWebhook) > 0,
+			"directory":      options.DirectoryPublish,
+			"dirwatch":       len(controller.Dirwatches.List) > 0,
+			"downstreams":    len(controller.Downstreams.List) > 0,
+			"ffmpeg":         controller.FFMpeg.available,
+			"heartbeats":     len(controller.Heartbeats.GetList()) > 0,
+			"listeningRooms": options.ListeningRooms,
+			"oidc":           controller.Oidc.IsEnabled(),
+			"saml":           controller.Saml.IsEnabled(),
+			"serverQueue":    options.ServerQueue,
+			"silenceAlert":   options.SilenceAlert > 0,
+			"voting":         options.VotingWindow > 0,
+			"webhooks":       len(controller.Webhooks.List) > 0,
+		},
+		"instance":   id,
+		"listeners":  getTelemetryBucket(controller.Clients.Count()),
+		"os":         runtime.GOOS,
+		"systems":    len(controller.Systems.List),
+		"talkgroups": getTelemetryBucket(talkgroups),
+		"version":    Version,
+	}, nil
+}
+
+func (telemetry *Telemetry) GetStatus() map[string]interface{} {
+	telemetry.mutex.Lock()
+	defer telemetry.mutex.Unlock()
+
+	m := map[string]interface{}{
+		"enabled":  telemetry.controller.Options.Telemetry && len(telemetry.controller.Options.TelemetryUrl) > 0,
+		"lastSent": telemetry.lastSent,
+	}
+
+	if len(telemetry.lastError) > 0 {
+		m["lastError"] = telemetry.lastError
+	}
+
+	if report, err := telemetry.GetReport(); err == nil {
+		m["report"] = report
+	} else {
+		m["lastError"] = err.Error()
+	}
+
+	return m
+}
+
+func (telemetry *Telemetry) Send() error {
+	formatError := func(err error) error {
+		return fmt.Errorf("telemetry.send: %v", err)
+	}
+
+	options := telemetry.controller.Options
+
+	if !options.Telemetry {
+		return nil
+	}
+
+	if len(options.TelemetryUrl) == 0 {
+		return formatError(errors.New("no telemetry url"))
+	}
+
+	report, err := telemetry.GetReport()
+	if err != nil {
+		return formatError(err)
+	}
+
+	b, err := json.Marshal(report)
+	if err != nil {
+		return formatError(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, options.TelemetryUrl, bytes.NewReader(b))
+	if err != nil {
+		return formatError(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("rdio-scanner/%s", Version))
+
+	c := http.Client{Timeout: TelemetryTimeout}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return formatError(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return formatError(fmt.Errorf("bad status: %s", res.Status))
+	}
+
+	return nil
+}
+
+func (telemetry *Telemetry) Start() {
+	telemetry.ticker = time.NewTicker(TelemetryInterval)
+
+	send := func() {
+		err := telemetry.Send()
+
+		telemetry.mutex.Lock()
+		if err == nil {
+			if telemetry.controller.Options.Telemetry {
+				telemetry.lastError = ""
+				telemetry.lastSent = time.Now().UTC()
+			}
+		} else {
+			telemetry.lastError = err.Error()
+			telemetry.controller.Logs.LogEvent(LogLevelWarn, err.Error())
+		}
+		telemetry.mutex.Unlock()
+	}
+
+	go func() {
+		send()
+		for range telemetry.ticker.C {
+			send()
+		}
+	}()
+}
+
+func (admin *Admin) TelemetryHandler(w http.ResponseWriter, r *http.Request) {
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		if b, err := json.Marshal(admin.Controller.Telemetry.GetStatus()); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.telemetryhandler: %s", err.Error()))
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func getTelemetryBucket(n int) string {
+	switch {
+	case n == 0:
+		return "0"
+	case n <= 10:
+		return "1-10"
+	case n <= 100:
+		return "11-100"
+	case n <= 1000:
+		return "101-1000"
+	case n <= 10000:
+		return "1001-10000"
+	default:
+		return "10000+"
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ServerTimingEntry struct {
+	Duration time.Duration
+	Name     string
+}
+
+type ServerTiming struct {
+	Entries []ServerTimingEntry
+}
+
+func NewServerTiming() *ServerTiming {
+	return &ServerTiming{Entries: []ServerTimingEntry{}}
+}
+
+func (timing *ServerTiming) Inject(b []byte) json.RawMessage {
+	s, err := json.Marshal(timing.String())
+	if err != nil || len(b) < 2 || b[len(b)-1] != '}' {
+		return json.RawMessage(b)
+	}
+
+	sep := ","
+	if len(b) == 2 {
+		sep = ""
+	}
+
+	return json.RawMessage(fmt.Sprintf(`%s%s"serverTiming":%s}`, b[:len(b)-1], sep, s))
+}
+
+func (timing *ServerTiming) SetHeader(w http.ResponseWriter) {
+	if len(timing.Entries) > 0 {
+		w.Header().Set("Server-Timing", timing.String())
+	}
+}
+
+func (timing *ServerTiming) Start(name string) func() {
+	start := time.Now()
+
+	return func() {
+		timing.Entries = append(timing.Entries, ServerTimingEntry{
+			Duration: time.Since(start),
+			Name:     name,
+		})
+	}
+}
+
+func (timing *ServerTiming) String() string {
+	a := []string{}
+
+	for _, entry := range timing.Entries {
+		a = append(a, fmt.Sprintf("%s;dur=%.1f", entry.Name, float64(entry.Duration.Microseconds())/1000))
+	}
+
+	return strings.Join(a, ", ")
+}
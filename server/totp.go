@@ -0,0 +1,352 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TotpDigits = 6
+	TotpIssuer = "Rdio Scanner"
+	TotpPeriod = 30
+	TotpSkew   = 1
+)
+
+type AdminTotpAccount struct {
+	Enabled bool   `json:"enabled"`
+	Secret  string `json:"secret"`
+	last    int64
+}
+
+type AdminTotp struct {
+	Accounts map[string]*AdminTotpAccount
+	mutex    sync.Mutex
+}
+
+func NewAdminTotp() *AdminTotp {
+	return &AdminTotp{
+		Accounts: map[string]*AdminTotpAccount{},
+		mutex:    sync.Mutex{},
+	}
+}
+
+func (admin *Admin) TotpHandler(w http.ResponseWriter, r *http.Request) {
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.totphandler: %s", err.Error()))
+	}
+
+	t := admin.GetAuthorization(r)
+	user, ok := admin.GetTokenUser(t)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	account := user.GetTotpAccount()
+	if len(account) == 0 {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	send := func(m map[string]interface{}) {
+		if b, err := json.Marshal(m); err == nil {
+			w.Header().Set("Cache-Control", "no-store")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(b)
+		} else {
+			w.WriteHeader(http.StatusExpectationFailed)
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		send(map[string]interface{}{"enabled": admin.Totp.IsEnabled(account)})
+
+	case http.MethodPost:
+		if !admin.CheckWritable(w) {
+			return
+		}
+
+		m := map[string]interface{}{}
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		action, _ := m["action"].(string)
+		code, _ := m["code"].(string)
+
+		switch action {
+		case "generate":
+			if admin.Totp.IsEnabled(account) {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+
+			secret, err := admin.Totp.Generate(account, admin.Controller.Database)
+			if err != nil {
+				logError(err)
+				w.WriteHeader(http.StatusExpectationFailed)
+				return
+			}
+
+			send(map[string]interface{}{
+				"enabled": false,
+				"secret":  secret,
+				"uri":     GetTotpUri(user.Username, secret),
+			})
+
+		case "verify":
+			if err := admin.Totp.Enable(account, code, admin.Controller.Database); err != nil {
+				logError(err)
+				w.WriteHeader(http.StatusExpectationFailed)
+				return
+			}
+
+			admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("two-factor authentication enabled for user=\"%s\"", user.Username))
+
+			admin.Controller.AuditLog.Record(r, user, AuditLogActionTotpEnable, nil)
+
+			send(map[string]interface{}{"enabled": true})
+
+		case "disable":
+			if !admin.Totp.Validate(account, code) {
+				w.WriteHeader(http.StatusExpectationFailed)
+				return
+			}
+
+			if err := admin.Totp.Disable(account, admin.Controller.Database); err != nil {
+				logError(err)
+				w.WriteHeader(http.StatusExpectationFailed)
+				return
+			}
+
+			admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("two-factor authentication disabled for user=\"%s\"", user.Username))
+
+			admin.Controller.AuditLog.Record(r, user, AuditLogActionTotpDisable, nil)
+
+			send(map[string]interface{}{"enabled": false})
+
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (totp *AdminTotp) Disable(account string, db *Database) error {
+	totp.mutex.Lock()
+	delete(totp.Accounts, account)
+	totp.mutex.Unlock()
+
+	return totp.Write(db)
+}
+
+func (totp *AdminTotp) Enable(account string, code string, db *Database) error {
+	totp.mutex.Lock()
+
+	a, ok := totp.Accounts[account]
+	if !ok {
+		totp.mutex.Unlock()
+		return fmt.Errorf("admintotp.enable: no pending secret for %s", account)
+	}
+
+	if !a.check(code) {
+		totp.mutex.Unlock()
+		return fmt.Errorf("admintotp.enable: invalid code for %s", account)
+	}
+
+	a.Enabled = true
+
+	totp.mutex.Unlock()
+
+	return totp.Write(db)
+}
+
+func (totp *AdminTotp) Generate(account string, db *Database) (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("admintotp.generate: %v", err)
+	}
+
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+
+	totp.mutex.Lock()
+	totp.Accounts[account] = &AdminTotpAccount{Secret: secret}
+	totp.mutex.Unlock()
+
+	return secret, totp.Write(db)
+}
+
+func (totp *AdminTotp) IsEnabled(account string) bool {
+	totp.mutex.Lock()
+	defer totp.mutex.Unlock()
+
+	a, ok := totp.Accounts[account]
+
+	return ok && a.Enabled
+}
+
+func (totp *AdminTotp) Read(db *Database) error {
+	var s string
+
+	totp.mutex.Lock()
+	defer totp.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("admintotp.read: %v", err)
+	}
+
+	totp.Accounts = map[string]*AdminTotpAccount{}
+
+	if err := db.Sql.QueryRow("select `val` from `rdioScannerConfigs` where `key` = 'totp'").Scan(&s); err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return formatError(err)
+	}
+
+	if err := json.Unmarshal([]byte(s), &totp.Accounts); err != nil {
+		return formatError(err)
+	}
+
+	return nil
+}
+
+func (totp *AdminTotp) Validate(account string, code string) bool {
+	totp.mutex.Lock()
+	defer totp.mutex.Unlock()
+
+	a, ok := totp.Accounts[account]
+
+	return ok && a.Enabled && a.check(code)
+}
+
+func (totp *AdminTotp) Write(db *Database) error {
+	var (
+		b   []byte
+		err error
+		i   int64
+		res sql.Result
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("admintotp.write: %v", err)
+	}
+
+	totp.mutex.Lock()
+	b, err = json.Marshal(totp.Accounts)
+	totp.mutex.Unlock()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if res, err = db.Sql.Exec("update `rdioScannerConfigs` set `val` = ? where `key` = 'totp'", string(b)); err != nil {
+		return formatError(err)
+	}
+
+	if i, err = res.RowsAffected(); err == nil && i == 0 {
+		if _, err = db.Sql.Exec("insert into `rdioScannerConfigs` (`key`, `val`) values (?, ?)", "totp", string(b)); err != nil {
+			return formatError(err)
+		}
+	}
+
+	return nil
+}
+
+func (account *AdminTotpAccount) check(code string) bool {
+	code = strings.ReplaceAll(code, " ", "")
+
+	if len(code) != TotpDigits {
+		return false
+	}
+
+	counter := time.Now().Unix() / TotpPeriod
+
+	for i := int64(-TotpSkew); i <= TotpSkew; i++ {
+		if counter+i <= account.last {
+			continue
+		}
+
+		if c, err := GetTotpCode(account.Secret, counter+i); err == nil && hmac.Equal([]byte(c), []byte(code)) {
+			account.last = counter + i
+			return true
+		}
+	}
+
+	return false
+}
+
+func (user *AdminUser) GetTotpAccount() string {
+	switch {
+	case user.Id != nil:
+		return fmt.Sprintf("user:%s", user.Username)
+	case user.sso:
+		return ""
+	default:
+		return "admin"
+	}
+}
+
+func GetTotpCode(secret string, counter int64) (string, error) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	if len(key) == 0 {
+		return "", errors.New("empty totp secret")
+	}
+
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", TotpDigits, value%1000000), nil
+}
+
+func GetTotpUri(username string, secret string) string {
+	v := url.Values{}
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprintf("%d", TotpDigits))
+	v.Set("issuer", TotpIssuer)
+	v.Set("period", fmt.Sprintf("%d", TotpPeriod))
+	v.Set("secret", secret)
+
+	return fmt.Sprintf("otpauth://totp/%s?%s", url.PathEscape(fmt.Sprintf("%s:%s", TotpIssuer, username)), v.Encode())
+}
@@ -0,0 +1,400 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	TranscriptionGoogle     string = "google"
+	TranscriptionWebhook    string = "webhook"
+	TranscriptionWhisper    string = "whisper"
+	TranscriptionWhisperApi string = "whisper-api"
+
+	TranscriptionGoogleUrl     = "https://speech.googleapis.com/v1/speech:recognize"
+	TranscriptionWhisperApiUrl = "https://api.openai.com/v1/audio/transcriptions"
+
+	TranscriptionQueueSize = 256
+	TranscriptionTimeout   = 2 * time.Minute
+)
+
+type Transcriber struct {
+	controller *Controller
+	client     *http.Client
+	queue      chan *Call
+}
+
+func NewTranscriber(controller *Controller) *Transcriber {
+	return &Transcriber{
+		controller: controller,
+		client:     &http.Client{Timeout: TranscriptionTimeout},
+		queue:      make(chan *Call, TranscriptionQueueSize),
+	}
+}
+
+func (transcriber *Transcriber) IsEnabled() bool {
+	switch transcriber.controller.Options.Transcription {
+	case TranscriptionGoogle, TranscriptionWebhook, TranscriptionWhisper, TranscriptionWhisperApi:
+		return true
+	default:
+		return false
+	}
+}
+
+func (transcriber *Transcriber) Start() {
+	go func() {
+		for call := range transcriber.queue {
+			transcriber.process(call)
+		}
+	}()
+}
+
+func (transcriber *Transcriber) Submit(call *Call) {
+	if !transcriber.IsEnabled() || len(call.transcript) > 0 || len(call.Audio) == 0 {
+		return
+	}
+
+	c := *call
+
+	select {
+	case transcriber.queue <- &c:
+	default:
+		transcriber.controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("transcriber.submit: queue is full, call %v will not be transcribed", call.Id))
+	}
+}
+
+func (transcriber *Transcriber) Transcribe(call *Call) (string, error) {
+	var (
+		err        error
+		transcript string
+	)
+
+	switch transcriber.controller.Options.Transcription {
+	case TranscriptionGoogle:
+		transcript, err = transcriber.transcribeGoogle(call)
+	case TranscriptionWebhook:
+		transcript, err = transcriber.transcribeWebhook(call)
+	case TranscriptionWhisper:
+		transcript, err = transcriber.transcribeWhisper(call)
+	case TranscriptionWhisperApi:
+		transcript, err = transcriber.transcribeWhisperApi(call)
+	default:
+		err = fmt.Errorf("unknown transcription engine %s", transcriber.controller.Options.Transcription)
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("transcriber.transcribe: %v", err)
+	}
+
+	return strings.Join(strings.Fields(transcript), " "), nil
+}
+
+func (transcriber *Transcriber) process(call *Call) {
+	id, ok := call.Id.(uint)
+	if !ok {
+		return
+	}
+
+	transcript, err := transcriber.Transcribe(call)
+	if err != nil {
+		transcriber.controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("call %v: %v", id, err))
+		return
+	}
+
+	if len(transcript) == 0 {
+		return
+	}
+
+	if err = transcriber.controller.Calls.WriteTranscript(id, transcript, transcriber.controller.Database); err != nil {
+		transcriber.controller.Logs.LogEvent(LogLevelError, err.Error())
+		return
+	}
+
+	call.transcript = transcript
+
+	transcriber.controller.Clients.EmitTranscript(call, transcriber.controller.Accesses.IsRestricted())
+
+	transcriber.controller.Alerts.CheckTranscript(call)
+}
+
+func (transcriber *Transcriber) postJson(u string, header http.Header, body interface{}, result interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return transcriber.send(req, result)
+}
+
+func (transcriber *Transcriber) send(req *http.Request, result interface{}) error {
+	res, err := transcriber.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s: %s", req.URL.Host, res.Status, getFFMpegStderrTail(string(b), 1))
+	}
+
+	return json.Unmarshal(b, result)
+}
+
+func (transcriber *Transcriber) transcribeGoogle(call *Call) (string, error) {
+	var result struct {
+		Results []struct {
+			Alternatives []struct {
+				Transcript string `json:"transcript"`
+			} `json:"alternatives"`
+		} `json:"results"`
+	}
+
+	options := transcriber.controller.Options
+
+	wav, err := transcriber.controller.FFMpeg.ToWav(call.Audio)
+	if err != nil {
+		return "", err
+	}
+
+	u := options.TranscriptionUrl
+	if len(u) == 0 {
+		u = TranscriptionGoogleUrl
+	}
+
+	if len(options.TranscriptionApiKey) > 0 {
+		if p, err := url.Parse(u); err == nil {
+			q := p.Query()
+			q.Set("key", options.TranscriptionApiKey)
+			p.RawQuery = q.Encode()
+			u = p.String()
+		}
+	}
+
+	body := map[string]interface{}{
+		"audio": map[string]interface{}{
+			"content": base64.StdEncoding.EncodeToString(wav),
+		},
+		"config": map[string]interface{}{
+			"encoding":        "LINEAR16",
+			"languageCode":    options.TranscriptionLanguage,
+			"sampleRateHertz": 16000,
+		},
+	}
+
+	if err = transcriber.postJson(u, http.Header{}, body, &result); err != nil {
+		return "", err
+	}
+
+	a := []string{}
+	for _, r := range result.Results {
+		if len(r.Alternatives) > 0 {
+			a = append(a, r.Alternatives[0].Transcript)
+		}
+	}
+
+	return strings.Join(a, " "), nil
+}
+
+func (transcriber *Transcriber) transcribeWebhook(call *Call) (string, error) {
+	var result struct {
+		Text       string `json:"text"`
+		Transcript string `json:"transcript"`
+	}
+
+	options := transcriber.controller.Options
+
+	if len(options.TranscriptionUrl) == 0 {
+		return "", errors.New("no webhook url defined")
+	}
+
+	header := http.Header{}
+	if len(options.TranscriptionApiKey) > 0 {
+		header.Set("Authorization", fmt.Sprintf("Bearer %s", options.TranscriptionApiKey))
+	}
+
+	body := map[string]interface{}{
+		"audio":          base64.StdEncoding.EncodeToString(call.Audio),
+		"audioName":      call.AudioName,
+		"audioType":      call.AudioType,
+		"dateTime":       call.DateTime,
+		"id":             call.Id,
+		"language":       options.TranscriptionLanguage,
+		"system":         call.System,
+		"systemLabel":    call.systemLabel,
+		"talkgroup":      call.Talkgroup,
+		"talkgroupLabel": call.talkgroupLabel,
+	}
+
+	if err := transcriber.postJson(options.TranscriptionUrl, header, body, &result); err != nil {
+		return "", err
+	}
+
+	if len(result.Transcript) > 0 {
+		return result.Transcript, nil
+	}
+
+	return result.Text, nil
+}
+
+func (transcriber *Transcriber) transcribeWhisper(call *Call) (string, error) {
+	options := transcriber.controller.Options
+
+	args := strings.Fields(options.TranscriptionCommand)
+	if len(args) == 0 {
+		return "", errors.New("no whisper command defined")
+	}
+
+	wav, err := transcriber.controller.FFMpeg.ToWav(call.Audio)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp("", "rdio-scanner-*.wav")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(wav)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	found := false
+	for i, arg := range args {
+		if strings.Contains(arg, "{file}") {
+			args[i] = strings.ReplaceAll(arg, "{file}", f.Name())
+			found = true
+		}
+	}
+	if !found {
+		args = append(args, f.Name())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), TranscriptionTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+
+	stderr := bytes.NewBuffer(nil)
+	cmd.Stderr = stderr
+
+	b, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%s timed out after %v", args[0], TranscriptionTimeout)
+		}
+		return "", fmt.Errorf("%s: %v: %s", args[0], err, getFFMpegStderrTail(stderr.String(), 3))
+	}
+
+	return string(b), nil
+}
+
+func (transcriber *Transcriber) transcribeWhisperApi(call *Call) (string, error) {
+	var result struct {
+		Text string `json:"text"`
+	}
+
+	options := transcriber.controller.Options
+
+	u := options.TranscriptionUrl
+	if len(u) == 0 {
+		u = TranscriptionWhisperApiUrl
+	}
+
+	name := "audio"
+	switch v := call.AudioName.(type) {
+	case string:
+		if len(v) > 0 {
+			name = path.Base(v)
+		}
+	}
+
+	body := bytes.NewBuffer(nil)
+	mw := multipart.NewWriter(body)
+
+	fw, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		return "", err
+	}
+	if _, err = fw.Write(call.Audio); err != nil {
+		return "", err
+	}
+
+	fields := map[string]string{
+		"language":        options.TranscriptionLanguage,
+		"model":           options.TranscriptionModel,
+		"response_format": "json",
+	}
+	for k, v := range fields {
+		if len(v) > 0 {
+			if err = mw.WriteField(k, v); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	if err = mw.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u, body)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if len(options.TranscriptionApiKey) > 0 {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", options.TranscriptionApiKey))
+	}
+
+	if err = transcriber.send(req, &result); err != nil {
+		return "", err
+	}
+
+	return result.Text, nil
+}
@@ -113,6 +113,19 @@ func (u *Units) Merge(units *Units) bool {
 	return merged
 }
 
+func (units *Units) GetUnit(id uint) (*Unit, bool) {
+	units.mutex.Lock()
+	defer units.mutex.Unlock()
+
+	for _, unit := range units.List {
+		if unit.Id == id {
+			return unit, true
+		}
+	}
+
+	return nil, false
+}
+
 func (units *Units) Read(db *Database, systemId uint) error {
 	var (
 		err  error
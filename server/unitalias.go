@@ -0,0 +1,403 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	UnitAliasMaxRange = 10000
+	UnitAliasMaxBatch = 500
+)
+
+type UnitAliasChange struct {
+	Added    bool   `json:"added,omitempty"`
+	Id       uint   `json:"id"`
+	Label    string `json:"label"`
+	Previous string `json:"previous,omitempty"`
+	Removed  bool   `json:"removed,omitempty"`
+	System   uint   `json:"system"`
+}
+
+type UnitAliasRange struct {
+	From      uint   `json:"from"`
+	Label     string `json:"label"`
+	Overwrite bool   `json:"overwrite"`
+	To        uint   `json:"to"`
+}
+
+type UnitAliasRename struct {
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace"`
+}
+
+type UnitAliasRequest struct {
+	DryRun      bool              `json:"dryRun"`
+	Ranges      []UnitAliasRange  `json:"ranges"`
+	Renames     []UnitAliasRename `json:"renames"`
+	Retroactive bool              `json:"retroactive"`
+	System      uint              `json:"system"`
+}
+
+func (req *UnitAliasRequest) GetChanges(systems []*System) ([]UnitAliasChange, error) {
+	changes := []UnitAliasChange{}
+
+	renames := []*regexp.Regexp{}
+	for _, rename := range req.Renames {
+		re, err := regexp.Compile(rename.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s", rename.Pattern)
+		}
+		renames = append(renames, re)
+	}
+
+	for _, r := range req.Ranges {
+		if r.From == 0 || r.To < r.From {
+			return nil, fmt.Errorf("invalid range %d-%d", r.From, r.To)
+		}
+		if r.To-r.From >= UnitAliasMaxRange {
+			return nil, fmt.Errorf("range %d-%d exceeds %d units", r.From, r.To, UnitAliasMaxRange)
+		}
+		if len(strings.TrimSpace(r.Label)) == 0 {
+			return nil, fmt.Errorf("no label for range %d-%d", r.From, r.To)
+		}
+	}
+
+	for _, system := range systems {
+		if req.System > 0 && system.Id != req.System {
+			continue
+		}
+
+		labels := map[uint]string{}
+		ids := []uint{}
+		previous := map[uint]string{}
+
+		system.Units.mutex.Lock()
+		for _, unit := range system.Units.List {
+			labels[unit.Id] = unit.Label
+			previous[unit.Id] = unit.Label
+			ids = append(ids, unit.Id)
+		}
+		system.Units.mutex.Unlock()
+
+		for _, r := range req.Ranges {
+			for id := r.From; id <= r.To; id++ {
+				label := strings.ReplaceAll(r.Label, "{id}", strconv.Itoa(int(id)))
+
+				if current, ok := labels[id]; !ok {
+					ids = append(ids, id)
+				} else if !r.Overwrite && len(current) > 0 && current != strconv.Itoa(int(id)) {
+					continue
+				}
+
+				labels[id] = label
+			}
+		}
+
+		for i, re := range renames {
+			for _, id := range ids {
+				if re.MatchString(labels[id]) {
+					labels[id] = re.ReplaceAllString(labels[id], req.Renames[i].Replace)
+				}
+			}
+		}
+
+		for _, id := range ids {
+			label, ok := previous[id]
+			if ok && label == labels[id] {
+				continue
+			}
+
+			change := UnitAliasChange{Added: !ok, Id: id, Label: labels[id], System: system.Id}
+			if ok {
+				change.Previous = label
+			}
+
+			changes = append(changes, change)
+		}
+	}
+
+	sort.SliceStable(changes, func(i int, j int) bool {
+		if changes[i].System == changes[j].System {
+			return changes[i].Id < changes[j].Id
+		}
+		return changes[i].System < changes[j].System
+	})
+
+	return changes, nil
+}
+
+func (units *Units) SetLabel(id uint, label string) {
+	units.mutex.Lock()
+	defer units.mutex.Unlock()
+
+	for _, unit := range units.List {
+		if unit.Id == id {
+			unit.Label = label
+			return
+		}
+	}
+
+	units.List = append(units.List, &Unit{Id: id, Label: label, Order: uint(len(units.List) + 1)})
+}
+
+func (calls *Calls) WriteUnitLabels(system uint, labels map[uint]string, db *Database) (uint, error) {
+	var (
+		count   uint
+		err     error
+		id      uint
+		lastId  uint
+		rows    *sql.Rows
+		updates = map[uint]string{}
+	)
+
+	formatError := func(err error) error {
+		return fmt.Errorf("calls.writeunitlabels: %v", err)
+	}
+
+	for {
+		var (
+			fetched uint
+			sources string
+		)
+
+		rows, err = db.Sql.Query("select `id`, `sources` from `rdioScannerCalls` where `system` = ? and `id` > ? order by `id` limit ?", system, lastId, UnitAliasMaxBatch)
+		if err != nil {
+			return count, formatError(err)
+		}
+
+		for rows.Next() {
+			if err = rows.Scan(&id, &sources); err != nil {
+				break
+			}
+
+			fetched++
+			lastId = id
+
+			entries := []map[string]interface{}{}
+			if json.Unmarshal([]byte(sources), &entries) != nil {
+				continue
+			}
+
+			changed := false
+			for _, entry := range entries {
+				src, ok := getCallNumber(entry["src"])
+				if !ok {
+					continue
+				}
+				if label, ok := labels[uint(src)]; ok && entry["tag"] != label {
+					entry["tag"] = label
+					changed = true
+				}
+			}
+
+			if changed {
+				if b, err := json.Marshal(entries); err == nil {
+					updates[id] = string(b)
+				}
+			}
+		}
+
+		rows.Close()
+
+		if err != nil {
+			return count, formatError(err)
+		}
+
+		calls.mutex.Lock()
+		for id, sources := range updates {
+			if _, err = db.Sql.Exec("update `rdioScannerCalls` set `sources` = ? where `id` = ?", sources, id); err != nil {
+				break
+			}
+			count++
+		}
+		calls.mutex.Unlock()
+
+		if err != nil {
+			return count, formatError(err)
+		}
+
+		updates = map[uint]string{}
+
+		if fetched < UnitAliasMaxBatch {
+			break
+		}
+	}
+
+	return count, nil
+}
+
+func (admin *Admin) UnitAliasesHandler(w http.ResponseWriter, r *http.Request) {
+	var req UnitAliasRequest
+
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.unitaliaseshandler: %s", err.Error()))
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+	if !req.DryRun {
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if !admin.CheckWritable(w) {
+			return
+		}
+	}
+
+	admin.mutex.Lock()
+
+	changes, err := req.GetChanges(admin.Controller.Systems.List)
+	if err != nil {
+		admin.mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	res := map[string]interface{}{
+		"changes": changes,
+		"dryRun":  req.DryRun,
+	}
+
+	if !req.DryRun && len(changes) > 0 {
+		labels := map[uint]map[uint]string{}
+
+		for _, change := range changes {
+			if labels[change.System] == nil {
+				labels[change.System] = map[uint]string{}
+			}
+			labels[change.System][change.Id] = change.Label
+		}
+
+		for _, system := range admin.Controller.Systems.List {
+			if labels[system.Id] == nil {
+				continue
+			}
+
+			for id, label := range labels[system.Id] {
+				system.Units.SetLabel(id, label)
+			}
+
+			if err = system.Units.Write(admin.Controller.Database, system.Id); err != nil {
+				break
+			}
+		}
+
+		if err != nil {
+			admin.mutex.Unlock()
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		revision, err := admin.IncrementRevision()
+		if err != nil {
+			logError(err)
+		}
+
+		admin.mutex.Unlock()
+
+		admin.BroadcastNotice(map[string]interface{}{
+			"message":  "configuration changed by someone else",
+			"revision": revision,
+			"sections": []string{"systems"},
+		})
+
+		admin.Controller.EmitConfig()
+
+		admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("%d unit labels changed by user=\"%s\"", len(changes), user.Username))
+
+		admin.Controller.AuditLog.Record(r, user, AuditLogActionUnitsAlias, GetUnitAliasAuditLogChanges(changes))
+
+		if req.Retroactive {
+			go func() {
+				var count uint
+
+				for system, l := range labels {
+					n, err := admin.Controller.Calls.WriteUnitLabels(system, l, admin.Controller.Database)
+					count += n
+					if err != nil {
+						logError(err)
+						break
+					}
+				}
+
+				admin.Controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("unit labels updated in %d calls", count))
+			}()
+		}
+
+		res["revision"] = revision
+
+	} else {
+		admin.mutex.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		logError(err)
+	}
+}
+
+func GetUnitAliasAuditLogChanges(changes []UnitAliasChange) []AuditLogChange {
+	auditChanges := []AuditLogChange{}
+
+	for _, change := range changes {
+		if len(auditChanges) >= AuditLogMaxChanges {
+			break
+		}
+
+		path := fmt.Sprintf("systems[id=%d].units[id=%d].label", change.System, change.Id)
+
+		switch {
+		case change.Added:
+			auditChanges = append(auditChanges, AuditLogChange{Op: "add", Path: path, To: change.Label})
+		case change.Removed:
+			auditChanges = append(auditChanges, AuditLogChange{Op: "remove", Path: path, From: change.Previous})
+		default:
+			auditChanges = append(auditChanges, AuditLogChange{Op: "replace", Path: path, From: change.Previous, To: change.Label})
+		}
+	}
+
+	return auditChanges
+}
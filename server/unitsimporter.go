@@ -0,0 +1,437 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	UnitsImportConflictKeep      = "keep"
+	UnitsImportConflictOverwrite = "overwrite"
+	UnitsImportConflictReplace   = "replace"
+	UnitsImportInterval          = 10 * time.Minute
+	UnitsImportMaxSize           = 16 << 20
+)
+
+var (
+	unitsImportIdColumns    = []string{"radioid", "unitid", "id", "rid", "uid", "dec", "decimal"}
+	unitsImportLabelColumns = []string{"alias", "alphatag", "description", "label", "name", "tag"}
+)
+
+type UnitsImportRequest struct {
+	Conflict string `json:"conflict"`
+	Csv      string `json:"csv"`
+	DryRun   bool   `json:"dryRun"`
+	System   uint   `json:"system"`
+	Url      string `json:"url"`
+}
+
+type UnitsImporter struct {
+	client     *http.Client
+	controller *Controller
+	imported   map[uint]time.Time
+	mutex      sync.Mutex
+	ticker     *time.Ticker
+}
+
+func NewUnitsImporter(controller *Controller) *UnitsImporter {
+	return &UnitsImporter{
+		client:     &http.Client{Timeout: time.Minute},
+		controller: controller,
+		imported:   map[uint]time.Time{},
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (importer *UnitsImporter) Fetch(url string) (map[uint]string, error) {
+	formatError := func(err error) error {
+		return fmt.Errorf("unitsimporter.fetch: %v", err)
+	}
+
+	res, err := importer.client.Get(url)
+	if err != nil {
+		return nil, formatError(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, formatError(fmt.Errorf("%s returned %s", url, res.Status))
+	}
+
+	labels, err := ParseUnitsCsv(io.LimitReader(res.Body, UnitsImportMaxSize))
+	if err != nil {
+		return nil, formatError(err)
+	}
+
+	return labels, nil
+}
+
+func (importer *UnitsImporter) GetChanges(system *System, labels map[uint]string, conflict string) []UnitAliasChange {
+	changes := []UnitAliasChange{}
+
+	current := map[uint]string{}
+
+	system.Units.mutex.Lock()
+	for _, unit := range system.Units.List {
+		current[unit.Id] = unit.Label
+	}
+	system.Units.mutex.Unlock()
+
+	for id, label := range labels {
+		previous, ok := current[id]
+
+		if !ok {
+			changes = append(changes, UnitAliasChange{Added: true, Id: id, Label: label, System: system.Id})
+			continue
+		}
+
+		if previous == label {
+			continue
+		}
+
+		keep := conflict != UnitsImportConflictOverwrite && conflict != UnitsImportConflictReplace
+
+		if keep && len(previous) > 0 && previous != strconv.Itoa(int(id)) {
+			continue
+		}
+
+		changes = append(changes, UnitAliasChange{Id: id, Label: label, Previous: previous, System: system.Id})
+	}
+
+	if conflict == UnitsImportConflictReplace {
+		for id, label := range current {
+			if _, ok := labels[id]; !ok {
+				changes = append(changes, UnitAliasChange{Id: id, Previous: label, Removed: true, System: system.Id})
+			}
+		}
+	}
+
+	sort.Slice(changes, func(i int, j int) bool {
+		return changes[i].Id < changes[j].Id
+	})
+
+	return changes
+}
+
+func (importer *UnitsImporter) Import(system *System, labels map[uint]string, conflict string) ([]UnitAliasChange, uint, error) {
+	var revision uint
+
+	admin := importer.controller.Admin
+
+	admin.mutex.Lock()
+
+	changes := importer.GetChanges(system, labels, conflict)
+
+	if len(changes) == 0 {
+		admin.mutex.Unlock()
+		return changes, revision, nil
+	}
+
+	for _, change := range changes {
+		if change.Removed {
+			system.Units.Remove(change.Id)
+		} else {
+			system.Units.SetLabel(change.Id, change.Label)
+		}
+	}
+
+	if err := system.Units.Write(importer.controller.Database, system.Id); err != nil {
+		admin.mutex.Unlock()
+		return nil, revision, fmt.Errorf("unitsimporter.import: %v", err)
+	}
+
+	revision, err := admin.IncrementRevision()
+	if err != nil {
+		importer.controller.Logs.LogEvent(LogLevelError, err.Error())
+	}
+
+	admin.mutex.Unlock()
+
+	admin.BroadcastNotice(map[string]interface{}{
+		"message":  "configuration changed by someone else",
+		"revision": revision,
+		"sections": []string{"systems"},
+	})
+
+	importer.controller.EmitConfig()
+
+	return changes, revision, nil
+}
+
+func (importer *UnitsImporter) Start() {
+	run := func() {
+		controller := importer.controller
+
+		if controller.Options.UnitsImportInterval == 0 {
+			return
+		}
+
+		if ok, err := controller.Leases.Acquire(controller.Database, "unitsImporter", LeaseTimeout); !ok {
+			if err != nil {
+				controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("unitsimporter.start: %v", err))
+			}
+			return
+		}
+
+		interval := time.Duration(controller.Options.UnitsImportInterval) * time.Hour
+
+		for _, system := range controller.Systems.List {
+			if len(system.UnitsImportUrl) == 0 {
+				continue
+			}
+
+			importer.mutex.Lock()
+			if t, ok := importer.imported[system.Id]; ok && time.Since(t) < interval {
+				importer.mutex.Unlock()
+				continue
+			}
+			importer.imported[system.Id] = time.Now()
+			importer.mutex.Unlock()
+
+			labels, err := importer.Fetch(system.UnitsImportUrl)
+			if err != nil {
+				controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("units import: system=%d %v", system.Id, err))
+				continue
+			}
+
+			changes, _, err := importer.Import(system, labels, system.UnitsImportConflict)
+			if err != nil {
+				controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("units import: system=%d %v", system.Id, err))
+				continue
+			}
+
+			if len(changes) > 0 {
+				controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("units import: system=%d %d unit aliases changed", system.Id, len(changes)))
+			}
+		}
+	}
+
+	importer.ticker = time.NewTicker(UnitsImportInterval)
+
+	go func() {
+		for range importer.ticker.C {
+			run()
+		}
+	}()
+}
+
+func (units *Units) Remove(id uint) {
+	units.mutex.Lock()
+	defer units.mutex.Unlock()
+
+	for i, unit := range units.List {
+		if unit.Id == id {
+			units.List = append(units.List[:i], units.List[i+1:]...)
+			return
+		}
+	}
+}
+
+func (admin *Admin) UnitsImportHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		err    error
+		labels map[uint]string
+		req    UnitsImportRequest
+	)
+
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.unitsimporthandler: %s", err.Error()))
+	}
+
+	writeError := func(err error) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err = json.NewDecoder(io.LimitReader(r.Body, UnitsImportMaxSize)).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	user, ok := admin.ValidateTokenRole(t, AdminRoleEditor)
+	if !req.DryRun {
+		if !ok {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if !admin.CheckWritable(w) {
+			return
+		}
+	}
+
+	system, ok := admin.Controller.Systems.GetSystem(req.System)
+	if !ok {
+		writeError(fmt.Errorf("unknown system %d", req.System))
+		return
+	}
+
+	if len(req.Conflict) == 0 {
+		req.Conflict = system.UnitsImportConflict
+	}
+
+	switch req.Conflict {
+	case "":
+		req.Conflict = UnitsImportConflictKeep
+	case UnitsImportConflictKeep, UnitsImportConflictOverwrite, UnitsImportConflictReplace:
+	default:
+		writeError(fmt.Errorf("invalid conflict rule %s", req.Conflict))
+		return
+	}
+
+	if len(req.Csv) > 0 {
+		labels, err = ParseUnitsCsv(strings.NewReader(req.Csv))
+	} else {
+		if len(req.Url) == 0 {
+			req.Url = system.UnitsImportUrl
+		}
+		if len(req.Url) == 0 {
+			writeError(errors.New("no csv and no url to import from"))
+			return
+		}
+		labels, err = admin.Controller.UnitsImporter.Fetch(req.Url)
+	}
+
+	if err != nil {
+		writeError(err)
+		return
+	}
+
+	res := map[string]interface{}{
+		"dryRun": req.DryRun,
+		"units":  len(labels),
+	}
+
+	if req.DryRun {
+		res["changes"] = admin.Controller.UnitsImporter.GetChanges(system, labels, req.Conflict)
+
+	} else {
+		changes, revision, err := admin.Controller.UnitsImporter.Import(system, labels, req.Conflict)
+		if err != nil {
+			logError(err)
+			w.WriteHeader(http.StatusExpectationFailed)
+			return
+		}
+
+		if len(changes) > 0 {
+			admin.Controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("%d unit aliases imported by user=\"%s\"", len(changes), user.Username))
+			admin.Controller.AuditLog.Record(r, user, AuditLogActionUnitsImport, GetUnitAliasAuditLogChanges(changes))
+			res["revision"] = revision
+		}
+
+		res["changes"] = changes
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err = json.NewEncoder(w).Encode(res); err != nil {
+		logError(err)
+	}
+}
+
+func ParseUnitsCsv(r io.Reader) (map[uint]string, error) {
+	idCol, labelCol := 0, 1
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) > 0 && len(records[0]) > 0 {
+		if _, err := strconv.ParseUint(strings.TrimSpace(records[0][0]), 10, 64); err != nil {
+			header := map[string]int{}
+			for i, name := range records[0] {
+				name = strings.ToLower(name)
+				name = strings.NewReplacer(" ", "", "_", "", "-", "", "\ufeff", "").Replace(name)
+				if _, ok := header[name]; !ok {
+					header[name] = i
+				}
+			}
+
+			for _, name := range unitsImportIdColumns {
+				if i, ok := header[name]; ok {
+					idCol = i
+					break
+				}
+			}
+
+			for _, name := range unitsImportLabelColumns {
+				if i, ok := header[name]; ok && i != idCol {
+					labelCol = i
+					break
+				}
+			}
+
+			records = records[1:]
+		}
+	}
+
+	labels := map[uint]string{}
+
+	for _, record := range records {
+		if idCol >= len(record) || labelCol >= len(record) {
+			continue
+		}
+
+		id, err := strconv.ParseUint(strings.TrimSpace(record[idCol]), 10, 64)
+		if err != nil || id == 0 {
+			continue
+		}
+
+		label := strings.TrimSpace(record[labelCol])
+		if len(label) == 0 {
+			continue
+		}
+
+		if _, ok := labels[uint(id)]; !ok {
+			labels[uint(id)] = label
+		}
+	}
+
+	if len(labels) == 0 {
+		return nil, errors.New("no unit found")
+	}
+
+	return labels, nil
+}
@@ -0,0 +1,150 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	UpdaterCheckInterval = 24 * time.Hour
+	UpdaterReleasesUrl   = "https://api.github.com/repos/chuot/rdio-scanner/releases/latest"
+)
+
+type Updater struct {
+	CheckedAt     time.Time
+	LatestUrl     string
+	LatestVersion string
+	mutex         sync.Mutex
+}
+
+func NewUpdater() *Updater {
+	return &Updater{
+		mutex: sync.Mutex{},
+	}
+}
+
+func (updater *Updater) Check() error {
+	var release struct {
+		HtmlUrl string `json:"html_url"`
+		TagName string `json:"tag_name"`
+	}
+
+	updater.mutex.Lock()
+	defer updater.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("updater.check: %v", err)
+	}
+
+	updater.CheckedAt = time.Now()
+
+	req, err := http.NewRequest(http.MethodGet, UpdaterReleasesUrl, nil)
+	if err != nil {
+		return formatError(err)
+	}
+
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("User-Agent", fmt.Sprintf("rdio-scanner/%s", Version))
+
+	c := http.Client{Timeout: 10 * time.Second}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return formatError(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return formatError(fmt.Errorf("bad status: %s", res.Status))
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(&release); err != nil {
+		return formatError(err)
+	}
+
+	updater.LatestUrl = release.HtmlUrl
+	updater.LatestVersion = regexp.MustCompile(`^v`).ReplaceAllString(release.TagName, "")
+
+	return nil
+}
+
+func (updater *Updater) IsDue() bool {
+	updater.mutex.Lock()
+	defer updater.mutex.Unlock()
+
+	return time.Since(updater.CheckedAt) >= UpdaterCheckInterval
+}
+
+func (updater *Updater) IsUpdateAvailable() bool {
+	updater.mutex.Lock()
+	defer updater.mutex.Unlock()
+
+	return CompareVersions(updater.LatestVersion, Version) > 0
+}
+
+func (updater *Updater) ToMap() map[string]interface{} {
+	updater.mutex.Lock()
+	defer updater.mutex.Unlock()
+
+	m := map[string]interface{}{
+		"available": CompareVersions(updater.LatestVersion, Version) > 0,
+		"current":   Version,
+	}
+
+	if len(updater.LatestVersion) > 0 {
+		m["checkedAt"] = updater.CheckedAt.UTC().Format(time.RFC3339)
+		m["latest"] = updater.LatestVersion
+		m["url"] = updater.LatestUrl
+	}
+
+	return m
+}
+
+func CompareVersions(a string, b string) int {
+	re := regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?`)
+
+	parse := func(s string) []int {
+		v := []int{0, 0, 0}
+		if m := re.FindStringSubmatch(s); m != nil {
+			for i := 1; i < len(m); i++ {
+				if n, err := strconv.Atoi(m[i]); err == nil {
+					v[i-1] = n
+				}
+			}
+		}
+		return v
+	}
+
+	va := parse(a)
+	vb := parse(b)
+
+	for i := range va {
+		if va[i] > vb[i] {
+			return 1
+		} else if va[i] < vb[i] {
+			return -1
+		}
+	}
+
+	return 0
+}
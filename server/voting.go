@@ -0,0 +1,141 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+type Ballot struct {
+	Calls []*Call
+	timer *time.Timer
+}
+
+type Voter struct {
+	Ballots    []*Ballot
+	controller *Controller
+	mutex      sync.Mutex
+}
+
+func NewVoter(controller *Controller) *Voter {
+	return &Voter{
+		Ballots:    []*Ballot{},
+		controller: controller,
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (voter *Voter) Elect(ballot *Ballot) {
+	voter.mutex.Lock()
+
+	for i, b := range voter.Ballots {
+		if b == ballot {
+			voter.Ballots = append(voter.Ballots[:i], voter.Ballots[i+1:]...)
+			break
+		}
+	}
+
+	voter.mutex.Unlock()
+
+	if len(ballot.Calls) == 0 {
+		return
+	}
+
+	winner := ballot.Calls[0]
+	for _, call := range ballot.Calls[1:] {
+		if GetVotingScore(call) > GetVotingScore(winner) {
+			winner = call
+		}
+	}
+
+	alternates := []*Call{}
+	for _, call := range ballot.Calls {
+		if call != winner {
+			alternates = append(alternates, call)
+		}
+	}
+
+	winner.alternates = alternates
+	winner.voted = true
+
+	if len(alternates) > 0 {
+		voter.controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("voter: system=%v talkgroup=%v site=%v elected among %v copies", winner.System, winner.Talkgroup, winner.site, len(ballot.Calls)))
+	}
+
+	voter.controller.IngestCall(winner)
+}
+
+func (voter *Voter) Submit(call *Call, window uint) {
+	voter.mutex.Lock()
+	defer voter.mutex.Unlock()
+
+	frame := time.Duration(window) * time.Millisecond
+
+	for _, ballot := range voter.Ballots {
+		first := ballot.Calls[0]
+		if first.System == call.System && first.Talkgroup == call.Talkgroup {
+			d := first.DateTime.Sub(call.DateTime)
+			if d < 0 {
+				d = -d
+			}
+			if d <= frame {
+				ballot.Calls = append(ballot.Calls, call)
+				return
+			}
+		}
+	}
+
+	ballot := &Ballot{Calls: []*Call{call}}
+	ballot.timer = time.AfterFunc(frame, func() {
+		voter.Elect(ballot)
+	})
+
+	voter.Ballots = append(voter.Ballots, ballot)
+}
+
+func GetVotingScore(call *Call) float64 {
+	var (
+		errors uint
+		score  float64
+	)
+
+	switch v := call.signal.(type) {
+	case float64:
+		score += v * 1000
+	}
+
+	switch v := call.Frequencies.(type) {
+	case []map[string]interface{}:
+		for _, f := range v {
+			switch c := f["errorCount"].(type) {
+			case uint:
+				errors += c
+			}
+			switch c := f["spikeCount"].(type) {
+			case uint:
+				errors += c
+			}
+		}
+	}
+
+	score -= float64(errors)
+	score += math.Log1p(float64(call.GetAudioSize()))
+
+	return score
+}
@@ -0,0 +1,176 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	WatchdogCheckInterval = 15 * time.Second
+	WatchdogHeartbeat     = 10 * time.Second
+)
+
+type Watchdog struct {
+	Controller *Controller
+	busy       map[string]time.Time
+	issues     map[string]string
+	mutex      sync.Mutex
+	ticker     *time.Ticker
+}
+
+func NewWatchdog(controller *Controller) *Watchdog {
+	return &Watchdog{
+		Controller: controller,
+		busy:       map[string]time.Time{},
+		issues:     map[string]string{},
+		mutex:      sync.Mutex{},
+	}
+}
+
+func (watchdog *Watchdog) Check() {
+	var (
+		controller = watchdog.Controller
+		issues     = map[string]string{}
+		restart    = []*Dirwatch{}
+		selfHeal   = controller.Options.WatchdogSelfHeal
+		slow       = []*Client{}
+		stall      = watchdog.GetStallTimeout()
+	)
+
+	if l, c := len(controller.Ingest), cap(controller.Ingest); c > 0 && l >= c*3/4 {
+		issues["ingest.queue"] = fmt.Sprintf("ingest queue backlog is %d/%d calls", l, c)
+	}
+
+	if l, c := controller.Conversions.QueueDepth(); c > 0 && l >= c*3/4 {
+		issues["conversion.queue"] = fmt.Sprintf("conversion queue backlog is %d/%d calls", l, c)
+	}
+
+	watchdog.mutex.Lock()
+	for name, t := range watchdog.busy {
+		if d := time.Since(t); d > stall {
+			issues[name+".stuck"] = fmt.Sprintf("%s has been running for %v", name, d.Round(time.Second))
+		}
+	}
+	watchdog.mutex.Unlock()
+
+	controller.Clients.Map.Range(func(k interface{}, _ interface{}) bool {
+		switch c := k.(type) {
+		case *Client:
+			if l, n := len(c.Send), cap(c.Send); n > 0 && l >= n*3/4 {
+				slow = append(slow, c)
+			}
+		}
+		return true
+	})
+
+	if len(slow) > 0 {
+		issues["broadcast.backlog"] = fmt.Sprintf("%d listener(s) have a broadcast backlog", len(slow))
+	}
+
+	for _, dirwatch := range controller.Dirwatches.List {
+		if dirwatch.Disabled || (dirwatch.watcher == nil && dirwatch.stop == nil) {
+			continue
+		}
+
+		if d := time.Since(time.Unix(0, atomic.LoadInt64(&dirwatch.heartbeat))); d > stall {
+			issues[fmt.Sprintf("dirwatch.%s", dirwatch.Directory)] = fmt.Sprintf("dirwatch event loop for %s is not responding since %v", dirwatch.Directory, d.Round(time.Second))
+			restart = append(restart, dirwatch)
+		}
+	}
+
+	watchdog.mutex.Lock()
+	for key, message := range issues {
+		if watchdog.issues[key] == "" {
+			controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("watchdog: %s", message))
+			controller.Admin.BroadcastNotice(map[string]interface{}{
+				"message": message,
+				"type":    "watchdog",
+			})
+		}
+	}
+	for key := range watchdog.issues {
+		if issues[key] == "" {
+			controller.Logs.LogEvent(LogLevelInfo, fmt.Sprintf("watchdog: %s recovered", key))
+		}
+	}
+	watchdog.issues = issues
+	watchdog.mutex.Unlock()
+
+	if !selfHeal {
+		return
+	}
+
+	for _, c := range slow {
+		controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("watchdog: disconnecting slow listener %s", c.GetRemoteAddr()))
+		c.Close()
+	}
+
+	for _, dirwatch := range restart {
+		controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("watchdog: restarting dirwatch %s", dirwatch.Directory))
+		dirwatch.Stop()
+		if err := dirwatch.Start(controller); err != nil {
+			controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("watchdog: %v", err))
+		}
+	}
+}
+
+func (watchdog *Watchdog) Enter(name string) func() {
+	watchdog.mutex.Lock()
+	watchdog.busy[name] = time.Now()
+	watchdog.mutex.Unlock()
+
+	return func() {
+		watchdog.mutex.Lock()
+		delete(watchdog.busy, name)
+		watchdog.mutex.Unlock()
+	}
+}
+
+func (watchdog *Watchdog) GetIssues() []string {
+	watchdog.mutex.Lock()
+	defer watchdog.mutex.Unlock()
+
+	list := []string{}
+	for _, message := range watchdog.issues {
+		list = append(list, message)
+	}
+
+	sort.Strings(list)
+
+	return list
+}
+
+func (watchdog *Watchdog) GetStallTimeout() time.Duration {
+	if v := watchdog.Controller.Options.WatchdogStallTimeout; v > 0 {
+		return time.Duration(v) * time.Second
+	}
+	return time.Duration(defaults.options.watchdogStallTimeout) * time.Second
+}
+
+func (watchdog *Watchdog) Start() {
+	watchdog.ticker = time.NewTicker(WatchdogCheckInterval)
+
+	go func() {
+		for range watchdog.ticker.C {
+			watchdog.Check()
+		}
+	}()
+}
@@ -0,0 +1,434 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/dhowden/tag"
+)
+
+const (
+	WatermarkBitDuration        = 0.04
+	WatermarkBits               = 24
+	WatermarkIdentAmplitude     = 0.15
+	WatermarkIdentFrequency     = 800
+	WatermarkIdentPeriod        = 20
+	WatermarkInaudibleAmplitude = 0.015
+	WatermarkInaudiblePeriod    = 10
+	WatermarkMaxId              = 0xffff
+	WatermarkMaxSize            = 32 << 20
+	WatermarkOneFrequency       = 3200
+	WatermarkPreambleDuration   = 0.2
+	WatermarkPreambleFrequency  = 2000
+	WatermarkSampleRate         = 22050
+	WatermarkZeroFrequency      = 2600
+)
+
+var watermarkMorse = map[rune]string{
+	'0': "-----",
+	'1': ".----",
+	'2': "..---",
+	'3': "...--",
+	'4': "....-",
+	'5': ".....",
+	'6': "-....",
+	'7': "--...",
+	'8': "---..",
+	'9': "----.",
+	'D': "-..",
+	'I': "..",
+}
+
+func (controller *Controller) GetWatermarkedCall(client *Client, call *Call) *Call {
+	if client == nil || client.Access == nil || len(call.Audio) == 0 {
+		return call
+	}
+
+	access := client.Access
+
+	switch access.Watermark {
+	case AccessWatermarkIdent, AccessWatermarkInaudible:
+	default:
+		return call
+	}
+
+	id, ok := access.Id.(uint)
+	if !ok || id > WatermarkMaxId {
+		return call
+	}
+
+	audio, err := controller.FFMpeg.Watermark(call.Audio, NewWatermarkWav(id, access.Watermark), fmt.Sprintf("watermark %d", id))
+	if err != nil {
+		controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("watermark: call=%v %v", call.Id, err))
+		return call
+	}
+
+	c := *call
+	c.Audio = audio
+	c.AudioType = "audio/mp4"
+
+	switch v := call.AudioName.(type) {
+	case string:
+		c.AudioName = fmt.Sprintf("%v.m4a", strings.TrimSuffix(v, path.Ext(v)))
+	}
+
+	return &c
+}
+
+func (controller *Controller) TraceWatermark(audio []byte) (uint, string, uint, bool) {
+	if m, err := tag.ReadFrom(bytes.NewReader(audio)); err == nil {
+		var id uint
+		if _, err = fmt.Sscanf(m.Comment(), "watermark %d", &id); err == nil {
+			return id, "metadata", 0, true
+		}
+	}
+
+	wav, err := controller.FFMpeg.ToWav(audio)
+	if err != nil {
+		controller.Logs.LogEvent(LogLevelWarn, fmt.Sprintf("watermark: %v", err))
+		return 0, "", 0, false
+	}
+
+	samples, sampleRate, ok := ParseWavPcm(wav)
+	if !ok {
+		return 0, "", 0, false
+	}
+
+	id, votes, ok := DecodeWatermark(samples, sampleRate)
+
+	return id, "signal", votes, ok
+}
+
+func (admin *Admin) WatermarkHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		id     uint
+		ok     bool
+		source string
+		votes  uint
+	)
+
+	t := admin.GetAuthorization(r)
+	if !admin.ValidateToken(t) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	logError := func(err error) {
+		admin.Controller.Logs.LogEvent(LogLevelError, fmt.Sprintf("admin.watermarkhandler: %s", err.Error()))
+	}
+
+	writeError := func(err error) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+	}
+
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, WatermarkMaxSize)
+
+	if err := r.ParseMultipartForm(WatermarkMaxSize); err != nil {
+		writeError(err)
+		return
+	}
+
+	if v := r.FormValue("id"); len(v) > 0 {
+		i, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			writeError(fmt.Errorf("invalid watermark id %s", v))
+			return
+		}
+		id, ok, source = uint(i), true, "id"
+
+	} else {
+		f, _, err := r.FormFile("audio")
+		if err != nil {
+			writeError(err)
+			return
+		}
+		defer f.Close()
+
+		audio, err := io.ReadAll(f)
+		if err != nil {
+			writeError(err)
+			return
+		}
+
+		id, source, votes, ok = admin.Controller.TraceWatermark(audio)
+	}
+
+	res := map[string]interface{}{"found": ok}
+
+	if ok {
+		res["id"] = id
+		res["source"] = source
+
+		if votes > 0 {
+			res["votes"] = votes
+		}
+
+		admin.Controller.Accesses.mutex.Lock()
+		for _, access := range admin.Controller.Accesses.List {
+			if v, ok := access.Id.(uint); ok && v == id {
+				res["access"] = map[string]interface{}{
+					"_id":   access.Id,
+					"code":  access.Code,
+					"ident": access.Ident,
+				}
+				break
+			}
+		}
+		admin.Controller.Accesses.mutex.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		logError(err)
+	}
+}
+
+func DecodeWatermark(samples []float64, sampleRate int) (uint, uint, bool) {
+	var (
+		best  uint
+		found bool
+	)
+
+	preamble := int(WatermarkPreambleDuration * float64(sampleRate))
+	bit := int(WatermarkBitDuration * float64(sampleRate))
+	frame := preamble + WatermarkBits*bit
+	step := sampleRate / 200
+
+	votes := map[uint]uint{}
+
+	amplitude := func(x []float64, frequency float64) float64 {
+		return 2 * math.Sqrt(goertzel(x, frequency, sampleRate)) / float64(len(x))
+	}
+
+	for offset := 0; offset+frame <= len(samples); offset += step {
+		x := samples[offset : offset+preamble]
+
+		p := amplitude(x, WatermarkPreambleFrequency)
+		if p < WatermarkInaudibleAmplitude/4 || p < 4*(amplitude(x, WatermarkPreambleFrequency-300)+amplitude(x, WatermarkPreambleFrequency+300)) {
+			continue
+		}
+
+		var value uint
+
+		valid := true
+
+		for i := 0; i < WatermarkBits; i++ {
+			x = samples[offset+preamble+i*bit : offset+preamble+(i+1)*bit]
+
+			zero := amplitude(x, WatermarkZeroFrequency)
+			one := amplitude(x, WatermarkOneFrequency)
+
+			if math.Max(zero, one) < WatermarkInaudibleAmplitude/4 || math.Max(zero, one) < 2*math.Min(zero, one) {
+				valid = false
+				break
+			}
+
+			value <<= 1
+			if one > zero {
+				value |= 1
+			}
+		}
+
+		if !valid {
+			continue
+		}
+
+		id := value >> 8
+		if getWatermarkChecksum(id) != value&0xff {
+			continue
+		}
+
+		votes[id]++
+
+		offset += frame - step
+	}
+
+	for id, count := range votes {
+		if !found || count > votes[best] {
+			best, found = id, true
+		}
+	}
+
+	return best, votes[best], found
+}
+
+func NewWatermarkWav(id uint, mode string) []byte {
+	var (
+		period  int
+		samples []float64
+	)
+
+	phase := 0.0
+
+	tone := func(frequency float64, amplitude float64, duration float64) {
+		n := int(duration * WatermarkSampleRate)
+		for i := 0; i < n; i++ {
+			samples = append(samples, amplitude*math.Sin(phase))
+			phase += 2 * math.Pi * frequency / WatermarkSampleRate
+		}
+	}
+
+	silence := func(duration float64) {
+		n := int(duration * WatermarkSampleRate)
+		samples = append(samples, make([]float64, n)...)
+	}
+
+	switch mode {
+	case AccessWatermarkIdent:
+		period = WatermarkIdentPeriod
+
+		dot := 0.06
+
+		for _, r := range fmt.Sprintf("ID %d", id) {
+			if r == ' ' {
+				silence(4 * dot)
+				continue
+			}
+
+			for _, s := range watermarkMorse[r] {
+				if s == '-' {
+					tone(WatermarkIdentFrequency, WatermarkIdentAmplitude, 3*dot)
+				} else {
+					tone(WatermarkIdentFrequency, WatermarkIdentAmplitude, dot)
+				}
+				silence(dot)
+			}
+
+			silence(2 * dot)
+		}
+
+	default:
+		period = WatermarkInaudiblePeriod
+
+		value := id<<8 | getWatermarkChecksum(id)
+
+		tone(WatermarkPreambleFrequency, WatermarkInaudibleAmplitude, WatermarkPreambleDuration)
+
+		for i := WatermarkBits - 1; i >= 0; i-- {
+			if value>>uint(i)&1 == 1 {
+				tone(WatermarkOneFrequency, WatermarkInaudibleAmplitude, WatermarkBitDuration)
+			} else {
+				tone(WatermarkZeroFrequency, WatermarkInaudibleAmplitude, WatermarkBitDuration)
+			}
+		}
+	}
+
+	ramp := WatermarkSampleRate / 250
+	for i := 0; i < ramp && i < len(samples); i++ {
+		samples[i] *= float64(i) / float64(ramp)
+		samples[len(samples)-1-i] *= float64(i) / float64(ramp)
+	}
+
+	if n := period * WatermarkSampleRate; len(samples) < n {
+		samples = append(samples, make([]float64, n-len(samples))...)
+	}
+
+	b := bytes.NewBuffer(nil)
+
+	b.WriteString("RIFF")
+	binary.Write(b, binary.LittleEndian, uint32(36+2*len(samples)))
+	b.WriteString("WAVEfmt ")
+	binary.Write(b, binary.LittleEndian, uint32(16))
+	binary.Write(b, binary.LittleEndian, []uint16{1, 1})
+	binary.Write(b, binary.LittleEndian, []uint32{WatermarkSampleRate, 2 * WatermarkSampleRate})
+	binary.Write(b, binary.LittleEndian, []uint16{2, 16})
+	b.WriteString("data")
+	binary.Write(b, binary.LittleEndian, uint32(2*len(samples)))
+
+	for _, s := range samples {
+		binary.Write(b, binary.LittleEndian, int16(s*math.MaxInt16))
+	}
+
+	return b.Bytes()
+}
+
+func ParseWavPcm(wav []byte) ([]float64, int, bool) {
+	var sampleRate int
+
+	if len(wav) < 12 || string(wav[0:4]) != "RIFF" || string(wav[8:12]) != "WAVE" {
+		return nil, 0, false
+	}
+
+	for i := 12; i+8 <= len(wav); {
+		id := string(wav[i : i+4])
+		size := int(binary.LittleEndian.Uint32(wav[i+4 : i+8]))
+
+		switch id {
+		case "fmt ":
+			if i+24 > len(wav) || binary.LittleEndian.Uint16(wav[i+8:i+10]) != 1 || binary.LittleEndian.Uint16(wav[i+10:i+12]) != 1 || binary.LittleEndian.Uint16(wav[i+22:i+24]) != 16 {
+				return nil, 0, false
+			}
+			sampleRate = int(binary.LittleEndian.Uint32(wav[i+12 : i+16]))
+
+		case "data":
+			if sampleRate == 0 {
+				return nil, 0, false
+			}
+
+			data := wav[i+8:]
+			if size > 0 && size < len(data) {
+				data = data[:size]
+			}
+
+			samples := make([]float64, len(data)/2)
+			for j := range samples {
+				samples[j] = float64(int16(binary.LittleEndian.Uint16(data[2*j:]))) / math.MaxInt16
+			}
+
+			return samples, sampleRate, true
+		}
+
+		i += 8 + size + size%2
+	}
+
+	return nil, 0, false
+}
+
+func getWatermarkChecksum(id uint) uint {
+	return (id>>8 ^ id&0xff ^ 0xa5) & 0xff
+}
+
+func goertzel(x []float64, frequency float64, sampleRate int) float64 {
+	var s1, s2 float64
+
+	coeff := 2 * math.Cos(2*math.Pi*frequency/float64(sampleRate))
+
+	for _, v := range x {
+		s0 := v + coeff*s1 - s2
+		s2 = s1
+		s1 = s0
+	}
+
+	return s1*s1 + s2*s2 - coeff*s1*s2
+}
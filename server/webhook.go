@@ -0,0 +1,528 @@
+// Copyright (C) 2019-2022 Chrystian Huot <chrystian.huot@saubeo.solutions>
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const WebhookTimeout = 10 * time.Second
+
+type Webhook struct {
+	Id        interface{} `json:"_id"`
+	AudioLink bool        `json:"audioLink"`
+	Disabled  bool        `json:"disabled"`
+	Headers   string      `json:"headers"`
+	Order     interface{} `json:"order"`
+	Systems   interface{} `json:"systems"`
+	Template  string      `json:"template"`
+	Url       string      `json:"url"`
+	template  *template.Template
+	tplError  error
+}
+
+func (webhook *Webhook) FromMap(m map[string]interface{}) *Webhook {
+	switch v := m["_id"].(type) {
+	case float64:
+		webhook.Id = uint(v)
+	}
+
+	switch v := m["audioLink"].(type) {
+	case bool:
+		webhook.AudioLink = v
+	}
+
+	switch v := m["disabled"].(type) {
+	case bool:
+		webhook.Disabled = v
+	}
+
+	switch v := m["headers"].(type) {
+	case string:
+		webhook.Headers = v
+	}
+
+	switch v := m["order"].(type) {
+	case float64:
+		webhook.Order = uint(v)
+	}
+
+	switch v := m["systems"].(type) {
+	case []interface{}:
+		if b, err := json.Marshal(v); err == nil {
+			webhook.Systems = string(b)
+		}
+	case string:
+		webhook.Systems = v
+	}
+
+	switch v := m["template"].(type) {
+	case string:
+		webhook.Template = v
+	}
+
+	switch v := m["url"].(type) {
+	case string:
+		webhook.Url = v
+	}
+
+	return webhook
+}
+
+func (webhook *Webhook) HasAccess(call *Call) bool {
+	if webhook.Disabled {
+		return false
+	}
+
+	switch v := webhook.Systems.(type) {
+	case []interface{}:
+		for _, f := range v {
+			switch v := f.(type) {
+			case map[string]interface{}:
+				switch id := v["id"].(type) {
+				case float64:
+					if id == float64(call.System) {
+						switch tg := v["talkgroups"].(type) {
+						case string:
+							if tg == "*" {
+								return true
+							}
+						case []interface{}:
+							for _, f := range tg {
+								switch tg := f.(type) {
+								case float64:
+									if tg == float64(call.Talkgroup) {
+										return true
+									}
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+
+	case string:
+		if v == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (webhook *Webhook) Payload(call *Call, options *Options) ([]byte, error) {
+	m := map[string]interface{}{
+		"dateTime":  call.DateTime.Format(time.RFC3339),
+		"id":        call.Id,
+		"system":    call.System,
+		"talkgroup": call.Talkgroup,
+	}
+
+	for k, v := range map[string]interface{}{
+		"audioName":      call.AudioName,
+		"audioType":      call.AudioType,
+		"frequency":      call.Frequency,
+		"patches":        call.Patches,
+		"source":         call.Source,
+		"sources":        call.Sources,
+		"systemLabel":    call.systemLabel,
+		"talkgroupGroup": call.talkgroupGroup,
+		"talkgroupLabel": call.talkgroupLabel,
+		"talkgroupName":  call.talkgroupName,
+		"talkgroupTag":   call.talkgroupTag,
+	} {
+		if v != nil {
+			m[k] = v
+		}
+	}
+
+	if call.duration > 0 {
+		m["duration"] = call.duration
+	}
+
+	if webhook.AudioLink {
+		if s := GetWebhookAudioUrl(call, options); len(s) > 0 {
+			m["audioUrl"] = s
+		}
+	}
+
+	if webhook.tplError != nil {
+		return nil, webhook.tplError
+	}
+
+	if webhook.template == nil {
+		return json.Marshal(m)
+	}
+
+	buf := bytes.Buffer{}
+
+	if err := webhook.template.Execute(&buf, m); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (webhook *Webhook) Send(call *Call, options *Options) error {
+	if webhook.Disabled {
+		return nil
+	}
+
+	return webhook.send(call, options, nil)
+}
+
+func (webhook *Webhook) Test(call *Call, options *Options) *DeliveryTest {
+	test := &DeliveryTest{Delivery: "webhook", Target: webhook.Url}
+
+	start := time.Now()
+
+	if err := webhook.send(call, options, test); err != nil {
+		test.Error = err.Error()
+	} else {
+		test.Success = true
+	}
+
+	test.Duration = uint(time.Since(start).Milliseconds())
+
+	return test
+}
+
+func (webhook *Webhook) parseTemplate() error {
+	webhook.template = nil
+	webhook.tplError = nil
+
+	if len(strings.TrimSpace(webhook.Template)) == 0 {
+		return nil
+	}
+
+	t, err := template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}).Parse(webhook.Template)
+	if err != nil {
+		webhook.tplError = err
+		return err
+	}
+
+	webhook.template = t
+
+	return nil
+}
+
+func (webhook *Webhook) send(call *Call, options *Options, test *DeliveryTest) error {
+	formatError := func(err error) error {
+		return fmt.Errorf("webhook.send: %v", err)
+	}
+
+	b, err := webhook.Payload(call, options)
+	if err != nil {
+		return formatError(err)
+	}
+
+	if test != nil {
+		test.Payload = string(b)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook.Url, bytes.NewReader(b))
+	if err != nil {
+		return formatError(err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", fmt.Sprintf("rdio-scanner/%s", Version))
+
+	for _, line := range strings.Split(webhook.Headers, "\n") {
+		if kv := strings.SplitN(line, ":", 2); len(kv) == 2 && len(strings.TrimSpace(kv[0])) > 0 {
+			req.Header.Set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+		}
+	}
+
+	c := http.Client{Timeout: WebhookTimeout}
+
+	res, err := c.Do(req)
+	if err != nil {
+		return formatError(err)
+	}
+
+	if test != nil {
+		test.Status = res.StatusCode
+		test.Response = ReadDeliveryTestResponse(res.Body)
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return formatError(fmt.Errorf("bad status: %s", res.Status))
+	}
+
+	return nil
+}
+
+type Webhooks struct {
+	List  []*Webhook
+	mutex sync.Mutex
+}
+
+func NewWebhooks() *Webhooks {
+	return &Webhooks{
+		List:  []*Webhook{},
+		mutex: sync.Mutex{},
+	}
+}
+
+func (webhooks *Webhooks) FromMap(f []interface{}) *Webhooks {
+	webhooks.mutex.Lock()
+	defer webhooks.mutex.Unlock()
+
+	webhooks.List = []*Webhook{}
+
+	for _, r := range f {
+		switch m := r.(type) {
+		case map[string]interface{}:
+			webhook := &Webhook{}
+			webhook.FromMap(m)
+			webhooks.List = append(webhooks.List, webhook)
+		}
+	}
+
+	return webhooks
+}
+
+func (webhooks *Webhooks) Read(db *Database) error {
+	var (
+		err     error
+		id      sql.NullFloat64
+		order   sql.NullFloat64
+		rows    *sql.Rows
+		systems string
+	)
+
+	webhooks.mutex.Lock()
+	defer webhooks.mutex.Unlock()
+
+	webhooks.List = []*Webhook{}
+
+	formatError := func(err error) error {
+		return fmt.Errorf("webhooks.read: %v", err)
+	}
+
+	if rows, err = db.Sql.Query("select `_id`, `audioLink`, `disabled`, `headers`, `order`, `systems`, `template`, `url` from `rdioScannerWebhooks`"); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		webhook := &Webhook{}
+
+		if err = rows.Scan(&id, &webhook.AudioLink, &webhook.Disabled, &webhook.Headers, &order, &systems, &webhook.Template, &webhook.Url); err != nil {
+			break
+		}
+
+		if id.Valid && id.Float64 > 0 {
+			webhook.Id = uint(id.Float64)
+		}
+
+		if order.Valid && order.Float64 > 0 {
+			webhook.Order = uint(order.Float64)
+		}
+
+		if json.Unmarshal([]byte(systems), &webhook.Systems) != nil {
+			webhook.Systems = []interface{}{}
+		}
+
+		if len(webhook.Url) == 0 {
+			continue
+		}
+
+		webhook.parseTemplate()
+
+		webhooks.List = append(webhooks.List, webhook)
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	return nil
+}
+
+func (webhooks *Webhooks) Send(controller *Controller, call *Call) {
+	webhooks.mutex.Lock()
+	defer webhooks.mutex.Unlock()
+
+	for _, webhook := range webhooks.List {
+		if !webhook.HasAccess(call) {
+			continue
+		}
+
+		go func(webhook *Webhook) {
+			logEvent := func(logLevel string, message string) {
+				controller.Logs.LogEvent(logLevel, fmt.Sprintf("webhook: system=%v talkgroup=%v file=%v to %v %v", call.System, call.Talkgroup, call.AudioName, webhook.Url, message))
+			}
+
+			if err := webhook.Send(call, controller.Options); err == nil {
+				logEvent(LogLevelInfo, "success")
+			} else {
+				logEvent(LogLevelError, err.Error())
+			}
+		}(webhook)
+	}
+}
+
+func (webhooks *Webhooks) Validate() error {
+	for _, webhook := range webhooks.List {
+		if err := webhook.parseTemplate(); err != nil {
+			return fmt.Errorf("webhook %v: %v", webhook.Url, err)
+		}
+	}
+
+	return nil
+}
+
+func (webhooks *Webhooks) Write(db *Database) error {
+	var (
+		count   uint
+		err     error
+		rows    *sql.Rows
+		rowIds  = []uint{}
+		systems interface{}
+	)
+
+	webhooks.mutex.Lock()
+	defer webhooks.mutex.Unlock()
+
+	formatError := func(err error) error {
+		return fmt.Errorf("webhooks.write: %v", err)
+	}
+
+	for _, webhook := range webhooks.List {
+		switch webhook.Systems {
+		case "*":
+			systems = `"*"`
+		default:
+			systems = webhook.Systems
+		}
+
+		if err = db.Sql.QueryRow("select count(*) from `rdioScannerWebhooks` where `_id` = ?", webhook.Id).Scan(&count); err != nil {
+			break
+		}
+
+		if count == 0 {
+			if _, err = db.Sql.Exec("insert into `rdioScannerWebhooks` (`_id`, `audioLink`, `disabled`, `headers`, `order`, `systems`, `template`, `url`) values (?, ?, ?, ?, ?, ?, ?, ?)", webhook.Id, webhook.AudioLink, webhook.Disabled, webhook.Headers, webhook.Order, systems, webhook.Template, webhook.Url); err != nil {
+				break
+			}
+
+		} else if _, err = db.Sql.Exec("update `rdioScannerWebhooks` set `_id` = ?, `audioLink` = ?, `disabled` = ?, `headers` = ?, `order` = ?, `systems` = ?, `template` = ?, `url` = ? where `_id` = ?", webhook.Id, webhook.AudioLink, webhook.Disabled, webhook.Headers, webhook.Order, systems, webhook.Template, webhook.Url, webhook.Id); err != nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if rows, err = db.Sql.Query("select `_id` from `rdioScannerWebhooks`"); err != nil {
+		return formatError(err)
+	}
+
+	for rows.Next() {
+		var rowId uint
+		if err = rows.Scan(&rowId); err != nil {
+			break
+		}
+		remove := true
+		for _, webhook := range webhooks.List {
+			if webhook.Id == nil || webhook.Id == rowId {
+				remove = false
+				break
+			}
+		}
+		if remove {
+			rowIds = append(rowIds, rowId)
+		}
+	}
+
+	rows.Close()
+
+	if err != nil {
+		return formatError(err)
+	}
+
+	if len(rowIds) > 0 {
+		if b, err := json.Marshal(rowIds); err == nil {
+			s := string(b)
+			s = strings.ReplaceAll(s, "[", "(")
+			s = strings.ReplaceAll(s, "]", ")")
+			q := fmt.Sprintf("delete from `rdioScannerWebhooks` where `_id` in %v", s)
+			if _, err = db.Sql.Exec(q); err != nil {
+				return formatError(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func GetWebhookAudioSignature(id uint, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("call-audio:%d", id)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func GetWebhookAudioUrl(call *Call, options *Options) string {
+	var id uint
+
+	switch v := call.Id.(type) {
+	case uint:
+		id = v
+	default:
+		return ""
+	}
+
+	if call.streamOnly || len(options.PublicUrl) == 0 || len(options.secret) == 0 {
+		return ""
+	}
+
+	u, err := url.Parse(options.PublicUrl)
+	if err != nil {
+		return ""
+	}
+
+	u.Path = path.Join(u.Path, "/api/call-audio")
+	u.RawQuery = url.Values{
+		"id":  []string{fmt.Sprintf("%d", id)},
+		"sig": []string{GetWebhookAudioSignature(id, options.secret)},
+	}.Encode()
+
+	return u.String()
+}